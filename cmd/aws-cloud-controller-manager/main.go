@@ -31,6 +31,7 @@ import (
 
 	"k8s.io/apimachinery/pkg/util/wait"
 	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider-aws/pkg/controllers/route"
 	"k8s.io/cloud-provider-aws/pkg/controllers/tagging"
 	awsv1 "k8s.io/cloud-provider-aws/pkg/providers/v1"
 	"k8s.io/cloud-provider/app"
@@ -75,8 +76,22 @@ func main() {
 	controllerInitializers[tagging.TaggingControllerKey] = taggingControllerConstructor
 	app.ControllersDisabledByDefault.Insert(tagging.TaggingControllerKey)
 
+	routeControllerWrapper := route.ControllerWrapper{}
+	routeControllerWrapper.Options.AddFlags(fss.FlagSet("coalescing route controller"))
+
+	routeControllerConstructor := app.ControllerInitFuncConstructor{
+		InitContext: app.ControllerInitContext{
+			ClientName: route.RouteControllerClientName,
+		},
+		Constructor: routeControllerWrapper.StartRouteControllerWrapper,
+	}
+
+	controllerInitializers[route.RouteControllerKey] = routeControllerConstructor
+	app.ControllersDisabledByDefault.Insert(route.RouteControllerKey)
+
 	controllerAliases := names.CCMControllerAliases()
 	controllerAliases[tagging.TaggingControllerKey] = tagging.TaggingControllerKey
+	controllerAliases[route.RouteControllerKey] = route.RouteControllerKey
 
 	command := app.NewCloudControllerManagerCommand(opts, cloudInitializer, controllerInitializers, controllerAliases, fss, wait.NeverStop)
 
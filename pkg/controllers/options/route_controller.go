@@ -0,0 +1,49 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package options
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// RouteControllerOptions contains the inputs that can
+// be used in the route controller
+type RouteControllerOptions struct {
+	ResyncPeriod time.Duration
+	WorkerCount  int
+}
+
+// AddFlags add the additional flags for the controller
+func (o *RouteControllerOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.DurationVar(&o.ResyncPeriod, "route-controller-resync-period", 30*time.Minute,
+		"The period at which the route controller reconciles the routes of all nodes, in between only nodes whose pod CIDRs changed are reconciled.")
+	fs.IntVar(&o.WorkerCount, "route-controller-concurrent-node-syncs", 10,
+		"The number of workers concurrently reconciling the routes of nodes")
+}
+
+// Validate checks for errors from user input
+func (o *RouteControllerOptions) Validate() error {
+	if o.ResyncPeriod <= 0 {
+		return fmt.Errorf("--route-controller-resync-period must be a positive duration")
+	}
+
+	if o.WorkerCount <= 0 {
+		return fmt.Errorf("--route-controller-concurrent-node-syncs must be a positive number")
+	}
+
+	return nil
+}
@@ -0,0 +1,411 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coreinformers "k8s.io/client-go/informers/core/v1"
+	clientset "k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	clientretry "k8s.io/client-go/util/retry"
+	"k8s.io/client-go/util/workqueue"
+	cloudprovider "k8s.io/cloud-provider"
+	_ "k8s.io/component-base/metrics/prometheus/workqueue" // enable prometheus provider for workqueue metrics
+	nodeutil "k8s.io/component-helpers/node/util"
+	"k8s.io/klog/v2"
+	netutils "k8s.io/utils/net"
+)
+
+const (
+	// maxRequeuingCount is how many times a node is retried before it is left to the next resync
+	maxRequeuingCount = 9
+	// routesSnapshotMaxAge is how long the listed routes are shared by the workers, so that syncing a batch of queued
+	// nodes lists the routes once rather than once per node
+	routesSnapshotMaxAge = 10 * time.Second
+)
+
+var updateNetworkConditionBackoff = wait.Backoff{
+	Steps:    5, // Maximum number of retries.
+	Duration: 100 * time.Millisecond,
+	Jitter:   1.0,
+}
+
+// Controller is the controller implementation for the routes of the pod CIDRs of nodes.
+// Unlike the upstream route controller, which reconciles the routes of every node on every
+// sync, it queues the names of the nodes whose pod CIDRs changed and reconciles the routes
+// of those nodes only. The queue holds a node at most once, so the changes a node goes
+// through before a worker picks it up are coalesced into a single reconciliation, and the
+// queue is bounded by the number of nodes. All nodes are requeued every resyncPeriod, which
+// also removes the blackholed routes and the routes of nodes that were deleted while the
+// controller was not running. The workers share a snapshot of the routes for
+// routesSnapshotMaxAge, which they update with the routes they create and delete.
+type Controller struct {
+	routes       cloudprovider.Routes
+	kubeClient   clientset.Interface
+	clusterName  string
+	clusterCIDRs []*net.IPNet
+	nodeLister   corelisters.NodeLister
+	nodesSynced  cache.InformerSynced
+	workqueue    workqueue.TypedRateLimitingInterface[string]
+
+	resyncPeriod time.Duration
+	workerCount  int
+
+	routesMu       sync.Mutex
+	routesSnapshot []*cloudprovider.Route
+	routesListed   time.Time
+}
+
+// NewRouteController creates a NewRouteController object
+func NewRouteController(
+	nodeInformer coreinformers.NodeInformer,
+	kubeClient clientset.Interface,
+	routes cloudprovider.Routes,
+	clusterName string,
+	clusterCIDRs []*net.IPNet,
+	resyncPeriod time.Duration,
+	workerCount int) (*Controller, error) {
+	if len(clusterCIDRs) == 0 {
+		return nil, fmt.Errorf("route controller requires at least one cluster CIDR")
+	}
+
+	rc := &Controller{
+		routes:       routes,
+		kubeClient:   kubeClient,
+		clusterName:  clusterName,
+		clusterCIDRs: clusterCIDRs,
+		nodeLister:   nodeInformer.Lister(),
+		nodesSynced:  nodeInformer.Informer().HasSynced,
+		workqueue: workqueue.NewTypedRateLimitingQueueWithConfig(workqueue.DefaultTypedControllerRateLimiter[string](), workqueue.TypedRateLimitingQueueConfig[string]{
+			Name: RouteControllerClientName,
+		}),
+		resyncPeriod: resyncPeriod,
+		workerCount:  workerCount,
+	}
+
+	// Use shared informer to listen to add/update/delete of nodes. Note that any nodes
+	// that exist before route controller starts will show up in the add method
+	nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			node := obj.(*v1.Node)
+			if len(node.Spec.PodCIDRs) == 0 {
+				return
+			}
+			rc.enqueueNode(node.Name)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode := oldObj.(*v1.Node)
+			newNode := newObj.(*v1.Node)
+			// Status updates, such as heartbeats, don't change the routes of a node
+			if sets.NewString(oldNode.Spec.PodCIDRs...).Equal(sets.NewString(newNode.Spec.PodCIDRs...)) {
+				return
+			}
+			rc.enqueueNode(newNode.Name)
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			node, ok := obj.(*v1.Node)
+			if !ok {
+				utilruntime.HandleError(fmt.Errorf("expected node in delete event but got %T", obj))
+				return
+			}
+			rc.enqueueNode(node.Name)
+		},
+	})
+
+	return rc, nil
+}
+
+// Run will start the controller to reconcile the routes of the nodes
+// queued by node events and by the periodic resync.
+func (rc *Controller) Run(ctx context.Context) {
+	defer utilruntime.HandleCrash()
+	defer rc.workqueue.ShutDown()
+
+	// Wait for the caches to be synced before starting workers
+	klog.Info("Waiting for informer caches to sync")
+	if ok := cache.WaitForCacheSync(ctx.Done(), rc.nodesSynced); !ok {
+		klog.Errorf("failed to wait for caches to sync")
+		return
+	}
+
+	klog.Infof("Starting the route controller")
+	for i := 0; i < rc.workerCount; i++ {
+		go wait.UntilWithContext(ctx, rc.work, time.Second)
+	}
+
+	go wait.NonSlidingUntilWithContext(ctx, func(ctx context.Context) {
+		if err := rc.resync(ctx); err != nil {
+			klog.Errorf("Couldn't resync node routes: %v", err)
+		}
+	}, rc.resyncPeriod)
+
+	<-ctx.Done()
+}
+
+// work is a long-running function that continuously
+// call process() for each node on the workqueue
+func (rc *Controller) work(ctx context.Context) {
+	for rc.process(ctx) {
+	}
+}
+
+// process reads a node name from the queue and reconciles the routes of that node
+func (rc *Controller) process(ctx context.Context) bool {
+	nodeName, shutdown := rc.workqueue.Get()
+	if shutdown {
+		return false
+	}
+	defer rc.workqueue.Done(nodeName)
+
+	if err := rc.syncNode(ctx, nodeName); err != nil {
+		numRetries := rc.workqueue.NumRequeues(nodeName)
+		if numRetries < maxRequeuingCount {
+			// Put the node back on the workqueue to handle any transient errors.
+			rc.workqueue.AddRateLimited(nodeName)
+			utilruntime.HandleError(fmt.Errorf("error reconciling routes of node %s: %v, requeuing count %d", nodeName, err, numRetries))
+			return true
+		}
+		utilruntime.HandleError(fmt.Errorf("error reconciling routes of node %s, requeuing count exceeded: %v", nodeName, err))
+	}
+
+	rc.workqueue.Forget(nodeName)
+	return true
+}
+
+// resync deletes the blackholed routes and queues every node, along with the target
+// nodes of routes whose node no longer exists so that their routes get deleted
+func (rc *Controller) resync(ctx context.Context) error {
+	// The queued nodes are synced with the routes listed here, as long as the snapshot is fresh
+	rc.invalidateRoutes()
+	routes, err := rc.listRoutes(ctx)
+	if err != nil {
+		return err
+	}
+	nodes, err := rc.nodeLister.List(labels.Everything())
+	if err != nil {
+		return fmt.Errorf("error listing nodes: %v", err)
+	}
+
+	var errs []error
+	for _, route := range routes {
+		if !rc.isResponsibleForRoute(route) {
+			continue
+		}
+		if route.Blackhole {
+			if err := rc.deleteRoute(ctx, route); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if route.TargetNode != "" {
+			rc.enqueueNode(string(route.TargetNode))
+		}
+	}
+	for _, node := range nodes {
+		if len(node.Spec.PodCIDRs) == 0 {
+			continue
+		}
+		rc.enqueueNode(node.Name)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// syncNode creates the missing routes to the pod CIDRs of a node and deletes its
+// routes to other CIDRs, or all of its routes if the node was deleted
+func (rc *Controller) syncNode(ctx context.Context, nodeName string) error {
+	node, err := rc.nodeLister.Get(nodeName)
+	if apierrors.IsNotFound(err) {
+		node = nil
+	} else if err != nil {
+		return err
+	}
+
+	routes, err := rc.listRoutes(ctx)
+	if err != nil {
+		return err
+	}
+
+	podCIDRs := sets.NewString()
+	if node != nil {
+		podCIDRs.Insert(node.Spec.PodCIDRs...)
+	}
+
+	var errs []error
+	routedCIDRs := sets.NewString()
+	for _, route := range routes {
+		if route.TargetNode != types.NodeName(nodeName) || !rc.isResponsibleForRoute(route) {
+			continue
+		}
+		if podCIDRs.Has(route.DestinationCIDR) {
+			routedCIDRs.Insert(route.DestinationCIDR)
+			continue
+		}
+		if err := rc.deleteRoute(ctx, route); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if node == nil || len(node.Spec.PodCIDRs) == 0 {
+		return utilerrors.NewAggregate(errs)
+	}
+
+	allRoutesCreated := true
+	for _, podCIDR := range node.Spec.PodCIDRs {
+		if routedCIDRs.Has(podCIDR) {
+			continue
+		}
+		route := &cloudprovider.Route{
+			TargetNode:          types.NodeName(node.Name),
+			TargetNodeAddresses: node.Status.Addresses,
+			DestinationCIDR:     podCIDR,
+		}
+		klog.Infof("Creating route for node %s %s", node.Name, podCIDR)
+		if err := rc.routes.CreateRoute(ctx, rc.clusterName, string(node.UID), route); err != nil {
+			// The route may have been created since the routes were listed
+			rc.invalidateRoutes()
+			allRoutesCreated = false
+			errs = append(errs, fmt.Errorf("could not create route %s for node %s: %v", podCIDR, node.Name, err))
+			continue
+		}
+		rc.updateRoutes(func(routes []*cloudprovider.Route) []*cloudprovider.Route { return append(routes, route) })
+		klog.Infof("Created route for node %s %s", node.Name, podCIDR)
+	}
+
+	if err := rc.updateNetworkingCondition(node, allRoutesCreated); err != nil {
+		errs = append(errs, err)
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+func (rc *Controller) deleteRoute(ctx context.Context, route *cloudprovider.Route) error {
+	klog.Infof("Deleting route %s %s", route.Name, route.DestinationCIDR)
+	if err := rc.routes.DeleteRoute(ctx, rc.clusterName, route); err != nil {
+		rc.invalidateRoutes()
+		return fmt.Errorf("could not delete route %s %s: %v", route.Name, route.DestinationCIDR, err)
+	}
+	rc.updateRoutes(func(routes []*cloudprovider.Route) []*cloudprovider.Route {
+		var kept []*cloudprovider.Route
+		for _, r := range routes {
+			if r != route {
+				kept = append(kept, r)
+			}
+		}
+		return kept
+	})
+	klog.Infof("Deleted route %s %s", route.Name, route.DestinationCIDR)
+	return nil
+}
+
+// listRoutes returns the snapshot of the routes, listing them again once the snapshot is older than
+// routesSnapshotMaxAge
+func (rc *Controller) listRoutes(ctx context.Context) ([]*cloudprovider.Route, error) {
+	rc.routesMu.Lock()
+	defer rc.routesMu.Unlock()
+	if rc.routesListed.IsZero() || time.Since(rc.routesListed) > routesSnapshotMaxAge {
+		routes, err := rc.routes.ListRoutes(ctx, rc.clusterName)
+		if err != nil {
+			return nil, fmt.Errorf("error listing routes: %v", err)
+		}
+		rc.routesSnapshot, rc.routesListed = routes, time.Now()
+	}
+	return append([]*cloudprovider.Route(nil), rc.routesSnapshot...), nil
+}
+
+// updateRoutes applies a route created or deleted by a worker to the snapshot of the routes
+func (rc *Controller) updateRoutes(update func([]*cloudprovider.Route) []*cloudprovider.Route) {
+	rc.routesMu.Lock()
+	defer rc.routesMu.Unlock()
+	if !rc.routesListed.IsZero() {
+		rc.routesSnapshot = update(rc.routesSnapshot)
+	}
+}
+
+// invalidateRoutes makes the next worker list the routes again
+func (rc *Controller) invalidateRoutes() {
+	rc.routesMu.Lock()
+	defer rc.routesMu.Unlock()
+	rc.routesSnapshot, rc.routesListed = nil, time.Time{}
+}
+
+// updateNetworkingCondition sets the NodeNetworkUnavailable condition of a node from whether its routes were created
+func (rc *Controller) updateNetworkingCondition(node *v1.Node, routesCreated bool) error {
+	_, condition := nodeutil.GetNodeCondition(&node.Status, v1.NodeNetworkUnavailable)
+	if condition != nil && (condition.Status == v1.ConditionFalse) == routesCreated {
+		return nil
+	}
+
+	klog.Infof("Patching node status %v with %v previous condition was:%+v", node.Name, routesCreated, condition)
+	newCondition := v1.NodeCondition{
+		Type:    v1.NodeNetworkUnavailable,
+		Status:  v1.ConditionTrue,
+		Reason:  "NoRouteCreated",
+		Message: "RouteController failed to create a route",
+	}
+	if routesCreated {
+		newCondition.Status = v1.ConditionFalse
+		newCondition.Reason = "RouteCreated"
+		newCondition.Message = "RouteController created a route"
+	}
+	err := clientretry.RetryOnConflict(updateNetworkConditionBackoff, func() error {
+		newCondition.LastTransitionTime = metav1.Now()
+		return nodeutil.SetNodeCondition(rc.kubeClient, types.NodeName(node.Name), newCondition)
+	})
+	if err != nil {
+		return fmt.Errorf("error updating networking condition of node %s: %v", node.Name, err)
+	}
+	return nil
+}
+
+// isResponsibleForRoute returns true if the destination of the route is within a cluster CIDR
+func (rc *Controller) isResponsibleForRoute(route *cloudprovider.Route) bool {
+	_, cidr, err := netutils.ParseCIDRSloppy(route.DestinationCIDR)
+	if err != nil {
+		klog.Errorf("Ignoring route %s, unparsable CIDR: %v", route.Name, err)
+		return false
+	}
+	lastIP := make([]byte, len(cidr.IP))
+	for i := range lastIP {
+		lastIP[i] = cidr.IP[i] | ^cidr.Mask[i]
+	}
+	for _, clusterCIDR := range rc.clusterCIDRs {
+		if clusterCIDR.Contains(cidr.IP) || clusterCIDR.Contains(lastIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueueNode adds a node to the workqueue, a node that is already queued is only queued once
+func (rc *Controller) enqueueNode(nodeName string) {
+	rc.workqueue.Add(nodeName)
+	klog.V(4).Infof("Added node %s to the workqueue", nodeName)
+}
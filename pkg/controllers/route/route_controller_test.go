@@ -0,0 +1,204 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	cloudprovider "k8s.io/cloud-provider"
+	nodeutil "k8s.io/component-helpers/node/util"
+	netutils "k8s.io/utils/net"
+)
+
+// fakeRoutes keeps the routes of a cluster in memory
+type fakeRoutes struct {
+	mu        sync.Mutex
+	routes    []*cloudprovider.Route
+	listCalls int
+}
+
+func (f *fakeRoutes) ListRoutes(ctx context.Context, clusterName string) ([]*cloudprovider.Route, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listCalls++
+	return append([]*cloudprovider.Route(nil), f.routes...), nil
+}
+
+func (f *fakeRoutes) CreateRoute(ctx context.Context, clusterName string, nameHint string, route *cloudprovider.Route) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	route.Name = clusterName + "-" + route.DestinationCIDR
+	f.routes = append(f.routes, route)
+	return nil
+}
+
+func (f *fakeRoutes) DeleteRoute(ctx context.Context, clusterName string, route *cloudprovider.Route) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, r := range f.routes {
+		if r.DestinationCIDR == route.DestinationCIDR {
+			f.routes = append(f.routes[:i], f.routes[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (f *fakeRoutes) destinations() map[types.NodeName][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	destinations := map[types.NodeName][]string{}
+	for _, r := range f.routes {
+		destinations[r.TargetNode] = append(destinations[r.TargetNode], r.DestinationCIDR)
+	}
+	return destinations
+}
+
+func newNode(name string, podCIDRs ...string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name + "-uid")},
+		Spec:       v1.NodeSpec{PodCIDRs: podCIDRs},
+	}
+}
+
+func newTestController(t *testing.T, routes *fakeRoutes, nodes ...*v1.Node) (*Controller, *fake.Clientset) {
+	client := fake.NewSimpleClientset()
+	for _, node := range nodes {
+		_, err := client.CoreV1().Nodes().Create(context.TODO(), node, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	clusterCIDRs, err := netutils.ParseCIDRs([]string{"10.244.0.0/16"})
+	require.NoError(t, err)
+	rc, err := NewRouteController(informerFactory.Core().V1().Nodes(), client, routes, "test-cluster", clusterCIDRs, time.Hour, 1)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	t.Cleanup(rc.workqueue.ShutDown)
+	informerFactory.Start(ctx.Done())
+	informerFactory.WaitForCacheSync(ctx.Done())
+	return rc, client
+}
+
+// waitForQueueLen waits until the informer events were delivered to the workqueue
+func waitForQueueLen(t *testing.T, rc *Controller, n int) {
+	err := wait.PollUntilContextTimeout(context.TODO(), 10*time.Millisecond, 5*time.Second, true, func(ctx context.Context) (bool, error) {
+		return rc.workqueue.Len() == n, nil
+	})
+	require.NoError(t, err, "workqueue has %d nodes, expected %d", rc.workqueue.Len(), n)
+}
+
+// drainQueue syncs the queued nodes and returns their names in queue order
+func drainQueue(t *testing.T, rc *Controller) []string {
+	var names []string
+	for rc.workqueue.Len() > 0 {
+		nodeName, _ := rc.workqueue.Get()
+		names = append(names, nodeName)
+		require.NoError(t, rc.syncNode(context.TODO(), nodeName))
+		rc.workqueue.Forget(nodeName)
+		rc.workqueue.Done(nodeName)
+	}
+	return names
+}
+
+func TestRouteControllerEnqueuesChangedNodesOnly(t *testing.T) {
+	routes := &fakeRoutes{}
+	rc, client := newTestController(t, routes,
+		newNode("node0", "10.244.0.0/24"),
+		newNode("node1", "10.244.1.0/24"),
+		newNode("node2"))
+
+	// Nodes without a pod CIDR have no routes and aren't queued
+	waitForQueueLen(t, rc, 2)
+	assert.ElementsMatch(t, []string{"node0", "node1"}, drainQueue(t, rc))
+	assert.Equal(t, map[types.NodeName][]string{"node0": {"10.244.0.0/24"}, "node1": {"10.244.1.0/24"}}, routes.destinations())
+
+	// A status update of a node doesn't change its routes
+	node0, err := client.CoreV1().Nodes().Get(context.TODO(), "node0", metav1.GetOptions{})
+	require.NoError(t, err)
+	node0.Status.Addresses = []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "192.168.0.10"}}
+	_, err = client.CoreV1().Nodes().UpdateStatus(context.TODO(), node0, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// Changing the pod CIDR of a node queues that node only, even when it changes several times
+	for _, podCIDR := range []string{"10.244.2.0/24", "10.244.3.0/24"} {
+		node1, err := client.CoreV1().Nodes().Get(context.TODO(), "node1", metav1.GetOptions{})
+		require.NoError(t, err)
+		node1.Spec.PodCIDRs = []string{podCIDR}
+		_, err = client.CoreV1().Nodes().Update(context.TODO(), node1, metav1.UpdateOptions{})
+		require.NoError(t, err)
+	}
+	err = wait.PollUntilContextTimeout(context.TODO(), 10*time.Millisecond, 5*time.Second, true, func(ctx context.Context) (bool, error) {
+		node1, err := rc.nodeLister.Get("node1")
+		return err == nil && node1.Spec.PodCIDRs[0] == "10.244.3.0/24", nil
+	})
+	require.NoError(t, err)
+	waitForQueueLen(t, rc, 1)
+	assert.Equal(t, []string{"node1"}, drainQueue(t, rc))
+	assert.Equal(t, map[types.NodeName][]string{"node0": {"10.244.0.0/24"}, "node1": {"10.244.3.0/24"}}, routes.destinations())
+
+	// Deleting a node queues it and removes its routes
+	require.NoError(t, client.CoreV1().Nodes().Delete(context.TODO(), "node0", metav1.DeleteOptions{}))
+	waitForQueueLen(t, rc, 1)
+	assert.Equal(t, []string{"node0"}, drainQueue(t, rc))
+	assert.Equal(t, map[types.NodeName][]string{"node1": {"10.244.3.0/24"}}, routes.destinations())
+}
+
+func TestRouteControllerResync(t *testing.T) {
+	routes := &fakeRoutes{routes: []*cloudprovider.Route{
+		{Name: "blackhole", DestinationCIDR: "10.244.9.0/24", Blackhole: true},
+		{Name: "deleted", TargetNode: "node-deleted", DestinationCIDR: "10.244.8.0/24"},
+		{Name: "unmanaged", TargetNode: "node-deleted", DestinationCIDR: "172.16.0.0/24"},
+	}}
+	rc, client := newTestController(t, routes, newNode("node0", "10.244.0.0/24"))
+	waitForQueueLen(t, rc, 1)
+	drainQueue(t, rc)
+
+	// The queued nodes are synced with the routes listed by the resync
+	routes.listCalls = 0
+	require.NoError(t, rc.resync(context.TODO()))
+	assert.ElementsMatch(t, []string{"node0", "node-deleted"}, drainQueue(t, rc))
+	assert.Equal(t, map[types.NodeName][]string{"node0": {"10.244.0.0/24"}, "node-deleted": {"172.16.0.0/24"}}, routes.destinations())
+	assert.Equal(t, 1, routes.listCalls)
+
+	// The routes created and deleted by the workers are kept in the shared routes
+	node1 := newNode("node1", "10.244.1.0/24")
+	_, err := client.CoreV1().Nodes().Create(context.TODO(), node1, metav1.CreateOptions{})
+	require.NoError(t, err)
+	waitForQueueLen(t, rc, 1)
+	assert.Equal(t, []string{"node1"}, drainQueue(t, rc))
+	rc.enqueueNode("node1")
+	assert.Equal(t, []string{"node1"}, drainQueue(t, rc))
+	assert.Equal(t, map[types.NodeName][]string{"node0": {"10.244.0.0/24"}, "node1": {"10.244.1.0/24"}, "node-deleted": {"172.16.0.0/24"}}, routes.destinations())
+	assert.Equal(t, 1, routes.listCalls)
+
+	node0, err := client.CoreV1().Nodes().Get(context.TODO(), "node0", metav1.GetOptions{})
+	require.NoError(t, err)
+	_, condition := nodeutil.GetNodeCondition(&node0.Status, v1.NodeNetworkUnavailable)
+	require.NotNil(t, condition)
+	assert.Equal(t, v1.ConditionFalse, condition.Status)
+	assert.Equal(t, "RouteCreated", condition.Reason)
+}
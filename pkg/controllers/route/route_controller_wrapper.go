@@ -0,0 +1,101 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package route
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/cloud-provider/app"
+	cloudcontrollerconfig "k8s.io/cloud-provider/app/config"
+	genericcontrollermanager "k8s.io/controller-manager/app"
+	"k8s.io/controller-manager/controller"
+	"k8s.io/klog/v2"
+	netutils "k8s.io/utils/net"
+
+	"k8s.io/cloud-provider-aws/pkg/controllers/options"
+)
+
+const (
+	// RouteControllerClientName is the name of the route controller
+	RouteControllerClientName = "coalescing-route-controller"
+
+	// RouteControllerKey is the key used to register this controller
+	RouteControllerKey = "coalescing-route"
+)
+
+// ControllerWrapper is the wrapper for the route controller
+type ControllerWrapper struct {
+	Options options.RouteControllerOptions
+}
+
+// StartRouteControllerWrapper is used to take cloud config as input and start the route controller
+func (rc *ControllerWrapper) StartRouteControllerWrapper(initContext app.ControllerInitContext, completedConfig *cloudcontrollerconfig.CompletedConfig, cloud cloudprovider.Interface) app.InitFunc {
+	return func(ctx context.Context, controllerContext genericcontrollermanager.ControllerContext) (controller.Interface, bool, error) {
+		return rc.startRouteController(ctx, initContext, completedConfig, cloud)
+	}
+}
+
+func (rc *ControllerWrapper) startRouteController(ctx context.Context, initContext app.ControllerInitContext, completedConfig *cloudcontrollerconfig.CompletedConfig, cloud cloudprovider.Interface) (controller.Interface, bool, error) {
+	err := rc.Options.Validate()
+	if err != nil {
+		klog.Fatalf("Route controller inputs are not properly set: %v", err)
+	}
+
+	if !completedConfig.ComponentConfig.KubeCloudShared.ConfigureCloudRoutes {
+		klog.Infof("Will not configure cloud provider routes, --configure-cloud-routes: %v", completedConfig.ComponentConfig.KubeCloudShared.ConfigureCloudRoutes)
+		return nil, false, nil
+	}
+
+	routes, ok := cloud.Routes()
+	if !ok {
+		klog.Warning("--configure-cloud-routes is set, but cloud provider does not support routes. Will not configure cloud provider routes.")
+		return nil, false, nil
+	}
+
+	clusterCIDRs, err := netutils.ParseCIDRs(strings.Split(strings.TrimSpace(completedConfig.ComponentConfig.KubeCloudShared.ClusterCIDR), ","))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(clusterCIDRs) > 1 {
+		dualStack, err := netutils.IsDualStackCIDRs(clusterCIDRs)
+		if err != nil {
+			return nil, false, err
+		}
+		if !dualStack || len(clusterCIDRs) > 2 {
+			return nil, false, fmt.Errorf("cluster CIDRs %v must be a single CIDR or one CIDR of each IP family", clusterCIDRs)
+		}
+	}
+
+	// Start the Controller
+	routecontroller, err := NewRouteController(
+		completedConfig.SharedInformers.Core().V1().Nodes(),
+		completedConfig.ClientBuilder.ClientOrDie(initContext.ClientName),
+		routes,
+		completedConfig.ComponentConfig.KubeCloudShared.ClusterName,
+		clusterCIDRs,
+		rc.Options.ResyncPeriod,
+		rc.Options.WorkerCount)
+
+	if err != nil {
+		klog.Warningf("failed to start route controller: %s", err)
+		return nil, false, nil
+	}
+
+	go routecontroller.Run(ctx)
+
+	return nil, true, nil
+}
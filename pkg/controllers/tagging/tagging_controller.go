@@ -48,12 +48,15 @@ func init() {
 type taggingControllerNode struct {
 	providerID string
 	name       string
+	instanceID string
 }
 
-// workItem contains the node name, provider id and an action for that node.
+// workItem contains the node name, provider id, the instance ID the cloud maps the node to and an action for that
+// node. The instance ID is resolved when the node is queued, as the node may be deleted by the time it is processed.
 type workItem struct {
 	name       string
 	providerID string
+	instanceID string
 	action     string
 }
 
@@ -235,29 +238,33 @@ func (tc *Controller) process(ctx context.Context) bool {
 			return nil
 		}
 
-		instanceID, err := awsv1.KubernetesInstanceID(workItem.providerID).MapToAWSInstanceID()
-		if err != nil {
-			err = fmt.Errorf("error in getting instanceID for node %s, error: %v", workItem.name, err)
+		instanceID := awsv1.InstanceID(workItem.instanceID)
+		if instanceID == "" {
+			err := fmt.Errorf("error in getting instanceID for node %s, provider ID %q", workItem.name, workItem.providerID)
 			utilruntime.HandleError(err)
 			return nil
 		}
-		klog.Infof("Instance ID of work item %s is %s", workItem, instanceID)
+		logger := klog.FromContext(ctx).WithValues("node", workItem.name, "instanceID", instanceID)
+		ctx := klog.NewContext(ctx, logger)
+		logger.Info("Found instance ID of work item", "action", workItem.action)
 
 		if variant.IsVariantNode(string(instanceID)) {
-			klog.Infof("Skip processing the node %s since it is a %s node",
-				instanceID, variant.NodeType(string(instanceID)))
+			logger.Info("Skip processing the node since it is a variant node", "nodeType", variant.NodeType(string(instanceID)))
 			tc.workqueue.Forget(obj)
 			return nil
 		}
+		var err error
 		if workItem.action == addTag {
 			err = tc.tagNodesResources(ctx, &taggingControllerNode{
 				name:       workItem.name,
 				providerID: workItem.providerID,
+				instanceID: workItem.instanceID,
 			})
 		} else {
 			err = tc.untagNodeResources(ctx, &taggingControllerNode{
 				name:       workItem.name,
 				providerID: workItem.providerID,
+				instanceID: workItem.instanceID,
 			})
 		}
 		if err != nil {
@@ -270,10 +277,10 @@ func (tc *Controller) process(ctx context.Context) bool {
 				return fmt.Errorf("error processing work item '%v': %s, requeuing count %d", workItem, err.Error(), numRetries)
 			}
 
-			klog.Errorf("error processing work item %s: %s, requeuing count exceeded", workItem, err.Error())
+			logger.Error(err, "Error processing work item, requeuing count exceeded")
 			recordWorkItemErrorMetrics(errorsAfterRetriesExhaustedWorkItemErrorMetric, string(instanceID))
 		} else {
-			klog.Infof("Finished processing %s", workItem)
+			logger.Info("Finished processing work item")
 		}
 
 		tc.workqueue.Forget(obj)
@@ -315,12 +322,15 @@ func (tc *Controller) tagNodesResources(ctx context.Context, node *taggingContro
 // tagEc2Instances applies the provided tags to each EC2 instance in
 // the cluster.
 func (tc *Controller) tagEc2Instance(ctx context.Context, node *v1.Node) error {
+	logger := klog.FromContext(ctx)
 	if !tc.isTaggingRequired(node) {
-		klog.Infof("Skip tagging node %s since it was already tagged earlier.", node.GetName())
+		logger.Info("Skip tagging node since it was already tagged earlier")
 		return nil
 	}
-	var err error
-	instanceID, _ := awsv1.KubernetesInstanceID(node.Spec.ProviderID).MapToAWSInstanceID()
+	instanceID, err := tc.cloud.NodeInstanceID(node)
+	if err != nil {
+		return err
+	}
 	if tc.batchingEnabled {
 		err = tc.cloud.TagResourceBatch(ctx, string(instanceID), tc.tags)
 	} else {
@@ -337,21 +347,21 @@ func (tc *Controller) tagEc2Instance(ctx context.Context, node *v1.Node) error {
 			}
 			// 2. The event in our workQueue is stale, and the instance no longer exists.
 			//    Tagging will never succeed, and the event should not be re-queued.
-			klog.Infof("Skip tagging since EC2 instance %s for node %s does not exist", instanceID, node.GetName())
+			logger.Info("Skip tagging since EC2 instance for node does not exist")
 			return nil
 		}
-		klog.Errorf("Error in tagging EC2 instance %s for node %s, error: %v", instanceID, node.GetName(), err)
+		logger.Error(err, "Error in tagging EC2 instance for node")
 		return err
 	}
 
 	labels := map[string]string{taggingControllerLabelKey: tc.getChecksumOfTags()}
-	klog.Infof("Successfully tagged %s with %v. Labeling the nodes with tagging controller labels now.", instanceID, tc.tags)
+	logger.Info("Successfully tagged EC2 instance, labeling the node with tagging controller labels now", "tags", tc.tags)
 	if !nodehelpers.AddOrUpdateLabelsOnNode(tc.kubeClient, labels, node) {
-		klog.Errorf("Couldn't apply labels %s to node %s.", labels, node.GetName())
+		logger.Error(nil, "Couldn't apply labels to node", "labels", labels)
 		return fmt.Errorf("couldn't apply labels %s to node %s", labels, node.GetName())
 	}
 
-	klog.Infof("Successfully labeled node %s with %v.", node.GetName(), labels)
+	logger.Info("Successfully labeled node", "labels", labels)
 
 	if tc.isInitialTag(node) {
 		initialNodeTaggingDelay.Observe(time.Since(node.CreationTimestamp.Time).Seconds())
@@ -379,21 +389,19 @@ func (tc *Controller) untagNodeResources(ctx context.Context, node *taggingContr
 // untagEc2Instances deletes the provided tags to each EC2 instances in
 // the cluster.
 func (tc *Controller) untagEc2Instance(ctx context.Context, node *taggingControllerNode) error {
-	instanceID, _ := awsv1.KubernetesInstanceID(node.providerID).MapToAWSInstanceID()
-
 	var err error
 	if tc.batchingEnabled {
-		err = tc.cloud.UntagResourceBatch(context.TODO(), string(instanceID), tc.tags)
+		err = tc.cloud.UntagResourceBatch(context.TODO(), node.instanceID, tc.tags)
 	} else {
-		err = tc.cloud.UntagResource(ctx, string(instanceID), tc.tags)
+		err = tc.cloud.UntagResource(ctx, node.instanceID, tc.tags)
 	}
 
 	if err != nil {
-		klog.Errorf("Error in untagging EC2 instance %s for node %s, error: %v", instanceID, node.name, err)
+		klog.FromContext(ctx).Error(err, "Error in untagging EC2 instance for node")
 		return err
 	}
 
-	klog.Infof("Successfully untagged %s with %v", instanceID, tc.tags)
+	klog.FromContext(ctx).Info("Successfully untagged EC2 instance", "tags", tc.tags)
 
 	return nil
 }
@@ -408,6 +416,9 @@ func (tc *Controller) enqueueNode(node *v1.Node, action string) {
 		providerID: node.Spec.ProviderID,
 		action:     action,
 	}
+	if instanceID, err := tc.cloud.NodeInstanceID(node); err == nil {
+		item.instanceID = string(instanceID)
+	}
 
 	if tc.rateLimitEnabled {
 		tc.workqueue.AddRateLimited(item)
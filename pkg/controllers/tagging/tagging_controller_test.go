@@ -29,13 +29,11 @@ import (
 	coreinformers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/util/workqueue"
-	awsv1 "k8s.io/cloud-provider-aws/pkg/providers/v1"
+	"k8s.io/cloud-provider-aws/pkg/internal/awstesting"
 	"k8s.io/cloud-provider-aws/pkg/providers/v1/config"
 	"k8s.io/klog/v2"
 )
 
-const TestClusterID = "clusterid.test"
-
 func Test_NodesJoiningAndLeaving(t *testing.T) {
 	klog.InitFlags(nil)
 	flag.CommandLine.Parse([]string{"--logtostderr=false"})
@@ -72,7 +70,7 @@ func Test_NodesJoiningAndLeaving(t *testing.T) {
 				},
 			},
 			toBeTagged:       true,
-			expectedMessages: []string{"Successfully tagged i-0001", "to the workqueue (without any rate-limit)"},
+			expectedMessages: []string{`"Successfully tagged EC2 instance, labeling the node with tagging controller labels now" node="node0" instanceID="i-0001"`, "to the workqueue (without any rate-limit)"},
 		},
 		{
 			name: "node0 joins the cluster (rate-limited).",
@@ -86,7 +84,7 @@ func Test_NodesJoiningAndLeaving(t *testing.T) {
 				},
 			},
 			toBeTagged:       true,
-			expectedMessages: []string{"Successfully tagged i-0001", "to the workqueue (rate-limited)"},
+			expectedMessages: []string{`"Successfully tagged EC2 instance, labeling the node with tagging controller labels now" node="node0" instanceID="i-0001"`, "to the workqueue (rate-limited)"},
 			rateLimited:      true,
 		},
 		{
@@ -104,7 +102,7 @@ func Test_NodesJoiningAndLeaving(t *testing.T) {
 				},
 			},
 			toBeTagged:       true,
-			expectedMessages: []string{"Successfully tagged i-0001"},
+			expectedMessages: []string{`"Successfully tagged EC2 instance, labeling the node with tagging controller labels now" node="node0" instanceID="i-0001"`},
 		},
 		{
 			name: "node0 joins the cluster but isn't tagged because it was already tagged earlier.",
@@ -121,7 +119,7 @@ func Test_NodesJoiningAndLeaving(t *testing.T) {
 				},
 			},
 			toBeTagged:       true,
-			expectedMessages: []string{"Skip tagging node node0 since it was already tagged earlier."},
+			expectedMessages: []string{`"Skip tagging node since it was already tagged earlier" node="node0" instanceID="i-0001"`},
 		},
 		{
 			name: "fargate node joins the cluster.",
@@ -135,7 +133,7 @@ func Test_NodesJoiningAndLeaving(t *testing.T) {
 				},
 			},
 			toBeTagged:       true,
-			expectedMessages: []string{"Skip processing the node fargate-ip-10-0-55-27.us-west-2.compute.internal since it is a fargate node"},
+			expectedMessages: []string{`"Skip processing the node since it is a variant node" node="fargatenode0" instanceID="fargate-ip-10-0-55-27.us-west-2.compute.internal" nodeType="fargate"`},
 		},
 		{
 			name: "node0 leaves the cluster, failed to untag.",
@@ -149,7 +147,7 @@ func Test_NodesJoiningAndLeaving(t *testing.T) {
 				},
 			},
 			toBeTagged:       false,
-			expectedMessages: []string{"Error in untagging EC2 instance i-error for node node0"},
+			expectedMessages: []string{`"Error in untagging EC2 instance for node" err="Unable to remove tag" node="node0" instanceID="i-error"`},
 		},
 		{
 			name: "node0 leaves the cluster.",
@@ -163,7 +161,7 @@ func Test_NodesJoiningAndLeaving(t *testing.T) {
 				},
 			},
 			toBeTagged:       false,
-			expectedMessages: []string{"Successfully untagged i-0001"},
+			expectedMessages: []string{`"Successfully untagged EC2 instance" node="node0" instanceID="i-0001"`},
 		},
 		{
 			name: "node0 is recently created and the instance is not found the first 3 CreateTags attempts",
@@ -177,7 +175,7 @@ func Test_NodesJoiningAndLeaving(t *testing.T) {
 				},
 			},
 			toBeTagged:       true,
-			expectedMessages: []string{"Successfully tagged i-not-found-count-3-0001", "node is within eventual consistency grace period"},
+			expectedMessages: []string{`"Successfully tagged EC2 instance, labeling the node with tagging controller labels now" node="node0" instanceID="i-not-found-count-3-0001"`, "node is within eventual consistency grace period"},
 		},
 		{
 			name: "node0 is not recently created and the instance is not found",
@@ -191,12 +189,11 @@ func Test_NodesJoiningAndLeaving(t *testing.T) {
 				},
 			},
 			toBeTagged:       true,
-			expectedMessages: []string{"Skip tagging since EC2 instance i-not-found for node node0 does not exist"},
+			expectedMessages: []string{`"Skip tagging since EC2 instance for node does not exist" node="node0" instanceID="i-not-found"`},
 		},
 	}
 
-	awsServices := awsv1.NewFakeAWSServices(TestClusterID)
-	fakeAws, _ := awsv1.NewAWSCloud(config.CloudConfig{}, awsServices)
+	fakeAws, _ := awstesting.NewCloud(t)
 	batching := [2]bool{true, false}
 	for _, testcase := range testcases {
 		var logBuf bytes.Buffer
@@ -275,8 +272,7 @@ func Test_NodesJoiningAndLeaving(t *testing.T) {
 }
 
 func TestMultipleEnqueues(t *testing.T) {
-	awsServices := awsv1.NewFakeAWSServices(TestClusterID)
-	fakeAws, _ := awsv1.NewAWSCloud(config.CloudConfig{}, awsServices)
+	fakeAws, _ := awstesting.NewCloud(t)
 
 	testNode := &v1.Node{
 		ObjectMeta: metav1.ObjectMeta{
@@ -339,6 +335,36 @@ func TestMultipleEnqueues(t *testing.T) {
 	}
 }
 
+func TestEnqueueNodeInstanceIDLabel(t *testing.T) {
+	cfg := config.CloudConfig{}
+	cfg.Global.InstanceIDLabel = "example.com/instance-id"
+	fakeAws, _ := awstesting.NewCloud(t, awstesting.WithConfig(cfg))
+
+	hybridNode := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "hybrid-node-1",
+			Labels: map[string]string{"example.com/instance-id": "i-0123456789abcdef0"},
+		},
+		Spec: v1.NodeSpec{
+			ProviderID: "hybrid://datacenter-1/hybrid-node-1",
+		},
+	}
+	clientset := fake.NewSimpleClientset(hybridNode)
+	informer := informers.NewSharedInformerFactory(clientset, time.Second)
+	tc, err := NewTaggingController(informer.Core().V1().Nodes(), clientset, fakeAws, time.Second, nil, []string{}, 0, 0, 10, false)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// The instance ID is mapped by the cloud's instance ID resolver, from the label instead of the provider ID
+	tc.enqueueNode(hybridNode, deleteTag)
+	item, _ := tc.workqueue.Get()
+	if instanceID := item.(workItem).instanceID; instanceID != "i-0123456789abcdef0" {
+		t.Errorf("invalid instance ID of work item, expected i-0123456789abcdef0, got %q", instanceID)
+	}
+	tc.workqueue.Done(item)
+}
+
 func syncNodeStore(nodeinformer coreinformers.NodeInformer, f *fake.Clientset) error {
 	nodes, err := f.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
 	if err != nil {
@@ -0,0 +1,102 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package awstesting builds AWS clouds wired to fake clients for tests.
+package awstesting
+
+import (
+	"testing"
+
+	awsv1 "k8s.io/cloud-provider-aws/pkg/providers/v1"
+	"k8s.io/cloud-provider-aws/pkg/providers/v1/config"
+)
+
+// DefaultClusterID is the cluster ID of the clouds built by NewCloud
+const DefaultClusterID = "clusterid.test"
+
+// Option overrides a default of NewCloud
+type Option func(*options)
+
+type options struct {
+	clusterID string
+	config    config.CloudConfig
+	services  []func(*awsv1.FakeAWSServices)
+}
+
+// WithClusterID sets the cluster ID the fake instances are tagged with, DefaultClusterID by default
+func WithClusterID(clusterID string) Option {
+	return func(o *options) {
+		o.clusterID = clusterID
+	}
+}
+
+// WithConfig sets the cloud config, the zero config by default
+func WithConfig(cfg config.CloudConfig) Option {
+	return func(o *options) {
+		o.config = cfg
+	}
+}
+
+// WithRegion sets the region of the fake metadata, us-west-2 by default
+func WithRegion(region string) Option {
+	return withServices(func(s *awsv1.FakeAWSServices) { s.WithRegion(region) })
+}
+
+// WithZone sets the availability zone of the instance running the cloud, us-west-2a by default
+func WithZone(zone string) Option {
+	return withServices(func(s *awsv1.FakeAWSServices) { s.WithAz(zone) })
+}
+
+// WithInstances adds n running instances, with IDs i-0 to i-(n-1), next to the instance running the cloud
+func WithInstances(n int) Option {
+	return withServices(func(s *awsv1.FakeAWSServices) { s.WithInstances(n) })
+}
+
+// WithELB replaces the fake ELB client, e.g. with a mock
+func WithELB(elb awsv1.ELB) Option {
+	return withServices(func(s *awsv1.FakeAWSServices) { s.WithELB(elb) })
+}
+
+// WithELBV2 replaces the fake ELBV2 client, e.g. with a mock
+func WithELBV2(elbv2 awsv1.ELBV2) Option {
+	return withServices(func(s *awsv1.FakeAWSServices) { s.WithELBV2(elbv2) })
+}
+
+func withServices(apply func(*awsv1.FakeAWSServices)) Option {
+	return func(o *options) {
+		o.services = append(o.services, apply)
+	}
+}
+
+// NewCloud returns a cloud wired to the fake EC2, ELB, ELBV2 and metadata clients of the returned fake services. The
+// test fails when the cloud can't be built.
+func NewCloud(t testing.TB, opts ...Option) (*awsv1.Cloud, *awsv1.FakeAWSServices) {
+	t.Helper()
+	o := &options{clusterID: DefaultClusterID}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	services := awsv1.NewFakeAWSServices(o.clusterID)
+	for _, apply := range o.services {
+		apply(services)
+	}
+	cloud, err := awsv1.NewAWSCloud(o.config, services)
+	if err != nil {
+		t.Fatalf("error building the AWS cloud: %v", err)
+	}
+	return cloud, services
+}
@@ -26,6 +26,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	stscredsv2 "github.com/aws/aws-sdk-go-v2/credentials/stscreds"
@@ -38,9 +39,12 @@ import (
 	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/elb"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/aws/smithy-go"
 	"gopkg.in/gcfg.v1"
@@ -48,18 +52,25 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
 	informercorev1 "k8s.io/client-go/informers/core/v1"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	v1core "k8s.io/client-go/kubernetes/typed/core/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	discoverylisters "k8s.io/client-go/listers/discovery/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 	cloudprovider "k8s.io/cloud-provider"
 	servicehelpers "k8s.io/cloud-provider/service/helpers"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/keymutex"
 	netutils "k8s.io/utils/net"
 
+	"k8s.io/cloud-provider-aws/pkg/providers/v1/batcher"
 	"k8s.io/cloud-provider-aws/pkg/providers/v1/config"
 	"k8s.io/cloud-provider-aws/pkg/providers/v1/iface"
 	"k8s.io/cloud-provider-aws/pkg/providers/v1/variant"
@@ -87,6 +98,11 @@ const ProviderName = "aws"
 // services. Used currently for ELBs only.
 const TagNameKubernetesService = "kubernetes.io/service-name"
 
+// TagNameKubernetesAdditionalTags is the tag name we use to record the space-separated keys of the
+// additional tags we added to an ELB, so that the tags removed from the annotation can be removed
+// without touching the tags added by others.
+const TagNameKubernetesAdditionalTags = "kubernetes.io/service-additional-tags"
+
 // TagNameSubnetInternalELB is the tag name used on a subnet to designate that
 // it should be used for internal ELBs
 const TagNameSubnetInternalELB = "kubernetes.io/role/internal-elb"
@@ -100,6 +116,11 @@ const TagNameSubnetPublicELB = "kubernetes.io/role/elb"
 // value is "nlb"
 const ServiceAnnotationLoadBalancerType = "service.beta.kubernetes.io/aws-load-balancer-type"
 
+// ServiceAnnotationLoadBalancerNLBTargetType is the annotation used on the service
+// to specify the targets of an NLB. The accepted values are "instance" (default),
+// to target the node ports of the instances, and "ip", to target the pod IPs directly.
+const ServiceAnnotationLoadBalancerNLBTargetType = "service.beta.kubernetes.io/aws-load-balancer-nlb-target-type"
+
 // ServiceAnnotationLoadBalancerInternal is the annotation used on the service
 // to indicate that we want an internal ELB.
 const ServiceAnnotationLoadBalancerInternal = "service.beta.kubernetes.io/aws-load-balancer-internal"
@@ -111,6 +132,12 @@ const ServiceAnnotationLoadBalancerInternal = "service.beta.kubernetes.io/aws-lo
 // certain backends.
 const ServiceAnnotationLoadBalancerProxyProtocol = "service.beta.kubernetes.io/aws-load-balancer-proxy-protocol"
 
+// ServiceAnnotationLoadBalancerXForwardedFor is the annotation used on the service to choose whether the classic ELB
+// passes the client IP to the backends in the X-Forwarded-For header. Only HTTP and HTTPS listeners add the header,
+// so "true" makes the listeners without a backend protocol HTTP, or HTTPS with a certificate, and "false" rejects
+// HTTP and HTTPS listeners.
+const ServiceAnnotationLoadBalancerXForwardedFor = "service.beta.kubernetes.io/aws-load-balancer-x-forwarded-for"
+
 // ServiceAnnotationLoadBalancerAccessLogEmitInterval is the annotation used to
 // specify access log emit interval.
 const ServiceAnnotationLoadBalancerAccessLogEmitInterval = "service.beta.kubernetes.io/aws-load-balancer-access-log-emit-interval"
@@ -135,6 +162,17 @@ const ServiceAnnotationLoadBalancerConnectionDrainingEnabled = "service.beta.kub
 // used on the service to specify a connection draining timeout.
 const ServiceAnnotationLoadBalancerConnectionDrainingTimeout = "service.beta.kubernetes.io/aws-load-balancer-connection-draining-timeout"
 
+// ServiceAnnotationLoadBalancerName is the annotation used on the service to
+// override the generated name of the load balancer. The name must be unique
+// within the region, up to 32 alphanumeric characters or hyphens long, and must
+// not begin or end with a hyphen.
+const ServiceAnnotationLoadBalancerName = "service.beta.kubernetes.io/aws-load-balancer-name"
+
+// ServiceAnnotationLoadBalancerTargetGroupAttributes is the annotation used on the
+// service to specify a comma-separated list of key-value pairs of target group
+// attributes for an NLB, e.g. "stickiness.enabled=true,stickiness.type=source_ip".
+const ServiceAnnotationLoadBalancerTargetGroupAttributes = "service.beta.kubernetes.io/aws-load-balancer-target-group-attributes"
+
 // ServiceAnnotationLoadBalancerConnectionIdleTimeout is the annotation used
 // on the service to specify the idle connection timeout.
 const ServiceAnnotationLoadBalancerConnectionIdleTimeout = "service.beta.kubernetes.io/aws-load-balancer-connection-idle-timeout"
@@ -143,6 +181,11 @@ const ServiceAnnotationLoadBalancerConnectionIdleTimeout = "service.beta.kuberne
 // used on the service to enable or disable cross-zone load balancing.
 const ServiceAnnotationLoadBalancerCrossZoneLoadBalancingEnabled = "service.beta.kubernetes.io/aws-load-balancer-cross-zone-load-balancing-enabled"
 
+// ServiceAnnotationLoadBalancerDeletionProtection is the annotation used on
+// the service to enable deletion protection of NLBs. A protected load balancer
+// is not deleted until the annotation is removed or set to false.
+const ServiceAnnotationLoadBalancerDeletionProtection = "service.beta.kubernetes.io/aws-load-balancer-deletion-protection"
+
 // ServiceAnnotationLoadBalancerExtraSecurityGroups is the annotation used
 // on the service to specify additional security groups to be added to ELB created
 const ServiceAnnotationLoadBalancerExtraSecurityGroups = "service.beta.kubernetes.io/aws-load-balancer-extra-security-groups"
@@ -156,8 +199,15 @@ const ServiceAnnotationLoadBalancerSecurityGroups = "service.beta.kubernetes.io/
 // service to request a secure listener. Value is a valid certificate ARN.
 // For more, see http://docs.aws.amazon.com/ElasticLoadBalancing/latest/DeveloperGuide/elb-listener-config.html
 // CertARN is an IAM or CM certificate ARN, e.g. arn:aws:acm:us-east-1:123456789012:certificate/12345678-1234-1234-1234-123456789012
+// The value "auto" uses the ACM certificate of the hostname of ServiceAnnotationLoadBalancerHostname instead.
 const ServiceAnnotationLoadBalancerCertificate = "service.beta.kubernetes.io/aws-load-balancer-ssl-cert"
 
+// ServiceAnnotationLoadBalancerHostname is the annotation used on the service
+// to specify the hostname clients connect to the load balancer with. The
+// certificate of the hostname is discovered in ACM when the
+// "service.beta.kubernetes.io/aws-load-balancer-ssl-cert" annotation is "auto".
+const ServiceAnnotationLoadBalancerHostname = "service.beta.kubernetes.io/aws-load-balancer-hostname"
+
 // ServiceAnnotationLoadBalancerSSLPorts is the annotation used on the service
 // to specify a comma-separated list of ports that will use SSL/HTTPS
 // listeners. Defaults to '*' (all).
@@ -225,6 +275,19 @@ const ServiceAnnotationLoadBalancerHCInterval = "service.beta.kubernetes.io/aws-
 // static IP addresses for the NLB. Only supported on elbv2 (NLB)
 const ServiceAnnotationLoadBalancerEIPAllocations = "service.beta.kubernetes.io/aws-load-balancer-eip-allocations"
 
+// ServiceAnnotationLoadBalancerStatusAddressType is the annotation used on the
+// service to specify whether the load balancer status reports the hostname
+// (default) or the static IP addresses of the NLB, for consumers that only use
+// IPs. "ip" requires elastic IPs. Only supported on elbv2 (NLB)
+const ServiceAnnotationLoadBalancerStatusAddressType = "service.beta.kubernetes.io/aws-load-balancer-status-address-type"
+
+const (
+	// statusAddressTypeHostname reports the DNS name of the load balancer
+	statusAddressTypeHostname = "hostname"
+	// statusAddressTypeIP reports only the static IPs of the load balancer
+	statusAddressTypeIP = "ip"
+)
+
 // ServiceAnnotationLoadBalancerTargetNodeLabels is the annotation used on the service
 // to specify a comma-separated list of key-value pairs which will be used to select
 // the target nodes for the load balancer
@@ -241,6 +304,11 @@ const ServiceAnnotationLoadBalancerTargetNodeLabels = "service.beta.kubernetes.i
 //  3. prefer the subnet that is first in lexicographic order
 const ServiceAnnotationLoadBalancerSubnets = "service.beta.kubernetes.io/aws-load-balancer-subnets"
 
+// ServiceAnnotationLoadBalancerWAFACLID is the annotation used on the service to associate a WAFv2 web ACL.
+// WAF web ACLs can only be associated with application load balancers, which this controller doesn't provision,
+// so the annotation is only recognized to warn that it is ignored.
+const ServiceAnnotationLoadBalancerWAFACLID = "service.beta.kubernetes.io/aws-load-balancer-waf-acl-id"
+
 const headerSourceArn = "x-amz-source-arn"
 const headerSourceAccount = "x-amz-source-account"
 
@@ -274,12 +342,28 @@ const (
 // awsTagNameMasterRoles is a set of well-known AWS tag names that indicate the instance is a master
 var awsTagNameMasterRoles = sets.NewString("kubernetes.io/role/master", "k8s.io/role/master")
 
-// Maps from backend protocol to ELB protocol
-var backendProtocolMapping = map[string]string{
-	"https": "https",
-	"http":  "https",
-	"ssl":   "ssl",
-	"tcp":   "ssl",
+// listenerProtocols are the protocols of a classic ELB listener, and of its instances
+type listenerProtocols struct {
+	protocol         string
+	instanceProtocol string
+}
+
+// sslListenerProtocols maps from backend protocol to the protocols of the listeners of SSL ports, which terminate
+// TLS with the certificate of the service
+var sslListenerProtocols = map[string]listenerProtocols{
+	"https": {"https", "https"},
+	"http":  {"https", "http"},
+	"ssl":   {"ssl", "ssl"},
+	"tcp":   {"ssl", "tcp"},
+}
+
+// plainListenerProtocols maps from backend protocol to the protocols of the listeners of the other ports, TLS
+// connections to HTTPS and SSL backends are passed through
+var plainListenerProtocols = map[string]listenerProtocols{
+	"https": {"tcp", "tcp"},
+	"http":  {"http", "http"},
+	"ssl":   {"tcp", "tcp"},
+	"tcp":   {"tcp", "tcp"},
 }
 
 // MaxReadThenCreateRetries sets the maximum number of attempts we will make when
@@ -295,6 +379,9 @@ type Services interface {
 	LoadBalancingV2(region string) (ELBV2, error)
 	Metadata() (config.EC2Metadata, error)
 	KeyManagement(region string) (KMS, error)
+	Autoscaling(region string) (ASG, error)
+	CertificateManager(region string) (ACM, error)
+	MessageQueue(region string) (SQS, error)
 }
 
 // ELB is a simple pass-through of AWS' ELB client interface, which allows for testing
@@ -303,18 +390,21 @@ type ELB interface {
 	DeleteLoadBalancer(*elb.DeleteLoadBalancerInput) (*elb.DeleteLoadBalancerOutput, error)
 	DescribeLoadBalancers(*elb.DescribeLoadBalancersInput) (*elb.DescribeLoadBalancersOutput, error)
 	AddTags(*elb.AddTagsInput) (*elb.AddTagsOutput, error)
+	RemoveTags(*elb.RemoveTagsInput) (*elb.RemoveTagsOutput, error)
 	RegisterInstancesWithLoadBalancer(*elb.RegisterInstancesWithLoadBalancerInput) (*elb.RegisterInstancesWithLoadBalancerOutput, error)
 	DeregisterInstancesFromLoadBalancer(*elb.DeregisterInstancesFromLoadBalancerInput) (*elb.DeregisterInstancesFromLoadBalancerOutput, error)
 	CreateLoadBalancerPolicy(*elb.CreateLoadBalancerPolicyInput) (*elb.CreateLoadBalancerPolicyOutput, error)
 	SetLoadBalancerPoliciesForBackendServer(*elb.SetLoadBalancerPoliciesForBackendServerInput) (*elb.SetLoadBalancerPoliciesForBackendServerOutput, error)
 	SetLoadBalancerPoliciesOfListener(input *elb.SetLoadBalancerPoliciesOfListenerInput) (*elb.SetLoadBalancerPoliciesOfListenerOutput, error)
 	DescribeLoadBalancerPolicies(input *elb.DescribeLoadBalancerPoliciesInput) (*elb.DescribeLoadBalancerPoliciesOutput, error)
+	DescribeTags(*elb.DescribeTagsInput) (*elb.DescribeTagsOutput, error)
 
 	DetachLoadBalancerFromSubnets(*elb.DetachLoadBalancerFromSubnetsInput) (*elb.DetachLoadBalancerFromSubnetsOutput, error)
 	AttachLoadBalancerToSubnets(*elb.AttachLoadBalancerToSubnetsInput) (*elb.AttachLoadBalancerToSubnetsOutput, error)
 
 	CreateLoadBalancerListeners(*elb.CreateLoadBalancerListenersInput) (*elb.CreateLoadBalancerListenersOutput, error)
 	DeleteLoadBalancerListeners(*elb.DeleteLoadBalancerListenersInput) (*elb.DeleteLoadBalancerListenersOutput, error)
+	SetLoadBalancerListenerSSLCertificate(*elb.SetLoadBalancerListenerSSLCertificateInput) (*elb.SetLoadBalancerListenerSSLCertificateOutput, error)
 
 	ApplySecurityGroupsToLoadBalancer(*elb.ApplySecurityGroupsToLoadBalancerInput) (*elb.ApplySecurityGroupsToLoadBalancerOutput, error)
 
@@ -327,6 +417,7 @@ type ELB interface {
 // ELBV2 is a simple pass-through of AWS' ELBV2 client interface, which allows for testing
 type ELBV2 interface {
 	AddTags(input *elbv2.AddTagsInput) (*elbv2.AddTagsOutput, error)
+	DescribeTags(input *elbv2.DescribeTagsInput) (*elbv2.DescribeTagsOutput, error)
 
 	CreateLoadBalancer(*elbv2.CreateLoadBalancerInput) (*elbv2.CreateLoadBalancerOutput, error)
 	DescribeLoadBalancers(*elbv2.DescribeLoadBalancersInput) (*elbv2.DescribeLoadBalancersOutput, error)
@@ -334,6 +425,7 @@ type ELBV2 interface {
 
 	ModifyLoadBalancerAttributes(*elbv2.ModifyLoadBalancerAttributesInput) (*elbv2.ModifyLoadBalancerAttributesOutput, error)
 	DescribeLoadBalancerAttributes(*elbv2.DescribeLoadBalancerAttributesInput) (*elbv2.DescribeLoadBalancerAttributesOutput, error)
+	SetSecurityGroups(*elbv2.SetSecurityGroupsInput) (*elbv2.SetSecurityGroupsOutput, error)
 
 	CreateTargetGroup(*elbv2.CreateTargetGroupInput) (*elbv2.CreateTargetGroupOutput, error)
 	DescribeTargetGroups(*elbv2.DescribeTargetGroupsInput) (*elbv2.DescribeTargetGroupsOutput, error)
@@ -356,12 +448,32 @@ type ELBV2 interface {
 	WaitUntilLoadBalancersDeleted(*elbv2.DescribeLoadBalancersInput) error
 }
 
+// ASG is a simple pass-through of the Autoscaling client interface, which
+// allows for testing.
+type ASG interface {
+	UpdateAutoScalingGroup(*autoscaling.UpdateAutoScalingGroupInput) (*autoscaling.UpdateAutoScalingGroupOutput, error)
+	DescribeAutoScalingGroups(*autoscaling.DescribeAutoScalingGroupsInput) (*autoscaling.DescribeAutoScalingGroupsOutput, error)
+}
+
 // KMS is a simple pass-through of the Key Management Service client interface,
 // which allows for testing.
 type KMS interface {
 	DescribeKey(*kms.DescribeKeyInput) (*kms.DescribeKeyOutput, error)
 }
 
+// ACM is a simple pass-through of the Certificate Manager client interface,
+// which allows for testing.
+type ACM interface {
+	ListCertificates(*acm.ListCertificatesInput) (*acm.ListCertificatesOutput, error)
+}
+
+// SQS is a simple pass-through of the Simple Queue Service client interface,
+// which allows for testing.
+type SQS interface {
+	ReceiveMessageWithContext(aws.Context, *sqs.ReceiveMessageInput, ...request.Option) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessageWithContext(aws.Context, *sqs.DeleteMessageInput, ...request.Option) (*sqs.DeleteMessageOutput, error)
+}
+
 var _ cloudprovider.Interface = (*Cloud)(nil)
 var _ cloudprovider.Instances = (*Cloud)(nil)
 var _ cloudprovider.LoadBalancer = (*Cloud)(nil)
@@ -373,7 +485,9 @@ type Cloud struct {
 	ec2      iface.EC2
 	elb      ELB
 	elbv2    ELBV2
+	asg      ASG
 	kms      KMS
+	acm      ACM
 	metadata config.EC2Metadata
 	cfg      *config.CloudConfig
 	region   string
@@ -393,16 +507,49 @@ type Cloud struct {
 	kubeClient    clientset.Interface
 
 	nodeInformer informercorev1.NodeInformer
+	// instanceIDResolver maps nodes to the IDs of their instances
+	instanceIDResolver InstanceIDResolver
 	// Extract the function out to make it easier to test
 	nodeInformerHasSynced cache.InformerSynced
 
+	// Services and their endpoint slices, used to register pod IPs with NLB target groups of target type "ip"
+	serviceLister             corelisters.ServiceLister
+	serviceListerSynced       cache.InformerSynced
+	endpointSliceLister       discoverylisters.EndpointSliceLister
+	endpointSliceListerSynced cache.InformerSynced
+	nlbIPTargetsQueue         workqueue.TypedRateLimitingInterface[string]
+	// Services of NLBs with instance targets to sync when the readiness of a node changes
+	nlbInstanceTargetsQueue workqueue.TypedRateLimitingInterface[string]
+	stopCh                  <-chan struct{}
+
 	eventBroadcaster record.EventBroadcaster
 	eventRecorder    record.EventRecorder
 
 	// Batching AWS api calls
-	createTagsBatcher       *createTagsBatcher
-	deleteTagsBatcher       *deleteTagsBatcher
-	describeInstanceBatcher *describeInstanceBatcher
+	createTagsBatcher            *createTagsBatcher
+	deleteTagsBatcher            *deleteTagsBatcher
+	describeInstanceBatcher      *describeInstanceBatcher
+	describeSecurityGroupBatcher *describeSecurityGroupBatcher
+	deregisterTargetsBatcher     *deregisterTargetsBatcher
+
+	describeAutoScalingGroupBatcher *describeAutoScalingGroupBatcher
+
+	// securityGroupFilterTags are tags that security groups must have to be discovered as cluster security groups
+	securityGroupFilterTags map[string]string
+
+	// nodeAddressCache caches node addresses looked up by node name or provider ID, it is nil when caching is disabled
+	nodeAddressCache *nodeAddressCache
+
+	// instanceStateEvents invalidates cached instances on their state change events, it is nil when not configured
+	instanceStateEvents *instanceStateEventConsumer
+
+	// loadBalancerLocks serializes the reconciles of the load balancer of a service, by service key
+	loadBalancerLocks keymutex.KeyMutex
+
+	// warnedAnnotations are the invalid annotation values last reported in warning events, by service, so that the
+	// events are only recorded again when the values change
+	warnedAnnotationsMu sync.Mutex
+	warnedAnnotations   map[types.NamespacedName]map[string]string
 }
 
 // Interface to make the CloudConfig immutable for awsSDKProvider
@@ -410,6 +557,8 @@ type awsCloudConfigProvider interface {
 	GetResolver() endpoints.ResolverFunc
 	GetEC2EndpointOpts(region string) []func(*ec2.Options) // for AWS SDK Go V2 EC2 Clients
 	GetCustomEC2Resolver() ec2.EndpointResolverV2          // for AWS SDK Go V2 EC2 Clients
+	GetIMDSv1FallbackEnabled() bool
+	GetAPICallMetricsEnabled() bool
 }
 
 // InstanceIDIndexFunc indexes based on a Node's instance ID found in its spec.providerID
@@ -431,6 +580,53 @@ func InstanceIDIndexFunc(obj interface{}) ([]string, error) {
 	return []string{string(instanceID)}, nil
 }
 
+// instanceIDIndexFunc indexes nodes by the instance ID the instance ID resolver maps them to
+func (c *Cloud) instanceIDIndexFunc(obj interface{}) ([]string, error) {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		return []string{""}, fmt.Errorf("%+v is not a Node", obj)
+	}
+	instanceID, err := c.instanceIDResolver.InstanceID(node)
+	if err != nil {
+		// the provider ID may not be populated yet, Informer.AddIndexers would panic if there is an error
+		klog.V(4).Infof("Not indexing node %q by instance ID: %v", node.Name, err)
+		return []string{""}, nil
+	}
+	return []string{string(instanceID)}, nil
+}
+
+// providerIDIndexFunc indexes nodes by their provider ID
+func providerIDIndexFunc(obj interface{}) ([]string, error) {
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		return []string{""}, fmt.Errorf("%+v is not a Node", obj)
+	}
+	return []string{node.Spec.ProviderID}, nil
+}
+
+// SetInstanceIDResolver replaces the default mapping of nodes to instance IDs, from their provider IDs, e.g. for
+// hybrid or Outposts nodes whose provider IDs don't hold the instance ID. It must be called before SetInformers.
+func (c *Cloud) SetInstanceIDResolver(resolver InstanceIDResolver) {
+	c.instanceIDResolver = resolver
+}
+
+// NodeInstanceID returns the ID of the instance backing the node, as mapped by the instance ID resolver
+func (c *Cloud) NodeInstanceID(node *v1.Node) (InstanceID, error) {
+	return c.instanceIDResolver.InstanceID(node)
+}
+
+// providerIDToInstanceID maps a provider ID to an instance ID with the instance ID resolver. The resolver is given
+// the node with the provider ID when the node informer has it, and a node with only the provider ID otherwise.
+func (c *Cloud) providerIDToInstanceID(providerID string) (InstanceID, error) {
+	node := &v1.Node{Spec: v1.NodeSpec{ProviderID: providerID}}
+	if c.nodeInformer != nil && providerID != "" {
+		if nodes, err := c.nodeInformer.Informer().GetIndexer().ByIndex("providerID", providerID); err == nil && len(nodes) == 1 {
+			node = nodes[0].(*v1.Node)
+		}
+	}
+	return c.instanceIDResolver.InstanceID(node)
+}
+
 // SetInformers implements InformerUser interface by setting up informer-fed caches for aws lib to
 // leverage Kubernetes API for caching
 func (c *Cloud) SetInformers(informerFactory informers.SharedInformerFactory) {
@@ -438,8 +634,70 @@ func (c *Cloud) SetInformers(informerFactory informers.SharedInformerFactory) {
 	c.nodeInformer = informerFactory.Core().V1().Nodes()
 	c.nodeInformerHasSynced = c.nodeInformer.Informer().HasSynced
 	c.nodeInformer.Informer().AddIndexers(cache.Indexers{
-		"instanceID": InstanceIDIndexFunc,
+		"instanceID": c.instanceIDIndexFunc,
+		"providerID": providerIDIndexFunc,
+	})
+	c.nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: c.invalidateChangedNode,
+		DeleteFunc: c.invalidateDeletedNode,
 	})
+	c.setNLBIPTargetsInformers(informerFactory)
+	c.setNLBInstanceTargetsInformers()
+}
+
+// invalidateDeletedNode removes the instance of a deleted node from the describe instance and node address caches
+func (c *Cloud) invalidateDeletedNode(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	node, ok := obj.(*v1.Node)
+	if !ok {
+		return
+	}
+	c.invalidateNodeInstance(node)
+}
+
+// invalidateChangedNode removes the instance of a node from the describe instance and node address caches when
+// the node becomes ready or not ready, which happens when its instance is stopped, started or replaced.
+func (c *Cloud) invalidateChangedNode(oldObj, newObj interface{}) {
+	oldNode, ok := oldObj.(*v1.Node)
+	if !ok {
+		return
+	}
+	newNode, ok := newObj.(*v1.Node)
+	if !ok {
+		return
+	}
+	if oldNode.Spec.ProviderID == newNode.Spec.ProviderID && nodeReadyStatus(oldNode) == nodeReadyStatus(newNode) {
+		return
+	}
+	c.invalidateNodeInstance(oldNode)
+	c.invalidateNodeInstance(newNode)
+}
+
+func (c *Cloud) invalidateNodeInstance(node *v1.Node) {
+	instanceID, err := c.instanceIDResolver.InstanceID(node)
+	if err != nil {
+		return
+	}
+	c.invalidateInstance(string(instanceID))
+}
+
+// invalidateInstance removes an instance from the caches of instance lookups
+func (c *Cloud) invalidateInstance(instanceID string) {
+	if c.describeInstanceBatcher != nil {
+		c.describeInstanceBatcher.invalidate(instanceID)
+	}
+	c.nodeAddressCache.invalidate(instanceID)
+}
+
+func nodeReadyStatus(node *v1.Node) v1.ConditionStatus {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeReady {
+			return condition.Status
+		}
+	}
+	return v1.ConditionUnknown
 }
 
 func newEc2Filter(name string, values ...string) ec2types.Filter {
@@ -500,20 +758,11 @@ func init() {
 			if err != nil {
 				return nil, fmt.Errorf("unable to create sts client, %v", err)
 			}
-			creds = credentials.NewChainCredentials(
-				[]credentials.Provider{
-					&credentials.EnvProvider{},
-					assumeRoleProvider(&stscreds.AssumeRoleProvider{
-						Client:  stsClient,
-						RoleARN: cfg.Global.RoleARN,
-					}),
-				})
-
 			stsClientv2, err := services.NewStsV2Client(ctx, regionName, cfg.Global.RoleARN, cfg.Global.SourceARN)
 			if err != nil {
 				return nil, fmt.Errorf("unable to create sts v2 client: %v", err)
 			}
-			credsV2 = stscredsv2.NewAssumeRoleProvider(stsClientv2, cfg.Global.RoleARN)
+			creds, credsV2 = newAssumeRoleCredentials(cfg, stsClient, stsClientv2)
 		}
 
 		aws := newAWSSDKProvider(creds, credsV2, cfg)
@@ -521,6 +770,36 @@ func init() {
 	})
 }
 
+// newAssumeRoleCredentials returns the credentials of the role specified by cfg.Global.RoleARN, for the AWS SDK v1
+// and v2 clients. The STS clients assume the role with the credentials of the default credential chain, so the
+// cloud provider can run in one account and manage resources in another. Credentials from the environment take
+// precedence over the assumed role for the AWS SDK v1 clients.
+func newAssumeRoleCredentials(cfg *config.CloudConfig, stsClient stscreds.AssumeRoler, stsClientv2 stscredsv2.AssumeRoleAPIClient) (*credentials.Credentials, *stscredsv2.AssumeRoleProvider) {
+	provider := &stscreds.AssumeRoleProvider{
+		Client:          stsClient,
+		RoleARN:         cfg.Global.RoleARN,
+		RoleSessionName: cfg.Global.RoleSessionName,
+	}
+	if cfg.Global.RoleExternalID != "" {
+		provider.ExternalID = aws.String(cfg.Global.RoleExternalID)
+	}
+	creds := credentials.NewChainCredentials(
+		[]credentials.Provider{
+			&credentials.EnvProvider{},
+			assumeRoleProvider(provider),
+		})
+
+	credsV2 := stscredsv2.NewAssumeRoleProvider(stsClientv2, cfg.Global.RoleARN, func(o *stscredsv2.AssumeRoleOptions) {
+		if cfg.Global.RoleSessionName != "" {
+			o.RoleSessionName = cfg.Global.RoleSessionName
+		}
+		if cfg.Global.RoleExternalID != "" {
+			o.ExternalID = aws.String(cfg.Global.RoleExternalID)
+		}
+	})
+	return creds, credsV2
+}
+
 func getSTSClient(sess *session.Session, roleARN, sourceARN string) (*sts.STS, error) {
 	klog.Infof("Using AWS assumed role %v", roleARN)
 	stsClient := sts.New(sess)
@@ -598,6 +877,9 @@ func newAWSCloud2(cfg config.CloudConfig, awsServices Services, provider config.
 	if err != nil {
 		return nil, fmt.Errorf("error creating AWS EC2 client: %v", err)
 	}
+	if cfg.GetAPICallMetricsEnabled() {
+		ec2 = &metricsEC2{ec2: ec2}
+	}
 
 	ec2v2, err := services.NewEc2SdkV2(ctx, regionName, credentialsV2)
 	if err != nil {
@@ -614,25 +896,94 @@ func newAWSCloud2(cfg config.CloudConfig, awsServices Services, provider config.
 		return nil, fmt.Errorf("error creating AWS ELBV2 client: %v", err)
 	}
 
+	// In a dry run the load balancer changes are logged rather than made, the clients are wrapped before the batchers
+	// are built so batched calls are covered too
+	var dryRunELBV2Client *dryRunELBV2
+	if cfg.GetLoadBalancerDryRunEnabled() {
+		klog.Warningf("Load balancer dry run is enabled, changes to load balancers and their security groups will only be logged")
+		ec2 = newDryRunEC2(ec2)
+		elb = newDryRunELB(elb)
+		dryRunELBV2Client = newDryRunELBV2(elbv2)
+		elbv2 = dryRunELBV2Client
+	}
+
 	kms, err := awsServices.KeyManagement(regionName)
 	if err != nil {
 		return nil, fmt.Errorf("error creating AWS key management client: %v", err)
 	}
 
+	asg, err := awsServices.Autoscaling(regionName)
+	if err != nil {
+		return nil, fmt.Errorf("error creating AWS autoscaling client: %v", err)
+	}
+
+	acm, err := awsServices.CertificateManager(regionName)
+	if err != nil {
+		return nil, fmt.Errorf("error creating AWS certificate manager client: %v", err)
+	}
+
+	instanceCacheTTL, err := cfg.GetInstanceCacheTTL()
+	if err != nil {
+		return nil, err
+	}
+	nodeAddressCacheTTL, err := cfg.GetNodeAddressCacheTTL()
+	if err != nil {
+		return nil, err
+	}
+	deregisterTargetsBatchIdleTimeout, err := cfg.GetDeregisterTargetsBatchIdleTimeout()
+	if err != nil {
+		return nil, err
+	}
+	securityGroupFilterTags, err := cfg.GetSecurityGroupFilterTags()
+	if err != nil {
+		return nil, err
+	}
+	resourceTags, err := cfg.GetResourceTags()
+	if err != nil {
+		return nil, err
+	}
+	// The batchers hold back their batches while the API rate limit shared by the clients is exhausted
+	var rateLimiter batcher.RateLimiter
+	if p, ok := provider.(*awsSDKProvider); ok && p.rateLimiter != nil {
+		rateLimiter = p.rateLimiter
+	}
+
 	awsCloud := &Cloud{
 		ec2:                     ec2,
 		elb:                     elb,
 		elbv2:                   elbv2,
+		asg:                     asg,
 		metadata:                metadata,
 		kms:                     kms,
+		acm:                     acm,
 		cfg:                     &cfg,
 		region:                  regionName,
-		createTagsBatcher:       newCreateTagsBatcher(ctx, ec2),
-		deleteTagsBatcher:       newDeleteTagsBatcher(ctx, ec2),
-		describeInstanceBatcher: newdescribeInstanceBatcher(ctx, ec2),
+		createTagsBatcher:       newCreateTagsBatcher(ctx, ec2, rateLimiter),
+		deleteTagsBatcher:       newDeleteTagsBatcher(ctx, ec2, rateLimiter),
+		describeInstanceBatcher: newdescribeInstanceBatcher(ctx, ec2, rateLimiter).withCache(instanceCacheTTL),
+
+		describeSecurityGroupBatcher: newDescribeSecurityGroupBatcher(ctx, ec2, rateLimiter),
+		deregisterTargetsBatcher:     newDeregisterTargetsBatcher(ctx, elbv2, deregisterTargetsBatchIdleTimeout, rateLimiter),
+		nodeAddressCache:             newNodeAddressCache(nodeAddressCacheTTL, clock.RealClock{}),
+		securityGroupFilterTags:      securityGroupFilterTags,
+		tagging:                      awsTagging{resourceTags: resourceTags},
+		instanceIDResolver:           providerIDInstanceIDResolver{},
+		loadBalancerLocks:            keymutex.NewHashed(0),
+
+		describeAutoScalingGroupBatcher: newDescribeAutoScalingGroupBatcher(ctx, asg),
 	}
 	awsCloud.instanceCache.cloud = awsCloud
 	awsCloud.zoneCache.cloud = awsCloud
+	if cfg.Global.InstanceIDLabel != "" {
+		awsCloud.instanceIDResolver = labelInstanceIDResolver{label: cfg.Global.InstanceIDLabel}
+	}
+	if cfg.Global.InstanceStateChangeQueueURL != "" {
+		queue, err := awsServices.MessageQueue(regionName)
+		if err != nil {
+			return nil, fmt.Errorf("error creating AWS SQS client: %v", err)
+		}
+		awsCloud.instanceStateEvents = newInstanceStateEventConsumer(queue, cfg.Global.InstanceStateChangeQueueURL, awsCloud.invalidateInstance)
+	}
 	awsCloud.instanceTopologyManager = resourcemanagers.NewInstanceTopologyManager(ec2v2, &cfg)
 
 	tagged := cfg.Global.KubernetesClusterTag != "" || cfg.Global.KubernetesClusterID != ""
@@ -641,9 +992,10 @@ func newAWSCloud2(cfg config.CloudConfig, awsServices Services, provider config.
 		// build up a dummy instance and use the VPC from the nodes account
 		klog.Info("Master is configured to run on a different AWS account, different cloud provider or on-premises")
 		awsCloud.selfAWSInstance = &awsInstance{
-			nodeName: "master-dummy",
-			vpcID:    cfg.Global.VPC,
-			subnetID: cfg.Global.SubnetID,
+			nodeName:         "master-dummy",
+			vpcID:            cfg.Global.VPC,
+			subnetID:         cfg.Global.SubnetID,
+			availabilityZone: cfg.Global.Zone,
 		}
 		awsCloud.vpcID = cfg.Global.VPC
 	} else {
@@ -654,6 +1006,9 @@ func newAWSCloud2(cfg config.CloudConfig, awsServices Services, provider config.
 		awsCloud.selfAWSInstance = selfAWSInstance
 		awsCloud.vpcID = selfAWSInstance.vpcID
 	}
+	if dryRunELBV2Client != nil {
+		dryRunELBV2Client.vpcID = awsCloud.vpcID
+	}
 
 	if cfg.Global.KubernetesClusterTag != "" || cfg.Global.KubernetesClusterID != "" {
 		if err := awsCloud.tagging.init(cfg.Global.KubernetesClusterTag, cfg.Global.KubernetesClusterID); err != nil {
@@ -692,11 +1047,21 @@ func NewAWSCloud(cfg config.CloudConfig, awsServices Services) (*Cloud, error) {
 // Initialize passes a Kubernetes clientBuilder interface to the cloud provider
 func (c *Cloud) Initialize(clientBuilder cloudprovider.ControllerClientBuilder, stop <-chan struct{}) {
 	c.clientBuilder = clientBuilder
+	c.stopCh = stop
 	c.kubeClient = clientBuilder.ClientOrDie("aws-cloud-provider")
 	c.eventBroadcaster = record.NewBroadcaster()
 	c.eventBroadcaster.StartStructuredLogging(0)
 	c.eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: c.kubeClient.CoreV1().Events("")})
 	c.eventRecorder = c.eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: "aws-cloud-provider"})
+	if stop != nil {
+		go func() {
+			<-stop
+			c.closeBatchers()
+		}()
+		if c.instanceStateEvents != nil {
+			go c.instanceStateEvents.run(wait.ContextForChannel(stop))
+		}
+	}
 
 	v, err := c.kubeClient.Discovery().ServerVersion()
 	if err != nil {
@@ -707,6 +1072,28 @@ func (c *Cloud) Initialize(clientBuilder cloudprovider.ControllerClientBuilder,
 	}
 }
 
+// closeBatchers closes the batchers of AWS API calls on shutdown, so that callers waiting for buffered calls get
+// their results instead of being left hanging
+func (c *Cloud) closeBatchers() {
+	var wg sync.WaitGroup
+	for _, closeBatcher := range []func(){
+		c.createTagsBatcher.batcher.Close,
+		c.deleteTagsBatcher.batcher.Close,
+		c.describeInstanceBatcher.batcher.Close,
+		c.describeSecurityGroupBatcher.batcher.Close,
+		c.deregisterTargetsBatcher.batcher.Close,
+		c.describeAutoScalingGroupBatcher.batcher.Close,
+	} {
+		wg.Add(1)
+		go func(closeBatcher func()) {
+			defer wg.Done()
+			closeBatcher()
+		}(closeBatcher)
+	}
+	wg.Wait()
+	klog.Info("Closed AWS API batchers")
+}
+
 // Clusters returns the list of clusters.
 func (c *Cloud) Clusters() (cloudprovider.Clusters, bool) {
 	return nil, false
@@ -753,12 +1140,14 @@ func (c *Cloud) NodeAddresses(ctx context.Context, name types.NodeName) ([]v1.No
 	if err != nil {
 		return nil, fmt.Errorf("could not look up instance ID for node %q: %v", name, err)
 	}
-	return c.NodeAddressesByProviderID(ctx, string(instanceID))
+	return c.instanceNodeAddresses(ctx, instanceID)
 }
 
 // extractIPv4NodeAddresses maps the instance information from EC2 to an array of NodeAddresses.
+// Every in-use network interface contributes its primary private IP, and its secondary private IPs when
+// includeSecondaryIPs is set, e.g. for nodes with several ENIs attached by the VPC CNI.
 // This function will extract private and public IP addresses and their corresponding DNS names.
-func extractIPv4NodeAddresses(instance *ec2types.Instance) ([]v1.NodeAddress, error) {
+func extractIPv4NodeAddresses(instance *ec2types.Instance, includeSecondaryIPs bool) ([]v1.NodeAddress, error) {
 	// Not clear if the order matters here, but we might as well indicate a sensible preference order
 
 	if instance == nil {
@@ -767,34 +1156,27 @@ func extractIPv4NodeAddresses(instance *ec2types.Instance) ([]v1.NodeAddress, er
 
 	addresses := []v1.NodeAddress{}
 
-	// sort by device index so that the first address added to the addresses list is from the first (primary) device
-	sort.Slice(instance.NetworkInterfaces, func(i, j int) bool {
-		// These nil checks should cause interfaces with non-nil attachments to sort before those with nil attachments
-		if instance.NetworkInterfaces[i].Attachment == nil {
-			return false
-		}
-		if instance.NetworkInterfaces[j].Attachment == nil {
-			return true
-		}
-
-		return aws.Int32Value(instance.NetworkInterfaces[i].Attachment.DeviceIndex) < aws.Int32Value(instance.NetworkInterfaces[j].Attachment.DeviceIndex)
-	})
+	sortNetworkInterfacesByDeviceIndex(instance)
 
 	// handle internal network interfaces
+	internalIPs := sets.NewString()
 	for _, networkInterface := range instance.NetworkInterfaces {
 		// skip network interfaces that are not currently in use
 		if networkInterface.Status != ec2types.NetworkInterfaceStatusInUse {
 			continue
 		}
 
-		for _, internalIP := range networkInterface.PrivateIpAddresses {
-			if ipAddress := aws.StringValue(internalIP.PrivateIpAddress); ipAddress != "" {
-				ip := netutils.ParseIPSloppy(ipAddress)
-				if ip == nil {
-					return nil, fmt.Errorf("EC2 instance had invalid private address: %s (%q)", aws.StringValue(instance.InstanceId), ipAddress)
-				}
-				addresses = append(addresses, v1.NodeAddress{Type: v1.NodeInternalIP, Address: ip.String()})
+		for _, ipAddress := range networkInterfacePrivateIPs(networkInterface, includeSecondaryIPs) {
+			ip := netutils.ParseIPSloppy(ipAddress)
+			if ip == nil {
+				return nil, fmt.Errorf("EC2 instance had invalid private address: %s (%q)", aws.StringValue(instance.InstanceId), ipAddress)
+			}
+			// the same address may be reported by several interfaces
+			if internalIPs.Has(ip.String()) {
+				continue
 			}
+			internalIPs.Insert(ip.String())
+			addresses = append(addresses, v1.NodeAddress{Type: v1.NodeInternalIP, Address: ip.String()})
 		}
 	}
 
@@ -822,6 +1204,71 @@ func extractIPv4NodeAddresses(instance *ec2types.Instance) ([]v1.NodeAddress, er
 	return addresses, nil
 }
 
+// sortNetworkInterfacesByDeviceIndex sorts the network interfaces of an instance by device index so that the first
+// address added to the addresses list is from the first (primary) device
+func sortNetworkInterfacesByDeviceIndex(instance *ec2types.Instance) {
+	sort.Slice(instance.NetworkInterfaces, func(i, j int) bool {
+		// These nil checks should cause interfaces with non-nil attachments to sort before those with nil attachments
+		if instance.NetworkInterfaces[i].Attachment == nil {
+			return false
+		}
+		if instance.NetworkInterfaces[j].Attachment == nil {
+			return true
+		}
+
+		return aws.Int32Value(instance.NetworkInterfaces[i].Attachment.DeviceIndex) < aws.Int32Value(instance.NetworkInterfaces[j].Attachment.DeviceIndex)
+	})
+}
+
+// networkInterfaceIPv6 returns the primary IPv6 address of a network interface, or its first IPv6 address when none
+// is marked as primary
+func networkInterfaceIPv6(networkInterface ec2types.InstanceNetworkInterface) string {
+	var first string
+	for _, ipv6Address := range networkInterface.Ipv6Addresses {
+		ipAddress := aws.StringValue(ipv6Address.Ipv6Address)
+		if ipAddress == "" {
+			continue
+		}
+		if aws.BoolValue(ipv6Address.IsPrimaryIpv6) {
+			return ipAddress
+		}
+		if first == "" {
+			first = ipAddress
+		}
+	}
+	return first
+}
+
+// networkInterfacePrivateIPs returns the primary private IP of a network interface, followed by its secondary private
+// IPs when includeSecondaryIPs is set. The first private IP is the primary one when none is marked as primary.
+func networkInterfacePrivateIPs(networkInterface ec2types.InstanceNetworkInterface, includeSecondaryIPs bool) []string {
+	var primary string
+	var secondary []string
+	for _, privateIP := range networkInterface.PrivateIpAddresses {
+		ipAddress := aws.StringValue(privateIP.PrivateIpAddress)
+		switch {
+		case ipAddress == "":
+		case aws.BoolValue(privateIP.Primary) && primary == "":
+			primary = ipAddress
+		default:
+			secondary = append(secondary, ipAddress)
+		}
+	}
+	if primary == "" {
+		primary = aws.StringValue(networkInterface.PrivateIpAddress)
+	}
+	if primary == "" && len(secondary) > 0 {
+		primary, secondary = secondary[0], secondary[1:]
+	}
+	if primary == "" {
+		return nil
+	}
+	if !includeSecondaryIPs {
+		return []string{primary}
+	}
+	return append([]string{primary}, secondary...)
+}
+
 // extractIPv6NodeAddresses maps the instance information from EC2 to an array of NodeAddresses
 // All IPv6 addresses are considered internal even if they are publicly routable. There are no instance DNS names associated with IPv6.
 func extractIPv6NodeAddresses(instance *ec2types.Instance) ([]v1.NodeAddress, error) {
@@ -833,19 +1280,30 @@ func extractIPv6NodeAddresses(instance *ec2types.Instance) ([]v1.NodeAddress, er
 
 	addresses := []v1.NodeAddress{}
 
+	sortNetworkInterfacesByDeviceIndex(instance)
+
 	// handle internal network interfaces with IPv6 addresses
+	internalIPs := sets.NewString()
 	for _, networkInterface := range instance.NetworkInterfaces {
 		// skip network interfaces that are not currently in use
-		if networkInterface.Status != ec2types.NetworkInterfaceStatusInUse || len(networkInterface.Ipv6Addresses) == 0 {
+		if networkInterface.Status != ec2types.NetworkInterfaceStatusInUse {
 			continue
 		}
 
-		// return only the "first" address for each ENI
-		internalIPv6 := aws.StringValue(networkInterface.Ipv6Addresses[0].Ipv6Address)
+		// return only the primary, or "first", address for each ENI
+		internalIPv6 := networkInterfaceIPv6(networkInterface)
+		if internalIPv6 == "" {
+			continue
+		}
 		ip := net.ParseIP(internalIPv6)
 		if ip == nil {
 			return nil, fmt.Errorf("EC2 instance had invalid IPv6 address: %s (%q)", aws.StringValue(instance.InstanceId), internalIPv6)
 		}
+		// the same address may be reported by several interfaces
+		if internalIPs.Has(ip.String()) {
+			continue
+		}
+		internalIPs.Insert(ip.String())
 		addresses = append(addresses, v1.NodeAddress{Type: v1.NodeInternalIP, Address: ip.String()})
 	}
 
@@ -856,21 +1314,37 @@ func extractIPv6NodeAddresses(instance *ec2types.Instance) ([]v1.NodeAddress, er
 // This method will not be called from the node that is requesting this ID. i.e. metadata service
 // and other local methods cannot be used here
 func (c *Cloud) NodeAddressesByProviderID(ctx context.Context, providerID string) ([]v1.NodeAddress, error) {
-	instanceID, err := KubernetesInstanceID(providerID).MapToAWSInstanceID()
+	instanceID, err := c.providerIDToInstanceID(providerID)
 	if err != nil {
 		return nil, err
 	}
+	return c.instanceNodeAddresses(ctx, instanceID)
+}
 
+// instanceNodeAddresses returns the node addresses of an instance, from the node address cache when they are cached
+func (c *Cloud) instanceNodeAddresses(ctx context.Context, instanceID InstanceID) ([]v1.NodeAddress, error) {
 	if v := variant.GetVariant(string(instanceID)); v != nil {
 		return v.NodeAddresses(ctx, string(instanceID), c.vpcID)
 	}
 
+	if addresses, ok := c.nodeAddressCache.get(string(instanceID)); ok {
+		return addresses, nil
+	}
+
 	instance, err := describeInstance(ctx, c.ec2, instanceID)
 	if err != nil {
 		return nil, err
 	}
 
-	return c.getInstanceNodeAddress(instance)
+	addresses, err := c.getInstanceNodeAddress(instance)
+	if err != nil {
+		return nil, err
+	}
+	// Instances in a transitional state are about to change their addresses
+	if !isTransitionalInstanceState(instance.State) {
+		c.nodeAddressCache.set(string(instanceID), addresses)
+	}
+	return addresses, nil
 }
 
 func (c *Cloud) getInstanceNodeAddress(instance *ec2types.Instance) ([]v1.NodeAddress, error) {
@@ -879,7 +1353,7 @@ func (c *Cloud) getInstanceNodeAddress(instance *ec2types.Instance) ([]v1.NodeAd
 	for _, family := range c.cfg.Global.NodeIPFamilies {
 		switch family {
 		case "ipv4":
-			ipv4addr, err := extractIPv4NodeAddresses(instance)
+			ipv4addr, err := extractIPv4NodeAddresses(instance, c.cfg.GetSecondaryIPNodeAddressesEnabled())
 			if err != nil {
 				return nil, err
 			}
@@ -899,7 +1373,7 @@ func (c *Cloud) getInstanceNodeAddress(instance *ec2types.Instance) ([]v1.NodeAd
 // InstanceExistsByProviderID returns true if the instance with the given provider id still exists.
 // If false is returned with no error, the instance will be immediately deleted by the cloud controller manager.
 func (c *Cloud) InstanceExistsByProviderID(ctx context.Context, providerID string) (bool, error) {
-	instanceID, err := KubernetesInstanceID(providerID).MapToAWSInstanceID()
+	instanceID, err := c.providerIDToInstanceID(providerID)
 	if err != nil {
 		return false, err
 	}
@@ -908,27 +1382,15 @@ func (c *Cloud) InstanceExistsByProviderID(ctx context.Context, providerID strin
 		return v.InstanceExists(ctx, string(instanceID), c.vpcID)
 	}
 
-	request := &ec2.DescribeInstancesInput{
-		InstanceIds: []string{string(instanceID)},
-	}
-
-	instances, err := c.describeInstanceBatcher.DescribeInstances(ctx, request)
+	instance, err := c.findInstanceByID(ctx, string(instanceID))
 	if err != nil {
-		// if err is InstanceNotFound, return false with no error
-		if IsAWSErrorInstanceNotFound(err) {
-			return false, nil
-		}
 		return false, err
 	}
-	if len(instances) == 0 {
+	if instance == nil {
 		return false, nil
 	}
-	if len(instances) > 1 {
-		return false, fmt.Errorf("multiple instances found for instance: %s", instanceID)
-	}
 
-	state := instances[0].State.Name
-	if state == ec2types.InstanceStateNameTerminated {
+	if instance.State != nil && instance.State.Name == ec2types.InstanceStateNameTerminated {
 		klog.Warningf("the instance %s is terminated", instanceID)
 		return false, nil
 	}
@@ -936,9 +1398,20 @@ func (c *Cloud) InstanceExistsByProviderID(ctx context.Context, providerID strin
 	return true, nil
 }
 
-// InstanceShutdownByProviderID returns true if the instance is terminated
+// findInstanceByID describes an instance through the instance batcher, so that the lookups of InstanceExists,
+// InstanceShutdown and InstanceMetadata for the same nodes share DescribeInstances calls. It returns nil when the
+// instance does not exist.
+func (c *Cloud) findInstanceByID(ctx context.Context, instanceID string) (*ec2types.Instance, error) {
+	instance, err := c.getInstanceByID(ctx, instanceID)
+	if errors.Is(err, cloudprovider.InstanceNotFound) || IsAWSErrorInstanceNotFound(err) {
+		return nil, nil
+	}
+	return instance, err
+}
+
+// InstanceShutdownByProviderID returns true if the instance is stopped
 func (c *Cloud) InstanceShutdownByProviderID(ctx context.Context, providerID string) (bool, error) {
-	instanceID, err := KubernetesInstanceID(providerID).MapToAWSInstanceID()
+	instanceID, err := c.providerIDToInstanceID(providerID)
 	if err != nil {
 		return false, err
 	}
@@ -947,30 +1420,19 @@ func (c *Cloud) InstanceShutdownByProviderID(ctx context.Context, providerID str
 		return v.InstanceShutdown(ctx, string(instanceID), c.vpcID)
 	}
 
-	request := &ec2.DescribeInstancesInput{
-		InstanceIds: []string{string(instanceID)},
-	}
-
-	instances, err := c.describeInstanceBatcher.DescribeInstances(ctx, request)
+	instance, err := c.findInstanceByID(ctx, string(instanceID))
 	if err != nil {
 		return false, err
 	}
-	if len(instances) == 0 {
+	if instance == nil {
 		klog.Warningf("the instance %s does not exist anymore", providerID)
 		// returns false, because otherwise node is not deleted from cluster
 		// false means that it will continue to check InstanceExistsByProviderID
 		return false, nil
 	}
-	if len(instances) > 1 {
-		return false, fmt.Errorf("multiple instances found for instance: %s", instanceID)
-	}
 
-	instance := instances[0]
-	if instance.State != nil {
-		state := instance.State.Name
-		if state == ec2types.InstanceStateNameStopped {
-			return true, nil
-		}
+	if instance.State != nil && instance.State.Name == ec2types.InstanceStateNameStopped {
+		return true, nil
 	}
 	return false, nil
 }
@@ -980,7 +1442,7 @@ func (c *Cloud) InstanceID(ctx context.Context, nodeName types.NodeName) (string
 	// In the future it is possible to also return an endpoint as:
 	// <endpoint>/<zone>/<instanceid>
 	if c.selfAWSInstance.nodeName == nodeName {
-		return "/" + c.selfAWSInstance.availabilityZone + "/" + c.selfAWSInstance.awsID, nil
+		return formatInstanceID(c.selfAWSInstance.availabilityZone, InstanceID(c.selfAWSInstance.awsID)), nil
 	}
 	inst, err := c.getInstanceByNodeName(ctx, nodeName)
 	if err != nil {
@@ -990,14 +1452,14 @@ func (c *Cloud) InstanceID(ctx context.Context, nodeName types.NodeName) (string
 		}
 		return "", fmt.Errorf("getInstanceByNodeName failed for %q with %q", nodeName, err)
 	}
-	return "/" + aws.StringValue(inst.Placement.AvailabilityZone) + "/" + aws.StringValue(inst.InstanceId), nil
+	return formatInstanceID(aws.StringValue(inst.Placement.AvailabilityZone), InstanceID(aws.StringValue(inst.InstanceId))), nil
 }
 
 // InstanceTypeByProviderID returns the cloudprovider instance type of the node with the specified unique providerID
 // This method will not be called from the node that is requesting this ID. i.e. metadata service
 // and other local methods cannot be used here
 func (c *Cloud) InstanceTypeByProviderID(ctx context.Context, providerID string) (string, error) {
-	instanceID, err := KubernetesInstanceID(providerID).MapToAWSInstanceID()
+	instanceID, err := c.providerIDToInstanceID(providerID)
 	if err != nil {
 		return "", err
 	}
@@ -1041,7 +1503,7 @@ func (c *Cloud) GetZone(ctx context.Context) (cloudprovider.Zone, error) {
 // This is particularly useful in external cloud providers where the kubelet
 // does not initialize node data.
 func (c *Cloud) GetZoneByProviderID(ctx context.Context, providerID string) (cloudprovider.Zone, error) {
-	instanceID, err := KubernetesInstanceID(providerID).MapToAWSInstanceID()
+	instanceID, err := c.providerIDToInstanceID(providerID)
 	if err != nil {
 		return cloudprovider.Zone{}, err
 	}
@@ -1050,6 +1512,8 @@ func (c *Cloud) GetZoneByProviderID(ctx context.Context, providerID string) (clo
 		return v.GetZone(ctx, string(instanceID), c.vpcID, c.region)
 	}
 
+	// Lookups by instance ID go through the describe instance batcher, so concurrent lookups of different
+	// instances are made with a single request
 	instance, err := c.getInstanceByID(ctx, string(instanceID))
 	if err != nil {
 		return cloudprovider.Zone{}, err
@@ -1058,8 +1522,12 @@ func (c *Cloud) GetZoneByProviderID(ctx context.Context, providerID string) (clo
 }
 
 func (c *Cloud) getInstanceZone(instance *ec2types.Instance) cloudprovider.Zone {
+	var availabilityZone string
+	if instance.Placement != nil {
+		availabilityZone = aws.StringValue(instance.Placement.AvailabilityZone)
+	}
 	return cloudprovider.Zone{
-		FailureDomain: *(instance.Placement.AvailabilityZone),
+		FailureDomain: availabilityZone,
 		Region:        c.region,
 	}
 }
@@ -1072,13 +1540,7 @@ func (c *Cloud) GetZoneByNodeName(ctx context.Context, nodeName types.NodeName)
 	if err != nil {
 		return cloudprovider.Zone{}, err
 	}
-	zone := cloudprovider.Zone{
-		FailureDomain: *(instance.Placement.AvailabilityZone),
-		Region:        c.region,
-	}
-
-	return zone, nil
-
+	return c.getInstanceZone(instance), nil
 }
 
 // IsAWSErrorInstanceNotFound returns true if the specified error is an awserr.Error with the code `InvalidInstanceId.NotFound`.
@@ -1171,6 +1633,23 @@ func (c *Cloud) addLoadBalancerTags(loadBalancerName string, requested map[strin
 	return nil
 }
 
+func (c *Cloud) removeLoadBalancerTags(loadBalancerName string, keys []string) error {
+	var tags []*elb.TagKeyOnly
+	for _, k := range keys {
+		tags = append(tags, &elb.TagKeyOnly{Key: aws.String(k)})
+	}
+
+	request := &elb.RemoveTagsInput{}
+	request.LoadBalancerNames = []*string{&loadBalancerName}
+	request.Tags = tags
+
+	_, err := c.elb.RemoveTags(request)
+	if err != nil {
+		return fmt.Errorf("error removing tags from load balancer: %v", err)
+	}
+	return nil
+}
+
 // Gets the current load balancer state
 func (c *Cloud) describeLoadBalancerv2(name string) (*elbv2.LoadBalancer, error) {
 	request := &elbv2.DescribeLoadBalancersInput{
@@ -1220,27 +1699,14 @@ func (c *Cloud) findVPCID() (string, error) {
 }
 
 // Retrieves the specified security group from the AWS API, or returns nil if not found
+// Lookups are batched, so concurrent reconciles share a single DescribeSecurityGroups call.
 func (c *Cloud) findSecurityGroup(ctx context.Context, securityGroupID string) (*ec2types.SecurityGroup, error) {
-	describeSecurityGroupsRequest := &ec2.DescribeSecurityGroupsInput{
-		GroupIds: []string{securityGroupID},
-	}
-	// We don't apply our tag filters because we are retrieving by ID
-
-	groups, err := c.ec2.DescribeSecurityGroups(ctx, describeSecurityGroupsRequest)
+	group, err := c.describeSecurityGroupBatcher.DescribeSecurityGroup(ctx, securityGroupID)
 	if err != nil {
 		klog.Warningf("Error retrieving security group: %q", err)
 		return nil, err
 	}
-
-	if len(groups) == 0 {
-		return nil, nil
-	}
-	if len(groups) != 1 {
-		// This should not be possible - ids should be unique
-		return nil, fmt.Errorf("multiple security groups found with same id %q", securityGroupID)
-	}
-	group := groups[0]
-	return &group, nil
+	return group, nil
 }
 
 func isEqualIntPointer(l, r *int32) bool {
@@ -1546,7 +2012,7 @@ func (c *Cloud) ensureSecurityGroup(ctx context.Context, name string, descriptio
 				klog.Warningf("Found multiple security groups with name: %q", name)
 			}
 			err := c.tagging.readRepairClusterTags(ctx,
-				c.ec2, aws.StringValue(securityGroups[0].GroupId),
+				c.createTagsBatcher, aws.StringValue(securityGroups[0].GroupId),
 				ResourceLifecycleOwned, nil, securityGroups[0].Tags)
 			if err != nil {
 				return "", err
@@ -1670,6 +2136,7 @@ func (c *Cloud) findELBSubnets(ctx context.Context, internalELB bool) ([]string,
 	}
 
 	subnetsByAZ := make(map[string]ec2types.Subnet)
+	publicSubnets := make(map[string]bool)
 	for _, subnet := range subnets {
 		az := aws.StringValue(subnet.AvailabilityZone)
 		id := aws.StringValue(subnet.SubnetId)
@@ -1686,6 +2153,7 @@ func (c *Cloud) findELBSubnets(ctx context.Context, internalELB bool) ([]string,
 			klog.V(2).Infof("Ignoring private subnet for public ELB %q", id)
 			continue
 		}
+		publicSubnets[id] = isPublic
 
 		existing, exists := subnetsByAZ[az]
 		if !exists {
@@ -1711,6 +2179,14 @@ func (c *Cloud) findELBSubnets(ctx context.Context, internalELB bool) ([]string,
 			continue
 		}
 
+		// Internal ELBs prefer private subnets
+		if internalELB && publicSubnets[aws.StringValue(existing.SubnetId)] != isPublic {
+			if !isPublic {
+				subnetsByAZ[az] = subnet
+			}
+			continue
+		}
+
 		// Prefer the one with the cluster Tag
 		existingHasClusterTag := c.tagging.hasClusterTag(existing.Tags)
 		subnetHasClusterTag := c.tagging.hasClusterTag(subnet.Tags)
@@ -1785,32 +2261,82 @@ func parseStringSliceAnnotation(annotations map[string]string, annotation string
 func (c *Cloud) getLoadBalancerSubnets(ctx context.Context, service *v1.Service, internalELB bool) ([]string, error) {
 	var rawSubnetNameOrIDs []string
 	if exists := parseStringSliceAnnotation(service.Annotations, ServiceAnnotationLoadBalancerSubnets, &rawSubnetNameOrIDs); exists {
-		return c.resolveSubnetNameOrIDs(ctx, rawSubnetNameOrIDs)
+		subnets, err := c.resolveSubnetNameOrIDs(ctx, rawSubnetNameOrIDs)
+		if err != nil {
+			return []string{}, err
+		}
+		if err := c.validateLoadBalancerSubnets(ctx, subnets, internalELB); err != nil {
+			return []string{}, err
+		}
+		var subnetIDs []string
+		for _, subnet := range subnets {
+			subnetIDs = append(subnetIDs, aws.StringValue(subnet.SubnetId))
+		}
+		return subnetIDs, nil
 	}
 	return c.findELBSubnets(ctx, internalELB)
 }
 
-func (c *Cloud) resolveSubnetNameOrIDs(ctx context.Context, subnetNameOrIDs []string) ([]string, error) {
-	var subnetIDs []string
-	var subnetNames []string
-	if len(subnetNameOrIDs) == 0 {
-		return []string{}, fmt.Errorf("unable to resolve empty subnet slice")
-	}
-	for _, nameOrID := range subnetNameOrIDs {
-		if strings.HasPrefix(nameOrID, "subnet-") {
-			subnetIDs = append(subnetIDs, nameOrID)
-		} else {
-			subnetNames = append(subnetNames, nameOrID)
+// validateLoadBalancerSubnets checks that user specified subnets can be used together for a load balancer: each subnet
+// must be in a different availability zone, and internet-facing load balancers may only use public subnets.
+func (c *Cloud) validateLoadBalancerSubnets(ctx context.Context, subnets []ec2types.Subnet, internalELB bool) error {
+	subnetsByAZ := make(map[string]string)
+	for _, subnet := range subnets {
+		az := aws.StringValue(subnet.AvailabilityZone)
+		id := aws.StringValue(subnet.SubnetId)
+		if existing, exists := subnetsByAZ[az]; exists {
+			return fmt.Errorf("subnets %q and %q from annotation %s are both in availability zone %q, only one subnet per availability zone is allowed",
+				existing, id, ServiceAnnotationLoadBalancerSubnets, az)
 		}
+		subnetsByAZ[az] = id
 	}
-	var resolvedSubnets []ec2types.Subnet
-	if len(subnetIDs) > 0 {
-		req := &ec2.DescribeSubnetsInput{
-			SubnetIds: subnetIDs,
-		}
+
+	// Internal load balancers can use public or private subnets
+	if internalELB {
+		return nil
+	}
+
+	rRequest := &ec2.DescribeRouteTablesInput{}
+	rRequest.Filters = []ec2types.Filter{newEc2Filter("vpc-id", c.vpcID)}
+	rt, err := c.ec2.DescribeRouteTables(ctx, rRequest)
+	if err != nil {
+		return fmt.Errorf("error describe route table: %q", err)
+	}
+	for _, subnet := range subnets {
+		id := aws.StringValue(subnet.SubnetId)
+		isPublic, err := isSubnetPublic(rt, id)
+		if err != nil {
+			return err
+		}
+		if !isPublic {
+			return fmt.Errorf("subnet %q from annotation %s is private and can't be used by an internet-facing load balancer",
+				id, ServiceAnnotationLoadBalancerSubnets)
+		}
+	}
+	return nil
+}
+
+func (c *Cloud) resolveSubnetNameOrIDs(ctx context.Context, subnetNameOrIDs []string) ([]ec2types.Subnet, error) {
+	var subnetIDs []string
+	var subnetNames []string
+	if len(subnetNameOrIDs) == 0 {
+		return nil, fmt.Errorf("unable to resolve empty subnet slice")
+	}
+	for _, nameOrID := range subnetNameOrIDs {
+		if strings.HasPrefix(nameOrID, "subnet-") {
+			subnetIDs = append(subnetIDs, nameOrID)
+		} else {
+			subnetNames = append(subnetNames, nameOrID)
+		}
+	}
+	var resolvedSubnets []ec2types.Subnet
+	if len(subnetIDs) > 0 {
+		req := &ec2.DescribeSubnetsInput{
+			SubnetIds: subnetIDs,
+		}
 		subnets, err := c.ec2.DescribeSubnets(ctx, req)
 		if err != nil {
-			return []string{}, err
+			return nil, err
 		}
 		resolvedSubnets = append(resolvedSubnets, subnets...)
 	}
@@ -1829,18 +2355,14 @@ func (c *Cloud) resolveSubnetNameOrIDs(ctx context.Context, subnetNameOrIDs []st
 		}
 		subnets, err := c.ec2.DescribeSubnets(ctx, req)
 		if err != nil {
-			return []string{}, err
+			return nil, err
 		}
 		resolvedSubnets = append(resolvedSubnets, subnets...)
 	}
 	if len(resolvedSubnets) != len(subnetNameOrIDs) {
-		return []string{}, fmt.Errorf("expected to find %v, but found %v subnets", len(subnetNameOrIDs), len(resolvedSubnets))
-	}
-	var subnets []string
-	for _, subnet := range resolvedSubnets {
-		subnets = append(subnets, aws.StringValue(subnet.SubnetId))
+		return nil, fmt.Errorf("expected to find %v, but found %v subnets", len(subnetNameOrIDs), len(resolvedSubnets))
 	}
-	return subnets, nil
+	return resolvedSubnets, nil
 }
 
 func isSubnetPublic(rt []ec2types.RouteTable, subnetID string) (bool, error) {
@@ -1965,6 +2487,22 @@ func (c *Cloud) buildELBSecurityGroupList(ctx context.Context, serviceName types
 	return sgList, setupSg, nil
 }
 
+// warnOnConflictingSecurityGroupAnnotations emits an event when both security group annotations are set. The
+// groups from ServiceAnnotationLoadBalancerSecurityGroups replace the managed security group, so the groups from
+// ServiceAnnotationLoadBalancerExtraSecurityGroups are only appended to them. The event is only recorded again once
+// the annotations change.
+func (c *Cloud) warnOnConflictingSecurityGroupAnnotations(service *v1.Service) {
+	securityGroups := service.Annotations[ServiceAnnotationLoadBalancerSecurityGroups]
+	extraSecurityGroups := service.Annotations[ServiceAnnotationLoadBalancerExtraSecurityGroups]
+	conflicting := len(getSGListFromAnnotation(securityGroups)) > 0 && len(getSGListFromAnnotation(extraSecurityGroups)) > 0
+	if !c.updateWarnedAnnotation(service, ServiceAnnotationLoadBalancerExtraSecurityGroups, securityGroups+"\n"+extraSecurityGroups, conflicting) {
+		return
+	}
+	c.recordServiceEvent(service, v1.EventTypeWarning, "ConflictingSecurityGroupAnnotations",
+		"Both %s and %s are set, no security group will be managed for the load balancer and the extra security groups are appended to the specified ones",
+		ServiceAnnotationLoadBalancerSecurityGroups, ServiceAnnotationLoadBalancerExtraSecurityGroups)
+}
+
 // sortELBSecurityGroupList returns a list of sorted securityGroupIDs based on the original order
 // from buildELBSecurityGroupList. The logic is:
 //   - securityGroups specified by ServiceAnnotationLoadBalancerSecurityGroups appears first in order
@@ -2010,28 +2548,43 @@ func buildListener(port v1.ServicePort, annotations map[string]string, sslPorts
 	loadBalancerPort := int64(port.Port)
 	portName := strings.ToLower(port.Name)
 	instancePort := int64(port.NodePort)
-	protocol := strings.ToLower(string(port.Protocol))
-	instanceProtocol := protocol
+
+	xForwardedFor, err := parseXForwardedForAnnotation(annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	// Backends speak HTTP when the X-Forwarded-For header is requested, and raw TCP otherwise
+	backendProtocol := annotations[ServiceAnnotationLoadBalancerBEProtocol]
+	if backendProtocol == "" && aws.BoolValue(xForwardedFor) {
+		backendProtocol = "http"
+	} else if backendProtocol == "" {
+		backendProtocol = "tcp"
+	}
 
 	listener := &elb.Listener{}
 	listener.InstancePort = &instancePort
 	listener.LoadBalancerPort = &loadBalancerPort
 	certID := annotations[ServiceAnnotationLoadBalancerCertificate]
+	if certID == "" && annotations[ServiceAnnotationLoadBalancerSSLPorts] != "" {
+		return nil, fmt.Errorf("annotation %s requires a certificate in %s", ServiceAnnotationLoadBalancerSSLPorts, ServiceAnnotationLoadBalancerCertificate)
+	}
+	protocols := plainListenerProtocols
 	if certID != "" && (sslPorts == nil || sslPorts.numbers.Has(loadBalancerPort) || sslPorts.names.Has(portName)) {
-		instanceProtocol = annotations[ServiceAnnotationLoadBalancerBEProtocol]
-		if instanceProtocol == "" {
-			protocol = "ssl"
-			instanceProtocol = "tcp"
-		} else {
-			protocol = backendProtocolMapping[instanceProtocol]
-			if protocol == "" {
-				return nil, fmt.Errorf("Invalid backend protocol %s for %s in %s", instanceProtocol, certID, ServiceAnnotationLoadBalancerBEProtocol)
-			}
-		}
+		protocols = sslListenerProtocols
 		listener.SSLCertificateId = &certID
-	} else if annotationProtocol := annotations[ServiceAnnotationLoadBalancerBEProtocol]; annotationProtocol == "http" {
-		instanceProtocol = annotationProtocol
-		protocol = "http"
+	}
+	listenerProtocols, ok := protocols[backendProtocol]
+	if !ok {
+		return nil, fmt.Errorf("Invalid backend protocol %s in %s, expected one of http, https, ssl or tcp", backendProtocol, ServiceAnnotationLoadBalancerBEProtocol)
+	}
+	protocol := listenerProtocols.protocol
+	instanceProtocol := listenerProtocols.instanceProtocol
+
+	// The listener adds the X-Forwarded-For header when it parses HTTP, the header can't be configured otherwise
+	if xForwardedFor != nil && *xForwardedFor != (protocol == "http" || protocol == "https") {
+		return nil, fmt.Errorf("annotation %s=%t conflicts with the %s listener of port %d, only HTTP and HTTPS listeners add the X-Forwarded-For header",
+			ServiceAnnotationLoadBalancerXForwardedFor, *xForwardedFor, strings.ToUpper(protocol), loadBalancerPort)
 	}
 
 	listener.Protocol = &protocol
@@ -2061,6 +2614,27 @@ func (c *Cloud) getSubnetCidrs(ctx context.Context, subnetIDs []string) ([]strin
 	return cidrs, nil
 }
 
+// getNLBHealthCheckCidrs returns the source ranges allowed to reach the health check ports of an NLB. Services with
+// the Local traffic policy allow every IPv4 CIDR of the VPC, including secondary CIDRs, since the health checks of
+// their health check node port can come from any subnet of the NLB. Other services allow the subnets of the NLB.
+func (c *Cloud) getNLBHealthCheckCidrs(ctx context.Context, service *v1.Service, subnetCidrs []string) ([]string, error) {
+	if service.Spec.ExternalTrafficPolicy != v1.ServiceExternalTrafficPolicyTypeLocal {
+		return subnetCidrs, nil
+	}
+	vpcCidrs, err := c.findVPCCIDRs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cidrs := sets.NewString(subnetCidrs...)
+	for _, cidr := range vpcCidrs {
+		// The health check rules are IPv4 ranges
+		if netutils.IsIPv4CIDRString(cidr) {
+			cidrs.Insert(cidr)
+		}
+	}
+	return cidrs.List(), nil
+}
+
 func parseStringAnnotation(annotations map[string]string, annotation string, value *string) bool {
 	if v, ok := annotations[annotation]; ok {
 		*value = v
@@ -2091,7 +2665,9 @@ func (c *Cloud) buildNLBHealthCheckConfiguration(svc *v1.Service) (healthCheckCo
 		HealthyThreshold:   defaultNlbHealthCheckThreshold,
 		UnhealthyThreshold: defaultNlbHealthCheckThreshold,
 	}
-	if svc.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal {
+	// Pods targeted by IP are health checked on their traffic port, rather than through the node
+	ipTargets := isNLB(svc.Annotations) && svc.Annotations[ServiceAnnotationLoadBalancerNLBTargetType] == elbv2.TargetTypeEnumIp
+	if svc.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal && !ipTargets {
 		path, port := servicehelpers.GetServiceHealthCheckPathPort(svc)
 		hc = healthCheckConfig{
 			Port:               strconv.Itoa(int(port)),
@@ -2124,7 +2700,7 @@ func (c *Cloud) buildNLBHealthCheckConfiguration(svc *v1.Service) (healthCheckCo
 		// The kube-proxy port should be open on all nodes and allows the health check to check the nodes ability to proxy traffic.
 		// When the node is shutting down, the health check should fail before the node loses the ability to route traffic to the backend pod.
 		// This allows the load balancer to gracefully drain connections from the node.
-		if svc.Spec.ExternalTrafficPolicy != v1.ServiceExternalTrafficPolicyTypeLocal {
+		if svc.Spec.ExternalTrafficPolicy != v1.ServiceExternalTrafficPolicyTypeLocal && !ipTargets {
 			hc.Path = defaultKubeProxyHealthCheckPath
 			if c.cfg.Global.ClusterServiceSharedLoadBalancerHealthProbePath != "" {
 				hc.Path = c.cfg.Global.ClusterServiceSharedLoadBalancerHealthProbePath
@@ -2164,14 +2740,212 @@ func (c *Cloud) buildNLBHealthCheckConfiguration(svc *v1.Service) (healthCheckCo
 	return hc, nil
 }
 
+// recordServiceEvent records an event on the service, events are dropped when no event recorder is configured
+func (c *Cloud) recordServiceEvent(service *v1.Service, eventType, reason, messageFmt string, args ...interface{}) {
+	if c.eventRecorder == nil {
+		return
+	}
+	c.eventRecorder.Eventf(service, eventType, reason, messageFmt, args...)
+}
+
+// recordNodeEvent records an event on the node, events are dropped when no event recorder is configured
+func (c *Cloud) recordNodeEvent(nodeName types.NodeName, eventType, reason, messageFmt string, args ...interface{}) {
+	if c.eventRecorder == nil {
+		return
+	}
+	nodeRef := &v1.ObjectReference{
+		Kind: "Node",
+		Name: string(nodeName),
+		UID:  types.UID(nodeName),
+	}
+	c.eventRecorder.Eventf(nodeRef, eventType, reason, messageFmt, args...)
+}
+
+// parseProxyProtocolAnnotation reports whether the proxy protocol annotation enables the proxy protocol on all backends.
+func parseProxyProtocolAnnotation(annotations map[string]string) (bool, error) {
+	proxyProtocolAnnotation := annotations[ServiceAnnotationLoadBalancerProxyProtocol]
+	if proxyProtocolAnnotation == "" {
+		return false, nil
+	}
+	if proxyProtocolAnnotation != "*" {
+		return false, fmt.Errorf("annotation %q=%q detected, but the only value supported currently is '*'", ServiceAnnotationLoadBalancerProxyProtocol, proxyProtocolAnnotation)
+	}
+	return true, nil
+}
+
+// parseXForwardedForAnnotation returns the value of the X-Forwarded-For annotation, or nil when it isn't set
+func parseXForwardedForAnnotation(annotations map[string]string) (*bool, error) {
+	xForwardedForAnnotation := annotations[ServiceAnnotationLoadBalancerXForwardedFor]
+	if xForwardedForAnnotation == "" {
+		return nil, nil
+	}
+	xForwardedFor, err := strconv.ParseBool(xForwardedForAnnotation)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing service annotation: %s=%s", ServiceAnnotationLoadBalancerXForwardedFor, xForwardedForAnnotation)
+	}
+	return &xForwardedFor, nil
+}
+
+// buildELBAttributes returns the attributes of the classic load balancer of the service, with timeouts outside of
+// the ranges allowed by AWS clamped and reported in events on the service
+func (c *Cloud) buildELBAttributes(service *v1.Service) (*elb.LoadBalancerAttributes, error) {
+	loadBalancerAttributes, err := getELBAttributesFromAnnotations(service.Annotations)
+	if err != nil {
+		return nil, err
+	}
+	if loadBalancerAttributes.ConnectionDraining.Timeout != nil {
+		loadBalancerAttributes.ConnectionDraining.Timeout = aws.Int64(c.clampConnectionDrainingTimeout(service, *loadBalancerAttributes.ConnectionDraining.Timeout))
+	}
+	loadBalancerAttributes.ConnectionSettings.IdleTimeout = aws.Int64(c.clampConnectionIdleTimeout(service, *loadBalancerAttributes.ConnectionSettings.IdleTimeout))
+	return loadBalancerAttributes, nil
+}
+
+// getELBAttributesFromAnnotations builds the attributes of a classic ELB from the service annotations
+func getELBAttributesFromAnnotations(annotations map[string]string) (*elb.LoadBalancerAttributes, error) {
+	// Some load balancer attributes are required, so defaults are set. These can be overridden by annotations.
+	loadBalancerAttributes := &elb.LoadBalancerAttributes{
+		AccessLog:              &elb.AccessLog{Enabled: aws.Bool(false)},
+		ConnectionDraining:     &elb.ConnectionDraining{Enabled: aws.Bool(false)},
+		ConnectionSettings:     &elb.ConnectionSettings{IdleTimeout: aws.Int64(60)},
+		CrossZoneLoadBalancing: &elb.CrossZoneLoadBalancing{Enabled: aws.Bool(false)},
+	}
+
+	// Determine if an access log emit interval has been specified
+	accessLogEmitIntervalAnnotation := annotations[ServiceAnnotationLoadBalancerAccessLogEmitInterval]
+	if accessLogEmitIntervalAnnotation != "" {
+		accessLogEmitInterval, err := strconv.ParseInt(accessLogEmitIntervalAnnotation, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing service annotation: %s=%s",
+				ServiceAnnotationLoadBalancerAccessLogEmitInterval,
+				accessLogEmitIntervalAnnotation,
+			)
+		}
+		// Classic ELBs only publish access logs every 5 or 60 minutes
+		if accessLogEmitInterval != 5 && accessLogEmitInterval != 60 {
+			return nil, fmt.Errorf("invalid service annotation: %s=%s, the emit interval must be 5 or 60 minutes",
+				ServiceAnnotationLoadBalancerAccessLogEmitInterval,
+				accessLogEmitIntervalAnnotation,
+			)
+		}
+		loadBalancerAttributes.AccessLog.EmitInterval = &accessLogEmitInterval
+	}
+
+	// Determine if access log enabled/disabled has been specified
+	accessLogEnabledAnnotation := annotations[ServiceAnnotationLoadBalancerAccessLogEnabled]
+	if accessLogEnabledAnnotation != "" {
+		accessLogEnabled, err := strconv.ParseBool(accessLogEnabledAnnotation)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing service annotation: %s=%s",
+				ServiceAnnotationLoadBalancerAccessLogEnabled,
+				accessLogEnabledAnnotation,
+			)
+		}
+		loadBalancerAttributes.AccessLog.Enabled = &accessLogEnabled
+	}
+
+	// Determine if access log s3 bucket name has been specified
+	accessLogS3BucketNameAnnotation := annotations[ServiceAnnotationLoadBalancerAccessLogS3BucketName]
+	if accessLogS3BucketNameAnnotation != "" {
+		loadBalancerAttributes.AccessLog.S3BucketName = &accessLogS3BucketNameAnnotation
+	}
+
+	// Determine if access log s3 bucket prefix has been specified
+	accessLogS3BucketPrefixAnnotation := annotations[ServiceAnnotationLoadBalancerAccessLogS3BucketPrefix]
+	if accessLogS3BucketPrefixAnnotation != "" {
+		loadBalancerAttributes.AccessLog.S3BucketPrefix = &accessLogS3BucketPrefixAnnotation
+	}
+
+	// Determine if connection draining enabled/disabled has been specified
+	connectionDrainingEnabledAnnotation := annotations[ServiceAnnotationLoadBalancerConnectionDrainingEnabled]
+	if connectionDrainingEnabledAnnotation != "" {
+		connectionDrainingEnabled, err := strconv.ParseBool(connectionDrainingEnabledAnnotation)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing service annotation: %s=%s",
+				ServiceAnnotationLoadBalancerConnectionDrainingEnabled,
+				connectionDrainingEnabledAnnotation,
+			)
+		}
+		loadBalancerAttributes.ConnectionDraining.Enabled = &connectionDrainingEnabled
+	}
+
+	// Determine if connection draining timeout has been specified
+	connectionDrainingTimeoutAnnotation := annotations[ServiceAnnotationLoadBalancerConnectionDrainingTimeout]
+	if connectionDrainingTimeoutAnnotation != "" {
+		connectionDrainingTimeout, err := strconv.ParseInt(connectionDrainingTimeoutAnnotation, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing service annotation: %s=%s",
+				ServiceAnnotationLoadBalancerConnectionDrainingTimeout,
+				connectionDrainingTimeoutAnnotation,
+			)
+		}
+		loadBalancerAttributes.ConnectionDraining.Timeout = &connectionDrainingTimeout
+	}
+
+	// Determine if connection idle timeout has been specified
+	connectionIdleTimeoutAnnotation := annotations[ServiceAnnotationLoadBalancerConnectionIdleTimeout]
+	if connectionIdleTimeoutAnnotation != "" {
+		connectionIdleTimeout, err := strconv.ParseInt(connectionIdleTimeoutAnnotation, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing service annotation: %s=%s",
+				ServiceAnnotationLoadBalancerConnectionIdleTimeout,
+				connectionIdleTimeoutAnnotation,
+			)
+		}
+		loadBalancerAttributes.ConnectionSettings.IdleTimeout = &connectionIdleTimeout
+	}
+
+	// Determine if cross zone load balancing enabled/disabled has been specified
+	crossZoneLoadBalancingEnabledAnnotation := annotations[ServiceAnnotationLoadBalancerCrossZoneLoadBalancingEnabled]
+	if crossZoneLoadBalancingEnabledAnnotation != "" {
+		crossZoneLoadBalancingEnabled, err := strconv.ParseBool(crossZoneLoadBalancingEnabledAnnotation)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing service annotation: %s=%s",
+				ServiceAnnotationLoadBalancerCrossZoneLoadBalancingEnabled,
+				crossZoneLoadBalancingEnabledAnnotation,
+			)
+		}
+		loadBalancerAttributes.CrossZoneLoadBalancing.Enabled = &crossZoneLoadBalancingEnabled
+	}
+
+	return loadBalancerAttributes, nil
+}
+
+// withServiceLogger returns a logger that attributes the log lines of a load balancer reconcile to the service, and
+// the context passing it to the functions called by the reconcile
+func withServiceLogger(ctx context.Context, service *v1.Service) (context.Context, klog.Logger) {
+	logger := klog.FromContext(ctx).WithValues("service", klog.KObj(service))
+	return klog.NewContext(ctx, logger), logger
+}
+
 // EnsureLoadBalancer implements LoadBalancer.EnsureLoadBalancer
 func (c *Cloud) EnsureLoadBalancer(ctx context.Context, clusterName string, apiService *v1.Service, nodes []*v1.Node) (*v1.LoadBalancerStatus, error) {
+	unlock := c.lockLoadBalancer(apiService)
+	defer unlock()
+	return c.ensureServiceLoadBalancer(ctx, clusterName, apiService, nodes)
+}
+
+// lockLoadBalancer waits until no other reconcile of the load balancer of a service runs, e.g. from the node
+// readiness queue or a previous leader's lingering reconcile, so they don't make conflicting AWS calls. The returned
+// function releases the lock.
+func (c *Cloud) lockLoadBalancer(service *v1.Service) func() {
+	key := types.NamespacedName{Namespace: service.Namespace, Name: service.Name}.String()
+	c.loadBalancerLocks.LockKey(key)
+	return func() {
+		if err := c.loadBalancerLocks.UnlockKey(key); err != nil {
+			klog.Errorf("Error unlocking load balancer of service %s: %v", key, err)
+		}
+	}
+}
+
+// ensureServiceLoadBalancer implements EnsureLoadBalancer, the caller holds the lock of the service
+func (c *Cloud) ensureServiceLoadBalancer(ctx context.Context, clusterName string, apiService *v1.Service, nodes []*v1.Node) (*v1.LoadBalancerStatus, error) {
 	annotations := apiService.Annotations
 	if isLBExternal(annotations) {
 		return nil, cloudprovider.ImplementedElsewhere
 	}
-	klog.V(2).Infof("EnsureLoadBalancer(%v, %v, %v, %v, %v, %v, %v)",
-		clusterName, apiService.Namespace, apiService.Name, c.region, apiService.Spec.LoadBalancerIP, apiService.Spec.Ports, annotations)
+	ctx, logger := withServiceLogger(ctx, apiService)
+	logger.V(2).Info("Ensuring load balancer", "clusterName", clusterName, "region", c.region,
+		"loadBalancerIP", apiService.Spec.LoadBalancerIP, "ports", apiService.Spec.Ports, "annotations", annotations)
 
 	if apiService.Spec.SessionAffinity != v1.ServiceAffinityNone {
 		// ELB supports sticky sessions, but only when configured for HTTP/HTTPS
@@ -2184,17 +2958,44 @@ func (c *Cloud) EnsureLoadBalancer(ctx context.Context, clusterName string, apiS
 	if err := checkMixedProtocol(apiService.Spec.Ports); err != nil {
 		return nil, err
 	}
+	c.warnOnUnsupportedWAFACL(apiService)
+	if err := c.validateLoadBalancerNameAnnotation(apiService); err != nil {
+		return nil, err
+	}
+	if err := c.checkLoadBalancerNameOwnership(ctx, clusterName, apiService); err != nil {
+		return nil, err
+	}
+	if err := c.deleteRenamedLoadBalancer(ctx, clusterName, apiService); err != nil {
+		return nil, err
+	}
+	annotations, err := c.resolveSSLCertificate(apiService, annotations)
+	if err != nil {
+		return nil, err
+	}
 	// Figure out what mappings we want on the load balancer
 	listeners := []*elb.Listener{}
 	v2Mappings := []nlbPortMapping{}
 
+	nlbTargetType := elbv2.TargetTypeEnumInstance
+	if isNLB(annotations) {
+		var err error
+		if nlbTargetType, err = getNLBTargetType(annotations); err != nil {
+			return nil, err
+		}
+	}
+
 	sslPorts := getPortSets(annotations[ServiceAnnotationLoadBalancerSSLPorts])
+	sslPolicies := getSSLNegotiationPolicies(annotations)
+	if isNLB(annotations) && sslPolicies != nil {
+		c.warnOnUnknownSSLNegotiationPolicies(apiService, sslPolicies)
+	}
 	for _, port := range apiService.Spec.Ports {
 		if err := checkProtocol(port, annotations); err != nil {
 			return nil, err
 		}
 
-		if port.NodePort == 0 {
+		// Pod IPs are targeted directly, so node ports aren't needed
+		if port.NodePort == 0 && nlbTargetType != elbv2.TargetTypeEnumIp {
 			klog.Errorf("Ignoring port without NodePort defined: %v", port)
 			continue
 		}
@@ -2205,17 +3006,33 @@ func (c *Cloud) EnsureLoadBalancer(ctx context.Context, clusterName string, apiS
 				FrontendProtocol: string(port.Protocol),
 				TrafficPort:      int64(port.NodePort),
 				TrafficProtocol:  string(port.Protocol),
+				TargetType:       nlbTargetType,
 			}
 			var err error
+			if nlbTargetType == elbv2.TargetTypeEnumIp {
+				portMapping.TrafficPort = nlbIPTargetPort(port)
+				if portMapping.IPTargets, err = c.computeTargetGroupExpectedIPTargets(apiService, port); err != nil {
+					return nil, err
+				}
+			}
 			if portMapping.HealthCheckConfig, err = c.buildNLBHealthCheckConfiguration(apiService); err != nil {
 				return nil, err
 			}
+			if portMapping.ProxyProtocol, err = parseProxyProtocolAnnotation(annotations); err != nil {
+				return nil, err
+			}
+			if portMapping.DeregistrationDelay, err = c.getNLBDeregistrationDelay(apiService); err != nil {
+				return nil, err
+			}
+			if portMapping.Stickiness, err = getNLBStickiness(annotations); err != nil {
+				return nil, err
+			}
 
 			certificateARN := annotations[ServiceAnnotationLoadBalancerCertificate]
 			if port.Protocol != v1.ProtocolUDP && certificateARN != "" && (sslPorts == nil || sslPorts.numbers.Has(int64(port.Port)) || sslPorts.names.Has(port.Name)) {
 				portMapping.FrontendProtocol = elbv2.ProtocolEnumTls
 				portMapping.SSLCertificateARN = certificateARN
-				portMapping.SSLPolicy = annotations[ServiceAnnotationLoadBalancerSSLNegotiationPolicy]
+				portMapping.SSLPolicy = sslPolicies.forPort(port)
 
 				if backendProtocol := annotations[ServiceAnnotationLoadBalancerBEProtocol]; backendProtocol == "ssl" {
 					portMapping.TrafficProtocol = elbv2.ProtocolEnumTls
@@ -2259,7 +3076,7 @@ func (c *Cloud) EnsureLoadBalancer(ctx context.Context, clusterName string, apiS
 		// Find the subnets that the ELB will live in
 		discoveredSubnetIDs, err := c.getLoadBalancerSubnets(ctx, apiService, internalELB)
 		if err != nil {
-			klog.Errorf("Error listing subnets in VPC: %q", err)
+			logger.Error(err, "Error listing subnets in VPC")
 			return nil, err
 		}
 		// Bail out early if there are no subnets
@@ -2275,34 +3092,6 @@ func (c *Cloud) EnsureLoadBalancer(ctx context.Context, clusterName string, apiS
 			instanceIDs = append(instanceIDs, string(id))
 		}
 
-		v2LoadBalancer, err := c.ensureLoadBalancerv2(
-			serviceName,
-			loadBalancerName,
-			v2Mappings,
-			instanceIDs,
-			discoveredSubnetIDs,
-			internalELB,
-			annotations,
-		)
-		if err != nil {
-			return nil, err
-		}
-
-		// try to get the ensured subnets of the LBs from AZs
-		var ensuredSubnetIDs []string
-		var subnetCidrs []string
-		for _, az := range v2LoadBalancer.AvailabilityZones {
-			ensuredSubnetIDs = append(ensuredSubnetIDs, *az.SubnetId)
-		}
-		if len(ensuredSubnetIDs) == 0 {
-			return nil, fmt.Errorf("did not find ensured subnets on LB %s", loadBalancerName)
-		}
-		subnetCidrs, err = c.getSubnetCidrs(ctx, ensuredSubnetIDs)
-		if err != nil {
-			klog.Errorf("Error getting subnet cidrs: %q", err)
-			return nil, err
-		}
-
 		sourceRangeCidrs := []string{}
 		for cidr := range sourceRanges {
 			sourceRangeCidrs = append(sourceRangeCidrs, cidr)
@@ -2311,131 +3100,113 @@ func (c *Cloud) EnsureLoadBalancer(ctx context.Context, clusterName string, apiS
 			sourceRangeCidrs = append(sourceRangeCidrs, "0.0.0.0/0")
 		}
 
-		err = c.updateInstanceSecurityGroupsForNLB(ctx, loadBalancerName, instances, subnetCidrs, sourceRangeCidrs, v2Mappings)
+		securityGroupIDs, err := c.buildNLBSecurityGroupList(ctx, serviceName, loadBalancerName, v2Mappings, sourceRangeCidrs, annotations)
 		if err != nil {
-			klog.Warningf("Error opening ingress rules for the load balancer to the instances: %q", err)
 			return nil, err
 		}
 
-		// We don't have an `ensureLoadBalancerInstances()` function for elbv2
-		// because `ensureLoadBalancerv2()` requires instance Ids
-
-		// TODO: Wait for creation?
-		return v2toStatus(v2LoadBalancer), nil
-	}
-
-	// Determine if we need to set the Proxy protocol policy
-	proxyProtocol := false
-	proxyProtocolAnnotation := apiService.Annotations[ServiceAnnotationLoadBalancerProxyProtocol]
-	if proxyProtocolAnnotation != "" {
-		if proxyProtocolAnnotation != "*" {
-			return nil, fmt.Errorf("annotation %q=%q detected, but the only value supported currently is '*'", ServiceAnnotationLoadBalancerProxyProtocol, proxyProtocolAnnotation)
+		allocationIDs, err := c.getNLBEIPAllocations(ctx, annotations, discoveredSubnetIDs, internalELB)
+		if err != nil {
+			return nil, err
 		}
-		proxyProtocol = true
-	}
-
-	// Some load balancer attributes are required, so defaults are set. These can be overridden by annotations.
-	loadBalancerAttributes := &elb.LoadBalancerAttributes{
-		AccessLog:              &elb.AccessLog{Enabled: aws.Bool(false)},
-		ConnectionDraining:     &elb.ConnectionDraining{Enabled: aws.Bool(false)},
-		ConnectionSettings:     &elb.ConnectionSettings{IdleTimeout: aws.Int64(60)},
-		CrossZoneLoadBalancing: &elb.CrossZoneLoadBalancing{Enabled: aws.Bool(false)},
-	}
-
-	// Determine if an access log emit interval has been specified
-	accessLogEmitIntervalAnnotation := annotations[ServiceAnnotationLoadBalancerAccessLogEmitInterval]
-	if accessLogEmitIntervalAnnotation != "" {
-		accessLogEmitInterval, err := strconv.ParseInt(accessLogEmitIntervalAnnotation, 10, 64)
+		statusAddressType, err := getStatusAddressType(annotations)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing service annotation: %s=%s",
-				ServiceAnnotationLoadBalancerAccessLogEmitInterval,
-				accessLogEmitIntervalAnnotation,
-			)
+			return nil, err
+		}
+		if statusAddressType == statusAddressTypeIP && len(allocationIDs) == 0 {
+			return nil, fmt.Errorf("annotation %s=%s requires static IPs, set %s",
+				ServiceAnnotationLoadBalancerStatusAddressType, statusAddressTypeIP, ServiceAnnotationLoadBalancerEIPAllocations)
 		}
-		loadBalancerAttributes.AccessLog.EmitInterval = &accessLogEmitInterval
-	}
 
-	// Determine if access log enabled/disabled has been specified
-	accessLogEnabledAnnotation := annotations[ServiceAnnotationLoadBalancerAccessLogEnabled]
-	if accessLogEnabledAnnotation != "" {
-		accessLogEnabled, err := strconv.ParseBool(accessLogEnabledAnnotation)
-		if err != nil {
-			return nil, fmt.Errorf("error parsing service annotation: %s=%s",
-				ServiceAnnotationLoadBalancerAccessLogEnabled,
-				accessLogEnabledAnnotation,
-			)
+		// The scheme and subnet mappings of a load balancer can't be changed, it is recreated with the new ones
+		if err := c.ensureLoadBalancerv2Scheme(apiService, loadBalancerName, internalELB); err != nil {
+			return nil, err
+		}
+		if err := c.ensureLoadBalancerv2SubnetMappings(apiService, loadBalancerName, discoveredSubnetIDs, allocationIDs); err != nil {
+			return nil, err
 		}
-		loadBalancerAttributes.AccessLog.Enabled = &accessLogEnabled
-	}
 
-	// Determine if access log s3 bucket name has been specified
-	accessLogS3BucketNameAnnotation := annotations[ServiceAnnotationLoadBalancerAccessLogS3BucketName]
-	if accessLogS3BucketNameAnnotation != "" {
-		loadBalancerAttributes.AccessLog.S3BucketName = &accessLogS3BucketNameAnnotation
-	}
+		v2LoadBalancer, err := c.ensureLoadBalancerv2(
+			ctx,
+			serviceName,
+			loadBalancerName,
+			v2Mappings,
+			instanceIDs,
+			discoveredSubnetIDs,
+			allocationIDs,
+			securityGroupIDs,
+			internalELB,
+			annotations,
+		)
+		if err != nil {
+			return nil, err
+		}
+		logger = logger.WithValues("loadBalancerARN", aws.StringValue(v2LoadBalancer.LoadBalancerArn))
+		ctx = klog.NewContext(ctx, logger)
 
-	// Determine if access log s3 bucket prefix has been specified
-	accessLogS3BucketPrefixAnnotation := annotations[ServiceAnnotationLoadBalancerAccessLogS3BucketPrefix]
-	if accessLogS3BucketPrefixAnnotation != "" {
-		loadBalancerAttributes.AccessLog.S3BucketPrefix = &accessLogS3BucketPrefixAnnotation
-	}
+		// The load balancer was created without the security group, as NLB security groups aren't supported
+		if len(securityGroupIDs) != 0 && len(v2LoadBalancer.SecurityGroups) == 0 {
+			if err := c.deleteLoadBalancerSecurityGroups(ctx, loadBalancerName, map[string]struct{}{securityGroupIDs[0]: {}}); err != nil {
+				logger.Error(err, "Error deleting unused security group of load balancer", "securityGroup", securityGroupIDs[0])
+			}
+		}
 
-	// Determine if connection draining enabled/disabled has been specified
-	connectionDrainingEnabledAnnotation := annotations[ServiceAnnotationLoadBalancerConnectionDrainingEnabled]
-	if connectionDrainingEnabledAnnotation != "" {
-		connectionDrainingEnabled, err := strconv.ParseBool(connectionDrainingEnabledAnnotation)
+		// try to get the ensured subnets of the LBs from AZs
+		var ensuredSubnetIDs []string
+		var subnetCidrs []string
+		for _, az := range v2LoadBalancer.AvailabilityZones {
+			ensuredSubnetIDs = append(ensuredSubnetIDs, *az.SubnetId)
+		}
+		if len(ensuredSubnetIDs) == 0 {
+			return nil, fmt.Errorf("did not find ensured subnets on LB %s", loadBalancerName)
+		}
+		subnetCidrs, err = c.getSubnetCidrs(ctx, ensuredSubnetIDs)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing service annotation: %s=%s",
-				ServiceAnnotationLoadBalancerConnectionDrainingEnabled,
-				connectionDrainingEnabledAnnotation,
-			)
+			logger.Error(err, "Error getting subnet cidrs")
+			return nil, err
 		}
-		loadBalancerAttributes.ConnectionDraining.Enabled = &connectionDrainingEnabled
-	}
 
-	// Determine if connection draining timeout has been specified
-	connectionDrainingTimeoutAnnotation := annotations[ServiceAnnotationLoadBalancerConnectionDrainingTimeout]
-	if connectionDrainingTimeoutAnnotation != "" {
-		connectionDrainingTimeout, err := strconv.ParseInt(connectionDrainingTimeoutAnnotation, 10, 64)
+		healthCheckCidrs, err := c.getNLBHealthCheckCidrs(ctx, apiService, subnetCidrs)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing service annotation: %s=%s",
-				ServiceAnnotationLoadBalancerConnectionDrainingTimeout,
-				connectionDrainingTimeoutAnnotation,
-			)
+			return nil, err
 		}
-		loadBalancerAttributes.ConnectionDraining.Timeout = &connectionDrainingTimeout
-	}
 
-	// Determine if connection idle timeout has been specified
-	connectionIdleTimeoutAnnotation := annotations[ServiceAnnotationLoadBalancerConnectionIdleTimeout]
-	if connectionIdleTimeoutAnnotation != "" {
-		connectionIdleTimeout, err := strconv.ParseInt(connectionIdleTimeoutAnnotation, 10, 64)
+		err = c.updateInstanceSecurityGroupsForNLB(ctx, loadBalancerName, instances, healthCheckCidrs, sourceRangeCidrs, v2Mappings)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing service annotation: %s=%s",
-				ServiceAnnotationLoadBalancerConnectionIdleTimeout,
-				connectionIdleTimeoutAnnotation,
-			)
+			logger.Error(err, "Error opening ingress rules for the load balancer to the instances")
+			return nil, err
 		}
-		loadBalancerAttributes.ConnectionSettings.IdleTimeout = &connectionIdleTimeout
-	}
 
-	// Determine if cross zone load balancing enabled/disabled has been specified
-	crossZoneLoadBalancingEnabledAnnotation := annotations[ServiceAnnotationLoadBalancerCrossZoneLoadBalancingEnabled]
-	if crossZoneLoadBalancingEnabledAnnotation != "" {
-		crossZoneLoadBalancingEnabled, err := strconv.ParseBool(crossZoneLoadBalancingEnabledAnnotation)
+		// We don't have an `ensureLoadBalancerInstances()` function for elbv2
+		// because `ensureLoadBalancerv2()` requires instance Ids
+
+		v2LoadBalancer, err = c.waitForLoadBalancerv2Active(ctx, v2LoadBalancer)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing service annotation: %s=%s",
-				ServiceAnnotationLoadBalancerCrossZoneLoadBalancingEnabled,
-				crossZoneLoadBalancingEnabledAnnotation,
-			)
+			return nil, err
 		}
-		loadBalancerAttributes.CrossZoneLoadBalancing.Enabled = &crossZoneLoadBalancingEnabled
+		return v2toStatus(v2LoadBalancer, statusAddressType), nil
+	}
+
+	// Determine if we need to set the Proxy protocol policy
+	proxyProtocol, err := parseProxyProtocolAnnotation(annotations)
+	if err != nil {
+		return nil, err
+	}
+	// The proxy protocol passes the client IP of TCP listeners, the X-Forwarded-For header the one of HTTP listeners
+	if xForwardedFor, _ := parseXForwardedForAnnotation(annotations); proxyProtocol && aws.BoolValue(xForwardedFor) {
+		return nil, fmt.Errorf("annotations %s and %s can't be used together, the proxy protocol requires TCP or SSL listeners",
+			ServiceAnnotationLoadBalancerProxyProtocol, ServiceAnnotationLoadBalancerXForwardedFor)
+	}
+
+	loadBalancerAttributes, err := c.buildELBAttributes(apiService)
+	if err != nil {
+		return nil, err
 	}
 
 	// Find the subnets that the ELB will live in
 	subnetIDs, err := c.getLoadBalancerSubnets(ctx, apiService, internalELB)
 	if err != nil {
-		klog.Errorf("Error listing subnets in VPC: %q", err)
+		logger.Error(err, "Error listing subnets in VPC")
 		return nil, err
 	}
 
@@ -2446,6 +3217,9 @@ func (c *Cloud) EnsureLoadBalancer(ctx context.Context, clusterName string, apiS
 
 	loadBalancerName := c.GetLoadBalancerName(ctx, clusterName, apiService)
 	serviceName := types.NamespacedName{Namespace: apiService.Namespace, Name: apiService.Name}
+	logger = logger.WithValues("loadBalancer", loadBalancerName)
+	ctx = klog.NewContext(ctx, logger)
+	c.warnOnConflictingSecurityGroupAnnotations(apiService)
 	securityGroupIDs, setupSg, err := c.buildELBSecurityGroupList(ctx, serviceName, loadBalancerName, annotations)
 	if err != nil {
 		return nil, err
@@ -2492,6 +3266,7 @@ func (c *Cloud) EnsureLoadBalancer(ctx context.Context, clusterName string, apiS
 
 	// Build the load balancer itself
 	loadBalancer, err := c.ensureLoadBalancer(
+		ctx,
 		serviceName,
 		loadBalancerName,
 		listeners,
@@ -2506,18 +3281,8 @@ func (c *Cloud) EnsureLoadBalancer(ctx context.Context, clusterName string, apiS
 		return nil, err
 	}
 
-	if sslPolicyName, ok := annotations[ServiceAnnotationLoadBalancerSSLNegotiationPolicy]; ok {
-		err := c.ensureSSLNegotiationPolicy(loadBalancer, sslPolicyName)
-		if err != nil {
-			return nil, err
-		}
-
-		for _, port := range c.getLoadBalancerTLSPorts(loadBalancer) {
-			err := c.setSSLNegotiationPolicy(loadBalancerName, sslPolicyName, port)
-			if err != nil {
-				return nil, err
-			}
-		}
+	if err := c.ensureLoadBalancerSSLNegotiationPolicies(apiService, loadBalancer); err != nil {
+		return nil, err
 	}
 
 	// We only configure a TCP health-check on the first port
@@ -2530,16 +3295,16 @@ func (c *Cloud) EnsureLoadBalancer(ctx context.Context, clusterName string, apiS
 		break
 	}
 	if path, healthCheckNodePort := servicehelpers.GetServiceHealthCheckPathPort(apiService); path != "" {
-		klog.V(4).Infof("service %v (%v) needs health checks on :%d%s)", apiService.Name, loadBalancerName, healthCheckNodePort, path)
+		logger.V(4).Info("Service needs health checks", "nodePort", healthCheckNodePort, "path", path)
 		if annotations[ServiceAnnotationLoadBalancerHealthCheckPort] == defaultHealthCheckPort {
 			healthCheckNodePort = tcpHealthCheckPort
 		}
-		err = c.ensureLoadBalancerHealthCheck(loadBalancer, "HTTP", healthCheckNodePort, path, annotations)
+		err = c.ensureLoadBalancerHealthCheck(apiService, loadBalancer, "HTTP", healthCheckNodePort, path)
 		if err != nil {
 			return nil, fmt.Errorf("Failed to ensure health check for localized service %v on node port %v: %q", loadBalancerName, healthCheckNodePort, err)
 		}
 	} else {
-		klog.V(4).Infof("service %v does not need custom health checks", apiService.Name)
+		logger.V(4).Info("Service does not need custom health checks")
 		var hcPath string
 		hcPort := tcpHealthCheckPort
 
@@ -2566,25 +3331,25 @@ func (c *Cloud) EnsureLoadBalancer(ctx context.Context, clusterName string, apiS
 			}
 		}
 
-		err = c.ensureLoadBalancerHealthCheck(loadBalancer, hcProtocol, hcPort, hcPath, annotations)
+		err = c.ensureLoadBalancerHealthCheck(apiService, loadBalancer, hcProtocol, hcPort, hcPath)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	err = c.updateInstanceSecurityGroupsForLoadBalancer(ctx, loadBalancer, instances, annotations, false)
+	err = c.updateInstanceSecurityGroupsForLoadBalancer(ctx, loadBalancer, instances, apiService, false)
 	if err != nil {
-		klog.Warningf("Error opening ingress rules for the load balancer to the instances: %q", err)
+		logger.Error(err, "Error opening ingress rules for the load balancer to the instances")
 		return nil, err
 	}
 
-	err = c.ensureLoadBalancerInstances(aws.StringValue(loadBalancer.LoadBalancerName), loadBalancer.Instances, instances)
+	err = c.ensureLoadBalancerInstances(ctx, aws.StringValue(loadBalancer.LoadBalancerName), loadBalancer.Instances, instances)
 	if err != nil {
-		klog.Warningf("Error registering instances with the load balancer: %q", err)
+		logger.Error(err, "Error registering instances with the load balancer")
 		return nil, err
 	}
 
-	klog.V(1).Infof("Loadbalancer %s (%v) has DNS name %s", loadBalancerName, serviceName, aws.StringValue(loadBalancer.DNSName))
+	logger.V(1).Info("Load balancer has DNS name", "dnsName", aws.StringValue(loadBalancer.DNSName))
 
 	// TODO: Wait for creation?
 
@@ -2597,7 +3362,10 @@ func (c *Cloud) GetLoadBalancer(ctx context.Context, clusterName string, service
 	if isLBExternal(service.Annotations) {
 		return nil, false, nil
 	}
-	loadBalancerName := c.GetLoadBalancerName(ctx, clusterName, service)
+	loadBalancerName, err := c.serviceLoadBalancerName(ctx, clusterName, service)
+	if err != nil {
+		return nil, false, err
+	}
 
 	if isNLB(service.Annotations) {
 		lb, err := c.describeLoadBalancerv2(loadBalancerName)
@@ -2607,7 +3375,11 @@ func (c *Cloud) GetLoadBalancer(ctx context.Context, clusterName string, service
 		if lb == nil {
 			return nil, false, nil
 		}
-		return v2toStatus(lb), true, nil
+		statusAddressType, err := getStatusAddressType(service.Annotations)
+		if err != nil {
+			return nil, false, err
+		}
+		return v2toStatus(lb, statusAddressType), true, nil
 	}
 
 	lb, err := c.describeLoadBalancer(loadBalancerName)
@@ -2624,7 +3396,11 @@ func (c *Cloud) GetLoadBalancer(ctx context.Context, clusterName string, service
 }
 
 // GetLoadBalancerName is an implementation of LoadBalancer.GetLoadBalancerName
+// A valid name annotation overrides the generated name, the other methods reject an invalid one.
 func (c *Cloud) GetLoadBalancerName(ctx context.Context, clusterName string, service *v1.Service) string {
+	if name := service.Annotations[ServiceAnnotationLoadBalancerName]; name != "" && validateLoadBalancerName(name) == nil {
+		return name
+	}
 	// TODO: replace DefaultLoadBalancerName to generate more meaningful loadbalancer names.
 	return cloudprovider.DefaultLoadBalancerName(service)
 }
@@ -2641,7 +3417,22 @@ func toStatus(lb *elb.LoadBalancerDescription) *v1.LoadBalancerStatus {
 	return status
 }
 
-func v2toStatus(lb *elbv2.LoadBalancer) *v1.LoadBalancerStatus {
+// getStatusAddressType returns the address type of the status annotation, statusAddressTypeHostname when it isn't set
+func getStatusAddressType(annotations map[string]string) (string, error) {
+	switch addressType := annotations[ServiceAnnotationLoadBalancerStatusAddressType]; addressType {
+	case "", statusAddressTypeHostname:
+		return statusAddressTypeHostname, nil
+	case statusAddressTypeIP:
+		return statusAddressTypeIP, nil
+	default:
+		return "", fmt.Errorf("invalid value %q for annotation %s, expected %s or %s",
+			addressType, ServiceAnnotationLoadBalancerStatusAddressType, statusAddressTypeHostname, statusAddressTypeIP)
+	}
+}
+
+// v2toStatus returns the status of an NLB, reporting its hostname and static IPs, or only its static IPs when the
+// address type is statusAddressTypeIP. The hostname is still reported when the NLB has no static IPs.
+func v2toStatus(lb *elbv2.LoadBalancer, addressType string) *v1.LoadBalancerStatus {
 	status := &v1.LoadBalancerStatus{}
 	if lb == nil {
 		klog.Error("[BUG] v2toStatus got nil input, this is a Kubernetes bug, please report")
@@ -2651,9 +3442,29 @@ func v2toStatus(lb *elbv2.LoadBalancer) *v1.LoadBalancerStatus {
 	// We check for Active or Provisioning, the only successful statuses
 	if aws.StringValue(lb.DNSName) != "" && (aws.StringValue(lb.State.Code) == elbv2.LoadBalancerStateEnumActive ||
 		aws.StringValue(lb.State.Code) == elbv2.LoadBalancerStateEnumProvisioning) {
-		var ingress v1.LoadBalancerIngress
-		ingress.Hostname = aws.StringValue(lb.DNSName)
-		status.Ingress = []v1.LoadBalancerIngress{ingress}
+		if addressType != statusAddressTypeIP {
+			var ingress v1.LoadBalancerIngress
+			ingress.Hostname = aws.StringValue(lb.DNSName)
+			status.Ingress = []v1.LoadBalancerIngress{ingress}
+			return status
+		}
+
+		// The static addresses, e.g. elastic IPs and the IPv6 addresses of a dualstack NLB, are reported instead of
+		// the hostname for consumers that need IPs. Their mode is proxy so that kube-proxy still sends traffic
+		// through the load balancer.
+		ipMode := v1.LoadBalancerIPModeProxy
+		seen := sets.New[string]()
+		for _, zone := range lb.AvailabilityZones {
+			for _, address := range zone.LoadBalancerAddresses {
+				for _, ip := range []*string{address.IpAddress, address.PrivateIPv4Address, address.IPv6Address} {
+					if aws.StringValue(ip) == "" || seen.Has(aws.StringValue(ip)) {
+						continue
+					}
+					seen.Insert(aws.StringValue(ip))
+					status.Ingress = append(status.Ingress, v1.LoadBalancerIngress{IP: aws.StringValue(ip), IPMode: &ipMode})
+				}
+			}
+		}
 	}
 
 	return status
@@ -2717,7 +3528,7 @@ func (c *Cloud) getTaggedSecurityGroups(ctx context.Context) (map[string]*ec2typ
 
 	m := make(map[string]*ec2types.SecurityGroup)
 	for _, group := range groups {
-		if !c.tagging.hasClusterTag(group.Tags) {
+		if !c.isClusterSecurityGroup(&group) {
 			continue
 		}
 
@@ -2731,9 +3542,94 @@ func (c *Cloud) getTaggedSecurityGroups(ctx context.Context) (map[string]*ec2typ
 	return m, nil
 }
 
+// isClusterSecurityGroup returns true if the security group has the cluster tag, matched on its full key, and all
+// the security group filter tags of the cloud config. Matching the full key keeps the groups of clusters whose IDs
+// share a prefix apart in shared VPCs.
+func (c *Cloud) isClusterSecurityGroup(group *ec2types.SecurityGroup) bool {
+	if !c.tagging.hasClusterTag(group.Tags) {
+		return false
+	}
+	tags := make(map[string]string, len(group.Tags))
+	for _, tag := range group.Tags {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	for key, value := range c.securityGroupFilterTags {
+		if actual, ok := tags[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+// loadBalancerIngressPermissions returns the permissions that open the instances to the load balancer security group.
+// Services with the Local external traffic policy only need the node ports of their listeners and their health
+// check node port, other services open all traffic from the load balancer.
+func loadBalancerIngressPermissions(service *v1.Service, loadBalancerSecurityGroupID string) []ec2types.IpPermission {
+	sourceGroups := []ec2types.UserIdGroupPair{{GroupId: aws.String(loadBalancerSecurityGroupID)}}
+	if service == nil || service.Spec.ExternalTrafficPolicy != v1.ServiceExternalTrafficPolicyTypeLocal {
+		return []ec2types.IpPermission{{IpProtocol: aws.String("-1"), UserIdGroupPairs: sourceGroups}}
+	}
+
+	ports := sets.New[int32]()
+	for _, port := range service.Spec.Ports {
+		if port.NodePort != 0 {
+			ports.Insert(port.NodePort)
+		}
+	}
+	if _, healthCheckNodePort := servicehelpers.GetServiceHealthCheckPathPort(service); healthCheckNodePort != 0 {
+		ports.Insert(healthCheckNodePort)
+	}
+	if s, ok := service.Annotations[ServiceAnnotationLoadBalancerHealthCheckPort]; ok && s != defaultHealthCheckPort {
+		if port, err := strconv.ParseInt(s, 10, 32); err == nil {
+			ports.Insert(int32(port))
+		}
+	}
+
+	permissions := make([]ec2types.IpPermission, 0, ports.Len())
+	for _, port := range sets.List(ports) {
+		permissions = append(permissions, ec2types.IpPermission{
+			IpProtocol:       aws.String("tcp"),
+			FromPort:         aws.Int32(port),
+			ToPort:           aws.Int32(port),
+			UserIdGroupPairs: sourceGroups,
+		})
+	}
+	return permissions
+}
+
+// sourceGroupPermissions returns the permissions of a security group that allow traffic from the source group
+func sourceGroupPermissions(group *ec2types.SecurityGroup, sourceGroupID string) []ec2types.IpPermission {
+	var permissions []ec2types.IpPermission
+	for _, permission := range group.IpPermissions {
+		for _, pair := range permission.UserIdGroupPairs {
+			if aws.StringValue(pair.GroupId) != sourceGroupID {
+				continue
+			}
+			permissions = append(permissions, ec2types.IpPermission{
+				IpProtocol:       permission.IpProtocol,
+				FromPort:         permission.FromPort,
+				ToPort:           permission.ToPort,
+				UserIdGroupPairs: []ec2types.UserIdGroupPair{{GroupId: aws.String(sourceGroupID)}},
+			})
+			break
+		}
+	}
+	return permissions
+}
+
+// containsIPPermission reports whether permissions contain permission
+func containsIPPermission(permissions []ec2types.IpPermission, permission ec2types.IpPermission) bool {
+	for i := range permissions {
+		if ipPermissionExists(&permission, &permissions[i], false) {
+			return true
+		}
+	}
+	return false
+}
+
 // Open security group ingress rules on the instances so that the load balancer can talk to them
 // Will also remove any security groups ingress rules for the load balancer that are _not_ needed for allInstances
-func (c *Cloud) updateInstanceSecurityGroupsForLoadBalancer(ctx context.Context, lb *elb.LoadBalancerDescription, instances map[InstanceID]*ec2types.Instance, annotations map[string]string, isDeleting bool) error {
+func (c *Cloud) updateInstanceSecurityGroupsForLoadBalancer(ctx context.Context, lb *elb.LoadBalancerDescription, instances map[InstanceID]*ec2types.Instance, service *v1.Service, isDeleting bool) error {
 	if c.cfg.Global.DisableSecurityGroupIngress {
 		return nil
 	}
@@ -2756,9 +3652,15 @@ func (c *Cloud) updateInstanceSecurityGroupsForLoadBalancer(ctx context.Context,
 		}
 	}
 
-	c.sortELBSecurityGroupList(lbSecurityGroupIDs, annotations, taggedLBSecurityGroups)
+	c.sortELBSecurityGroupList(lbSecurityGroupIDs, service.Annotations, taggedLBSecurityGroups)
 	loadBalancerSecurityGroupID := lbSecurityGroupIDs[0]
 
+	desiredPermissions := loadBalancerIngressPermissions(service, loadBalancerSecurityGroupID)
+	// The security groups of the annotation and of the cloud config can be shared by several services, which each
+	// open their own ports from them. Only the permissions of this service are revoked from the instances then.
+	sharedSecurityGroup := len(getSGListFromAnnotation(service.Annotations[ServiceAnnotationLoadBalancerSecurityGroups])) > 0 ||
+		loadBalancerSecurityGroupID == c.cfg.Global.ElbSecurityGroup
+
 	// Get the actual list of groups that allow ingress from the load-balancer
 	actualGroups := make(map[*ec2types.SecurityGroup]bool)
 	{
@@ -2771,7 +3673,7 @@ func (c *Cloud) updateInstanceSecurityGroupsForLoadBalancer(ctx context.Context,
 			return fmt.Errorf("error querying security groups for ELB: %q", err)
 		}
 		for _, sg := range response {
-			actualGroups[&sg] = c.tagging.hasClusterTag(sg.Tags)
+			actualGroups[&sg] = c.isClusterSecurityGroup(&sg)
 		}
 	}
 
@@ -2804,6 +3706,7 @@ func (c *Cloud) updateInstanceSecurityGroupsForLoadBalancer(ctx context.Context,
 	}
 
 	// Compare to actual groups
+	stalePermissions := map[string][]ec2types.IpPermission{}
 	for actualGroup, hasClusterTag := range actualGroups {
 		actualGroupID := aws.StringValue(actualGroup.GroupId)
 		if actualGroupID == "" {
@@ -2811,16 +3714,47 @@ func (c *Cloud) updateInstanceSecurityGroupsForLoadBalancer(ctx context.Context,
 			continue
 		}
 
+		actualPermissions := sourceGroupPermissions(actualGroup, loadBalancerSecurityGroupID)
 		adding, found := instanceSecurityGroupIds[actualGroupID]
 		if found && adding {
-			// We don't need to make a change; the permission is already in place
-			delete(instanceSecurityGroupIds, actualGroupID)
+			// Permissions that are no longer needed are removed, e.g. when the external traffic policy changed
+			for _, actualPermission := range actualPermissions {
+				if !sharedSecurityGroup && !containsIPPermission(desiredPermissions, actualPermission) {
+					stalePermissions[actualGroupID] = append(stalePermissions[actualGroupID], actualPermission)
+				}
+			}
+			inPlace := true
+			for _, desiredPermission := range desiredPermissions {
+				inPlace = inPlace && containsIPPermission(actualPermissions, desiredPermission)
+			}
+			if inPlace && len(stalePermissions[actualGroupID]) == 0 {
+				// We don't need to make a change; the permissions are already in place
+				delete(instanceSecurityGroupIds, actualGroupID)
+			}
 		} else {
 			if hasClusterTag || isDeleting {
 				// If the group is tagged, and we don't need the rule, we should remove it.
 				// If the security group is deleting, we should also remove the rule else
 				// we cannot remove the security group, we wiil get a dependency violation.
 				instanceSecurityGroupIds[actualGroupID] = false
+				if sharedSecurityGroup {
+					var ownPermissions []ec2types.IpPermission
+					for _, actualPermission := range actualPermissions {
+						if containsIPPermission(desiredPermissions, actualPermission) {
+							ownPermissions = append(ownPermissions, actualPermission)
+						}
+					}
+					actualPermissions = ownPermissions
+					if len(actualPermissions) == 0 {
+						// Nothing of this service to revoke, the other services still use the group
+						delete(instanceSecurityGroupIds, actualGroupID)
+						continue
+					}
+				}
+				if len(actualPermissions) == 0 {
+					actualPermissions = loadBalancerIngressPermissions(nil, loadBalancerSecurityGroupID)
+				}
+				stalePermissions[actualGroupID] = actualPermissions
 			}
 		}
 	}
@@ -2831,36 +3765,111 @@ func (c *Cloud) updateInstanceSecurityGroupsForLoadBalancer(ctx context.Context,
 		} else {
 			klog.V(2).Infof("Removing rule for traffic from the load balancer (%s) to instance (%s)", loadBalancerSecurityGroupID, instanceSecurityGroupID)
 		}
-		sourceGroupID := ec2types.UserIdGroupPair{}
-		sourceGroupID.GroupId = &loadBalancerSecurityGroupID
-
-		allProtocols := "-1"
-
-		permission := ec2types.IpPermission{}
-		permission.IpProtocol = &allProtocols
-		permission.UserIdGroupPairs = []ec2types.UserIdGroupPair{sourceGroupID}
 
-		permissions := []ec2types.IpPermission{permission}
-
-		if add {
-			changed, err := c.addSecurityGroupIngress(ctx, instanceSecurityGroupID, permissions)
+		if permissions := stalePermissions[instanceSecurityGroupID]; len(permissions) > 0 {
+			changed, err := c.removeSecurityGroupIngress(ctx, instanceSecurityGroupID, permissions)
 			if err != nil {
 				return err
 			}
 			if !changed {
-				klog.Warning("Allowing ingress was not needed; concurrent change? groupId=", instanceSecurityGroupID)
+				klog.Warning("Revoking ingress was not needed; concurrent change? groupId=", instanceSecurityGroupID)
 			}
-		} else {
-			changed, err := c.removeSecurityGroupIngress(ctx, instanceSecurityGroupID, permissions)
+		}
+		if add {
+			changed, err := c.addSecurityGroupIngress(ctx, instanceSecurityGroupID, desiredPermissions)
 			if err != nil {
 				return err
 			}
 			if !changed {
-				klog.Warning("Revoking ingress was not needed; concurrent change? groupId=", instanceSecurityGroupID)
+				klog.Warning("Allowing ingress was not needed; concurrent change? groupId=", instanceSecurityGroupID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// securityGroupDeletionBackoff is used while deleting the security groups of a deleted load balancer, which fails
+// with a DependencyViolation until the load balancer has finished deleting in the background.
+var securityGroupDeletionBackoff = wait.Backoff{
+	Duration: 5 * time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    8,
+	Cap:      2 * time.Minute,
+}
+
+// deleteLoadBalancerSecurityGroups detaches the security groups from any remaining network interfaces and deletes
+// them, retrying with backoff while they are still in use.
+func (c *Cloud) deleteLoadBalancerSecurityGroups(ctx context.Context, serviceName string, securityGroupIDs map[string]struct{}) error {
+	err := wait.ExponentialBackoffWithContext(ctx, securityGroupDeletionBackoff, func(ctx context.Context) (bool, error) {
+		for securityGroupID := range securityGroupIDs {
+			if err := c.detachSecurityGroupFromNetworkInterfaces(ctx, securityGroupID); err != nil {
+				return false, err
 			}
+			_, err := c.ec2.DeleteSecurityGroup(ctx, &ec2.DeleteSecurityGroupInput{GroupId: aws.String(securityGroupID)})
+			if err == nil {
+				delete(securityGroupIDs, securityGroupID)
+				continue
+			}
+			var ae smithy.APIError
+			if !errors.As(err, &ae) || ae.ErrorCode() != "DependencyViolation" {
+				return false, fmt.Errorf("error while deleting load balancer security group (%s): %q", securityGroupID, err)
+			}
+			klog.V(2).Infof("Ignoring DependencyViolation while deleting load-balancer security group (%s), assuming because LB is in process of deleting", securityGroupID)
+		}
+
+		if len(securityGroupIDs) == 0 {
+			klog.V(2).Info("Deleted all security groups for load balancer: ", serviceName)
+			return true, nil
+		}
+		klog.V(2).Info("Waiting for load-balancer to delete so we can delete security groups: ", serviceName)
+		return false, nil
+	})
+	if wait.Interrupted(err) {
+		ids := []string{}
+		for id := range securityGroupIDs {
+			ids = append(ids, id)
 		}
+		sort.Strings(ids)
+		return fmt.Errorf("timed out deleting ELB: %s. Could not delete security groups %v", serviceName, strings.Join(ids, ","))
 	}
+	return err
+}
 
+// detachSecurityGroupFromNetworkInterfaces removes the security group from the network interfaces still using it, so
+// that it can be deleted. Interfaces managed by AWS services, and interfaces with no other security group, are left
+// unchanged.
+func (c *Cloud) detachSecurityGroupFromNetworkInterfaces(ctx context.Context, securityGroupID string) error {
+	response, err := c.ec2.DescribeNetworkInterfaces(ctx, &ec2.DescribeNetworkInterfacesInput{
+		Filters: []ec2types.Filter{newEc2Filter("group-id", securityGroupID)},
+	})
+	if err != nil {
+		return fmt.Errorf("error describing network interfaces of security group (%s): %q", securityGroupID, err)
+	}
+	for _, networkInterface := range response.NetworkInterfaces {
+		eniID := aws.StringValue(networkInterface.NetworkInterfaceId)
+		if aws.BoolValue(networkInterface.RequesterManaged) {
+			continue
+		}
+		var groups []string
+		for _, group := range networkInterface.Groups {
+			if id := aws.StringValue(group.GroupId); id != securityGroupID {
+				groups = append(groups, id)
+			}
+		}
+		if len(groups) == 0 {
+			klog.Warningf("Not detaching security group (%s) from network interface %s, as it is its only security group", securityGroupID, eniID)
+			continue
+		}
+		klog.Infof("Detaching security group (%s) from network interface %s", securityGroupID, eniID)
+		if _, err := c.ec2.ModifyNetworkInterfaceAttribute(ctx, &ec2.ModifyNetworkInterfaceAttributeInput{
+			NetworkInterfaceId: networkInterface.NetworkInterfaceId,
+			Groups:             groups,
+		}); err != nil {
+			return fmt.Errorf("error detaching security group (%s) from network interface %s: %q", securityGroupID, eniID, err)
+		}
+	}
 	return nil
 }
 
@@ -2869,7 +3878,34 @@ func (c *Cloud) EnsureLoadBalancerDeleted(ctx context.Context, clusterName strin
 	if isLBExternal(service.Annotations) {
 		return nil
 	}
-	loadBalancerName := c.GetLoadBalancerName(ctx, clusterName, service)
+	unlock := c.lockLoadBalancer(service)
+	defer unlock()
+	ctx, logger := withServiceLogger(ctx, service)
+	c.forgetWarnedAnnotations(service)
+	// Never delete a load balancer of another service that the name annotation points to
+	if err := c.checkLoadBalancerNameOwnership(ctx, clusterName, service); err != nil {
+		logger.Info("Not deleting load balancer of service", "err", err)
+		return nil
+	}
+	loadBalancerName, err := c.serviceLoadBalancerName(ctx, clusterName, service)
+	if err != nil {
+		// The load balancer of a service with an invalid name annotation is only found by its tags
+		if loadBalancerName, err = c.findTaggedLoadBalancerName(service); err != nil {
+			return err
+		}
+		if loadBalancerName == "" {
+			logger.Info("Load balancer already deleted")
+			return nil
+		}
+	}
+	return c.deleteLoadBalancer(ctx, service, loadBalancerName)
+}
+
+// deleteLoadBalancer deletes the load balancer of the service with the given name, along with its security groups and
+// the rules allowing its traffic to the instances
+func (c *Cloud) deleteLoadBalancer(ctx context.Context, service *v1.Service, loadBalancerName string) error {
+	logger := klog.FromContext(ctx).WithValues("loadBalancer", loadBalancerName)
+	ctx = klog.NewContext(ctx, logger)
 
 	if isNLB(service.Annotations) {
 		lb, err := c.describeLoadBalancerv2(loadBalancerName)
@@ -2877,44 +3913,19 @@ func (c *Cloud) EnsureLoadBalancerDeleted(ctx context.Context, clusterName strin
 			return err
 		}
 		if lb == nil {
-			klog.Info("Load balancer already deleted: ", loadBalancerName)
+			logger.Info("Load balancer already deleted")
 			return nil
 		}
+		logger = logger.WithValues("loadBalancerARN", aws.StringValue(lb.LoadBalancerArn))
+		ctx = klog.NewContext(ctx, logger)
 
-		// Delete the LoadBalancer and target groups
-		//
-		// Deleting a target group while associated with a load balancer will
-		// fail. We delete the loadbalancer first. This does leave the
-		// possibility of zombie target groups if DeleteLoadBalancer() fails
-		//
-		// * Get target groups for NLB
-		// * Delete Load Balancer
-		// * Delete target groups
-		// * Clean up SecurityGroupRules
-		{
-
-			targetGroups, err := c.elbv2.DescribeTargetGroups(
-				&elbv2.DescribeTargetGroupsInput{LoadBalancerArn: lb.LoadBalancerArn},
-			)
-			if err != nil {
-				return fmt.Errorf("error listing target groups before deleting load balancer: %q", err)
-			}
-
-			_, err = c.elbv2.DeleteLoadBalancer(
-				&elbv2.DeleteLoadBalancerInput{LoadBalancerArn: lb.LoadBalancerArn},
-			)
-			if err != nil {
-				return fmt.Errorf("error deleting load balancer %q: %v", loadBalancerName, err)
-			}
+		// Delete the LoadBalancer and target groups, then clean up SecurityGroupRules
+		if err := c.deleteLoadBalancerv2(lb, service.Annotations); err != nil {
+			return err
+		}
 
-			for _, group := range targetGroups.TargetGroups {
-				_, err := c.elbv2.DeleteTargetGroup(
-					&elbv2.DeleteTargetGroupInput{TargetGroupArn: group.TargetGroupArn},
-				)
-				if err != nil {
-					return fmt.Errorf("error deleting target groups after deleting load balancer: %q", err)
-				}
-			}
+		if err := c.deleteNLBSecurityGroups(ctx, lb); err != nil {
+			return err
 		}
 
 		return c.updateInstanceSecurityGroupsForNLB(ctx, loadBalancerName, nil, nil, nil, nil)
@@ -2926,7 +3937,7 @@ func (c *Cloud) EnsureLoadBalancerDeleted(ctx context.Context, clusterName strin
 	}
 
 	if lb == nil {
-		klog.Info("Load balancer already deleted: ", loadBalancerName)
+		logger.Info("Load balancer already deleted")
 		return nil
 	}
 
@@ -2967,20 +3978,26 @@ func (c *Cloud) EnsureLoadBalancerDeleted(ctx context.Context, clusterName strin
 				continue
 			}
 			if sgID == "" {
-				klog.Warningf("Ignoring empty security group in %s", service.Name)
+				logger.Info("Ignoring empty security group")
 				continue
 			}
 
 			if !c.tagging.hasClusterTag(sg.Tags) {
-				klog.Warningf("Ignoring security group with no cluster tag in %s", service.Name)
+				logger.Info("Ignoring security group with no cluster tag", "securityGroup", sgID)
 				continue
 			} else {
 				taggedLBSecurityGroups[sgID] = struct{}{}
 			}
 
+			// Security groups shared with other clusters may still be in use by their load balancers.
+			if c.tagging.hasOtherClusterTag(sg.Tags) {
+				logger.Info("Ignoring security group shared with other clusters", "securityGroup", sgID)
+				continue
+			}
+
 			// This is an extra protection of deletion of non provisioned Security Group which is annotated with `service.beta.kubernetes.io/aws-load-balancer-security-groups`.
 			if _, ok := annotatedSgSet[sgID]; ok {
-				klog.Warningf("Ignoring security group with annotation `service.beta.kubernetes.io/aws-load-balancer-security-groups` or service.beta.kubernetes.io/aws-load-balancer-extra-security-groups in %s", service.Name)
+				logger.Info("Ignoring security group with annotation `service.beta.kubernetes.io/aws-load-balancer-security-groups` or service.beta.kubernetes.io/aws-load-balancer-extra-security-groups", "securityGroup", sgID)
 				continue
 			}
 
@@ -3000,9 +4017,9 @@ func (c *Cloud) EnsureLoadBalancerDeleted(ctx context.Context, clusterName strin
 		_, isDeleteingLBSecurityGroup := securityGroupIDs[loadBalancerSecurityGroupID]
 
 		// De-authorize the load balancer security group from the instances security group
-		err = c.updateInstanceSecurityGroupsForLoadBalancer(ctx, lb, nil, service.Annotations, isDeleteingLBSecurityGroup)
+		err = c.updateInstanceSecurityGroupsForLoadBalancer(ctx, lb, nil, service, isDeleteingLBSecurityGroup)
 		if err != nil {
-			klog.Errorf("Error deregistering load balancer from instance security groups: %q", err)
+			logger.Error(err, "Error deregistering load balancer from instance security groups")
 			return err
 		}
 	}
@@ -3015,57 +4032,12 @@ func (c *Cloud) EnsureLoadBalancerDeleted(ctx context.Context, clusterName strin
 		_, err = c.elb.DeleteLoadBalancer(request)
 		if err != nil {
 			// TODO: Check if error was because load balancer was concurrently deleted
-			klog.Errorf("Error deleting load balancer: %q", err)
+			logger.Error(err, "Error deleting load balancer")
 			return err
 		}
 	}
 
-	{
-
-		// Loop through and try to delete them
-		timeoutAt := time.Now().Add(time.Second * 600)
-		for {
-			for securityGroupID := range securityGroupIDs {
-				request := &ec2.DeleteSecurityGroupInput{}
-				request.GroupId = &securityGroupID
-				_, err := c.ec2.DeleteSecurityGroup(ctx, request)
-				if err == nil {
-					delete(securityGroupIDs, securityGroupID)
-				} else {
-					ignore := false
-					if awsError, ok := err.(awserr.Error); ok {
-						if awsError.Code() == "DependencyViolation" {
-							klog.V(2).Infof("Ignoring DependencyViolation while deleting load-balancer security group (%s), assuming because LB is in process of deleting", securityGroupID)
-							ignore = true
-						}
-					}
-					if !ignore {
-						return fmt.Errorf("error while deleting load balancer security group (%s): %q", securityGroupID, err)
-					}
-				}
-			}
-
-			if len(securityGroupIDs) == 0 {
-				klog.V(2).Info("Deleted all security groups for load balancer: ", service.Name)
-				break
-			}
-
-			if time.Now().After(timeoutAt) {
-				ids := []string{}
-				for id := range securityGroupIDs {
-					ids = append(ids, id)
-				}
-
-				return fmt.Errorf("timed out deleting ELB: %s. Could not delete security groups %v", service.Name, strings.Join(ids, ","))
-			}
-
-			klog.V(2).Info("Waiting for load-balancer to delete so we can delete security groups: ", service.Name)
-
-			time.Sleep(10 * time.Second)
-		}
-	}
-
-	return nil
+	return c.deleteLoadBalancerSecurityGroups(ctx, service.Name, securityGroupIDs)
 }
 
 // UpdateLoadBalancer implements LoadBalancer.UpdateLoadBalancer
@@ -3073,11 +4045,19 @@ func (c *Cloud) UpdateLoadBalancer(ctx context.Context, clusterName string, serv
 	if isLBExternal(service.Annotations) {
 		return cloudprovider.ImplementedElsewhere
 	}
+	unlock := c.lockLoadBalancer(service)
+	defer unlock()
+	if err := c.checkLoadBalancerNameOwnership(ctx, clusterName, service); err != nil {
+		return err
+	}
 	instances, err := c.findInstancesForELB(ctx, nodes, service.Annotations)
 	if err != nil {
 		return err
 	}
-	loadBalancerName := c.GetLoadBalancerName(ctx, clusterName, service)
+	loadBalancerName, err := c.serviceLoadBalancerName(ctx, clusterName, service)
+	if err != nil {
+		return err
+	}
 	if isNLB(service.Annotations) {
 		lb, err := c.describeLoadBalancerv2(loadBalancerName)
 		if err != nil {
@@ -3086,9 +4066,12 @@ func (c *Cloud) UpdateLoadBalancer(ctx context.Context, clusterName string, serv
 		if lb == nil {
 			return fmt.Errorf("Load balancer not found")
 		}
-		_, err = c.EnsureLoadBalancer(ctx, clusterName, service, nodes)
+		_, err = c.ensureServiceLoadBalancer(ctx, clusterName, service, nodes)
 		return err
 	}
+	ctx, logger := withServiceLogger(ctx, service)
+	logger = logger.WithValues("loadBalancer", loadBalancerName)
+	ctx = klog.NewContext(ctx, logger)
 	lb, err := c.describeLoadBalancer(loadBalancerName)
 	if err != nil {
 		return err
@@ -3098,26 +4081,17 @@ func (c *Cloud) UpdateLoadBalancer(ctx context.Context, clusterName string, serv
 		return fmt.Errorf("Load balancer not found")
 	}
 
-	if sslPolicyName, ok := service.Annotations[ServiceAnnotationLoadBalancerSSLNegotiationPolicy]; ok {
-		err := c.ensureSSLNegotiationPolicy(lb, sslPolicyName)
-		if err != nil {
-			return err
-		}
-		for _, port := range c.getLoadBalancerTLSPorts(lb) {
-			err := c.setSSLNegotiationPolicy(loadBalancerName, sslPolicyName, port)
-			if err != nil {
-				return err
-			}
-		}
+	if err := c.ensureLoadBalancerSSLNegotiationPolicies(service, lb); err != nil {
+		return err
 	}
 
-	err = c.ensureLoadBalancerInstances(aws.StringValue(lb.LoadBalancerName), lb.Instances, instances)
+	err = c.ensureLoadBalancerInstances(ctx, aws.StringValue(lb.LoadBalancerName), lb.Instances, instances)
 	if err != nil {
-		klog.Warningf("Error registering/deregistering instances with the load balancer: %q", err)
+		logger.Error(err, "Error registering/deregistering instances with the load balancer")
 		return err
 	}
 
-	err = c.updateInstanceSecurityGroupsForLoadBalancer(ctx, lb, instances, service.Annotations, false)
+	err = c.updateInstanceSecurityGroupsForLoadBalancer(ctx, lb, instances, service, false)
 	if err != nil {
 		return err
 	}
@@ -3148,22 +4122,35 @@ func (c *Cloud) getInstancesByIDs(ctx context.Context, instanceIDs []string) (ma
 		return instancesByID, nil
 	}
 
-	request := &ec2.DescribeInstancesInput{
-		InstanceIds: instanceIDs,
+	// The batcher describes a single instance per call, concurrent calls are batched together
+	instances := make([][]*ec2types.Instance, len(instanceIDs))
+	errs := make([]error, len(instanceIDs))
+	var wg sync.WaitGroup
+	for i, instanceID := range instanceIDs {
+		wg.Add(1)
+		go func(i int, instanceID string) {
+			defer wg.Done()
+			request := &ec2.DescribeInstancesInput{
+				InstanceIds: []string{instanceID},
+			}
+			instances[i], errs[i] = c.describeInstanceBatcher.DescribeInstances(ctx, request)
+		}(i, instanceID)
 	}
-
-	instances, err := c.describeInstanceBatcher.DescribeInstances(ctx, request)
-	if err != nil {
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
 		return nil, err
 	}
 
-	for _, instance := range instances {
-		instanceID := aws.StringValue(instance.InstanceId)
-		if instanceID == "" {
-			continue
-		}
+	for _, described := range instances {
+		for _, instance := range described {
+			// Instances that are not found have no instance ID
+			instanceID := aws.StringValue(instance.InstanceId)
+			if instanceID == "" {
+				continue
+			}
 
-		instancesByID[instanceID] = instance
+			instancesByID[instanceID] = instance
+		}
 	}
 
 	return instancesByID, nil
@@ -3358,11 +4345,7 @@ func (c *Cloud) nodeNameToInstanceID(nodeName types.NodeName) (InstanceID, error
 	if err != nil {
 		return "", err
 	}
-	if len(node.Spec.ProviderID) == 0 {
-		return "", fmt.Errorf("node has no providerID")
-	}
-
-	return KubernetesInstanceID(node.Spec.ProviderID).MapToAWSInstanceID()
+	return c.instanceIDResolver.InstanceID(node)
 }
 
 func (c *Cloud) instanceIDToNodeName(instanceID InstanceID) (types.NodeName, error) {
@@ -3422,5 +4405,9 @@ func getRegionFromMetadata(cfg config.CloudConfig, metadata config.EC2Metadata)
 		return azToRegion(zone)
 	}
 
-	return cfg.GetRegion(metadata)
+	region, err := cfg.GetRegion(metadata)
+	if err != nil {
+		return "", fmt.Errorf("unable to get the region from the ec2 metadata service, set Region or Zone in the cloud config when running outside of EC2: %v", err)
+	}
+	return region, nil
 }
@@ -17,13 +17,23 @@ limitations under the License.
 package aws
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"sync"
 	"testing"
 	"time"
 
+	stsv2 "github.com/aws/aws-sdk-go-v2/service/sts"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/cloud-provider-aws/pkg/providers/v1/config"
 )
 
 func Test_assumeRoleProviderWithRateLimiting_Retrieve(t *testing.T) {
@@ -130,3 +140,90 @@ func (f *fakeAssumeRoleProvider) Retrieve() (credentials.Value, error) {
 }
 
 func (f *fakeAssumeRoleProvider) IsExpired() bool { return true }
+
+type fakeSTSClient struct {
+	input *sts.AssumeRoleInput
+}
+
+func (f *fakeSTSClient) AssumeRole(input *sts.AssumeRoleInput) (*sts.AssumeRoleOutput, error) {
+	f.input = input
+	return &sts.AssumeRoleOutput{Credentials: &sts.Credentials{
+		AccessKeyId:     aws.String("assumedID"),
+		SecretAccessKey: aws.String("assumedSecret"),
+		SessionToken:    aws.String("assumedToken"),
+		Expiration:      aws.Time(time.Now().Add(time.Hour)),
+	}}, nil
+}
+
+type fakeSTSClientV2 struct {
+	input *stsv2.AssumeRoleInput
+}
+
+func (f *fakeSTSClientV2) AssumeRole(ctx context.Context, input *stsv2.AssumeRoleInput, optFns ...func(*stsv2.Options)) (*stsv2.AssumeRoleOutput, error) {
+	f.input = input
+	return &stsv2.AssumeRoleOutput{Credentials: &ststypes.Credentials{
+		AccessKeyId:     aws.String("assumedID"),
+		SecretAccessKey: aws.String("assumedSecret"),
+		SessionToken:    aws.String("assumedToken"),
+		Expiration:      aws.Time(time.Now().Add(time.Hour)),
+	}}, nil
+}
+
+func TestNewAssumeRoleCredentials(t *testing.T) {
+	// Environment credentials take precedence over the assumed role
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_ACCESS_KEY", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SECRET_KEY", "")
+
+	tests := []struct {
+		name            string
+		sessionName     string
+		externalID      string
+		wantExternalID  *string
+		wantSessionName bool
+	}{{
+		name: "role ARN only",
+	}, {
+		name:            "role ARN with session name and external ID",
+		sessionName:     "cloud-provider-aws",
+		externalID:      "external-id",
+		wantExternalID:  aws.String("external-id"),
+		wantSessionName: true,
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.CloudConfig{}
+			cfg.Global.RoleARN = "arn:aws:iam::123456789012:role/cloud-provider"
+			cfg.Global.RoleSessionName = tt.sessionName
+			cfg.Global.RoleExternalID = tt.externalID
+			stsClient := &fakeSTSClient{}
+			stsClientV2 := &fakeSTSClientV2{}
+
+			creds, credsV2 := newAssumeRoleCredentials(cfg, stsClient, stsClientV2)
+
+			value, err := creds.Get()
+			require.NoError(t, err)
+			assert.Equal(t, "assumedID", value.AccessKeyID)
+			assert.Equal(t, stscreds.ProviderName, value.ProviderName)
+			require.NotNil(t, stsClient.input)
+			assert.Equal(t, cfg.Global.RoleARN, aws.StringValue(stsClient.input.RoleArn))
+			assert.Equal(t, tt.wantExternalID, stsClient.input.ExternalId)
+			assert.NotEmpty(t, aws.StringValue(stsClient.input.RoleSessionName))
+			if tt.wantSessionName {
+				assert.Equal(t, tt.sessionName, aws.StringValue(stsClient.input.RoleSessionName))
+			}
+
+			valueV2, err := credsV2.Retrieve(context.TODO())
+			require.NoError(t, err)
+			assert.Equal(t, "assumedID", valueV2.AccessKeyID)
+			require.NotNil(t, stsClientV2.input)
+			assert.Equal(t, cfg.Global.RoleARN, aws.StringValue(stsClientV2.input.RoleArn))
+			assert.Equal(t, tt.wantExternalID, stsClientV2.input.ExternalId)
+			assert.NotEmpty(t, aws.StringValue(stsClientV2.input.RoleSessionName))
+			if tt.wantSessionName {
+				assert.Equal(t, tt.sessionName, aws.StringValue(stsClientV2.input.RoleSessionName))
+			}
+		})
+	}
+}
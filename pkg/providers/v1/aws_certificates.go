@@ -0,0 +1,117 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/acm"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// sslCertificateAuto is the value of ServiceAnnotationLoadBalancerCertificate that discovers the certificate in ACM
+const sslCertificateAuto = "auto"
+
+// resolveSSLCertificate returns the annotations of the service with the certificate annotation set to the ARN of the
+// ACM certificate of the hostname when it is "auto". The certificate is discovered on every sync, so listeners switch
+// to a certificate that replaces it once the old one is no longer issued.
+func (c *Cloud) resolveSSLCertificate(service *v1.Service, annotations map[string]string) (map[string]string, error) {
+	if annotations[ServiceAnnotationLoadBalancerCertificate] != sslCertificateAuto {
+		return annotations, nil
+	}
+	hostname := annotations[ServiceAnnotationLoadBalancerHostname]
+	if hostname == "" {
+		return nil, fmt.Errorf("%s is required to discover the certificate of %s: %s", ServiceAnnotationLoadBalancerHostname, ServiceAnnotationLoadBalancerCertificate, sslCertificateAuto)
+	}
+	certificateARN, err := c.discoverCertificate(service, hostname)
+	if err != nil {
+		return nil, err
+	}
+	resolved := maps.Clone(annotations)
+	resolved[ServiceAnnotationLoadBalancerCertificate] = certificateARN
+	return resolved, nil
+}
+
+// discoverCertificate returns the ARN of the only issued ACM certificate that covers the hostname, it fails and
+// records an event on the service when several certificates do
+func (c *Cloud) discoverCertificate(service *v1.Service, hostname string) (string, error) {
+	request := &acm.ListCertificatesInput{
+		CertificateStatuses: aws.StringSlice([]string{acm.CertificateStatusIssued}),
+	}
+	now := time.Now()
+	var matches []string
+	for {
+		output, err := c.acm.ListCertificates(request)
+		if err != nil {
+			return "", fmt.Errorf("error listing ACM certificates: %q", err)
+		}
+		for _, certificate := range output.CertificateSummaryList {
+			// The status of a certificate may not be updated yet when it expires
+			if certificate.NotAfter != nil && certificate.NotAfter.Before(now) {
+				continue
+			}
+			if certificateCoversHostname(certificate, hostname) {
+				matches = append(matches, aws.StringValue(certificate.CertificateArn))
+			}
+		}
+		if aws.StringValue(output.NextToken) == "" {
+			break
+		}
+		request.NextToken = output.NextToken
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no issued ACM certificate found for hostname %q", hostname)
+	case 1:
+		klog.V(2).Infof("Discovered ACM certificate %s for hostname %q", matches[0], hostname)
+		return matches[0], nil
+	}
+	c.recordServiceEvent(service, v1.EventTypeWarning, "AmbiguousCertificate",
+		"Found %d issued ACM certificates for hostname %q, expected exactly one: %s", len(matches), hostname, strings.Join(matches, ", "))
+	return "", fmt.Errorf("found %d issued ACM certificates for hostname %q, expected exactly one", len(matches), hostname)
+}
+
+// certificateCoversHostname returns whether the domain name or a subject alternative name of the certificate covers
+// the hostname
+func certificateCoversHostname(certificate *acm.CertificateSummary, hostname string) bool {
+	if domainNameCoversHostname(aws.StringValue(certificate.DomainName), hostname) {
+		return true
+	}
+	for _, name := range certificate.SubjectAlternativeNameSummaries {
+		if domainNameCoversHostname(aws.StringValue(name), hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// domainNameCoversHostname compares DNS names case-insensitively, a wildcard name covers a single label
+func domainNameCoversHostname(name, hostname string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+	if suffix, ok := strings.CutPrefix(name, "*."); ok {
+		label, rest, found := strings.Cut(hostname, ".")
+		return found && label != "" && rest == suffix
+	}
+	return name != "" && name == hostname
+}
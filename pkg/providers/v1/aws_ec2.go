@@ -36,14 +36,18 @@ type EC2API interface {
 	DeleteRoute(ctx context.Context, params *ec2.DeleteRouteInput, optFns ...func(*ec2.Options)) (*ec2.DeleteRouteOutput, error)
 	DeleteSecurityGroup(ctx context.Context, params *ec2.DeleteSecurityGroupInput, optFns ...func(*ec2.Options)) (*ec2.DeleteSecurityGroupOutput, error)
 	DeleteTags(ctx context.Context, params *ec2.DeleteTagsInput, optFns ...func(*ec2.Options)) (*ec2.DeleteTagsOutput, error)
+	DescribeAddresses(ctx context.Context, params *ec2.DescribeAddressesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeAddressesOutput, error)
 	DescribeAvailabilityZones(ctx context.Context, params *ec2.DescribeAvailabilityZonesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeAvailabilityZonesOutput, error)
 	DescribeInstances(ctx context.Context, params *ec2.DescribeInstancesInput, optFuns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error)
 	DescribeNetworkInterfaces(ctx context.Context, params *ec2.DescribeNetworkInterfacesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkInterfacesOutput, error)
 	DescribeRouteTables(ctx context.Context, params *ec2.DescribeRouteTablesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error)
 	DescribeSecurityGroups(ctx context.Context, params *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error)
 	DescribeSubnets(ctx context.Context, params *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error)
+	DescribeVolumesModifications(ctx context.Context, params *ec2.DescribeVolumesModificationsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVolumesModificationsOutput, error)
 	DescribeVpcs(ctx context.Context, params *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error)
 	ModifyInstanceAttribute(ctx context.Context, params *ec2.ModifyInstanceAttributeInput, optFns ...func(*ec2.Options)) (*ec2.ModifyInstanceAttributeOutput, error)
+	ModifyNetworkInterfaceAttribute(ctx context.Context, params *ec2.ModifyNetworkInterfaceAttributeInput, optFns ...func(*ec2.Options)) (*ec2.ModifyNetworkInterfaceAttributeOutput, error)
+	ModifyVolume(ctx context.Context, params *ec2.ModifyVolumeInput, optFns ...func(*ec2.Options)) (*ec2.ModifyVolumeOutput, error)
 	RevokeSecurityGroupIngress(ctx context.Context, params *ec2.RevokeSecurityGroupIngressInput, optFns ...func(*ec2.Options)) (*ec2.RevokeSecurityGroupIngressOutput, error)
 }
 
@@ -131,6 +135,15 @@ func (s *awsSdkEC2) DescribeSubnets(ctx context.Context, request *ec2.DescribeSu
 	return response.Subnets, nil
 }
 
+func (s *awsSdkEC2) DescribeAddresses(ctx context.Context, request *ec2.DescribeAddressesInput, optFns ...func(*ec2.Options)) ([]ec2types.Address, error) {
+	// Addresses are not paged
+	response, err := s.ec2.DescribeAddresses(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("error listing AWS addresses: %q", err)
+	}
+	return response.Addresses, nil
+}
+
 func (s *awsSdkEC2) DescribeAvailabilityZones(ctx context.Context, request *ec2.DescribeAvailabilityZonesInput, optFns ...func(*ec2.Options)) ([]ec2types.AvailabilityZone, error) {
 	// AZs are not paged
 	response, err := s.ec2.DescribeAvailabilityZones(ctx, request)
@@ -208,6 +221,44 @@ func (s *awsSdkEC2) ModifyInstanceAttribute(ctx context.Context, request *ec2.Mo
 	return s.ec2.ModifyInstanceAttribute(ctx, request)
 }
 
+func (s *awsSdkEC2) ModifyNetworkInterfaceAttribute(ctx context.Context, request *ec2.ModifyNetworkInterfaceAttributeInput, optFns ...func(*ec2.Options)) (*ec2.ModifyNetworkInterfaceAttributeOutput, error) {
+	return s.ec2.ModifyNetworkInterfaceAttribute(ctx, request)
+}
+
 func (s *awsSdkEC2) DescribeVpcs(ctx context.Context, request *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error) {
 	return s.ec2.DescribeVpcs(ctx, request)
 }
+
+func (s *awsSdkEC2) ModifyVolume(ctx context.Context, request *ec2.ModifyVolumeInput, optFns ...func(*ec2.Options)) (*ec2.ModifyVolumeOutput, error) {
+	requestTime := time.Now()
+	resp, err := s.ec2.ModifyVolume(ctx, request)
+	timeTaken := time.Since(requestTime).Seconds()
+	recordAWSMetric("modify_volume", timeTaken, err)
+	return resp, err
+}
+
+// Implements EC2.DescribeVolumesModifications
+func (s *awsSdkEC2) DescribeVolumesModifications(ctx context.Context, request *ec2.DescribeVolumesModificationsInput, optFns ...func(*ec2.Options)) ([]ec2types.VolumeModification, error) {
+	// Volume modifications are paged
+	results := []ec2types.VolumeModification{}
+	var nextToken *string
+	requestTime := time.Now()
+	for {
+		response, err := s.ec2.DescribeVolumesModifications(ctx, request)
+		if err != nil {
+			recordAWSMetric("describe_volumes_modifications", 0, err)
+			return nil, fmt.Errorf("error listing AWS volume modifications: %q", err)
+		}
+
+		results = append(results, response.VolumesModifications...)
+
+		nextToken = response.NextToken
+		if aws.ToString(nextToken) == "" {
+			break
+		}
+		request.NextToken = nextToken
+	}
+	timeTaken := time.Since(requestTime).Seconds()
+	recordAWSMetric("describe_volumes_modifications", timeTaken, nil)
+	return results, nil
+}
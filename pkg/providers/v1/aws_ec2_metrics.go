@@ -0,0 +1,174 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+
+	"k8s.io/cloud-provider-aws/pkg/providers/v1/iface"
+)
+
+// metricsEC2 wraps an EC2 client and counts every call by API name and result, and records its latency
+type metricsEC2 struct {
+	ec2 iface.EC2
+}
+
+var _ iface.EC2 = &metricsEC2{}
+
+func (m *metricsEC2) DescribeInstances(ctx context.Context, request *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) ([]ec2types.Instance, error) {
+	start := time.Now()
+	output, err := m.ec2.DescribeInstances(ctx, request, optFns...)
+	recordAWSCall("ec2", "DescribeInstances", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) DescribeSecurityGroups(ctx context.Context, request *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) ([]ec2types.SecurityGroup, error) {
+	start := time.Now()
+	output, err := m.ec2.DescribeSecurityGroups(ctx, request, optFns...)
+	recordAWSCall("ec2", "DescribeSecurityGroups", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) CreateSecurityGroup(ctx context.Context, request *ec2.CreateSecurityGroupInput, optFns ...func(*ec2.Options)) (*ec2.CreateSecurityGroupOutput, error) {
+	start := time.Now()
+	output, err := m.ec2.CreateSecurityGroup(ctx, request, optFns...)
+	recordAWSCall("ec2", "CreateSecurityGroup", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) DeleteSecurityGroup(ctx context.Context, request *ec2.DeleteSecurityGroupInput, optFns ...func(*ec2.Options)) (*ec2.DeleteSecurityGroupOutput, error) {
+	start := time.Now()
+	output, err := m.ec2.DeleteSecurityGroup(ctx, request, optFns...)
+	recordAWSCall("ec2", "DeleteSecurityGroup", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) AuthorizeSecurityGroupIngress(ctx context.Context, request *ec2.AuthorizeSecurityGroupIngressInput, optFns ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	start := time.Now()
+	output, err := m.ec2.AuthorizeSecurityGroupIngress(ctx, request, optFns...)
+	recordAWSCall("ec2", "AuthorizeSecurityGroupIngress", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) RevokeSecurityGroupIngress(ctx context.Context, request *ec2.RevokeSecurityGroupIngressInput, optFns ...func(*ec2.Options)) (*ec2.RevokeSecurityGroupIngressOutput, error) {
+	start := time.Now()
+	output, err := m.ec2.RevokeSecurityGroupIngress(ctx, request, optFns...)
+	recordAWSCall("ec2", "RevokeSecurityGroupIngress", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) DescribeSubnets(ctx context.Context, request *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) ([]ec2types.Subnet, error) {
+	start := time.Now()
+	output, err := m.ec2.DescribeSubnets(ctx, request, optFns...)
+	recordAWSCall("ec2", "DescribeSubnets", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) DescribeAddresses(ctx context.Context, request *ec2.DescribeAddressesInput, optFns ...func(*ec2.Options)) ([]ec2types.Address, error) {
+	start := time.Now()
+	output, err := m.ec2.DescribeAddresses(ctx, request, optFns...)
+	recordAWSCall("ec2", "DescribeAddresses", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) DescribeAvailabilityZones(ctx context.Context, request *ec2.DescribeAvailabilityZonesInput, optFns ...func(*ec2.Options)) ([]ec2types.AvailabilityZone, error) {
+	start := time.Now()
+	output, err := m.ec2.DescribeAvailabilityZones(ctx, request, optFns...)
+	recordAWSCall("ec2", "DescribeAvailabilityZones", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) CreateTags(ctx context.Context, request *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+	start := time.Now()
+	output, err := m.ec2.CreateTags(ctx, request, optFns...)
+	recordAWSCall("ec2", "CreateTags", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) DeleteTags(ctx context.Context, request *ec2.DeleteTagsInput, optFns ...func(*ec2.Options)) (*ec2.DeleteTagsOutput, error) {
+	start := time.Now()
+	output, err := m.ec2.DeleteTags(ctx, request, optFns...)
+	recordAWSCall("ec2", "DeleteTags", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) DescribeRouteTables(ctx context.Context, request *ec2.DescribeRouteTablesInput, optFns ...func(*ec2.Options)) ([]ec2types.RouteTable, error) {
+	start := time.Now()
+	output, err := m.ec2.DescribeRouteTables(ctx, request, optFns...)
+	recordAWSCall("ec2", "DescribeRouteTables", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) CreateRoute(ctx context.Context, request *ec2.CreateRouteInput, optFns ...func(*ec2.Options)) (*ec2.CreateRouteOutput, error) {
+	start := time.Now()
+	output, err := m.ec2.CreateRoute(ctx, request, optFns...)
+	recordAWSCall("ec2", "CreateRoute", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) DeleteRoute(ctx context.Context, request *ec2.DeleteRouteInput, optFns ...func(*ec2.Options)) (*ec2.DeleteRouteOutput, error) {
+	start := time.Now()
+	output, err := m.ec2.DeleteRoute(ctx, request, optFns...)
+	recordAWSCall("ec2", "DeleteRoute", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) ModifyInstanceAttribute(ctx context.Context, request *ec2.ModifyInstanceAttributeInput, optFns ...func(*ec2.Options)) (*ec2.ModifyInstanceAttributeOutput, error) {
+	start := time.Now()
+	output, err := m.ec2.ModifyInstanceAttribute(ctx, request, optFns...)
+	recordAWSCall("ec2", "ModifyInstanceAttribute", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) ModifyNetworkInterfaceAttribute(ctx context.Context, request *ec2.ModifyNetworkInterfaceAttributeInput, optFns ...func(*ec2.Options)) (*ec2.ModifyNetworkInterfaceAttributeOutput, error) {
+	start := time.Now()
+	output, err := m.ec2.ModifyNetworkInterfaceAttribute(ctx, request, optFns...)
+	recordAWSCall("ec2", "ModifyNetworkInterfaceAttribute", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) DescribeVpcs(ctx context.Context, input *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error) {
+	start := time.Now()
+	output, err := m.ec2.DescribeVpcs(ctx, input, optFns...)
+	recordAWSCall("ec2", "DescribeVpcs", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) DescribeNetworkInterfaces(ctx context.Context, input *ec2.DescribeNetworkInterfacesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	start := time.Now()
+	output, err := m.ec2.DescribeNetworkInterfaces(ctx, input, optFns...)
+	recordAWSCall("ec2", "DescribeNetworkInterfaces", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) ModifyVolume(ctx context.Context, request *ec2.ModifyVolumeInput, optFns ...func(*ec2.Options)) (*ec2.ModifyVolumeOutput, error) {
+	start := time.Now()
+	output, err := m.ec2.ModifyVolume(ctx, request, optFns...)
+	recordAWSCall("ec2", "ModifyVolume", start, err)
+	return output, err
+}
+
+func (m *metricsEC2) DescribeVolumesModifications(ctx context.Context, request *ec2.DescribeVolumesModificationsInput, optFns ...func(*ec2.Options)) ([]ec2types.VolumeModification, error) {
+	start := time.Now()
+	output, err := m.ec2.DescribeVolumesModifications(ctx, request, optFns...)
+	recordAWSCall("ec2", "DescribeVolumesModifications", start, err)
+	return output, err
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/component-base/metrics/testutil"
+
+	"k8s.io/cloud-provider-aws/pkg/providers/v1/config"
+)
+
+func TestMetricsEC2CountsCalls(t *testing.T) {
+	instance := makeInstance("i-00000000000000000", "192.168.0.1", "1.2.3.4", "instance-same.ec2.internal", "instance-same.ec2.external", nil, true)
+	awsServices := NewFakeAWSServices(TestClusterID)
+	awsServices.instances = []*ec2types.Instance{&instance}
+	awsServices.selfInstance = &instance
+	cfg := config.CloudConfig{}
+	cfg.Global.EnableAPICallMetrics = true
+	c, err := newAWSCloud(cfg, awsServices)
+	require.NoError(t, err)
+	require.IsType(t, &metricsEC2{}, c.ec2)
+
+	callCount := func(result string) float64 {
+		value, err := testutil.GetCounterMetricValue(awsAPICallsMetric.WithLabelValues("ec2", "DescribeInstances", result))
+		require.NoError(t, err)
+		return value
+	}
+	successes, errs := callCount("success"), callCount("error")
+
+	_, err = c.ec2.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{})
+	require.NoError(t, err)
+	assert.Equal(t, successes+1, callCount("success"))
+	assert.Equal(t, errs, callCount("error"))
+
+	awsServices.ec2.(*FakeEC2Impl).SetError("DescribeInstances", errors.New("throttled"))
+	_, err = c.ec2.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{})
+	require.Error(t, err)
+	assert.Equal(t, successes+1, callCount("success"))
+	assert.Equal(t, errs+1, callCount("error"))
+}
+
+func TestMetricsEC2Disabled(t *testing.T) {
+	awsServices := NewFakeAWSServices(TestClusterID)
+	c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+	require.NoError(t, err)
+	assert.IsType(t, &FakeEC2Impl{}, c.ec2)
+}
@@ -20,19 +20,25 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	stscredsv2 "github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/acm"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/elb"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/smithy-go"
 	"k8s.io/klog/v2"
 
 	"k8s.io/cloud-provider-aws/pkg/providers/v1/config"
@@ -54,6 +60,8 @@ type FakeAWSServices struct {
 	asg      *FakeASG
 	metadata *FakeMetadata
 	kms      *FakeKMS
+	acm      *FakeACM
+	sqs      *FakeSQS
 
 	callCounts map[string]int
 }
@@ -68,6 +76,8 @@ func NewFakeAWSServices(clusterID string) *FakeAWSServices {
 	s.asg = &FakeASG{aws: s}
 	s.metadata = &FakeMetadata{aws: s}
 	s.kms = &FakeKMS{aws: s}
+	s.acm = &FakeACM{aws: s}
+	s.sqs = &FakeSQS{aws: s}
 
 	s.networkInterfacesMacs = []string{"aa:bb:cc:dd:ee:00", "aa:bb:cc:dd:ee:01"}
 	s.networkInterfacesVpcIDs = []string{"vpc-mac0", "vpc-mac1"}
@@ -146,6 +156,32 @@ func (s *FakeAWSServices) WithRegion(region string) *FakeAWSServices {
 	return s
 }
 
+// WithELB sets the ELB client, replacing the fake ELB client
+func (s *FakeAWSServices) WithELB(elb ELB) *FakeAWSServices {
+	s.elb = elb
+	return s
+}
+
+// WithELBV2 sets the ELBV2 client, replacing the fake ELBV2 client
+func (s *FakeAWSServices) WithELBV2(elbv2 ELBV2) *FakeAWSServices {
+	s.elbv2 = elbv2
+	return s
+}
+
+// WithInstances adds n fake instances, with IDs i-0 to i-(n-1), to the instances described by the fake EC2 client
+func (s *FakeAWSServices) WithInstances(n int) *FakeAWSServices {
+	for i := 0; i < n; i++ {
+		s.instances = append(s.instances, &ec2types.Instance{
+			InstanceId: aws.String(fmt.Sprintf("i-%d", i)),
+			Placement: &ec2types.Placement{
+				AvailabilityZone: s.selfInstance.Placement.AvailabilityZone,
+			},
+			State: &ec2types.InstanceState{Name: ec2types.InstanceStateNameRunning},
+		})
+	}
+	return s
+}
+
 // countCall increments the counter for the given service, api, and resourceID and returns the resulting call count
 func (s *FakeAWSServices) countCall(service string, api string, resourceID string) int {
 	key := fmt.Sprintf("%s:%s:%s", service, api, resourceID)
@@ -175,11 +211,26 @@ func (s *FakeAWSServices) Metadata() (config.EC2Metadata, error) {
 	return s.metadata, nil
 }
 
+// Autoscaling returns a fake ASG client
+func (s *FakeAWSServices) Autoscaling(region string) (ASG, error) {
+	return s.asg, nil
+}
+
 // KeyManagement returns a fake KMS client
 func (s *FakeAWSServices) KeyManagement(region string) (KMS, error) {
 	return s.kms, nil
 }
 
+// CertificateManager returns a fake ACM client
+func (s *FakeAWSServices) CertificateManager(region string) (ACM, error) {
+	return s.acm, nil
+}
+
+// MessageQueue returns a fake SQS client
+func (s *FakeAWSServices) MessageQueue(region string) (SQS, error) {
+	return s.sqs, nil
+}
+
 // FakeEC2 is a fake EC2 client used for testing
 type FakeEC2 interface {
 	iface.EC2
@@ -196,12 +247,77 @@ type FakeEC2Impl struct {
 	DescribeSubnetsInput     *ec2.DescribeSubnetsInput
 	RouteTables              []ec2types.RouteTable
 	DescribeRouteTablesInput *ec2.DescribeRouteTablesInput
+	SecurityGroups           []ec2types.SecurityGroup
+	Vpcs                     []ec2types.Vpc
+	NetworkInterfaces        []ec2types.NetworkInterface
+	VolumeModifications      map[string]*ec2types.VolumeModification
+	Addresses                []ec2types.Address
+	// ModifyInstanceAttributeInputs are the requests of the calls to ModifyInstanceAttribute
+	ModifyInstanceAttributeInputs []*ec2.ModifyInstanceAttributeInput
+
+	fakeAPIErrors
+}
+
+// fakeAPIErrors injects errors into the calls of a fake AWS client and counts the calls by API name
+type fakeAPIErrors struct {
+	// injected errors returned by API name, and by API name and call number
+	errorsMu     sync.Mutex
+	apiCalls     map[string]int
+	errors       map[string]error
+	errorsOnCall map[string]map[int]error
+}
+
+// SetError makes every following call to the named API, e.g. "DescribeInstances", return err. A nil err
+// clears the injected error.
+func (f *fakeAPIErrors) SetError(apiName string, err error) {
+	f.errorsMu.Lock()
+	defer f.errorsMu.Unlock()
+	if f.errors == nil {
+		f.errors = map[string]error{}
+	}
+	f.errors[apiName] = err
+}
+
+// SetErrorOnCall makes the n-th call to the named API return err, counting from 1
+func (f *fakeAPIErrors) SetErrorOnCall(apiName string, n int, err error) {
+	f.errorsMu.Lock()
+	defer f.errorsMu.Unlock()
+	if f.errorsOnCall == nil {
+		f.errorsOnCall = map[string]map[int]error{}
+	}
+	if f.errorsOnCall[apiName] == nil {
+		f.errorsOnCall[apiName] = map[int]error{}
+	}
+	f.errorsOnCall[apiName][n] = err
+}
+
+// injectedError counts a call to the named API and returns the error injected for it, if any
+func (f *fakeAPIErrors) injectedError(apiName string) error {
+	f.errorsMu.Lock()
+	defer f.errorsMu.Unlock()
+	if f.apiCalls == nil {
+		f.apiCalls = map[string]int{}
+	}
+	f.apiCalls[apiName]++
+	if err := f.errorsOnCall[apiName][f.apiCalls[apiName]]; err != nil {
+		return err
+	}
+	return f.errors[apiName]
 }
 
 // DescribeInstances returns fake instance descriptions
 func (ec2i *FakeEC2Impl) DescribeInstances(ctx context.Context, request *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) ([]ec2types.Instance, error) {
+	if err := ec2i.injectedError("DescribeInstances"); err != nil {
+		return nil, err
+	}
+	matches := ec2i.matchInstances(request)
+	ec2i.aws.countCall("ec2", "DescribeInstances", strings.Join(instanceIDs(matches), ","))
+	return matches, nil
+}
+
+// matchInstances returns the fake instances matching the instance IDs and filters of the request
+func (ec2i *FakeEC2Impl) matchInstances(request *ec2.DescribeInstancesInput) []ec2types.Instance {
 	matches := []ec2types.Instance{}
-	var matchedInstances []string
 	for _, instance := range ec2i.aws.instances {
 		if request.InstanceIds != nil {
 			if instance.InstanceId == nil {
@@ -233,11 +349,57 @@ func (ec2i *FakeEC2Impl) DescribeInstances(ctx context.Context, request *ec2.Des
 			}
 		}
 		matches = append(matches, *instance)
-		matchedInstances = append(matchedInstances, *instance.InstanceId)
 	}
+	return matches
+}
 
-	ec2i.aws.countCall("ec2", "DescribeInstances", strings.Join(matchedInstances, ","))
-	return matches, nil
+func instanceIDs(instances []ec2types.Instance) []string {
+	ids := make([]string, 0, len(instances))
+	for _, instance := range instances {
+		ids = append(ids, aws.StringValue(instance.InstanceId))
+	}
+	return ids
+}
+
+// FakeEC2API is a paged EC2API backed by a FakeEC2Impl, it can be wrapped in an awsSdkEC2 to test pagination
+type FakeEC2API struct {
+	EC2API
+	ec2 *FakeEC2Impl
+}
+
+// NewFakeEC2API creates a FakeEC2API serving the instances of the given FakeAWSServices
+func NewFakeEC2API(s *FakeAWSServices) *FakeEC2API {
+	return &FakeEC2API{ec2: s.ec2.(*FakeEC2Impl)}
+}
+
+// DescribeInstances returns a single page of fake instance descriptions. When more instances match than
+// MaxResults, a NextToken is returned that serves the following page.
+func (f *FakeEC2API) DescribeInstances(ctx context.Context, request *ec2.DescribeInstancesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	if err := f.ec2.injectedError("DescribeInstances"); err != nil {
+		return nil, err
+	}
+	matches := f.ec2.matchInstances(request)
+	start := 0
+	if token := aws.StringValue(request.NextToken); token != "" {
+		var err error
+		if start, err = strconv.Atoi(token); err != nil || start > len(matches) {
+			return nil, fmt.Errorf("invalid NextToken %q", token)
+		}
+	}
+	end := len(matches)
+	if request.MaxResults != nil && start+int(*request.MaxResults) < end {
+		end = start + int(*request.MaxResults)
+	}
+	page := matches[start:end]
+	f.ec2.aws.countCall("ec2", "DescribeInstances", strings.Join(instanceIDs(page), ","))
+
+	output := &ec2.DescribeInstancesOutput{
+		Reservations: []ec2types.Reservation{{Instances: page}},
+	}
+	if end < len(matches) {
+		output.NextToken = aws.String(strconv.Itoa(end))
+	}
+	return output, nil
 }
 
 // AttachVolume is not implemented but is required for interface conformance
@@ -265,45 +427,193 @@ func (ec2i *FakeEC2Impl) DeleteVolume(request *ec2.DeleteVolumeInput) (resp *ec2
 	panic("Not implemented")
 }
 
-// DescribeSecurityGroups is not implemented but is required for interface
-// conformance
+// DescribeSecurityGroups returns the fake security groups matching the group IDs of the request. Like EC2, it fails
+// when any of the requested groups doesn't exist.
 func (ec2i *FakeEC2Impl) DescribeSecurityGroups(ctx context.Context, request *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) ([]ec2types.SecurityGroup, error) {
-	panic("Not implemented")
+	if err := ec2i.injectedError("DescribeSecurityGroups"); err != nil {
+		return nil, err
+	}
+	ec2i.aws.countCall("ec2", "DescribeSecurityGroups", "")
+	if len(request.GroupIds) == 0 {
+		for _, filter := range request.Filters {
+			switch aws.StringValue(filter.Name) {
+			case "ip-permission.group-id":
+				return ec2i.securityGroupsWithSourceGroups(filter.Values), nil
+			case "group-name":
+				return ec2i.securityGroupsWithNames(filter.Values), nil
+			}
+		}
+		return ec2i.SecurityGroups, nil
+	}
+	matches := []ec2types.SecurityGroup{}
+	for _, id := range request.GroupIds {
+		found := false
+		for _, sg := range ec2i.SecurityGroups {
+			if aws.StringValue(sg.GroupId) == id {
+				matches = append(matches, sg)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("InvalidGroup.NotFound: the security group %q does not exist", id)
+		}
+	}
+	return matches, nil
 }
 
-// CreateSecurityGroup is not implemented but is required for interface
-// conformance
+// securityGroupsWithSourceGroups returns the fake security groups that allow ingress from any of the source groups
+func (ec2i *FakeEC2Impl) securityGroupsWithSourceGroups(sourceGroupIDs []string) []ec2types.SecurityGroup {
+	matches := []ec2types.SecurityGroup{}
+	for _, sg := range ec2i.SecurityGroups {
+		for _, sourceGroupID := range sourceGroupIDs {
+			if len(sourceGroupPermissions(&sg, sourceGroupID)) > 0 {
+				matches = append(matches, sg)
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// securityGroupsWithNames returns the fake security groups with any of the names
+func (ec2i *FakeEC2Impl) securityGroupsWithNames(names []string) []ec2types.SecurityGroup {
+	matches := []ec2types.SecurityGroup{}
+	for _, sg := range ec2i.SecurityGroups {
+		for _, name := range names {
+			if aws.StringValue(sg.GroupName) == name {
+				matches = append(matches, sg)
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// CreateSecurityGroup adds a fake security group with an ID derived from its name and the requested tags, or returns
+// the injected error
 func (ec2i *FakeEC2Impl) CreateSecurityGroup(ctx context.Context, request *ec2.CreateSecurityGroupInput, optFns ...func(*ec2.Options)) (*ec2.CreateSecurityGroupOutput, error) {
-	panic("Not implemented")
+	if err := ec2i.injectedError("CreateSecurityGroup"); err != nil {
+		return nil, err
+	}
+	group := ec2types.SecurityGroup{
+		GroupId:     aws.String("sg-" + aws.StringValue(request.GroupName)),
+		GroupName:   request.GroupName,
+		Description: request.Description,
+		VpcId:       request.VpcId,
+	}
+	for _, specification := range request.TagSpecifications {
+		group.Tags = append(group.Tags, specification.Tags...)
+	}
+	ec2i.SecurityGroups = append(ec2i.SecurityGroups, group)
+	return &ec2.CreateSecurityGroupOutput{GroupId: group.GroupId}, nil
 }
 
-// DeleteSecurityGroup is not implemented but is required for interface
-// conformance
+// DeleteSecurityGroup removes the security group from the fake security groups
 func (ec2i *FakeEC2Impl) DeleteSecurityGroup(ctx context.Context, request *ec2.DeleteSecurityGroupInput, optFns ...func(*ec2.Options)) (*ec2.DeleteSecurityGroupOutput, error) {
-	panic("Not implemented")
+	if err := ec2i.injectedError("DeleteSecurityGroup"); err != nil {
+		return nil, err
+	}
+	for i, sg := range ec2i.SecurityGroups {
+		if aws.StringValue(sg.GroupId) == aws.StringValue(request.GroupId) {
+			ec2i.SecurityGroups = append(ec2i.SecurityGroups[:i], ec2i.SecurityGroups[i+1:]...)
+			return &ec2.DeleteSecurityGroupOutput{}, nil
+		}
+	}
+	return nil, fmt.Errorf("InvalidGroup.NotFound: the security group %q does not exist", aws.StringValue(request.GroupId))
 }
 
-// AuthorizeSecurityGroupIngress is not implemented but is required for
-// interface conformance
+// AuthorizeSecurityGroupIngress adds the permissions to the fake security group
 func (ec2i *FakeEC2Impl) AuthorizeSecurityGroupIngress(ctx context.Context, request *ec2.AuthorizeSecurityGroupIngressInput, optFns ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
-	panic("Not implemented")
+	if err := ec2i.injectedError("AuthorizeSecurityGroupIngress"); err != nil {
+		return nil, err
+	}
+	for i := range ec2i.SecurityGroups {
+		sg := &ec2i.SecurityGroups[i]
+		if aws.StringValue(sg.GroupId) == aws.StringValue(request.GroupId) {
+			sg.IpPermissions = append(sg.IpPermissions, request.IpPermissions...)
+			return &ec2.AuthorizeSecurityGroupIngressOutput{}, nil
+		}
+	}
+	return nil, fmt.Errorf("InvalidGroup.NotFound: the security group %q does not exist", aws.StringValue(request.GroupId))
 }
 
-// RevokeSecurityGroupIngress is not implemented but is required for interface
-// conformance
+// RevokeSecurityGroupIngress removes the permissions from the fake security group
 func (ec2i *FakeEC2Impl) RevokeSecurityGroupIngress(ctx context.Context, request *ec2.RevokeSecurityGroupIngressInput, optFns ...func(*ec2.Options)) (*ec2.RevokeSecurityGroupIngressOutput, error) {
-	panic("Not implemented")
+	if err := ec2i.injectedError("RevokeSecurityGroupIngress"); err != nil {
+		return nil, err
+	}
+	for i := range ec2i.SecurityGroups {
+		sg := &ec2i.SecurityGroups[i]
+		if aws.StringValue(sg.GroupId) != aws.StringValue(request.GroupId) {
+			continue
+		}
+		remaining := []ec2types.IpPermission{}
+		for _, permission := range sg.IpPermissions {
+			if !containsIPPermission(request.IpPermissions, permission) {
+				remaining = append(remaining, permission)
+			}
+		}
+		sg.IpPermissions = remaining
+		return &ec2.RevokeSecurityGroupIngressOutput{}, nil
+	}
+	return nil, fmt.Errorf("InvalidGroup.NotFound: the security group %q does not exist", aws.StringValue(request.GroupId))
 }
 
-// DescribeVolumeModifications is not implemented but is required for interface
-// conformance
-func (ec2i *FakeEC2Impl) DescribeVolumeModifications(*ec2.DescribeVolumesModificationsInput) ([]*ec2types.VolumeModification, error) {
-	panic("Not implemented")
+// DescribeVolumesModifications returns the fake volume modifications of the requested volumes. Every call advances
+// the modifications that are in progress to their next state, from modifying to optimizing to completed. Like EBS,
+// modifications to more than 16 TiB fail.
+func (ec2i *FakeEC2Impl) DescribeVolumesModifications(ctx context.Context, request *ec2.DescribeVolumesModificationsInput, optFns ...func(*ec2.Options)) ([]ec2types.VolumeModification, error) {
+	if err := ec2i.injectedError("DescribeVolumesModifications"); err != nil {
+		return nil, err
+	}
+	matches := []ec2types.VolumeModification{}
+	for _, volumeID := range request.VolumeIds {
+		modification, ok := ec2i.VolumeModifications[volumeID]
+		if !ok {
+			continue
+		}
+		matches = append(matches, *modification)
+		switch modification.ModificationState {
+		case ec2types.VolumeModificationStateModifying:
+			if aws.Int32Value(modification.TargetSize) > 16384 {
+				modification.ModificationState = ec2types.VolumeModificationStateFailed
+				modification.StatusMessage = aws.String("the volume size exceeds the maximum of 16384 GiB")
+				break
+			}
+			modification.ModificationState = ec2types.VolumeModificationStateOptimizing
+		case ec2types.VolumeModificationStateOptimizing:
+			modification.ModificationState = ec2types.VolumeModificationStateCompleted
+		}
+	}
+	return matches, nil
 }
 
-// ModifyVolume is not implemented but is required for interface conformance
-func (ec2i *FakeEC2Impl) ModifyVolume(*ec2.ModifyVolumeInput) (*ec2.ModifyVolumeOutput, error) {
-	panic("Not implemented")
+// ModifyVolume starts a fake volume modification. Like EC2, it fails while a modification of the volume is in
+// progress.
+func (ec2i *FakeEC2Impl) ModifyVolume(ctx context.Context, request *ec2.ModifyVolumeInput, optFns ...func(*ec2.Options)) (*ec2.ModifyVolumeOutput, error) {
+	if err := ec2i.injectedError("ModifyVolume"); err != nil {
+		return nil, err
+	}
+	volumeID := aws.StringValue(request.VolumeId)
+	if modification, ok := ec2i.VolumeModifications[volumeID]; ok &&
+		(modification.ModificationState == ec2types.VolumeModificationStateModifying || modification.ModificationState == ec2types.VolumeModificationStateOptimizing) {
+		return nil, &smithy.GenericAPIError{
+			Code:    "IncorrectModificationState",
+			Message: fmt.Sprintf("volume %s is already being modified", volumeID),
+		}
+	}
+	if ec2i.VolumeModifications == nil {
+		ec2i.VolumeModifications = map[string]*ec2types.VolumeModification{}
+	}
+	modification := &ec2types.VolumeModification{
+		VolumeId:          request.VolumeId,
+		TargetSize:        request.Size,
+		ModificationState: ec2types.VolumeModificationStateModifying,
+	}
+	ec2i.VolumeModifications[volumeID] = modification
+	output := *modification
+	return &ec2.ModifyVolumeOutput{VolumeModification: &output}, nil
 }
 
 // CreateSubnet creates fake subnets
@@ -317,8 +627,27 @@ func (ec2i *FakeEC2Impl) CreateSubnet(request *ec2types.Subnet) (*ec2.CreateSubn
 
 // DescribeSubnets returns fake subnet descriptions
 func (ec2i *FakeEC2Impl) DescribeSubnets(ctx context.Context, request *ec2.DescribeSubnetsInput, optFns ...func(*ec2.Options)) ([]ec2types.Subnet, error) {
+	if err := ec2i.injectedError("DescribeSubnets"); err != nil {
+		return nil, err
+	}
 	ec2i.DescribeSubnetsInput = request
-	return ec2i.Subnets, nil
+	// Lookups by subnet ID only return the requested subnets
+	subnetIDs := request.SubnetIds
+	for _, filter := range request.Filters {
+		if aws.StringValue(filter.Name) == "subnet-id" {
+			subnetIDs = append(subnetIDs, filter.Values...)
+		}
+	}
+	if len(subnetIDs) == 0 {
+		return ec2i.Subnets, nil
+	}
+	var subnets []ec2types.Subnet
+	for _, subnet := range ec2i.Subnets {
+		if slices.Contains(subnetIDs, aws.StringValue(subnet.SubnetId)) {
+			subnets = append(subnets, subnet)
+		}
+	}
+	return subnets, nil
 }
 
 // RemoveSubnets clears subnets on client
@@ -326,29 +655,50 @@ func (ec2i *FakeEC2Impl) RemoveSubnets() {
 	ec2i.Subnets = ec2i.Subnets[:0]
 }
 
+// DescribeAddresses returns the fake addresses with the requested allocation IDs
+func (ec2i *FakeEC2Impl) DescribeAddresses(ctx context.Context, request *ec2.DescribeAddressesInput, optFns ...func(*ec2.Options)) ([]ec2types.Address, error) {
+	if err := ec2i.injectedError("DescribeAddresses"); err != nil {
+		return nil, err
+	}
+	var addresses []ec2types.Address
+	for _, address := range ec2i.Addresses {
+		if len(request.AllocationIds) == 0 || slices.Contains(request.AllocationIds, aws.StringValue(address.AllocationId)) {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses, nil
+}
+
 // DescribeAvailabilityZones returns fake availability zones
 // For every input returns a hardcoded list of fake availability zones for the moment
 func (ec2i *FakeEC2Impl) DescribeAvailabilityZones(ctx context.Context, request *ec2.DescribeAvailabilityZonesInput, optFns ...func(*ec2.Options)) ([]ec2types.AvailabilityZone, error) {
+	if err := ec2i.injectedError("DescribeAvailabilityZones"); err != nil {
+		return nil, err
+	}
 	return []ec2types.AvailabilityZone{
 		{
-			ZoneName: aws.String("us-west-2a"),
-			ZoneType: aws.String("availability-zone"),
-			ZoneId:   aws.String("az1"),
+			ZoneName:           aws.String("us-west-2a"),
+			ZoneType:           aws.String("availability-zone"),
+			NetworkBorderGroup: aws.String("us-west-2"),
+			ZoneId:             aws.String("az1"),
 		},
 		{
-			ZoneName: aws.String("us-west-2b"),
-			ZoneType: aws.String("availability-zone"),
-			ZoneId:   aws.String("az2"),
+			ZoneName:           aws.String("us-west-2b"),
+			ZoneType:           aws.String("availability-zone"),
+			NetworkBorderGroup: aws.String("us-west-2"),
+			ZoneId:             aws.String("az2"),
 		},
 		{
-			ZoneName: aws.String("us-west-2c"),
-			ZoneType: aws.String("availability-zone"),
-			ZoneId:   aws.String("az3"),
+			ZoneName:           aws.String("us-west-2c"),
+			ZoneType:           aws.String("availability-zone"),
+			NetworkBorderGroup: aws.String("us-west-2"),
+			ZoneId:             aws.String("az3"),
 		},
 		{
-			ZoneName: aws.String("az-local"),
-			ZoneType: aws.String("local-zone"),
-			ZoneId:   aws.String("lz1"),
+			ZoneName:           aws.String("az-local"),
+			ZoneType:           aws.String("local-zone"),
+			NetworkBorderGroup: aws.String("us-west-2-lax-1"),
+			ZoneId:             aws.String("lz1"),
 		},
 		{
 			ZoneName: aws.String("az-wavelength"),
@@ -360,6 +710,9 @@ func (ec2i *FakeEC2Impl) DescribeAvailabilityZones(ctx context.Context, request
 
 // CreateTags is a mock for CreateTags from EC2
 func (ec2i *FakeEC2Impl) CreateTags(ctx context.Context, input *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+	if err := ec2i.injectedError("CreateTags"); err != nil {
+		return nil, err
+	}
 	for _, id := range input.Resources {
 		callCount := ec2i.aws.countCall("ec2", "CreateTags", id)
 		if id == "i-error" {
@@ -380,12 +733,21 @@ func (ec2i *FakeEC2Impl) CreateTags(ctx context.Context, input *ec2.CreateTagsIn
 				return nil, awserr.New("InvalidInstanceID.NotFound", "Instance not found", nil)
 			}
 		}
+
+		for i := range ec2i.SecurityGroups {
+			if aws.StringValue(ec2i.SecurityGroups[i].GroupId) == id {
+				ec2i.SecurityGroups[i].Tags = append(ec2i.SecurityGroups[i].Tags, input.Tags...)
+			}
+		}
 	}
 	return &ec2.CreateTagsOutput{}, nil
 }
 
 // DeleteTags is a mock for DeleteTags from EC2
 func (ec2i *FakeEC2Impl) DeleteTags(ctx context.Context, input *ec2.DeleteTagsInput, optFns ...func(*ec2.Options)) (*ec2.DeleteTagsOutput, error) {
+	if err := ec2i.injectedError("DeleteTags"); err != nil {
+		return nil, err
+	}
 	for _, id := range input.Resources {
 		if id == "i-error" {
 			return nil, errors.New("Unable to remove tag")
@@ -400,6 +762,9 @@ func (ec2i *FakeEC2Impl) DeleteTags(ctx context.Context, input *ec2.DeleteTagsIn
 
 // DescribeRouteTables returns fake route table descriptions
 func (ec2i *FakeEC2Impl) DescribeRouteTables(ctx context.Context, request *ec2.DescribeRouteTablesInput, optFns ...func(*ec2.Options)) ([]ec2types.RouteTable, error) {
+	if err := ec2i.injectedError("DescribeRouteTables"); err != nil {
+		return nil, err
+	}
 	ec2i.DescribeRouteTablesInput = request
 	return ec2i.RouteTables, nil
 }
@@ -418,34 +783,106 @@ func (ec2i *FakeEC2Impl) RemoveRouteTables() {
 	ec2i.RouteTables = ec2i.RouteTables[:0]
 }
 
-// CreateRoute is not implemented but is required for interface conformance
+// CreateRoute adds a route to the fake route table
 func (ec2i *FakeEC2Impl) CreateRoute(ctx context.Context, request *ec2.CreateRouteInput, optFns ...func(*ec2.Options)) (*ec2.CreateRouteOutput, error) {
-	panic("Not implemented")
+	if err := ec2i.injectedError("CreateRoute"); err != nil {
+		return nil, err
+	}
+	for i := range ec2i.RouteTables {
+		table := &ec2i.RouteTables[i]
+		if aws.StringValue(table.RouteTableId) != aws.StringValue(request.RouteTableId) {
+			continue
+		}
+		table.Routes = append(table.Routes, ec2types.Route{
+			DestinationCidrBlock:     request.DestinationCidrBlock,
+			DestinationIpv6CidrBlock: request.DestinationIpv6CidrBlock,
+			InstanceId:               request.InstanceId,
+			State:                    ec2types.RouteStateActive,
+		})
+		return &ec2.CreateRouteOutput{Return: aws.Bool(true)}, nil
+	}
+	return nil, fmt.Errorf("InvalidRouteTableID.NotFound: the route table %q does not exist", aws.StringValue(request.RouteTableId))
 }
 
-// DeleteRoute is not implemented but is required for interface conformance
+// DeleteRoute removes a route from the fake route table
 func (ec2i *FakeEC2Impl) DeleteRoute(ctx context.Context, request *ec2.DeleteRouteInput, optFns ...func(*ec2.Options)) (*ec2.DeleteRouteOutput, error) {
-	panic("Not implemented")
+	if err := ec2i.injectedError("DeleteRoute"); err != nil {
+		return nil, err
+	}
+	for i := range ec2i.RouteTables {
+		table := &ec2i.RouteTables[i]
+		if aws.StringValue(table.RouteTableId) != aws.StringValue(request.RouteTableId) {
+			continue
+		}
+		for j, route := range table.Routes {
+			if aws.StringValue(route.DestinationCidrBlock) == aws.StringValue(request.DestinationCidrBlock) &&
+				aws.StringValue(route.DestinationIpv6CidrBlock) == aws.StringValue(request.DestinationIpv6CidrBlock) {
+				table.Routes = append(table.Routes[:j], table.Routes[j+1:]...)
+				return &ec2.DeleteRouteOutput{}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("InvalidRoute.NotFound: no route found in route table %q", aws.StringValue(request.RouteTableId))
 }
 
-// ModifyInstanceAttribute is not implemented but is required for interface
-// conformance
+// ModifyInstanceAttribute records the request and sets the source-dest-check of the fake instance
 func (ec2i *FakeEC2Impl) ModifyInstanceAttribute(ctx context.Context, request *ec2.ModifyInstanceAttributeInput, optFns ...func(*ec2.Options)) (*ec2.ModifyInstanceAttributeOutput, error) {
-	panic("Not implemented")
+	if err := ec2i.injectedError("ModifyInstanceAttribute"); err != nil {
+		return nil, err
+	}
+	ec2i.ModifyInstanceAttributeInputs = append(ec2i.ModifyInstanceAttributeInputs, request)
+	for _, instance := range ec2i.aws.instances {
+		if aws.StringValue(instance.InstanceId) == aws.StringValue(request.InstanceId) && request.SourceDestCheck != nil {
+			instance.SourceDestCheck = request.SourceDestCheck.Value
+		}
+	}
+	return &ec2.ModifyInstanceAttributeOutput{}, nil
 }
 
-// DescribeVpcs returns fake VPC descriptions
+// ModifyNetworkInterfaceAttribute replaces the security groups of a fake network interface
+func (ec2i *FakeEC2Impl) ModifyNetworkInterfaceAttribute(ctx context.Context, request *ec2.ModifyNetworkInterfaceAttributeInput, optFns ...func(*ec2.Options)) (*ec2.ModifyNetworkInterfaceAttributeOutput, error) {
+	if err := ec2i.injectedError("ModifyNetworkInterfaceAttribute"); err != nil {
+		return nil, err
+	}
+	for i := range ec2i.NetworkInterfaces {
+		networkInterface := &ec2i.NetworkInterfaces[i]
+		if aws.StringValue(networkInterface.NetworkInterfaceId) != aws.StringValue(request.NetworkInterfaceId) {
+			continue
+		}
+		if request.Groups != nil {
+			networkInterface.Groups = nil
+			for _, id := range request.Groups {
+				networkInterface.Groups = append(networkInterface.Groups, ec2types.GroupIdentifier{GroupId: aws.String(id)})
+			}
+		}
+		return &ec2.ModifyNetworkInterfaceAttributeOutput{}, nil
+	}
+	return nil, fmt.Errorf("InvalidNetworkInterfaceID.NotFound: the network interface %q does not exist", aws.StringValue(request.NetworkInterfaceId))
+}
+
+// DescribeVpcs returns the fake VPCs, or a single VPC with the CIDR 172.20.0.0/16 when none are set
 func (ec2i *FakeEC2Impl) DescribeVpcs(ctx context.Context, request *ec2.DescribeVpcsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error) {
+	if err := ec2i.injectedError("DescribeVpcs"); err != nil {
+		return nil, err
+	}
+	if len(ec2i.Vpcs) > 0 {
+		return &ec2.DescribeVpcsOutput{Vpcs: ec2i.Vpcs}, nil
+	}
 	return &ec2.DescribeVpcsOutput{Vpcs: []ec2types.Vpc{{CidrBlock: aws.String("172.20.0.0/16")}}}, nil
 }
 
 // FakeMetadata is a fake EC2 metadata service client used for testing
 type FakeMetadata struct {
 	aws *FakeAWSServices
+	// err is returned by every call when set, as when the metadata service is unreachable
+	err error
 }
 
 // GetMetadata returns fake EC2 metadata for testing
 func (m *FakeMetadata) GetMetadata(key string) (string, error) {
+	if m.err != nil {
+		return "", m.err
+	}
 	networkInterfacesPrefix := "network/interfaces/macs/"
 	i := m.aws.selfInstance
 	if key == "placement/availability-zone" {
@@ -512,18 +949,29 @@ func (m *FakeMetadata) GetMetadata(key string) (string, error) {
 
 // Region returns AWS region
 func (m *FakeMetadata) Region() (string, error) {
+	if m.err != nil {
+		return "", m.err
+	}
 	return m.aws.region, nil
 }
 
 // FakeELB is a fake ELB client used for testing
 type FakeELB struct {
 	aws *FakeAWSServices
+
+	fakeAPIErrors
+
+	// keys are load balancer names
+	LoadBalancerAttributes             map[string]*elb.LoadBalancerAttributes
+	ModifyLoadBalancerAttributesInputs []*elb.ModifyLoadBalancerAttributesInput
 }
 
-// CreateLoadBalancer is not implemented but is required for interface
-// conformance
+// CreateLoadBalancer returns an empty output, or the injected error
 func (e *FakeELB) CreateLoadBalancer(*elb.CreateLoadBalancerInput) (*elb.CreateLoadBalancerOutput, error) {
-	panic("Not implemented")
+	if err := e.injectedError("CreateLoadBalancer"); err != nil {
+		return nil, err
+	}
+	return &elb.CreateLoadBalancerOutput{}, nil
 }
 
 // DeleteLoadBalancer is not implemented but is required for interface
@@ -543,6 +991,16 @@ func (e *FakeELB) AddTags(input *elb.AddTagsInput) (*elb.AddTagsOutput, error) {
 	panic("Not implemented")
 }
 
+// RemoveTags is not implemented but is required for interface conformance
+func (e *FakeELB) RemoveTags(input *elb.RemoveTagsInput) (*elb.RemoveTagsOutput, error) {
+	panic("Not implemented")
+}
+
+// DescribeTags is not implemented but is required for interface conformance
+func (e *FakeELB) DescribeTags(input *elb.DescribeTagsInput) (*elb.DescribeTagsOutput, error) {
+	panic("Not implemented")
+}
+
 // RegisterInstancesWithLoadBalancer is not implemented but is required for
 // interface conformance
 func (e *FakeELB) RegisterInstancesWithLoadBalancer(*elb.RegisterInstancesWithLoadBalancerInput) (*elb.RegisterInstancesWithLoadBalancerOutput, error) {
@@ -561,10 +1019,12 @@ func (e *FakeELB) DetachLoadBalancerFromSubnets(*elb.DetachLoadBalancerFromSubne
 	panic("Not implemented")
 }
 
-// AttachLoadBalancerToSubnets is not implemented but is required for interface
-// conformance
+// AttachLoadBalancerToSubnets returns an empty output, or the injected error
 func (e *FakeELB) AttachLoadBalancerToSubnets(*elb.AttachLoadBalancerToSubnetsInput) (*elb.AttachLoadBalancerToSubnetsOutput, error) {
-	panic("Not implemented")
+	if err := e.injectedError("AttachLoadBalancerToSubnets"); err != nil {
+		return nil, err
+	}
+	return &elb.AttachLoadBalancerToSubnetsOutput{}, nil
 }
 
 // CreateLoadBalancerListeners is not implemented but is required for interface
@@ -579,12 +1039,20 @@ func (e *FakeELB) DeleteLoadBalancerListeners(*elb.DeleteLoadBalancerListenersIn
 	panic("Not implemented")
 }
 
-// ApplySecurityGroupsToLoadBalancer is not implemented but is required for
+// SetLoadBalancerListenerSSLCertificate is not implemented but is required for
 // interface conformance
-func (e *FakeELB) ApplySecurityGroupsToLoadBalancer(*elb.ApplySecurityGroupsToLoadBalancerInput) (*elb.ApplySecurityGroupsToLoadBalancerOutput, error) {
+func (e *FakeELB) SetLoadBalancerListenerSSLCertificate(*elb.SetLoadBalancerListenerSSLCertificateInput) (*elb.SetLoadBalancerListenerSSLCertificateOutput, error) {
 	panic("Not implemented")
 }
 
+// ApplySecurityGroupsToLoadBalancer returns an empty output, or the injected error
+func (e *FakeELB) ApplySecurityGroupsToLoadBalancer(*elb.ApplySecurityGroupsToLoadBalancerInput) (*elb.ApplySecurityGroupsToLoadBalancerOutput, error) {
+	if err := e.injectedError("ApplySecurityGroupsToLoadBalancer"); err != nil {
+		return nil, err
+	}
+	return &elb.ApplySecurityGroupsToLoadBalancerOutput{}, nil
+}
+
 // ConfigureHealthCheck is not implemented but is required for interface
 // conformance
 func (e *FakeELB) ConfigureHealthCheck(*elb.ConfigureHealthCheckInput) (*elb.ConfigureHealthCheckOutput, error) {
@@ -615,16 +1083,28 @@ func (e *FakeELB) DescribeLoadBalancerPolicies(input *elb.DescribeLoadBalancerPo
 	panic("Not implemented")
 }
 
-// DescribeLoadBalancerAttributes is not implemented but is required for
-// interface conformance
-func (e *FakeELB) DescribeLoadBalancerAttributes(*elb.DescribeLoadBalancerAttributesInput) (*elb.DescribeLoadBalancerAttributesOutput, error) {
-	panic("Not implemented")
+// DescribeLoadBalancerAttributes returns the attributes last set by ModifyLoadBalancerAttributes, or the injected error
+func (e *FakeELB) DescribeLoadBalancerAttributes(input *elb.DescribeLoadBalancerAttributesInput) (*elb.DescribeLoadBalancerAttributesOutput, error) {
+	if err := e.injectedError("DescribeLoadBalancerAttributes"); err != nil {
+		return nil, err
+	}
+	if attributes, ok := e.LoadBalancerAttributes[aws.StringValue(input.LoadBalancerName)]; ok {
+		return &elb.DescribeLoadBalancerAttributesOutput{LoadBalancerAttributes: attributes}, nil
+	}
+	return &elb.DescribeLoadBalancerAttributesOutput{LoadBalancerAttributes: &elb.LoadBalancerAttributes{}}, nil
 }
 
-// ModifyLoadBalancerAttributes is not implemented but is required for
-// interface conformance
-func (e *FakeELB) ModifyLoadBalancerAttributes(*elb.ModifyLoadBalancerAttributesInput) (*elb.ModifyLoadBalancerAttributesOutput, error) {
-	panic("Not implemented")
+// ModifyLoadBalancerAttributes records the input and stores the attributes, or returns the injected error
+func (e *FakeELB) ModifyLoadBalancerAttributes(input *elb.ModifyLoadBalancerAttributesInput) (*elb.ModifyLoadBalancerAttributesOutput, error) {
+	if err := e.injectedError("ModifyLoadBalancerAttributes"); err != nil {
+		return nil, err
+	}
+	e.ModifyLoadBalancerAttributesInputs = append(e.ModifyLoadBalancerAttributesInputs, input)
+	if e.LoadBalancerAttributes == nil {
+		e.LoadBalancerAttributes = map[string]*elb.LoadBalancerAttributes{}
+	}
+	e.LoadBalancerAttributes[aws.StringValue(input.LoadBalancerName)] = input.LoadBalancerAttributes
+	return &elb.ModifyLoadBalancerAttributesOutput{}, nil
 }
 
 // FakeELBV2 is a fake ELBV2 client used for testing
@@ -637,6 +1117,11 @@ func (elb *FakeELBV2) AddTags(input *elbv2.AddTagsInput) (*elbv2.AddTagsOutput,
 	panic("Not implemented")
 }
 
+// DescribeTags is not implemented but is required for interface conformance
+func (elb *FakeELBV2) DescribeTags(input *elbv2.DescribeTagsInput) (*elbv2.DescribeTagsOutput, error) {
+	panic("Not implemented")
+}
+
 // CreateLoadBalancer is not implemented but is required for interface
 // conformance
 func (elb *FakeELBV2) CreateLoadBalancer(*elbv2.CreateLoadBalancerInput) (*elbv2.CreateLoadBalancerOutput, error) {
@@ -667,6 +1152,12 @@ func (elb *FakeELBV2) DescribeLoadBalancerAttributes(*elbv2.DescribeLoadBalancer
 	panic("Not implemented")
 }
 
+// SetSecurityGroups is not implemented but is required for interface
+// conformance
+func (elb *FakeELBV2) SetSecurityGroups(*elbv2.SetSecurityGroupsInput) (*elbv2.SetSecurityGroupsOutput, error) {
+	panic("Not implemented")
+}
+
 // CreateTargetGroup is not implemented but is required for interface
 // conformance
 func (elb *FakeELBV2) CreateTargetGroup(*elbv2.CreateTargetGroupInput) (*elbv2.CreateTargetGroupOutput, error) {
@@ -749,7 +1240,8 @@ func (elb *FakeELBV2) WaitUntilLoadBalancersDeleted(*elbv2.DescribeLoadBalancers
 
 // FakeASG is a fake Autoscaling client used for testing
 type FakeASG struct {
-	aws *FakeAWSServices
+	aws               *FakeAWSServices
+	AutoScalingGroups []*autoscaling.Group
 }
 
 // UpdateAutoScalingGroup is not implemented but is required for interface
@@ -758,10 +1250,17 @@ func (a *FakeASG) UpdateAutoScalingGroup(*autoscaling.UpdateAutoScalingGroupInpu
 	panic("Not implemented")
 }
 
-// DescribeAutoScalingGroups is not implemented but is required for interface
-// conformance
-func (a *FakeASG) DescribeAutoScalingGroups(*autoscaling.DescribeAutoScalingGroupsInput) (*autoscaling.DescribeAutoScalingGroupsOutput, error) {
-	panic("Not implemented")
+// DescribeAutoScalingGroups returns the fake auto scaling groups with the requested names
+func (a *FakeASG) DescribeAutoScalingGroups(request *autoscaling.DescribeAutoScalingGroupsInput) (*autoscaling.DescribeAutoScalingGroupsOutput, error) {
+	names := aws.StringValueSlice(request.AutoScalingGroupNames)
+	a.aws.countCall("autoscaling", "DescribeAutoScalingGroups", "")
+	var groups []*autoscaling.Group
+	for _, group := range a.AutoScalingGroups {
+		if len(names) == 0 || slices.Contains(names, aws.StringValue(group.AutoScalingGroupName)) {
+			groups = append(groups, group)
+		}
+	}
+	return &autoscaling.DescribeAutoScalingGroupsOutput{AutoScalingGroups: groups}, nil
 }
 
 // FakeKMS is a fake KMS client used for testing
@@ -774,6 +1273,56 @@ func (kms *FakeKMS) DescribeKey(*kms.DescribeKeyInput) (*kms.DescribeKeyOutput,
 	panic("Not implemented")
 }
 
+// FakeACM is a fake Certificate Manager client used for testing
+type FakeACM struct {
+	aws          *FakeAWSServices
+	Certificates []*acm.CertificateSummary
+}
+
+// ListCertificates returns the fake certificates with the requested statuses
+func (a *FakeACM) ListCertificates(request *acm.ListCertificatesInput) (*acm.ListCertificatesOutput, error) {
+	statuses := aws.StringValueSlice(request.CertificateStatuses)
+	a.aws.countCall("acm", "ListCertificates", "")
+	var certificates []*acm.CertificateSummary
+	for _, certificate := range a.Certificates {
+		if len(statuses) == 0 || slices.Contains(statuses, aws.StringValue(certificate.Status)) {
+			certificates = append(certificates, certificate)
+		}
+	}
+	return &acm.ListCertificatesOutput{CertificateSummaryList: certificates}, nil
+}
+
+// FakeSQS is a fake Simple Queue Service client used for testing
+type FakeSQS struct {
+	aws *FakeAWSServices
+
+	mu sync.Mutex
+	// Messages are the messages waiting in the queue
+	Messages []*sqs.Message
+	// DeletedReceiptHandles are the receipt handles of the deleted messages
+	DeletedReceiptHandles []string
+}
+
+// ReceiveMessageWithContext returns the waiting messages, it doesn't wait for messages when the queue is empty
+func (q *FakeSQS) ReceiveMessageWithContext(ctx aws.Context, input *sqs.ReceiveMessageInput, opts ...request.Option) (*sqs.ReceiveMessageOutput, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.aws.countCall("sqs", "ReceiveMessage", aws.StringValue(input.QueueUrl))
+	n := min(len(q.Messages), int(aws.Int64Value(input.MaxNumberOfMessages)))
+	messages := q.Messages[:n]
+	q.Messages = q.Messages[n:]
+	return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+}
+
+// DeleteMessageWithContext records the deleted receipt handle
+func (q *FakeSQS) DeleteMessageWithContext(ctx aws.Context, input *sqs.DeleteMessageInput, opts ...request.Option) (*sqs.DeleteMessageOutput, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.aws.countCall("sqs", "DeleteMessage", aws.StringValue(input.QueueUrl))
+	q.DeletedReceiptHandles = append(q.DeletedReceiptHandles, aws.StringValue(input.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
 func instanceMatchesFilter(instance *ec2types.Instance, filter ec2types.Filter) bool {
 	name := *filter.Name
 	if name == "private-dns-name" {
@@ -821,6 +1370,26 @@ func contains(haystack []string, needle string) bool {
 
 // DescribeNetworkInterfaces returns list of ENIs for testing
 func (ec2i *FakeEC2Impl) DescribeNetworkInterfaces(ctx context.Context, input *ec2.DescribeNetworkInterfacesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeNetworkInterfacesOutput, error) {
+	if err := ec2i.injectedError("DescribeNetworkInterfaces"); err != nil {
+		return nil, err
+	}
+	// Lookups by security group are served from the fake network interfaces
+	for _, filter := range input.Filters {
+		if aws.StringValue(filter.Name) != "group-id" {
+			continue
+		}
+		var matches []ec2types.NetworkInterface
+		for _, networkInterface := range ec2i.NetworkInterfaces {
+			for _, group := range networkInterface.Groups {
+				if aws.StringValue(group.GroupId) == filter.Values[0] {
+					matches = append(matches, networkInterface)
+					break
+				}
+			}
+		}
+		return &ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: matches}, nil
+	}
+
 	fargateNodeNamePrefix := "fargate-"
 	networkInterface := []ec2types.NetworkInterface{
 		{
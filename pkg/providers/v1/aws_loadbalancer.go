@@ -20,13 +20,17 @@ import (
 	"context"
 	"crypto/sha1"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"maps"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -37,6 +41,10 @@ import (
 
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	netutils "k8s.io/utils/net"
+
+	"k8s.io/cloud-provider-aws/pkg/providers/v1/variant"
 )
 
 const (
@@ -52,6 +60,38 @@ const (
 	lbAttrAccessLogsS3Enabled           = "access_logs.s3.enabled"
 	lbAttrAccessLogsS3Bucket            = "access_logs.s3.bucket"
 	lbAttrAccessLogsS3Prefix            = "access_logs.s3.prefix"
+	lbAttrDeletionProtectionEnabled     = "deletion_protection.enabled"
+
+	tgAttrProxyProtocolV2Enabled            = "proxy_protocol_v2.enabled"
+	tgAttrDeregistrationDelayTimeoutSeconds = "deregistration_delay.timeout_seconds"
+	tgAttrStickinessEnabled                 = "stickiness.enabled"
+	tgAttrStickinessType                    = "stickiness.type"
+
+	// tgStickinessTypeSourceIP is the only stickiness type of NLB target groups, the cookie based types are for ALBs
+	tgStickinessTypeSourceIP = "source_ip"
+
+	// elbTagValueMaxLength is the maximum length of a tag value allowed by AWS
+	elbTagValueMaxLength = 256
+
+	// Classic ELB connection draining timeouts allowed by AWS, in seconds
+	minConnectionDrainingTimeout = 1
+	maxConnectionDrainingTimeout = 3600
+
+	// NLB target group deregistration delays allowed by AWS, in seconds
+	minDeregistrationDelay = 0
+	maxDeregistrationDelay = 3600
+
+	// Connection idle timeouts allowed by AWS, in seconds
+	minConnectionIdleTimeout = 1
+	maxConnectionIdleTimeout = 4000
+
+	// Classic ELB health check thresholds, and intervals and timeouts in seconds, allowed by AWS
+	minElbHCThreshold = 2
+	maxElbHCThreshold = 10
+	minElbHCInterval  = 5
+	maxElbHCInterval  = 300
+	minElbHCTimeout   = 2
+	maxElbHCTimeout   = 60
 
 	// defaultEC2InstanceCacheMaxAge is the max age for the EC2 instance cache
 	defaultEC2InstanceCacheMaxAge = 10 * time.Minute
@@ -111,6 +151,271 @@ type nlbPortMapping struct {
 	SSLCertificateARN string
 	SSLPolicy         string
 	HealthCheckConfig healthCheckConfig
+	ProxyProtocol     bool
+	// DeregistrationDelay is the connection draining timeout of the target group, nil leaves it unmanaged
+	DeregistrationDelay *int64
+	// Stickiness enables source IP stickiness on the target group, nil leaves it unmanaged
+	Stickiness *bool
+
+	// TargetType is the target type of the target group, either instance or ip
+	TargetType string
+	// IPTargets are the pod IPs registered with target groups of target type ip
+	IPTargets []*elbv2.TargetDescription
+}
+
+// targetType returns the target type of the target group, defaulting to instance
+func (m nlbPortMapping) targetType() string {
+	if m.TargetType == "" {
+		return elbv2.TargetTypeEnumInstance
+	}
+	return m.TargetType
+}
+
+// loadBalancerNameRegexp matches the names AWS allows for load balancers, alphanumeric characters and hyphens that
+// don't begin or end with a hyphen
+var loadBalancerNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?$`)
+
+// maxLoadBalancerNameLength is the longest name AWS allows for load balancers
+const maxLoadBalancerNameLength = 32
+
+// validateLoadBalancerName returns an error when AWS would reject the name of a load balancer
+func validateLoadBalancerName(name string) error {
+	if len(name) > maxLoadBalancerNameLength {
+		return fmt.Errorf("load balancer name %q is longer than %d characters", name, maxLoadBalancerNameLength)
+	}
+	if !loadBalancerNameRegexp.MatchString(name) {
+		return fmt.Errorf("load balancer name %q must only contain alphanumeric characters or hyphens, and must not begin or end with a hyphen", name)
+	}
+	if strings.HasPrefix(name, "internal-") {
+		return fmt.Errorf("load balancer name %q must not begin with internal-", name)
+	}
+	return nil
+}
+
+// validateLoadBalancerNameAnnotation returns an error, and records it as an event on the service, when the name
+// annotation of the service is set to a name AWS would reject
+func (c *Cloud) validateLoadBalancerNameAnnotation(service *v1.Service) error {
+	name, ok := service.Annotations[ServiceAnnotationLoadBalancerName]
+	if !ok {
+		return nil
+	}
+	if err := validateLoadBalancerName(name); err != nil {
+		c.recordServiceEvent(service, v1.EventTypeWarning, "InvalidLoadBalancerName", "Invalid %s: %v", ServiceAnnotationLoadBalancerName, err)
+		return err
+	}
+	return nil
+}
+
+// describeLoadBalancerTags returns the tags of the load balancer with the given name, or nil when it doesn't exist
+func (c *Cloud) describeLoadBalancerTags(loadBalancerName string, nlb bool) (map[string]string, error) {
+	tags := map[string]string{}
+	if nlb {
+		loadBalancer, err := c.describeLoadBalancerv2(loadBalancerName)
+		if err != nil || loadBalancer == nil {
+			return nil, err
+		}
+		response, err := c.elbv2.DescribeTags(&elbv2.DescribeTagsInput{ResourceArns: []*string{loadBalancer.LoadBalancerArn}})
+		if err != nil {
+			return nil, fmt.Errorf("error describing tags of load balancer %s: %q", loadBalancerName, err)
+		}
+		for _, description := range response.TagDescriptions {
+			for _, tag := range description.Tags {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+		}
+		return tags, nil
+	}
+
+	response, err := c.elb.DescribeTags(&elb.DescribeTagsInput{LoadBalancerNames: []*string{aws.String(loadBalancerName)}})
+	if err != nil {
+		if awsError, ok := err.(awserr.Error); ok && awsError.Code() == elb.ErrCodeAccessPointNotFoundException {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error describing tags of load balancer %s: %q", loadBalancerName, err)
+	}
+	for _, description := range response.TagDescriptions {
+		for _, tag := range description.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+	return tags, nil
+}
+
+// checkLoadBalancerNameOwnership returns an error, and records it as an event on the service, when the name
+// annotation of the service names an existing load balancer that isn't tagged as the load balancer of the service in
+// this cluster. Load balancers are identified by their service and cluster tags rather than the name, so a service
+// never takes over a load balancer it didn't create.
+func (c *Cloud) checkLoadBalancerNameOwnership(ctx context.Context, clusterName string, service *v1.Service) error {
+	if service.Annotations[ServiceAnnotationLoadBalancerName] == "" {
+		return nil
+	}
+	loadBalancerName := c.GetLoadBalancerName(ctx, clusterName, service)
+	tags, err := c.describeLoadBalancerTags(loadBalancerName, isNLB(service.Annotations))
+	if err != nil || tags == nil {
+		return err
+	}
+
+	serviceName := types.NamespacedName{Namespace: service.Namespace, Name: service.Name}.String()
+	_, clusterTagged := tags[c.tagging.clusterTagKey()]
+	if owner := tags[TagNameKubernetesService]; owner != serviceName || !clusterTagged {
+		err := fmt.Errorf("load balancer %s is not managed for service %s in this cluster", loadBalancerName, serviceName)
+		if owner != "" && owner != serviceName {
+			err = fmt.Errorf("load balancer %s is already managed for service %s", loadBalancerName, owner)
+		}
+		c.recordServiceEvent(service, v1.EventTypeWarning, "LoadBalancerNameConflict", "Invalid %s: %v", ServiceAnnotationLoadBalancerName, err)
+		return err
+	}
+	return nil
+}
+
+// maxDescribeTagsResources is the most load balancers DescribeTags accepts in a request
+const maxDescribeTagsResources = 20
+
+// isServiceLoadBalancerTags returns true if the tags are those of the load balancer of the service in this cluster
+func (c *Cloud) isServiceLoadBalancerTags(tags map[string]string, serviceName string) bool {
+	_, clusterTagged := tags[c.tagging.clusterTagKey()]
+	return clusterTagged && tags[TagNameKubernetesService] == serviceName
+}
+
+// findTaggedLoadBalancerName returns the name of the load balancer tagged as the load balancer of the service in this
+// cluster, or "" when there is none. It lists all the load balancers of the region, so it is only used when no load
+// balancer has the name of the service, to find the one created before its name annotation changed or was removed.
+func (c *Cloud) findTaggedLoadBalancerName(service *v1.Service) (string, error) {
+	serviceName := types.NamespacedName{Namespace: service.Namespace, Name: service.Name}.String()
+	if isNLB(service.Annotations) {
+		names := map[string]string{}
+		var arns []*string
+		request := &elbv2.DescribeLoadBalancersInput{}
+		for {
+			response, err := c.elbv2.DescribeLoadBalancers(request)
+			if err != nil {
+				return "", fmt.Errorf("error listing load balancers: %q", err)
+			}
+			for _, lb := range response.LoadBalancers {
+				if aws.StringValue(lb.Type) == elbv2.LoadBalancerTypeEnumNetwork {
+					names[aws.StringValue(lb.LoadBalancerArn)] = aws.StringValue(lb.LoadBalancerName)
+					arns = append(arns, lb.LoadBalancerArn)
+				}
+			}
+			if aws.StringValue(response.NextMarker) == "" {
+				break
+			}
+			request.Marker = response.NextMarker
+		}
+		for start := 0; start < len(arns); start += maxDescribeTagsResources {
+			response, err := c.elbv2.DescribeTags(&elbv2.DescribeTagsInput{ResourceArns: arns[start:min(start+maxDescribeTagsResources, len(arns))]})
+			if err != nil {
+				return "", fmt.Errorf("error describing tags of load balancers: %q", err)
+			}
+			for _, description := range response.TagDescriptions {
+				tags := map[string]string{}
+				for _, tag := range description.Tags {
+					tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+				}
+				if c.isServiceLoadBalancerTags(tags, serviceName) {
+					return names[aws.StringValue(description.ResourceArn)], nil
+				}
+			}
+		}
+		return "", nil
+	}
+
+	var names []*string
+	request := &elb.DescribeLoadBalancersInput{}
+	for {
+		response, err := c.elb.DescribeLoadBalancers(request)
+		if err != nil {
+			return "", fmt.Errorf("error listing load balancers: %q", err)
+		}
+		for _, lb := range response.LoadBalancerDescriptions {
+			names = append(names, lb.LoadBalancerName)
+		}
+		if aws.StringValue(response.NextMarker) == "" {
+			break
+		}
+		request.Marker = response.NextMarker
+	}
+	for start := 0; start < len(names); start += maxDescribeTagsResources {
+		response, err := c.elb.DescribeTags(&elb.DescribeTagsInput{LoadBalancerNames: names[start:min(start+maxDescribeTagsResources, len(names))]})
+		if err != nil {
+			return "", fmt.Errorf("error describing tags of load balancers: %q", err)
+		}
+		for _, description := range response.TagDescriptions {
+			tags := map[string]string{}
+			for _, tag := range description.Tags {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+			if c.isServiceLoadBalancerTags(tags, serviceName) {
+				return aws.StringValue(description.LoadBalancerName), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// loadBalancerExists returns true if a load balancer has the given name
+func (c *Cloud) loadBalancerExists(loadBalancerName string, nlb bool) (bool, error) {
+	if nlb {
+		lb, err := c.describeLoadBalancerv2(loadBalancerName)
+		return lb != nil, err
+	}
+	lb, err := c.describeLoadBalancer(loadBalancerName)
+	return lb != nil, err
+}
+
+// mayHaveRenamedLoadBalancer returns true when the load balancer of the service may exist under another name than
+// loadBalancerName: its name annotation is set and may have changed, or its status reports a load balancer whose
+// hostname isn't that of loadBalancerName, e.g. after the annotation was removed.
+func mayHaveRenamedLoadBalancer(service *v1.Service, loadBalancerName string) bool {
+	if _, ok := service.Annotations[ServiceAnnotationLoadBalancerName]; ok {
+		return true
+	}
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		hostname := strings.ToLower(strings.TrimPrefix(ingress.Hostname, "internal-"))
+		if !strings.HasPrefix(hostname, strings.ToLower(loadBalancerName)+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceLoadBalancerName returns the name of the load balancer of the service. It is the name of the name annotation,
+// or the generated name, unless no load balancer has that name and a load balancer is tagged for the service under
+// another name, because the annotation changed or was removed since the load balancer was created. Load balancers are
+// only looked up by tag when the service may have been renamed, see mayHaveRenamedLoadBalancer. An invalid name
+// annotation is an error rather than falling back to the generated name.
+func (c *Cloud) serviceLoadBalancerName(ctx context.Context, clusterName string, service *v1.Service) (string, error) {
+	if name, ok := service.Annotations[ServiceAnnotationLoadBalancerName]; ok {
+		if err := validateLoadBalancerName(name); err != nil {
+			return "", fmt.Errorf("invalid %s: %v", ServiceAnnotationLoadBalancerName, err)
+		}
+	}
+	loadBalancerName := c.GetLoadBalancerName(ctx, clusterName, service)
+	exists, err := c.loadBalancerExists(loadBalancerName, isNLB(service.Annotations))
+	if err != nil || exists || !mayHaveRenamedLoadBalancer(service, loadBalancerName) {
+		return loadBalancerName, err
+	}
+	taggedName, err := c.findTaggedLoadBalancerName(service)
+	if err != nil || taggedName == "" {
+		return loadBalancerName, err
+	}
+	return taggedName, nil
+}
+
+// deleteRenamedLoadBalancer deletes the load balancer of the service that was created under another name, before its
+// name annotation changed or was removed. Load balancers can't be renamed, so ensureLoadBalancer creates it again.
+func (c *Cloud) deleteRenamedLoadBalancer(ctx context.Context, clusterName string, service *v1.Service) error {
+	existingName, err := c.serviceLoadBalancerName(ctx, clusterName, service)
+	if err != nil {
+		return err
+	}
+	loadBalancerName := c.GetLoadBalancerName(ctx, clusterName, service)
+	if existingName == loadBalancerName {
+		return nil
+	}
+	c.recordServiceEvent(service, v1.EventTypeNormal, "LoadBalancerNameChanged",
+		"Deleting load balancer %s, the load balancer of the service is now named %s", existingName, loadBalancerName)
+	return c.deleteLoadBalancer(ctx, service, existingName)
 }
 
 // getKeyValuePropertiesFromAnnotation converts the comma separated list of key-value
@@ -141,8 +446,291 @@ func getKeyValuePropertiesFromAnnotation(annotations map[string]string, annotati
 	return additionalTags
 }
 
+// additionalTagKeys returns the sorted keys of the additional tags, separated by spaces, to be recorded in the
+// TagNameKubernetesAdditionalTags tag. It returns an empty string when there are no keys, or when they don't fit in a
+// tag value, in which case the tags removed from the annotation are left on the load balancer.
+func additionalTagKeys(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	value := strings.Join(keys, " ")
+	if len(value) > elbTagValueMaxLength {
+		klog.Warningf("Not recording the keys of %d additional tags, they exceed the maximum tag value length of %d", len(keys), elbTagValueMaxLength)
+		return ""
+	}
+	return value
+}
+
+// loadBalancerv2Scheme returns the scheme of a load balancer with the internal load balancer annotation
+func loadBalancerv2Scheme(internalELB bool) string {
+	if internalELB {
+		return elbv2.LoadBalancerSchemeEnumInternal
+	}
+	return elbv2.LoadBalancerSchemeEnumInternetFacing
+}
+
+// ensureLoadBalancerv2Scheme deletes the NLB of a service when its scheme doesn't match the internal load balancer
+// annotation anymore, the scheme of a load balancer can't be changed so ensureLoadBalancerv2 creates it again.
+// The security groups of the load balancer are kept for the new load balancer.
+func (c *Cloud) ensureLoadBalancerv2Scheme(service *v1.Service, loadBalancerName string, internalELB bool) error {
+	loadBalancer, err := c.describeLoadBalancerv2(loadBalancerName)
+	if err != nil || loadBalancer == nil {
+		return err
+	}
+	actual, expected := aws.StringValue(loadBalancer.Scheme), loadBalancerv2Scheme(internalELB)
+	if actual == "" || actual == expected {
+		return nil
+	}
+
+	klog.Warningf("Recreating load balancer %s of service %s/%s to change its scheme from %s to %s", loadBalancerName, service.Namespace, service.Name, actual, expected)
+	c.recordServiceEvent(service, v1.EventTypeWarning, "LoadBalancerSchemeChange",
+		"Recreating load balancer %s to change its scheme from %s to %s, the DNS name %s stops resolving and clients must use the new endpoint",
+		loadBalancerName, actual, expected, aws.StringValue(loadBalancer.DNSName))
+	return c.deleteLoadBalancerv2AndWait(loadBalancer, service.Annotations)
+}
+
+// getNLBEIPAllocations returns the allocation IDs of the EIP allocations annotation, the nth allocation is mapped to
+// the nth subnet. There must be one allocation per subnet, and each elastic IP must be usable in the availability
+// zone of its subnet. It returns nil when the annotation isn't set.
+func (c *Cloud) getNLBEIPAllocations(ctx context.Context, annotations map[string]string, subnetIDs []string, internalELB bool) ([]string, error) {
+	eipList, present := annotations[ServiceAnnotationLoadBalancerEIPAllocations]
+	if !present {
+		return nil, nil
+	}
+	if internalELB {
+		return nil, fmt.Errorf("annotation %s is only supported by internet-facing load balancers", ServiceAnnotationLoadBalancerEIPAllocations)
+	}
+	allocationIDs := splitCommaSeparatedString(eipList)
+	if len(allocationIDs) != len(subnetIDs) {
+		return nil, fmt.Errorf("must have same number of EIP AllocationIDs (%d) and SubnetIDs (%d)", len(allocationIDs), len(subnetIDs))
+	}
+
+	addresses, err := c.ec2.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{AllocationIds: allocationIDs})
+	if err != nil {
+		return nil, err
+	}
+	borderGroups := make(map[string]string, len(addresses))
+	for _, address := range addresses {
+		borderGroups[aws.StringValue(address.AllocationId)] = aws.StringValue(address.NetworkBorderGroup)
+	}
+	subnets, err := c.ec2.DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{SubnetIds: subnetIDs})
+	if err != nil {
+		return nil, err
+	}
+	subnetZones := make(map[string]string, len(subnets))
+	var zoneNames []string
+	for _, subnet := range subnets {
+		subnetZones[aws.StringValue(subnet.SubnetId)] = aws.StringValue(subnet.AvailabilityZone)
+		zoneNames = append(zoneNames, aws.StringValue(subnet.AvailabilityZone))
+	}
+	zones, err := c.zoneCache.getZoneDetailsByNames(ctx, zoneNames)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, allocationID := range allocationIDs {
+		borderGroup, found := borderGroups[allocationID]
+		if !found {
+			return nil, fmt.Errorf("EIP allocation %q from annotation %s was not found", allocationID, ServiceAnnotationLoadBalancerEIPAllocations)
+		}
+		zone := subnetZones[subnetIDs[i]]
+		expected := zones[zone].networkBorderGroup
+		if borderGroup != "" && expected != "" && borderGroup != expected {
+			return nil, fmt.Errorf("EIP allocation %q from network border group %q can't be used by subnet %q in availability zone %q of network border group %q",
+				allocationID, borderGroup, subnetIDs[i], zone, expected)
+		}
+	}
+	return allocationIDs, nil
+}
+
+// ensureLoadBalancerv2SubnetMappings deletes the NLB of a service when the elastic IPs of its subnets don't match the
+// EIP allocations annotation anymore, the subnet mappings of a load balancer can't be changed so ensureLoadBalancerv2
+// creates it again. Load balancers without elastic IPs are left alone.
+func (c *Cloud) ensureLoadBalancerv2SubnetMappings(service *v1.Service, loadBalancerName string, subnetIDs, allocationIDs []string) error {
+	loadBalancer, err := c.describeLoadBalancerv2(loadBalancerName)
+	if err != nil || loadBalancer == nil {
+		return err
+	}
+	actual := map[string]string{}
+	for _, zone := range loadBalancer.AvailabilityZones {
+		actual[aws.StringValue(zone.SubnetId)] = ""
+		for _, address := range zone.LoadBalancerAddresses {
+			if aws.StringValue(address.AllocationId) != "" {
+				actual[aws.StringValue(zone.SubnetId)] = aws.StringValue(address.AllocationId)
+			}
+		}
+	}
+	expected := map[string]string{}
+	for _, mapping := range createSubnetMappings(subnetIDs, allocationIDs) {
+		expected[aws.StringValue(mapping.SubnetId)] = aws.StringValue(mapping.AllocationId)
+	}
+	hasAllocations := func(mappings map[string]string) bool {
+		for _, allocationID := range mappings {
+			if allocationID != "" {
+				return true
+			}
+		}
+		return false
+	}
+	if (!hasAllocations(actual) && !hasAllocations(expected)) || maps.Equal(actual, expected) {
+		return nil
+	}
+
+	klog.Warningf("Recreating load balancer %s of service %s/%s to change its EIP allocations", loadBalancerName, service.Namespace, service.Name)
+	c.recordServiceEvent(service, v1.EventTypeWarning, "LoadBalancerEIPAllocationsChange",
+		"Recreating load balancer %s to change its EIP allocations, the DNS name %s stops resolving and clients must use the new endpoint",
+		loadBalancerName, aws.StringValue(loadBalancer.DNSName))
+	return c.deleteLoadBalancerv2AndWait(loadBalancer, service.Annotations)
+}
+
+// deleteLoadBalancerv2AndWait deletes a v2 load balancer so that it can be created again with the same name
+func (c *Cloud) deleteLoadBalancerv2AndWait(loadBalancer *elbv2.LoadBalancer, annotations map[string]string) error {
+	if err := c.deleteLoadBalancerv2(loadBalancer, annotations); err != nil {
+		return err
+	}
+	// A load balancer with the same name can't be created until the old one is deleted
+	if err := c.elbv2.WaitUntilLoadBalancersDeleted(&elbv2.DescribeLoadBalancersInput{LoadBalancerArns: []*string{loadBalancer.LoadBalancerArn}}); err != nil {
+		return fmt.Errorf("error waiting for load balancer %q to be deleted: %q", aws.StringValue(loadBalancer.LoadBalancerName), err)
+	}
+	return nil
+}
+
+// loadBalancerProvisioningBackoff is used while waiting for a new NLB to become active, the service is synced again
+// if it is still provisioning once the backoff is exhausted
+var loadBalancerProvisioningBackoff = wait.Backoff{
+	Duration: 2 * time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    6,
+	Cap:      30 * time.Second,
+}
+
+// waitForLoadBalancerv2Active polls a v2 load balancer that is still provisioning until it is active, so that the
+// status of the service isn't reported before the load balancer can serve traffic
+func (c *Cloud) waitForLoadBalancerv2Active(ctx context.Context, loadBalancer *elbv2.LoadBalancer) (*elbv2.LoadBalancer, error) {
+	name := aws.StringValue(loadBalancer.LoadBalancerName)
+	active := func() (bool, error) {
+		// The load balancers of a dry run are never created, so they never become active
+		if loadBalancer.State == nil || isDryRunResource(loadBalancer.LoadBalancerArn) {
+			return true, nil
+		}
+		switch aws.StringValue(loadBalancer.State.Code) {
+		case elbv2.LoadBalancerStateEnumActive, elbv2.LoadBalancerStateEnumActiveImpaired:
+			return true, nil
+		case elbv2.LoadBalancerStateEnumFailed:
+			return false, fmt.Errorf("load balancer %q failed to provision: %s", name, aws.StringValue(loadBalancer.State.Reason))
+		}
+		klog.V(2).Infof("Waiting for load balancer %s to become active, it is %s", name, aws.StringValue(loadBalancer.State.Code))
+		return false, nil
+	}
+	if done, err := active(); done || err != nil {
+		return loadBalancer, err
+	}
+	err := wait.ExponentialBackoffWithContext(ctx, loadBalancerProvisioningBackoff, func(ctx context.Context) (bool, error) {
+		response, err := c.elbv2.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{LoadBalancerArns: []*string{loadBalancer.LoadBalancerArn}})
+		if err != nil {
+			return false, fmt.Errorf("error describing load balancer %q: %q", name, err)
+		}
+		if len(response.LoadBalancers) != 1 {
+			return false, fmt.Errorf("found %d load balancers with ARN %q", len(response.LoadBalancers), aws.StringValue(loadBalancer.LoadBalancerArn))
+		}
+		loadBalancer = response.LoadBalancers[0]
+		return active()
+	})
+	if wait.Interrupted(err) {
+		return nil, fmt.Errorf("load balancer %q is still %s", name, aws.StringValue(loadBalancer.State.Code))
+	}
+	return loadBalancer, err
+}
+
+// deleteLoadBalancerv2 deletes a v2 load balancer and its target groups
+//
+// Deleting a target group while associated with a load balancer will
+// fail. We delete the loadbalancer first. This does leave the
+// possibility of zombie target groups if DeleteLoadBalancer() fails
+func (c *Cloud) deleteLoadBalancerv2(loadBalancer *elbv2.LoadBalancer, annotations map[string]string) error {
+	if err := c.disableDeletionProtection(loadBalancer, annotations); err != nil {
+		return err
+	}
+
+	targetGroups, err := c.elbv2.DescribeTargetGroups(
+		&elbv2.DescribeTargetGroupsInput{LoadBalancerArn: loadBalancer.LoadBalancerArn},
+	)
+	if err != nil {
+		return fmt.Errorf("error listing target groups before deleting load balancer: %q", err)
+	}
+
+	_, err = c.elbv2.DeleteLoadBalancer(
+		&elbv2.DeleteLoadBalancerInput{LoadBalancerArn: loadBalancer.LoadBalancerArn},
+	)
+	if err != nil {
+		return fmt.Errorf("error deleting load balancer %q: %v", aws.StringValue(loadBalancer.LoadBalancerName), err)
+	}
+
+	for _, group := range targetGroups.TargetGroups {
+		_, err := c.elbv2.DeleteTargetGroup(
+			&elbv2.DeleteTargetGroupInput{TargetGroupArn: group.TargetGroupArn},
+		)
+		if err != nil {
+			return fmt.Errorf("error deleting target groups after deleting load balancer: %q", err)
+		}
+	}
+	return nil
+}
+
+// disableDeletionProtection disables the deletion protection of a v2 load balancer before it is deleted. It returns an
+// error instead when the annotations of the service still require the protection.
+func (c *Cloud) disableDeletionProtection(loadBalancer *elbv2.LoadBalancer, annotations map[string]string) error {
+	loadBalancerName := aws.StringValue(loadBalancer.LoadBalancerName)
+	protected, err := deletionProtectionEnabled(annotations)
+	if err != nil {
+		return err
+	}
+	if protected {
+		return fmt.Errorf("load balancer %s has deletion protection enabled, set %s to false or remove it to delete the load balancer",
+			loadBalancerName, ServiceAnnotationLoadBalancerDeletionProtection)
+	}
+
+	response, err := c.elbv2.DescribeLoadBalancerAttributes(&elbv2.DescribeLoadBalancerAttributesInput{
+		LoadBalancerArn: loadBalancer.LoadBalancerArn,
+	})
+	if err != nil {
+		return fmt.Errorf("error describing attributes of load balancer %q before deleting it: %q", loadBalancerName, err)
+	}
+	for _, attr := range response.Attributes {
+		if aws.StringValue(attr.Key) != lbAttrDeletionProtectionEnabled || aws.StringValue(attr.Value) != "true" {
+			continue
+		}
+		klog.Infof("Disabling deletion protection of load balancer %s before deleting it", loadBalancerName)
+		_, err := c.elbv2.ModifyLoadBalancerAttributes(&elbv2.ModifyLoadBalancerAttributesInput{
+			LoadBalancerArn: loadBalancer.LoadBalancerArn,
+			Attributes:      []*elbv2.LoadBalancerAttribute{{Key: aws.String(lbAttrDeletionProtectionEnabled), Value: aws.String("false")}},
+		})
+		if err != nil {
+			return fmt.Errorf("error disabling deletion protection of load balancer %q: %q", loadBalancerName, err)
+		}
+	}
+	return nil
+}
+
+// deletionProtectionEnabled returns whether the deletion protection annotation enables the deletion protection
+func deletionProtectionEnabled(annotations map[string]string) (bool, error) {
+	annotation := annotations[ServiceAnnotationLoadBalancerDeletionProtection]
+	if annotation == "" {
+		return false, nil
+	}
+	enabled, err := strconv.ParseBool(annotation)
+	if err != nil {
+		return false, fmt.Errorf("error parsing service annotation: %s=%s", ServiceAnnotationLoadBalancerDeletionProtection, annotation)
+	}
+	return enabled, nil
+}
+
 // ensureLoadBalancerv2 ensures a v2 load balancer is created
-func (c *Cloud) ensureLoadBalancerv2(namespacedName types.NamespacedName, loadBalancerName string, mappings []nlbPortMapping, instanceIDs, discoveredSubnetIDs []string, internalELB bool, annotations map[string]string) (*elbv2.LoadBalancer, error) {
+func (c *Cloud) ensureLoadBalancerv2(ctx context.Context, namespacedName types.NamespacedName, loadBalancerName string, mappings []nlbPortMapping, instanceIDs, discoveredSubnetIDs, allocationIDs, securityGroupIDs []string, internalELB bool, annotations map[string]string) (*elbv2.LoadBalancer, error) {
+	logger := klog.FromContext(ctx)
 	loadBalancer, err := c.describeLoadBalancerv2(loadBalancerName)
 	if err != nil {
 		return nil, err
@@ -163,15 +751,10 @@ func (c *Cloud) ensureLoadBalancerv2(namespacedName types.NamespacedName, loadBa
 			Name: aws.String(loadBalancerName),
 		}
 		if internalELB {
-			createRequest.Scheme = aws.String("internal")
+			createRequest.Scheme = aws.String(elbv2.LoadBalancerSchemeEnumInternal)
 		}
-
-		var allocationIDs []string
-		if eipList, present := annotations[ServiceAnnotationLoadBalancerEIPAllocations]; present {
-			allocationIDs = strings.Split(eipList, ",")
-			if len(allocationIDs) != len(discoveredSubnetIDs) {
-				return nil, fmt.Errorf("error creating load balancer: Must have same number of EIP AllocationIDs (%d) and SubnetIDs (%d)", len(allocationIDs), len(discoveredSubnetIDs))
-			}
+		if len(securityGroupIDs) != 0 {
+			createRequest.SecurityGroups = aws.StringSlice(securityGroupIDs)
 		}
 
 		// We are supposed to specify one subnet per AZ.
@@ -184,17 +767,23 @@ func (c *Cloud) ensureLoadBalancerv2(namespacedName types.NamespacedName, loadBa
 			})
 		}
 
-		klog.Infof("Creating load balancer for %v with name: %s", namespacedName, loadBalancerName)
+		logger.Info("Creating load balancer", "loadBalancer", loadBalancerName)
 		createResponse, err := c.elbv2.CreateLoadBalancer(createRequest)
+		if err != nil && createRequest.SecurityGroups != nil && isNLBSecurityGroupsUnsupportedError(err) {
+			logger.Info("Security groups aren't supported for network load balancers, creating load balancer without security groups", "loadBalancer", loadBalancerName, "err", err)
+			createRequest.SecurityGroups = nil
+			createResponse, err = c.elbv2.CreateLoadBalancer(createRequest)
+		}
 		if err != nil {
 			return nil, fmt.Errorf("error creating load balancer: %q", err)
 		}
 
 		loadBalancer = createResponse.LoadBalancers[0]
+		ctx = klog.NewContext(ctx, logger.WithValues("loadBalancerARN", aws.StringValue(loadBalancer.LoadBalancerArn)))
 		for i := range mappings {
 			// It is easier to keep track of updates by having possibly
 			// duplicate target groups where the backend port is the same
-			_, err := c.createListenerV2(createResponse.LoadBalancers[0].LoadBalancerArn, mappings[i], namespacedName, instanceIDs, *createResponse.LoadBalancers[0].VpcId, tags)
+			_, err := c.createListenerV2(ctx, createResponse.LoadBalancers[0].LoadBalancerArn, mappings[i], namespacedName, instanceIDs, *createResponse.LoadBalancers[0].VpcId, tags)
 			if err != nil {
 				return nil, fmt.Errorf("error creating listener: %q", err)
 			}
@@ -203,7 +792,8 @@ func (c *Cloud) ensureLoadBalancerv2(namespacedName types.NamespacedName, loadBa
 			return nil, err
 		}
 	} else {
-		// TODO: Sync internal vs non-internal
+		// Scheme changes are handled by ensureLoadBalancerv2Scheme, which recreates the load balancer
+		ctx = klog.NewContext(ctx, logger.WithValues("loadBalancerARN", aws.StringValue(loadBalancer.LoadBalancerArn)))
 
 		// sync mappings
 		{
@@ -272,7 +862,7 @@ func (c *Cloud) ensureLoadBalancerv2(namespacedName types.NamespacedName, loadBa
 						}
 					}
 
-					// recreate targetGroup if trafficPort, protocol or HealthCheckProtocol changed
+					// recreate targetGroup if trafficPort, protocol, target type or HealthCheckProtocol changed
 					healthCheckModified := false
 					targetGroupRecreated := false
 					targetGroup, ok := nodePortTargetGroup[nodePort]
@@ -282,9 +872,11 @@ func (c *Cloud) ensureLoadBalancerv2(namespacedName types.NamespacedName, loadBa
 						healthCheckModified = true
 					}
 
-					if !ok || aws.StringValue(targetGroup.Protocol) != mapping.TrafficProtocol || healthCheckModified {
+					if !ok || aws.StringValue(targetGroup.Protocol) != mapping.TrafficProtocol ||
+						aws.StringValue(targetGroup.TargetType) != mapping.targetType() || healthCheckModified {
 						// create new target group
 						targetGroup, err = c.ensureTargetGroup(
+							ctx,
 							nil,
 							namespacedName,
 							mapping,
@@ -299,6 +891,8 @@ func (c *Cloud) ensureLoadBalancerv2(namespacedName types.NamespacedName, loadBa
 						listenerNeedsModification = true
 					}
 
+					// Capture the current target group before the listener is pointed at a new one
+					oldTargetGroupArn := listener.DefaultActions[0].TargetGroupArn
 					if listenerNeedsModification {
 						modifyListenerInput := &elbv2.ModifyListenerInput{
 							ListenerArn: listener.ListenerArn,
@@ -327,13 +921,14 @@ func (c *Cloud) ensureLoadBalancerv2(namespacedName types.NamespacedName, loadBa
 					// Delete old targetGroup if needed
 					if targetGroupRecreated {
 						if _, err := c.elbv2.DeleteTargetGroup(&elbv2.DeleteTargetGroupInput{
-							TargetGroupArn: listener.DefaultActions[0].TargetGroupArn,
+							TargetGroupArn: oldTargetGroupArn,
 						}); err != nil {
 							return nil, fmt.Errorf("error deleting old target group: %q", err)
 						}
 					} else {
 						// Run ensureTargetGroup to make sure instances in service are up-to-date
 						_, err = c.ensureTargetGroup(
+							ctx,
 							targetGroup,
 							namespacedName,
 							mapping,
@@ -350,7 +945,7 @@ func (c *Cloud) ensureLoadBalancerv2(namespacedName types.NamespacedName, loadBa
 				}
 
 				// Additions
-				_, err := c.createListenerV2(loadBalancer.LoadBalancerArn, mapping, namespacedName, instanceIDs, *loadBalancer.VpcId, tags)
+				_, err := c.createListenerV2(ctx, loadBalancer.LoadBalancerArn, mapping, namespacedName, instanceIDs, *loadBalancer.VpcId, tags)
 				if err != nil {
 					return nil, err
 				}
@@ -378,6 +973,14 @@ func (c *Cloud) ensureLoadBalancerv2(namespacedName types.NamespacedName, loadBa
 				}
 			}
 		}
+		securityGroupsChanged, err := c.ensureLoadBalancerv2SecurityGroups(loadBalancer, securityGroupIDs)
+		if err != nil {
+			return nil, err
+		}
+		if securityGroupsChanged {
+			dirty = true
+		}
+
 		if err := c.reconcileLBAttributes(aws.StringValue(loadBalancer.LoadBalancerArn), annotations); err != nil {
 			return nil, err
 		}
@@ -400,6 +1003,165 @@ func (c *Cloud) ensureLoadBalancerv2(namespacedName types.NamespacedName, loadBa
 	return loadBalancer, nil
 }
 
+// nlbSecurityGroupName returns the name of the security group managed for an NLB
+func nlbSecurityGroupName(loadBalancerName string) string {
+	return "k8s-elb-" + loadBalancerName
+}
+
+// buildNLBSecurityGroupList returns the security groups to attach to an NLB, which is the security group managed for
+// the load balancer when NLB security groups are enabled. Security groups can only be attached to NLBs created with
+// security groups, so none are returned for existing NLBs without any.
+func (c *Cloud) buildNLBSecurityGroupList(ctx context.Context, serviceName types.NamespacedName, loadBalancerName string, mappings []nlbPortMapping, sourceRangeCidrs []string, annotations map[string]string) ([]string, error) {
+	if !c.cfg.GetNLBSecurityGroupsEnabled() {
+		return nil, nil
+	}
+	loadBalancer, err := c.describeLoadBalancerv2(loadBalancerName)
+	if err != nil {
+		return nil, err
+	}
+	if loadBalancer != nil && len(loadBalancer.SecurityGroups) == 0 {
+		klog.V(2).Infof("Not managing a security group for load balancer %s, as it was created without security groups", loadBalancerName)
+		return nil, nil
+	}
+	securityGroupID, err := c.ensureNLBSecurityGroup(ctx, serviceName, loadBalancerName, mappings, sourceRangeCidrs, annotations)
+	if err != nil {
+		return nil, err
+	}
+	return []string{securityGroupID}, nil
+}
+
+// ensureNLBSecurityGroup makes sure the security group managed for an NLB exists, and that its ingress allows the
+// traffic from the source ranges to the listener ports, and the ICMP fragmentation packets used for MTU discovery.
+// The source ranges are the loadBalancerSourceRanges of the service, IPv4 or IPv6, or 0.0.0.0/0 when unspecified.
+func (c *Cloud) ensureNLBSecurityGroup(ctx context.Context, serviceName types.NamespacedName, loadBalancerName string, mappings []nlbPortMapping, sourceRangeCidrs []string, annotations map[string]string) (string, error) {
+	sgName := nlbSecurityGroupName(loadBalancerName)
+	sgDescription := fmt.Sprintf("Security group for Kubernetes NLB %s (%v)", loadBalancerName, serviceName)
+	securityGroupID, err := c.ensureSecurityGroup(ctx, sgName, sgDescription, getKeyValuePropertiesFromAnnotation(annotations, ServiceAnnotationLoadBalancerAdditionalTags))
+	if err != nil {
+		klog.Errorf("Error creating load balancer security group: %q", err)
+		return "", err
+	}
+
+	var ipRanges []ec2types.IpRange
+	var ipv6Ranges []ec2types.Ipv6Range
+	for _, cidr := range sourceRangeCidrs {
+		if netutils.IsIPv6CIDRString(cidr) {
+			ipv6Ranges = append(ipv6Ranges, ec2types.Ipv6Range{CidrIpv6: aws.String(cidr)})
+		} else {
+			ipRanges = append(ipRanges, ec2types.IpRange{CidrIp: aws.String(cidr)})
+		}
+	}
+	permissions := NewIPPermissionSet()
+	for _, mapping := range mappings {
+		protocol := "tcp"
+		if mapping.FrontendProtocol == string(v1.ProtocolUDP) {
+			protocol = "udp"
+		}
+		if len(ipRanges) > 0 {
+			permissions.Insert(ec2types.IpPermission{
+				IpProtocol: aws.String(protocol),
+				FromPort:   aws.Int32(int32(mapping.FrontendPort)),
+				ToPort:     aws.Int32(int32(mapping.FrontendPort)),
+				IpRanges:   ipRanges,
+			})
+		}
+		if len(ipv6Ranges) > 0 {
+			permissions.Insert(ec2types.IpPermission{
+				IpProtocol: aws.String(protocol),
+				FromPort:   aws.Int32(int32(mapping.FrontendPort)),
+				ToPort:     aws.Int32(int32(mapping.FrontendPort)),
+				Ipv6Ranges: ipv6Ranges,
+			})
+		}
+	}
+	// Allow ICMP fragmentation packets, important for MTU discovery, the packet too big messages with IPv6
+	if len(ipRanges) > 0 {
+		permissions.Insert(ec2types.IpPermission{
+			IpProtocol: aws.String("icmp"),
+			FromPort:   aws.Int32(3),
+			ToPort:     aws.Int32(4),
+			IpRanges:   ipRanges,
+		})
+	}
+	if len(ipv6Ranges) > 0 {
+		permissions.Insert(ec2types.IpPermission{
+			IpProtocol: aws.String("icmpv6"),
+			FromPort:   aws.Int32(2),
+			ToPort:     aws.Int32(-1),
+			Ipv6Ranges: ipv6Ranges,
+		})
+	}
+	if _, err := c.setSecurityGroupIngress(ctx, securityGroupID, permissions); err != nil {
+		return "", err
+	}
+	return securityGroupID, nil
+}
+
+// ensureLoadBalancerv2SecurityGroups sets the security groups of an NLB, if it was created with security groups.
+// It returns whether the security groups were changed.
+func (c *Cloud) ensureLoadBalancerv2SecurityGroups(loadBalancer *elbv2.LoadBalancer, securityGroupIDs []string) (bool, error) {
+	if len(securityGroupIDs) == 0 || len(loadBalancer.SecurityGroups) == 0 {
+		return false, nil
+	}
+	if sets.New(securityGroupIDs...).Equal(sets.New(aws.StringValueSlice(loadBalancer.SecurityGroups)...)) {
+		return false, nil
+	}
+	klog.V(2).Infof("Setting security groups %v of load balancer %s", securityGroupIDs, aws.StringValue(loadBalancer.LoadBalancerName))
+	_, err := c.elbv2.SetSecurityGroups(&elbv2.SetSecurityGroupsInput{
+		LoadBalancerArn: loadBalancer.LoadBalancerArn,
+		SecurityGroups:  aws.StringSlice(securityGroupIDs),
+	})
+	if err != nil {
+		if isNLBSecurityGroupsUnsupportedError(err) {
+			klog.Warningf("Not setting security groups of load balancer %s, as they aren't supported: %v", aws.StringValue(loadBalancer.LoadBalancerName), err)
+			return false, nil
+		}
+		return false, fmt.Errorf("error setting load balancer security groups: %q", err)
+	}
+	return true, nil
+}
+
+// deleteNLBSecurityGroups deletes the security group managed for an NLB that was deleted
+func (c *Cloud) deleteNLBSecurityGroups(ctx context.Context, loadBalancer *elbv2.LoadBalancer) error {
+	if len(loadBalancer.SecurityGroups) == 0 {
+		return nil
+	}
+	loadBalancerName := aws.StringValue(loadBalancer.LoadBalancerName)
+	groups, err := c.ec2.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
+		GroupIds: aws.StringValueSlice(loadBalancer.SecurityGroups),
+	})
+	if err != nil {
+		return fmt.Errorf("error querying security groups for load balancer %s: %q", loadBalancerName, err)
+	}
+	securityGroupIDs := map[string]struct{}{}
+	for _, group := range groups {
+		// Only the security group managed for the load balancer is deleted
+		if aws.StringValue(group.GroupName) != nlbSecurityGroupName(loadBalancerName) ||
+			!c.tagging.hasClusterTag(group.Tags) || c.tagging.hasOtherClusterTag(group.Tags) {
+			continue
+		}
+		securityGroupIDs[aws.StringValue(group.GroupId)] = struct{}{}
+	}
+	if len(securityGroupIDs) == 0 {
+		return nil
+	}
+	return c.deleteLoadBalancerSecurityGroups(ctx, loadBalancerName, securityGroupIDs)
+}
+
+// isNLBSecurityGroupsUnsupportedError returns true if the error is returned because security groups aren't supported
+// for NLBs, e.g. in regions where the feature isn't available yet
+func isNLBSecurityGroupsUnsupportedError(err error) bool {
+	var awsError awserr.Error
+	if !errors.As(err, &awsError) {
+		return false
+	}
+	switch awsError.Code() {
+	case "ValidationError", elbv2.ErrCodeInvalidConfigurationRequestException:
+		return strings.Contains(strings.ToLower(awsError.Message()), "security group")
+	}
+	return false
+}
+
 func (c *Cloud) reconcileLBAttributes(loadBalancerArn string, annotations map[string]string) error {
 	desiredLoadBalancerAttributes := map[string]string{}
 
@@ -435,6 +1197,15 @@ func (c *Cloud) reconcileLBAttributes(loadBalancerArn string, annotations map[st
 		}
 	}
 
+	// Deletion protection is only managed when the annotation is set, so protection enabled outside of the cluster
+	// isn't reverted
+	_, manageDeletionProtection := annotations[ServiceAnnotationLoadBalancerDeletionProtection]
+	deletionProtection, err := deletionProtectionEnabled(annotations)
+	if err != nil {
+		return err
+	}
+	desiredLoadBalancerAttributes[lbAttrDeletionProtectionEnabled] = strconv.FormatBool(deletionProtection)
+
 	desiredLoadBalancerAttributes[lbAttrAccessLogsS3Bucket] = annotations[ServiceAnnotationLoadBalancerAccessLogS3BucketName]
 	desiredLoadBalancerAttributes[lbAttrAccessLogsS3Prefix] = annotations[ServiceAnnotationLoadBalancerAccessLogS3BucketPrefix]
 
@@ -463,6 +1234,15 @@ func (c *Cloud) reconcileLBAttributes(loadBalancerArn string, annotations map[st
 		})
 	}
 
+	// Deletion protection is disabled by default, the attribute is missing from the attributes of some load balancers
+	if current := currentLoadBalancerAttributes[lbAttrDeletionProtectionEnabled]; manageDeletionProtection &&
+		desiredLoadBalancerAttributes[lbAttrDeletionProtectionEnabled] != current && (current != "" || deletionProtection) {
+		changedAttributes = append(changedAttributes, &elbv2.LoadBalancerAttribute{
+			Key:   aws.String(lbAttrDeletionProtectionEnabled),
+			Value: aws.String(desiredLoadBalancerAttributes[lbAttrDeletionProtectionEnabled]),
+		})
+	}
+
 	// ELBV2 API forbids us to set bucket to an empty bucket, so we keep it unchanged if AccessLogsS3Enabled==false.
 	if desiredLoadBalancerAttributes[lbAttrAccessLogsS3Enabled] == "true" {
 		if desiredLoadBalancerAttributes[lbAttrAccessLogsS3Bucket] != currentLoadBalancerAttributes[lbAttrAccessLogsS3Bucket] {
@@ -516,8 +1296,9 @@ func (c *Cloud) buildTargetGroupName(serviceName types.NamespacedName, servicePo
 	return fmt.Sprintf("k8s-%.8s-%.8s-%.10s", sanitizedNamespace, sanitizedServiceName, tgUUID)
 }
 
-func (c *Cloud) createListenerV2(loadBalancerArn *string, mapping nlbPortMapping, namespacedName types.NamespacedName, instanceIDs []string, vpcID string, tags map[string]string) (listener *elbv2.Listener, err error) {
+func (c *Cloud) createListenerV2(ctx context.Context, loadBalancerArn *string, mapping nlbPortMapping, namespacedName types.NamespacedName, instanceIDs []string, vpcID string, tags map[string]string) (listener *elbv2.Listener, err error) {
 	target, err := c.ensureTargetGroup(
+		ctx,
 		nil,
 		namespacedName,
 		mapping,
@@ -558,7 +1339,7 @@ func (c *Cloud) createListenerV2(loadBalancerArn *string, mapping nlbPortMapping
 		}
 	}
 
-	klog.Infof("Creating load balancer listener for %v", namespacedName)
+	klog.FromContext(ctx).Info("Creating load balancer listener", "port", mapping.FrontendPort, "protocol", mapping.FrontendProtocol)
 	createListenerOutput, err := c.elbv2.CreateListener(createListernerInput)
 	if err != nil {
 		return nil, fmt.Errorf("error creating load balancer listener: %q", err)
@@ -580,13 +1361,16 @@ func (c *Cloud) deleteListenerV2(listener *elbv2.Listener) error {
 }
 
 // ensureTargetGroup creates a target group with a set of instances.
-func (c *Cloud) ensureTargetGroup(targetGroup *elbv2.TargetGroup, serviceName types.NamespacedName, mapping nlbPortMapping, instances []string, vpcID string, tags map[string]string) (*elbv2.TargetGroup, error) {
+func (c *Cloud) ensureTargetGroup(ctx context.Context, targetGroup *elbv2.TargetGroup, serviceName types.NamespacedName, mapping nlbPortMapping, instances []string, vpcID string, tags map[string]string) (*elbv2.TargetGroup, error) {
 	dirty := false
 	expectedTargets := c.computeTargetGroupExpectedTargets(instances, mapping.TrafficPort)
+	if mapping.targetType() == elbv2.TargetTypeEnumIp {
+		expectedTargets = mapping.IPTargets
+	}
 	if targetGroup == nil {
-		targetType := "instance"
+		targetType := mapping.targetType()
 		name := c.buildTargetGroupName(serviceName, mapping.FrontendPort, mapping.TrafficPort, mapping.TrafficProtocol, targetType, mapping)
-		klog.Infof("Creating load balancer target group for %v with name: %s", serviceName, name)
+		klog.FromContext(ctx).Info("Creating load balancer target group", "targetGroup", name)
 		input := &elbv2.CreateTargetGroupInput{
 			VpcId:                      aws.String(vpcID),
 			Name:                       aws.String(name),
@@ -624,12 +1408,22 @@ func (c *Cloud) ensureTargetGroup(targetGroup *elbv2.TargetGroup, serviceName ty
 
 		tg := result.TargetGroups[0]
 		tgARN := aws.StringValue(tg.TargetGroupArn)
-		if err := c.ensureTargetGroupTargets(tgARN, expectedTargets, nil); err != nil {
+		// New target groups use the default attributes, so they only need to be set when configured
+		if mapping.ProxyProtocol || mapping.DeregistrationDelay != nil || mapping.Stickiness != nil {
+			if err := c.ensureTargetGroupAttributes(tgARN, mapping); err != nil {
+				return nil, err
+			}
+		}
+		if err := c.ensureTargetGroupTargets(ctx, tgARN, expectedTargets, nil); err != nil {
 			return nil, err
 		}
 		return tg, nil
 	}
 
+	if err := c.ensureTargetGroupAttributes(aws.StringValue(targetGroup.TargetGroupArn), mapping); err != nil {
+		return nil, err
+	}
+
 	// handle instances in service
 	{
 		tgARN := aws.StringValue(targetGroup.TargetGroupArn)
@@ -637,7 +1431,7 @@ func (c *Cloud) ensureTargetGroup(targetGroup *elbv2.TargetGroup, serviceName ty
 		if err != nil {
 			return nil, err
 		}
-		if err := c.ensureTargetGroupTargets(tgARN, expectedTargets, actualTargets); err != nil {
+		if err := c.ensureTargetGroupTargets(ctx, tgARN, expectedTargets, actualTargets); err != nil {
 			return nil, err
 		}
 	}
@@ -653,44 +1447,233 @@ func (c *Cloud) ensureTargetGroup(targetGroup *elbv2.TargetGroup, serviceName ty
 			input.HealthCheckPort = aws.String(mapping.HealthCheckConfig.Port)
 			dirtyHealthCheck = true
 		}
-		if mapping.HealthCheckConfig.HealthyThreshold != aws.Int64Value(targetGroup.HealthyThresholdCount) {
+		if mapping.HealthCheckConfig.HealthyThreshold != aws.Int64Value(targetGroup.HealthyThresholdCount) ||
+			mapping.HealthCheckConfig.UnhealthyThreshold != aws.Int64Value(targetGroup.UnhealthyThresholdCount) {
 			dirtyHealthCheck = true
 			input.HealthyThresholdCount = aws.Int64(mapping.HealthCheckConfig.HealthyThreshold)
 			input.UnhealthyThresholdCount = aws.Int64(mapping.HealthCheckConfig.UnhealthyThreshold)
 		}
+		// TCP health checks don't accept a path, so it is only reconciled for HTTP and HTTPS.
 		if !strings.EqualFold(mapping.HealthCheckConfig.Protocol, elbv2.ProtocolEnumTcp) {
-			if mapping.HealthCheckConfig.Path != aws.StringValue(input.HealthCheckPath) {
+			if mapping.HealthCheckConfig.Path != aws.StringValue(targetGroup.HealthCheckPath) {
 				input.HealthCheckPath = aws.String(mapping.HealthCheckConfig.Path)
 				dirtyHealthCheck = true
 			}
 		}
 
-		if dirtyHealthCheck {
-			_, err := c.elbv2.ModifyTargetGroup(input)
-			if err != nil {
-				return nil, fmt.Errorf("error modifying target group health check: %q", err)
-			}
+		if dirtyHealthCheck {
+			_, err := c.elbv2.ModifyTargetGroup(input)
+			if err != nil {
+				return nil, fmt.Errorf("error modifying target group health check: %q", err)
+			}
+
+			dirty = true
+		}
+	}
+
+	if dirty {
+		result, err := c.elbv2.DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{
+			TargetGroupArns: []*string{targetGroup.TargetGroupArn},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving target group after creation/update: %q", err)
+		}
+		targetGroup = result.TargetGroups[0]
+	}
+
+	return targetGroup, nil
+}
+
+// ensureTargetGroupAttributes makes sure the attributes of the target group match the mapping, only issuing an
+// update when they differ.
+func (c *Cloud) ensureTargetGroupAttributes(tgARN string, mapping nlbPortMapping) error {
+	describeAttributesOutput, err := c.elbv2.DescribeTargetGroupAttributes(&elbv2.DescribeTargetGroupAttributesInput{
+		TargetGroupArn: aws.String(tgARN),
+	})
+	if err != nil {
+		return fmt.Errorf("error retrieving target group attributes: %q", err)
+	}
+	currentTargetGroupAttributes := map[string]string{}
+	for _, attr := range describeAttributesOutput.Attributes {
+		currentTargetGroupAttributes[aws.StringValue(attr.Key)] = aws.StringValue(attr.Value)
+	}
+
+	var changedAttributes []*elbv2.TargetGroupAttribute
+	if (currentTargetGroupAttributes[tgAttrProxyProtocolV2Enabled] == "true") != mapping.ProxyProtocol {
+		changedAttributes = append(changedAttributes, &elbv2.TargetGroupAttribute{
+			Key:   aws.String(tgAttrProxyProtocolV2Enabled),
+			Value: aws.String(strconv.FormatBool(mapping.ProxyProtocol)),
+		})
+	}
+	if mapping.DeregistrationDelay != nil {
+		deregistrationDelay := strconv.FormatInt(*mapping.DeregistrationDelay, 10)
+		if currentTargetGroupAttributes[tgAttrDeregistrationDelayTimeoutSeconds] != deregistrationDelay {
+			changedAttributes = append(changedAttributes, &elbv2.TargetGroupAttribute{
+				Key:   aws.String(tgAttrDeregistrationDelayTimeoutSeconds),
+				Value: aws.String(deregistrationDelay),
+			})
+		}
+	}
+
+	if mapping.Stickiness != nil {
+		stickiness := strconv.FormatBool(*mapping.Stickiness)
+		if currentTargetGroupAttributes[tgAttrStickinessEnabled] != stickiness {
+			changedAttributes = append(changedAttributes, &elbv2.TargetGroupAttribute{
+				Key:   aws.String(tgAttrStickinessEnabled),
+				Value: aws.String(stickiness),
+			})
+		}
+		if *mapping.Stickiness && currentTargetGroupAttributes[tgAttrStickinessType] != tgStickinessTypeSourceIP {
+			changedAttributes = append(changedAttributes, &elbv2.TargetGroupAttribute{
+				Key:   aws.String(tgAttrStickinessType),
+				Value: aws.String(tgStickinessTypeSourceIP),
+			})
+		}
+	}
+
+	if len(changedAttributes) > 0 {
+		// Attributes that drifted, e.g. edited in the console, are restored on every reconcile
+		klog.V(2).Infof("updating target group attributes for %q: %v", tgARN, changedAttributes)
 
-			dirty = true
+		if _, err := c.elbv2.ModifyTargetGroupAttributes(&elbv2.ModifyTargetGroupAttributesInput{
+			TargetGroupArn: aws.String(tgARN),
+			Attributes:     changedAttributes,
+		}); err != nil {
+			return fmt.Errorf("error modifying target group attributes: %q", err)
 		}
 	}
+	return nil
+}
 
-	if dirty {
-		result, err := c.elbv2.DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{
-			TargetGroupArns: []*string{targetGroup.TargetGroupArn},
-		})
+// getNLBDeregistrationDelay returns the deregistration delay for the target groups of the service from the connection
+// draining annotations. It returns nil when they aren't set, leaving the target group default unchanged.
+func (c *Cloud) getNLBDeregistrationDelay(service *v1.Service) (*int64, error) {
+	annotations := service.Annotations
+	if enabledAnnotation := annotations[ServiceAnnotationLoadBalancerConnectionDrainingEnabled]; enabledAnnotation != "" {
+		enabled, err := strconv.ParseBool(enabledAnnotation)
 		if err != nil {
-			return nil, fmt.Errorf("error retrieving target group after creation/update: %q", err)
+			return nil, fmt.Errorf("error parsing service annotation: %s=%s",
+				ServiceAnnotationLoadBalancerConnectionDrainingEnabled,
+				enabledAnnotation,
+			)
+		}
+		if !enabled {
+			return aws.Int64(0), nil
 		}
-		targetGroup = result.TargetGroups[0]
 	}
 
-	return targetGroup, nil
+	timeoutAnnotation := annotations[ServiceAnnotationLoadBalancerConnectionDrainingTimeout]
+	if timeoutAnnotation == "" {
+		return nil, nil
+	}
+	timeout, err := strconv.ParseInt(timeoutAnnotation, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing service annotation: %s=%s",
+			ServiceAnnotationLoadBalancerConnectionDrainingTimeout,
+			timeoutAnnotation,
+		)
+	}
+	return aws.Int64(c.clampDeregistrationDelay(service, timeout)), nil
+}
+
+// getNLBStickiness returns whether source IP stickiness is enabled on the target groups of the service from the
+// target group attributes annotation. It returns nil when stickiness isn't set, leaving the target group default
+// unchanged.
+func getNLBStickiness(annotations map[string]string) (*bool, error) {
+	attributes := getKeyValuePropertiesFromAnnotation(annotations, ServiceAnnotationLoadBalancerTargetGroupAttributes)
+	for key := range attributes {
+		if key != tgAttrStickinessEnabled && key != tgAttrStickinessType {
+			return nil, fmt.Errorf("unsupported target group attribute %q in service annotation %s, supported attributes are %s and %s",
+				key, ServiceAnnotationLoadBalancerTargetGroupAttributes, tgAttrStickinessEnabled, tgAttrStickinessType)
+		}
+	}
+	if stickinessType, ok := attributes[tgAttrStickinessType]; ok && stickinessType != tgStickinessTypeSourceIP {
+		return nil, fmt.Errorf("unsupported %s %q in service annotation %s, network load balancers only support %s",
+			tgAttrStickinessType, stickinessType, ServiceAnnotationLoadBalancerTargetGroupAttributes, tgStickinessTypeSourceIP)
+	}
+
+	enabledValue, ok := attributes[tgAttrStickinessEnabled]
+	if !ok {
+		return nil, nil
+	}
+	enabled, err := strconv.ParseBool(enabledValue)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s in service annotation: %s=%s",
+			tgAttrStickinessEnabled, ServiceAnnotationLoadBalancerTargetGroupAttributes, annotations[ServiceAnnotationLoadBalancerTargetGroupAttributes])
+	}
+	return aws.Bool(enabled), nil
+}
+
+// clampConnectionDrainingTimeout limits a connection draining timeout to the range allowed by AWS, and records a
+// warning event on the service when it is out of range, rather than letting the API call fail.
+func (c *Cloud) clampConnectionDrainingTimeout(service *v1.Service, timeout int64) int64 {
+	return c.clampAnnotationValue(service, ServiceAnnotationLoadBalancerConnectionDrainingTimeout, "InvalidConnectionDrainingTimeout",
+		timeout, minConnectionDrainingTimeout, maxConnectionDrainingTimeout)
+}
+
+// clampDeregistrationDelay limits a target group deregistration delay to the range allowed by AWS, and records a
+// warning event on the service when it is out of range, rather than letting the API call fail.
+func (c *Cloud) clampDeregistrationDelay(service *v1.Service, delay int64) int64 {
+	return c.clampAnnotationValue(service, ServiceAnnotationLoadBalancerConnectionDrainingTimeout, "InvalidConnectionDrainingTimeout",
+		delay, minDeregistrationDelay, maxDeregistrationDelay)
+}
+
+// clampConnectionIdleTimeout limits a connection idle timeout to the range allowed by AWS, and records a warning
+// event on the service when it is out of range, rather than letting the API call fail.
+func (c *Cloud) clampConnectionIdleTimeout(service *v1.Service, timeout int64) int64 {
+	return c.clampAnnotationValue(service, ServiceAnnotationLoadBalancerConnectionIdleTimeout, "InvalidConnectionIdleTimeout",
+		timeout, minConnectionIdleTimeout, maxConnectionIdleTimeout)
+}
+
+// clampAnnotationValue limits the value of an annotation to the range [minValue, maxValue], recording a warning event
+// with the given reason on the service when the value is out of range. The event is only recorded again once the
+// value changes, so that every sync of the service doesn't repeat it.
+func (c *Cloud) clampAnnotationValue(service *v1.Service, annotation, reason string, value, minValue, maxValue int64) int64 {
+	clamped := min(max(value, minValue), maxValue)
+	if c.updateWarnedAnnotation(service, annotation, strconv.FormatInt(value, 10), clamped != value) {
+		c.recordServiceEvent(service, v1.EventTypeWarning, reason,
+			"%s=%d is outside of the allowed range %d-%d, using %d",
+			annotation, value, minValue, maxValue, clamped)
+	}
+	return clamped
+}
+
+// updateWarnedAnnotation records whether the value of the annotation of the service is invalid, and returns true
+// when it is invalid and differs from the value last recorded, i.e. when a warning event should be recorded for it
+func (c *Cloud) updateWarnedAnnotation(service *v1.Service, annotation string, value string, invalid bool) bool {
+	c.warnedAnnotationsMu.Lock()
+	defer c.warnedAnnotationsMu.Unlock()
+	key := types.NamespacedName{Namespace: service.Namespace, Name: service.Name}
+	last, ok := c.warnedAnnotations[key][annotation]
+	if !invalid {
+		delete(c.warnedAnnotations[key], annotation)
+		return false
+	}
+	if ok && last == value {
+		return false
+	}
+	if c.warnedAnnotations == nil {
+		c.warnedAnnotations = map[types.NamespacedName]map[string]string{}
+	}
+	if c.warnedAnnotations[key] == nil {
+		c.warnedAnnotations[key] = map[string]string{}
+	}
+	c.warnedAnnotations[key][annotation] = value
+	return true
 }
 
-func (c *Cloud) ensureTargetGroupTargets(tgARN string, expectedTargets []*elbv2.TargetDescription, actualTargets []*elbv2.TargetDescription) error {
+// forgetWarnedAnnotations drops the invalid annotation values recorded for the service
+func (c *Cloud) forgetWarnedAnnotations(service *v1.Service) {
+	c.warnedAnnotationsMu.Lock()
+	defer c.warnedAnnotationsMu.Unlock()
+	delete(c.warnedAnnotations, types.NamespacedName{Namespace: service.Namespace, Name: service.Name})
+}
+
+func (c *Cloud) ensureTargetGroupTargets(ctx context.Context, tgARN string, expectedTargets []*elbv2.TargetDescription, actualTargets []*elbv2.TargetDescription) error {
+	logger := klog.FromContext(ctx).WithValues("targetGroupARN", tgARN)
 	targetsToRegister, targetsToDeregister := c.diffTargetGroupTargets(expectedTargets, actualTargets)
 	if len(targetsToRegister) > 0 {
+		logger.V(2).Info("Registering targets", "targets", targetDescriptionIDs(targetsToRegister))
 		targetsToRegisterChunks := c.chunkTargetDescriptions(targetsToRegister, defaultRegisterTargetsChunkSize)
 		for _, targetsChunk := range targetsToRegisterChunks {
 			req := &elbv2.RegisterTargetsInput{
@@ -703,13 +1686,15 @@ func (c *Cloud) ensureTargetGroupTargets(tgARN string, expectedTargets []*elbv2.
 		}
 	}
 	if len(targetsToDeregister) > 0 {
+		logger.V(2).Info("Deregistering targets", "targets", targetDescriptionIDs(targetsToDeregister))
+		// Deregistrations are batched per target group, so that scaling down many nodes doesn't make a call per node
 		targetsToDeregisterChunks := c.chunkTargetDescriptions(targetsToDeregister, defaultDeregisterTargetsChunkSize)
 		for _, targetsChunk := range targetsToDeregisterChunks {
 			req := &elbv2.DeregisterTargetsInput{
 				TargetGroupArn: aws.String(tgARN),
 				Targets:        targetsChunk,
 			}
-			if _, err := c.elbv2.DeregisterTargets(req); err != nil {
+			if _, err := c.deregisterTargetsBatcher.DeregisterTargets(ctx, req); err != nil {
 				return fmt.Errorf("error trying to deregister targets in target group: %q", err)
 			}
 		}
@@ -717,6 +1702,15 @@ func (c *Cloud) ensureTargetGroupTargets(tgARN string, expectedTargets []*elbv2.
 	return nil
 }
 
+// targetDescriptionIDs returns the IDs of the targets, for logging
+func targetDescriptionIDs(targets []*elbv2.TargetDescription) []string {
+	ids := make([]string, 0, len(targets))
+	for _, target := range targets {
+		ids = append(ids, aws.StringValue(target.Id))
+	}
+	return ids
+}
+
 func (c *Cloud) computeTargetGroupExpectedTargets(instanceIDs []string, port int64) []*elbv2.TargetDescription {
 	expectedTargets := make([]*elbv2.TargetDescription, 0, len(instanceIDs))
 	for _, instanceID := range instanceIDs {
@@ -785,6 +1779,9 @@ func (c *Cloud) chunkTargetDescriptions(targets []*elbv2.TargetDescription, chun
 
 // updateInstanceSecurityGroupsForNLB will adjust securityGroup's settings to allow inbound traffic into instances from clientCIDRs and portMappings.
 // TIP: if either instances or clientCIDRs or portMappings are nil, then the securityGroup rules for lbName are cleared.
+// The rules are owned by the load balancer named in their description, so the rules of other load balancers, e.g. of
+// other clusters sharing the security group, are left alone. The MTU discovery rules are shared and follow the client
+// rules of all load balancers.
 func (c *Cloud) updateInstanceSecurityGroupsForNLB(ctx context.Context, lbName string, instances map[InstanceID]*ec2types.Instance, subnetCIDRs []string, clientCIDRs []string, portMappings []nlbPortMapping) error {
 	if c.cfg.Global.DisableSecurityGroupIngress {
 		return nil
@@ -877,17 +1874,17 @@ func (c *Cloud) updateInstanceSecurityGroupForNLBTraffic(ctx context.Context, sg
 	desiredPerms := NewIPPermissionSet()
 	for port := range ports {
 		for _, cidr := range cidrs {
-			desiredPerms.Insert(ec2types.IpPermission{
+			permission := ec2types.IpPermission{
 				IpProtocol: aws.String(protocol),
 				FromPort:   aws.Int32(int32(port)),
 				ToPort:     aws.Int32(int32(port)),
-				IpRanges: []ec2types.IpRange{
-					{
-						CidrIp:      aws.String(cidr),
-						Description: aws.String(ruleDesc),
-					},
-				},
-			})
+			}
+			if netutils.IsIPv6CIDRString(cidr) {
+				permission.Ipv6Ranges = []ec2types.Ipv6Range{{CidrIpv6: aws.String(cidr), Description: aws.String(ruleDesc)}}
+			} else {
+				permission.IpRanges = []ec2types.IpRange{{CidrIp: aws.String(cidr), Description: aws.String(ruleDesc)}}
+			}
+			desiredPerms.Insert(permission)
 		}
 	}
 
@@ -973,7 +1970,48 @@ func (c *Cloud) updateInstanceSecurityGroupForNLBMTU(ctx context.Context, sgID s
 	return nil
 }
 
-func (c *Cloud) ensureLoadBalancer(namespacedName types.NamespacedName, loadBalancerName string, listeners []*elb.Listener, subnetIDs []string, securityGroupIDs []string, internalELB, proxyProtocol bool, loadBalancerAttributes *elb.LoadBalancerAttributes, annotations map[string]string) (*elb.LoadBalancerDescription, error) {
+// eventualConsistencyBackoff is used to retry ELB calls that fail because subnets or security groups that were
+// just created are not visible to the ELB API yet
+var eventualConsistencyBackoff = wait.Backoff{
+	Duration: 1 * time.Second,
+	Factor:   2,
+	Jitter:   0.1,
+	Steps:    6,
+	Cap:      30 * time.Second,
+}
+
+// eventualConsistencyErrorCodes are the ELB error codes returned for subnets and security groups that are not
+// visible yet
+var eventualConsistencyErrorCodes = sets.NewString(
+	elb.ErrCodeInvalidSubnetException,
+	elb.ErrCodeSubnetNotFoundException,
+	elb.ErrCodeInvalidSecurityGroupException,
+	"InvalidSecurityGroupID.NotFound",
+)
+
+// retryOnEventualConsistency calls fn until it succeeds or fails with an error that isn't caused by eventual
+// consistency, giving up once eventualConsistencyBackoff is exhausted
+func retryOnEventualConsistency(operation string, fn func() error) error {
+	var lastErr error
+	err := wait.ExponentialBackoff(eventualConsistencyBackoff, func() (bool, error) {
+		lastErr = fn()
+		if lastErr == nil {
+			return true, nil
+		}
+		if aerr, ok := lastErr.(awserr.Error); ok && eventualConsistencyErrorCodes.Has(aerr.Code()) {
+			klog.V(2).Infof("Retrying %s, which failed because a resource is not visible yet: %v", operation, lastErr)
+			return false, nil
+		}
+		return false, lastErr
+	})
+	if wait.Interrupted(err) {
+		return fmt.Errorf("gave up %s after retrying for resources to become visible: %q", operation, lastErr)
+	}
+	return err
+}
+
+func (c *Cloud) ensureLoadBalancer(ctx context.Context, namespacedName types.NamespacedName, loadBalancerName string, listeners []*elb.Listener, subnetIDs []string, securityGroupIDs []string, internalELB, proxyProtocol bool, loadBalancerAttributes *elb.LoadBalancerAttributes, annotations map[string]string) (*elb.LoadBalancerDescription, error) {
+	logger := klog.FromContext(ctx)
 	loadBalancer, err := c.describeLoadBalancer(loadBalancerName)
 	if err != nil {
 		return nil, err
@@ -1007,6 +2045,9 @@ func (c *Cloud) ensureLoadBalancer(namespacedName types.NamespacedName, loadBala
 
 		// Get additional tags set by the user
 		tags := getKeyValuePropertiesFromAnnotation(annotations, ServiceAnnotationLoadBalancerAdditionalTags)
+		if keys := additionalTagKeys(tags); keys != "" {
+			tags[TagNameKubernetesAdditionalTags] = keys
+		}
 
 		// Add default tags
 		tags[TagNameKubernetesService] = namespacedName.String()
@@ -1018,8 +2059,11 @@ func (c *Cloud) ensureLoadBalancer(namespacedName types.NamespacedName, loadBala
 			})
 		}
 
-		klog.Infof("Creating load balancer for %v with name: %s", namespacedName, loadBalancerName)
-		_, err := c.elb.CreateLoadBalancer(createRequest)
+		logger.Info("Creating load balancer")
+		err := retryOnEventualConsistency("creating load balancer "+loadBalancerName, func() error {
+			_, err := c.elb.CreateLoadBalancer(createRequest)
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -1031,7 +2075,7 @@ func (c *Cloud) ensureLoadBalancer(namespacedName types.NamespacedName, loadBala
 			}
 
 			for _, listener := range listeners {
-				klog.V(2).Infof("Adjusting AWS loadbalancer proxy protocol on node port %d. Setting to true", *listener.InstancePort)
+				logger.V(2).Info("Adjusting proxy protocol", "nodePort", *listener.InstancePort, "proxyProtocol", true)
 				err := c.setBackendPolicies(loadBalancerName, *listener.InstancePort, []*string{aws.String(ProxyProtocolPolicyName)})
 				if err != nil {
 					return nil, err
@@ -1045,35 +2089,11 @@ func (c *Cloud) ensureLoadBalancer(namespacedName types.NamespacedName, loadBala
 
 		{
 			// Sync subnets
-			expected := sets.NewString(subnetIDs...)
-			actual := stringSetFromPointers(loadBalancer.Subnets)
-
-			additions := expected.Difference(actual)
-			removals := actual.Difference(expected)
-
-			if removals.Len() != 0 {
-				request := &elb.DetachLoadBalancerFromSubnetsInput{}
-				request.LoadBalancerName = aws.String(loadBalancerName)
-				request.Subnets = stringSetToPointers(removals)
-				klog.V(2).Info("Detaching load balancer from removed subnets")
-				_, err := c.elb.DetachLoadBalancerFromSubnets(request)
-				if err != nil {
-					return nil, fmt.Errorf("error detaching AWS loadbalancer from subnets: %q", err)
-				}
-				dirty = true
-			}
-
-			if additions.Len() != 0 {
-				request := &elb.AttachLoadBalancerToSubnetsInput{}
-				request.LoadBalancerName = aws.String(loadBalancerName)
-				request.Subnets = stringSetToPointers(additions)
-				klog.V(2).Info("Attaching load balancer to added subnets")
-				_, err := c.elb.AttachLoadBalancerToSubnets(request)
-				if err != nil {
-					return nil, fmt.Errorf("error attaching AWS loadbalancer to subnets: %q", err)
-				}
-				dirty = true
+			changed, err := c.ensureLoadBalancerSubnets(loadBalancerName, aws.StringValueSlice(loadBalancer.Subnets), subnetIDs)
+			if err != nil {
+				return nil, err
 			}
+			dirty = dirty || changed
 		}
 
 		{
@@ -1090,8 +2110,11 @@ func (c *Cloud) ensureLoadBalancer(namespacedName types.NamespacedName, loadBala
 				} else {
 					request.SecurityGroups = aws.StringSlice(securityGroupIDs)
 				}
-				klog.V(2).Info("Applying updated security groups to load balancer")
-				_, err := c.elb.ApplySecurityGroupsToLoadBalancer(request)
+				logger.V(2).Info("Applying updated security groups to load balancer", "securityGroups", securityGroupIDs)
+				err := retryOnEventualConsistency("applying security groups to load balancer "+loadBalancerName, func() error {
+					_, err := c.elb.ApplySecurityGroupsToLoadBalancer(request)
+					return err
+				})
 				if err != nil {
 					return nil, fmt.Errorf("error applying AWS loadbalancer security groups: %q", err)
 				}
@@ -1100,27 +2123,11 @@ func (c *Cloud) ensureLoadBalancer(namespacedName types.NamespacedName, loadBala
 		}
 
 		{
-			additions, removals := syncElbListeners(loadBalancerName, listeners, loadBalancer.ListenerDescriptions)
-
-			if len(removals) != 0 {
-				request := &elb.DeleteLoadBalancerListenersInput{}
-				request.LoadBalancerName = aws.String(loadBalancerName)
-				request.LoadBalancerPorts = removals
-				klog.V(2).Info("Deleting removed load balancer listeners")
-				if _, err := c.elb.DeleteLoadBalancerListeners(request); err != nil {
-					return nil, fmt.Errorf("error deleting AWS loadbalancer listeners: %q", err)
-				}
-				dirty = true
+			listenersChanged, err := c.ensureLoadBalancerListeners(loadBalancerName, listeners, loadBalancer.ListenerDescriptions)
+			if err != nil {
+				return nil, err
 			}
-
-			if len(additions) != 0 {
-				request := &elb.CreateLoadBalancerListenersInput{}
-				request.LoadBalancerName = aws.String(loadBalancerName)
-				request.Listeners = additions
-				klog.V(2).Info("Creating added load balancer listeners")
-				if _, err := c.elb.CreateLoadBalancerListeners(request); err != nil {
-					return nil, fmt.Errorf("error creating AWS loadbalancer listeners: %q", err)
-				}
+			if listenersChanged {
 				dirty = true
 			}
 		}
@@ -1168,7 +2175,7 @@ func (c *Cloud) ensureLoadBalancer(namespacedName types.NamespacedName, loadBala
 				}
 
 				if setPolicy {
-					klog.V(2).Infof("Adjusting AWS loadbalancer proxy protocol on node port %d. Setting to %t", instancePort, proxyProtocol)
+					logger.V(2).Info("Adjusting proxy protocol", "nodePort", instancePort, "proxyProtocol", proxyProtocol)
 					err := c.setBackendPolicies(loadBalancerName, instancePort, proxyPolicies)
 					if err != nil {
 						return nil, err
@@ -1182,7 +2189,7 @@ func (c *Cloud) ensureLoadBalancer(namespacedName types.NamespacedName, loadBala
 			// corresponding listener anymore
 			for instancePort, found := range foundBackends {
 				if !found {
-					klog.V(2).Infof("Adjusting AWS loadbalancer proxy protocol on node port %d. Setting to false", instancePort)
+					logger.V(2).Info("Adjusting proxy protocol", "nodePort", instancePort, "proxyProtocol", false)
 					err := c.setBackendPolicies(loadBalancerName, instancePort, []*string{})
 					if err != nil {
 						return nil, err
@@ -1193,10 +2200,40 @@ func (c *Cloud) ensureLoadBalancer(namespacedName types.NamespacedName, loadBala
 		}
 
 		{
-			// Add additional tags
-			klog.V(2).Infof("Creating additional load balancer tags for %s", loadBalancerName)
+			// Sync additional tags, only the tags that are missing or have another value are added, and only the tags
+			// we added before are removed when they are no longer in the annotation
 			tags := getKeyValuePropertiesFromAnnotation(annotations, ServiceAnnotationLoadBalancerAdditionalTags)
+			currentTags, err := c.describeLoadBalancerTags(loadBalancerName, false)
+			if err != nil {
+				return nil, err
+			}
+			var removedTags []string
+			for _, k := range strings.Fields(currentTags[TagNameKubernetesAdditionalTags]) {
+				if _, ok := tags[k]; !ok && k != TagNameKubernetesAdditionalTags {
+					if _, exists := currentTags[k]; exists {
+						removedTags = append(removedTags, k)
+					}
+				}
+			}
+			if keys := additionalTagKeys(tags); keys != "" {
+				tags[TagNameKubernetesAdditionalTags] = keys
+			} else if _, ok := currentTags[TagNameKubernetesAdditionalTags]; ok {
+				removedTags = append(removedTags, TagNameKubernetesAdditionalTags)
+			}
+			for k, v := range currentTags {
+				if value, ok := tags[k]; ok && value == v {
+					delete(tags, k)
+				}
+			}
+			if len(removedTags) > 0 {
+				logger.V(2).Info("Removing additional load balancer tags", "tags", removedTags)
+				err := c.removeLoadBalancerTags(loadBalancerName, removedTags)
+				if err != nil {
+					return nil, fmt.Errorf("unable to remove additional load balancer tags: %v", err)
+				}
+			}
 			if len(tags) > 0 {
+				logger.V(2).Info("Creating additional load balancer tags", "tags", tags)
 				err := c.addLoadBalancerTags(loadBalancerName, tags)
 				if err != nil {
 					return nil, fmt.Errorf("unable to create additional load balancer tags: %v", err)
@@ -1213,15 +2250,15 @@ func (c *Cloud) ensureLoadBalancer(namespacedName types.NamespacedName, loadBala
 		describeAttributesRequest.LoadBalancerName = aws.String(loadBalancerName)
 		describeAttributesOutput, err := c.elb.DescribeLoadBalancerAttributes(describeAttributesRequest)
 		if err != nil {
-			klog.Warning("Unable to retrieve load balancer attributes during attribute sync")
+			logger.Info("Unable to retrieve load balancer attributes during attribute sync")
 			return nil, err
 		}
 
-		foundAttributes := &describeAttributesOutput.LoadBalancerAttributes
+		foundAttributes := describeAttributesOutput.LoadBalancerAttributes
 
 		// Update attributes if they're dirty
 		if !reflect.DeepEqual(loadBalancerAttributes, foundAttributes) {
-			klog.V(2).Infof("Updating load-balancer attributes for %q", loadBalancerName)
+			logger.V(2).Info("Updating load balancer attributes")
 
 			modifyAttributesRequest := &elb.ModifyLoadBalancerAttributesInput{}
 			modifyAttributesRequest.LoadBalancerName = aws.String(loadBalancerName)
@@ -1237,7 +2274,7 @@ func (c *Cloud) ensureLoadBalancer(namespacedName types.NamespacedName, loadBala
 	if dirty {
 		loadBalancer, err = c.describeLoadBalancer(loadBalancerName)
 		if err != nil {
-			klog.Warning("Unable to retrieve load balancer after creation/update")
+			logger.Info("Unable to retrieve load balancer after creation/update")
 			return nil, err
 		}
 	}
@@ -1245,14 +2282,61 @@ func (c *Cloud) ensureLoadBalancer(namespacedName types.NamespacedName, loadBala
 	return loadBalancer, nil
 }
 
+// ensureLoadBalancerListeners reconciles the listeners of an existing ELB with the desired listeners. Only the
+// listeners that changed are deleted and recreated, and a listener whose SSL certificate changed is updated in
+// place, so the connections of unchanged listeners aren't dropped. It returns whether the ELB was modified.
+func (c *Cloud) ensureLoadBalancerListeners(loadBalancerName string, listeners []*elb.Listener, listenerDescriptions []*elb.ListenerDescription) (bool, error) {
+	additions, removals, certificateUpdates := syncElbListeners(loadBalancerName, listeners, listenerDescriptions)
+	changed := false
+
+	if len(removals) != 0 {
+		request := &elb.DeleteLoadBalancerListenersInput{}
+		request.LoadBalancerName = aws.String(loadBalancerName)
+		request.LoadBalancerPorts = removals
+		klog.V(2).Info("Deleting removed load balancer listeners")
+		if _, err := c.elb.DeleteLoadBalancerListeners(request); err != nil {
+			return changed, fmt.Errorf("error deleting AWS loadbalancer listeners: %q", err)
+		}
+		changed = true
+	}
+
+	if len(additions) != 0 {
+		request := &elb.CreateLoadBalancerListenersInput{}
+		request.LoadBalancerName = aws.String(loadBalancerName)
+		request.Listeners = additions
+		klog.V(2).Info("Creating added load balancer listeners")
+		if _, err := c.elb.CreateLoadBalancerListeners(request); err != nil {
+			return changed, fmt.Errorf("error creating AWS loadbalancer listeners: %q", err)
+		}
+		changed = true
+	}
+
+	for _, listener := range certificateUpdates {
+		request := &elb.SetLoadBalancerListenerSSLCertificateInput{}
+		request.LoadBalancerName = aws.String(loadBalancerName)
+		request.LoadBalancerPort = listener.LoadBalancerPort
+		request.SSLCertificateId = listener.SSLCertificateId
+		klog.V(2).Infof("Updating SSL certificate of load balancer listener on port %d", aws.Int64Value(listener.LoadBalancerPort))
+		if _, err := c.elb.SetLoadBalancerListenerSSLCertificate(request); err != nil {
+			return changed, fmt.Errorf("error updating AWS loadbalancer listener SSL certificate: %q", err)
+		}
+		changed = true
+	}
+
+	return changed, nil
+}
+
 // syncElbListeners computes a plan to reconcile the desired vs actual state of the listeners on an ELB
+// Listeners that only differ by their SSL certificate are returned as certificate updates rather than being
+// recreated.
 // NOTE: there exists an O(nlgn) implementation for this function. However, as the default limit of
 //
 //	listeners per elb is 100, this implementation is reduced from O(m*n) => O(n).
-func syncElbListeners(loadBalancerName string, listeners []*elb.Listener, listenerDescriptions []*elb.ListenerDescription) ([]*elb.Listener, []*int64) {
+func syncElbListeners(loadBalancerName string, listeners []*elb.Listener, listenerDescriptions []*elb.ListenerDescription) ([]*elb.Listener, []*int64, []*elb.Listener) {
 	foundSet := make(map[int]bool)
 	removals := []*int64{}
 	additions := []*elb.Listener{}
+	certificateUpdates := []*elb.Listener{}
 
 	for _, listenerDescription := range listenerDescriptions {
 		actual := listenerDescription.Listener
@@ -1275,6 +2359,19 @@ func syncElbListeners(loadBalancerName string, listeners []*elb.Listener, listen
 				break
 			}
 		}
+		if !found {
+			for i, expected := range listeners {
+				if expected == nil || foundSet[i] {
+					continue
+				}
+				if elbListenerCertificateChanged(actual, expected) {
+					foundSet[i] = true
+					found = true
+					certificateUpdates = append(certificateUpdates, expected)
+					break
+				}
+			}
+		}
 		if !found {
 			removals = append(removals, actual.LoadBalancerPort)
 		}
@@ -1286,7 +2383,21 @@ func syncElbListeners(loadBalancerName string, listeners []*elb.Listener, listen
 		}
 	}
 
-	return additions, removals
+	return additions, removals, certificateUpdates
+}
+
+// elbListenerCertificateChanged returns true if the listeners only differ by their SSL certificate, which can be
+// updated without recreating the listener
+func elbListenerCertificateChanged(actual, expected *elb.Listener) bool {
+	if actual.SSLCertificateId == nil || expected.SSLCertificateId == nil {
+		return false
+	}
+	if awsArnEquals(actual.SSLCertificateId, expected.SSLCertificateId) {
+		return false
+	}
+	withCertificate := *actual
+	withCertificate.SSLCertificateId = expected.SSLCertificateId
+	return elbListenersAreEqual(&withCertificate, expected)
 }
 
 func elbListenersAreEqual(actual, expected *elb.Listener) bool {
@@ -1308,6 +2419,92 @@ func elbListenersAreEqual(actual, expected *elb.Listener) bool {
 	return true
 }
 
+// ensureLoadBalancerSubnets attaches and detaches subnets so the classic ELB is in the expected subnets, e.g. to
+// follow the cluster into a new AZ. An ELB is in at most one subnet per AZ, so subnets in new AZs are attached before
+// the removed subnets are detached, and the subnets that replace one in the same AZ after. When every subnet would be
+// detached first, one is kept until the next sync, except for the only subnet of the ELB replaced by another subnet in
+// its AZ, which can only be detached before the replacement is attached.
+func (c *Cloud) ensureLoadBalancerSubnets(loadBalancerName string, actualSubnetIDs, expectedSubnetIDs []string) (bool, error) {
+	actual := sets.NewString(actualSubnetIDs...)
+	expected := sets.NewString(expectedSubnetIDs...)
+	additions := expected.Difference(actual)
+	removals := actual.Difference(expected)
+	if additions.Len() == 0 && removals.Len() == 0 {
+		return false, nil
+	}
+
+	subnets, err := c.ec2.DescribeSubnets(context.TODO(), &ec2.DescribeSubnetsInput{SubnetIds: actual.Union(expected).List()})
+	if err != nil {
+		return false, fmt.Errorf("error describing subnets of load balancer %s: %q", loadBalancerName, err)
+	}
+	subnetAZs := map[string]string{}
+	for _, subnet := range subnets {
+		subnetAZs[aws.StringValue(subnet.SubnetId)] = aws.StringValue(subnet.AvailabilityZone)
+	}
+	attachedAZs := sets.NewString()
+	for _, subnetID := range actual.UnsortedList() {
+		attachedAZs.Insert(subnetAZs[subnetID])
+	}
+	var newAZAdditions, replacements []string
+	for _, subnetID := range additions.List() {
+		if attachedAZs.Has(subnetAZs[subnetID]) {
+			replacements = append(replacements, subnetID)
+		} else {
+			newAZAdditions = append(newAZAdditions, subnetID)
+		}
+	}
+	if len(newAZAdditions) == 0 && removals.Equal(actual) && removals.Len() > 0 && (removals.Len() > 1 || len(replacements) == 0) {
+		kept := removals.List()[0]
+		klog.Warningf("Keeping load balancer %s in subnet %s until the subnets replacing it are attached, it can't be left without subnets", loadBalancerName, kept)
+		removals.Delete(kept)
+		var attachable []string
+		for _, subnetID := range replacements {
+			if subnetAZs[subnetID] != subnetAZs[kept] {
+				attachable = append(attachable, subnetID)
+			}
+		}
+		replacements = attachable
+	}
+
+	if len(newAZAdditions) != 0 {
+		if err := c.attachLoadBalancerToSubnets(loadBalancerName, newAZAdditions); err != nil {
+			return false, err
+		}
+	}
+	if removals.Len() != 0 {
+		request := &elb.DetachLoadBalancerFromSubnetsInput{}
+		request.LoadBalancerName = aws.String(loadBalancerName)
+		request.Subnets = stringSetToPointers(removals)
+		klog.V(2).Info("Detaching load balancer from removed subnets")
+		_, err := c.elb.DetachLoadBalancerFromSubnets(request)
+		if err != nil {
+			return false, fmt.Errorf("error detaching AWS loadbalancer from subnets: %q", err)
+		}
+	}
+	if len(replacements) != 0 {
+		if err := c.attachLoadBalancerToSubnets(loadBalancerName, replacements); err != nil {
+			return false, err
+		}
+	}
+	return len(newAZAdditions) != 0 || removals.Len() != 0 || len(replacements) != 0, nil
+}
+
+// attachLoadBalancerToSubnets attaches the classic ELB to the subnets, retrying while they aren't visible yet
+func (c *Cloud) attachLoadBalancerToSubnets(loadBalancerName string, subnetIDs []string) error {
+	request := &elb.AttachLoadBalancerToSubnetsInput{}
+	request.LoadBalancerName = aws.String(loadBalancerName)
+	request.Subnets = aws.StringSlice(subnetIDs)
+	klog.V(2).Info("Attaching load balancer to added subnets")
+	err := retryOnEventualConsistency("attaching load balancer "+loadBalancerName+" to subnets", func() error {
+		_, err := c.elb.AttachLoadBalancerToSubnets(request)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error attaching AWS loadbalancer to subnets: %q", err)
+	}
+	return nil
+}
+
 func createSubnetMappings(subnetIDs []string, allocationIDs []string) []*elbv2.SubnetMapping {
 	response := []*elbv2.SubnetMapping{}
 
@@ -1344,7 +2541,7 @@ func awsArnEquals(l, r *string) bool {
 // and using either sensible defaults or overrides via Service annotations
 func (c *Cloud) getExpectedHealthCheck(target string, annotations map[string]string) (*elb.HealthCheck, error) {
 	healthcheck := &elb.HealthCheck{Target: &target}
-	getOrDefault := func(annotation string, defaultValue int64) (*int64, error) {
+	getOrDefault := func(annotation string, defaultValue, minValue, maxValue int64) (*int64, error) {
 		i64 := defaultValue
 		var err error
 		if s, ok := annotations[annotation]; ok {
@@ -1352,23 +2549,26 @@ func (c *Cloud) getExpectedHealthCheck(target string, annotations map[string]str
 			if err != nil {
 				return nil, fmt.Errorf("failed parsing health check annotation value: %v", err)
 			}
+			if i64 < minValue || i64 > maxValue {
+				return nil, fmt.Errorf("health check annotation %s=%d is outside of the allowed range %d-%d", annotation, i64, minValue, maxValue)
+			}
 		}
 		return &i64, nil
 	}
 	var err error
-	healthcheck.HealthyThreshold, err = getOrDefault(ServiceAnnotationLoadBalancerHCHealthyThreshold, defaultElbHCHealthyThreshold)
+	healthcheck.HealthyThreshold, err = getOrDefault(ServiceAnnotationLoadBalancerHCHealthyThreshold, defaultElbHCHealthyThreshold, minElbHCThreshold, maxElbHCThreshold)
 	if err != nil {
 		return nil, err
 	}
-	healthcheck.UnhealthyThreshold, err = getOrDefault(ServiceAnnotationLoadBalancerHCUnhealthyThreshold, defaultElbHCUnhealthyThreshold)
+	healthcheck.UnhealthyThreshold, err = getOrDefault(ServiceAnnotationLoadBalancerHCUnhealthyThreshold, defaultElbHCUnhealthyThreshold, minElbHCThreshold, maxElbHCThreshold)
 	if err != nil {
 		return nil, err
 	}
-	healthcheck.Timeout, err = getOrDefault(ServiceAnnotationLoadBalancerHCTimeout, defaultElbHCTimeout)
+	healthcheck.Timeout, err = getOrDefault(ServiceAnnotationLoadBalancerHCTimeout, defaultElbHCTimeout, minElbHCTimeout, maxElbHCTimeout)
 	if err != nil {
 		return nil, err
 	}
-	healthcheck.Interval, err = getOrDefault(ServiceAnnotationLoadBalancerHCInterval, defaultElbHCInterval)
+	healthcheck.Interval, err = getOrDefault(ServiceAnnotationLoadBalancerHCInterval, defaultElbHCInterval, minElbHCInterval, maxElbHCInterval)
 	if err != nil {
 		return nil, err
 	}
@@ -1379,10 +2579,14 @@ func (c *Cloud) getExpectedHealthCheck(target string, annotations map[string]str
 }
 
 // Makes sure that the health check for an ELB matches the configured health check node port
-func (c *Cloud) ensureLoadBalancerHealthCheck(loadBalancer *elb.LoadBalancerDescription, protocol string, port int32, path string, annotations map[string]string) error {
+func (c *Cloud) ensureLoadBalancerHealthCheck(service *v1.Service, loadBalancer *elb.LoadBalancerDescription, protocol string, port int32, path string) error {
 	name := aws.StringValue(loadBalancer.LoadBalancerName)
+	annotations := service.Annotations
 
 	actual := loadBalancer.HealthCheck
+	if actual == nil {
+		actual = &elb.HealthCheck{}
+	}
 	// Override healthcheck protocol, port and path based on annotations
 	if s, ok := annotations[ServiceAnnotationLoadBalancerHealthCheckProtocol]; ok {
 		protocol = s
@@ -1411,6 +2615,17 @@ func (c *Cloud) ensureLoadBalancerHealthCheck(loadBalancer *elb.LoadBalancerDesc
 		return fmt.Errorf("cannot update health check for load balancer %q: %q", name, err)
 	}
 
+	// AWS rejects health checks that time out after the next check is due, the current health check is kept
+	if aws.Int64Value(expected.Timeout) >= aws.Int64Value(expected.Interval) {
+		klog.Warningf("Not updating health check for load balancer %q, timeout %ds must be less than interval %ds",
+			name, aws.Int64Value(expected.Timeout), aws.Int64Value(expected.Interval))
+		c.recordServiceEvent(service, v1.EventTypeWarning, "InvalidHealthCheckTimeout",
+			"%s=%d must be less than %s=%d, the load balancer health check is not updated",
+			ServiceAnnotationLoadBalancerHCTimeout, aws.Int64Value(expected.Timeout),
+			ServiceAnnotationLoadBalancerHCInterval, aws.Int64Value(expected.Interval))
+		return nil
+	}
+
 	// comparing attributes 1 by 1 to avoid breakage in case a new field is
 	// added to the HC which breaks the equality
 	if aws.StringValue(expected.Target) == aws.StringValue(actual.Target) &&
@@ -1434,7 +2649,7 @@ func (c *Cloud) ensureLoadBalancerHealthCheck(loadBalancer *elb.LoadBalancerDesc
 }
 
 // Makes sure that exactly the specified hosts are registered as instances with the load balancer
-func (c *Cloud) ensureLoadBalancerInstances(loadBalancerName string, lbInstances []*elb.Instance, instanceIDs map[InstanceID]*ec2types.Instance) error {
+func (c *Cloud) ensureLoadBalancerInstances(ctx context.Context, loadBalancerName string, lbInstances []*elb.Instance, instanceIDs map[InstanceID]*ec2types.Instance) error {
 	expected := sets.NewString()
 	for id := range instanceIDs {
 		expected.Insert(string(id))
@@ -1470,7 +2685,7 @@ func (c *Cloud) ensureLoadBalancerInstances(loadBalancerName string, lbInstances
 		if err != nil {
 			return err
 		}
-		klog.V(1).Infof("Instances added to load-balancer %s", loadBalancerName)
+		klog.FromContext(ctx).V(1).Info("Instances added to load balancer", "instances", additions.List())
 	}
 
 	if len(removeInstances) > 0 {
@@ -1481,22 +2696,180 @@ func (c *Cloud) ensureLoadBalancerInstances(loadBalancerName string, lbInstances
 		if err != nil {
 			return err
 		}
-		klog.V(1).Infof("Instances removed from load-balancer %s", loadBalancerName)
+		klog.FromContext(ctx).V(1).Info("Instances removed from load balancer", "instances", removals.List())
 	}
 
 	return nil
 }
 
-func (c *Cloud) getLoadBalancerTLSPorts(loadBalancer *elb.LoadBalancerDescription) []int64 {
-	ports := []int64{}
+// knownSSLNegotiationPolicies are the predefined ELB security policies, of classic and network load balancers
+var knownSSLNegotiationPolicies = sets.NewString(
+	"ELBSample-ELBDefaultCipherPolicy",
+	"ELBSample-OpenSSLDefaultCipherPolicy",
+	"ELBSecurityPolicy-2011-08",
+	"ELBSecurityPolicy-2014-01",
+	"ELBSecurityPolicy-2014-10",
+	"ELBSecurityPolicy-2015-02",
+	"ELBSecurityPolicy-2015-03",
+	"ELBSecurityPolicy-2015-05",
+	"ELBSecurityPolicy-2016-08",
+	"ELBSecurityPolicy-FS-2018-06",
+	"ELBSecurityPolicy-FS-1-1-2019-08",
+	"ELBSecurityPolicy-FS-1-2-2019-08",
+	"ELBSecurityPolicy-FS-1-2-Res-2019-08",
+	"ELBSecurityPolicy-FS-1-2-Res-2020-10",
+	"ELBSecurityPolicy-TLS-1-0-2015-04",
+	"ELBSecurityPolicy-TLS-1-1-2017-01",
+	"ELBSecurityPolicy-TLS-1-2-2017-01",
+	"ELBSecurityPolicy-TLS-1-2-Ext-2018-06",
+	"ELBSecurityPolicy-TLS13-1-0-2021-06",
+	"ELBSecurityPolicy-TLS13-1-1-2021-06",
+	"ELBSecurityPolicy-TLS13-1-2-2021-06",
+	"ELBSecurityPolicy-TLS13-1-2-Ext1-2021-06",
+	"ELBSecurityPolicy-TLS13-1-2-Ext2-2021-06",
+	"ELBSecurityPolicy-TLS13-1-2-Res-2021-06",
+	"ELBSecurityPolicy-TLS13-1-3-2021-06",
+)
+
+// sslNegotiationPolicies are the SSL negotiation policies of the TLS listeners of a load balancer, from the
+// ServiceAnnotationLoadBalancerSSLNegotiationPolicy annotation. The annotation is either a single policy for all
+// listeners, or a comma-separated list of "port=policy" entries keyed by service port number or name, in which an
+// entry without a port sets the policy of the ports that aren't listed, e.g. "443=ELBSecurityPolicy-TLS-1-2-2017-01,ELBSecurityPolicy-2016-08".
+type sslNegotiationPolicies struct {
+	defaultPolicy string
+	portPolicies  map[string]string
+}
+
+// getSSLNegotiationPolicies parses the SSL negotiation policies of a service, it returns nil when the annotation
+// is not set
+func getSSLNegotiationPolicies(annotations map[string]string) *sslNegotiationPolicies {
+	if _, ok := annotations[ServiceAnnotationLoadBalancerSSLNegotiationPolicy]; !ok {
+		return nil
+	}
+	policies := &sslNegotiationPolicies{portPolicies: map[string]string{}}
+	for key, value := range getKeyValuePropertiesFromAnnotation(annotations, ServiceAnnotationLoadBalancerSSLNegotiationPolicy) {
+		if value == "" {
+			policies.defaultPolicy = key
+			continue
+		}
+		policies.portPolicies[key] = value
+	}
+	return policies
+}
+
+// forPort returns the policy of a service port, or an empty string if the port has none
+func (p *sslNegotiationPolicies) forPort(port v1.ServicePort) string {
+	if p == nil {
+		return ""
+	}
+	if policy, ok := p.portPolicies[strconv.Itoa(int(port.Port))]; ok {
+		return policy
+	}
+	if policy, ok := p.portPolicies[port.Name]; ok && port.Name != "" {
+		return policy
+	}
+	return p.defaultPolicy
+}
+
+// forLoadBalancerPort returns the policy of the listener of a load balancer port
+func (p *sslNegotiationPolicies) forLoadBalancerPort(ports []v1.ServicePort, loadBalancerPort int64) string {
+	for _, port := range ports {
+		if int64(port.Port) == loadBalancerPort {
+			return p.forPort(port)
+		}
+	}
+	return p.forPort(v1.ServicePort{Port: int32(loadBalancerPort)})
+}
+
+// names returns all the policies that are set
+func (p *sslNegotiationPolicies) names() sets.String {
+	names := sets.NewString()
+	if p.defaultPolicy != "" {
+		names.Insert(p.defaultPolicy)
+	}
+	for _, policy := range p.portPolicies {
+		names.Insert(policy)
+	}
+	return names
+}
+
+// warnOnUnknownSSLNegotiationPolicies records an event for policies that are not predefined ELB security policies.
+// The policies are applied regardless, as AWS may have added policies that are not known yet.
+func (c *Cloud) warnOnUnknownSSLNegotiationPolicies(service *v1.Service, policies *sslNegotiationPolicies) {
+	if unknown := policies.names().Difference(knownSSLNegotiationPolicies); unknown.Len() > 0 {
+		c.recordServiceEvent(service, v1.EventTypeWarning, "UnknownSSLNegotiationPolicy",
+			"Unknown SSL negotiation policies %v in annotation %s", unknown.List(), ServiceAnnotationLoadBalancerSSLNegotiationPolicy)
+	}
+}
+
+// warnOnUnsupportedWAFACL records an event when the service requests a WAF web ACL, which can't be associated with
+// classic or network load balancers. The event is only recorded again once the annotation changes.
+func (c *Cloud) warnOnUnsupportedWAFACL(service *v1.Service) {
+	acl := service.Annotations[ServiceAnnotationLoadBalancerWAFACLID]
+	if c.updateWarnedAnnotation(service, ServiceAnnotationLoadBalancerWAFACLID, acl, acl != "") {
+		c.recordServiceEvent(service, v1.EventTypeWarning, "UnsupportedWAFWebACL",
+			"Ignoring %s=%s, WAF web ACLs can only be associated with application load balancers", ServiceAnnotationLoadBalancerWAFACLID, acl)
+	}
+}
+
+// ensureLoadBalancerSSLNegotiationPolicies sets the SSL negotiation policy of each TLS listener of a classic load
+// balancer, and removes the policies of listeners that no longer have one.
+func (c *Cloud) ensureLoadBalancerSSLNegotiationPolicies(service *v1.Service, loadBalancer *elb.LoadBalancerDescription) error {
+	policies := getSSLNegotiationPolicies(service.Annotations)
+	if policies == nil {
+		return nil
+	}
+	c.warnOnUnknownSSLNegotiationPolicies(service, policies)
 
+	loadBalancerName := aws.StringValue(loadBalancer.LoadBalancerName)
+	ensuredPolicies := sets.NewString()
 	for _, listenerDescription := range loadBalancer.ListenerDescriptions {
 		protocol := aws.StringValue(listenerDescription.Listener.Protocol)
-		if protocol == "SSL" || protocol == "HTTPS" {
-			ports = append(ports, aws.Int64Value(listenerDescription.Listener.LoadBalancerPort))
+		if protocol != "SSL" && protocol != "HTTPS" {
+			continue
+		}
+		port := aws.Int64Value(listenerDescription.Listener.LoadBalancerPort)
+		sslPolicyName := policies.forLoadBalancerPort(service.Spec.Ports, port)
+		if sslPolicyName == "" {
+			if err := c.removeSSLNegotiationPolicies(loadBalancerName, port, listenerDescription.PolicyNames); err != nil {
+				return err
+			}
+			continue
+		}
+		if !ensuredPolicies.Has(sslPolicyName) {
+			if err := c.ensureSSLNegotiationPolicy(loadBalancer, sslPolicyName); err != nil {
+				return err
+			}
+			ensuredPolicies.Insert(sslPolicyName)
+		}
+		if err := c.setSSLNegotiationPolicy(loadBalancerName, sslPolicyName, port); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeSSLNegotiationPolicies removes the SSL negotiation policies set on a listener, keeping its other policies
+func (c *Cloud) removeSSLNegotiationPolicies(loadBalancerName string, port int64, policyNames []*string) error {
+	keep := []*string{}
+	for _, policyName := range policyNames {
+		if !strings.HasPrefix(aws.StringValue(policyName), fmt.Sprintf(SSLNegotiationPolicyNameFormat, "")) {
+			keep = append(keep, policyName)
 		}
 	}
-	return ports
+	if len(keep) == len(policyNames) {
+		return nil
+	}
+	klog.V(2).Infof("Removing SSL negotiation policy from port %d of load balancer %s", port, loadBalancerName)
+	_, err := c.elb.SetLoadBalancerPoliciesOfListener(&elb.SetLoadBalancerPoliciesOfListenerInput{
+		LoadBalancerName: aws.String(loadBalancerName),
+		LoadBalancerPort: aws.Int64(port),
+		PolicyNames:      keep,
+	})
+	if err != nil {
+		return fmt.Errorf("error removing SSL negotiation policy from load balancer: %q", err)
+	}
+	return nil
 }
 
 func (c *Cloud) ensureSSLNegotiationPolicy(loadBalancer *elb.LoadBalancerDescription, policyName string) error {
@@ -1508,16 +2881,10 @@ func (c *Cloud) ensureSSLNegotiationPolicy(loadBalancer *elb.LoadBalancerDescrip
 		},
 	})
 	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok {
-			switch aerr.Code() {
-			case elb.ErrCodePolicyNotFoundException:
-			default:
-				return fmt.Errorf("error describing security policies on load balancer: %q", err)
-			}
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != elb.ErrCodePolicyNotFoundException {
+			return fmt.Errorf("error describing security policies on load balancer: %q", err)
 		}
-	}
-
-	if len(result.PolicyDescriptions) > 0 {
+	} else if len(result.PolicyDescriptions) > 0 {
 		return nil
 	}
 
@@ -1613,10 +2980,15 @@ func proxyProtocolEnabled(backend *elb.BackendServerDescription) bool {
 // and we ignore instances which are not found
 func (c *Cloud) findInstancesForELB(ctx context.Context, nodes []*v1.Node, annotations map[string]string) (map[InstanceID]*ec2types.Instance, error) {
 
-	targetNodes := filterTargetNodes(nodes, annotations)
+	targetNodes := filterInstanceNodes(filterTargetNodes(nodes, annotations))
+	if isNLB(annotations) {
+		targetNodes = filterReadyNodes(targetNodes)
+	} else if c.cfg.GetCordonedNodeDeregistrationEnabled() {
+		targetNodes = filterSchedulableNodes(targetNodes)
+	}
 
 	// Map to instance ids ignoring Nodes where we cannot find the id (but logging)
-	instanceIDs := mapToAWSInstanceIDsTolerant(targetNodes)
+	instanceIDs := mapToAWSInstanceIDsTolerant(c.instanceIDResolver, targetNodes)
 
 	cacheCriteria := cacheCriteria{
 		MaxAge:       defaultEC2InstanceCacheMaxAge,
@@ -1633,6 +3005,77 @@ func (c *Cloud) findInstancesForELB(ctx context.Context, nodes []*v1.Node, annot
 	return instances, nil
 }
 
+// filterInstanceNodes skips the nodes that aren't backed by EC2 instances, like Fargate nodes, which can't be
+// registered with the ELB
+func filterInstanceNodes(nodes []*v1.Node) []*v1.Node {
+	instanceNodes := make([]*v1.Node, 0, len(nodes))
+	var skipped []string
+	for _, node := range nodes {
+		if instanceID, err := KubernetesInstanceID(node.Spec.ProviderID).MapToAWSInstanceID(); err == nil && variant.IsVariantNode(string(instanceID)) {
+			skipped = append(skipped, node.Name)
+			continue
+		}
+		instanceNodes = append(instanceNodes, node)
+	}
+	if len(skipped) > 0 {
+		klog.Infof("Skipping nodes that are not EC2 instances as load balancer backends: %v", skipped)
+	}
+	return instanceNodes
+}
+
+// filterReadyNodes skips the nodes that aren't Ready or report an unavailable network, so NLBs don't register
+// targets that fail their health checks until kube-proxy serves the node ports. When no node is ready all nodes are
+// kept, NLBs route to all targets when none of them is healthy.
+func filterReadyNodes(nodes []*v1.Node) []*v1.Node {
+	readyNodes := make([]*v1.Node, 0, len(nodes))
+	var skipped []string
+	for _, node := range nodes {
+		if nodeReadyStatus(node) != v1.ConditionTrue || nodeNetworkUnavailable(node) {
+			skipped = append(skipped, node.Name)
+			continue
+		}
+		readyNodes = append(readyNodes, node)
+	}
+	if len(readyNodes) == 0 {
+		return nodes
+	}
+	if len(skipped) > 0 {
+		klog.Infof("Skipping nodes that are not ready as NLB targets: %v", skipped)
+	}
+	return readyNodes
+}
+
+// filterSchedulableNodes skips the nodes that are cordoned, so classic ELBs stop sending traffic to them before they
+// are drained. When every node is cordoned all nodes are kept, so the load balancer keeps serving.
+func filterSchedulableNodes(nodes []*v1.Node) []*v1.Node {
+	schedulableNodes := make([]*v1.Node, 0, len(nodes))
+	var skipped []string
+	for _, node := range nodes {
+		if node.Spec.Unschedulable {
+			skipped = append(skipped, node.Name)
+			continue
+		}
+		schedulableNodes = append(schedulableNodes, node)
+	}
+	if len(schedulableNodes) == 0 {
+		return nodes
+	}
+	if len(skipped) > 0 {
+		klog.Infof("Skipping cordoned nodes as ELB instances: %v", skipped)
+	}
+	return schedulableNodes
+}
+
+// nodeNetworkUnavailable reports whether the network of the node isn't configured yet
+func nodeNetworkUnavailable(node *v1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == v1.NodeNetworkUnavailable {
+			return condition.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
 // filterTargetNodes uses node labels to filter the nodes that should be targeted by the ELB,
 // checking if all the labels provided in an annotation are present in the nodes
 func filterTargetNodes(nodes []*v1.Node, annotations map[string]string) []*v1.Node {
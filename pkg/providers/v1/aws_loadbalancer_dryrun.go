@@ -0,0 +1,566 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-aws/pkg/providers/v1/iface"
+)
+
+// dryRunMarker is part of the IDs, ARNs and DNS names of the resources that would have been created in a dry run
+const dryRunMarker = "dry-run"
+
+// dryRunARNPrefix prefixes the ARNs of the load balancers, target groups and listeners that would have been created
+// in a dry run, so later reads of them are answered by the dry run client instead of AWS
+const dryRunARNPrefix = "arn:aws:elasticloadbalancing:" + dryRunMarker + ":"
+
+// isDryRunResource returns whether the ARN is of a resource that would have been created in a dry run
+func isDryRunResource(arn *string) bool {
+	return strings.HasPrefix(aws.StringValue(arn), dryRunARNPrefix)
+}
+
+// dryRunELBV2 wraps an ELBV2 client and logs the mutating calls instead of making them. Created resources are
+// returned from the request, so reconciliation computes the full desired state, and reads of them return no
+// listeners, targets or attributes.
+type dryRunELBV2 struct {
+	ELBV2
+
+	// vpcID is the VPC of the load balancers that would have been created
+	vpcID string
+
+	mu           sync.Mutex
+	targetGroups map[string]*elbv2.TargetGroup
+}
+
+var _ ELBV2 = &dryRunELBV2{}
+
+func newDryRunELBV2(elbv2api ELBV2) *dryRunELBV2 {
+	return &dryRunELBV2{ELBV2: elbv2api, targetGroups: map[string]*elbv2.TargetGroup{}}
+}
+
+func (d *dryRunELBV2) AddTags(input *elbv2.AddTagsInput) (*elbv2.AddTagsOutput, error) {
+	klog.Infof("Dry run, not adding tags to %v: %v", aws.StringValueSlice(input.ResourceArns), input.Tags)
+	return &elbv2.AddTagsOutput{}, nil
+}
+
+func (d *dryRunELBV2) DescribeTags(input *elbv2.DescribeTagsInput) (*elbv2.DescribeTagsOutput, error) {
+	var arns []*string
+	output := &elbv2.DescribeTagsOutput{}
+	for _, arn := range input.ResourceArns {
+		if isDryRunResource(arn) {
+			output.TagDescriptions = append(output.TagDescriptions, &elbv2.TagDescription{ResourceArn: arn})
+		} else {
+			arns = append(arns, arn)
+		}
+	}
+	if len(arns) == 0 {
+		return output, nil
+	}
+	request := *input
+	request.ResourceArns = arns
+	response, err := d.ELBV2.DescribeTags(&request)
+	if err != nil {
+		return nil, err
+	}
+	response.TagDescriptions = append(response.TagDescriptions, output.TagDescriptions...)
+	return response, nil
+}
+
+func (d *dryRunELBV2) CreateLoadBalancer(input *elbv2.CreateLoadBalancerInput) (*elbv2.CreateLoadBalancerOutput, error) {
+	klog.Infof("Dry run, not creating load balancer %q: %v", aws.StringValue(input.Name), input)
+	loadBalancer := &elbv2.LoadBalancer{
+		LoadBalancerArn:  aws.String(fmt.Sprintf("%sloadbalancer/net/%s", dryRunARNPrefix, aws.StringValue(input.Name))),
+		LoadBalancerName: input.Name,
+		DNSName:          aws.String(fmt.Sprintf("%s.%s", aws.StringValue(input.Name), dryRunMarker)),
+		Type:             input.Type,
+		Scheme:           input.Scheme,
+		IpAddressType:    input.IpAddressType,
+		SecurityGroups:   input.SecurityGroups,
+		VpcId:            aws.String(d.vpcID),
+		State:            &elbv2.LoadBalancerState{Code: aws.String(elbv2.LoadBalancerStateEnumProvisioning)},
+	}
+	for _, mapping := range input.SubnetMappings {
+		loadBalancer.AvailabilityZones = append(loadBalancer.AvailabilityZones, &elbv2.AvailabilityZone{SubnetId: mapping.SubnetId})
+	}
+	return &elbv2.CreateLoadBalancerOutput{LoadBalancers: []*elbv2.LoadBalancer{loadBalancer}}, nil
+}
+
+func (d *dryRunELBV2) DeleteLoadBalancer(input *elbv2.DeleteLoadBalancerInput) (*elbv2.DeleteLoadBalancerOutput, error) {
+	klog.Infof("Dry run, not deleting load balancer %q", aws.StringValue(input.LoadBalancerArn))
+	return &elbv2.DeleteLoadBalancerOutput{}, nil
+}
+
+func (d *dryRunELBV2) WaitUntilLoadBalancersDeleted(*elbv2.DescribeLoadBalancersInput) error {
+	return nil
+}
+
+func (d *dryRunELBV2) SetSecurityGroups(input *elbv2.SetSecurityGroupsInput) (*elbv2.SetSecurityGroupsOutput, error) {
+	klog.Infof("Dry run, not setting security groups %v of load balancer %q", aws.StringValueSlice(input.SecurityGroups), aws.StringValue(input.LoadBalancerArn))
+	return &elbv2.SetSecurityGroupsOutput{SecurityGroupIds: input.SecurityGroups}, nil
+}
+
+func (d *dryRunELBV2) ModifyLoadBalancerAttributes(input *elbv2.ModifyLoadBalancerAttributesInput) (*elbv2.ModifyLoadBalancerAttributesOutput, error) {
+	klog.Infof("Dry run, not modifying attributes of load balancer %q: %v", aws.StringValue(input.LoadBalancerArn), input.Attributes)
+	return &elbv2.ModifyLoadBalancerAttributesOutput{Attributes: input.Attributes}, nil
+}
+
+func (d *dryRunELBV2) DescribeLoadBalancerAttributes(input *elbv2.DescribeLoadBalancerAttributesInput) (*elbv2.DescribeLoadBalancerAttributesOutput, error) {
+	if isDryRunResource(input.LoadBalancerArn) {
+		return &elbv2.DescribeLoadBalancerAttributesOutput{}, nil
+	}
+	return d.ELBV2.DescribeLoadBalancerAttributes(input)
+}
+
+func (d *dryRunELBV2) CreateTargetGroup(input *elbv2.CreateTargetGroupInput) (*elbv2.CreateTargetGroupOutput, error) {
+	klog.Infof("Dry run, not creating target group %q: %v", aws.StringValue(input.Name), input)
+	targetGroup := &elbv2.TargetGroup{
+		TargetGroupArn:             aws.String(fmt.Sprintf("%stargetgroup/%s", dryRunARNPrefix, aws.StringValue(input.Name))),
+		TargetGroupName:            input.Name,
+		VpcId:                      input.VpcId,
+		Port:                       input.Port,
+		Protocol:                   input.Protocol,
+		TargetType:                 input.TargetType,
+		HealthCheckIntervalSeconds: input.HealthCheckIntervalSeconds,
+		HealthCheckPath:            input.HealthCheckPath,
+		HealthCheckPort:            input.HealthCheckPort,
+		HealthCheckProtocol:        input.HealthCheckProtocol,
+		HealthyThresholdCount:      input.HealthyThresholdCount,
+		UnhealthyThresholdCount:    input.UnhealthyThresholdCount,
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.targetGroups[aws.StringValue(targetGroup.TargetGroupArn)] = targetGroup
+	return &elbv2.CreateTargetGroupOutput{TargetGroups: []*elbv2.TargetGroup{targetGroup}}, nil
+}
+
+func (d *dryRunELBV2) DescribeTargetGroups(input *elbv2.DescribeTargetGroupsInput) (*elbv2.DescribeTargetGroupsOutput, error) {
+	if isDryRunResource(input.LoadBalancerArn) {
+		return &elbv2.DescribeTargetGroupsOutput{}, nil
+	}
+	var arns []*string
+	output := &elbv2.DescribeTargetGroupsOutput{}
+	d.mu.Lock()
+	for _, arn := range input.TargetGroupArns {
+		if targetGroup, ok := d.targetGroups[aws.StringValue(arn)]; ok {
+			output.TargetGroups = append(output.TargetGroups, targetGroup)
+		} else {
+			arns = append(arns, arn)
+		}
+	}
+	d.mu.Unlock()
+	if len(input.TargetGroupArns) > 0 && len(arns) == 0 {
+		return output, nil
+	}
+	request := *input
+	request.TargetGroupArns = arns
+	response, err := d.ELBV2.DescribeTargetGroups(&request)
+	if err != nil {
+		return nil, err
+	}
+	response.TargetGroups = append(response.TargetGroups, output.TargetGroups...)
+	return response, nil
+}
+
+func (d *dryRunELBV2) ModifyTargetGroup(input *elbv2.ModifyTargetGroupInput) (*elbv2.ModifyTargetGroupOutput, error) {
+	klog.Infof("Dry run, not modifying target group %q: %v", aws.StringValue(input.TargetGroupArn), input)
+	return &elbv2.ModifyTargetGroupOutput{}, nil
+}
+
+func (d *dryRunELBV2) DeleteTargetGroup(input *elbv2.DeleteTargetGroupInput) (*elbv2.DeleteTargetGroupOutput, error) {
+	klog.Infof("Dry run, not deleting target group %q", aws.StringValue(input.TargetGroupArn))
+	return &elbv2.DeleteTargetGroupOutput{}, nil
+}
+
+func (d *dryRunELBV2) DescribeTargetHealth(input *elbv2.DescribeTargetHealthInput) (*elbv2.DescribeTargetHealthOutput, error) {
+	if isDryRunResource(input.TargetGroupArn) {
+		return &elbv2.DescribeTargetHealthOutput{}, nil
+	}
+	return d.ELBV2.DescribeTargetHealth(input)
+}
+
+func (d *dryRunELBV2) DescribeTargetGroupAttributes(input *elbv2.DescribeTargetGroupAttributesInput) (*elbv2.DescribeTargetGroupAttributesOutput, error) {
+	if isDryRunResource(input.TargetGroupArn) {
+		return &elbv2.DescribeTargetGroupAttributesOutput{}, nil
+	}
+	return d.ELBV2.DescribeTargetGroupAttributes(input)
+}
+
+func (d *dryRunELBV2) ModifyTargetGroupAttributes(input *elbv2.ModifyTargetGroupAttributesInput) (*elbv2.ModifyTargetGroupAttributesOutput, error) {
+	klog.Infof("Dry run, not modifying attributes of target group %q: %v", aws.StringValue(input.TargetGroupArn), input.Attributes)
+	return &elbv2.ModifyTargetGroupAttributesOutput{Attributes: input.Attributes}, nil
+}
+
+func (d *dryRunELBV2) RegisterTargets(input *elbv2.RegisterTargetsInput) (*elbv2.RegisterTargetsOutput, error) {
+	klog.Infof("Dry run, not registering targets with target group %q: %v", aws.StringValue(input.TargetGroupArn), input.Targets)
+	return &elbv2.RegisterTargetsOutput{}, nil
+}
+
+func (d *dryRunELBV2) DeregisterTargets(input *elbv2.DeregisterTargetsInput) (*elbv2.DeregisterTargetsOutput, error) {
+	klog.Infof("Dry run, not deregistering targets from target group %q: %v", aws.StringValue(input.TargetGroupArn), input.Targets)
+	return &elbv2.DeregisterTargetsOutput{}, nil
+}
+
+func (d *dryRunELBV2) CreateListener(input *elbv2.CreateListenerInput) (*elbv2.CreateListenerOutput, error) {
+	klog.Infof("Dry run, not creating listener on load balancer %q: %v", aws.StringValue(input.LoadBalancerArn), input)
+	listener := &elbv2.Listener{
+		ListenerArn:     aws.String(fmt.Sprintf("%slistener/%s/%d", dryRunARNPrefix, aws.StringValue(input.LoadBalancerArn), aws.Int64Value(input.Port))),
+		LoadBalancerArn: input.LoadBalancerArn,
+		Port:            input.Port,
+		Protocol:        input.Protocol,
+		SslPolicy:       input.SslPolicy,
+		Certificates:    input.Certificates,
+		DefaultActions:  input.DefaultActions,
+	}
+	return &elbv2.CreateListenerOutput{Listeners: []*elbv2.Listener{listener}}, nil
+}
+
+func (d *dryRunELBV2) DescribeListeners(input *elbv2.DescribeListenersInput) (*elbv2.DescribeListenersOutput, error) {
+	if isDryRunResource(input.LoadBalancerArn) {
+		return &elbv2.DescribeListenersOutput{}, nil
+	}
+	return d.ELBV2.DescribeListeners(input)
+}
+
+func (d *dryRunELBV2) DeleteListener(input *elbv2.DeleteListenerInput) (*elbv2.DeleteListenerOutput, error) {
+	klog.Infof("Dry run, not deleting listener %q", aws.StringValue(input.ListenerArn))
+	return &elbv2.DeleteListenerOutput{}, nil
+}
+
+func (d *dryRunELBV2) ModifyListener(input *elbv2.ModifyListenerInput) (*elbv2.ModifyListenerOutput, error) {
+	klog.Infof("Dry run, not modifying listener %q: %v", aws.StringValue(input.ListenerArn), input)
+	listener := &elbv2.Listener{
+		ListenerArn:    input.ListenerArn,
+		Port:           input.Port,
+		Protocol:       input.Protocol,
+		SslPolicy:      input.SslPolicy,
+		Certificates:   input.Certificates,
+		DefaultActions: input.DefaultActions,
+	}
+	return &elbv2.ModifyListenerOutput{Listeners: []*elbv2.Listener{listener}}, nil
+}
+
+// dryRunELB wraps an ELB client and logs the mutating calls instead of making them. Load balancers that would have
+// been created are described from the create request, so the rest of the reconciliation can be computed.
+type dryRunELB struct {
+	ELB
+
+	mu            sync.Mutex
+	loadBalancers map[string]*elb.LoadBalancerDescription
+}
+
+var _ ELB = &dryRunELB{}
+
+func newDryRunELB(elbapi ELB) *dryRunELB {
+	return &dryRunELB{ELB: elbapi, loadBalancers: map[string]*elb.LoadBalancerDescription{}}
+}
+
+// dryRunLoadBalancer returns the load balancer that would have been created with the name
+func (d *dryRunELB) dryRunLoadBalancer(name *string) (*elb.LoadBalancerDescription, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	loadBalancer, ok := d.loadBalancers[aws.StringValue(name)]
+	return loadBalancer, ok
+}
+
+func (d *dryRunELB) CreateLoadBalancer(input *elb.CreateLoadBalancerInput) (*elb.CreateLoadBalancerOutput, error) {
+	klog.Infof("Dry run, not creating load balancer %q: %v", aws.StringValue(input.LoadBalancerName), input)
+	dnsName := aws.String(fmt.Sprintf("%s.%s", aws.StringValue(input.LoadBalancerName), dryRunMarker))
+	loadBalancer := &elb.LoadBalancerDescription{
+		LoadBalancerName:  input.LoadBalancerName,
+		DNSName:           dnsName,
+		Scheme:            input.Scheme,
+		Subnets:           input.Subnets,
+		SecurityGroups:    input.SecurityGroups,
+		AvailabilityZones: input.AvailabilityZones,
+	}
+	for _, listener := range input.Listeners {
+		loadBalancer.ListenerDescriptions = append(loadBalancer.ListenerDescriptions, &elb.ListenerDescription{Listener: listener})
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.loadBalancers[aws.StringValue(input.LoadBalancerName)] = loadBalancer
+	return &elb.CreateLoadBalancerOutput{DNSName: dnsName}, nil
+}
+
+func (d *dryRunELB) DeleteLoadBalancer(input *elb.DeleteLoadBalancerInput) (*elb.DeleteLoadBalancerOutput, error) {
+	klog.Infof("Dry run, not deleting load balancer %q", aws.StringValue(input.LoadBalancerName))
+	return &elb.DeleteLoadBalancerOutput{}, nil
+}
+
+func (d *dryRunELB) DescribeLoadBalancers(input *elb.DescribeLoadBalancersInput) (*elb.DescribeLoadBalancersOutput, error) {
+	var names []*string
+	output := &elb.DescribeLoadBalancersOutput{}
+	for _, name := range input.LoadBalancerNames {
+		if loadBalancer, ok := d.dryRunLoadBalancer(name); ok {
+			output.LoadBalancerDescriptions = append(output.LoadBalancerDescriptions, loadBalancer)
+		} else {
+			names = append(names, name)
+		}
+	}
+	if len(input.LoadBalancerNames) > 0 && len(names) == 0 {
+		return output, nil
+	}
+	request := *input
+	request.LoadBalancerNames = names
+	response, err := d.ELB.DescribeLoadBalancers(&request)
+	if err != nil {
+		return nil, err
+	}
+	response.LoadBalancerDescriptions = append(response.LoadBalancerDescriptions, output.LoadBalancerDescriptions...)
+	return response, nil
+}
+
+func (d *dryRunELB) AddTags(input *elb.AddTagsInput) (*elb.AddTagsOutput, error) {
+	klog.Infof("Dry run, not adding tags to load balancers %v: %v", aws.StringValueSlice(input.LoadBalancerNames), input.Tags)
+	return &elb.AddTagsOutput{}, nil
+}
+
+func (d *dryRunELB) RemoveTags(input *elb.RemoveTagsInput) (*elb.RemoveTagsOutput, error) {
+	klog.Infof("Dry run, not removing tags from load balancers %v: %v", aws.StringValueSlice(input.LoadBalancerNames), input.Tags)
+	return &elb.RemoveTagsOutput{}, nil
+}
+
+func (d *dryRunELB) DescribeTags(input *elb.DescribeTagsInput) (*elb.DescribeTagsOutput, error) {
+	var names []*string
+	output := &elb.DescribeTagsOutput{}
+	for _, name := range input.LoadBalancerNames {
+		if _, ok := d.dryRunLoadBalancer(name); ok {
+			output.TagDescriptions = append(output.TagDescriptions, &elb.TagDescription{LoadBalancerName: name})
+		} else {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return output, nil
+	}
+	request := *input
+	request.LoadBalancerNames = names
+	response, err := d.ELB.DescribeTags(&request)
+	if err != nil {
+		return nil, err
+	}
+	response.TagDescriptions = append(response.TagDescriptions, output.TagDescriptions...)
+	return response, nil
+}
+
+func (d *dryRunELB) RegisterInstancesWithLoadBalancer(input *elb.RegisterInstancesWithLoadBalancerInput) (*elb.RegisterInstancesWithLoadBalancerOutput, error) {
+	klog.Infof("Dry run, not registering instances with load balancer %q: %v", aws.StringValue(input.LoadBalancerName), input.Instances)
+	return &elb.RegisterInstancesWithLoadBalancerOutput{Instances: input.Instances}, nil
+}
+
+func (d *dryRunELB) DeregisterInstancesFromLoadBalancer(input *elb.DeregisterInstancesFromLoadBalancerInput) (*elb.DeregisterInstancesFromLoadBalancerOutput, error) {
+	klog.Infof("Dry run, not deregistering instances from load balancer %q: %v", aws.StringValue(input.LoadBalancerName), input.Instances)
+	return &elb.DeregisterInstancesFromLoadBalancerOutput{}, nil
+}
+
+func (d *dryRunELB) CreateLoadBalancerPolicy(input *elb.CreateLoadBalancerPolicyInput) (*elb.CreateLoadBalancerPolicyOutput, error) {
+	klog.Infof("Dry run, not creating policy %q on load balancer %q: %v", aws.StringValue(input.PolicyName), aws.StringValue(input.LoadBalancerName), input.PolicyAttributes)
+	return &elb.CreateLoadBalancerPolicyOutput{}, nil
+}
+
+func (d *dryRunELB) SetLoadBalancerPoliciesForBackendServer(input *elb.SetLoadBalancerPoliciesForBackendServerInput) (*elb.SetLoadBalancerPoliciesForBackendServerOutput, error) {
+	klog.Infof("Dry run, not setting policies %v of instance port %d on load balancer %q",
+		aws.StringValueSlice(input.PolicyNames), aws.Int64Value(input.InstancePort), aws.StringValue(input.LoadBalancerName))
+	return &elb.SetLoadBalancerPoliciesForBackendServerOutput{}, nil
+}
+
+func (d *dryRunELB) SetLoadBalancerPoliciesOfListener(input *elb.SetLoadBalancerPoliciesOfListenerInput) (*elb.SetLoadBalancerPoliciesOfListenerOutput, error) {
+	klog.Infof("Dry run, not setting policies %v of listener port %d on load balancer %q",
+		aws.StringValueSlice(input.PolicyNames), aws.Int64Value(input.LoadBalancerPort), aws.StringValue(input.LoadBalancerName))
+	return &elb.SetLoadBalancerPoliciesOfListenerOutput{}, nil
+}
+
+func (d *dryRunELB) DescribeLoadBalancerPolicies(input *elb.DescribeLoadBalancerPoliciesInput) (*elb.DescribeLoadBalancerPoliciesOutput, error) {
+	if _, ok := d.dryRunLoadBalancer(input.LoadBalancerName); ok {
+		return &elb.DescribeLoadBalancerPoliciesOutput{}, nil
+	}
+	return d.ELB.DescribeLoadBalancerPolicies(input)
+}
+
+func (d *dryRunELB) DetachLoadBalancerFromSubnets(input *elb.DetachLoadBalancerFromSubnetsInput) (*elb.DetachLoadBalancerFromSubnetsOutput, error) {
+	klog.Infof("Dry run, not detaching load balancer %q from subnets %v", aws.StringValue(input.LoadBalancerName), aws.StringValueSlice(input.Subnets))
+	return &elb.DetachLoadBalancerFromSubnetsOutput{}, nil
+}
+
+func (d *dryRunELB) AttachLoadBalancerToSubnets(input *elb.AttachLoadBalancerToSubnetsInput) (*elb.AttachLoadBalancerToSubnetsOutput, error) {
+	klog.Infof("Dry run, not attaching load balancer %q to subnets %v", aws.StringValue(input.LoadBalancerName), aws.StringValueSlice(input.Subnets))
+	return &elb.AttachLoadBalancerToSubnetsOutput{}, nil
+}
+
+func (d *dryRunELB) CreateLoadBalancerListeners(input *elb.CreateLoadBalancerListenersInput) (*elb.CreateLoadBalancerListenersOutput, error) {
+	klog.Infof("Dry run, not creating listeners on load balancer %q: %v", aws.StringValue(input.LoadBalancerName), input.Listeners)
+	return &elb.CreateLoadBalancerListenersOutput{}, nil
+}
+
+func (d *dryRunELB) DeleteLoadBalancerListeners(input *elb.DeleteLoadBalancerListenersInput) (*elb.DeleteLoadBalancerListenersOutput, error) {
+	klog.Infof("Dry run, not deleting listeners on ports %v of load balancer %q", aws.Int64ValueSlice(input.LoadBalancerPorts), aws.StringValue(input.LoadBalancerName))
+	return &elb.DeleteLoadBalancerListenersOutput{}, nil
+}
+
+func (d *dryRunELB) SetLoadBalancerListenerSSLCertificate(input *elb.SetLoadBalancerListenerSSLCertificateInput) (*elb.SetLoadBalancerListenerSSLCertificateOutput, error) {
+	klog.Infof("Dry run, not setting SSL certificate %q on port %d of load balancer %q", aws.StringValue(input.SSLCertificateId), aws.Int64Value(input.LoadBalancerPort), aws.StringValue(input.LoadBalancerName))
+	return &elb.SetLoadBalancerListenerSSLCertificateOutput{}, nil
+}
+
+func (d *dryRunELB) ApplySecurityGroupsToLoadBalancer(input *elb.ApplySecurityGroupsToLoadBalancerInput) (*elb.ApplySecurityGroupsToLoadBalancerOutput, error) {
+	klog.Infof("Dry run, not applying security groups %v to load balancer %q", aws.StringValueSlice(input.SecurityGroups), aws.StringValue(input.LoadBalancerName))
+	return &elb.ApplySecurityGroupsToLoadBalancerOutput{SecurityGroups: input.SecurityGroups}, nil
+}
+
+func (d *dryRunELB) ConfigureHealthCheck(input *elb.ConfigureHealthCheckInput) (*elb.ConfigureHealthCheckOutput, error) {
+	klog.Infof("Dry run, not configuring health check of load balancer %q: %v", aws.StringValue(input.LoadBalancerName), input.HealthCheck)
+	return &elb.ConfigureHealthCheckOutput{HealthCheck: input.HealthCheck}, nil
+}
+
+func (d *dryRunELB) DescribeLoadBalancerAttributes(input *elb.DescribeLoadBalancerAttributesInput) (*elb.DescribeLoadBalancerAttributesOutput, error) {
+	if _, ok := d.dryRunLoadBalancer(input.LoadBalancerName); ok {
+		return &elb.DescribeLoadBalancerAttributesOutput{LoadBalancerAttributes: &elb.LoadBalancerAttributes{}}, nil
+	}
+	return d.ELB.DescribeLoadBalancerAttributes(input)
+}
+
+func (d *dryRunELB) ModifyLoadBalancerAttributes(input *elb.ModifyLoadBalancerAttributesInput) (*elb.ModifyLoadBalancerAttributesOutput, error) {
+	klog.Infof("Dry run, not modifying attributes of load balancer %q: %v", aws.StringValue(input.LoadBalancerName), input.LoadBalancerAttributes)
+	return &elb.ModifyLoadBalancerAttributesOutput{
+		LoadBalancerName:       input.LoadBalancerName,
+		LoadBalancerAttributes: input.LoadBalancerAttributes,
+	}, nil
+}
+
+// dryRunEC2 wraps an EC2 client and logs the changes load balancer reconciliation makes to security groups and
+// network interfaces instead of making them. Other calls, e.g. for routes and instance tags, are passed through.
+type dryRunEC2 struct {
+	iface.EC2
+
+	mu             sync.Mutex
+	securityGroups map[string]ec2types.SecurityGroup
+}
+
+var _ iface.EC2 = &dryRunEC2{}
+
+func newDryRunEC2(ec2api iface.EC2) *dryRunEC2 {
+	return &dryRunEC2{EC2: ec2api, securityGroups: map[string]ec2types.SecurityGroup{}}
+}
+
+func (d *dryRunEC2) DescribeSecurityGroups(ctx context.Context, request *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) ([]ec2types.SecurityGroup, error) {
+	var groupIDs []string
+	var securityGroups []ec2types.SecurityGroup
+	d.mu.Lock()
+	for _, groupID := range request.GroupIds {
+		if securityGroup, ok := d.securityGroups[groupID]; ok {
+			securityGroups = append(securityGroups, securityGroup)
+		} else {
+			groupIDs = append(groupIDs, groupID)
+		}
+	}
+	d.mu.Unlock()
+	if len(request.GroupIds) > 0 && len(groupIDs) == 0 {
+		return securityGroups, nil
+	}
+	input := *request
+	input.GroupIds = groupIDs
+	response, err := d.EC2.DescribeSecurityGroups(ctx, &input, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	return append(response, securityGroups...), nil
+}
+
+func (d *dryRunEC2) CreateSecurityGroup(ctx context.Context, request *ec2.CreateSecurityGroupInput, optFns ...func(*ec2.Options)) (*ec2.CreateSecurityGroupOutput, error) {
+	klog.Infof("Dry run, not creating security group %q in %q", aws.StringValue(request.GroupName), aws.StringValue(request.VpcId))
+	securityGroup := ec2types.SecurityGroup{
+		GroupId:     aws.String(fmt.Sprintf("sg-%s-%s", dryRunMarker, aws.StringValue(request.GroupName))),
+		GroupName:   request.GroupName,
+		Description: request.Description,
+		VpcId:       request.VpcId,
+	}
+	for _, tagSpecification := range request.TagSpecifications {
+		securityGroup.Tags = append(securityGroup.Tags, tagSpecification.Tags...)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.securityGroups[aws.StringValue(securityGroup.GroupId)] = securityGroup
+	return &ec2.CreateSecurityGroupOutput{GroupId: securityGroup.GroupId, Tags: securityGroup.Tags}, nil
+}
+
+func (d *dryRunEC2) DeleteSecurityGroup(ctx context.Context, request *ec2.DeleteSecurityGroupInput, optFns ...func(*ec2.Options)) (*ec2.DeleteSecurityGroupOutput, error) {
+	klog.Infof("Dry run, not deleting security group %q", aws.StringValue(request.GroupId))
+	return &ec2.DeleteSecurityGroupOutput{}, nil
+}
+
+func (d *dryRunEC2) AuthorizeSecurityGroupIngress(ctx context.Context, request *ec2.AuthorizeSecurityGroupIngressInput, optFns ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	klog.Infof("Dry run, not authorizing ingress to security group %q: %s", aws.StringValue(request.GroupId), ipPermissionsString(request.IpPermissions))
+	return &ec2.AuthorizeSecurityGroupIngressOutput{}, nil
+}
+
+func (d *dryRunEC2) RevokeSecurityGroupIngress(ctx context.Context, request *ec2.RevokeSecurityGroupIngressInput, optFns ...func(*ec2.Options)) (*ec2.RevokeSecurityGroupIngressOutput, error) {
+	klog.Infof("Dry run, not revoking ingress to security group %q: %s", aws.StringValue(request.GroupId), ipPermissionsString(request.IpPermissions))
+	return &ec2.RevokeSecurityGroupIngressOutput{}, nil
+}
+
+func (d *dryRunEC2) ModifyNetworkInterfaceAttribute(ctx context.Context, request *ec2.ModifyNetworkInterfaceAttributeInput, optFns ...func(*ec2.Options)) (*ec2.ModifyNetworkInterfaceAttributeOutput, error) {
+	klog.Infof("Dry run, not setting security groups of network interface %q to %v", aws.StringValue(request.NetworkInterfaceId), request.Groups)
+	return &ec2.ModifyNetworkInterfaceAttributeOutput{}, nil
+}
+
+// CreateTags logs tags for security groups, and passes other tags through
+func (d *dryRunEC2) CreateTags(ctx context.Context, request *ec2.CreateTagsInput, optFns ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error) {
+	if !allSecurityGroups(request.Resources) {
+		return d.EC2.CreateTags(ctx, request, optFns...)
+	}
+	klog.Infof("Dry run, not creating tags on %v: %v", request.Resources, request.Tags)
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+// DeleteTags logs tags for security groups, and passes other tags through
+func (d *dryRunEC2) DeleteTags(ctx context.Context, request *ec2.DeleteTagsInput, optFns ...func(*ec2.Options)) (*ec2.DeleteTagsOutput, error) {
+	if !allSecurityGroups(request.Resources) {
+		return d.EC2.DeleteTags(ctx, request, optFns...)
+	}
+	klog.Infof("Dry run, not deleting tags from %v: %v", request.Resources, request.Tags)
+	return &ec2.DeleteTagsOutput{}, nil
+}
+
+// allSecurityGroups returns whether all the resource IDs are of security groups
+func allSecurityGroups(resources []string) bool {
+	for _, resource := range resources {
+		if !strings.HasPrefix(resource, "sg-") {
+			return false
+		}
+	}
+	return len(resources) > 0
+}
+
+// ipPermissionsString returns a readable representation of the permissions for logging
+func ipPermissionsString(permissions []ec2types.IpPermission) string {
+	keys := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		keys = append(keys, keyForIPPermission(permission))
+	}
+	return strings.Join(keys, ", ")
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/cloud-provider-aws/pkg/providers/v1/config"
+)
+
+// elbv2CallRecorder records the calls that reach the wrapped ELBV2 client by API name
+type elbv2CallRecorder struct {
+	ELBV2
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (r *elbv2CallRecorder) record(api string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls[api]++
+}
+
+func (r *elbv2CallRecorder) mutatingCalls() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var mutating []string
+	for api := range r.calls {
+		switch api {
+		case "DescribeLoadBalancers", "DescribeListeners", "DescribeTargetGroups", "DescribeTargetHealth",
+			"DescribeTargetGroupAttributes", "DescribeLoadBalancerAttributes", "DescribeTags":
+		default:
+			mutating = append(mutating, api)
+		}
+	}
+	return mutating
+}
+
+func (r *elbv2CallRecorder) AddTags(input *elbv2.AddTagsInput) (*elbv2.AddTagsOutput, error) {
+	r.record("AddTags")
+	return r.ELBV2.AddTags(input)
+}
+
+func (r *elbv2CallRecorder) CreateLoadBalancer(input *elbv2.CreateLoadBalancerInput) (*elbv2.CreateLoadBalancerOutput, error) {
+	r.record("CreateLoadBalancer")
+	return r.ELBV2.CreateLoadBalancer(input)
+}
+
+func (r *elbv2CallRecorder) DescribeLoadBalancers(input *elbv2.DescribeLoadBalancersInput) (*elbv2.DescribeLoadBalancersOutput, error) {
+	r.record("DescribeLoadBalancers")
+	return r.ELBV2.DescribeLoadBalancers(input)
+}
+
+func (r *elbv2CallRecorder) ModifyLoadBalancerAttributes(input *elbv2.ModifyLoadBalancerAttributesInput) (*elbv2.ModifyLoadBalancerAttributesOutput, error) {
+	r.record("ModifyLoadBalancerAttributes")
+	return r.ELBV2.ModifyLoadBalancerAttributes(input)
+}
+
+func (r *elbv2CallRecorder) CreateTargetGroup(input *elbv2.CreateTargetGroupInput) (*elbv2.CreateTargetGroupOutput, error) {
+	r.record("CreateTargetGroup")
+	return r.ELBV2.CreateTargetGroup(input)
+}
+
+func (r *elbv2CallRecorder) DescribeTargetGroups(input *elbv2.DescribeTargetGroupsInput) (*elbv2.DescribeTargetGroupsOutput, error) {
+	r.record("DescribeTargetGroups")
+	return r.ELBV2.DescribeTargetGroups(input)
+}
+
+func (r *elbv2CallRecorder) ModifyTargetGroup(input *elbv2.ModifyTargetGroupInput) (*elbv2.ModifyTargetGroupOutput, error) {
+	r.record("ModifyTargetGroup")
+	return r.ELBV2.ModifyTargetGroup(input)
+}
+
+func (r *elbv2CallRecorder) DeleteTargetGroup(input *elbv2.DeleteTargetGroupInput) (*elbv2.DeleteTargetGroupOutput, error) {
+	r.record("DeleteTargetGroup")
+	return r.ELBV2.DeleteTargetGroup(input)
+}
+
+func (r *elbv2CallRecorder) ModifyTargetGroupAttributes(input *elbv2.ModifyTargetGroupAttributesInput) (*elbv2.ModifyTargetGroupAttributesOutput, error) {
+	r.record("ModifyTargetGroupAttributes")
+	return r.ELBV2.ModifyTargetGroupAttributes(input)
+}
+
+func (r *elbv2CallRecorder) RegisterTargets(input *elbv2.RegisterTargetsInput) (*elbv2.RegisterTargetsOutput, error) {
+	r.record("RegisterTargets")
+	return r.ELBV2.RegisterTargets(input)
+}
+
+func (r *elbv2CallRecorder) DeregisterTargets(input *elbv2.DeregisterTargetsInput) (*elbv2.DeregisterTargetsOutput, error) {
+	r.record("DeregisterTargets")
+	return r.ELBV2.DeregisterTargets(input)
+}
+
+func (r *elbv2CallRecorder) CreateListener(input *elbv2.CreateListenerInput) (*elbv2.CreateListenerOutput, error) {
+	r.record("CreateListener")
+	return r.ELBV2.CreateListener(input)
+}
+
+func (r *elbv2CallRecorder) DescribeListeners(input *elbv2.DescribeListenersInput) (*elbv2.DescribeListenersOutput, error) {
+	r.record("DescribeListeners")
+	return r.ELBV2.DescribeListeners(input)
+}
+
+func (r *elbv2CallRecorder) DeleteListener(input *elbv2.DeleteListenerInput) (*elbv2.DeleteListenerOutput, error) {
+	r.record("DeleteListener")
+	return r.ELBV2.DeleteListener(input)
+}
+
+func (r *elbv2CallRecorder) ModifyListener(input *elbv2.ModifyListenerInput) (*elbv2.ModifyListenerOutput, error) {
+	r.record("ModifyListener")
+	return r.ELBV2.ModifyListener(input)
+}
+
+// recordDryRunCalls records the ELBV2 calls of a dry run cloud that reach the fake client
+func recordDryRunCalls(t *testing.T, c *Cloud) *elbv2CallRecorder {
+	dryRun, ok := c.elbv2.(*dryRunELBV2)
+	require.True(t, ok, "expected a dry run ELBV2 client, got %T", c.elbv2)
+	recorder := &elbv2CallRecorder{ELBV2: dryRun.ELBV2, calls: map[string]int{}}
+	dryRun.ELBV2 = recorder
+	return recorder
+}
+
+func TestLoadBalancerDryRunCreate(t *testing.T) {
+	cfg := config.CloudConfig{}
+	cfg.Global.EnableLoadBalancerDryRun = true
+	c, awsServices, nodes := newMockedNLBCloudWithConfig(t, cfg)
+	require.IsType(t, &dryRunEC2{}, c.ec2)
+	require.IsType(t, &dryRunELB{}, c.elb)
+	recorder := recordDryRunCalls(t, c)
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+
+	svc := newNLBService(map[string]string{
+		ServiceAnnotationLoadBalancerHealthCheckProtocol:           "HTTP",
+		ServiceAnnotationLoadBalancerCrossZoneLoadBalancingEnabled: "true",
+	})
+	status, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+
+	// The desired load balancer is returned without creating anything
+	require.Len(t, status.Ingress, 1)
+	assert.Contains(t, status.Ingress[0].Hostname, dryRunMarker)
+	assert.Empty(t, recorder.mutatingCalls())
+	assert.Empty(t, elbv2Mock.LoadBalancers)
+	assert.Empty(t, elbv2Mock.TargetGroups)
+	assert.Empty(t, elbv2Mock.Listeners)
+	assert.Empty(t, elbv2Mock.RegisteredInstances)
+
+	// The existing state is still read
+	assert.NotZero(t, recorder.calls["DescribeLoadBalancers"])
+	assert.NotEmpty(t, awsServices.ec2.(*MockedFakeEC2).Calls)
+}
+
+func TestLoadBalancerDryRunUpdate(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+
+	svc := newNLBService(map[string]string{})
+	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	require.Len(t, elbv2Mock.Listeners, 1)
+	require.Len(t, elbv2Mock.TargetGroups, 1)
+	listenerARN := aws.StringValue(elbv2Mock.Listeners[0].ListenerArn)
+	targetGroupARN := aws.StringValue(elbv2Mock.TargetGroups[0].TargetGroupArn)
+	modifyAttributesCalls := len(elbv2Mock.ModifyLoadBalancerAttributesInputs)
+
+	cfg := config.CloudConfig{}
+	cfg.Global.EnableLoadBalancerDryRun = true
+	dryRunCloud, err := newAWSCloud(cfg, awsServices)
+	require.NoError(t, err)
+	recorder := recordDryRunCalls(t, dryRunCloud)
+
+	// Changing the port and health check would replace the listener and target group, and removing a node would
+	// deregister it
+	svc.Spec.Ports[0].Port = 9090
+	svc.Annotations[ServiceAnnotationLoadBalancerHealthCheckProtocol] = "HTTP"
+	svc.Annotations[ServiceAnnotationLoadBalancerCrossZoneLoadBalancingEnabled] = "true"
+	_, err = dryRunCloud.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes[:2])
+	require.NoError(t, err)
+
+	assert.Empty(t, recorder.mutatingCalls())
+	assert.NotZero(t, recorder.calls["DescribeListeners"])
+	assert.NotZero(t, recorder.calls["DescribeTargetGroups"])
+	require.Len(t, elbv2Mock.Listeners, 1)
+	require.Len(t, elbv2Mock.TargetGroups, 1)
+	assert.Equal(t, listenerARN, aws.StringValue(elbv2Mock.Listeners[0].ListenerArn))
+	assert.Equal(t, int64(8080), aws.Int64Value(elbv2Mock.Listeners[0].Port))
+	assert.Equal(t, targetGroupARN, aws.StringValue(elbv2Mock.TargetGroups[0].TargetGroupArn))
+	assert.Len(t, elbv2Mock.RegisteredInstances[targetGroupARN], 3)
+	assert.Len(t, elbv2Mock.ModifyLoadBalancerAttributesInputs, modifyAttributesCalls)
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// toBeDeletedTaint is the taint the cluster autoscaler adds to nodes it is about to delete, the service controller
+// doesn't pass these nodes to the load balancers
+const toBeDeletedTaint = "ToBeDeletedByClusterAutoscaler"
+
+// setNLBInstanceTargetsInformers watches the readiness of nodes, to register nodes with the target groups of NLBs
+// with instance targets once they become ready and deregister them when they stop being ready. The service
+// controller only syncs load balancers when nodes are added or removed, not when their readiness changes.
+func (c *Cloud) setNLBInstanceTargetsInformers() {
+	c.nlbInstanceTargetsQueue = workqueue.NewTypedRateLimitingQueueWithConfig(
+		workqueue.DefaultTypedControllerRateLimiter[string](),
+		workqueue.TypedRateLimitingQueueConfig[string]{Name: "nlb-instance-targets"},
+	)
+	c.nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: c.enqueueNodeReadinessChange,
+	})
+	if c.cfg.GetCordonedNodeDeregistrationEnabled() {
+		c.nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			UpdateFunc: c.enqueueNodeSchedulabilityChange,
+		})
+	}
+
+	if c.stopCh != nil {
+		go c.runNLBInstanceTargetsWorker(c.stopCh)
+	}
+}
+
+// enqueueNodeReadinessChange queues the services of NLBs with instance targets for a sync when a node becomes
+// ready or not ready
+func (c *Cloud) enqueueNodeReadinessChange(oldObj, newObj interface{}) {
+	oldNode, ok := oldObj.(*v1.Node)
+	if !ok {
+		return
+	}
+	newNode, ok := newObj.(*v1.Node)
+	if !ok {
+		return
+	}
+	if nodeReadyStatus(oldNode) == nodeReadyStatus(newNode) && nodeNetworkUnavailable(oldNode) == nodeNetworkUnavailable(newNode) {
+		return
+	}
+	services, err := c.serviceLister.List(labels.Everything())
+	if err != nil {
+		klog.ErrorS(err, "Error listing services to sync NLB targets after readiness change of node", "node", klog.KObj(newNode))
+		return
+	}
+	for _, service := range services {
+		if isNLBWithInstanceTargets(service) {
+			klog.V(4).InfoS("Queuing sync of NLB targets after readiness change of node", "node", klog.KObj(newNode), "service", klog.KObj(service))
+			c.nlbInstanceTargetsQueue.Add(service.Namespace + "/" + service.Name)
+		}
+	}
+}
+
+// enqueueNodeSchedulabilityChange queues the services of classic ELBs for a sync when a node is cordoned or
+// uncordoned, the service controller doesn't sync load balancers on these changes
+func (c *Cloud) enqueueNodeSchedulabilityChange(oldObj, newObj interface{}) {
+	oldNode, ok := oldObj.(*v1.Node)
+	if !ok {
+		return
+	}
+	newNode, ok := newObj.(*v1.Node)
+	if !ok {
+		return
+	}
+	if oldNode.Spec.Unschedulable == newNode.Spec.Unschedulable {
+		return
+	}
+	services, err := c.serviceLister.List(labels.Everything())
+	if err != nil {
+		klog.ErrorS(err, "Error listing services to sync ELB instances after node was cordoned or uncordoned", "node", klog.KObj(newNode))
+		return
+	}
+	for _, service := range services {
+		if isClassicELB(service) {
+			klog.V(4).InfoS("Queuing sync of ELB instances after node was cordoned or uncordoned", "node", klog.KObj(newNode), "service", klog.KObj(service))
+			c.nlbInstanceTargetsQueue.Add(service.Namespace + "/" + service.Name)
+		}
+	}
+}
+
+// isClassicELB reports whether the service has a classic ELB managed by the cloud provider
+func isClassicELB(service *v1.Service) bool {
+	return service.Spec.Type == v1.ServiceTypeLoadBalancer && !isNLB(service.Annotations) && !isLBExternal(service.Annotations)
+}
+
+// isNLBWithInstanceTargets reports whether the service has an NLB that targets the instances of the nodes
+func isNLBWithInstanceTargets(service *v1.Service) bool {
+	return service.Spec.Type == v1.ServiceTypeLoadBalancer && isNLB(service.Annotations) && !isLBExternal(service.Annotations) &&
+		service.Annotations[ServiceAnnotationLoadBalancerNLBTargetType] != elbv2.TargetTypeEnumIp
+}
+
+func (c *Cloud) runNLBInstanceTargetsWorker(stopCh <-chan struct{}) {
+	defer c.nlbInstanceTargetsQueue.ShutDown()
+
+	if !cache.WaitForNamedCacheSync("nlb-instance-targets", stopCh, c.serviceListerSynced, c.nodeInformerHasSynced) {
+		return
+	}
+	go wait.Until(func() {
+		for c.processNextNLBInstanceTargetsItem() {
+		}
+	}, time.Second, stopCh)
+	<-stopCh
+}
+
+func (c *Cloud) processNextNLBInstanceTargetsItem() bool {
+	key, quit := c.nlbInstanceTargetsQueue.Get()
+	if quit {
+		return false
+	}
+	defer c.nlbInstanceTargetsQueue.Done(key)
+
+	if err := c.syncNLBInstanceTargets(context.Background(), key); err != nil {
+		klog.ErrorS(err, "Error syncing load balancer targets of service, requeuing", "service", key)
+		c.nlbInstanceTargetsQueue.AddRateLimited(key)
+		return true
+	}
+	c.nlbInstanceTargetsQueue.Forget(key)
+	return true
+}
+
+// syncNLBInstanceTargets registers the ready nodes with the instance target groups of the NLB of the service, or the
+// schedulable nodes with the classic ELB of the service when cordoned nodes are deregistered. Only the targets are
+// reconciled, the service controller syncs the rest of the load balancer. Load balancers that don't exist yet are
+// skipped, EnsureLoadBalancer registers their targets.
+func (c *Cloud) syncNLBInstanceTargets(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	service, err := c.serviceLister.Services(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	nlb := isNLBWithInstanceTargets(service)
+	if !nlb && !(isClassicELB(service) && c.cfg.GetCordonedNodeDeregistrationEnabled()) {
+		return nil
+	}
+
+	unlock := c.lockLoadBalancer(service)
+	defer unlock()
+	loadBalancerName, err := c.serviceLoadBalancerName(ctx, c.tagging.clusterID(), service)
+	if err != nil {
+		return err
+	}
+	nodes, err := c.nodeInformer.Lister().List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	instances, err := c.findInstancesForELB(ctx, loadBalancerNodes(nodes), service.Annotations)
+	if err != nil {
+		return err
+	}
+
+	if !nlb {
+		loadBalancer, err := c.describeLoadBalancer(loadBalancerName)
+		if err != nil || loadBalancer == nil {
+			return err
+		}
+		return c.ensureLoadBalancerInstances(ctx, loadBalancerName, loadBalancer.Instances, instances)
+	}
+	loadBalancer, err := c.describeLoadBalancerv2(loadBalancerName)
+	if err != nil || loadBalancer == nil {
+		return err
+	}
+	targetGroups, err := c.elbv2.DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{
+		LoadBalancerArn: loadBalancer.LoadBalancerArn,
+	})
+	if err != nil {
+		return fmt.Errorf("error listing target groups: %q", err)
+	}
+	instanceIDs := make([]string, 0, len(instances))
+	for id := range instances {
+		instanceIDs = append(instanceIDs, string(id))
+	}
+	for _, targetGroup := range targetGroups.TargetGroups {
+		if aws.StringValue(targetGroup.TargetType) != elbv2.TargetTypeEnumInstance {
+			continue
+		}
+		tgARN := aws.StringValue(targetGroup.TargetGroupArn)
+		actualTargets, err := c.obtainTargetGroupActualTargets(tgARN)
+		if err != nil {
+			return err
+		}
+		// The instances are registered on the port of the target group, the traffic port of the service port
+		expectedTargets := c.computeTargetGroupExpectedTargets(instanceIDs, aws.Int64Value(targetGroup.Port))
+		if err := c.ensureTargetGroupTargets(ctx, tgARN, expectedTargets, actualTargets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadBalancerNodes returns the nodes the service controller passes to load balancers, without the nodes excluded
+// from load balancers and the nodes the cluster autoscaler is about to delete
+func loadBalancerNodes(nodes []*v1.Node) []*v1.Node {
+	var included []*v1.Node
+	for _, node := range nodes {
+		if _, excluded := node.Labels[v1.LabelNodeExcludeBalancers]; excluded || !node.DeletionTimestamp.IsZero() {
+			continue
+		}
+		tainted := false
+		for _, taint := range node.Spec.Taints {
+			if taint.Key == toBeDeletedTaint {
+				tainted = true
+				break
+			}
+		}
+		if !tainted {
+			included = append(included, node)
+		}
+	}
+	return included
+}
@@ -0,0 +1,267 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog/v2"
+)
+
+// getNLBTargetType returns the target type of the NLB target groups from the service annotations
+func getNLBTargetType(annotations map[string]string) (string, error) {
+	switch targetType := annotations[ServiceAnnotationLoadBalancerNLBTargetType]; targetType {
+	case "", elbv2.TargetTypeEnumInstance:
+		return elbv2.TargetTypeEnumInstance, nil
+	case elbv2.TargetTypeEnumIp:
+		return elbv2.TargetTypeEnumIp, nil
+	default:
+		return "", fmt.Errorf("unsupported value %q for annotation %s, expected %q or %q",
+			targetType, ServiceAnnotationLoadBalancerNLBTargetType, elbv2.TargetTypeEnumInstance, elbv2.TargetTypeEnumIp)
+	}
+}
+
+// nlbIPTargetPort returns the port of target groups of target type ip, the target port of the service port, e.g. 8443
+// for a listener on port 443. Named target ports can differ between pods, so the targets are registered with the port
+// the endpoint slices resolved from the pod spec and the service port is used for the target group.
+func nlbIPTargetPort(port v1.ServicePort) int64 {
+	if port.TargetPort.Type == intstr.Int && port.TargetPort.IntVal != 0 {
+		return int64(port.TargetPort.IntVal)
+	}
+	return int64(port.Port)
+}
+
+// setNLBIPTargetsInformers watches the endpoint slices of services, to keep the pod IPs registered with their NLB
+// target groups up to date between service updates.
+func (c *Cloud) setNLBIPTargetsInformers(informerFactory informers.SharedInformerFactory) {
+	serviceInformer := informerFactory.Core().V1().Services()
+	c.serviceLister = serviceInformer.Lister()
+	c.serviceListerSynced = serviceInformer.Informer().HasSynced
+
+	endpointSliceInformer := informerFactory.Discovery().V1().EndpointSlices()
+	c.endpointSliceLister = endpointSliceInformer.Lister()
+	c.endpointSliceListerSynced = endpointSliceInformer.Informer().HasSynced
+
+	c.nlbIPTargetsQueue = workqueue.NewTypedRateLimitingQueueWithConfig(
+		workqueue.DefaultTypedControllerRateLimiter[string](),
+		workqueue.TypedRateLimitingQueueConfig[string]{Name: "nlb-ip-targets"},
+	)
+	endpointSliceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueEndpointSliceService,
+		UpdateFunc: func(_, newObj interface{}) {
+			c.enqueueEndpointSliceService(newObj)
+		},
+		DeleteFunc: c.enqueueEndpointSliceService,
+	})
+
+	if c.stopCh != nil {
+		go c.runNLBIPTargetsWorker(c.stopCh)
+	}
+}
+
+// enqueueEndpointSliceService queues the service owning the endpoint slice for a sync of its NLB IP targets
+func (c *Cloud) enqueueEndpointSliceService(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	endpointSlice, ok := obj.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return
+	}
+	serviceName := endpointSlice.Labels[discoveryv1.LabelServiceName]
+	if serviceName == "" {
+		return
+	}
+	c.nlbIPTargetsQueue.Add(endpointSlice.Namespace + "/" + serviceName)
+}
+
+func (c *Cloud) runNLBIPTargetsWorker(stopCh <-chan struct{}) {
+	defer c.nlbIPTargetsQueue.ShutDown()
+
+	if !cache.WaitForNamedCacheSync("nlb-ip-targets", stopCh, c.serviceListerSynced, c.endpointSliceListerSynced) {
+		return
+	}
+	go wait.Until(func() {
+		for c.processNextNLBIPTargetsItem() {
+		}
+	}, time.Second, stopCh)
+	<-stopCh
+}
+
+func (c *Cloud) processNextNLBIPTargetsItem() bool {
+	key, quit := c.nlbIPTargetsQueue.Get()
+	if quit {
+		return false
+	}
+	defer c.nlbIPTargetsQueue.Done(key)
+
+	if err := c.syncNLBIPTargets(context.Background(), key); err != nil {
+		klog.Errorf("Error syncing NLB IP targets of service %s, requeuing: %v", key, err)
+		c.nlbIPTargetsQueue.AddRateLimited(key)
+		return true
+	}
+	c.nlbIPTargetsQueue.Forget(key)
+	return true
+}
+
+// syncNLBIPTargets registers the ready pod IPs of the service with the target groups of its NLB, when the NLB
+// targets pod IPs. Load balancers that don't exist yet are skipped, EnsureLoadBalancer registers their targets.
+func (c *Cloud) syncNLBIPTargets(ctx context.Context, key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return err
+	}
+	service, err := c.serviceLister.Services(namespace).Get(name)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if service.Spec.Type != v1.ServiceTypeLoadBalancer || !isNLB(service.Annotations) ||
+		service.Annotations[ServiceAnnotationLoadBalancerNLBTargetType] != elbv2.TargetTypeEnumIp {
+		return nil
+	}
+
+	unlock := c.lockLoadBalancer(service)
+	defer unlock()
+	loadBalancerName, err := c.serviceLoadBalancerName(ctx, c.tagging.clusterID(), service)
+	if err != nil {
+		return err
+	}
+	loadBalancer, err := c.describeLoadBalancerv2(loadBalancerName)
+	if err != nil {
+		return err
+	}
+	if loadBalancer == nil {
+		return nil
+	}
+	targetGroups, err := c.elbv2.DescribeTargetGroups(&elbv2.DescribeTargetGroupsInput{
+		LoadBalancerArn: loadBalancer.LoadBalancerArn,
+	})
+	if err != nil {
+		return fmt.Errorf("error listing target groups: %q", err)
+	}
+	ipTargetGroups := sets.NewString()
+	for _, targetGroup := range targetGroups.TargetGroups {
+		if aws.StringValue(targetGroup.TargetType) == elbv2.TargetTypeEnumIp {
+			ipTargetGroups.Insert(aws.StringValue(targetGroup.TargetGroupArn))
+		}
+	}
+	// Service ports are matched with their target group through the listener on the service port, several service
+	// ports can target the same port
+	listeners, err := c.elbv2.DescribeListeners(&elbv2.DescribeListenersInput{
+		LoadBalancerArn: loadBalancer.LoadBalancerArn,
+	})
+	if err != nil {
+		return fmt.Errorf("error listing listeners: %q", err)
+	}
+	listenerTargetGroups := map[int64]string{}
+	for _, listener := range listeners.Listeners {
+		if len(listener.DefaultActions) > 0 {
+			listenerTargetGroups[aws.Int64Value(listener.Port)] = aws.StringValue(listener.DefaultActions[0].TargetGroupArn)
+		}
+	}
+
+	synced := sets.NewString()
+	for _, port := range service.Spec.Ports {
+		tgARN := listenerTargetGroups[int64(port.Port)]
+		if !ipTargetGroups.Has(tgARN) || synced.Has(tgARN) {
+			continue
+		}
+		synced.Insert(tgARN)
+		expectedTargets, err := c.computeTargetGroupExpectedIPTargets(service, port)
+		if err != nil {
+			return err
+		}
+		actualTargets, err := c.obtainTargetGroupActualTargets(tgARN)
+		if err != nil {
+			return err
+		}
+		if err := c.ensureTargetGroupTargets(ctx, tgARN, expectedTargets, actualTargets); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// computeTargetGroupExpectedIPTargets returns the IPs of the ready endpoints of the service port, with the port
+// they serve it on. It returns an error until the endpoint slices are synced, so that a partial cache doesn't
+// deregister the pods that aren't listed yet; the sync is retried.
+func (c *Cloud) computeTargetGroupExpectedIPTargets(service *v1.Service, servicePort v1.ServicePort) ([]*elbv2.TargetDescription, error) {
+	if c.endpointSliceLister == nil {
+		return nil, fmt.Errorf("endpoint slices are not available to find the pod IPs of service %s/%s", service.Namespace, service.Name)
+	}
+	if !c.endpointSliceListerSynced() {
+		return nil, fmt.Errorf("endpoint slices are not synced yet to find the pod IPs of service %s/%s", service.Namespace, service.Name)
+	}
+	endpointSlices, err := c.endpointSliceLister.EndpointSlices(service.Namespace).List(
+		labels.SelectorFromSet(labels.Set{discoveryv1.LabelServiceName: service.Name}))
+	if err != nil {
+		return nil, fmt.Errorf("error listing endpoint slices of service %s/%s: %q", service.Namespace, service.Name, err)
+	}
+
+	targets := map[string]*elbv2.TargetDescription{}
+	for _, endpointSlice := range endpointSlices {
+		// The target groups are created with the default IPv4 address type
+		if endpointSlice.AddressType != discoveryv1.AddressTypeIPv4 {
+			continue
+		}
+		var targetPort *int32
+		for _, port := range endpointSlice.Ports {
+			if aws.StringValue(port.Name) == servicePort.Name {
+				targetPort = port.Port
+				break
+			}
+		}
+		if targetPort == nil {
+			continue
+		}
+		for _, endpoint := range endpointSlice.Endpoints {
+			// A nil ready condition means the endpoint is ready
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			for _, address := range endpoint.Addresses {
+				targets[fmt.Sprintf("%s:%d", address, *targetPort)] = &elbv2.TargetDescription{
+					Id:   aws.String(address),
+					Port: aws.Int64(int64(*targetPort)),
+				}
+			}
+		}
+	}
+
+	expectedTargets := make([]*elbv2.TargetDescription, 0, len(targets))
+	for _, target := range targets {
+		expectedTargets = append(expectedTargets, target)
+	}
+	return expectedTargets, nil
+}
@@ -20,18 +20,23 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
 
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/elb"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"k8s.io/cloud-provider-aws/pkg/providers/v1/config"
 )
@@ -220,6 +225,7 @@ func TestSyncElbListeners(t *testing.T) {
 		listenerDescriptions []*elb.ListenerDescription
 		toCreate             []*elb.Listener
 		toDelete             []*int64
+		toUpdate             []*elb.Listener
 	}{
 		{
 			name:             "no edge cases",
@@ -288,13 +294,51 @@ func TestSyncElbListeners(t *testing.T) {
 				{InstancePort: aws.Int64(443), InstanceProtocol: aws.String("HTTP"), LoadBalancerPort: aws.Int64(443), Protocol: aws.String("HTTP")},
 			},
 		},
+		{
+			name:             "certificate changed",
+			loadBalancerName: "lb_five",
+			listeners: []*elb.Listener{
+				{InstancePort: aws.Int64(30443), InstanceProtocol: aws.String("HTTP"), LoadBalancerPort: aws.Int64(443), Protocol: aws.String("HTTPS"), SSLCertificateId: aws.String("def-456")},
+				{InstancePort: aws.Int64(30080), InstanceProtocol: aws.String("HTTP"), LoadBalancerPort: aws.Int64(80), Protocol: aws.String("HTTP")},
+			},
+			listenerDescriptions: []*elb.ListenerDescription{
+				{Listener: &elb.Listener{InstancePort: aws.Int64(30443), InstanceProtocol: aws.String("HTTP"), LoadBalancerPort: aws.Int64(443), Protocol: aws.String("HTTPS"), SSLCertificateId: aws.String("abc-123")}},
+				{Listener: &elb.Listener{InstancePort: aws.Int64(30080), InstanceProtocol: aws.String("HTTP"), LoadBalancerPort: aws.Int64(80), Protocol: aws.String("HTTP")}},
+			},
+			toDelete: []*int64{},
+			toCreate: []*elb.Listener{},
+			toUpdate: []*elb.Listener{
+				{InstancePort: aws.Int64(30443), InstanceProtocol: aws.String("HTTP"), LoadBalancerPort: aws.Int64(443), Protocol: aws.String("HTTPS"), SSLCertificateId: aws.String("def-456")},
+			},
+		},
+		{
+			name:             "certificate and instance port changed",
+			loadBalancerName: "lb_six",
+			listeners: []*elb.Listener{
+				{InstancePort: aws.Int64(31443), InstanceProtocol: aws.String("HTTP"), LoadBalancerPort: aws.Int64(443), Protocol: aws.String("HTTPS"), SSLCertificateId: aws.String("def-456")},
+			},
+			listenerDescriptions: []*elb.ListenerDescription{
+				{Listener: &elb.Listener{InstancePort: aws.Int64(30443), InstanceProtocol: aws.String("HTTP"), LoadBalancerPort: aws.Int64(443), Protocol: aws.String("HTTPS"), SSLCertificateId: aws.String("abc-123")}},
+			},
+			toDelete: []*int64{
+				aws.Int64(443),
+			},
+			toCreate: []*elb.Listener{
+				{InstancePort: aws.Int64(31443), InstanceProtocol: aws.String("HTTP"), LoadBalancerPort: aws.Int64(443), Protocol: aws.String("HTTPS"), SSLCertificateId: aws.String("def-456")},
+			},
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			additions, removals := syncElbListeners(test.loadBalancerName, test.listeners, test.listenerDescriptions)
+			additions, removals, certificateUpdates := syncElbListeners(test.loadBalancerName, test.listeners, test.listenerDescriptions)
 			assert.Equal(t, additions, test.toCreate)
 			assert.Equal(t, removals, test.toDelete)
+			if test.toUpdate == nil {
+				assert.Empty(t, certificateUpdates)
+			} else {
+				assert.Equal(t, certificateUpdates, test.toUpdate)
+			}
 		})
 	}
 }
@@ -628,6 +672,393 @@ func TestCloud_findInstancesForELB(t *testing.T) {
 	assert.True(t, cacheExpiryNew.After(cacheExpiryOld))
 }
 
+func TestCloud_findInstancesForELBSkipsFargateNodes(t *testing.T) {
+	ec2Node, ec2Instance := makeNodeInstancePair(1)
+	fargateNode := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "fargate-ip-192-168-164-88.us-east-1.compute.internal",
+		},
+		Spec: v1.NodeSpec{
+			ProviderID: "aws:///us-east-1b/1abc-2def/fargate-192.168.164.88",
+		},
+	}
+	awsServices := newMockedFakeAWSServices(TestClusterID)
+	awsServices.instances = append(awsServices.instances, ec2Instance)
+	c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+	assert.NoError(t, err)
+	fakeEC2 := awsServices.ec2.(*MockedFakeEC2).FakeEC2Impl
+	fakeEC2.apiCalls = map[string]int{}
+
+	var instances map[InstanceID]*ec2types.Instance
+	for i := 0; i < 2; i++ {
+		instances, err = c.findInstancesForELB(context.TODO(), []*v1.Node{ec2Node, fargateNode}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, map[InstanceID]*ec2types.Instance{
+			InstanceID(aws.StringValue(ec2Instance.InstanceId)): ec2Instance,
+		}, instances)
+	}
+	// The Fargate node isn't looked up in the instance cache, so it doesn't cause a refresh
+	assert.Equal(t, 1, fakeEC2.apiCalls["DescribeInstances"])
+
+	mockedELB := awsServices.elb.(*MockedFakeELB)
+	mockedELB.On("RegisterInstancesWithLoadBalancer", &elb.RegisterInstancesWithLoadBalancerInput{
+		LoadBalancerName: aws.String("lb"),
+		Instances:        []*elb.Instance{{InstanceId: ec2Instance.InstanceId}},
+	}).Return(&elb.RegisterInstancesWithLoadBalancerOutput{})
+	err = c.ensureLoadBalancerInstances(context.TODO(), "lb", nil, instances)
+	assert.NoError(t, err)
+	mockedELB.AssertExpectations(t)
+}
+
+func TestCloud_findInstancesForELBSkipsCordonedNodes(t *testing.T) {
+	node1, instance1 := makeNodeInstancePair(1)
+	node2, instance2 := makeNodeInstancePair(2)
+	awsServices := NewFakeAWSServices(TestClusterID)
+	awsServices.instances = append(awsServices.instances, instance1, instance2)
+	cfg := config.CloudConfig{}
+	cfg.Global.EnableCordonedNodeDeregistration = true
+	c, err := newAWSCloud(cfg, awsServices)
+	require.NoError(t, err)
+	findInstanceIDs := func(c *Cloud, annotations map[string]string, nodes ...*v1.Node) []InstanceID {
+		instances, err := c.findInstancesForELB(context.TODO(), nodes, annotations)
+		require.NoError(t, err)
+		var ids []InstanceID
+		for id := range instances {
+			ids = append(ids, id)
+		}
+		return ids
+	}
+	id1, id2 := InstanceID(aws.StringValue(instance1.InstanceId)), InstanceID(aws.StringValue(instance2.InstanceId))
+	assert.ElementsMatch(t, []InstanceID{id1, id2}, findInstanceIDs(c, nil, node1, node2))
+
+	// A cordoned node is deregistered from classic ELBs
+	node1.Spec.Unschedulable = true
+	assert.ElementsMatch(t, []InstanceID{id2}, findInstanceIDs(c, nil, node1, node2))
+
+	// NLBs only consider the readiness of nodes
+	assert.ElementsMatch(t, []InstanceID{id1, id2}, findInstanceIDs(c, map[string]string{ServiceAnnotationLoadBalancerType: "nlb"}, node1, node2))
+
+	// All nodes are kept when every node is cordoned
+	node2.Spec.Unschedulable = true
+	assert.ElementsMatch(t, []InstanceID{id1, id2}, findInstanceIDs(c, nil, node1, node2))
+
+	// The node is registered again once it is uncordoned
+	node1.Spec.Unschedulable = false
+	assert.ElementsMatch(t, []InstanceID{id1}, findInstanceIDs(c, nil, node1, node2))
+
+	// Cordoned nodes are kept unless the deregistration is enabled
+	c, err = newAWSCloud(config.CloudConfig{}, awsServices)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []InstanceID{id1, id2}, findInstanceIDs(c, nil, node1, node2))
+}
+
+func TestEnsureLoadBalancerSubnets(t *testing.T) {
+	awsServices := newMockedFakeAWSServices(TestClusterID)
+	c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+	require.NoError(t, err)
+	awsServices.ec2.(*MockedFakeEC2).Subnets = []ec2types.Subnet{
+		{SubnetId: aws.String("subnet-a1"), AvailabilityZone: aws.String("us-west-2a")},
+		{SubnetId: aws.String("subnet-a2"), AvailabilityZone: aws.String("us-west-2a")},
+		{SubnetId: aws.String("subnet-b1"), AvailabilityZone: aws.String("us-west-2b")},
+		{SubnetId: aws.String("subnet-c1"), AvailabilityZone: aws.String("us-west-2c")},
+	}
+	newMockedELB := func() *MockedFakeELB {
+		mockedELB := &MockedFakeELB{FakeELB: &FakeELB{aws: awsServices}}
+		c.elb = mockedELB
+		return mockedELB
+	}
+	attach := func(subnetIDs ...string) *elb.AttachLoadBalancerToSubnetsInput {
+		return &elb.AttachLoadBalancerToSubnetsInput{LoadBalancerName: aws.String("lb"), Subnets: aws.StringSlice(subnetIDs)}
+	}
+	detach := func(subnetIDs ...string) *elb.DetachLoadBalancerFromSubnetsInput {
+		return &elb.DetachLoadBalancerFromSubnetsInput{LoadBalancerName: aws.String("lb"), Subnets: aws.StringSlice(subnetIDs)}
+	}
+	calledMethods := func(mockedELB *MockedFakeELB) []string {
+		var methods []string
+		for _, call := range mockedELB.Calls {
+			methods = append(methods, call.Method)
+		}
+		return methods
+	}
+
+	// The subnet of an added AZ is attached
+	mockedELB := newMockedELB()
+	mockedELB.On("AttachLoadBalancerToSubnets", attach("subnet-c1")).Return(&elb.AttachLoadBalancerToSubnetsOutput{})
+	changed, err := c.ensureLoadBalancerSubnets("lb", []string{"subnet-a1", "subnet-b1"}, []string{"subnet-a1", "subnet-b1", "subnet-c1"})
+	require.NoError(t, err)
+	assert.True(t, changed)
+	mockedELB.AssertExpectations(t)
+
+	// The subnet of a removed AZ is detached
+	mockedELB = newMockedELB()
+	mockedELB.On("DetachLoadBalancerFromSubnets", detach("subnet-c1")).Return(&elb.DetachLoadBalancerFromSubnetsOutput{})
+	changed, err = c.ensureLoadBalancerSubnets("lb", []string{"subnet-a1", "subnet-b1", "subnet-c1"}, []string{"subnet-a1", "subnet-b1"})
+	require.NoError(t, err)
+	assert.True(t, changed)
+	mockedELB.AssertExpectations(t)
+
+	// Subnets in new AZs are attached before the replaced subnets are detached, so the load balancer keeps a subnet
+	mockedELB = newMockedELB()
+	mockedELB.On("AttachLoadBalancerToSubnets", attach("subnet-b1")).Return(&elb.AttachLoadBalancerToSubnetsOutput{})
+	mockedELB.On("DetachLoadBalancerFromSubnets", detach("subnet-a1")).Return(&elb.DetachLoadBalancerFromSubnetsOutput{})
+	mockedELB.On("AttachLoadBalancerToSubnets", attach("subnet-a2")).Return(&elb.AttachLoadBalancerToSubnetsOutput{})
+	_, err = c.ensureLoadBalancerSubnets("lb", []string{"subnet-a1"}, []string{"subnet-a2", "subnet-b1"})
+	require.NoError(t, err)
+	mockedELB.AssertExpectations(t)
+	assert.Equal(t, []string{"AttachLoadBalancerToSubnets", "DetachLoadBalancerFromSubnets", "AttachLoadBalancerToSubnets"}, calledMethods(mockedELB))
+
+	// The only subnet is detached before the subnet replacing it in the same AZ is attached
+	mockedELB = newMockedELB()
+	mockedELB.On("DetachLoadBalancerFromSubnets", detach("subnet-a1")).Return(&elb.DetachLoadBalancerFromSubnetsOutput{})
+	mockedELB.On("AttachLoadBalancerToSubnets", attach("subnet-a2")).Return(&elb.AttachLoadBalancerToSubnetsOutput{})
+	changed, err = c.ensureLoadBalancerSubnets("lb", []string{"subnet-a1"}, []string{"subnet-a2"})
+	require.NoError(t, err)
+	assert.True(t, changed)
+	mockedELB.AssertExpectations(t)
+	assert.Equal(t, []string{"DetachLoadBalancerFromSubnets", "AttachLoadBalancerToSubnets"}, calledMethods(mockedELB))
+
+	// Subnets replaced in their AZs are swapped one sync at a time, keeping a subnet attached
+	mockedELB = newMockedELB()
+	mockedELB.On("DetachLoadBalancerFromSubnets", detach("subnet-b1")).Return(&elb.DetachLoadBalancerFromSubnetsOutput{})
+	changed, err = c.ensureLoadBalancerSubnets("lb", []string{"subnet-a1", "subnet-b1"}, []string{"subnet-a2"})
+	require.NoError(t, err)
+	assert.True(t, changed)
+	mockedELB.AssertExpectations(t)
+	mockedELB = newMockedELB()
+	mockedELB.On("DetachLoadBalancerFromSubnets", detach("subnet-a1")).Return(&elb.DetachLoadBalancerFromSubnetsOutput{})
+	mockedELB.On("AttachLoadBalancerToSubnets", attach("subnet-a2")).Return(&elb.AttachLoadBalancerToSubnetsOutput{})
+	changed, err = c.ensureLoadBalancerSubnets("lb", []string{"subnet-a1"}, []string{"subnet-a2"})
+	require.NoError(t, err)
+	assert.True(t, changed)
+	mockedELB.AssertExpectations(t)
+
+	// The only subnet is kept when no subnet replaces it
+	mockedELB = newMockedELB()
+	changed, err = c.ensureLoadBalancerSubnets("lb", []string{"subnet-a1"}, nil)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Empty(t, mockedELB.Calls)
+
+	// Nothing is described when the subnets match
+	describeSubnetsCalls := awsServices.ec2.(*MockedFakeEC2).apiCalls["DescribeSubnets"]
+	changed, err = c.ensureLoadBalancerSubnets("lb", []string{"subnet-a1", "subnet-b1"}, []string{"subnet-b1", "subnet-a1"})
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, describeSubnetsCalls, awsServices.ec2.(*MockedFakeEC2).apiCalls["DescribeSubnets"])
+}
+
+func TestEnsureLoadBalancerEventualConsistency(t *testing.T) {
+	defer func(backoff wait.Backoff) { eventualConsistencyBackoff = backoff }(eventualConsistencyBackoff)
+	eventualConsistencyBackoff = wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3}
+
+	newCloud := func(t *testing.T) (*Cloud, *MockedFakeELB) {
+		awsServices := newMockedFakeAWSServices(TestClusterID)
+		c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+		assert.NoError(t, err)
+		mockedELB := awsServices.elb.(*MockedFakeELB)
+		// The load balancer doesn't exist yet, so it is created
+		mockedELB.On("DescribeLoadBalancers", &elb.DescribeLoadBalancersInput{LoadBalancerNames: []*string{aws.String("lb")}}).Return(&elb.DescribeLoadBalancersOutput{})
+		return c, mockedELB
+	}
+	ensure := func(c *Cloud) error {
+		_, err := c.ensureLoadBalancer(context.TODO(), types.NamespacedName{Namespace: "default", Name: "myservice"}, "lb",
+			[]*elb.Listener{{Protocol: aws.String("TCP"), LoadBalancerPort: aws.Int64(80), InstancePort: aws.Int64(30080)}},
+			[]string{"subnet-new"}, []string{"sg-new"}, false, false, nil, nil)
+		return err
+	}
+
+	t.Run("retries until the subnet is visible", func(t *testing.T) {
+		c, mockedELB := newCloud(t)
+		mockedELB.SetErrorOnCall("CreateLoadBalancer", 1, awserr.New(elb.ErrCodeInvalidSubnetException, "subnet-new does not exist", nil))
+
+		assert.NoError(t, ensure(c))
+		assert.Equal(t, 2, mockedELB.apiCalls["CreateLoadBalancer"])
+	})
+
+	t.Run("gives up when the security group stays invisible", func(t *testing.T) {
+		c, mockedELB := newCloud(t)
+		mockedELB.SetError("CreateLoadBalancer", awserr.New("InvalidSecurityGroupID.NotFound", "sg-new does not exist", nil))
+
+		err := ensure(c)
+		assert.ErrorContains(t, err, "gave up creating load balancer lb")
+		assert.ErrorContains(t, err, "InvalidSecurityGroupID.NotFound")
+		assert.Equal(t, 3, mockedELB.apiCalls["CreateLoadBalancer"])
+	})
+
+	t.Run("doesn't retry other errors", func(t *testing.T) {
+		c, mockedELB := newCloud(t)
+		mockedELB.SetError("CreateLoadBalancer", awserr.New(elb.ErrCodeTooManyAccessPointsException, "too many load balancers", nil))
+
+		err := ensure(c)
+		assert.ErrorContains(t, err, elb.ErrCodeTooManyAccessPointsException)
+		assert.Equal(t, 1, mockedELB.apiCalls["CreateLoadBalancer"])
+	})
+}
+
+func TestEnsureLoadBalancerConnectionIdleTimeout(t *testing.T) {
+	awsServices := newMockedFakeAWSServices(TestClusterID)
+	c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+	require.NoError(t, err)
+	recorder := record.NewFakeRecorder(10)
+	c.eventRecorder = recorder
+	mockedELB := awsServices.elb.(*MockedFakeELB)
+	mockedELB.On("DescribeLoadBalancers", &elb.DescribeLoadBalancersInput{LoadBalancerNames: []*string{aws.String("lb")}}).Return(&elb.DescribeLoadBalancersOutput{})
+
+	service := &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "myservice"}}
+	ensure := func(idleTimeout string) {
+		service.Annotations = map[string]string{ServiceAnnotationLoadBalancerConnectionIdleTimeout: idleTimeout}
+		attributes, err := c.buildELBAttributes(service)
+		require.NoError(t, err)
+		_, err = c.ensureLoadBalancer(context.TODO(), types.NamespacedName{Namespace: "default", Name: "myservice"}, "lb",
+			[]*elb.Listener{{Protocol: aws.String("TCP"), LoadBalancerPort: aws.Int64(80), InstancePort: aws.Int64(30080)}},
+			[]string{"subnet-a"}, []string{"sg-a"}, false, false, attributes, nil)
+		require.NoError(t, err)
+	}
+	lastIdleTimeout := func() int64 {
+		inputs := mockedELB.ModifyLoadBalancerAttributesInputs
+		require.NotEmpty(t, inputs)
+		return aws.Int64Value(inputs[len(inputs)-1].LoadBalancerAttributes.ConnectionSettings.IdleTimeout)
+	}
+
+	ensure("120")
+	assert.Len(t, mockedELB.ModifyLoadBalancerAttributesInputs, 1)
+	assert.Equal(t, int64(120), lastIdleTimeout())
+
+	// Unchanged attributes aren't modified again
+	ensure("120")
+	assert.Len(t, mockedELB.ModifyLoadBalancerAttributesInputs, 1)
+
+	ensure("300")
+	assert.Len(t, mockedELB.ModifyLoadBalancerAttributesInputs, 2)
+	assert.Equal(t, int64(300), lastIdleTimeout())
+	assert.Empty(t, recorder.Events)
+
+	ensure("5000")
+	assert.Len(t, mockedELB.ModifyLoadBalancerAttributesInputs, 3)
+	assert.Equal(t, int64(maxConnectionIdleTimeout), lastIdleTimeout())
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Warning InvalidConnectionIdleTimeout")
+
+	ensure("0")
+	assert.Equal(t, int64(minConnectionIdleTimeout), lastIdleTimeout())
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Warning InvalidConnectionIdleTimeout")
+}
+
+func TestEnsureLoadBalancerTagsOnly(t *testing.T) {
+	awsServices := newMockedFakeAWSServices(TestClusterID)
+	c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+	require.NoError(t, err)
+	mockedELB := awsServices.elb.(*MockedFakeELB)
+	listener := &elb.Listener{Protocol: aws.String("TCP"), LoadBalancerPort: aws.Int64(80), InstanceProtocol: aws.String("TCP"), InstancePort: aws.Int64(30080)}
+	mockedELB.On("DescribeLoadBalancers", &elb.DescribeLoadBalancersInput{LoadBalancerNames: []*string{aws.String("lb")}}).Return(&elb.DescribeLoadBalancersOutput{
+		LoadBalancerDescriptions: []*elb.LoadBalancerDescription{{
+			LoadBalancerName:     aws.String("lb"),
+			Subnets:              aws.StringSlice([]string{"subnet-a"}),
+			SecurityGroups:       aws.StringSlice([]string{"sg-a"}),
+			ListenerDescriptions: []*elb.ListenerDescription{{Listener: listener}},
+		}},
+	})
+	mockedELB.On("DescribeTags", &elb.DescribeTagsInput{LoadBalancerNames: []*string{aws.String("lb")}}).Return(&elb.DescribeTagsOutput{
+		TagDescriptions: []*elb.TagDescription{{
+			LoadBalancerName: aws.String("lb"),
+			Tags: []*elb.Tag{
+				{Key: aws.String("team"), Value: aws.String("a")},
+				{Key: aws.String("env"), Value: aws.String("prod")},
+				{Key: aws.String(TagNameKubernetesAdditionalTags), Value: aws.String("env team")},
+			},
+		}},
+	}, nil)
+	// Only the tag whose value changed is added
+	mockedELB.On("AddTags", &elb.AddTagsInput{
+		LoadBalancerNames: []*string{aws.String("lb")},
+		Tags:              []*elb.Tag{{Key: aws.String("team"), Value: aws.String("b")}},
+	}).Return(&elb.AddTagsOutput{})
+
+	_, err = c.ensureLoadBalancer(context.TODO(), types.NamespacedName{Namespace: "default", Name: "myservice"}, "lb",
+		[]*elb.Listener{listener}, []string{"subnet-a"}, []string{"sg-a"}, false, false, &elb.LoadBalancerAttributes{},
+		map[string]string{ServiceAnnotationLoadBalancerAdditionalTags: "team=b,env=prod"})
+	require.NoError(t, err)
+	mockedELB.AssertExpectations(t)
+
+	var methods []string
+	for _, call := range mockedELB.Calls {
+		methods = append(methods, call.Method)
+	}
+	assert.Equal(t, []string{"DescribeLoadBalancers", "DescribeTags", "AddTags"}, methods)
+	assert.Empty(t, mockedELB.ModifyLoadBalancerAttributesInputs)
+}
+
+func TestEnsureLoadBalancerRemovedTags(t *testing.T) {
+	listener := &elb.Listener{Protocol: aws.String("TCP"), LoadBalancerPort: aws.Int64(80), InstanceProtocol: aws.String("TCP"), InstancePort: aws.Int64(30080)}
+	currentTags := []*elb.Tag{
+		{Key: aws.String("team"), Value: aws.String("a")},
+		{Key: aws.String("env"), Value: aws.String("prod")},
+		{Key: aws.String("owner"), Value: aws.String("ops")},
+		{Key: aws.String(TagNameKubernetesAdditionalTags), Value: aws.String("env stale team")},
+	}
+	for _, tc := range []struct {
+		name        string
+		annotation  string
+		removedTags []string
+		addedTags   []*elb.Tag
+	}{
+		{
+			name:        "tag removed from the annotation",
+			annotation:  "team=a",
+			removedTags: []string{"env"},
+			addedTags:   []*elb.Tag{{Key: aws.String(TagNameKubernetesAdditionalTags), Value: aws.String("team")}},
+		},
+		{
+			name:        "annotation removed",
+			removedTags: []string{"env", "team", TagNameKubernetesAdditionalTags},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			awsServices := newMockedFakeAWSServices(TestClusterID)
+			c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+			require.NoError(t, err)
+			mockedELB := awsServices.elb.(*MockedFakeELB)
+			mockedELB.On("DescribeLoadBalancers", &elb.DescribeLoadBalancersInput{LoadBalancerNames: []*string{aws.String("lb")}}).Return(&elb.DescribeLoadBalancersOutput{
+				LoadBalancerDescriptions: []*elb.LoadBalancerDescription{{
+					LoadBalancerName:     aws.String("lb"),
+					Subnets:              aws.StringSlice([]string{"subnet-a"}),
+					SecurityGroups:       aws.StringSlice([]string{"sg-a"}),
+					ListenerDescriptions: []*elb.ListenerDescription{{Listener: listener}},
+				}},
+			})
+			mockedELB.On("DescribeTags", &elb.DescribeTagsInput{LoadBalancerNames: []*string{aws.String("lb")}}).Return(&elb.DescribeTagsOutput{
+				TagDescriptions: []*elb.TagDescription{{LoadBalancerName: aws.String("lb"), Tags: currentTags}},
+			}, nil)
+			// Only the recorded tags that are still on the load balancer are removed, the owner tag was added by
+			// someone else and is kept
+			var removedTags []*elb.TagKeyOnly
+			for _, k := range tc.removedTags {
+				removedTags = append(removedTags, &elb.TagKeyOnly{Key: aws.String(k)})
+			}
+			mockedELB.On("RemoveTags", &elb.RemoveTagsInput{
+				LoadBalancerNames: []*string{aws.String("lb")},
+				Tags:              removedTags,
+			}).Return(&elb.RemoveTagsOutput{})
+			if tc.addedTags != nil {
+				mockedELB.On("AddTags", &elb.AddTagsInput{
+					LoadBalancerNames: []*string{aws.String("lb")},
+					Tags:              tc.addedTags,
+				}).Return(&elb.AddTagsOutput{})
+			}
+
+			annotations := map[string]string{}
+			if tc.annotation != "" {
+				annotations[ServiceAnnotationLoadBalancerAdditionalTags] = tc.annotation
+			}
+			_, err = c.ensureLoadBalancer(context.TODO(), types.NamespacedName{Namespace: "default", Name: "myservice"}, "lb",
+				[]*elb.Listener{listener}, []string{"subnet-a"}, []string{"sg-a"}, false, false, &elb.LoadBalancerAttributes{}, annotations)
+			require.NoError(t, err)
+			mockedELB.AssertExpectations(t)
+		})
+	}
+}
+
 func TestCloud_chunkTargetDescriptions(t *testing.T) {
 	type args struct {
 		targets   []*elbv2.TargetDescription
@@ -1079,3 +1510,46 @@ func TestCloud_computeTargetGroupExpectedTargets(t *testing.T) {
 		})
 	}
 }
+
+// deregisterTargetsRecorder records the DeregisterTargets calls by target group
+type deregisterTargetsRecorder struct {
+	ELBV2
+	mu    sync.Mutex
+	calls map[string][]*elbv2.DeregisterTargetsInput
+}
+
+func (r *deregisterTargetsRecorder) DeregisterTargets(input *elbv2.DeregisterTargetsInput) (*elbv2.DeregisterTargetsOutput, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	arn := aws.StringValue(input.TargetGroupArn)
+	r.calls[arn] = append(r.calls[arn], input)
+	return &elbv2.DeregisterTargetsOutput{}, nil
+}
+
+func TestDeregisterTargetsBatching(t *testing.T) {
+	recorder := &deregisterTargetsRecorder{calls: map[string][]*elbv2.DeregisterTargetsInput{}}
+	b := newDeregisterTargetsBatcher(context.Background(), recorder, config.DefaultDeregisterTargetsBatchIdleTimeout, nil)
+	defer b.batcher.Close()
+
+	targetGroups := []string{"tg-a", "tg-b"}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := b.DeregisterTargets(context.Background(), &elbv2.DeregisterTargetsInput{
+				TargetGroupArn: aws.String(targetGroups[i%len(targetGroups)]),
+				Targets:        []*elbv2.TargetDescription{{Id: aws.String(fmt.Sprintf("i-%d", i)), Port: aws.Int64(30080)}},
+			})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	// Each target group is deregistered from in a single call with all of its targets
+	require.Len(t, recorder.calls, len(targetGroups))
+	for _, tg := range targetGroups {
+		require.Len(t, recorder.calls[tg], 1, "calls for %s", tg)
+		assert.Len(t, recorder.calls[tg][0].Targets, 25)
+	}
+}
@@ -18,6 +18,9 @@ package aws
 
 import (
 	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
 
 	"k8s.io/component-base/metrics"
 	"k8s.io/component-base/metrics/legacyregistry"
@@ -47,6 +50,22 @@ var (
 			StabilityLevel: metrics.ALPHA,
 		},
 		[]string{"operation_name"})
+
+	awsAPICallsMetric = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Name:           "cloudprovider_aws_api_calls_total",
+			Help:           "AWS API calls by service, API name and result, when API call metrics are enabled",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"service", "operation", "result"})
+
+	awsAPICallDurationMetric = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Name:           "cloudprovider_aws_api_call_duration_seconds",
+			Help:           "Latency of AWS API calls by service and API name, when API call metrics are enabled",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{"service", "operation"})
 )
 
 func recordAWSMetric(actionName string, timeTaken float64, err error) {
@@ -61,6 +80,22 @@ func recordAWSThrottlesMetric(operation string) {
 	awsAPIThrottlesMetric.With(metrics.Labels{"operation_name": operation}).Inc()
 }
 
+// recordAWSCall counts a call to an AWS API along with its result, and records its latency
+func recordAWSCall(service, operation string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	awsAPICallsMetric.With(metrics.Labels{"service": service, "operation": operation, "result": result}).Inc()
+	awsAPICallDurationMetric.With(metrics.Labels{"service": service, "operation": operation}).Observe(time.Since(start).Seconds())
+}
+
+// awsAPICallMetricsHandler records every call made by an AWS SDK v1 client once it completes
+func awsAPICallMetricsHandler(req *request.Request) {
+	service, name := awsServiceAndName(req)
+	recordAWSCall(service, name, req.Time, req.Error)
+}
+
 var registerOnce sync.Once
 
 func registerMetrics() {
@@ -68,5 +103,7 @@ func registerMetrics() {
 		legacyregistry.MustRegister(awsAPIMetric)
 		legacyregistry.MustRegister(awsAPIErrorMetric)
 		legacyregistry.MustRegister(awsAPIThrottlesMetric)
+		legacyregistry.MustRegister(awsAPICallsMetric)
+		legacyregistry.MustRegister(awsAPICallDurationMetric)
 	})
 }
@@ -19,15 +19,103 @@ package aws
 import (
 	"context"
 	"fmt"
+	"net"
 
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go/aws"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog/v2"
+	netutils "k8s.io/utils/net"
 
 	cloudprovider "k8s.io/cloud-provider"
 )
 
+// routeDestinationCIDR returns the destination of a route, AWS reports IPv4 and IPv6 destinations in separate fields
+func routeDestinationCIDR(r ec2types.Route) string {
+	if destinationCIDR := aws.StringValue(r.DestinationCidrBlock); destinationCIDR != "" {
+		return destinationCIDR
+	}
+	return aws.StringValue(r.DestinationIpv6CidrBlock)
+}
+
+// findVPCCIDRs returns the IPv4 and IPv6 CIDR blocks associated with the VPC of the cluster
+func (c *Cloud) findVPCCIDRs(ctx context.Context) ([]string, error) {
+	request := &ec2.DescribeVpcsInput{Filters: []ec2types.Filter{newEc2Filter("vpc-id", c.vpcID)}}
+	response, err := c.ec2.DescribeVpcs(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("error describing VPC %s: %q", c.vpcID, err)
+	}
+
+	cidrs := sets.NewString()
+	for _, vpc := range response.Vpcs {
+		if cidr := aws.StringValue(vpc.CidrBlock); cidr != "" {
+			cidrs.Insert(cidr)
+		}
+		for _, association := range vpc.CidrBlockAssociationSet {
+			if association.CidrBlockState != nil && association.CidrBlockState.State == ec2types.VpcCidrBlockStateCodeAssociated {
+				cidrs.Insert(aws.StringValue(association.CidrBlock))
+			}
+		}
+		for _, association := range vpc.Ipv6CidrBlockAssociationSet {
+			if association.Ipv6CidrBlockState != nil && association.Ipv6CidrBlockState.State == ec2types.VpcCidrBlockStateCodeAssociated {
+				cidrs.Insert(aws.StringValue(association.Ipv6CidrBlock))
+			}
+		}
+	}
+	return cidrs.List(), nil
+}
+
+// cidrsOverlap reports whether two CIDRs share any address, CIDRs of different IP families never overlap
+func cidrsOverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// validateRouteDestination rejects a pod CIDR that overlaps the VPC or a route of the table that doesn't target an
+// instance, creating the route would take traffic away from the VPC or from the gateway of the existing route.
+// Default routes are expected to cover the pod CIDRs and are ignored.
+func (c *Cloud) validateRouteDestination(ctx context.Context, table *ec2types.RouteTable, destinationCIDR string) error {
+	_, destination, err := netutils.ParseCIDRSloppy(destinationCIDR)
+	if err != nil {
+		return fmt.Errorf("invalid route destination %q: %v", destinationCIDR, err)
+	}
+
+	vpcCIDRs, err := c.findVPCCIDRs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, vpcCIDR := range vpcCIDRs {
+		_, vpcNet, err := netutils.ParseCIDRSloppy(vpcCIDR)
+		if err != nil {
+			klog.Warningf("Ignoring invalid CIDR %q of VPC %s: %v", vpcCIDR, c.vpcID, err)
+			continue
+		}
+		if cidrsOverlap(destination, vpcNet) {
+			return fmt.Errorf("pod CIDR %s overlaps CIDR %s of VPC %s", destinationCIDR, vpcCIDR, c.vpcID)
+		}
+	}
+
+	for _, r := range table.Routes {
+		routeCIDR := routeDestinationCIDR(r)
+		// Routes to instances are pod routes, a route with the same destination is replaced by CreateRoute
+		if routeCIDR == "" || routeCIDR == destinationCIDR || aws.StringValue(r.InstanceId) != "" {
+			continue
+		}
+		_, routeNet, err := netutils.ParseCIDRSloppy(routeCIDR)
+		if err != nil {
+			continue
+		}
+		if ones, _ := routeNet.Mask.Size(); ones == 0 {
+			continue
+		}
+		if cidrsOverlap(destination, routeNet) {
+			return fmt.Errorf("pod CIDR %s overlaps existing route to %s in route table %s", destinationCIDR, routeCIDR, aws.StringValue(table.RouteTableId))
+		}
+	}
+	return nil
+}
+
 func (c *Cloud) findRouteTable(ctx context.Context, clusterName string) (*ec2types.RouteTable, error) {
 	// This should be unnecessary (we already filter on TagNameKubernetesCluster,
 	// and something is broken if cluster name doesn't match, but anyway...
@@ -75,7 +163,8 @@ func (c *Cloud) ListRoutes(ctx context.Context, clusterName string) ([]*cloudpro
 	}
 
 	var routes []*cloudprovider.Route
-	var instanceIDs []string
+	// Dual-stack nodes have a route for each family to the same instance
+	instanceIDs := sets.NewString()
 
 	for _, r := range table.Routes {
 		instanceID := aws.StringValue(r.InstanceId)
@@ -84,16 +173,16 @@ func (c *Cloud) ListRoutes(ctx context.Context, clusterName string) ([]*cloudpro
 			continue
 		}
 
-		instanceIDs = append(instanceIDs, instanceID)
+		instanceIDs.Insert(instanceID)
 	}
 
-	instances, err := c.getInstancesByIDs(ctx, instanceIDs)
+	instances, err := c.getInstancesByIDs(ctx, instanceIDs.List())
 	if err != nil {
 		return nil, err
 	}
 
 	for _, r := range table.Routes {
-		destinationCIDR := aws.StringValue(r.DestinationCidrBlock)
+		destinationCIDR := routeDestinationCIDR(r)
 		if destinationCIDR == "" {
 			continue
 		}
@@ -113,23 +202,32 @@ func (c *Cloud) ListRoutes(ctx context.Context, clusterName string) ([]*cloudpro
 		// Capture instance routes
 		instanceID := aws.StringValue(r.InstanceId)
 		if instanceID != "" {
-			_, found := instances[instanceID]
-			if found {
-				node, err := c.instanceIDToNodeName(InstanceID(instanceID))
-				if err != nil {
-					return nil, err
-				}
-				route.TargetNode = node
+			instance, found := instances[instanceID]
+			if !found || isTerminatedInstance(instance) {
+				// The instance was terminated outside Kubernetes, the route is reported as blackholed so that the
+				// route controller removes it on its next sync, even if it never saw the node being deleted
+				klog.Warningf("Route to %s targets instance %s that no longer exists, reporting it as blackholed", destinationCIDR, instanceID)
+				route.Blackhole = true
 				routes = append(routes, route)
-			} else {
-				klog.Warningf("unable to find instance ID %s in the list of instances being routed to", instanceID)
+				continue
+			}
+			node, err := c.instanceIDToNodeName(InstanceID(instanceID))
+			if err != nil {
+				return nil, err
 			}
+			route.TargetNode = node
+			routes = append(routes, route)
 		}
 	}
 
 	return routes, nil
 }
 
+// isTerminatedInstance returns true if the instance was terminated, routes to it can't be used anymore
+func isTerminatedInstance(instance *ec2types.Instance) bool {
+	return instance.State != nil && instance.State.Name == ec2types.InstanceStateNameTerminated
+}
+
 // Sets the instance attribute "source-dest-check" to the specified value
 func (c *Cloud) configureInstanceSourceDestCheck(ctx context.Context, instanceID string, sourceDestCheck bool) error {
 	request := &ec2.ModifyInstanceAttributeInput{}
@@ -151,21 +249,32 @@ func (c *Cloud) CreateRoute(ctx context.Context, clusterName string, nameHint st
 		return err
 	}
 
-	// In addition to configuring the route itself, we also need to configure the instance to accept that traffic
-	// On AWS, this requires turning source-dest checks off
-	err = c.configureInstanceSourceDestCheck(ctx, aws.StringValue(instance.InstanceId), false)
+	table, err := c.findRouteTable(ctx, clusterName)
 	if err != nil {
 		return err
 	}
 
-	table, err := c.findRouteTable(ctx, clusterName)
-	if err != nil {
+	// Refuse to create a route that conflicts with the VPC or an existing route rather than blackholing its traffic
+	if err := c.validateRouteDestination(ctx, table, route.DestinationCIDR); err != nil {
+		c.recordNodeEvent(route.TargetNode, v1.EventTypeWarning, "ConflictingPodCIDR", "Not creating route: %v", err)
 		return err
 	}
 
+	// In addition to configuring the route itself, we also need to configure the instance to accept that traffic
+	// On AWS, this requires turning source-dest checks off
+	if c.cfg.Global.DisableSourceDestCheckManagement {
+		klog.V(4).Infof("Not managing source-dest-check on instance %s", aws.StringValue(instance.InstanceId))
+	} else if instance.SourceDestCheck == nil || *instance.SourceDestCheck {
+		klog.Infof("Disabling source-dest-check on instance %s", aws.StringValue(instance.InstanceId))
+		err = c.configureInstanceSourceDestCheck(ctx, aws.StringValue(instance.InstanceId), false)
+		if err != nil {
+			return err
+		}
+	}
+
 	var deleteRoute *ec2types.Route
 	for _, r := range table.Routes {
-		destinationCIDR := aws.StringValue(r.DestinationCidrBlock)
+		destinationCIDR := routeDestinationCIDR(r)
 
 		if destinationCIDR != route.DestinationCIDR {
 			continue
@@ -177,21 +286,27 @@ func (c *Cloud) CreateRoute(ctx context.Context, clusterName string, nameHint st
 	}
 
 	if deleteRoute != nil {
-		klog.Infof("deleting blackholed route: %s", aws.StringValue(deleteRoute.DestinationCidrBlock))
+		klog.Infof("deleting blackholed route: %s", route.DestinationCIDR)
 
 		request := &ec2.DeleteRouteInput{}
 		request.DestinationCidrBlock = deleteRoute.DestinationCidrBlock
+		request.DestinationIpv6CidrBlock = deleteRoute.DestinationIpv6CidrBlock
 		request.RouteTableId = table.RouteTableId
 
 		_, err = c.ec2.DeleteRoute(ctx, request)
 		if err != nil {
-			return fmt.Errorf("error deleting blackholed AWS route (%s): %q", aws.StringValue(deleteRoute.DestinationCidrBlock), err)
+			return fmt.Errorf("error deleting blackholed AWS route (%s): %q", route.DestinationCIDR, err)
 		}
 	}
 
 	request := &ec2.CreateRouteInput{}
 	// TODO: use ClientToken for idempotency?
-	request.DestinationCidrBlock = aws.String(route.DestinationCIDR)
+	// Dual-stack nodes get a route for each family, with the pod CIDRs of the node as destination
+	if netutils.IsIPv6CIDRString(route.DestinationCIDR) {
+		request.DestinationIpv6CidrBlock = aws.String(route.DestinationCIDR)
+	} else {
+		request.DestinationCidrBlock = aws.String(route.DestinationCIDR)
+	}
 	request.InstanceId = instance.InstanceId
 	request.RouteTableId = table.RouteTableId
 
@@ -212,7 +327,11 @@ func (c *Cloud) DeleteRoute(ctx context.Context, clusterName string, route *clou
 	}
 
 	request := &ec2.DeleteRouteInput{}
-	request.DestinationCidrBlock = aws.String(route.DestinationCIDR)
+	if netutils.IsIPv6CIDRString(route.DestinationCIDR) {
+		request.DestinationIpv6CidrBlock = aws.String(route.DestinationCIDR)
+	} else {
+		request.DestinationCidrBlock = aws.String(route.DestinationCIDR)
+	}
 	request.RouteTableId = table.RouteTableId
 
 	_, err = c.ec2.DeleteRoute(ctx, request)
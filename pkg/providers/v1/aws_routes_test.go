@@ -0,0 +1,251 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"testing"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	cloudprovider "k8s.io/cloud-provider"
+
+	"k8s.io/cloud-provider-aws/pkg/providers/v1/config"
+)
+
+const testRouteTableID = "rtb-0123456789"
+
+// newRoutesTestCloud returns a cloud with a single route table and a node backed by the fake self instance
+func newRoutesTestCloud(t *testing.T, routes ...ec2types.Route) (*Cloud, *FakeEC2Impl, types.NodeName) {
+	awsServices := NewFakeAWSServices(TestClusterID)
+	cfg := config.CloudConfig{}
+	cfg.Global.RouteTableID = testRouteTableID
+	c, err := newAWSCloud(cfg, awsServices)
+	require.NoError(t, err)
+
+	fakeEC2 := awsServices.ec2.(*FakeEC2Impl)
+	fakeEC2.CreateRouteTable(&ec2types.RouteTable{RouteTableId: aws.String(testRouteTableID), Routes: routes})
+
+	nodeName := types.NodeName(aws.StringValue(awsServices.selfInstance.PrivateDnsName))
+	c.kubeClient = fake.NewSimpleClientset()
+	c.SetInformers(informers.NewSharedInformerFactory(c.kubeClient, 0))
+	c.nodeInformer.Informer().GetStore().Add(&v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: string(nodeName)},
+		Spec:       v1.NodeSpec{ProviderID: "aws:///us-west-2a/" + aws.StringValue(awsServices.selfInstance.InstanceId)},
+	})
+	c.nodeInformerHasSynced = informerSynced
+	return c, fakeEC2, nodeName
+}
+
+func TestRoutesPodCIDRFamilies(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		podCIDRs []string
+	}{
+		{
+			name:     "IPv4 only",
+			podCIDRs: []string{"10.0.1.0/24"},
+		},
+		{
+			name:     "IPv6 only",
+			podCIDRs: []string{"2600:1f14:abc:100::/80"},
+		},
+		{
+			name:     "dual-stack",
+			podCIDRs: []string{"10.0.1.0/24", "2600:1f14:abc:100::/80"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, fakeEC2, nodeName := newRoutesTestCloud(t)
+
+			// The route controller creates one route per pod CIDR of the node
+			for _, podCIDR := range tc.podCIDRs {
+				err := c.CreateRoute(context.TODO(), TestClusterName, "", &cloudprovider.Route{TargetNode: nodeName, DestinationCIDR: podCIDR})
+				require.NoError(t, err)
+			}
+			for i, route := range fakeEC2.RouteTables[0].Routes {
+				assert.Equal(t, tc.podCIDRs[i], routeDestinationCIDR(route))
+				assert.Equal(t, "i-self", aws.StringValue(route.InstanceId))
+			}
+
+			routes, err := c.ListRoutes(context.TODO(), TestClusterName)
+			require.NoError(t, err)
+			require.Len(t, routes, len(tc.podCIDRs))
+			for i, route := range routes {
+				assert.Equal(t, tc.podCIDRs[i], route.DestinationCIDR)
+				assert.Equal(t, TestClusterName+"-"+tc.podCIDRs[i], route.Name)
+				assert.Equal(t, nodeName, route.TargetNode)
+			}
+
+			for _, route := range routes {
+				require.NoError(t, c.DeleteRoute(context.TODO(), TestClusterName, route))
+			}
+			assert.Empty(t, fakeEC2.RouteTables[0].Routes)
+		})
+	}
+}
+
+func TestCreateRouteReplacesBlackholedRoutes(t *testing.T) {
+	c, fakeEC2, nodeName := newRoutesTestCloud(t,
+		ec2types.Route{DestinationCidrBlock: aws.String("10.0.1.0/24"), State: ec2types.RouteStateBlackhole},
+		ec2types.Route{DestinationIpv6CidrBlock: aws.String("2600:1f14:abc:100::/80"), State: ec2types.RouteStateBlackhole},
+	)
+
+	routes, err := c.ListRoutes(context.TODO(), TestClusterName)
+	require.NoError(t, err)
+	require.Len(t, routes, 2)
+	for _, route := range routes {
+		assert.True(t, route.Blackhole, "route to %s should be blackholed", route.DestinationCIDR)
+	}
+
+	for _, podCIDR := range []string{"10.0.1.0/24", "2600:1f14:abc:100::/80"} {
+		err := c.CreateRoute(context.TODO(), TestClusterName, "", &cloudprovider.Route{TargetNode: nodeName, DestinationCIDR: podCIDR})
+		require.NoError(t, err)
+	}
+
+	routes, err = c.ListRoutes(context.TODO(), TestClusterName)
+	require.NoError(t, err)
+	require.Len(t, routes, 2)
+	for _, route := range routes {
+		assert.False(t, route.Blackhole, "route to %s should not be blackholed", route.DestinationCIDR)
+		assert.Equal(t, nodeName, route.TargetNode)
+	}
+	assert.Len(t, fakeEC2.RouteTables[0].Routes, 2)
+}
+
+func TestCreateRouteDisablesSourceDestCheck(t *testing.T) {
+	t.Run("disables the check when it is enabled", func(t *testing.T) {
+		c, fakeEC2, nodeName := newRoutesTestCloud(t)
+		fakeEC2.aws.selfInstance.SourceDestCheck = aws.Bool(true)
+
+		err := c.CreateRoute(context.TODO(), TestClusterName, "", &cloudprovider.Route{TargetNode: nodeName, DestinationCIDR: "10.0.1.0/24"})
+		require.NoError(t, err)
+		require.Len(t, fakeEC2.ModifyInstanceAttributeInputs, 1)
+		assert.Equal(t, "i-self", aws.StringValue(fakeEC2.ModifyInstanceAttributeInputs[0].InstanceId))
+		assert.Equal(t, aws.Bool(false), fakeEC2.ModifyInstanceAttributeInputs[0].SourceDestCheck.Value)
+
+		// The check is left alone once it is disabled
+		err = c.CreateRoute(context.TODO(), TestClusterName, "", &cloudprovider.Route{TargetNode: nodeName, DestinationCIDR: "2600:1f14:abc:100::/80"})
+		require.NoError(t, err)
+		assert.Len(t, fakeEC2.ModifyInstanceAttributeInputs, 1)
+	})
+
+	t.Run("leaves the check alone when its management is disabled", func(t *testing.T) {
+		c, fakeEC2, nodeName := newRoutesTestCloud(t)
+		fakeEC2.aws.selfInstance.SourceDestCheck = aws.Bool(true)
+		c.cfg.Global.DisableSourceDestCheckManagement = true
+
+		err := c.CreateRoute(context.TODO(), TestClusterName, "", &cloudprovider.Route{TargetNode: nodeName, DestinationCIDR: "10.0.1.0/24"})
+		require.NoError(t, err)
+		assert.Empty(t, fakeEC2.ModifyInstanceAttributeInputs)
+		assert.Len(t, fakeEC2.RouteTables[0].Routes, 1)
+	})
+}
+
+func TestCreateRouteRejectsConflictingPodCIDRs(t *testing.T) {
+	existingRoutes := []ec2types.Route{
+		{DestinationCidrBlock: aws.String("0.0.0.0/0"), GatewayId: aws.String("igw-0123456789")},
+		{DestinationCidrBlock: aws.String("10.1.0.0/16"), TransitGatewayId: aws.String("tgw-0123456789")},
+		{DestinationCidrBlock: aws.String("10.0.2.0/24"), InstanceId: aws.String("i-other")},
+	}
+	for _, tc := range []struct {
+		name        string
+		podCIDR     string
+		expectedErr string
+	}{
+		{
+			name:        "overlapping the VPC",
+			podCIDR:     "172.20.1.0/24",
+			expectedErr: "overlaps CIDR 172.20.0.0/16 of VPC",
+		},
+		{
+			name:        "containing the VPC",
+			podCIDR:     "172.16.0.0/12",
+			expectedErr: "overlaps CIDR 172.20.0.0/16 of VPC",
+		},
+		{
+			name:        "overlapping a non-pod route",
+			podCIDR:     "10.1.2.0/24",
+			expectedErr: "overlaps existing route to 10.1.0.0/16",
+		},
+		{
+			name:    "only covered by the default route",
+			podCIDR: "10.0.1.0/24",
+		},
+		{
+			name:    "next to a pod route",
+			podCIDR: "10.0.3.0/24",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, fakeEC2, nodeName := newRoutesTestCloud(t, existingRoutes...)
+			recorder := record.NewFakeRecorder(1)
+			c.eventRecorder = recorder
+
+			err := c.CreateRoute(context.TODO(), TestClusterName, "", &cloudprovider.Route{TargetNode: nodeName, DestinationCIDR: tc.podCIDR})
+			if tc.expectedErr == "" {
+				require.NoError(t, err)
+				assert.Len(t, fakeEC2.RouteTables[0].Routes, len(existingRoutes)+1)
+				assert.Empty(t, recorder.Events)
+				return
+			}
+			require.ErrorContains(t, err, tc.expectedErr)
+			assert.Len(t, fakeEC2.RouteTables[0].Routes, len(existingRoutes))
+			require.Len(t, recorder.Events, 1)
+			assert.Contains(t, <-recorder.Events, "Warning ConflictingPodCIDR")
+		})
+	}
+}
+
+func TestListRoutesBlackholesRoutesToTerminatedInstances(t *testing.T) {
+	c, fakeEC2, nodeName := newRoutesTestCloud(t,
+		ec2types.Route{DestinationCidrBlock: aws.String("10.0.1.0/24"), InstanceId: aws.String("i-self"), State: ec2types.RouteStateActive},
+		ec2types.Route{DestinationCidrBlock: aws.String("10.0.2.0/24"), InstanceId: aws.String("i-terminated"), State: ec2types.RouteStateActive},
+		ec2types.Route{DestinationCidrBlock: aws.String("10.0.3.0/24"), InstanceId: aws.String("i-deleted"), State: ec2types.RouteStateActive},
+	)
+	fakeEC2.aws.instances = append(fakeEC2.aws.instances, &ec2types.Instance{
+		InstanceId: aws.String("i-terminated"),
+		State:      &ec2types.InstanceState{Name: ec2types.InstanceStateNameTerminated},
+	})
+
+	routes, err := c.ListRoutes(context.TODO(), TestClusterName)
+	require.NoError(t, err)
+	require.Len(t, routes, 3)
+	assert.False(t, routes[0].Blackhole)
+	assert.Equal(t, nodeName, routes[0].TargetNode)
+	for _, route := range routes[1:] {
+		assert.True(t, route.Blackhole, "route to %s should be blackholed", route.DestinationCIDR)
+		assert.Empty(t, route.TargetNode)
+	}
+
+	// The route controller deletes the blackholed routes
+	for _, route := range routes {
+		if route.Blackhole {
+			require.NoError(t, c.DeleteRoute(context.TODO(), TestClusterName, route))
+		}
+	}
+	require.Len(t, fakeEC2.RouteTables[0].Routes, 1)
+	assert.Equal(t, "10.0.1.0/24", routeDestinationCIDR(fakeEC2.RouteTables[0].Routes[0]))
+}
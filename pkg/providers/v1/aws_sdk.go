@@ -28,13 +28,17 @@ import (
 	stscredsv2 "github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
 	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/elb"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/sqs"
 
 	smithymiddleware "github.com/aws/smithy-go/middleware"
 
@@ -51,6 +55,9 @@ type awsSDKProvider struct {
 
 	mutex          sync.Mutex
 	regionDelayers map[string]*CrossRequestRetryDelay
+
+	// rateLimiter is shared by the EC2 and ELB clients of every region, it is nil when calls aren't limited
+	rateLimiter *apiRateLimiter
 }
 
 func newAWSSDKProvider(creds *credentials.Credentials, credsV2 awsv2.CredentialsProvider, cfg *config.CloudConfig) *awsSDKProvider {
@@ -59,6 +66,7 @@ func newAWSSDKProvider(creds *credentials.Credentials, credsV2 awsv2.Credentials
 		credsV2:        credsV2,
 		cfg:            cfg,
 		regionDelayers: make(map[string]*CrossRequestRetryDelay),
+		rateLimiter:    newAPIRateLimiter(cfg.GetAPIRateLimit()),
 	}
 }
 
@@ -86,6 +94,13 @@ func (p *awsSDKProvider) AddHandlers(regionName string, h *request.Handlers) {
 		})
 	}
 
+	if p.cfg.GetAPICallMetricsEnabled() {
+		h.Complete.PushBackNamed(request.NamedHandler{
+			Name: "k8s/api-call-metrics",
+			Fn:   awsAPICallMetricsHandler,
+		})
+	}
+
 	p.addAPILoggingHandlers(h)
 }
 
@@ -101,6 +116,30 @@ func (p *awsSDKProvider) addAPILoggingHandlers(h *request.Handlers) {
 	})
 }
 
+// addRateLimitHandlers makes the requests of an AWS SDK Go V1 client wait for the shared API rate limiter
+func (p *awsSDKProvider) addRateLimitHandlers(h *request.Handlers) {
+	if p.rateLimiter == nil {
+		return
+	}
+	h.Sign.PushFrontNamed(request.NamedHandler{
+		Name: "k8s/rate-limit",
+		Fn:   p.rateLimiter.BeforeSign,
+	})
+}
+
+// addRateLimitHandlersV2 makes every attempt of the requests of an AWS SDK Go V2 client wait for the shared API rate
+// limiter
+func (p *awsSDKProvider) addRateLimitHandlersV2(cfg *awsv2.Config) {
+	if p.rateLimiter == nil {
+		return
+	}
+	cfg.APIOptions = append(cfg.APIOptions,
+		func(stack *smithymiddleware.Stack) error {
+			return stack.Finalize.Insert(rateLimitMiddleware(p.rateLimiter), "Retry", smithymiddleware.After)
+		},
+	)
+}
+
 // Adds handlers to AWS SDK Go V2 clients. For AWS SDK Go V1 clients,
 // func (p *awsSDKProvider) AddHandlers is used.
 func (p *awsSDKProvider) AddHandlersV2(ctx context.Context, regionName string, cfg *awsv2.Config) {
@@ -169,6 +208,7 @@ func (p *awsSDKProvider) Compute(ctx context.Context, regionName string, assumeR
 	}
 
 	p.AddHandlersV2(ctx, regionName, &cfg)
+	p.addRateLimitHandlersV2(&cfg)
 	var opts []func(*ec2.Options) = p.cfg.GetEC2EndpointOpts(regionName)
 	opts = append(opts, func(o *ec2.Options) {
 		o.Retryer = &customRetryer{
@@ -202,6 +242,7 @@ func (p *awsSDKProvider) LoadBalancing(regionName string) (ELB, error) {
 	}
 	elbClient := elb.New(sess)
 	p.AddHandlers(regionName, &elbClient.Handlers)
+	p.addRateLimitHandlers(&elbClient.Handlers)
 
 	return elbClient, nil
 }
@@ -223,6 +264,7 @@ func (p *awsSDKProvider) LoadBalancingV2(regionName string) (ELBV2, error) {
 	elbClient := elbv2.New(sess)
 
 	p.AddHandlers(regionName, &elbClient.Handlers)
+	p.addRateLimitHandlers(&elbClient.Handlers)
 
 	return elbClient, nil
 }
@@ -234,7 +276,7 @@ func (p *awsSDKProvider) Metadata() (config.EC2Metadata, error) {
 	if err != nil {
 		return nil, fmt.Errorf("unable to initialize AWS session: %v", err)
 	}
-	client := ec2metadata.New(sess)
+	client := newEC2MetadataClient(sess, p.cfg.GetIMDSv1FallbackEnabled())
 	p.addAPILoggingHandlers(&client.Handlers)
 
 	identity, err := client.GetInstanceIdentityDocument()
@@ -252,6 +294,56 @@ func (p *awsSDKProvider) Metadata() (config.EC2Metadata, error) {
 	return client, nil
 }
 
+// newEC2MetadataClient creates an instance metadata client that uses IMDSv2 session tokens. The client fetches a
+// token before its first request and again once the token expires. Requests only fall back to IMDSv1 when a token
+// can't be retrieved and enableFallback is set.
+func newEC2MetadataClient(p client.ConfigProvider, enableFallback bool, cfgs ...*aws.Config) *ec2metadata.EC2Metadata {
+	cfgs = append([]*aws.Config{{EC2MetadataEnableFallback: aws.Bool(enableFallback)}}, cfgs...)
+	return ec2metadata.New(p, cfgs...)
+}
+
+func (p *awsSDKProvider) Autoscaling(regionName string) (ASG, error) {
+	awsConfig := &aws.Config{
+		Region:      &regionName,
+		Credentials: p.creds,
+	}
+	awsConfig = awsConfig.WithCredentialsChainVerboseErrors(true).
+		WithEndpointResolver(p.cfg.GetResolver())
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *awsConfig,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize AWS session: %v", err)
+	}
+	client := autoscaling.New(sess)
+
+	p.AddHandlers(regionName, &client.Handlers)
+
+	return client, nil
+}
+
+func (p *awsSDKProvider) CertificateManager(regionName string) (ACM, error) {
+	awsConfig := &aws.Config{
+		Region:      &regionName,
+		Credentials: p.creds,
+	}
+	awsConfig = awsConfig.WithCredentialsChainVerboseErrors(true).
+		WithEndpointResolver(p.cfg.GetResolver())
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *awsConfig,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize AWS session: %v", err)
+	}
+	client := acm.New(sess)
+
+	p.AddHandlers(regionName, &client.Handlers)
+
+	return client, nil
+}
+
 func (p *awsSDKProvider) KeyManagement(regionName string) (KMS, error) {
 	awsConfig := &aws.Config{
 		Region:      &regionName,
@@ -272,3 +364,24 @@ func (p *awsSDKProvider) KeyManagement(regionName string) (KMS, error) {
 
 	return kmsClient, nil
 }
+
+func (p *awsSDKProvider) MessageQueue(regionName string) (SQS, error) {
+	awsConfig := &aws.Config{
+		Region:      &regionName,
+		Credentials: p.creds,
+	}
+	awsConfig = awsConfig.WithCredentialsChainVerboseErrors(true).
+		WithEndpointResolver(p.cfg.GetResolver())
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *awsConfig,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize AWS session: %v", err)
+	}
+	client := sqs.New(sess)
+
+	p.AddHandlers(regionName, &client.Handlers)
+
+	return client, nil
+}
@@ -5,9 +5,15 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/elb"
 	"github.com/stretchr/testify/assert"
 	"k8s.io/cloud-provider-aws/pkg/providers/v1/config"
 )
@@ -50,6 +56,119 @@ func TestComputeEndpointOverride(t *testing.T) {
 	assert.True(t, usedCustomEndpoint == true, "custom endpoint was not used for EC2 Client")
 }
 
+// Overrides use the service names of aws-sdk-go, they should also apply to the aws-sdk-go-v2 clients
+func TestServiceEndpointOverrides(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "access-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret-key")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	requests := map[string]int{}
+	newServer := func(service string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests[service]++
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	override := func(service, url string) *struct {
+		Service       string
+		Region        string
+		URL           string
+		SigningRegion string
+		SigningMethod string
+		SigningName   string
+	} {
+		return &struct {
+			Service       string
+			Region        string
+			URL           string
+			SigningRegion string
+			SigningMethod string
+			SigningName   string
+		}{Service: service, Region: "us-gov-west-1", URL: url, SigningRegion: "us-gov-west-1"}
+	}
+	cfg := config.CloudConfig{}
+	cfg.ServiceOverride = map[string]*struct {
+		Service       string
+		Region        string
+		URL           string
+		SigningRegion string
+		SigningMethod string
+		SigningName   string
+	}{
+		"1": override("ec2", newServer("ec2").URL),
+		"2": override("elasticloadbalancing", newServer("elasticloadbalancing").URL),
+	}
+	assert.NoError(t, cfg.ValidateOverrides())
+	provider := newAWSSDKProvider(credentials.NewStaticCredentials("access-key", "secret-key", ""), nil, &cfg)
+
+	ec2Client, err := provider.Compute(context.TODO(), "us-gov-west-1", nil)
+	assert.NoError(t, err)
+	ec2Client.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{})
+	assert.Equal(t, 1, requests["ec2"], "custom endpoint was not used for EC2 Client")
+
+	elbClient, err := provider.LoadBalancing("us-gov-west-1")
+	assert.NoError(t, err)
+	elbClient.DescribeLoadBalancers(&elb.DescribeLoadBalancersInput{})
+	assert.Equal(t, 1, requests["elasticloadbalancing"], "custom endpoint was not used for ELB Client")
+
+	// Clients for other regions are not overridden
+	endpoint, err := cfg.GetResolver()("ec2", "us-gov-east-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://ec2.us-gov-east-1.amazonaws.com", endpoint.URL)
+}
+
+// Calls to the EC2 and ELB APIs share a rate limiter, so they are paced at the configured QPS
+func TestAPIRateLimit(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "access-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret-key")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+	var mu sync.Mutex
+	var calls []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, time.Now())
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := config.CloudConfig{}
+	cfg.Global.APIRateLimitQPS = 5
+	cfg.Global.APIRateLimitBurst = 1
+	cfg.ServiceOverride = map[string]*struct {
+		Service       string
+		Region        string
+		URL           string
+		SigningRegion string
+		SigningMethod string
+		SigningName   string
+	}{
+		"1": {Service: "ec2", Region: "us-west-2", URL: server.URL, SigningRegion: "us-west-2"},
+		"2": {Service: "elasticloadbalancing", Region: "us-west-2", URL: server.URL, SigningRegion: "us-west-2"},
+	}
+	assert.NoError(t, cfg.ValidateOverrides())
+	provider := newAWSSDKProvider(credentials.NewStaticCredentials("access-key", "secret-key", ""), nil, &cfg)
+
+	ec2Client, err := provider.Compute(context.TODO(), "us-west-2", nil)
+	assert.NoError(t, err)
+	elbClient, err := provider.LoadBalancing("us-west-2")
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		ec2Client.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{})
+		elbClient.DescribeLoadBalancers(&elb.DescribeLoadBalancersInput{})
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, calls, 4)
+	for i := 1; i < len(calls); i++ {
+		// Allow for the limiter refilling the bucket slightly ahead of the wall clock
+		assert.GreaterOrEqual(t, calls[i].Sub(calls[i-1]), 180*time.Millisecond, "call %d wasn't paced", i)
+	}
+}
+
 // When a nonRetryableError is thrown, an API request should not be retried
 func TestComputeNoRetry(t *testing.T) {
 	attemptCount := 0
@@ -144,3 +263,78 @@ func TestComputeWithRetry(t *testing.T) {
 	_, err = ec2Client.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{})
 	assert.True(t, attemptCount > 1, fmt.Sprintf("expected an attempt count >1, got %d", attemptCount))
 }
+
+// newFakeMetadataServer serves IMDSv2 tokens valid for ttlSeconds, and serves metadata only to requests carrying
+// the latest token. When tokens are disabled the token endpoint returns 403 and metadata is served to any request.
+func newFakeMetadataServer(t *testing.T, ttlSeconds int, tokensDisabled bool) (*httptest.Server, *int) {
+	tokensIssued := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			if tokensDisabled {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			assert.NotEmpty(t, r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"))
+			tokensIssued++
+			w.Header().Set("X-aws-ec2-metadata-token-ttl-seconds", fmt.Sprint(ttlSeconds))
+			fmt.Fprintf(w, "token-%d", tokensIssued)
+		case r.Method == http.MethodGet && r.URL.Path == "/latest/meta-data/instance-id":
+			if !tokensDisabled && r.Header.Get("X-aws-ec2-metadata-token") != fmt.Sprintf("token-%d", tokensIssued) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, "i-self")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &tokensIssued
+}
+
+func newTestEC2MetadataClient(t *testing.T, server *httptest.Server, enableFallback bool) config.EC2Metadata {
+	sess, err := session.NewSession()
+	assert.NoError(t, err)
+	return newEC2MetadataClient(sess, enableFallback, &aws.Config{
+		Endpoint:   aws.String(server.URL + "/latest"),
+		MaxRetries: aws.Int(0),
+	})
+}
+
+func TestEC2MetadataUsesIMDSv2Token(t *testing.T) {
+	server, tokensIssued := newFakeMetadataServer(t, 21600, false)
+	client := newTestEC2MetadataClient(t, server, false)
+
+	for i := 0; i < 3; i++ {
+		instanceID, err := client.GetMetadata("instance-id")
+		assert.NoError(t, err)
+		assert.Equal(t, "i-self", instanceID)
+	}
+	// the token is reused until it expires
+	assert.Equal(t, 1, *tokensIssued)
+}
+
+func TestEC2MetadataRefreshesExpiredToken(t *testing.T) {
+	// tokens that expire within the SDK's expiry window are refreshed before every request
+	server, tokensIssued := newFakeMetadataServer(t, 1, false)
+	client := newTestEC2MetadataClient(t, server, false)
+
+	for i := 0; i < 3; i++ {
+		instanceID, err := client.GetMetadata("instance-id")
+		assert.NoError(t, err)
+		assert.Equal(t, "i-self", instanceID)
+	}
+	assert.Equal(t, 3, *tokensIssued)
+}
+
+func TestEC2MetadataIMDSv1Fallback(t *testing.T) {
+	server, _ := newFakeMetadataServer(t, 21600, true)
+
+	_, err := newTestEC2MetadataClient(t, server, false).GetMetadata("instance-id")
+	assert.Error(t, err, "IMDSv1 should not be used when fallback is disabled")
+
+	instanceID, err := newTestEC2MetadataClient(t, server, true).GetMetadata("instance-id")
+	assert.NoError(t, err)
+	assert.Equal(t, "i-self", instanceID)
+}
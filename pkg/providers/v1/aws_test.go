@@ -1,6 +1,51 @@
 /*
 Copyright 2014 The Kubernetes Authors.
 
+		{
+			"Bogus backend protocol without cert",
+			80, "", 8020, "bacon", "", "", "",
+			true, "", "", "",
+		},
+		{
+			"SSL ports without cert",
+			443, "", 8021, "tcp", "", "443", "",
+			true, "", "", "",
+		},
+		{
+			"All SSL ports without cert",
+			443, "", 8022, "https", "", "*", "",
+			true, "", "", "",
+		},
+		{
+			"All SSL ports, HTTPS->HTTPS",
+			443, "", 8023, "https", "cert", "*", "",
+			false, "https", "https", "cert",
+		},
+		{
+			"SSL port in whitelist, SSL->SSL",
+			443, "", 8024, "ssl", "cert", "443", "",
+			false, "ssl", "ssl", "cert",
+		},
+		{
+			"SSL backend on port not in whitelist, passthrough",
+			80, "", 8025, "ssl", "cert", "443", "",
+			false, "tcp", "tcp", "",
+		},
+		{
+			"HTTPS backend on port not in whitelist, passthrough",
+			80, "", 8026, "https", "cert", "443", "",
+			false, "tcp", "tcp", "",
+		},
+		{
+			"HTTP backend on port not in whitelist, HTTP->HTTP",
+			80, "", 8027, "http", "cert", "443", "",
+			false, "http", "http", "",
+		},
+		{
+			"HTTP backend on named port in whitelist, HTTPS->HTTP",
+			8443, "https", 8028, "http", "cert", "https", "",
+			false, "https", "http", "cert",
+		},
 Licensed under the Apache License, Version 2.0 (the "License");
 you may not use this file except in compliance with the License.
 You may obtain a copy of the License at
@@ -17,33 +62,48 @@ limitations under the License.
 package aws
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"reflect"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/acm"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/aws/aws-sdk-go/service/elb"
 	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/aws/smithy-go"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	cloudprovider "k8s.io/cloud-provider"
+	"k8s.io/klog/v2"
+	"k8s.io/klog/v2/textlogger"
+	clocktesting "k8s.io/utils/clock/testing"
 
 	"k8s.io/cloud-provider-aws/pkg/providers/v1/config"
 )
@@ -54,6 +114,9 @@ const TestClusterName = "testCluster"
 type MockedFakeEC2 struct {
 	*FakeEC2Impl
 	mock.Mock
+
+	// fakeSecurityGroups serves DescribeSecurityGroups from the fake security groups instead of the mock
+	fakeSecurityGroups bool
 }
 
 func (m *MockedFakeEC2) expectDescribeSecurityGroups(clusterID, groupName string) {
@@ -92,6 +155,9 @@ func (m *MockedFakeEC2) expectDescribeSecurityGroupsByFilter(clusterID, filterNa
 }
 
 func (m *MockedFakeEC2) DescribeSecurityGroups(ctx context.Context, request *ec2.DescribeSecurityGroupsInput, optFns ...func(*ec2.Options)) ([]ec2types.SecurityGroup, error) {
+	if m.fakeSecurityGroups {
+		return m.FakeEC2Impl.DescribeSecurityGroups(ctx, request, optFns...)
+	}
 	args := m.Called(request)
 	return args.Get(0).([]ec2types.SecurityGroup), nil
 }
@@ -106,6 +172,21 @@ func (m *MockedFakeELB) DescribeLoadBalancers(input *elb.DescribeLoadBalancersIn
 	return args.Get(0).(*elb.DescribeLoadBalancersOutput), nil
 }
 
+func (m *MockedFakeELB) RegisterInstancesWithLoadBalancer(input *elb.RegisterInstancesWithLoadBalancerInput) (*elb.RegisterInstancesWithLoadBalancerOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*elb.RegisterInstancesWithLoadBalancerOutput), nil
+}
+
+func (m *MockedFakeELB) AttachLoadBalancerToSubnets(input *elb.AttachLoadBalancerToSubnetsInput) (*elb.AttachLoadBalancerToSubnetsOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*elb.AttachLoadBalancerToSubnetsOutput), nil
+}
+
+func (m *MockedFakeELB) DetachLoadBalancerFromSubnets(input *elb.DetachLoadBalancerFromSubnetsInput) (*elb.DetachLoadBalancerFromSubnetsOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*elb.DetachLoadBalancerFromSubnetsOutput), nil
+}
+
 func (m *MockedFakeELB) expectDescribeLoadBalancers(loadBalancerName string) {
 	m.On("DescribeLoadBalancers", &elb.DescribeLoadBalancersInput{LoadBalancerNames: []*string{aws.String(loadBalancerName)}}).Return(&elb.DescribeLoadBalancersOutput{
 		LoadBalancerDescriptions: []*elb.LoadBalancerDescription{
@@ -116,11 +197,24 @@ func (m *MockedFakeELB) expectDescribeLoadBalancers(loadBalancerName string) {
 	})
 }
 
+func (m *MockedFakeELB) DescribeTags(input *elb.DescribeTagsInput) (*elb.DescribeTagsOutput, error) {
+	args := m.Called(input)
+	if err := args.Error(1); err != nil {
+		return nil, err
+	}
+	return args.Get(0).(*elb.DescribeTagsOutput), nil
+}
+
 func (m *MockedFakeELB) AddTags(input *elb.AddTagsInput) (*elb.AddTagsOutput, error) {
 	args := m.Called(input)
 	return args.Get(0).(*elb.AddTagsOutput), nil
 }
 
+func (m *MockedFakeELB) RemoveTags(input *elb.RemoveTagsInput) (*elb.RemoveTagsOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*elb.RemoveTagsOutput), nil
+}
+
 func (m *MockedFakeELB) ConfigureHealthCheck(input *elb.ConfigureHealthCheckInput) (*elb.ConfigureHealthCheckOutput, error) {
 	args := m.Called(input)
 	if args.Get(0) == nil {
@@ -139,6 +233,65 @@ func (m *MockedFakeELB) expectConfigureHealthCheck(loadBalancerName *string, exp
 	}
 }
 
+func (m *MockedFakeELB) DescribeLoadBalancerPolicies(input *elb.DescribeLoadBalancerPoliciesInput) (*elb.DescribeLoadBalancerPoliciesOutput, error) {
+	args := m.Called(input)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*elb.DescribeLoadBalancerPoliciesOutput), args.Error(1)
+}
+
+func (m *MockedFakeELB) CreateLoadBalancerPolicy(input *elb.CreateLoadBalancerPolicyInput) (*elb.CreateLoadBalancerPolicyOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*elb.CreateLoadBalancerPolicyOutput), nil
+}
+
+func (m *MockedFakeELB) SetLoadBalancerPoliciesOfListener(input *elb.SetLoadBalancerPoliciesOfListenerInput) (*elb.SetLoadBalancerPoliciesOfListenerOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*elb.SetLoadBalancerPoliciesOfListenerOutput), nil
+}
+
+func (m *MockedFakeELB) CreateLoadBalancerListeners(input *elb.CreateLoadBalancerListenersInput) (*elb.CreateLoadBalancerListenersOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*elb.CreateLoadBalancerListenersOutput), nil
+}
+
+func (m *MockedFakeELB) DeleteLoadBalancerListeners(input *elb.DeleteLoadBalancerListenersInput) (*elb.DeleteLoadBalancerListenersOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*elb.DeleteLoadBalancerListenersOutput), nil
+}
+
+func (m *MockedFakeELB) SetLoadBalancerListenerSSLCertificate(input *elb.SetLoadBalancerListenerSSLCertificateInput) (*elb.SetLoadBalancerListenerSSLCertificateOutput, error) {
+	args := m.Called(input)
+	return args.Get(0).(*elb.SetLoadBalancerListenerSSLCertificateOutput), nil
+}
+
+// expectSSLNegotiationPolicy expects the SSL negotiation policy to be created, as it doesn't exist yet
+func (m *MockedFakeELB) expectSSLNegotiationPolicy(loadBalancerName, sslPolicyName string) {
+	policyName := aws.String(fmt.Sprintf(SSLNegotiationPolicyNameFormat, sslPolicyName))
+	m.On("DescribeLoadBalancerPolicies", &elb.DescribeLoadBalancerPoliciesInput{
+		LoadBalancerName: aws.String(loadBalancerName),
+		PolicyNames:      []*string{policyName},
+	}).Return(nil, awserr.New(elb.ErrCodePolicyNotFoundException, "not found", nil)).Once()
+	m.On("CreateLoadBalancerPolicy", &elb.CreateLoadBalancerPolicyInput{
+		LoadBalancerName: aws.String(loadBalancerName),
+		PolicyName:       policyName,
+		PolicyTypeName:   aws.String("SSLNegotiationPolicyType"),
+		PolicyAttributes: []*elb.PolicyAttribute{{
+			AttributeName:  aws.String("Reference-Security-Policy"),
+			AttributeValue: aws.String(sslPolicyName),
+		}},
+	}).Return(&elb.CreateLoadBalancerPolicyOutput{}).Once()
+}
+
+func (m *MockedFakeELB) expectListenerPolicies(loadBalancerName string, port int64, policyNames ...string) {
+	m.On("SetLoadBalancerPoliciesOfListener", &elb.SetLoadBalancerPoliciesOfListenerInput{
+		LoadBalancerName: aws.String(loadBalancerName),
+		LoadBalancerPort: aws.Int64(port),
+		PolicyNames:      aws.StringSlice(policyNames),
+	}).Return(&elb.SetLoadBalancerPoliciesOfListenerOutput{}).Once()
+}
+
 func TestReadAWSCloudConfigNodeIPFamilies(t *testing.T) {
 	tests := []struct {
 		name string
@@ -379,6 +532,19 @@ func TestOverridesActiveConfig(t *testing.T) {
 			[]ServiceDescriptor{{name: "s3", region: "region1", signingRegion: "sregion1", signingMethod: "v3"},
 				{name: "s3", region: "region2", signingRegion: "sregion1", signingMethod: "v4", signingName: "name"}},
 		},
+		{
+			"Metadata service without region",
+			strings.NewReader(`
+                 [global]
+
+				[ServiceOverride "1"]
+                 Service=ec2metadata
+                 URL=https://ec2metadata.foo.bar
+                 `),
+			nil,
+			false, true,
+			[]ServiceDescriptor{{name: "ec2metadata", region: ""}},
+		},
 	}
 
 	for _, test := range tests {
@@ -692,6 +858,118 @@ func TestNodeAddressesByProviderID(t *testing.T) {
 	}
 }
 
+func TestNodeAddressesByProviderIDCache(t *testing.T) {
+	instance := makeInstance("i-00000000000000000", "192.168.0.1", "1.2.3.4", "instance-same.ec2.internal", "instance-same.ec2.external", nil, true)
+	awsServices := NewFakeAWSServices(TestClusterID)
+	awsServices.instances = []*ec2types.Instance{&instance}
+	awsServices.selfInstance = &instance
+	cfg := config.CloudConfig{}
+	cfg.Global.NodeAddressCacheTTL = "1m"
+	c, err := newAWSCloud(cfg, awsServices)
+	require.NoError(t, err)
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	c.nodeAddressCache.clock = fakeClock
+	fakeEC2 := awsServices.ec2.(*FakeEC2Impl)
+	fakeEC2.apiCalls = map[string]int{}
+
+	lookup := func() {
+		addrs, err := c.NodeAddressesByProviderID(context.TODO(), "aws:///us-west-2a/i-00000000000000000")
+		require.NoError(t, err)
+		testHasNodeAddress(t, addrs, v1.NodeInternalIP, "192.168.0.1")
+	}
+
+	// Repeated lookups within the TTL are served from the cache
+	lookup()
+	lookup()
+	assert.Equal(t, 1, fakeEC2.apiCalls["DescribeInstances"])
+
+	fakeClock.Step(time.Minute)
+	lookup()
+	assert.Equal(t, 2, fakeEC2.apiCalls["DescribeInstances"])
+
+	// The instance of a node that became not ready is described again
+	node := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node"},
+		Spec:       v1.NodeSpec{ProviderID: "aws:///us-west-2a/i-00000000000000000"},
+		Status:     v1.NodeStatus{Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}},
+	}
+	notReadyNode := node.DeepCopy()
+	notReadyNode.Status.Conditions[0].Status = v1.ConditionFalse
+	c.invalidateChangedNode(node, node.DeepCopy())
+	lookup()
+	assert.Equal(t, 2, fakeEC2.apiCalls["DescribeInstances"])
+	c.invalidateChangedNode(node, notReadyNode)
+	lookup()
+	assert.Equal(t, 3, fakeEC2.apiCalls["DescribeInstances"])
+
+	c.invalidateDeletedNode(notReadyNode)
+	lookup()
+	assert.Equal(t, 4, fakeEC2.apiCalls["DescribeInstances"])
+}
+
+func TestNodeAddressesCache(t *testing.T) {
+	instance := makeInstance("i-00000000000000000", "192.168.0.1", "1.2.3.4", "instance-same.ec2.internal", "instance-same.ec2.external", nil, true)
+	c, awsServices := mockInstancesResp(&instance, []*ec2types.Instance{&instance})
+	c.nodeAddressCache = newNodeAddressCache(time.Minute, clocktesting.NewFakeClock(time.Now()))
+	fakeEC2 := awsServices.ec2.(*MockedFakeEC2).FakeEC2Impl
+	fakeEC2.apiCalls = map[string]int{}
+
+	// Addresses looked up by node name share the cache of the addresses looked up by provider ID
+	for i := 0; i < 2; i++ {
+		addrs, err := c.NodeAddresses(context.TODO(), "instance-same.ec2.internal")
+		require.NoError(t, err)
+		testHasNodeAddress(t, addrs, v1.NodeInternalIP, "192.168.0.1")
+		assert.Equal(t, 1, fakeEC2.apiCalls["DescribeInstances"])
+	}
+	_, err := c.NodeAddressesByProviderID(context.TODO(), "aws:///us-west-2a/i-00000000000000000")
+	require.NoError(t, err)
+	assert.Equal(t, 1, fakeEC2.apiCalls["DescribeInstances"])
+}
+
+func TestInstanceStateChangeEvents(t *testing.T) {
+	instance := makeInstance("i-00000000000000000", "192.168.0.1", "1.2.3.4", "instance-same.ec2.internal", "instance-same.ec2.external", nil, true)
+	awsServices := NewFakeAWSServices(TestClusterID)
+	awsServices.instances = []*ec2types.Instance{&instance}
+	awsServices.selfInstance = &instance
+	cfg := config.CloudConfig{}
+	cfg.Global.NodeAddressCacheTTL = "1h"
+	cfg.Global.InstanceStateChangeQueueURL = "https://sqs.us-west-2.amazonaws.com/123456789012/instance-state"
+	c, err := newAWSCloud(cfg, awsServices)
+	require.NoError(t, err)
+	require.NotNil(t, c.instanceStateEvents)
+	fakeEC2 := awsServices.ec2.(*FakeEC2Impl)
+	fakeEC2.apiCalls = map[string]int{}
+
+	lookup := func() {
+		_, err := c.NodeAddressesByProviderID(context.TODO(), "aws:///us-west-2a/i-00000000000000000")
+		require.NoError(t, err)
+	}
+	event := func(state string) *sqs.Message {
+		body := fmt.Sprintf(`{"version":"0","detail-type":"EC2 Instance State-change Notification","source":"aws.ec2",`+
+			`"region":"us-west-2","detail":{"instance-id":"i-00000000000000000","state":"%s"}}`, state)
+		return &sqs.Message{MessageId: aws.String(state), ReceiptHandle: aws.String(state), Body: aws.String(body)}
+	}
+	receive := func(messages ...*sqs.Message) {
+		awsServices.sqs.Messages = messages
+		require.NoError(t, c.instanceStateEvents.receive(context.TODO()))
+	}
+
+	lookup()
+	assert.Equal(t, 1, fakeEC2.apiCalls["DescribeInstances"])
+
+	// Events of instances that keep running and messages that aren't events leave the cache alone
+	receive(event("running"), &sqs.Message{ReceiptHandle: aws.String("invalid"), Body: aws.String("not json")})
+	lookup()
+	assert.Equal(t, 1, fakeEC2.apiCalls["DescribeInstances"])
+
+	// A terminated instance is described again
+	receive(event("terminated"))
+	lookup()
+	assert.Equal(t, 2, fakeEC2.apiCalls["DescribeInstances"])
+
+	assert.Equal(t, []string{"running", "invalid", "terminated"}, awsServices.sqs.DeletedReceiptHandles)
+}
+
 func TestNodeAddresses(t *testing.T) {
 	for _, tc := range []struct {
 		Name            string
@@ -798,6 +1076,74 @@ func TestNodeAddresses(t *testing.T) {
 	}
 }
 
+func TestNodeAddressesSecondaryIPs(t *testing.T) {
+	instance := makeInstance("i-00000000000000000", "192.168.0.1", "1.2.3.4", "instance-same.ec2.internal", "", nil, true)
+	privateIP := func(ip string, primary bool) ec2types.InstancePrivateIpAddress {
+		return ec2types.InstancePrivateIpAddress{PrivateIpAddress: aws.String(ip), Primary: aws.Bool(primary)}
+	}
+	// A trunk ENI and a branch ENI with secondary IPs for pods, one of them reported by both interfaces
+	instance.NetworkInterfaces = []ec2types.InstanceNetworkInterface{
+		{
+			Status:     ec2types.NetworkInterfaceStatusInUse,
+			Attachment: &ec2types.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int32(1)},
+			PrivateIpAddresses: []ec2types.InstancePrivateIpAddress{
+				privateIP("192.168.1.11", false),
+				privateIP("192.168.1.1", true),
+				privateIP("192.168.0.12", false),
+			},
+		},
+		{
+			Status:     ec2types.NetworkInterfaceStatusInUse,
+			Attachment: &ec2types.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int32(0)},
+			PrivateIpAddresses: []ec2types.InstancePrivateIpAddress{
+				privateIP("192.168.0.1", true),
+				privateIP("192.168.0.11", false),
+				privateIP("192.168.0.12", false),
+			},
+		},
+		{
+			Status:             ec2types.NetworkInterfaceStatusDetaching,
+			Attachment:         &ec2types.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int32(2)},
+			PrivateIpAddresses: []ec2types.InstancePrivateIpAddress{privateIP("192.168.2.1", true)},
+		},
+	}
+	internalIPs := func(addresses []v1.NodeAddress) []string {
+		var ips []string
+		for _, address := range addresses {
+			if address.Type == v1.NodeInternalIP {
+				ips = append(ips, address.Address)
+			}
+		}
+		return ips
+	}
+
+	for _, tc := range []struct {
+		name                string
+		enableSecondaryIPs  bool
+		expectedInternalIPs []string
+	}{
+		{
+			name:                "primary IPs only by default",
+			expectedInternalIPs: []string{"192.168.0.1", "192.168.1.1"},
+		},
+		{
+			name:                "secondary IPs when enabled",
+			enableSecondaryIPs:  true,
+			expectedInternalIPs: []string{"192.168.0.1", "192.168.0.11", "192.168.0.12", "192.168.1.1", "192.168.1.11"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := mockInstancesResp(&instance, []*ec2types.Instance{&instance})
+			c.cfg.Global.EnableSecondaryIPNodeAddresses = tc.enableSecondaryIPs
+
+			addresses, err := c.NodeAddressesByProviderID(context.TODO(), "aws:///us-west-2a/i-00000000000000000")
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedInternalIPs, internalIPs(addresses))
+			testHasNodeAddress(t, addresses, v1.NodeExternalIP, "1.2.3.4")
+		})
+	}
+}
+
 func TestGetRegion(t *testing.T) {
 	aws := mockZone("us-west-2", "us-west-2e")
 	zones, ok := aws.Zones()
@@ -1176,6 +1522,7 @@ func Test_getLoadBalancerSubnets(t *testing.T) {
 		name        string
 		service     *v1.Service
 		subnets     []*ec2types.Subnet
+		routeTables map[string]bool
 		internalELB bool
 		want        []string
 		wantErr     error
@@ -1254,13 +1601,89 @@ func Test_getLoadBalancerSubnets(t *testing.T) {
 			},
 			wantErr: errors.New("expected to find 3, but found 1 subnets"),
 		},
+		{
+			name: "subnets in the same availability zone",
+			subnets: []*ec2types.Subnet{
+				{
+					AvailabilityZone: aws.String("us-west-2c"),
+					SubnetId:         aws.String("subnet-a000001"),
+				},
+				{
+					AvailabilityZone: aws.String("us-west-2c"),
+					SubnetId:         aws.String("subnet-a000002"),
+				},
+			},
+			service: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"service.beta.kubernetes.io/aws-load-balancer-subnets": "subnet-a000001, subnet-a000002",
+					},
+				},
+			},
+			wantErr: errors.New(`subnets "subnet-a000001" and "subnet-a000002" from annotation service.beta.kubernetes.io/aws-load-balancer-subnets are both in availability zone "us-west-2c", only one subnet per availability zone is allowed`),
+		},
+		{
+			name: "private subnet for internet-facing load balancer",
+			subnets: []*ec2types.Subnet{
+				{
+					AvailabilityZone: aws.String("us-west-2c"),
+					SubnetId:         aws.String("subnet-a000001"),
+				},
+				{
+					AvailabilityZone: aws.String("us-west-2b"),
+					SubnetId:         aws.String("subnet-a000002"),
+				},
+			},
+			routeTables: map[string]bool{
+				"subnet-a000001": true,
+				"subnet-a000002": false,
+			},
+			service: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"service.beta.kubernetes.io/aws-load-balancer-subnets": "subnet-a000001, subnet-a000002",
+					},
+				},
+			},
+			wantErr: errors.New(`subnet "subnet-a000002" from annotation service.beta.kubernetes.io/aws-load-balancer-subnets is private and can't be used by an internet-facing load balancer`),
+		},
+		{
+			name: "private subnet for internal load balancer",
+			subnets: []*ec2types.Subnet{
+				{
+					AvailabilityZone: aws.String("us-west-2c"),
+					SubnetId:         aws.String("subnet-a000001"),
+				},
+				{
+					AvailabilityZone: aws.String("us-west-2b"),
+					SubnetId:         aws.String("subnet-a000002"),
+				},
+			},
+			routeTables: map[string]bool{
+				"subnet-a000001": false,
+				"subnet-a000002": false,
+			},
+			service: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						"service.beta.kubernetes.io/aws-load-balancer-subnets": "subnet-a000001, subnet-a000002",
+					},
+				},
+			},
+			internalELB: true,
+			want:        []string{"subnet-a000001", "subnet-a000002"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			awsServices.ec2.RemoveSubnets()
+			awsServices.ec2.RemoveRouteTables()
 			for _, subnet := range tt.subnets {
 				awsServices.ec2.CreateSubnet(subnet)
 			}
+			for _, rt := range constructRouteTables(tt.routeTables) {
+				awsServices.ec2.CreateRouteTable(rt)
+			}
 			got, err := c.getLoadBalancerSubnets(context.TODO(), tt.service, tt.internalELB)
 			if tt.wantErr != nil {
 				assert.EqualError(t, err, tt.wantErr.Error())
@@ -1823,6 +2246,7 @@ func TestBuildListener(t *testing.T) {
 		backendProtocolAnnotation string
 		certAnnotation            string
 		sslPortAnnotation         string
+		xForwardedForAnnotation   string
 
 		expectError      bool
 		lbProtocol       string
@@ -1831,74 +2255,109 @@ func TestBuildListener(t *testing.T) {
 	}{
 		{
 			"No cert or BE protocol annotation, passthrough",
-			80, "", 7999, "", "", "",
+			80, "", 7999, "", "", "", "",
 			false, "tcp", "tcp", "",
 		},
 		{
 			"Cert annotation without BE protocol specified, SSL->TCP",
-			80, "", 8000, "", "cert", "",
+			80, "", 8000, "", "cert", "", "",
 			false, "ssl", "tcp", "cert",
 		},
 		{
 			"BE protocol without cert annotation, passthrough",
-			443, "", 8001, "https", "", "",
+			443, "", 8001, "https", "", "", "",
 			false, "tcp", "tcp", "",
 		},
 		{
 			"Invalid cert annotation, bogus backend protocol",
-			443, "", 8002, "bacon", "foo", "",
+			443, "", 8002, "bacon", "foo", "", "",
 			true, "tcp", "tcp", "",
 		},
 		{
 			"Invalid cert annotation, protocol followed by equal sign",
-			443, "", 8003, "http=", "=", "",
+			443, "", 8003, "http=", "=", "", "",
 			true, "tcp", "tcp", "",
 		},
 		{
 			"HTTPS->HTTPS",
-			443, "", 8004, "https", "cert", "",
+			443, "", 8004, "https", "cert", "", "",
 			false, "https", "https", "cert",
 		},
 		{
 			"HTTPS->HTTP",
-			443, "", 8005, "http", "cert", "",
+			443, "", 8005, "http", "cert", "", "",
 			false, "https", "http", "cert",
 		},
 		{
 			"SSL->SSL",
-			443, "", 8006, "ssl", "cert", "",
+			443, "", 8006, "ssl", "cert", "", "",
 			false, "ssl", "ssl", "cert",
 		},
 		{
 			"SSL->TCP",
-			443, "", 8007, "tcp", "cert", "",
+			443, "", 8007, "tcp", "cert", "", "",
 			false, "ssl", "tcp", "cert",
 		},
 		{
 			"Port in whitelist",
-			1234, "", 8008, "tcp", "cert", "1234,5678",
+			1234, "", 8008, "tcp", "cert", "1234,5678", "",
 			false, "ssl", "tcp", "cert",
 		},
 		{
 			"Port not in whitelist, passthrough",
-			443, "", 8009, "tcp", "cert", "1234,5678",
+			443, "", 8009, "tcp", "cert", "1234,5678", "",
 			false, "tcp", "tcp", "",
 		},
 		{
 			"Named port in whitelist",
-			1234, "bar", 8010, "tcp", "cert", "foo,bar",
+			1234, "bar", 8010, "tcp", "cert", "foo,bar", "",
 			false, "ssl", "tcp", "cert",
 		},
 		{
 			"Named port not in whitelist, passthrough",
-			443, "", 8011, "tcp", "cert", "foo,bar",
+			443, "", 8011, "tcp", "cert", "foo,bar", "",
 			false, "tcp", "tcp", "",
 		},
 		{
 			"HTTP->HTTP",
-			80, "", 8012, "http", "", "",
+			80, "", 8012, "http", "", "", "",
+			false, "http", "http", "",
+		},
+		{
+			"X-Forwarded-For without cert, HTTP->HTTP",
+			80, "", 8013, "", "", "", "true",
+			false, "http", "http", "",
+		},
+		{
+			"X-Forwarded-For with cert, HTTPS->HTTP",
+			443, "", 8014, "", "cert", "", "true",
+			false, "https", "http", "cert",
+		},
+		{
+			"X-Forwarded-For on port not in whitelist, HTTP->HTTP",
+			80, "", 8015, "", "cert", "443", "true",
 			false, "http", "http", "",
 		},
+		{
+			"X-Forwarded-For with TCP backend protocol",
+			443, "", 8016, "tcp", "cert", "", "true",
+			true, "", "", "",
+		},
+		{
+			"X-Forwarded-For disabled, passthrough",
+			80, "", 8017, "", "", "", "false",
+			false, "tcp", "tcp", "",
+		},
+		{
+			"X-Forwarded-For disabled with HTTP backend protocol",
+			80, "", 8018, "http", "", "", "false",
+			true, "", "", "",
+		},
+		{
+			"Invalid X-Forwarded-For annotation",
+			80, "", 8019, "", "", "", "yes",
+			true, "", "", "",
+		},
 	}
 
 	for _, test := range tests {
@@ -1910,6 +2369,12 @@ func TestBuildListener(t *testing.T) {
 		if test.certAnnotation != "" {
 			annotations[ServiceAnnotationLoadBalancerCertificate] = test.certAnnotation
 		}
+		if test.xForwardedForAnnotation != "" {
+			annotations[ServiceAnnotationLoadBalancerXForwardedFor] = test.xForwardedForAnnotation
+		}
+		if test.sslPortAnnotation != "" {
+			annotations[ServiceAnnotationLoadBalancerSSLPorts] = test.sslPortAnnotation
+		}
 		ports := getPortSets(test.sslPortAnnotation)
 		l, err := buildListener(v1.ServicePort{
 			NodePort: int32(test.instancePort),
@@ -2106,6 +2571,47 @@ func TestLBSecurityGroupsAnnotation(t *testing.T) {
 	}
 }
 
+func TestLBSecurityGroupsAndExtraSecurityGroupsAnnotations(t *testing.T) {
+	awsServices := newMockedFakeAWSServices(TestClusterID)
+	c, _ := newAWSCloud(config.CloudConfig{}, awsServices)
+	recorder := record.NewFakeRecorder(10)
+	c.eventRecorder = recorder
+
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myservice",
+			Namespace: "default",
+			Annotations: map[string]string{
+				ServiceAnnotationLoadBalancerSecurityGroups:      "sg-000001",
+				ServiceAnnotationLoadBalancerExtraSecurityGroups: "sg-000002, sg-000003",
+			},
+		},
+	}
+	serviceName := types.NamespacedName{Namespace: service.Namespace, Name: service.Name}
+
+	sgList, setupSg, err := c.buildELBSecurityGroupList(context.TODO(), serviceName, "aid", service.Annotations)
+	assert.NoError(t, err, "buildELBSecurityGroupList failed")
+	assert.Equal(t, []string{"sg-000001", "sg-000002", "sg-000003"}, sgList)
+	assert.False(t, setupSg)
+
+	c.warnOnConflictingSecurityGroupAnnotations(service)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Warning ConflictingSecurityGroupAnnotations")
+
+	// The event is only recorded again when the annotations change
+	c.warnOnConflictingSecurityGroupAnnotations(service)
+	assert.Empty(t, recorder.Events)
+	service.Annotations[ServiceAnnotationLoadBalancerExtraSecurityGroups] = "sg-000002"
+	c.warnOnConflictingSecurityGroupAnnotations(service)
+	require.Len(t, recorder.Events, 1)
+	<-recorder.Events
+
+	// Only one of the annotations is set, nothing to warn about.
+	delete(service.Annotations, ServiceAnnotationLoadBalancerSecurityGroups)
+	c.warnOnConflictingSecurityGroupAnnotations(service)
+	assert.Empty(t, recorder.Events)
+}
+
 // Test that we can add a load balancer tag
 func TestAddLoadBalancerTags(t *testing.T) {
 	loadBalancerName := "test-elb"
@@ -2131,6 +2637,11 @@ func TestAddLoadBalancerTags(t *testing.T) {
 	awsServices.elb.(*MockedFakeELB).AssertExpectations(t)
 }
 
+// newHealthCheckService returns a service with the annotations for ensureLoadBalancerHealthCheck
+func newHealthCheckService(annotations map[string]string) *v1.Service {
+	return &v1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "myservice", Annotations: annotations}}
+}
+
 func TestEnsureLoadBalancerHealthCheck(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -2192,6 +2703,22 @@ func TestEnsureLoadBalancerHealthCheck(t *testing.T) {
 				Target:             aws.String("TCP:8080"),
 			},
 		},
+		{
+			name: "thresholds, interval and timeout at the AWS limits",
+			annotations: map[string]string{
+				ServiceAnnotationLoadBalancerHCHealthyThreshold:   "10",
+				ServiceAnnotationLoadBalancerHCUnhealthyThreshold: "2",
+				ServiceAnnotationLoadBalancerHCTimeout:            "60",
+				ServiceAnnotationLoadBalancerHCInterval:           "300",
+			},
+			want: elb.HealthCheck{
+				HealthyThreshold:   aws.Int64(10),
+				UnhealthyThreshold: aws.Int64(2),
+				Timeout:            aws.Int64(60),
+				Interval:           aws.Int64(300),
+				Target:             aws.String("TCP:8080"),
+			},
+		},
 		{
 			name: "healthcheck port override",
 			annotations: map[string]string{
@@ -2289,7 +2816,7 @@ func TestEnsureLoadBalancerHealthCheck(t *testing.T) {
 			expectedHC := test.want
 			awsServices.elb.(*MockedFakeELB).expectConfigureHealthCheck(&lbName, &expectedHC, nil)
 
-			err = c.ensureLoadBalancerHealthCheck(elbDesc, protocol, port, path, test.annotations)
+			err = c.ensureLoadBalancerHealthCheck(newHealthCheckService(test.annotations), elbDesc, protocol, port, path)
 
 			require.NoError(t, err)
 			awsServices.elb.(*MockedFakeELB).AssertExpectations(t)
@@ -2310,14 +2837,30 @@ func TestEnsureLoadBalancerHealthCheck(t *testing.T) {
 		// NOTE no call expectations are set on the ELB mock
 		// test default HC
 		elbDesc := &elb.LoadBalancerDescription{LoadBalancerName: &lbName, HealthCheck: defaultHC}
-		err = c.ensureLoadBalancerHealthCheck(elbDesc, protocol, port, path, map[string]string{})
+		err = c.ensureLoadBalancerHealthCheck(newHealthCheckService(map[string]string{}), elbDesc, protocol, port, path)
 		assert.NoError(t, err)
 		// test HC with override
 		elbDesc = &elb.LoadBalancerDescription{LoadBalancerName: &lbName, HealthCheck: &currentHC}
-		err = c.ensureLoadBalancerHealthCheck(elbDesc, protocol, port, path, annotations)
+		err = c.ensureLoadBalancerHealthCheck(newHealthCheckService(annotations), elbDesc, protocol, port, path)
 		assert.NoError(t, err)
 	})
 
+	t.Run("updates the target when the node port changes", func(t *testing.T) {
+		awsServices := newMockedFakeAWSServices(TestClusterID)
+		c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+		require.NoError(t, err)
+		expectedHC := *defaultHC
+		expectedHC.Target = aws.String("TCP:31235")
+		awsServices.elb.(*MockedFakeELB).expectConfigureHealthCheck(&lbName, &expectedHC, nil)
+
+		// the current health check still targets the previous node port 8080
+		elbDesc := &elb.LoadBalancerDescription{LoadBalancerName: &lbName, HealthCheck: defaultHC}
+		err = c.ensureLoadBalancerHealthCheck(newHealthCheckService(map[string]string{}), elbDesc, protocol, 31235, path)
+
+		require.NoError(t, err)
+		awsServices.elb.(*MockedFakeELB).AssertExpectations(t)
+	})
+
 	t.Run("validates resulting expected health check before making an API call", func(t *testing.T) {
 		awsServices := newMockedFakeAWSServices(TestClusterID)
 		c, err := newAWSCloud(config.CloudConfig{}, awsServices)
@@ -2329,7 +2872,7 @@ func TestEnsureLoadBalancerHealthCheck(t *testing.T) {
 		annotations := map[string]string{ServiceAnnotationLoadBalancerHCTimeout: "1"}
 
 		// NOTE no call expectations are set on the ELB mock
-		err = c.ensureLoadBalancerHealthCheck(elbDesc, protocol, port, path, annotations)
+		err = c.ensureLoadBalancerHealthCheck(newHealthCheckService(annotations), elbDesc, protocol, port, path)
 
 		require.Error(t, err)
 	})
@@ -2341,7 +2884,7 @@ func TestEnsureLoadBalancerHealthCheck(t *testing.T) {
 		annotations := map[string]string{ServiceAnnotationLoadBalancerHCTimeout: "3.3"}
 
 		// NOTE no call expectations are set on the ELB mock
-		err = c.ensureLoadBalancerHealthCheck(elbDesc, protocol, port, path, annotations)
+		err = c.ensureLoadBalancerHealthCheck(newHealthCheckService(annotations), elbDesc, protocol, port, path)
 
 		require.Error(t, err)
 	})
@@ -2353,11 +2896,51 @@ func TestEnsureLoadBalancerHealthCheck(t *testing.T) {
 		returnErr := fmt.Errorf("throttling error")
 		awsServices.elb.(*MockedFakeELB).expectConfigureHealthCheck(&lbName, defaultHC, returnErr)
 
-		err = c.ensureLoadBalancerHealthCheck(elbDesc, protocol, port, path, map[string]string{})
+		err = c.ensureLoadBalancerHealthCheck(newHealthCheckService(map[string]string{}), elbDesc, protocol, port, path)
 
 		require.Error(t, err)
 		awsServices.elb.(*MockedFakeELB).AssertExpectations(t)
 	})
+
+	t.Run("rejects values outside of the AWS limits", func(t *testing.T) {
+		for _, annotations := range []map[string]string{
+			{ServiceAnnotationLoadBalancerHCHealthyThreshold: "1"},
+			{ServiceAnnotationLoadBalancerHCHealthyThreshold: "11"},
+			{ServiceAnnotationLoadBalancerHCUnhealthyThreshold: "11"},
+			{ServiceAnnotationLoadBalancerHCInterval: "4"},
+			{ServiceAnnotationLoadBalancerHCInterval: "301"},
+			{ServiceAnnotationLoadBalancerHCTimeout: "61", ServiceAnnotationLoadBalancerHCInterval: "300"},
+		} {
+			awsServices := newMockedFakeAWSServices(TestClusterID)
+			c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+			require.NoError(t, err)
+
+			// NOTE no call expectations are set on the ELB mock
+			err = c.ensureLoadBalancerHealthCheck(newHealthCheckService(annotations), elbDesc, protocol, port, path)
+
+			assert.ErrorContains(t, err, "outside of the allowed range", "annotations %v", annotations)
+		}
+	})
+
+	t.Run("records an event instead of updating when the timeout isn't less than the interval", func(t *testing.T) {
+		for _, annotations := range []map[string]string{
+			{ServiceAnnotationLoadBalancerHCTimeout: "10"},
+			{ServiceAnnotationLoadBalancerHCTimeout: "20", ServiceAnnotationLoadBalancerHCInterval: "15"},
+		} {
+			awsServices := newMockedFakeAWSServices(TestClusterID)
+			c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+			require.NoError(t, err)
+			recorder := record.NewFakeRecorder(10)
+			c.eventRecorder = recorder
+
+			// NOTE no call expectations are set on the ELB mock
+			err = c.ensureLoadBalancerHealthCheck(newHealthCheckService(annotations), elbDesc, protocol, port, path)
+
+			require.NoError(t, err)
+			require.Len(t, recorder.Events, 1)
+			assert.Contains(t, <-recorder.Events, "InvalidHealthCheckTimeout")
+		}
+	})
 }
 
 func TestFindSecurityGroupForInstance(t *testing.T) {
@@ -2521,23 +3104,132 @@ func TestInstanceIDToNodeName(t *testing.T) {
 	}
 }
 
-func informerSynced() bool {
-	return true
-}
+// hybridInstanceIDResolver maps nodes to the instance ID of a label, like a resolver for hybrid nodes would
+type hybridInstanceIDResolver struct{}
 
-func informerNotSynced() bool {
-	return false
+func (hybridInstanceIDResolver) InstanceID(node *v1.Node) (InstanceID, error) {
+	if id := node.Labels["example.com/instance-id"]; id != "" {
+		return InstanceID(id), nil
+	}
+	return "", fmt.Errorf("node %q has no instance ID label", node.Name)
 }
 
-type MockedFakeELBV2 struct {
-	LoadBalancers []*elbv2.LoadBalancer
-	TargetGroups  []*elbv2.TargetGroup
-	Listeners     []*elbv2.Listener
+func TestSetInstanceIDResolver(t *testing.T) {
+	hybridNode := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "hybrid-node-1",
+			Labels: map[string]string{"example.com/instance-id": "i-self"},
+		},
+		Spec: v1.NodeSpec{ProviderID: "hybrid://datacenter-1/hybrid-node-1"},
+	}
+	awsServices := NewFakeAWSServices(TestClusterID)
+	c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+	require.NoError(t, err)
+
+	// The default resolver can't map the provider ID of the node
+	_, err = c.instanceIDResolver.InstanceID(hybridNode)
+	assert.Error(t, err)
+
+	c.SetInstanceIDResolver(hybridInstanceIDResolver{})
+	c.kubeClient = fake.NewSimpleClientset()
+	c.SetInformers(informers.NewSharedInformerFactory(c.kubeClient, 0))
+	require.NoError(t, c.nodeInformer.Informer().GetStore().Add(hybridNode))
+	c.nodeInformerHasSynced = informerSynced
+
+	instanceID, err := c.nodeNameToInstanceID("hybrid-node-1")
+	require.NoError(t, err)
+	assert.Equal(t, InstanceID("i-self"), instanceID)
+	nodeName, err := c.instanceIDToNodeName("i-self")
+	require.NoError(t, err)
+	assert.Equal(t, types.NodeName("hybrid-node-1"), nodeName)
+	instance, err := c.getInstanceByNodeName(context.TODO(), "hybrid-node-1")
+	require.NoError(t, err)
+	assert.Equal(t, "i-self", aws.StringValue(instance.InstanceId))
+	assert.Equal(t, []InstanceID{"i-self"}, mapToAWSInstanceIDsTolerant(c.instanceIDResolver, []*v1.Node{hybridNode}))
+}
+
+func TestInstanceIDLabel(t *testing.T) {
+	const instanceID = "i-0123456789abcdef0"
+	hybridNode := &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "hybrid-node-1",
+			Labels: map[string]string{"example.com/instance-id": instanceID},
+		},
+		Spec: v1.NodeSpec{ProviderID: "hybrid://datacenter-1/hybrid-node-1"},
+	}
+	instance := makeMinimalInstance(instanceID)
+	awsServices := newMockedFakeAWSServices(TestClusterID)
+	awsServices.instances = append(awsServices.instances, &instance)
+	cfg := config.CloudConfig{}
+	cfg.Global.InstanceIDLabel = "example.com/instance-id"
+	c, err := newAWSCloud(cfg, awsServices)
+	require.NoError(t, err)
+	c.kubeClient = fake.NewSimpleClientset()
+	c.SetInformers(informers.NewSharedInformerFactory(c.kubeClient, 0))
+	require.NoError(t, c.nodeInformer.Informer().GetStore().Add(hybridNode))
+	c.nodeInformerHasSynced = informerSynced
+
+	// The provider ID lookups map the node with the provider ID through the label
+	exists, err := c.InstanceExistsByProviderID(context.TODO(), hybridNode.Spec.ProviderID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	zone, err := c.GetZoneByProviderID(context.TODO(), hybridNode.Spec.ProviderID)
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2a", zone.FailureDomain)
+	instanceType, err := c.InstanceTypeByProviderID(context.TODO(), hybridNode.Spec.ProviderID)
+	require.NoError(t, err)
+	assert.Equal(t, string(ec2types.InstanceTypeC3Large), instanceType)
+	nodeInstanceID, err := c.NodeInstanceID(hybridNode)
+	require.NoError(t, err)
+	assert.Equal(t, InstanceID(instanceID), nodeInstanceID)
+
+	// Nodes without the label are mapped by their provider ID
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}, Spec: v1.NodeSpec{ProviderID: "aws:///us-west-2a/" + instanceID}}
+	nodeInstanceID, err = c.NodeInstanceID(node)
+	require.NoError(t, err)
+	assert.Equal(t, InstanceID(instanceID), nodeInstanceID)
+
+	hybridNode = hybridNode.DeepCopy()
+	hybridNode.Labels["example.com/instance-id"] = "hybrid-node-1"
+	_, err = c.NodeInstanceID(hybridNode)
+	assert.ErrorContains(t, err, "invalid instance ID")
+}
+
+func informerSynced() bool {
+	return true
+}
+
+func informerNotSynced() bool {
+	return false
+}
+
+type MockedFakeELBV2 struct {
+	LoadBalancers []*elbv2.LoadBalancer
+	TargetGroups  []*elbv2.TargetGroup
+	Listeners     []*elbv2.Listener
 
 	// keys on all of these maps are ARNs
 	LoadBalancerAttributes map[string]map[string]string
 	Tags                   map[string][]elbv2.Tag
-	RegisteredInstances    map[string][]string // value is list of instance IDs
+	RegisteredInstances    map[string][]string         // value is list of instance IDs
+	RegisteredPorts        map[string]map[string]int64 // ports of the targets registered with a port, by target ID
+	TargetGroupAttributes  map[string]map[string]string
+
+	ModifyTargetGroupCalls             int
+	ModifyTargetGroupAttributesCalls   int
+	ModifyLoadBalancerAttributesInputs []*elbv2.ModifyLoadBalancerAttributesInput
+	SetSecurityGroupsInputs            []*elbv2.SetSecurityGroupsInput
+	CreateLoadBalancerInputs           []*elbv2.CreateLoadBalancerInput
+	// ListLoadBalancersCalls counts the calls to DescribeLoadBalancers listing all the load balancers
+	ListLoadBalancersCalls int
+
+	// SecurityGroupsUnsupported rejects security groups on NLBs, like regions without NLB security groups
+	SecurityGroupsUnsupported bool
+	// ProvisioningDescribes is how many more times new load balancers are described as provisioning before they
+	// become active
+	ProvisioningDescribes int
+	// CreateLoadBalancerDelay is how long creating a load balancer takes
+	CreateLoadBalancerDelay time.Duration
 }
 
 func (m *MockedFakeELBV2) AddTags(request *elbv2.AddTagsInput) (*elbv2.AddTagsOutput, error) {
@@ -2550,7 +3242,24 @@ func (m *MockedFakeELBV2) AddTags(request *elbv2.AddTagsInput) (*elbv2.AddTagsOu
 	return &elbv2.AddTagsOutput{}, nil
 }
 
+func (m *MockedFakeELBV2) DescribeTags(request *elbv2.DescribeTagsInput) (*elbv2.DescribeTagsOutput, error) {
+	output := &elbv2.DescribeTagsOutput{}
+	for _, arn := range request.ResourceArns {
+		description := &elbv2.TagDescription{ResourceArn: arn}
+		for _, tag := range m.Tags[aws.StringValue(arn)] {
+			description.Tags = append(description.Tags, &elbv2.Tag{Key: tag.Key, Value: tag.Value})
+		}
+		output.TagDescriptions = append(output.TagDescriptions, description)
+	}
+	return output, nil
+}
+
 func (m *MockedFakeELBV2) CreateLoadBalancer(request *elbv2.CreateLoadBalancerInput) (*elbv2.CreateLoadBalancerOutput, error) {
+	if m.SecurityGroupsUnsupported && len(request.SecurityGroups) != 0 {
+		return nil, awserr.New("ValidationError", "Security groups are not supported for load balancers with type 'network'", nil)
+	}
+	time.Sleep(m.CreateLoadBalancerDelay)
+	m.CreateLoadBalancerInputs = append(m.CreateLoadBalancerInputs, request)
 	accountID := 123456789
 	arn := fmt.Sprintf("arn:aws:elasticloadbalancing:us-west-2:%d:loadbalancer/net/%x/%x",
 		accountID,
@@ -2561,7 +3270,11 @@ func (m *MockedFakeELBV2) CreateLoadBalancer(request *elbv2.CreateLoadBalancerIn
 		LoadBalancerArn:  aws.String(arn),
 		LoadBalancerName: request.Name,
 		Type:             aws.String(elbv2.LoadBalancerTypeEnumNetwork),
+		Scheme:           aws.String(elbv2.LoadBalancerSchemeEnumInternetFacing),
+		DNSName:          aws.String(fmt.Sprintf("%s-%x.elb.us-west-2.amazonaws.com", aws.StringValue(request.Name), rand.Uint32())),
+		State:            &elbv2.LoadBalancerState{Code: aws.String(elbv2.LoadBalancerStateEnumActive)},
 		VpcId:            aws.String("vpc-abc123def456abc78"),
+		SecurityGroups:   request.SecurityGroups,
 		AvailabilityZones: []*elbv2.AvailabilityZone{
 			{
 				ZoneName: aws.String("us-west-2a"),
@@ -2569,7 +3282,23 @@ func (m *MockedFakeELBV2) CreateLoadBalancer(request *elbv2.CreateLoadBalancerIn
 			},
 		},
 	}
+	if request.Scheme != nil {
+		newLB.Scheme = request.Scheme
+	}
+	if m.ProvisioningDescribes > 0 {
+		newLB.State = &elbv2.LoadBalancerState{Code: aws.String(elbv2.LoadBalancerStateEnumProvisioning)}
+	}
+	for _, mapping := range request.SubnetMappings {
+		for _, zone := range newLB.AvailabilityZones {
+			if mapping.AllocationId != nil && aws.StringValue(zone.SubnetId) == aws.StringValue(mapping.SubnetId) {
+				zone.LoadBalancerAddresses = append(zone.LoadBalancerAddresses, &elbv2.LoadBalancerAddress{AllocationId: mapping.AllocationId})
+			}
+		}
+	}
 	m.LoadBalancers = append(m.LoadBalancers, newLB)
+	for _, tag := range request.Tags {
+		m.Tags[arn] = append(m.Tags[arn], *tag)
+	}
 
 	return &elbv2.CreateLoadBalancerOutput{
 		LoadBalancers: []*elbv2.LoadBalancer{newLB},
@@ -2577,6 +3306,9 @@ func (m *MockedFakeELBV2) CreateLoadBalancer(request *elbv2.CreateLoadBalancerIn
 }
 
 func (m *MockedFakeELBV2) DescribeLoadBalancers(request *elbv2.DescribeLoadBalancersInput) (*elbv2.DescribeLoadBalancersOutput, error) {
+	if len(request.Names) == 0 && len(request.LoadBalancerArns) == 0 {
+		m.ListLoadBalancersCalls++
+	}
 	findMeNames := make(map[string]bool)
 	for _, name := range request.Names {
 		findMeNames[aws.StringValue(name)] = true
@@ -2590,7 +3322,16 @@ func (m *MockedFakeELBV2) DescribeLoadBalancers(request *elbv2.DescribeLoadBalan
 	result := []*elbv2.LoadBalancer{}
 
 	for _, lb := range m.LoadBalancers {
-		if _, present := findMeNames[aws.StringValue(lb.LoadBalancerName)]; present {
+		if aws.StringValue(lb.State.Code) == elbv2.LoadBalancerStateEnumProvisioning {
+			if m.ProvisioningDescribes > 0 {
+				m.ProvisioningDescribes--
+			} else {
+				lb.State = &elbv2.LoadBalancerState{Code: aws.String(elbv2.LoadBalancerStateEnumActive)}
+			}
+		}
+		if len(request.Names) == 0 && len(request.LoadBalancerArns) == 0 {
+			result = append(result, lb)
+		} else if _, present := findMeNames[aws.StringValue(lb.LoadBalancerName)]; present {
 			result = append(result, lb)
 			delete(findMeNames, aws.StringValue(lb.LoadBalancerName))
 		} else if _, present := findMeARNs[aws.StringValue(lb.LoadBalancerArn)]; present {
@@ -2608,11 +3349,28 @@ func (m *MockedFakeELBV2) DescribeLoadBalancers(request *elbv2.DescribeLoadBalan
 	}, nil
 }
 
-func (m *MockedFakeELBV2) DeleteLoadBalancer(*elbv2.DeleteLoadBalancerInput) (*elbv2.DeleteLoadBalancerOutput, error) {
-	panic("Not implemented")
+func (m *MockedFakeELBV2) DeleteLoadBalancer(request *elbv2.DeleteLoadBalancerInput) (*elbv2.DeleteLoadBalancerOutput, error) {
+	newLoadBalancers := []*elbv2.LoadBalancer{}
+	for _, lb := range m.LoadBalancers {
+		if aws.StringValue(lb.LoadBalancerArn) != aws.StringValue(request.LoadBalancerArn) {
+			newLoadBalancers = append(newLoadBalancers, lb)
+		}
+	}
+	m.LoadBalancers = newLoadBalancers
+
+	newListeners := []*elbv2.Listener{}
+	for _, listener := range m.Listeners {
+		if aws.StringValue(listener.LoadBalancerArn) != aws.StringValue(request.LoadBalancerArn) {
+			newListeners = append(newListeners, listener)
+		}
+	}
+	m.Listeners = newListeners
+
+	return &elbv2.DeleteLoadBalancerOutput{}, nil
 }
 
 func (m *MockedFakeELBV2) ModifyLoadBalancerAttributes(request *elbv2.ModifyLoadBalancerAttributesInput) (*elbv2.ModifyLoadBalancerAttributesOutput, error) {
+	m.ModifyLoadBalancerAttributesInputs = append(m.ModifyLoadBalancerAttributesInputs, request)
 	attrMap, present := m.LoadBalancerAttributes[aws.StringValue(request.LoadBalancerArn)]
 
 	if !present {
@@ -2658,6 +3416,7 @@ func (m *MockedFakeELBV2) CreateTargetGroup(request *elbv2.CreateTargetGroupInpu
 		TargetGroupName:            request.Name,
 		Port:                       request.Port,
 		Protocol:                   request.Protocol,
+		TargetType:                 request.TargetType,
 		HealthCheckProtocol:        request.HealthCheckProtocol,
 		HealthCheckPath:            request.HealthCheckPath,
 		HealthCheckPort:            request.HealthCheckPort,
@@ -2730,6 +3489,7 @@ func (m *MockedFakeELBV2) ModifyTargetGroup(request *elbv2.ModifyTargetGroupInpu
 		}
 	}
 
+	m.ModifyTargetGroupCalls++
 	if matchingTargetGroup != nil {
 		dirtyGroups = append(dirtyGroups, matchingTargetGroup)
 
@@ -2797,11 +3557,15 @@ func (m *MockedFakeELBV2) DescribeTargetHealth(request *elbv2.DescribeTargetHeal
 
 	if registeredTargets, present := m.RegisteredInstances[aws.StringValue(request.TargetGroupArn)]; present {
 		for _, target := range registeredTargets {
+			port := matchingTargetGroup.Port
+			if registeredPort, ok := m.RegisteredPorts[aws.StringValue(request.TargetGroupArn)][target]; ok {
+				port = aws.Int64(registeredPort)
+			}
 			healthDescriptions = append(healthDescriptions, &elbv2.TargetHealthDescription{
 				HealthCheckPort: matchingTargetGroup.HealthCheckPort,
 				Target: &elbv2.TargetDescription{
 					Id:   aws.String(target),
-					Port: matchingTargetGroup.Port,
+					Port: port,
 				},
 				TargetHealth: &elbv2.TargetHealth{
 					State: aws.String("healthy"),
@@ -2815,12 +3579,39 @@ func (m *MockedFakeELBV2) DescribeTargetHealth(request *elbv2.DescribeTargetHeal
 	}, nil
 }
 
-func (m *MockedFakeELBV2) DescribeTargetGroupAttributes(*elbv2.DescribeTargetGroupAttributesInput) (*elbv2.DescribeTargetGroupAttributesOutput, error) {
-	panic("Not implemented")
+func (m *MockedFakeELBV2) DescribeTargetGroupAttributes(request *elbv2.DescribeTargetGroupAttributesInput) (*elbv2.DescribeTargetGroupAttributesOutput, error) {
+	attrs := []*elbv2.TargetGroupAttribute{}
+
+	for key, value := range m.TargetGroupAttributes[aws.StringValue(request.TargetGroupArn)] {
+		attrs = append(attrs, &elbv2.TargetGroupAttribute{
+			Key:   aws.String(key),
+			Value: aws.String(value),
+		})
+	}
+
+	return &elbv2.DescribeTargetGroupAttributesOutput{
+		Attributes: attrs,
+	}, nil
 }
 
-func (m *MockedFakeELBV2) ModifyTargetGroupAttributes(*elbv2.ModifyTargetGroupAttributesInput) (*elbv2.ModifyTargetGroupAttributesOutput, error) {
-	panic("Not implemented")
+func (m *MockedFakeELBV2) ModifyTargetGroupAttributes(request *elbv2.ModifyTargetGroupAttributesInput) (*elbv2.ModifyTargetGroupAttributesOutput, error) {
+	m.ModifyTargetGroupAttributesCalls++
+	if m.TargetGroupAttributes == nil {
+		m.TargetGroupAttributes = make(map[string]map[string]string)
+	}
+	attrMap, present := m.TargetGroupAttributes[aws.StringValue(request.TargetGroupArn)]
+	if !present {
+		attrMap = make(map[string]string)
+		m.TargetGroupAttributes[aws.StringValue(request.TargetGroupArn)] = attrMap
+	}
+
+	for _, attr := range request.Attributes {
+		attrMap[aws.StringValue(attr.Key)] = aws.StringValue(attr.Value)
+	}
+
+	return &elbv2.ModifyTargetGroupAttributesOutput{
+		Attributes: request.Attributes,
+	}, nil
 }
 
 func (m *MockedFakeELBV2) RegisterTargets(request *elbv2.RegisterTargetsInput) (*elbv2.RegisterTargetsOutput, error) {
@@ -2833,6 +3624,15 @@ func (m *MockedFakeELBV2) RegisterTargets(request *elbv2.RegisterTargetsInput) (
 		if !alreadyExists[aws.StringValue(target.Id)] {
 			m.RegisteredInstances[arn] = append(m.RegisteredInstances[arn], aws.StringValue(target.Id))
 		}
+		if target.Port != nil {
+			if m.RegisteredPorts == nil {
+				m.RegisteredPorts = map[string]map[string]int64{}
+			}
+			if m.RegisteredPorts[arn] == nil {
+				m.RegisteredPorts[arn] = map[string]int64{}
+			}
+			m.RegisteredPorts[arn][aws.StringValue(target.Id)] = aws.Int64Value(target.Port)
+		}
 	}
 	return &elbv2.RegisterTargetsOutput{}, nil
 }
@@ -2841,6 +3641,10 @@ func (m *MockedFakeELBV2) DeregisterTargets(request *elbv2.DeregisterTargetsInpu
 	removeMe := make(map[string]bool)
 
 	for _, target := range request.Targets {
+		// A target re-registered on another port stays registered
+		if port, ok := m.RegisteredPorts[aws.StringValue(request.TargetGroupArn)][aws.StringValue(target.Id)]; ok && target.Port != nil && port != aws.Int64Value(target.Port) {
+			continue
+		}
 		removeMe[aws.StringValue(target.Id)] = true
 	}
 	newRegisteredInstancesForArn := []string{}
@@ -2868,6 +3672,8 @@ func (m *MockedFakeELBV2) CreateListener(request *elbv2.CreateListenerInput) (*e
 		Protocol:        request.Protocol,
 		DefaultActions:  request.DefaultActions,
 		LoadBalancerArn: request.LoadBalancerArn,
+		SslPolicy:       request.SslPolicy,
+		Certificates:    request.Certificates,
 	}
 
 	m.Listeners = append(m.Listeners, newListener)
@@ -2964,6 +3770,8 @@ func (m *MockedFakeELBV2) ModifyListener(request *elbv2.ModifyListenerInput) (*e
 			if request.Protocol != nil {
 				listener.Protocol = request.Protocol
 			}
+			listener.SslPolicy = request.SslPolicy
+			listener.Certificates = request.Certificates
 
 			modifiedListeners = append(modifiedListeners, listener)
 		}
@@ -2974,8 +3782,20 @@ func (m *MockedFakeELBV2) ModifyListener(request *elbv2.ModifyListenerInput) (*e
 	}, nil
 }
 
+func (m *MockedFakeELBV2) SetSecurityGroups(request *elbv2.SetSecurityGroupsInput) (*elbv2.SetSecurityGroupsOutput, error) {
+	m.SetSecurityGroupsInputs = append(m.SetSecurityGroupsInputs, request)
+	for _, lb := range m.LoadBalancers {
+		if aws.StringValue(lb.LoadBalancerArn) == aws.StringValue(request.LoadBalancerArn) {
+			lb.SecurityGroups = request.SecurityGroups
+			return &elbv2.SetSecurityGroupsOutput{SecurityGroupIds: request.SecurityGroups}, nil
+		}
+	}
+	return nil, awserr.New(elbv2.ErrCodeLoadBalancerNotFoundException, "not found", nil)
+}
+
 func (m *MockedFakeELBV2) WaitUntilLoadBalancersDeleted(*elbv2.DescribeLoadBalancersInput) error {
-	panic("Not implemented")
+	// Load balancers are deleted immediately
+	return nil
 }
 
 func (m *MockedFakeEC2) maybeExpectDescribeSecurityGroups(clusterID, groupName string) {
@@ -3056,44 +3876,2066 @@ func TestNLBNodeRegistration(t *testing.T) {
 		},
 	}
 
-	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, fauxService, nodes)
-	if err != nil {
-		t.Errorf("EnsureLoadBalancer returned an error: %v", err)
-	}
-	for _, instances := range awsServices.elbv2.(*MockedFakeELBV2).RegisteredInstances {
-		if len(instances) != 3 {
-			t.Errorf("Expected 3 nodes registered with target group, saw %d", len(instances))
+	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, fauxService, nodes)
+	if err != nil {
+		t.Errorf("EnsureLoadBalancer returned an error: %v", err)
+	}
+	for _, instances := range awsServices.elbv2.(*MockedFakeELBV2).RegisteredInstances {
+		if len(instances) != 3 {
+			t.Errorf("Expected 3 nodes registered with target group, saw %d", len(instances))
+		}
+	}
+
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, fauxService, nodes[:2])
+	if err != nil {
+		t.Errorf("EnsureLoadBalancer returned an error: %v", err)
+	}
+	for _, instances := range awsServices.elbv2.(*MockedFakeELBV2).RegisteredInstances {
+		if len(instances) != 2 {
+			t.Errorf("Expected 2 nodes registered with target group, saw %d", len(instances))
+		}
+	}
+
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, fauxService, nodes)
+	if err != nil {
+		t.Errorf("EnsureLoadBalancer returned an error: %v", err)
+	}
+	for _, instances := range awsServices.elbv2.(*MockedFakeELBV2).RegisteredInstances {
+		if len(instances) != 3 {
+			t.Errorf("Expected 3 nodes registered with target group, saw %d", len(instances))
+		}
+	}
+
+	fauxService.Annotations[ServiceAnnotationLoadBalancerHealthCheckProtocol] = "http"
+	tgARN := aws.StringValue(awsServices.elbv2.(*MockedFakeELBV2).Listeners[0].DefaultActions[0].TargetGroupArn)
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, fauxService, nodes)
+	if err != nil {
+		t.Errorf("EnsureLoadBalancer returned an error: %v", err)
+	}
+	assert.Equal(t, 1, len(awsServices.elbv2.(*MockedFakeELBV2).Listeners))
+	assert.NotEqual(t, tgARN, aws.StringValue(awsServices.elbv2.(*MockedFakeELBV2).Listeners[0].DefaultActions[0].TargetGroupArn))
+}
+
+// newMockedNLBCloud returns a Cloud backed by a MockedFakeELBV2, with a single
+// owned public subnet and three nodes to register as NLB targets.
+func newMockedNLBCloud(t *testing.T) (*Cloud, *FakeAWSServices, []*v1.Node) {
+	return newMockedNLBCloudWithConfig(t, config.CloudConfig{})
+}
+
+// newMockedNLBCloudWithConfig is newMockedNLBCloud with the given cloud config.
+func newMockedNLBCloudWithConfig(t *testing.T, cfg config.CloudConfig) (*Cloud, *FakeAWSServices, []*v1.Node) {
+	awsServices := newMockedFakeAWSServices(TestClusterID)
+	awsServices.elbv2 = &MockedFakeELBV2{Tags: make(map[string][]elbv2.Tag), RegisteredInstances: make(map[string][]string), LoadBalancerAttributes: make(map[string]map[string]string)}
+	c, err := newAWSCloud(cfg, awsServices)
+	require.NoError(t, err)
+
+	awsServices.ec2.(*MockedFakeEC2).Subnets = []ec2types.Subnet{
+		{
+			AvailabilityZone: aws.String("us-west-2a"),
+			SubnetId:         aws.String("subnet-abc123de"),
+			Tags: []ec2types.Tag{
+				{
+					Key:   aws.String(c.tagging.clusterTagKey()),
+					Value: aws.String("owned"),
+				},
+			},
+		},
+	}
+	awsServices.ec2.(*MockedFakeEC2).RouteTables = []ec2types.RouteTable{
+		{
+			Associations: []ec2types.RouteTableAssociation{
+				{
+					Main:                    aws.Bool(true),
+					RouteTableAssociationId: aws.String("rtbassoc-abc123def456abc78"),
+					RouteTableId:            aws.String("rtb-abc123def456abc78"),
+					SubnetId:                aws.String("subnet-abc123de"),
+				},
+			},
+			RouteTableId: aws.String("rtb-abc123def456abc78"),
+			Routes: []ec2types.Route{
+				{
+					DestinationCidrBlock: aws.String("0.0.0.0/0"),
+					GatewayId:            aws.String("igw-abc123def456abc78"),
+					State:                ec2types.RouteStateActive,
+				},
+			},
+		},
+	}
+	awsServices.ec2.(*MockedFakeEC2).maybeExpectDescribeSecurityGroups(TestClusterID, "k8s-elb-aid")
+
+	nodes := []*v1.Node{makeNamedNode(awsServices, 0, "a"), makeNamedNode(awsServices, 1, "b"), makeNamedNode(awsServices, 2, "c")}
+	return c, awsServices, nodes
+}
+
+func newNLBService(annotations map[string]string) *v1.Service {
+	annotations[ServiceAnnotationLoadBalancerType] = "nlb"
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "myservice",
+			UID:         "id",
+			Annotations: annotations,
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{
+				{
+					Name:       "http",
+					Port:       8080,
+					NodePort:   31173,
+					TargetPort: intstr.FromInt(31173),
+					Protocol:   v1.ProtocolTCP,
+				},
+			},
+			SessionAffinity: v1.ServiceAffinityNone,
+		},
+	}
+}
+
+func TestNLBEIPAllocations(t *testing.T) {
+	newCloud := func(t *testing.T) (*Cloud, *MockedFakeELBV2, []*v1.Node) {
+		c, awsServices, nodes := newMockedNLBCloud(t)
+		awsServices.ec2.(*MockedFakeEC2).Addresses = []ec2types.Address{
+			{AllocationId: aws.String("eipalloc-1"), NetworkBorderGroup: aws.String("us-west-2")},
+			{AllocationId: aws.String("eipalloc-2"), NetworkBorderGroup: aws.String("us-west-2")},
+			{AllocationId: aws.String("eipalloc-lax"), NetworkBorderGroup: aws.String("us-west-2-lax-1")},
+		}
+		return c, awsServices.elbv2.(*MockedFakeELBV2), nodes
+	}
+
+	for _, tc := range []struct {
+		name          string
+		annotations   map[string]string
+		expectedError string
+	}{
+		{
+			name:        "one allocation per subnet",
+			annotations: map[string]string{ServiceAnnotationLoadBalancerEIPAllocations: "eipalloc-1"},
+		},
+		{
+			name:          "more allocations than subnets",
+			annotations:   map[string]string{ServiceAnnotationLoadBalancerEIPAllocations: "eipalloc-1,eipalloc-2"},
+			expectedError: "must have same number of EIP AllocationIDs (2) and SubnetIDs (1)",
+		},
+		{
+			name: "internal",
+			annotations: map[string]string{
+				ServiceAnnotationLoadBalancerEIPAllocations: "eipalloc-1",
+				ServiceAnnotationLoadBalancerInternal:       "true",
+			},
+			expectedError: "only supported by internet-facing load balancers",
+		},
+		{
+			name:          "unknown allocation",
+			annotations:   map[string]string{ServiceAnnotationLoadBalancerEIPAllocations: "eipalloc-unknown"},
+			expectedError: `EIP allocation "eipalloc-unknown" from annotation ` + ServiceAnnotationLoadBalancerEIPAllocations + " was not found",
+		},
+		{
+			name:          "allocation from another availability zone",
+			annotations:   map[string]string{ServiceAnnotationLoadBalancerEIPAllocations: "eipalloc-lax"},
+			expectedError: `EIP allocation "eipalloc-lax" from network border group "us-west-2-lax-1" can't be used by subnet "subnet-abc123de"`,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, elbv2Mock, nodes := newCloud(t)
+
+			_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, newNLBService(tc.annotations), nodes)
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, err, tc.expectedError)
+				assert.Empty(t, elbv2Mock.CreateLoadBalancerInputs)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, elbv2Mock.CreateLoadBalancerInputs, 1)
+			assert.Equal(t, []*elbv2.SubnetMapping{{SubnetId: aws.String("subnet-abc123de"), AllocationId: aws.String("eipalloc-1")}},
+				elbv2Mock.CreateLoadBalancerInputs[0].SubnetMappings)
+		})
+	}
+
+	t.Run("allocation change", func(t *testing.T) {
+		c, elbv2Mock, nodes := newCloud(t)
+		recorder := record.NewFakeRecorder(10)
+		c.eventRecorder = recorder
+		svc := newNLBService(map[string]string{ServiceAnnotationLoadBalancerEIPAllocations: "eipalloc-1"})
+
+		_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+		require.NoError(t, err)
+		_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+		require.NoError(t, err)
+		assert.Len(t, elbv2Mock.CreateLoadBalancerInputs, 1, "unchanged allocations don't recreate the load balancer")
+
+		svc.Annotations[ServiceAnnotationLoadBalancerEIPAllocations] = "eipalloc-2"
+		_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+		require.NoError(t, err)
+		require.Len(t, elbv2Mock.CreateLoadBalancerInputs, 2)
+		assert.Equal(t, "eipalloc-2", aws.StringValue(elbv2Mock.CreateLoadBalancerInputs[1].SubnetMappings[0].AllocationId))
+		require.Len(t, elbv2Mock.LoadBalancers, 1)
+		assert.Len(t, recorder.Events, 1)
+		assert.Contains(t, <-recorder.Events, "LoadBalancerEIPAllocationsChange")
+	})
+}
+
+func TestEnsureLoadBalancerConcurrentReconciles(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	service := newNLBService(map[string]string{})
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+	// The second reconcile would describe the load balancer while the first creates it
+	elbv2Mock.CreateLoadBalancerDelay = 100 * time.Millisecond
+
+	// Two reconciles of the same service, e.g. of the service controller and the node readiness queue
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = c.EnsureLoadBalancer(context.TODO(), TestClusterName, service.DeepCopy(), nodes)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	assert.Len(t, elbv2Mock.CreateLoadBalancerInputs, 1)
+	assert.Len(t, elbv2Mock.LoadBalancers, 1)
+	assert.Len(t, elbv2Mock.TargetGroups, 1)
+}
+
+func TestNLBWaitForActive(t *testing.T) {
+	defer func(backoff wait.Backoff) { loadBalancerProvisioningBackoff = backoff }(loadBalancerProvisioningBackoff)
+	loadBalancerProvisioningBackoff = wait.Backoff{Duration: time.Millisecond, Factor: 2, Steps: 4}
+
+	t.Run("active", func(t *testing.T) {
+		c, awsServices, nodes := newMockedNLBCloud(t)
+		elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+		elbv2Mock.ProvisioningDescribes = 3
+
+		status, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, newNLBService(map[string]string{}), nodes)
+		require.NoError(t, err)
+		assert.Zero(t, elbv2Mock.ProvisioningDescribes, "the load balancer is described until it is active")
+		require.Len(t, elbv2Mock.LoadBalancers, 1)
+		assert.Equal(t, elbv2.LoadBalancerStateEnumActive, aws.StringValue(elbv2Mock.LoadBalancers[0].State.Code))
+		assert.Equal(t, []v1.LoadBalancerIngress{{Hostname: aws.StringValue(elbv2Mock.LoadBalancers[0].DNSName)}}, status.Ingress)
+	})
+
+	t.Run("still provisioning", func(t *testing.T) {
+		c, awsServices, nodes := newMockedNLBCloud(t)
+		awsServices.elbv2.(*MockedFakeELBV2).ProvisioningDescribes = 100
+
+		_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, newNLBService(map[string]string{}), nodes)
+		assert.ErrorContains(t, err, "is still provisioning")
+	})
+}
+
+func TestNLBScheme(t *testing.T) {
+	newCloud := func(t *testing.T) (*Cloud, *MockedFakeELBV2, []*v1.Node) {
+		c, awsServices, nodes := newMockedNLBCloud(t)
+		// Add a private subnet next to the public subnet
+		ec2Mock := awsServices.ec2.(*MockedFakeEC2)
+		ec2Mock.Subnets = append(ec2Mock.Subnets, ec2types.Subnet{
+			AvailabilityZone: aws.String("us-west-2a"),
+			SubnetId:         aws.String("subnet-private"),
+			Tags:             []ec2types.Tag{{Key: aws.String(c.tagging.clusterTagKey()), Value: aws.String("owned")}},
+		})
+		ec2Mock.RouteTables = append(ec2Mock.RouteTables, ec2types.RouteTable{
+			RouteTableId: aws.String("rtb-private"),
+			Associations: []ec2types.RouteTableAssociation{{RouteTableId: aws.String("rtb-private"), SubnetId: aws.String("subnet-private")}},
+		})
+		return c, awsServices.elbv2.(*MockedFakeELBV2), nodes
+	}
+	subnetIDs := func(request *elbv2.CreateLoadBalancerInput) []string {
+		return lo.Map(request.SubnetMappings, func(mapping *elbv2.SubnetMapping, _ int) string { return aws.StringValue(mapping.SubnetId) })
+	}
+
+	for _, tc := range []struct {
+		name           string
+		annotations    map[string]string
+		expectedScheme string
+		expectedSubnet string
+	}{
+		{
+			name:           "internet-facing",
+			annotations:    map[string]string{},
+			expectedScheme: elbv2.LoadBalancerSchemeEnumInternetFacing,
+			expectedSubnet: "subnet-abc123de",
+		},
+		{
+			name:           "internal",
+			annotations:    map[string]string{ServiceAnnotationLoadBalancerInternal: "true"},
+			expectedScheme: elbv2.LoadBalancerSchemeEnumInternal,
+			expectedSubnet: "subnet-private",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, elbv2Mock, nodes := newCloud(t)
+
+			_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, newNLBService(tc.annotations), nodes)
+			require.NoError(t, err)
+			require.Len(t, elbv2Mock.CreateLoadBalancerInputs, 1)
+			assert.Equal(t, []string{tc.expectedSubnet}, subnetIDs(elbv2Mock.CreateLoadBalancerInputs[0]))
+			require.Len(t, elbv2Mock.LoadBalancers, 1)
+			assert.Equal(t, tc.expectedScheme, aws.StringValue(elbv2Mock.LoadBalancers[0].Scheme))
+		})
+	}
+
+	t.Run("scheme change", func(t *testing.T) {
+		c, elbv2Mock, nodes := newCloud(t)
+		recorder := record.NewFakeRecorder(10)
+		c.eventRecorder = recorder
+
+		svc := newNLBService(map[string]string{})
+		_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+		require.NoError(t, err)
+		require.Len(t, elbv2Mock.LoadBalancers, 1)
+		oldLoadBalancer := elbv2Mock.LoadBalancers[0]
+		oldTargetGroupARN := aws.StringValue(elbv2Mock.TargetGroups[0].TargetGroupArn)
+
+		// Reconciling without a change doesn't recreate the load balancer
+		_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+		require.NoError(t, err)
+		assert.Len(t, elbv2Mock.CreateLoadBalancerInputs, 1)
+		assert.Empty(t, recorder.Events)
+
+		svc.Annotations[ServiceAnnotationLoadBalancerInternal] = "true"
+		status, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+		require.NoError(t, err)
+		require.Len(t, elbv2Mock.LoadBalancers, 1)
+		newLoadBalancer := elbv2Mock.LoadBalancers[0]
+		assert.NotEqual(t, aws.StringValue(oldLoadBalancer.LoadBalancerArn), aws.StringValue(newLoadBalancer.LoadBalancerArn))
+		assert.Equal(t, elbv2.LoadBalancerSchemeEnumInternal, aws.StringValue(newLoadBalancer.Scheme))
+		assert.Equal(t, []string{"subnet-private"}, subnetIDs(elbv2Mock.CreateLoadBalancerInputs[1]))
+		assert.Equal(t, aws.StringValue(newLoadBalancer.DNSName), status.Ingress[0].Hostname)
+
+		// The target groups and listeners of the old load balancer are replaced
+		require.Len(t, elbv2Mock.TargetGroups, 1)
+		assert.NotEqual(t, oldTargetGroupARN, aws.StringValue(elbv2Mock.TargetGroups[0].TargetGroupArn))
+		require.Len(t, elbv2Mock.Listeners, 1)
+		assert.Equal(t, aws.StringValue(newLoadBalancer.LoadBalancerArn), aws.StringValue(elbv2Mock.Listeners[0].LoadBalancerArn))
+
+		require.Len(t, recorder.Events, 1)
+		event := <-recorder.Events
+		assert.Contains(t, event, "Warning LoadBalancerSchemeChange")
+		assert.Contains(t, event, aws.StringValue(oldLoadBalancer.DNSName))
+	})
+}
+
+func TestNLBStatusStaticAddresses(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+	svc := newNLBService(map[string]string{})
+
+	// Without static addresses only the hostname is reported
+	status, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	require.Len(t, elbv2Mock.LoadBalancers, 1)
+	hostname := aws.StringValue(elbv2Mock.LoadBalancers[0].DNSName)
+	assert.Equal(t, []v1.LoadBalancerIngress{{Hostname: hostname}}, status.Ingress)
+
+	// The elastic IPs and IPv6 addresses of a dualstack NLB are only reported when the status address type asks for
+	// them
+	elbv2Mock.LoadBalancers[0].IpAddressType = aws.String(elbv2.IpAddressTypeDualstack)
+	elbv2Mock.LoadBalancers[0].AvailabilityZones[0].LoadBalancerAddresses = []*elbv2.LoadBalancerAddress{
+		{IpAddress: aws.String("3.3.3.3"), AllocationId: aws.String("eipalloc-1"), IPv6Address: aws.String("2600:1f14::1")},
+		{IpAddress: aws.String("3.3.3.3"), AllocationId: aws.String("eipalloc-1")},
+	}
+	status, exists, err := c.GetLoadBalancer(context.TODO(), TestClusterName, svc)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, []v1.LoadBalancerIngress{{Hostname: hostname}}, status.Ingress)
+
+	svc.Annotations[ServiceAnnotationLoadBalancerStatusAddressType] = statusAddressTypeIP
+	status, exists, err = c.GetLoadBalancer(context.TODO(), TestClusterName, svc)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	proxy := v1.LoadBalancerIPModeProxy
+	assert.Equal(t, []v1.LoadBalancerIngress{
+		{IP: "3.3.3.3", IPMode: &proxy},
+		{IP: "2600:1f14::1", IPMode: &proxy},
+	}, status.Ingress)
+}
+
+func TestNLBStatusAddressType(t *testing.T) {
+	newCloud := func(t *testing.T) (*Cloud, *MockedFakeELBV2, []*v1.Node) {
+		c, awsServices, nodes := newMockedNLBCloud(t)
+		awsServices.ec2.(*MockedFakeEC2).Addresses = []ec2types.Address{
+			{AllocationId: aws.String("eipalloc-1"), PublicIp: aws.String("3.3.3.3"), NetworkBorderGroup: aws.String("us-west-2")},
+		}
+		return c, awsServices.elbv2.(*MockedFakeELBV2), nodes
+	}
+	proxy := v1.LoadBalancerIPModeProxy
+
+	for _, addressType := range []string{"", statusAddressTypeHostname, statusAddressTypeIP} {
+		t.Run("address type "+addressType, func(t *testing.T) {
+			c, elbv2Mock, nodes := newCloud(t)
+			annotations := map[string]string{ServiceAnnotationLoadBalancerEIPAllocations: "eipalloc-1"}
+			if addressType != "" {
+				annotations[ServiceAnnotationLoadBalancerStatusAddressType] = addressType
+			}
+			svc := newNLBService(annotations)
+			_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+			require.NoError(t, err)
+			require.Len(t, elbv2Mock.LoadBalancers, 1)
+			lb := elbv2Mock.LoadBalancers[0]
+			lb.AvailabilityZones[0].LoadBalancerAddresses[0].IpAddress = aws.String("3.3.3.3")
+
+			expected := []v1.LoadBalancerIngress{{Hostname: aws.StringValue(lb.DNSName)}}
+			if addressType == statusAddressTypeIP {
+				expected = []v1.LoadBalancerIngress{{IP: "3.3.3.3", IPMode: &proxy}}
+			}
+			status, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+			require.NoError(t, err)
+			assert.Equal(t, expected, status.Ingress)
+			status, _, err = c.GetLoadBalancer(context.TODO(), TestClusterName, svc)
+			require.NoError(t, err)
+			assert.Equal(t, expected, status.Ingress)
+			assert.Len(t, elbv2Mock.CreateLoadBalancerInputs, 1)
+		})
+	}
+
+	t.Run("ip requires static IPs", func(t *testing.T) {
+		c, elbv2Mock, nodes := newCloud(t)
+		svc := newNLBService(map[string]string{ServiceAnnotationLoadBalancerStatusAddressType: statusAddressTypeIP})
+		_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+		assert.ErrorContains(t, err, "requires static IPs")
+		assert.Empty(t, elbv2Mock.CreateLoadBalancerInputs)
+	})
+
+	t.Run("invalid address type", func(t *testing.T) {
+		c, elbv2Mock, nodes := newCloud(t)
+		svc := newNLBService(map[string]string{ServiceAnnotationLoadBalancerStatusAddressType: "dns"})
+		_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+		assert.ErrorContains(t, err, `invalid value "dns" for annotation `+ServiceAnnotationLoadBalancerStatusAddressType)
+		assert.Empty(t, elbv2Mock.CreateLoadBalancerInputs)
+	})
+}
+
+func TestNLBDeletionProtection(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+	deletionProtection := func() string {
+		require.Len(t, elbv2Mock.LoadBalancers, 1)
+		return elbv2Mock.LoadBalancerAttributes[aws.StringValue(elbv2Mock.LoadBalancers[0].LoadBalancerArn)][lbAttrDeletionProtectionEnabled]
+	}
+
+	svc := newNLBService(map[string]string{ServiceAnnotationLoadBalancerDeletionProtection: "true"})
+	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, "true", deletionProtection())
+
+	// The load balancer isn't deleted while the annotation requires the protection
+	err = c.EnsureLoadBalancerDeleted(context.TODO(), TestClusterName, svc)
+	assert.ErrorContains(t, err, "has deletion protection enabled")
+	assert.Len(t, elbv2Mock.LoadBalancers, 1)
+
+	// Deletion protection is disabled before the load balancer is deleted once the annotation is removed
+	delete(svc.Annotations, ServiceAnnotationLoadBalancerDeletionProtection)
+	require.NoError(t, c.EnsureLoadBalancerDeleted(context.TODO(), TestClusterName, svc))
+	assert.Empty(t, elbv2Mock.LoadBalancers)
+	lastInput := elbv2Mock.ModifyLoadBalancerAttributesInputs[len(elbv2Mock.ModifyLoadBalancerAttributesInputs)-1]
+	assert.Equal(t, []*elbv2.LoadBalancerAttribute{{Key: aws.String(lbAttrDeletionProtectionEnabled), Value: aws.String("false")}}, lastInput.Attributes)
+
+	// Setting the annotation to false disables the protection when reconciling
+	svc.Annotations[ServiceAnnotationLoadBalancerDeletionProtection] = "true"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, "true", deletionProtection())
+	svc.Annotations[ServiceAnnotationLoadBalancerDeletionProtection] = "false"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, "false", deletionProtection())
+	require.NoError(t, c.EnsureLoadBalancerDeleted(context.TODO(), TestClusterName, svc))
+	assert.Empty(t, elbv2Mock.LoadBalancers)
+
+	// Without the annotation, deletion protection enabled outside of the cluster is left alone
+	delete(svc.Annotations, ServiceAnnotationLoadBalancerDeletionProtection)
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	elbv2Mock.LoadBalancerAttributes[aws.StringValue(elbv2Mock.LoadBalancers[0].LoadBalancerArn)][lbAttrDeletionProtectionEnabled] = "true"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, "true", deletionProtection())
+	require.NoError(t, c.EnsureLoadBalancerDeleted(context.TODO(), TestClusterName, svc))
+	assert.Empty(t, elbv2Mock.LoadBalancers)
+
+	svc.Annotations[ServiceAnnotationLoadBalancerDeletionProtection] = "yes"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	assert.ErrorContains(t, err, "error parsing service annotation: "+ServiceAnnotationLoadBalancerDeletionProtection)
+}
+
+func TestNLBSecurityGroups(t *testing.T) {
+	const securityGroupID = "sg-k8s-elb-aid"
+	newCloud := func(t *testing.T) (*Cloud, *FakeAWSServices, []*v1.Node) {
+		cfg := config.CloudConfig{}
+		cfg.Global.EnableNLBSecurityGroups = true
+		c, awsServices, nodes := newMockedNLBCloudWithConfig(t, cfg)
+		awsServices.ec2.(*MockedFakeEC2).fakeSecurityGroups = true
+		return c, awsServices, nodes
+	}
+	findSecurityGroup := func(awsServices *FakeAWSServices) *ec2types.SecurityGroup {
+		for _, sg := range awsServices.ec2.(*MockedFakeEC2).SecurityGroups {
+			if aws.StringValue(sg.GroupId) == securityGroupID {
+				return &sg
+			}
+		}
+		return nil
+	}
+	ingress := func(cidr string, protocol string, fromPort, toPort int32) ec2types.IpPermission {
+		return ec2types.IpPermission{
+			IpProtocol: aws.String(protocol),
+			FromPort:   aws.Int32(fromPort),
+			ToPort:     aws.Int32(toPort),
+			IpRanges:   []ec2types.IpRange{{CidrIp: aws.String(cidr)}},
+		}
+	}
+	ingressIPv6 := func(cidr string, protocol string, fromPort, toPort int32) ec2types.IpPermission {
+		return ec2types.IpPermission{
+			IpProtocol: aws.String(protocol),
+			FromPort:   aws.Int32(fromPort),
+			ToPort:     aws.Int32(toPort),
+			Ipv6Ranges: []ec2types.Ipv6Range{{CidrIpv6: aws.String(cidr)}},
+		}
+	}
+
+	t.Run("source ranges", func(t *testing.T) {
+		c, awsServices, nodes := newCloud(t)
+
+		svc := newNLBService(map[string]string{})
+		svc.Spec.LoadBalancerSourceRanges = []string{"10.0.0.0/8", "192.168.0.0/16", "2001:db8::/32"}
+		_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []ec2types.IpPermission{
+			ingress("10.0.0.0/8", "tcp", 8080, 8080),
+			ingress("192.168.0.0/16", "tcp", 8080, 8080),
+			ingressIPv6("2001:db8::/32", "tcp", 8080, 8080),
+			ingress("10.0.0.0/8", "icmp", 3, 4),
+			ingress("192.168.0.0/16", "icmp", 3, 4),
+			ingressIPv6("2001:db8::/32", "icmpv6", 2, -1),
+		}, NewIPPermissionSet(findSecurityGroup(awsServices).IpPermissions...).Ungroup().List())
+
+		// The annotation is used when the spec has no source ranges
+		svc.Spec.LoadBalancerSourceRanges = nil
+		svc.Annotations[v1.AnnotationLoadBalancerSourceRangesKey] = "172.16.0.0/12"
+		_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []ec2types.IpPermission{
+			ingress("172.16.0.0/12", "tcp", 8080, 8080),
+			ingress("172.16.0.0/12", "icmp", 3, 4),
+		}, NewIPPermissionSet(findSecurityGroup(awsServices).IpPermissions...).Ungroup().List())
+
+		// Without source ranges the listeners are open to all IPv4 addresses
+		delete(svc.Annotations, v1.AnnotationLoadBalancerSourceRangesKey)
+		_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []ec2types.IpPermission{
+			ingress("0.0.0.0/0", "tcp", 8080, 8080),
+			ingress("0.0.0.0/0", "icmp", 3, 4),
+		}, NewIPPermissionSet(findSecurityGroup(awsServices).IpPermissions...).Ungroup().List())
+	})
+
+	t.Run("attach, reconcile and detach", func(t *testing.T) {
+		c, awsServices, nodes := newCloud(t)
+		elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+
+		svc := newNLBService(map[string]string{})
+		_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+		require.NoError(t, err)
+		require.Len(t, elbv2Mock.LoadBalancers, 1)
+		assert.Equal(t, []string{securityGroupID}, aws.StringValueSlice(elbv2Mock.LoadBalancers[0].SecurityGroups))
+		sg := findSecurityGroup(awsServices)
+		require.NotNil(t, sg)
+		assert.True(t, c.tagging.hasClusterTag(sg.Tags))
+		assert.ElementsMatch(t, []ec2types.IpPermission{
+			ingress("0.0.0.0/0", "tcp", 8080, 8080),
+			ingress("0.0.0.0/0", "icmp", 3, 4),
+		}, NewIPPermissionSet(sg.IpPermissions...).Ungroup().List())
+
+		// The ingress follows the listener ports and source ranges
+		svc.Spec.Ports = append(svc.Spec.Ports, v1.ServicePort{Name: "admin", Port: 9090, NodePort: 31174, Protocol: v1.ProtocolTCP})
+		svc.Spec.LoadBalancerSourceRanges = []string{"10.0.0.0/8"}
+		_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []ec2types.IpPermission{
+			ingress("10.0.0.0/8", "tcp", 8080, 8080),
+			ingress("10.0.0.0/8", "tcp", 9090, 9090),
+			ingress("10.0.0.0/8", "icmp", 3, 4),
+		}, NewIPPermissionSet(findSecurityGroup(awsServices).IpPermissions...).Ungroup().List())
+		assert.Empty(t, elbv2Mock.SetSecurityGroupsInputs)
+
+		// The managed security group is attached again if it was replaced
+		elbv2Mock.LoadBalancers[0].SecurityGroups = aws.StringSlice([]string{"sg-other"})
+		_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+		require.NoError(t, err)
+		require.Len(t, elbv2Mock.SetSecurityGroupsInputs, 1)
+		assert.Equal(t, []string{securityGroupID}, aws.StringValueSlice(elbv2Mock.LoadBalancers[0].SecurityGroups))
+
+		require.NoError(t, c.EnsureLoadBalancerDeleted(context.TODO(), TestClusterName, svc))
+		assert.Empty(t, elbv2Mock.LoadBalancers)
+		assert.Nil(t, findSecurityGroup(awsServices))
+	})
+
+	t.Run("load balancer created without security groups", func(t *testing.T) {
+		_, awsServices, nodes := newCloud(t)
+		elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+		svc := newNLBService(map[string]string{})
+		c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+		require.NoError(t, err)
+		_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+		require.NoError(t, err)
+
+		cfg := config.CloudConfig{}
+		cfg.Global.EnableNLBSecurityGroups = true
+		c, err = newAWSCloud(cfg, awsServices)
+		require.NoError(t, err)
+		_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+		require.NoError(t, err)
+		require.Len(t, elbv2Mock.LoadBalancers, 1)
+		assert.Empty(t, elbv2Mock.LoadBalancers[0].SecurityGroups)
+		assert.Empty(t, elbv2Mock.SetSecurityGroupsInputs)
+		assert.Nil(t, findSecurityGroup(awsServices))
+	})
+
+	t.Run("security groups unsupported", func(t *testing.T) {
+		c, awsServices, nodes := newCloud(t)
+		elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+		elbv2Mock.SecurityGroupsUnsupported = true
+
+		svc := newNLBService(map[string]string{})
+		_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+		require.NoError(t, err)
+		require.Len(t, elbv2Mock.LoadBalancers, 1)
+		assert.Empty(t, elbv2Mock.LoadBalancers[0].SecurityGroups)
+		assert.Nil(t, findSecurityGroup(awsServices))
+
+		// The load balancer without security groups is left unchanged
+		_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+		require.NoError(t, err)
+		assert.Empty(t, elbv2Mock.SetSecurityGroupsInputs)
+		assert.Nil(t, findSecurityGroup(awsServices))
+	})
+}
+
+func TestNLBHealthCheckAnnotations(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+
+	svc := newNLBService(map[string]string{
+		ServiceAnnotationLoadBalancerHealthCheckProtocol: "HTTP",
+		ServiceAnnotationLoadBalancerHealthCheckPath:     "/healthz",
+		ServiceAnnotationLoadBalancerHealthCheckPort:     "10256",
+		ServiceAnnotationLoadBalancerHCHealthyThreshold:  "3",
+	})
+	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	require.Len(t, elbv2Mock.TargetGroups, 1)
+	tg := elbv2Mock.TargetGroups[0]
+	assert.Equal(t, "HTTP", aws.StringValue(tg.HealthCheckProtocol))
+	assert.Equal(t, "/healthz", aws.StringValue(tg.HealthCheckPath))
+	assert.Equal(t, "10256", aws.StringValue(tg.HealthCheckPort))
+	assert.Equal(t, int64(3), aws.Int64Value(tg.HealthyThresholdCount))
+
+	// Unchanged annotations must not modify the target group.
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, 0, elbv2Mock.ModifyTargetGroupCalls)
+
+	// Path, port and threshold changes are applied in place.
+	svc.Annotations[ServiceAnnotationLoadBalancerHealthCheckPath] = "/ready"
+	svc.Annotations[ServiceAnnotationLoadBalancerHealthCheckPort] = "8081"
+	svc.Annotations[ServiceAnnotationLoadBalancerHCUnhealthyThreshold] = "5"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	require.Len(t, elbv2Mock.TargetGroups, 1)
+	assert.Equal(t, aws.StringValue(tg.TargetGroupArn), aws.StringValue(elbv2Mock.TargetGroups[0].TargetGroupArn))
+	assert.Equal(t, "/ready", aws.StringValue(tg.HealthCheckPath))
+	assert.Equal(t, "8081", aws.StringValue(tg.HealthCheckPort))
+	assert.Equal(t, int64(5), aws.Int64Value(tg.UnhealthyThresholdCount))
+	assert.Equal(t, 1, elbv2Mock.ModifyTargetGroupCalls)
+
+	// Switching to TCP replaces the target group, and the path is dropped.
+	svc.Annotations[ServiceAnnotationLoadBalancerHealthCheckProtocol] = "TCP"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	require.Len(t, elbv2Mock.TargetGroups, 1)
+	tcpTG := elbv2Mock.TargetGroups[0]
+	assert.NotEqual(t, aws.StringValue(tg.TargetGroupArn), aws.StringValue(tcpTG.TargetGroupArn))
+	assert.Equal(t, "TCP", aws.StringValue(tcpTG.HealthCheckProtocol))
+	assert.Nil(t, tcpTG.HealthCheckPath)
+
+	// The path annotation is ignored for TCP health checks.
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Nil(t, elbv2Mock.TargetGroups[0].HealthCheckPath)
+	assert.Equal(t, 1, elbv2Mock.ModifyTargetGroupCalls)
+
+	svc.Annotations[ServiceAnnotationLoadBalancerHealthCheckProtocol] = "UDP"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	assert.Error(t, err)
+}
+
+func TestNLBProxyProtocol(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+	proxyProtocolEnabled := func() string {
+		require.Len(t, elbv2Mock.TargetGroups, 1)
+		return elbv2Mock.TargetGroupAttributes[aws.StringValue(elbv2Mock.TargetGroups[0].TargetGroupArn)][tgAttrProxyProtocolV2Enabled]
+	}
+
+	svc := newNLBService(map[string]string{
+		ServiceAnnotationLoadBalancerProxyProtocol: "*",
+	})
+	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, "true", proxyProtocolEnabled())
+	assert.Equal(t, 1, elbv2Mock.ModifyTargetGroupAttributesCalls)
+
+	// Reconciling again must not issue redundant updates.
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, 1, elbv2Mock.ModifyTargetGroupAttributesCalls)
+
+	delete(svc.Annotations, ServiceAnnotationLoadBalancerProxyProtocol)
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, "false", proxyProtocolEnabled())
+	assert.Equal(t, 2, elbv2Mock.ModifyTargetGroupAttributesCalls)
+
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, 2, elbv2Mock.ModifyTargetGroupAttributesCalls)
+
+	svc.Annotations[ServiceAnnotationLoadBalancerProxyProtocol] = "8080"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	assert.Error(t, err)
+}
+
+func TestNLBIPTargets(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+	c.kubeClient = fake.NewSimpleClientset()
+	informerFactory := informers.NewSharedInformerFactory(c.kubeClient, 0)
+	c.SetInformers(informerFactory)
+	endpointSlicesSynced := false
+	c.endpointSliceListerSynced = func() bool { return endpointSlicesSynced }
+	endpointSlices := informerFactory.Discovery().V1().EndpointSlices().Informer().GetStore()
+	registeredTargets := func() []string {
+		require.Len(t, elbv2Mock.TargetGroups, 1)
+		targets := elbv2Mock.RegisteredInstances[aws.StringValue(elbv2Mock.TargetGroups[0].TargetGroupArn)]
+		sort.Strings(targets)
+		return targets
+	}
+	newEndpoint := func(ip string, ready bool) discoveryv1.Endpoint {
+		return discoveryv1.Endpoint{Addresses: []string{ip}, Conditions: discoveryv1.EndpointConditions{Ready: aws.Bool(ready)}}
+	}
+	endpointSlice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myservice-abcde",
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "myservice"},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports:       []discoveryv1.EndpointPort{{Name: aws.String("http"), Port: aws.Int32(31173)}},
+		Endpoints:   []discoveryv1.Endpoint{newEndpoint("10.0.0.1", true), newEndpoint("10.0.0.2", true), newEndpoint("10.0.0.3", false)},
+	}
+	require.NoError(t, endpointSlices.Add(endpointSlice))
+
+	svc := newNLBService(map[string]string{
+		ServiceAnnotationLoadBalancerNLBTargetType: "ip",
+	})
+	svc.Namespace = "default"
+	svc.Spec.Type = v1.ServiceTypeLoadBalancer
+	require.NoError(t, informerFactory.Core().V1().Services().Informer().GetStore().Add(svc))
+
+	// The targets aren't computed from endpoint slices that aren't synced yet
+	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	assert.ErrorContains(t, err, "not synced")
+	assert.Empty(t, elbv2Mock.TargetGroups)
+
+	endpointSlicesSynced = true
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, elbv2.TargetTypeEnumIp, aws.StringValue(elbv2Mock.TargetGroups[0].TargetType))
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, registeredTargets())
+
+	// Endpoint changes are synced without waiting for the service to be updated
+	endpointSlice = endpointSlice.DeepCopy()
+	endpointSlice.Endpoints = []discoveryv1.Endpoint{newEndpoint("10.0.0.2", true), newEndpoint("10.0.0.3", true)}
+	require.NoError(t, endpointSlices.Update(endpointSlice))
+	c.enqueueEndpointSliceService(endpointSlice)
+	key, _ := c.nlbIPTargetsQueue.Get()
+	assert.Equal(t, "default/myservice", key)
+	endpointSlicesSynced = false
+	assert.ErrorContains(t, c.syncNLBIPTargets(context.TODO(), key), "not synced")
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, registeredTargets())
+	endpointSlicesSynced = true
+
+	// The sync waits for the reconcile of the load balancer of the service
+	unlock := c.lockLoadBalancer(svc)
+	synced := make(chan error, 1)
+	go func() { synced <- c.syncNLBIPTargets(context.TODO(), key) }()
+	select {
+	case <-synced:
+		t.Fatal("IP targets synced while the load balancer of the service was locked")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, registeredTargets())
+	unlock()
+	require.NoError(t, <-synced)
+	c.nlbIPTargetsQueue.Done(key)
+	assert.Equal(t, []string{"10.0.0.2", "10.0.0.3"}, registeredTargets())
+
+	// Switching the target type recreates the target group
+	svc.Annotations[ServiceAnnotationLoadBalancerNLBTargetType] = "instance"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, elbv2.TargetTypeEnumInstance, aws.StringValue(elbv2Mock.TargetGroups[0].TargetType))
+	assert.Len(t, registeredTargets(), len(nodes))
+
+	// Target groups of target type instance are left to EnsureLoadBalancer
+	require.NoError(t, c.syncNLBIPTargets(context.TODO(), "default/myservice"))
+	assert.Len(t, registeredTargets(), len(nodes))
+
+	svc.Annotations[ServiceAnnotationLoadBalancerNLBTargetType] = "pod"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	assert.ErrorContains(t, err, ServiceAnnotationLoadBalancerNLBTargetType)
+}
+
+func TestNLBIPTargetsRenamedLoadBalancer(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+	c.kubeClient = fake.NewSimpleClientset()
+	informerFactory := informers.NewSharedInformerFactory(c.kubeClient, 0)
+	c.SetInformers(informerFactory)
+	c.endpointSliceListerSynced = func() bool { return true }
+	endpointSlices := informerFactory.Discovery().V1().EndpointSlices().Informer().GetStore()
+	endpointSlice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myservice-abcde",
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "myservice"},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Ports:       []discoveryv1.EndpointPort{{Name: aws.String("http"), Port: aws.Int32(31173)}},
+		Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.1"}}},
+	}
+	require.NoError(t, endpointSlices.Add(endpointSlice))
+
+	svc := newNLBService(map[string]string{ServiceAnnotationLoadBalancerNLBTargetType: "ip"})
+	svc.Namespace = "default"
+	svc.Spec.Type = v1.ServiceTypeLoadBalancer
+	services := informerFactory.Core().V1().Services().Informer().GetStore()
+	require.NoError(t, services.Add(svc))
+	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	require.Len(t, elbv2Mock.TargetGroups, 1)
+	tgARN := aws.StringValue(elbv2Mock.TargetGroups[0].TargetGroupArn)
+	assert.Equal(t, []string{"10.0.0.1"}, elbv2Mock.RegisteredInstances[tgARN])
+
+	// Until the service is reconciled again, its load balancer keeps the name it was created with and is found by its
+	// tags, so its targets are still synced
+	svc = svc.DeepCopy()
+	svc.Annotations[ServiceAnnotationLoadBalancerName] = "payments-prod"
+	require.NoError(t, services.Update(svc))
+	endpointSlice = endpointSlice.DeepCopy()
+	endpointSlice.Endpoints = []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.2"}}}
+	require.NoError(t, endpointSlices.Update(endpointSlice))
+	require.NoError(t, c.syncNLBIPTargets(context.TODO(), "default/myservice"))
+	assert.Equal(t, []string{"10.0.0.2"}, elbv2Mock.RegisteredInstances[tgARN])
+}
+
+func TestNLBIPTargetPorts(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+	c.kubeClient = fake.NewSimpleClientset()
+	informerFactory := informers.NewSharedInformerFactory(c.kubeClient, 0)
+	c.SetInformers(informerFactory)
+	c.endpointSliceListerSynced = func() bool { return true }
+	endpointSlices := informerFactory.Discovery().V1().EndpointSlices().Informer().GetStore()
+	endpointSlice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myservice-abcde",
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "myservice"},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		// The endpoint slices resolve the named target port from the pod spec
+		Ports: []discoveryv1.EndpointPort{
+			{Name: aws.String("https"), Port: aws.Int32(8443)},
+			{Name: aws.String("http"), Port: aws.Int32(9080)},
+			{Name: aws.String("admin"), Port: aws.Int32(9443)},
+		},
+		Endpoints: []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.1"}}},
+	}
+	require.NoError(t, endpointSlices.Add(endpointSlice))
+
+	svc := newNLBService(map[string]string{ServiceAnnotationLoadBalancerNLBTargetType: "ip"})
+	svc.Namespace = "default"
+	svc.Spec.Type = v1.ServiceTypeLoadBalancer
+	svc.Spec.Ports = []v1.ServicePort{
+		{Name: "https", Port: 443, TargetPort: intstr.FromInt(8443), Protocol: v1.ProtocolTCP},
+		{Name: "http", Port: 80, TargetPort: intstr.FromString("web"), Protocol: v1.ProtocolTCP},
+		// The target group of the named port has the same port as the target group of the https port
+		{Name: "admin", Port: 8443, TargetPort: intstr.FromString("admin"), Protocol: v1.ProtocolTCP},
+	}
+	require.NoError(t, informerFactory.Core().V1().Services().Informer().GetStore().Add(svc))
+
+	// targetPorts returns the port of the target group forwarded to by the listener on the port, and the ports of its
+	// registered targets
+	targetPorts := func(listenerPort int64) (int64, map[string]int64) {
+		for _, listener := range elbv2Mock.Listeners {
+			if aws.Int64Value(listener.Port) != listenerPort {
+				continue
+			}
+			tgARN := aws.StringValue(listener.DefaultActions[0].TargetGroupArn)
+			for _, targetGroup := range elbv2Mock.TargetGroups {
+				if aws.StringValue(targetGroup.TargetGroupArn) == tgARN {
+					return aws.Int64Value(targetGroup.Port), elbv2Mock.RegisteredPorts[tgARN]
+				}
+			}
+		}
+		t.Fatalf("no target group for listener port %d", listenerPort)
+		return 0, nil
+	}
+
+	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	require.Len(t, elbv2Mock.TargetGroups, 3)
+	port, targets := targetPorts(443)
+	assert.Equal(t, int64(8443), port)
+	assert.Equal(t, map[string]int64{"10.0.0.1": 8443}, targets)
+	// Named target ports can differ between pods, the target group uses the service port
+	port, targets = targetPorts(80)
+	assert.Equal(t, int64(80), port)
+	assert.Equal(t, map[string]int64{"10.0.0.1": 9080}, targets)
+	port, targets = targetPorts(8443)
+	assert.Equal(t, int64(8443), port)
+	assert.Equal(t, map[string]int64{"10.0.0.1": 9443}, targets)
+
+	// A change of the named port of the pods is synced with the endpoints
+	endpointSlice = endpointSlice.DeepCopy()
+	endpointSlice.Ports[1].Port = aws.Int32(9090)
+	require.NoError(t, endpointSlices.Update(endpointSlice))
+	require.NoError(t, c.syncNLBIPTargets(context.TODO(), "default/myservice"))
+	_, targets = targetPorts(80)
+	assert.Equal(t, map[string]int64{"10.0.0.1": 9090}, targets)
+	_, targets = targetPorts(443)
+	assert.Equal(t, map[string]int64{"10.0.0.1": 8443}, targets)
+	_, targets = targetPorts(8443)
+	assert.Equal(t, map[string]int64{"10.0.0.1": 9443}, targets)
+}
+
+func TestNLBInstanceTargetsNodeReadiness(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+	c.kubeClient = fake.NewSimpleClientset()
+	informerFactory := informers.NewSharedInformerFactory(c.kubeClient, 0)
+	c.SetInformers(informerFactory)
+	nodeStore := informerFactory.Core().V1().Nodes().Informer().GetStore()
+	registeredTargets := func() []string {
+		require.Len(t, elbv2Mock.TargetGroups, 1)
+		targets := elbv2Mock.RegisteredInstances[aws.StringValue(elbv2Mock.TargetGroups[0].TargetGroupArn)]
+		sort.Strings(targets)
+		return targets
+	}
+	instanceIDs := func(nodes ...*v1.Node) []string {
+		var ids []string
+		for _, node := range nodes {
+			id, err := KubernetesInstanceID(node.Spec.ProviderID).MapToAWSInstanceID()
+			require.NoError(t, err)
+			ids = append(ids, string(id))
+		}
+		sort.Strings(ids)
+		return ids
+	}
+	setReady := func(node *v1.Node, status v1.ConditionStatus) *v1.Node {
+		node = node.DeepCopy()
+		node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: status}}
+		return node
+	}
+	for i := range nodes {
+		nodes[i] = setReady(nodes[i], v1.ConditionTrue)
+		require.NoError(t, nodeStore.Add(nodes[i]))
+	}
+
+	svc := newNLBService(map[string]string{})
+	svc.Namespace = "default"
+	svc.Spec.Type = v1.ServiceTypeLoadBalancer
+	require.NoError(t, informerFactory.Core().V1().Services().Informer().GetStore().Add(svc))
+	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, instanceIDs(nodes...), registeredTargets())
+
+	// Readiness changes are synced without waiting for the service controller, only registering and deregistering
+	// the targets
+	ec2Mock := awsServices.ec2.(*MockedFakeEC2)
+	transition := func(status v1.ConditionStatus) {
+		ec2Mock.apiCalls = map[string]int{}
+		ec2Mock.Calls = nil
+		modifyLoadBalancerAttributes := len(elbv2Mock.ModifyLoadBalancerAttributesInputs)
+		modifyTargetGroupAttributes := elbv2Mock.ModifyTargetGroupAttributesCalls
+		defer func() {
+			assert.Zero(t, ec2Mock.apiCalls["DescribeSubnets"])
+			assert.Empty(t, ec2Mock.Calls)
+			assert.Len(t, elbv2Mock.ModifyLoadBalancerAttributesInputs, modifyLoadBalancerAttributes)
+			assert.Equal(t, modifyTargetGroupAttributes, elbv2Mock.ModifyTargetGroupAttributesCalls)
+		}()
+		oldNode := nodes[0]
+		nodes[0] = setReady(oldNode, status)
+		require.NoError(t, nodeStore.Update(nodes[0]))
+		c.enqueueNodeReadinessChange(oldNode, nodes[0])
+		key, _ := c.nlbInstanceTargetsQueue.Get()
+		assert.Equal(t, "default/myservice", key)
+		require.NoError(t, c.syncNLBInstanceTargets(context.TODO(), key))
+		c.nlbInstanceTargetsQueue.Done(key)
+	}
+	transition(v1.ConditionFalse)
+	assert.Equal(t, instanceIDs(nodes[1:]...), registeredTargets())
+
+	// The service controller doesn't register a node that isn't ready either
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, instanceIDs(nodes[1:]...), registeredTargets())
+
+	transition(v1.ConditionTrue)
+	assert.Equal(t, instanceIDs(nodes...), registeredTargets())
+
+	// Updates that don't change the readiness of a node don't sync the load balancers
+	c.enqueueNodeReadinessChange(nodes[0], nodes[0].DeepCopy())
+	assert.Zero(t, c.nlbInstanceTargetsQueue.Len())
+}
+
+func TestEnqueueNodeSchedulabilityChange(t *testing.T) {
+	cfg := config.CloudConfig{}
+	cfg.Global.EnableCordonedNodeDeregistration = true
+	c, err := newAWSCloud(cfg, newMockedFakeAWSServices(TestClusterID))
+	require.NoError(t, err)
+	c.kubeClient = fake.NewSimpleClientset()
+	informerFactory := informers.NewSharedInformerFactory(c.kubeClient, 0)
+	c.SetInformers(informerFactory)
+	serviceStore := informerFactory.Core().V1().Services().Informer().GetStore()
+
+	classic := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "classic", Namespace: "default"},
+		Spec:       v1.ServiceSpec{Type: v1.ServiceTypeLoadBalancer},
+	}
+	nlb := newNLBService(map[string]string{})
+	nlb.Namespace = "default"
+	nlb.Spec.Type = v1.ServiceTypeLoadBalancer
+	clusterIP := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "cluster-ip", Namespace: "default"}}
+	for _, service := range []*v1.Service{classic, nlb, clusterIP} {
+		require.NoError(t, serviceStore.Add(service))
+	}
+
+	node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node"}}
+	cordoned := node.DeepCopy()
+	cordoned.Spec.Unschedulable = true
+
+	// Cordoning or uncordoning a node syncs the classic ELBs
+	for _, transition := range [][2]*v1.Node{{node, cordoned}, {cordoned, node}} {
+		c.enqueueNodeSchedulabilityChange(transition[0], transition[1])
+		require.Equal(t, 1, c.nlbInstanceTargetsQueue.Len())
+		key, _ := c.nlbInstanceTargetsQueue.Get()
+		assert.Equal(t, "default/classic", key)
+		c.nlbInstanceTargetsQueue.Done(key)
+	}
+
+	// Updates that don't change the schedulability of a node don't sync the load balancers
+	c.enqueueNodeSchedulabilityChange(cordoned, cordoned.DeepCopy())
+	assert.Zero(t, c.nlbInstanceTargetsQueue.Len())
+}
+
+func TestFilterReadyNodes(t *testing.T) {
+	newNode := func(name string, conditions ...v1.NodeCondition) *v1.Node {
+		return &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}, Status: v1.NodeStatus{Conditions: conditions}}
+	}
+	ready := v1.NodeCondition{Type: v1.NodeReady, Status: v1.ConditionTrue}
+	notReady := v1.NodeCondition{Type: v1.NodeReady, Status: v1.ConditionFalse}
+	networkUnavailable := v1.NodeCondition{Type: v1.NodeNetworkUnavailable, Status: v1.ConditionTrue}
+	nodeNames := func(nodes []*v1.Node) []string {
+		var names []string
+		for _, node := range nodes {
+			names = append(names, node.Name)
+		}
+		return names
+	}
+
+	nodes := []*v1.Node{
+		newNode("ready", ready),
+		newNode("not-ready", notReady),
+		newNode("unknown"),
+		newNode("network-unavailable", ready, networkUnavailable),
+	}
+	assert.Equal(t, []string{"ready"}, nodeNames(filterReadyNodes(nodes)))
+
+	// All nodes are kept when none is ready
+	assert.Equal(t, []string{"not-ready", "unknown"}, nodeNames(filterReadyNodes(nodes[1:3])))
+}
+
+func TestNLBCrossZoneLoadBalancing(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+	crossZoneEnabled := func() string {
+		require.Len(t, elbv2Mock.LoadBalancers, 1)
+		return elbv2Mock.LoadBalancerAttributes[aws.StringValue(elbv2Mock.LoadBalancers[0].LoadBalancerArn)][lbAttrLoadBalancingCrossZoneEnabled]
+	}
+	lastCrossZoneUpdate := func() *string {
+		require.NotEmpty(t, elbv2Mock.ModifyLoadBalancerAttributesInputs)
+		input := elbv2Mock.ModifyLoadBalancerAttributesInputs[len(elbv2Mock.ModifyLoadBalancerAttributesInputs)-1]
+		for _, attr := range input.Attributes {
+			if aws.StringValue(attr.Key) == lbAttrLoadBalancingCrossZoneEnabled {
+				return attr.Value
+			}
+		}
+		return nil
+	}
+
+	svc := newNLBService(map[string]string{
+		ServiceAnnotationLoadBalancerCrossZoneLoadBalancingEnabled: "true",
+	})
+	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, "true", crossZoneEnabled())
+	assert.Equal(t, "true", aws.StringValue(lastCrossZoneUpdate()))
+	calls := len(elbv2Mock.ModifyLoadBalancerAttributesInputs)
+
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Len(t, elbv2Mock.ModifyLoadBalancerAttributesInputs, calls)
+
+	svc.Annotations[ServiceAnnotationLoadBalancerCrossZoneLoadBalancingEnabled] = "false"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Len(t, elbv2Mock.ModifyLoadBalancerAttributesInputs, calls+1)
+	assert.Equal(t, "false", aws.StringValue(lastCrossZoneUpdate()))
+	assert.Equal(t, "false", crossZoneEnabled())
+
+	// Drift made outside of the controller is reverted.
+	elbv2Mock.LoadBalancerAttributes[aws.StringValue(elbv2Mock.LoadBalancers[0].LoadBalancerArn)][lbAttrLoadBalancingCrossZoneEnabled] = "true"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Len(t, elbv2Mock.ModifyLoadBalancerAttributesInputs, calls+2)
+	assert.Equal(t, "false", crossZoneEnabled())
+
+	// Without the annotation cross-zone load balancing stays disabled.
+	delete(svc.Annotations, ServiceAnnotationLoadBalancerCrossZoneLoadBalancingEnabled)
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Len(t, elbv2Mock.ModifyLoadBalancerAttributesInputs, calls+2)
+
+	svc.Annotations[ServiceAnnotationLoadBalancerCrossZoneLoadBalancingEnabled] = "yes please"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	assert.Error(t, err)
+}
+
+func TestNLBAccessLogs(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+	attributes := func() map[string]string {
+		require.Len(t, elbv2Mock.LoadBalancers, 1)
+		return elbv2Mock.LoadBalancerAttributes[aws.StringValue(elbv2Mock.LoadBalancers[0].LoadBalancerArn)]
+	}
+
+	svc := newNLBService(map[string]string{
+		ServiceAnnotationLoadBalancerAccessLogEnabled:        "true",
+		ServiceAnnotationLoadBalancerAccessLogS3BucketName:   "audit-logs",
+		ServiceAnnotationLoadBalancerAccessLogS3BucketPrefix: "nlb",
+	})
+	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, "true", attributes()[lbAttrAccessLogsS3Enabled])
+	assert.Equal(t, "audit-logs", attributes()[lbAttrAccessLogsS3Bucket])
+	assert.Equal(t, "nlb", attributes()[lbAttrAccessLogsS3Prefix])
+	calls := len(elbv2Mock.ModifyLoadBalancerAttributesInputs)
+
+	svc.Annotations[ServiceAnnotationLoadBalancerAccessLogS3BucketPrefix] = "network"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Len(t, elbv2Mock.ModifyLoadBalancerAttributesInputs, calls+1)
+	assert.Equal(t, "network", attributes()[lbAttrAccessLogsS3Prefix])
+
+	// Disabling access logs keeps the bucket, which ELBv2 doesn't allow to be cleared.
+	svc.Annotations[ServiceAnnotationLoadBalancerAccessLogEnabled] = "false"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, "false", attributes()[lbAttrAccessLogsS3Enabled])
+	assert.Equal(t, "audit-logs", attributes()[lbAttrAccessLogsS3Bucket])
+}
+
+func TestGetELBAttributesFromAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        *elb.AccessLog
+		wantErr     bool
+	}{
+		{
+			name:        "access logs disabled by default",
+			annotations: map[string]string{},
+			want:        &elb.AccessLog{Enabled: aws.Bool(false)},
+		},
+		{
+			name: "access logs enabled",
+			annotations: map[string]string{
+				ServiceAnnotationLoadBalancerAccessLogEnabled:        "true",
+				ServiceAnnotationLoadBalancerAccessLogEmitInterval:   "5",
+				ServiceAnnotationLoadBalancerAccessLogS3BucketName:   "audit-logs",
+				ServiceAnnotationLoadBalancerAccessLogS3BucketPrefix: "elb",
+			},
+			want: &elb.AccessLog{
+				Enabled:        aws.Bool(true),
+				EmitInterval:   aws.Int64(5),
+				S3BucketName:   aws.String("audit-logs"),
+				S3BucketPrefix: aws.String("elb"),
+			},
+		},
+		{
+			name: "emit interval of 60 minutes",
+			annotations: map[string]string{
+				ServiceAnnotationLoadBalancerAccessLogEmitInterval: "60",
+			},
+			want: &elb.AccessLog{Enabled: aws.Bool(false), EmitInterval: aws.Int64(60)},
+		},
+		{
+			name: "unsupported emit interval",
+			annotations: map[string]string{
+				ServiceAnnotationLoadBalancerAccessLogEmitInterval: "10",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid emit interval",
+			annotations: map[string]string{
+				ServiceAnnotationLoadBalancerAccessLogEmitInterval: "hourly",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid enabled value",
+			annotations: map[string]string{
+				ServiceAnnotationLoadBalancerAccessLogEnabled: "sometimes",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getELBAttributesFromAnnotations(tt.annotations)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.AccessLog)
+		})
+	}
+}
+
+func TestNLBDeregistrationDelay(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	recorder := record.NewFakeRecorder(10)
+	c.eventRecorder = recorder
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+	deregistrationDelay := func() string {
+		require.Len(t, elbv2Mock.TargetGroups, 1)
+		return elbv2Mock.TargetGroupAttributes[aws.StringValue(elbv2Mock.TargetGroups[0].TargetGroupArn)][tgAttrDeregistrationDelayTimeoutSeconds]
+	}
+
+	// Without annotations the target group default is left alone.
+	svc := newNLBService(map[string]string{})
+	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, 0, elbv2Mock.ModifyTargetGroupAttributesCalls)
+
+	svc.Annotations[ServiceAnnotationLoadBalancerConnectionDrainingEnabled] = "true"
+	svc.Annotations[ServiceAnnotationLoadBalancerConnectionDrainingTimeout] = "120"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, "120", deregistrationDelay())
+	assert.Equal(t, 1, elbv2Mock.ModifyTargetGroupAttributesCalls)
+
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, 1, elbv2Mock.ModifyTargetGroupAttributesCalls)
+
+	svc.Annotations[ServiceAnnotationLoadBalancerConnectionDrainingTimeout] = "7200"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, "3600", deregistrationDelay())
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "InvalidConnectionDrainingTimeout")
+
+	// The unchanged out of range value isn't reported again
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Empty(t, recorder.Events)
+
+	svc.Annotations[ServiceAnnotationLoadBalancerConnectionDrainingEnabled] = "false"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, "0", deregistrationDelay())
+	assert.Empty(t, recorder.Events)
+
+	// A deregistration delay of 0 is valid for NLBs, unlike the classic ELB connection draining timeout
+	svc.Annotations[ServiceAnnotationLoadBalancerConnectionDrainingEnabled] = "true"
+	svc.Annotations[ServiceAnnotationLoadBalancerConnectionDrainingTimeout] = "60"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, "60", deregistrationDelay())
+	svc.Annotations[ServiceAnnotationLoadBalancerConnectionDrainingTimeout] = "0"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, "0", deregistrationDelay())
+	assert.Empty(t, recorder.Events)
+}
+
+func TestNLBStickiness(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+	targetGroupAttributes := func() map[string]string {
+		require.Len(t, elbv2Mock.TargetGroups, 1)
+		return elbv2Mock.TargetGroupAttributes[aws.StringValue(elbv2Mock.TargetGroups[0].TargetGroupArn)]
+	}
+
+	// Without the annotation the target group default is left alone.
+	svc := newNLBService(map[string]string{})
+	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, 0, elbv2Mock.ModifyTargetGroupAttributesCalls)
+
+	svc.Annotations[ServiceAnnotationLoadBalancerTargetGroupAttributes] = "stickiness.enabled=true,stickiness.type=source_ip"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, "true", targetGroupAttributes()[tgAttrStickinessEnabled])
+	assert.Equal(t, tgStickinessTypeSourceIP, targetGroupAttributes()[tgAttrStickinessType])
+	assert.Equal(t, 1, elbv2Mock.ModifyTargetGroupAttributesCalls)
+
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, 1, elbv2Mock.ModifyTargetGroupAttributesCalls)
+
+	svc.Annotations[ServiceAnnotationLoadBalancerTargetGroupAttributes] = "stickiness.enabled=false"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, "false", targetGroupAttributes()[tgAttrStickinessEnabled])
+	assert.Equal(t, 2, elbv2Mock.ModifyTargetGroupAttributesCalls)
+}
+
+func TestGetNLBStickiness(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		annotation *string
+		expected   *bool
+		wantErr    bool
+	}{
+		{
+			name: "no annotation",
+		},
+		{
+			name:       "enabled",
+			annotation: aws.String("stickiness.enabled=true,stickiness.type=source_ip"),
+			expected:   aws.Bool(true),
+		},
+		{
+			name:       "enabled without a type",
+			annotation: aws.String("stickiness.enabled=true"),
+			expected:   aws.Bool(true),
+		},
+		{
+			name:       "disabled",
+			annotation: aws.String("stickiness.enabled=false"),
+			expected:   aws.Bool(false),
+		},
+		{
+			name:       "ALB cookie stickiness",
+			annotation: aws.String("stickiness.enabled=true,stickiness.type=lb_cookie"),
+			wantErr:    true,
+		},
+		{
+			name:       "invalid enabled value",
+			annotation: aws.String("stickiness.enabled=yes please"),
+			wantErr:    true,
+		},
+		{
+			name:       "unsupported attribute",
+			annotation: aws.String("slow_start.duration_seconds=30"),
+			wantErr:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if tc.annotation != nil {
+				annotations[ServiceAnnotationLoadBalancerTargetGroupAttributes] = *tc.annotation
+			}
+			stickiness, err := getNLBStickiness(annotations)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, stickiness)
+		})
+	}
+}
+
+func TestNLBTargetGroupAttributeDrift(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+	svc := newNLBService(map[string]string{
+		ServiceAnnotationLoadBalancerProxyProtocol:             "*",
+		ServiceAnnotationLoadBalancerConnectionDrainingTimeout: "60",
+		ServiceAnnotationLoadBalancerTargetGroupAttributes:     "stickiness.enabled=true,stickiness.type=source_ip",
+	})
+	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	require.Len(t, elbv2Mock.TargetGroups, 1)
+	attributes := elbv2Mock.TargetGroupAttributes[aws.StringValue(elbv2Mock.TargetGroups[0].TargetGroupArn)]
+	expected := map[string]string{
+		tgAttrProxyProtocolV2Enabled:            "true",
+		tgAttrDeregistrationDelayTimeoutSeconds: "60",
+		tgAttrStickinessEnabled:                 "true",
+		tgAttrStickinessType:                    tgStickinessTypeSourceIP,
+	}
+	assert.Equal(t, expected, attributes)
+
+	// The attributes are changed out of band, the next reconcile without a change of the service restores them
+	attributes[tgAttrProxyProtocolV2Enabled] = "false"
+	attributes[tgAttrDeregistrationDelayTimeoutSeconds] = "300"
+	attributes[tgAttrStickinessEnabled] = "false"
+	modifyCalls := elbv2Mock.ModifyTargetGroupAttributesCalls
+	require.NoError(t, c.UpdateLoadBalancer(context.TODO(), TestClusterName, svc, nodes))
+	assert.Equal(t, expected, attributes)
+	assert.Equal(t, modifyCalls+1, elbv2Mock.ModifyTargetGroupAttributesCalls)
+
+	// Without drift nothing is modified
+	require.NoError(t, c.UpdateLoadBalancer(context.TODO(), TestClusterName, svc, nodes))
+	assert.Equal(t, modifyCalls+1, elbv2Mock.ModifyTargetGroupAttributesCalls)
+}
+
+func TestValidateLoadBalancerName(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		lbName  string
+		wantErr bool
+	}{
+		{name: "valid", lbName: "payments-prod-1"},
+		{name: "32 characters", lbName: strings.Repeat("a", 32)},
+		{name: "33 characters", lbName: strings.Repeat("a", 33), wantErr: true},
+		{name: "invalid characters", lbName: "payments_prod", wantErr: true},
+		{name: "leading hyphen", lbName: "-payments", wantErr: true},
+		{name: "trailing hyphen", lbName: "payments-", wantErr: true},
+		{name: "internal prefix", lbName: "internal-payments", wantErr: true},
+		{name: "empty", lbName: "", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateLoadBalancerName(tc.lbName)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNLBNameAnnotation(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	recorder := record.NewFakeRecorder(10)
+	c.eventRecorder = recorder
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+
+	svc := newNLBService(map[string]string{ServiceAnnotationLoadBalancerName: "payments-prod"})
+	assert.Equal(t, "payments-prod", c.GetLoadBalancerName(context.TODO(), TestClusterName, svc))
+	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	require.Len(t, elbv2Mock.LoadBalancers, 1)
+	assert.Equal(t, "payments-prod", aws.StringValue(elbv2Mock.LoadBalancers[0].LoadBalancerName))
+
+	// The load balancer is reconciled again after a restart
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Len(t, elbv2Mock.LoadBalancers, 1)
+	assert.Empty(t, recorder.Events)
+
+	// Another service can't take over the load balancer
+	other := newNLBService(map[string]string{ServiceAnnotationLoadBalancerName: "payments-prod"})
+	other.Name = "other"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, other, nodes)
+	require.ErrorContains(t, err, "already managed for service /myservice")
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Warning LoadBalancerNameConflict")
+	assert.Error(t, c.UpdateLoadBalancer(context.TODO(), TestClusterName, other, nodes))
+	<-recorder.Events
+	require.NoError(t, c.EnsureLoadBalancerDeleted(context.TODO(), TestClusterName, other))
+	<-recorder.Events
+	assert.Len(t, elbv2Mock.LoadBalancers, 1)
+
+	invalid := newNLBService(map[string]string{ServiceAnnotationLoadBalancerName: "payments_prod"})
+	assert.Equal(t, cloudprovider.DefaultLoadBalancerName(invalid), c.GetLoadBalancerName(context.TODO(), TestClusterName, invalid))
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, invalid, nodes)
+	require.Error(t, err)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Warning InvalidLoadBalancerName")
+	assert.Len(t, elbv2Mock.LoadBalancers, 1)
+}
+
+func TestNLBNameAnnotationChanges(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	recorder := record.NewFakeRecorder(10)
+	c.eventRecorder = recorder
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+	loadBalancerNames := func() []string {
+		var names []string
+		for _, lb := range elbv2Mock.LoadBalancers {
+			names = append(names, aws.StringValue(lb.LoadBalancerName))
+		}
+		return names
+	}
+
+	svc := newNLBService(map[string]string{ServiceAnnotationLoadBalancerName: "payments-prod"})
+	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"payments-prod"}, loadBalancerNames())
+
+	// A load balancer can't be renamed, the load balancer created under the previous name is replaced
+	svc.Annotations[ServiceAnnotationLoadBalancerName] = "payments-v2"
+	status, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	svc.Status.LoadBalancer = *status
+	assert.Equal(t, []string{"payments-v2"}, loadBalancerNames())
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Normal LoadBalancerNameChanged Deleting load balancer payments-prod")
+
+	// The status is reported for the existing load balancer until it is replaced
+	svc.Annotations[ServiceAnnotationLoadBalancerName] = "payments-v3"
+	_, exists, err := c.GetLoadBalancer(context.TODO(), TestClusterName, svc)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// Removing the annotation moves the load balancer reported in the status to the generated name
+	delete(svc.Annotations, ServiceAnnotationLoadBalancerName)
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, []string{cloudprovider.DefaultLoadBalancerName(svc)}, loadBalancerNames())
+	<-recorder.Events
+
+	// An invalid annotation is rejected instead of falling back to the generated name
+	svc.Annotations[ServiceAnnotationLoadBalancerName] = "payments_prod"
+	_, _, err = c.GetLoadBalancer(context.TODO(), TestClusterName, svc)
+	assert.ErrorContains(t, err, "invalid "+ServiceAnnotationLoadBalancerName)
+	assert.Error(t, c.UpdateLoadBalancer(context.TODO(), TestClusterName, svc, nodes))
+
+	// The load balancer of a service with an invalid annotation is still deleted
+	require.NoError(t, c.EnsureLoadBalancerDeleted(context.TODO(), TestClusterName, svc))
+	assert.Empty(t, loadBalancerNames())
+}
+
+func TestNLBGeneratedNameNotLookedUpByTag(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+
+	// Without a name annotation, a service is only renamed when its status reports another load balancer
+	svc := newNLBService(map[string]string{})
+	status, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	svc.Status.LoadBalancer = *status
+	_, exists, err := c.GetLoadBalancer(context.TODO(), TestClusterName, svc)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	require.NoError(t, c.EnsureLoadBalancerDeleted(context.TODO(), TestClusterName, svc))
+	require.NoError(t, c.EnsureLoadBalancerDeleted(context.TODO(), TestClusterName, svc))
+	assert.Empty(t, elbv2Mock.LoadBalancers)
+	assert.Zero(t, elbv2Mock.ListLoadBalancersCalls)
+}
+
+func TestCheckLoadBalancerNameOwnershipELB(t *testing.T) {
+	awsServices := newMockedFakeAWSServices(TestClusterID)
+	c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+	require.NoError(t, err)
+	recorder := record.NewFakeRecorder(10)
+	c.eventRecorder = recorder
+	elbMock := awsServices.elb.(*MockedFakeELB)
+
+	svc := &v1.Service{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "default",
+		Name:        "payments",
+		Annotations: map[string]string{ServiceAnnotationLoadBalancerName: "payments-prod"},
+	}}
+	describeTags := &elb.DescribeTagsInput{LoadBalancerNames: []*string{aws.String("payments-prod")}}
+
+	elbMock.On("DescribeTags", describeTags).Return(nil, awserr.New(elb.ErrCodeAccessPointNotFoundException, "not found", nil)).Once()
+	assert.NoError(t, c.checkLoadBalancerNameOwnership(context.TODO(), TestClusterName, svc))
+
+	elbMock.On("DescribeTags", describeTags).Return(&elb.DescribeTagsOutput{TagDescriptions: []*elb.TagDescription{{
+		Tags: []*elb.Tag{
+			{Key: aws.String(TagNameKubernetesService), Value: aws.String("default/payments")},
+			{Key: aws.String(c.tagging.clusterTagKey()), Value: aws.String(ResourceLifecycleOwned)},
+		},
+	}}}, nil).Once()
+	assert.NoError(t, c.checkLoadBalancerNameOwnership(context.TODO(), TestClusterName, svc))
+	assert.Empty(t, recorder.Events)
+
+	// A load balancer that wasn't created by the cloud provider isn't managed for the service
+	elbMock.On("DescribeTags", describeTags).Return(&elb.DescribeTagsOutput{TagDescriptions: []*elb.TagDescription{{}}}, nil).Once()
+	assert.ErrorContains(t, c.checkLoadBalancerNameOwnership(context.TODO(), TestClusterName, svc), "not managed for service default/payments")
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Warning LoadBalancerNameConflict")
+	elbMock.AssertExpectations(t)
+}
+
+func TestGetSSLNegotiationPolicies(t *testing.T) {
+	ports := []v1.ServicePort{
+		{Name: "https", Port: 443},
+		{Name: "admin", Port: 8443},
+		{Name: "tls", Port: 9443},
+	}
+	for _, tc := range []struct {
+		name       string
+		annotation *string
+		expected   []string
+	}{
+		{
+			name:     "no annotation",
+			expected: []string{"", "", ""},
+		},
+		{
+			name:       "single policy",
+			annotation: aws.String("ELBSecurityPolicy-2016-08"),
+			expected:   []string{"ELBSecurityPolicy-2016-08", "ELBSecurityPolicy-2016-08", "ELBSecurityPolicy-2016-08"},
+		},
+		{
+			name:       "policies by port number and name",
+			annotation: aws.String("443=ELBSecurityPolicy-TLS-1-2-2017-01, admin=ELBSecurityPolicy-TLS13-1-2-2021-06"),
+			expected:   []string{"ELBSecurityPolicy-TLS-1-2-2017-01", "ELBSecurityPolicy-TLS13-1-2-2021-06", ""},
+		},
+		{
+			name:       "policies by port with a default",
+			annotation: aws.String("9443=ELBSecurityPolicy-TLS-1-2-2017-01,ELBSecurityPolicy-2016-08"),
+			expected:   []string{"ELBSecurityPolicy-2016-08", "ELBSecurityPolicy-2016-08", "ELBSecurityPolicy-TLS-1-2-2017-01"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			annotations := map[string]string{}
+			if tc.annotation != nil {
+				annotations[ServiceAnnotationLoadBalancerSSLNegotiationPolicy] = *tc.annotation
+			}
+			policies := getSSLNegotiationPolicies(annotations)
+			assert.Equal(t, tc.annotation == nil, policies == nil)
+			for i, port := range ports {
+				assert.Equal(t, tc.expected[i], policies.forPort(port), "policy of port %d", port.Port)
+			}
+		})
+	}
+}
+
+func TestEnsureLoadBalancerListeners(t *testing.T) {
+	const loadBalancerName = "lb"
+	const certificate = "arn:aws:acm:us-east-1:123456789012:certificate/abc"
+	listener := func(protocol string, port, instancePort int64, certificateID string) *elb.Listener {
+		l := &elb.Listener{
+			Protocol:         aws.String(protocol),
+			LoadBalancerPort: aws.Int64(port),
+			InstanceProtocol: aws.String("HTTP"),
+			InstancePort:     aws.Int64(instancePort),
+		}
+		if certificateID != "" {
+			l.SSLCertificateId = aws.String(certificateID)
+		}
+		return l
+	}
+	existing := []*elb.ListenerDescription{
+		{Listener: listener("HTTP", 80, 30080, "")},
+		{Listener: listener("HTTPS", 443, 30443, certificate)},
+		{Listener: listener("HTTP", 8080, 30808, "")},
+	}
+	newCloud := func(t *testing.T) (*Cloud, *MockedFakeELB) {
+		awsServices := newMockedFakeAWSServices(TestClusterID)
+		c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+		require.NoError(t, err)
+		return c, awsServices.elb.(*MockedFakeELB)
+	}
+
+	t.Run("unchanged listeners", func(t *testing.T) {
+		c, elbMock := newCloud(t)
+
+		changed, err := c.ensureLoadBalancerListeners(loadBalancerName, []*elb.Listener{
+			listener("HTTP", 80, 30080, ""),
+			listener("HTTPS", 443, 30443, certificate),
+			listener("HTTP", 8080, 30808, ""),
+		}, existing)
+		require.NoError(t, err)
+		assert.False(t, changed)
+		elbMock.AssertExpectations(t)
+	})
+
+	t.Run("one of three ports changed", func(t *testing.T) {
+		c, elbMock := newCloud(t)
+		elbMock.On("DeleteLoadBalancerListeners", &elb.DeleteLoadBalancerListenersInput{
+			LoadBalancerName:  aws.String(loadBalancerName),
+			LoadBalancerPorts: []*int64{aws.Int64(8080)},
+		}).Return(&elb.DeleteLoadBalancerListenersOutput{}).Once()
+		elbMock.On("CreateLoadBalancerListeners", &elb.CreateLoadBalancerListenersInput{
+			LoadBalancerName: aws.String(loadBalancerName),
+			Listeners:        []*elb.Listener{listener("HTTP", 9090, 30808, "")},
+		}).Return(&elb.CreateLoadBalancerListenersOutput{}).Once()
+
+		changed, err := c.ensureLoadBalancerListeners(loadBalancerName, []*elb.Listener{
+			listener("HTTP", 80, 30080, ""),
+			listener("HTTPS", 443, 30443, certificate),
+			listener("HTTP", 9090, 30808, ""),
+		}, existing)
+		require.NoError(t, err)
+		assert.True(t, changed)
+		elbMock.AssertExpectations(t)
+	})
+
+	t.Run("certificate changed", func(t *testing.T) {
+		c, elbMock := newCloud(t)
+		const newCertificate = "arn:aws:acm:us-east-1:123456789012:certificate/def"
+		elbMock.On("SetLoadBalancerListenerSSLCertificate", &elb.SetLoadBalancerListenerSSLCertificateInput{
+			LoadBalancerName: aws.String(loadBalancerName),
+			LoadBalancerPort: aws.Int64(443),
+			SSLCertificateId: aws.String(newCertificate),
+		}).Return(&elb.SetLoadBalancerListenerSSLCertificateOutput{}).Once()
+
+		changed, err := c.ensureLoadBalancerListeners(loadBalancerName, []*elb.Listener{
+			listener("HTTP", 80, 30080, ""),
+			listener("HTTPS", 443, 30443, newCertificate),
+			listener("HTTP", 8080, 30808, ""),
+		}, existing)
+		require.NoError(t, err)
+		assert.True(t, changed)
+		elbMock.AssertExpectations(t)
+	})
+
+	t.Run("X-Forwarded-For enabled on a TCP listener", func(t *testing.T) {
+		c, elbMock := newCloud(t)
+		port := v1.ServicePort{Port: 80, NodePort: 30080, Protocol: v1.ProtocolTCP}
+		tcpListener, err := buildListener(port, map[string]string{}, nil)
+		require.NoError(t, err)
+		httpListener, err := buildListener(port, map[string]string{ServiceAnnotationLoadBalancerXForwardedFor: "true"}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "http", aws.StringValue(httpListener.Protocol))
+		assert.Equal(t, "http", aws.StringValue(httpListener.InstanceProtocol))
+
+		// The listeners are described with upper case protocols
+		described := &elb.Listener{Protocol: aws.String("TCP"), LoadBalancerPort: aws.Int64(80), InstanceProtocol: aws.String("TCP"), InstancePort: aws.Int64(30080)}
+		changed, err := c.ensureLoadBalancerListeners(loadBalancerName, []*elb.Listener{tcpListener}, []*elb.ListenerDescription{{Listener: described}})
+		require.NoError(t, err)
+		assert.False(t, changed)
+
+		elbMock.On("DeleteLoadBalancerListeners", &elb.DeleteLoadBalancerListenersInput{
+			LoadBalancerName:  aws.String(loadBalancerName),
+			LoadBalancerPorts: []*int64{aws.Int64(80)},
+		}).Return(&elb.DeleteLoadBalancerListenersOutput{}).Once()
+		elbMock.On("CreateLoadBalancerListeners", &elb.CreateLoadBalancerListenersInput{
+			LoadBalancerName: aws.String(loadBalancerName),
+			Listeners:        []*elb.Listener{httpListener},
+		}).Return(&elb.CreateLoadBalancerListenersOutput{}).Once()
+		changed, err = c.ensureLoadBalancerListeners(loadBalancerName, []*elb.Listener{httpListener}, []*elb.ListenerDescription{{Listener: described}})
+		require.NoError(t, err)
+		assert.True(t, changed)
+		elbMock.AssertExpectations(t)
+	})
+}
+
+func TestEnsureLoadBalancerSSLNegotiationPolicies(t *testing.T) {
+	const loadBalancerName = "lb"
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "myservice", Namespace: "default", Annotations: map[string]string{}},
+		Spec: v1.ServiceSpec{Ports: []v1.ServicePort{
+			{Name: "http", Port: 80},
+			{Name: "https", Port: 443},
+			{Name: "admin", Port: 8443},
+		}},
+	}
+	listener := func(protocol string, port int64, policyNames ...string) *elb.ListenerDescription {
+		return &elb.ListenerDescription{
+			Listener:    &elb.Listener{Protocol: aws.String(protocol), LoadBalancerPort: aws.Int64(port)},
+			PolicyNames: aws.StringSlice(policyNames),
+		}
+	}
+	newCloud := func(t *testing.T) (*Cloud, *MockedFakeELB, *record.FakeRecorder) {
+		awsServices := newMockedFakeAWSServices(TestClusterID)
+		c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+		require.NoError(t, err)
+		recorder := record.NewFakeRecorder(10)
+		c.eventRecorder = recorder
+		return c, awsServices.elb.(*MockedFakeELB), recorder
+	}
+
+	t.Run("single policy for all listeners", func(t *testing.T) {
+		c, elbMock, recorder := newCloud(t)
+		svc := service.DeepCopy()
+		svc.Annotations[ServiceAnnotationLoadBalancerSSLNegotiationPolicy] = "ELBSecurityPolicy-2016-08"
+		elbMock.expectSSLNegotiationPolicy(loadBalancerName, "ELBSecurityPolicy-2016-08")
+		elbMock.expectListenerPolicies(loadBalancerName, 443, "k8s-SSLNegotiationPolicy-ELBSecurityPolicy-2016-08")
+		elbMock.expectListenerPolicies(loadBalancerName, 8443, "k8s-SSLNegotiationPolicy-ELBSecurityPolicy-2016-08")
+
+		err := c.ensureLoadBalancerSSLNegotiationPolicies(svc, &elb.LoadBalancerDescription{
+			LoadBalancerName: aws.String(loadBalancerName),
+			ListenerDescriptions: []*elb.ListenerDescription{
+				listener("HTTP", 80), listener("HTTPS", 443), listener("SSL", 8443),
+			},
+		})
+		require.NoError(t, err)
+		elbMock.AssertExpectations(t)
+		assert.Empty(t, recorder.Events)
+	})
+
+	t.Run("policies by port", func(t *testing.T) {
+		c, elbMock, recorder := newCloud(t)
+		svc := service.DeepCopy()
+		svc.Annotations[ServiceAnnotationLoadBalancerSSLNegotiationPolicy] = "443=ELBSecurityPolicy-TLS-1-2-2017-01,admin=My-Custom-Policy"
+		elbMock.expectSSLNegotiationPolicy(loadBalancerName, "ELBSecurityPolicy-TLS-1-2-2017-01")
+		elbMock.expectSSLNegotiationPolicy(loadBalancerName, "My-Custom-Policy")
+		elbMock.expectListenerPolicies(loadBalancerName, 443, "k8s-SSLNegotiationPolicy-ELBSecurityPolicy-TLS-1-2-2017-01")
+		elbMock.expectListenerPolicies(loadBalancerName, 8443, "k8s-SSLNegotiationPolicy-My-Custom-Policy")
+
+		err := c.ensureLoadBalancerSSLNegotiationPolicies(svc, &elb.LoadBalancerDescription{
+			LoadBalancerName: aws.String(loadBalancerName),
+			ListenerDescriptions: []*elb.ListenerDescription{
+				listener("HTTP", 80), listener("HTTPS", 443), listener("SSL", 8443),
+			},
+		})
+		require.NoError(t, err)
+		elbMock.AssertExpectations(t)
+		require.Len(t, recorder.Events, 1)
+		event := <-recorder.Events
+		assert.Contains(t, event, "UnknownSSLNegotiationPolicy")
+		assert.Contains(t, event, "My-Custom-Policy")
+	})
+
+	t.Run("removes the policy of listeners removed from the mapping", func(t *testing.T) {
+		c, elbMock, _ := newCloud(t)
+		svc := service.DeepCopy()
+		svc.Annotations[ServiceAnnotationLoadBalancerSSLNegotiationPolicy] = "443=ELBSecurityPolicy-TLS-1-2-2017-01"
+		elbMock.expectSSLNegotiationPolicy(loadBalancerName, "ELBSecurityPolicy-TLS-1-2-2017-01")
+		elbMock.expectListenerPolicies(loadBalancerName, 443, "k8s-SSLNegotiationPolicy-ELBSecurityPolicy-TLS-1-2-2017-01")
+		elbMock.expectListenerPolicies(loadBalancerName, 8443, "my-stickiness-policy")
+
+		err := c.ensureLoadBalancerSSLNegotiationPolicies(svc, &elb.LoadBalancerDescription{
+			LoadBalancerName: aws.String(loadBalancerName),
+			ListenerDescriptions: []*elb.ListenerDescription{
+				listener("HTTPS", 443, "k8s-SSLNegotiationPolicy-ELBSecurityPolicy-2016-08"),
+				listener("SSL", 8443, "k8s-SSLNegotiationPolicy-ELBSecurityPolicy-2016-08", "my-stickiness-policy"),
+			},
+		})
+		require.NoError(t, err)
+		elbMock.AssertExpectations(t)
+	})
+}
+
+func TestNLBSSLNegotiationPolicies(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	recorder := record.NewFakeRecorder(10)
+	c.eventRecorder = recorder
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+	listenerSSLPolicies := func() map[int64]string {
+		policies := map[int64]string{}
+		for _, listener := range elbv2Mock.Listeners {
+			policies[aws.Int64Value(listener.Port)] = aws.StringValue(listener.SslPolicy)
 		}
+		return policies
 	}
 
-	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, fauxService, nodes[:2])
-	if err != nil {
-		t.Errorf("EnsureLoadBalancer returned an error: %v", err)
-	}
-	for _, instances := range awsServices.elbv2.(*MockedFakeELBV2).RegisteredInstances {
-		if len(instances) != 2 {
-			t.Errorf("Expected 2 nodes registered with target group, saw %d", len(instances))
+	svc := newNLBService(map[string]string{
+		ServiceAnnotationLoadBalancerCertificate:          "arn:aws:acm:us-west-2:123456789012:certificate/abc",
+		ServiceAnnotationLoadBalancerSSLNegotiationPolicy: "tls=ELBSecurityPolicy-TLS13-1-2-2021-06,ELBSecurityPolicy-2016-08",
+	})
+	svc.Spec.Ports = append(svc.Spec.Ports, v1.ServicePort{
+		Name:       "tls",
+		Port:       8443,
+		NodePort:   31174,
+		TargetPort: intstr.FromInt(31174),
+		Protocol:   v1.ProtocolTCP,
+	})
+	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]string{8080: "ELBSecurityPolicy-2016-08", 8443: "ELBSecurityPolicy-TLS13-1-2-2021-06"}, listenerSSLPolicies())
+	assert.Empty(t, recorder.Events)
+
+	// Changing the mapping updates the listeners
+	svc.Annotations[ServiceAnnotationLoadBalancerSSLNegotiationPolicy] = "8080=ELBSecurityPolicy-TLS-1-2-2017-01,8443=Unknown-Policy"
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, map[int64]string{8080: "ELBSecurityPolicy-TLS-1-2-2017-01", 8443: "Unknown-Policy"}, listenerSSLPolicies())
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "UnknownSSLNegotiationPolicy")
+}
+
+func TestLoadBalancerLogContext(t *testing.T) {
+	c, _, nodes := newMockedNLBCloud(t)
+	var logs bytes.Buffer
+	logger := textlogger.NewLogger(textlogger.NewConfig(textlogger.Output(&logs), textlogger.Verbosity(4)))
+	ctx := klog.NewContext(context.TODO(), logger)
+	svc := newNLBService(map[string]string{})
+	svc.Namespace = "default"
+	loadBalancerName := c.GetLoadBalancerName(ctx, TestClusterName, svc)
+
+	assertLogContext := func(fields ...string) {
+		t.Helper()
+		lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+		require.NotEmpty(t, lines)
+		for _, line := range lines {
+			for _, field := range fields {
+				assert.Contains(t, line, field)
+			}
 		}
+		logs.Reset()
 	}
 
-	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, fauxService, nodes)
-	if err != nil {
-		t.Errorf("EnsureLoadBalancer returned an error: %v", err)
-	}
-	for _, instances := range awsServices.elbv2.(*MockedFakeELBV2).RegisteredInstances {
-		if len(instances) != 3 {
-			t.Errorf("Expected 3 nodes registered with target group, saw %d", len(instances))
+	// Every line of the reconcile is attributed to the service, the lines after the load balancer is created to its ARN
+	_, err := c.EnsureLoadBalancer(ctx, TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Contains(t, logs.String(), `"Creating load balancer listener" service="default/myservice" loadBalancerARN="arn:`)
+	assert.Regexp(t, `"Registering targets" service="default/myservice" loadBalancerARN="arn:[^"]+" targetGroupARN="arn:`, logs.String())
+	assertLogContext(`service="default/myservice"`)
+
+	require.NoError(t, c.EnsureLoadBalancerDeleted(ctx, TestClusterName, svc))
+	require.NoError(t, c.EnsureLoadBalancerDeleted(ctx, TestClusterName, svc))
+	assert.Contains(t, logs.String(), `"Load balancer already deleted" service="default/myservice" loadBalancer="`+loadBalancerName+`"`)
+	assertLogContext(`service="default/myservice"`)
+}
+
+func TestNLBSSLCertificateDiscovery(t *testing.T) {
+	c, awsServices, nodes := newMockedNLBCloud(t)
+	recorder := record.NewFakeRecorder(10)
+	c.eventRecorder = recorder
+	elbv2Mock := awsServices.elbv2.(*MockedFakeELBV2)
+	listenerCertificates := func() []string {
+		var certificates []string
+		for _, listener := range elbv2Mock.Listeners {
+			for _, certificate := range listener.Certificates {
+				certificates = append(certificates, aws.StringValue(certificate.CertificateArn))
+			}
+		}
+		return certificates
+	}
+	certificate := func(arn, domainName string, alternativeNames ...string) *acm.CertificateSummary {
+		return &acm.CertificateSummary{
+			CertificateArn:                  aws.String(arn),
+			DomainName:                      aws.String(domainName),
+			SubjectAlternativeNameSummaries: aws.StringSlice(alternativeNames),
+			Status:                          aws.String(acm.CertificateStatusIssued),
+			NotAfter:                        aws.Time(time.Now().Add(24 * time.Hour)),
 		}
 	}
+	svc := newNLBService(map[string]string{
+		ServiceAnnotationLoadBalancerCertificate: "auto",
+		ServiceAnnotationLoadBalancerHostname:    "app.example.com",
+	})
 
-	fauxService.Annotations[ServiceAnnotationLoadBalancerHealthCheckProtocol] = "http"
-	tgARN := aws.StringValue(awsServices.elbv2.(*MockedFakeELBV2).Listeners[0].DefaultActions[0].TargetGroupArn)
-	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, fauxService, nodes)
-	if err != nil {
-		t.Errorf("EnsureLoadBalancer returned an error: %v", err)
-	}
-	assert.Equal(t, 1, len(awsServices.elbv2.(*MockedFakeELBV2).Listeners))
-	assert.NotEqual(t, tgARN, aws.StringValue(awsServices.elbv2.(*MockedFakeELBV2).Listeners[0].DefaultActions[0].TargetGroupArn))
+	// No certificate covers the hostname
+	awsServices.acm.Certificates = []*acm.CertificateSummary{certificate("arn:other", "other.example.com")}
+	_, err := c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	assert.ErrorContains(t, err, `no issued ACM certificate found for hostname "app.example.com"`)
+
+	// A single certificate covers the hostname, certificates that aren't issued or have expired are ignored
+	pending := certificate("arn:pending", "app.example.com")
+	pending.Status = aws.String(acm.CertificateStatusPendingValidation)
+	expired := certificate("arn:expired", "app.example.com")
+	expired.NotAfter = aws.Time(time.Now().Add(-time.Hour))
+	awsServices.acm.Certificates = []*acm.CertificateSummary{
+		certificate("arn:other", "other.example.com"),
+		certificate("arn:wildcard", "example.org", "*.example.com"),
+		pending,
+		expired,
+	}
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"arn:wildcard"}, listenerCertificates())
+	assert.Equal(t, "auto", svc.Annotations[ServiceAnnotationLoadBalancerCertificate])
+
+	// A certificate that replaces it is picked up on the next sync
+	awsServices.acm.Certificates = []*acm.CertificateSummary{certificate("arn:renewed", "APP.example.com")}
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"arn:renewed"}, listenerCertificates())
+	assert.Empty(t, recorder.Events)
+
+	// Several certificates cover the hostname
+	awsServices.acm.Certificates = append(awsServices.acm.Certificates, certificate("arn:wildcard", "*.example.com"))
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	assert.ErrorContains(t, err, "found 2 issued ACM certificates")
+	assert.Equal(t, []string{"arn:renewed"}, listenerCertificates())
+	require.Len(t, recorder.Events, 1)
+	assert.Equal(t, `Warning AmbiguousCertificate Found 2 issued ACM certificates for hostname "app.example.com", expected exactly one: arn:renewed, arn:wildcard`, <-recorder.Events)
+
+	// The hostname is required
+	delete(svc.Annotations, ServiceAnnotationLoadBalancerHostname)
+	_, err = c.EnsureLoadBalancer(context.TODO(), TestClusterName, svc, nodes)
+	assert.ErrorContains(t, err, ServiceAnnotationLoadBalancerHostname+" is required")
+}
+
+func TestDomainNameCoversHostname(t *testing.T) {
+	assert.True(t, domainNameCoversHostname("app.example.com", "app.example.com"))
+	assert.True(t, domainNameCoversHostname("App.Example.com.", "app.example.com"))
+	assert.True(t, domainNameCoversHostname("*.example.com", "app.example.com"))
+	assert.False(t, domainNameCoversHostname("*.example.com", "example.com"))
+	assert.False(t, domainNameCoversHostname("*.example.com", "a.app.example.com"))
+	assert.False(t, domainNameCoversHostname("example.com", "app.example.com"))
+	assert.False(t, domainNameCoversHostname("", ""))
+}
+
+func TestClampConnectionDrainingTimeout(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	c := &Cloud{eventRecorder: recorder}
+	svc := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice"}}
+
+	assert.Equal(t, int64(300), c.clampConnectionDrainingTimeout(svc, 300))
+	assert.Equal(t, int64(1), c.clampConnectionDrainingTimeout(svc, 1))
+	assert.Equal(t, int64(3600), c.clampConnectionDrainingTimeout(svc, 3600))
+	assert.Empty(t, recorder.Events)
+
+	assert.Equal(t, int64(1), c.clampConnectionDrainingTimeout(svc, 0))
+	require.Len(t, recorder.Events, 1)
+	assert.Equal(t, "Warning InvalidConnectionDrainingTimeout service.beta.kubernetes.io/aws-load-balancer-connection-draining-timeout=0 is outside of the allowed range 1-3600, using 1", <-recorder.Events)
+
+	// The event is only recorded again when the value changes
+	assert.Equal(t, int64(1), c.clampConnectionDrainingTimeout(svc, 0))
+	assert.Empty(t, recorder.Events)
+	assert.Equal(t, int64(3600), c.clampConnectionDrainingTimeout(svc, 3601))
+	require.Len(t, recorder.Events, 1)
+	<-recorder.Events
+	assert.Equal(t, int64(300), c.clampConnectionDrainingTimeout(svc, 300))
+	assert.Equal(t, int64(3600), c.clampConnectionDrainingTimeout(svc, 3601))
+	require.Len(t, recorder.Events, 1)
+	<-recorder.Events
+
+	// Once the load balancer is deleted, the value is reported again
+	c.forgetWarnedAnnotations(svc)
+	assert.Equal(t, int64(3600), c.clampConnectionDrainingTimeout(svc, 3601))
+	require.Len(t, recorder.Events, 1)
+	<-recorder.Events
+
+	// Without an event recorder the timeout is still clamped
+	assert.Equal(t, int64(3600), (&Cloud{}).clampConnectionDrainingTimeout(svc, 4000))
+}
+
+func TestWarnOnUnsupportedWAFACL(t *testing.T) {
+	recorder := record.NewFakeRecorder(10)
+	c := &Cloud{eventRecorder: recorder}
+	svc := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "myservice"}}
+
+	c.warnOnUnsupportedWAFACL(svc)
+	assert.Empty(t, recorder.Events)
+
+	svc.Annotations = map[string]string{ServiceAnnotationLoadBalancerWAFACLID: "arn:aws:wafv2:us-west-2:123456789012:regional/webacl/acl/1234"}
+	c.warnOnUnsupportedWAFACL(svc)
+	require.Len(t, recorder.Events, 1)
+	assert.Equal(t, "Warning UnsupportedWAFWebACL Ignoring service.beta.kubernetes.io/aws-load-balancer-waf-acl-id=arn:aws:wafv2:us-west-2:123456789012:regional/webacl/acl/1234, WAF web ACLs can only be associated with application load balancers", <-recorder.Events)
+
+	// The event is only recorded again when the annotation changes
+	c.warnOnUnsupportedWAFACL(svc)
+	assert.Empty(t, recorder.Events)
+	svc.Annotations[ServiceAnnotationLoadBalancerWAFACLID] = "arn:aws:wafv2:us-west-2:123456789012:regional/webacl/acl/5678"
+	c.warnOnUnsupportedWAFACL(svc)
+	require.Len(t, recorder.Events, 1)
+	<-recorder.Events
+	delete(svc.Annotations, ServiceAnnotationLoadBalancerWAFACLID)
+	c.warnOnUnsupportedWAFACL(svc)
+	svc.Annotations[ServiceAnnotationLoadBalancerWAFACLID] = "arn:aws:wafv2:us-west-2:123456789012:regional/webacl/acl/5678"
+	c.warnOnUnsupportedWAFACL(svc)
+	require.Len(t, recorder.Events, 1)
+	<-recorder.Events
 }
 
 func makeNamedNode(s *FakeAWSServices, offset int, name string) *v1.Node {
@@ -3398,6 +6240,43 @@ func TestCloud_buildNLBHealthCheckConfiguration(t *testing.T) {
 			},
 			wantError: false,
 		},
+		{
+			name:        "local with ip targets",
+			annotations: map[string]string{},
+			service: &v1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "test-svc",
+					UID:  "UID",
+					Annotations: map[string]string{
+						ServiceAnnotationLoadBalancerType:          "nlb",
+						ServiceAnnotationLoadBalancerNLBTargetType: "ip",
+					},
+				},
+				Spec: v1.ServiceSpec{
+					Type: v1.ServiceTypeLoadBalancer,
+					Ports: []v1.ServicePort{
+						{
+							Name:       "http",
+							Protocol:   v1.ProtocolTCP,
+							Port:       8080,
+							TargetPort: intstr.FromInt(8880),
+							NodePort:   32205,
+						},
+					},
+					ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeLocal,
+					HealthCheckNodePort:   32213,
+				},
+			},
+			want: healthCheckConfig{
+				Port:               "traffic-port",
+				Protocol:           elbv2.ProtocolEnumTcp,
+				Interval:           30,
+				Timeout:            10,
+				HealthyThreshold:   3,
+				UnhealthyThreshold: 3,
+			},
+			wantError: false,
+		},
 		{
 			name: "with TCP healthcheck",
 			service: &v1.Service{
@@ -3718,7 +6597,9 @@ func verifyNodeAddressesForFargate(t *testing.T, ipFamily string, verifyPublicIP
 
 func TestNodeAddressesOrderedByDeviceIndex(t *testing.T) {
 	awsServices := newMockedFakeAWSServices(TestClusterID)
-	c, _ := newAWSCloud(config.CloudConfig{}, awsServices)
+	cfg := config.CloudConfig{}
+	cfg.Global.EnableSecondaryIPNodeAddresses = true
+	c, _ := newAWSCloud(cfg, awsServices)
 
 	nodeAddresses, _ := c.NodeAddressesByProviderID(context.TODO(), "aws:///us-west-2a/i-self")
 	expectedAddresses := []v1.NodeAddress{
@@ -3733,6 +6614,86 @@ func TestNodeAddressesOrderedByDeviceIndex(t *testing.T) {
 	assert.Equal(t, expectedAddresses, nodeAddresses)
 }
 
+func TestNodeAddressesIPFamilies(t *testing.T) {
+	// newInstance returns an instance whose network interfaces are listed out of device index order
+	newInstance := func() *ec2types.Instance {
+		return &ec2types.Instance{
+			InstanceId:     aws.String("i-dualstack"),
+			PrivateDnsName: aws.String("ip-10-0-0-1.ec2.internal"),
+			NetworkInterfaces: []ec2types.InstanceNetworkInterface{
+				{
+					Status:           ec2types.NetworkInterfaceStatusInUse,
+					Attachment:       &ec2types.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int32(1)},
+					PrivateIpAddress: aws.String("10.0.1.1"),
+					Ipv6Addresses:    []ec2types.InstanceIpv6Address{{Ipv6Address: aws.String("2600:1f14:abc:1::1")}},
+				},
+				{
+					Status:           ec2types.NetworkInterfaceStatusInUse,
+					Attachment:       &ec2types.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int32(0)},
+					PrivateIpAddress: aws.String("10.0.0.1"),
+					Ipv6Addresses: []ec2types.InstanceIpv6Address{
+						{Ipv6Address: aws.String("2600:1f14:abc:0::10")},
+						{Ipv6Address: aws.String("2600:1f14:abc:0::1"), IsPrimaryIpv6: aws.Bool(true)},
+					},
+				},
+				{
+					// the same address reported by another interface is only added once
+					Status:        ec2types.NetworkInterfaceStatusInUse,
+					Attachment:    &ec2types.InstanceNetworkInterfaceAttachment{DeviceIndex: aws.Int32(2)},
+					Ipv6Addresses: []ec2types.InstanceIpv6Address{{Ipv6Address: aws.String("2600:1f14:abc:1::1")}},
+				},
+			},
+		}
+	}
+	ipv4Addresses := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.1"},
+		{Type: v1.NodeInternalIP, Address: "10.0.1.1"},
+		{Type: v1.NodeInternalDNS, Address: "ip-10-0-0-1.ec2.internal"},
+		{Type: v1.NodeHostName, Address: "ip-10-0-0-1.ec2.internal"},
+	}
+	ipv6Addresses := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "2600:1f14:abc::1"},
+		{Type: v1.NodeInternalIP, Address: "2600:1f14:abc:1::1"},
+	}
+	for _, tc := range []struct {
+		name     string
+		families []string
+		expected []v1.NodeAddress
+	}{
+		{
+			name:     "IPv4 only",
+			families: []string{"ipv4"},
+			expected: ipv4Addresses,
+		},
+		{
+			name:     "IPv6 only",
+			families: []string{"ipv6"},
+			expected: ipv6Addresses,
+		},
+		{
+			name:     "dual-stack",
+			families: []string{"ipv4", "ipv6"},
+			expected: append(slices.Clone(ipv4Addresses), ipv6Addresses...),
+		},
+		{
+			name:     "dual-stack preferring IPv6",
+			families: []string{"ipv6", "ipv4"},
+			expected: append(slices.Clone(ipv6Addresses), ipv4Addresses...),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.CloudConfig{}
+			cfg.Global.NodeIPFamilies = tc.families
+			c, err := newAWSCloud(cfg, newMockedFakeAWSServices(TestClusterID))
+			require.NoError(t, err)
+
+			addresses, err := c.getInstanceNodeAddress(newInstance())
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, addresses)
+		})
+	}
+}
+
 func TestInstanceExistsByProviderIDForFargate(t *testing.T) {
 	awsServices := newMockedFakeAWSServices(TestClusterID)
 	c, _ := newAWSCloud(config.CloudConfig{}, awsServices)
@@ -3753,7 +6714,7 @@ func TestInstanceExistsByProviderIDWithNodeNameForFargate(t *testing.T) {
 
 func TestInstanceExistsByProviderIDForInstanceNotFound(t *testing.T) {
 	mockedEC2API := newMockedEC2API()
-	c := &Cloud{ec2: &awsSdkEC2{ec2: mockedEC2API}, describeInstanceBatcher: newdescribeInstanceBatcher(context.Background(), &awsSdkEC2{ec2: mockedEC2API})}
+	c := &Cloud{ec2: &awsSdkEC2{ec2: mockedEC2API}, describeInstanceBatcher: newdescribeInstanceBatcher(context.Background(), &awsSdkEC2{ec2: mockedEC2API}, nil), instanceIDResolver: providerIDInstanceIDResolver{}}
 
 	mockedEC2API.On("DescribeInstances", mock.Anything).Return(&ec2.DescribeInstancesOutput{}, awserr.New("InvalidInstanceID.NotFound", "Instance not found", nil))
 
@@ -3807,6 +6768,43 @@ func TestGetZoneByProviderIDForFargate(t *testing.T) {
 	assert.Equal(t, "us-west-2c", zoneDetails.FailureDomain)
 }
 
+func TestGetZoneByProviderIDBatched(t *testing.T) {
+	awsServices := NewFakeAWSServices(TestClusterID)
+	zones := []string{"us-west-2a", "us-west-2b", "us-west-2c"}
+	for i := 0; i < 10; i++ {
+		awsServices.instances = append(awsServices.instances, &ec2types.Instance{
+			InstanceId: aws.String(fmt.Sprintf("i-%017d", i)),
+			Placement:  &ec2types.Placement{AvailabilityZone: aws.String(zones[i%len(zones)])},
+		})
+	}
+	c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+	require.NoError(t, err)
+	fakeEC2 := awsServices.ec2.(*FakeEC2Impl)
+	fakeEC2.apiCalls = map[string]int{}
+
+	// Concurrent lookups of different instances are made with a single request
+	results := make([]cloudprovider.Zone, 10)
+	errs := make([]error, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = c.GetZoneByProviderID(context.TODO(), fmt.Sprintf("aws:///%s/i-%017d", zones[i%len(zones)], i))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, fakeEC2.apiCalls["DescribeInstances"])
+	for i := 0; i < 10; i++ {
+		require.NoError(t, errs[i])
+		assert.Equal(t, cloudprovider.Zone{FailureDomain: zones[i%len(zones)], Region: "us-west-2"}, results[i])
+	}
+
+	_, err = c.GetZoneByProviderID(context.TODO(), "aws:///us-west-2a/i-99999999999999999")
+	assert.Equal(t, cloudprovider.InstanceNotFound, err)
+}
+
 func TestGetRegionFromMetadata(t *testing.T) {
 	awsServices := newMockedFakeAWSServices(TestClusterID)
 	// Returns region from zone if set
@@ -3825,6 +6823,53 @@ func TestGetRegionFromMetadata(t *testing.T) {
 	region, err = getRegionFromMetadata(cfg, awsServices.metadata)
 	assert.NoError(t, err)
 	assert.Equal(t, "us-west-2", region)
+
+	// Falls back to the region or zone of the config when the metadata service is unreachable
+	awsServices.metadata.err = errors.New("EC2MetadataRequestError: failed to get EC2 instance identity document")
+	cfg = config.CloudConfig{}
+	cfg.Global.Region = "eu-west-1"
+	region, err = getRegionFromMetadata(cfg, awsServices.metadata)
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-west-1", region)
+	cfg = config.CloudConfig{}
+	cfg.Global.Zone = "eu-west-1b"
+	region, err = getRegionFromMetadata(cfg, awsServices.metadata)
+	assert.NoError(t, err)
+	assert.Equal(t, "eu-west-1", region)
+	// Returns a clear error when neither is available
+	cfg = config.CloudConfig{}
+	_, err = getRegionFromMetadata(cfg, awsServices.metadata)
+	assert.ErrorContains(t, err, "set Region or Zone in the cloud config")
+	assert.ErrorContains(t, err, "EC2MetadataRequestError")
+}
+
+func TestGetZoneWithoutMetadata(t *testing.T) {
+	awsServices := newMockedFakeAWSServices(TestClusterID)
+	awsServices.metadata.err = errors.New("EC2MetadataRequestError: failed to get EC2 instance identity document")
+	cfg := config.CloudConfig{}
+	cfg.Global.VPC = "vpc-123456"
+	cfg.Global.SubnetID = "subnet-123456"
+	cfg.Global.KubernetesClusterID = TestClusterID
+
+	// The zone of a management cluster outside of EC2 comes from the config
+	cfg.Global.Zone = "eu-west-1b"
+	c, err := newAWSCloud(cfg, awsServices)
+	require.NoError(t, err)
+	zone, err := c.GetZone(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, cloudprovider.Zone{FailureDomain: "eu-west-1b", Region: "eu-west-1"}, zone)
+
+	cfg.Global.Zone = ""
+	cfg.Global.Region = "eu-west-1"
+	c, err = newAWSCloud(cfg, awsServices)
+	require.NoError(t, err)
+	zone, err = c.GetZone(context.TODO())
+	require.NoError(t, err)
+	assert.Equal(t, cloudprovider.Zone{Region: "eu-west-1"}, zone)
+
+	cfg.Global.Region = ""
+	_, err = newAWSCloud(cfg, awsServices)
+	assert.ErrorContains(t, err, "set Region or Zone in the cloud config")
 }
 
 type MockedEC2API struct {
@@ -3934,6 +6979,557 @@ func TestDescribeInstances(t *testing.T) {
 	}
 }
 
+func TestDescribeInstancesPagination(t *testing.T) {
+	awsServices := NewFakeAWSServices(TestClusterID).WithInstances(25)
+	fakeEC2 := awsSdkEC2{
+		ec2: NewFakeEC2API(awsServices),
+	}
+
+	instances, err := fakeEC2.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{MaxResults: aws.Int32(10)})
+	assert.NoError(t, err)
+	// 25 seeded instances plus the self instance are served in pages of 10
+	assert.Len(t, instances, 26)
+	ids := sets.New[string]()
+	for _, instance := range instances {
+		ids.Insert(aws.StringValue(instance.InstanceId))
+	}
+	assert.Equal(t, 26, ids.Len())
+	assert.True(t, ids.Has("i-self"))
+	assert.True(t, ids.Has("i-24"))
+	calls := 0
+	for key, count := range awsServices.callCounts {
+		if strings.HasPrefix(key, "ec2:DescribeInstances:") {
+			calls += count
+		}
+	}
+	assert.Equal(t, 3, calls)
+}
+
+func TestFakeEC2InjectedErrors(t *testing.T) {
+	awsServices := NewFakeAWSServices(TestClusterID)
+	fakeEC2 := awsServices.ec2.(*FakeEC2Impl)
+	throttled := awserr.New("RequestLimitExceeded", "Request limit exceeded", nil)
+
+	fakeEC2.SetErrorOnCall("DescribeInstances", 3, throttled)
+	for call := 1; call <= 4; call++ {
+		_, err := fakeEC2.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{})
+		if call == 3 {
+			assert.Equal(t, throttled, err, "call %d", call)
+		} else {
+			assert.NoError(t, err, "call %d", call)
+		}
+	}
+
+	fakeEC2.SetError("DescribeSubnets", throttled)
+	_, err := fakeEC2.DescribeSubnets(context.TODO(), &ec2.DescribeSubnetsInput{})
+	assert.Equal(t, throttled, err)
+	_, err = fakeEC2.DescribeSubnets(context.TODO(), &ec2.DescribeSubnetsInput{})
+	assert.Equal(t, throttled, err)
+	fakeEC2.SetError("DescribeSubnets", nil)
+	_, err = fakeEC2.DescribeSubnets(context.TODO(), &ec2.DescribeSubnetsInput{})
+	assert.NoError(t, err)
+}
+
+func TestGetAutoScalingGroupInstances(t *testing.T) {
+	awsServices := NewFakeAWSServices(TestClusterID)
+	c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+	require.NoError(t, err)
+
+	var instances []*ec2types.Instance
+	for i := 0; i < 6; i++ {
+		instance := &ec2types.Instance{InstanceId: aws.String(fmt.Sprintf("i-%d", i))}
+		// The last instance doesn't belong to an auto scaling group
+		if i < 5 {
+			instance.Tags = []ec2types.Tag{{Key: aws.String(TagNameAutoScalingGroupName), Value: aws.String(fmt.Sprintf("asg-%d", i%2))}}
+		}
+		instances = append(instances, instance)
+	}
+	awsServices.asg.AutoScalingGroups = []*autoscaling.Group{
+		{
+			AutoScalingGroupName: aws.String("asg-0"),
+			Instances: []*autoscaling.Instance{
+				{InstanceId: aws.String("i-0"), LifecycleState: aws.String(autoscaling.LifecycleStateInService)},
+				{InstanceId: aws.String("i-2"), LifecycleState: aws.String(autoscaling.LifecycleStateTerminatingWait)},
+				{InstanceId: aws.String("i-4"), LifecycleState: aws.String(autoscaling.LifecycleStateInService)},
+			},
+		},
+		{
+			AutoScalingGroupName: aws.String("asg-1"),
+			Instances: []*autoscaling.Instance{
+				{InstanceId: aws.String("i-1"), LifecycleState: aws.String(autoscaling.LifecycleStatePending)},
+				// i-3 has already left the group
+			},
+		},
+	}
+
+	members, err := c.getAutoScalingGroupInstances(context.TODO(), instances)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"i-0": autoscaling.LifecycleStateInService,
+		"i-1": autoscaling.LifecycleStatePending,
+		"i-2": autoscaling.LifecycleStateTerminatingWait,
+		"i-4": autoscaling.LifecycleStateInService,
+	}, lo.MapValues(members, func(member *autoscaling.Instance, _ string) string { return aws.StringValue(member.LifecycleState) }))
+	// Both groups are described together
+	assert.Equal(t, 1, awsServices.callCounts["autoscaling:DescribeAutoScalingGroups:"])
+
+	// Described groups are cached
+	_, err = c.getAutoScalingGroupInstances(context.TODO(), instances)
+	require.NoError(t, err)
+	assert.Equal(t, 1, awsServices.callCounts["autoscaling:DescribeAutoScalingGroups:"])
+}
+
+func TestDescribeSecurityGroupBatching(t *testing.T) {
+	awsServices := NewFakeAWSServices(TestClusterID)
+	fakeEC2 := awsServices.ec2.(*FakeEC2Impl)
+	for i := 0; i < 20; i++ {
+		fakeEC2.SecurityGroups = append(fakeEC2.SecurityGroups, ec2types.SecurityGroup{
+			GroupId:   aws.String(fmt.Sprintf("sg-%d", i)),
+			GroupName: aws.String(fmt.Sprintf("group-%d", i)),
+		})
+	}
+	c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+	require.NoError(t, err)
+	delete(awsServices.callCounts, "ec2:DescribeSecurityGroups:")
+
+	var wg sync.WaitGroup
+	groups := make([]*ec2types.SecurityGroup, 20)
+	errs := make([]error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			groups[i], errs[i] = c.findSecurityGroup(context.TODO(), fmt.Sprintf("sg-%d", i))
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, errs[i])
+		require.NotNil(t, groups[i])
+		assert.Equal(t, fmt.Sprintf("group-%d", i), aws.StringValue(groups[i].GroupName))
+	}
+	assert.Equal(t, 1, awsServices.callCounts["ec2:DescribeSecurityGroups:"])
+
+	// A missing group fails the batched call, the other lookups are retried individually.
+	var missing, found *ec2types.SecurityGroup
+	var missingErr, foundErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		missing, missingErr = c.findSecurityGroup(context.TODO(), "sg-missing")
+	}()
+	go func() {
+		defer wg.Done()
+		found, foundErr = c.findSecurityGroup(context.TODO(), "sg-1")
+	}()
+	wg.Wait()
+	assert.Error(t, missingErr)
+	assert.Nil(t, missing)
+	require.NoError(t, foundErr)
+	assert.Equal(t, "sg-1", aws.StringValue(found.GroupId))
+}
+
+func TestDeleteLoadBalancerSecurityGroups(t *testing.T) {
+	defer func(backoff wait.Backoff) { securityGroupDeletionBackoff = backoff }(securityGroupDeletionBackoff)
+	securityGroupDeletionBackoff = wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 3}
+
+	newCloud := func(t *testing.T) (*Cloud, *FakeEC2Impl) {
+		awsServices := NewFakeAWSServices(TestClusterID)
+		c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+		require.NoError(t, err)
+		fakeEC2 := awsServices.ec2.(*FakeEC2Impl)
+		fakeEC2.SecurityGroups = []ec2types.SecurityGroup{
+			{GroupId: aws.String("sg-lb")},
+			{GroupId: aws.String("sg-other")},
+		}
+		return c, fakeEC2
+	}
+	dependencyViolation := &smithy.GenericAPIError{Code: "DependencyViolation", Message: "resource sg-lb has a dependent object"}
+
+	t.Run("retries while the security group is in use", func(t *testing.T) {
+		c, fakeEC2 := newCloud(t)
+		fakeEC2.SetErrorOnCall("DeleteSecurityGroup", 1, dependencyViolation)
+
+		err := c.deleteLoadBalancerSecurityGroups(context.TODO(), "myservice", map[string]struct{}{"sg-lb": {}})
+		require.NoError(t, err)
+		assert.Equal(t, 2, fakeEC2.apiCalls["DeleteSecurityGroup"])
+		assert.Len(t, fakeEC2.SecurityGroups, 1)
+		assert.Equal(t, "sg-other", aws.StringValue(fakeEC2.SecurityGroups[0].GroupId))
+	})
+
+	t.Run("times out when the security group stays in use", func(t *testing.T) {
+		c, fakeEC2 := newCloud(t)
+		fakeEC2.SetError("DeleteSecurityGroup", dependencyViolation)
+
+		err := c.deleteLoadBalancerSecurityGroups(context.TODO(), "myservice", map[string]struct{}{"sg-lb": {}})
+		assert.EqualError(t, err, "timed out deleting ELB: myservice. Could not delete security groups sg-lb")
+		assert.Equal(t, 3, fakeEC2.apiCalls["DeleteSecurityGroup"])
+	})
+
+	t.Run("returns other errors immediately", func(t *testing.T) {
+		c, fakeEC2 := newCloud(t)
+		fakeEC2.SetError("DeleteSecurityGroup", &smithy.GenericAPIError{Code: "UnauthorizedOperation"})
+
+		err := c.deleteLoadBalancerSecurityGroups(context.TODO(), "myservice", map[string]struct{}{"sg-lb": {}})
+		assert.ErrorContains(t, err, "UnauthorizedOperation")
+		assert.Equal(t, 1, fakeEC2.apiCalls["DeleteSecurityGroup"])
+	})
+
+	t.Run("detaches the security group from network interfaces", func(t *testing.T) {
+		c, fakeEC2 := newCloud(t)
+		fakeEC2.NetworkInterfaces = []ec2types.NetworkInterface{
+			{
+				NetworkInterfaceId: aws.String("eni-shared"),
+				Groups:             []ec2types.GroupIdentifier{{GroupId: aws.String("sg-lb")}, {GroupId: aws.String("sg-other")}},
+			},
+			{
+				NetworkInterfaceId: aws.String("eni-managed"),
+				RequesterManaged:   aws.Bool(true),
+				Groups:             []ec2types.GroupIdentifier{{GroupId: aws.String("sg-lb")}, {GroupId: aws.String("sg-other")}},
+			},
+			{
+				NetworkInterfaceId: aws.String("eni-only"),
+				Groups:             []ec2types.GroupIdentifier{{GroupId: aws.String("sg-lb")}},
+			},
+		}
+
+		err := c.deleteLoadBalancerSecurityGroups(context.TODO(), "myservice", map[string]struct{}{"sg-lb": {}})
+		require.NoError(t, err)
+		assert.Equal(t, 1, fakeEC2.apiCalls["ModifyNetworkInterfaceAttribute"])
+		assert.Equal(t, []ec2types.GroupIdentifier{{GroupId: aws.String("sg-other")}}, fakeEC2.NetworkInterfaces[0].Groups)
+		assert.Len(t, fakeEC2.NetworkInterfaces[1].Groups, 2)
+		assert.Len(t, fakeEC2.NetworkInterfaces[2].Groups, 1)
+	})
+}
+
+func TestUpdateInstanceSecurityGroupsForLoadBalancerTrafficPolicy(t *testing.T) {
+	awsServices := NewFakeAWSServices(TestClusterID)
+	c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+	require.NoError(t, err)
+	clusterTags := []ec2types.Tag{{Key: aws.String(TagNameKubernetesClusterLegacy), Value: aws.String(TestClusterID)}}
+	fakeEC2 := awsServices.ec2.(*FakeEC2Impl)
+	fakeEC2.SecurityGroups = []ec2types.SecurityGroup{
+		{GroupId: aws.String("sg-lb"), Tags: clusterTags},
+		{GroupId: aws.String("sg-node"), Tags: clusterTags},
+	}
+	instance := makeInstance("i-00000000000000000", "192.168.0.1", "1.2.3.4", "instance-same.ec2.internal", "instance-same.ec2.external", nil, true)
+	instance.SecurityGroups = []ec2types.GroupIdentifier{{GroupId: aws.String("sg-node")}}
+	instances := map[InstanceID]*ec2types.Instance{"i-00000000000000000": &instance}
+	lb := &elb.LoadBalancerDescription{LoadBalancerName: aws.String("lb"), SecurityGroups: []*string{aws.String("sg-lb")}}
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "myservice", UID: "id"},
+		Spec: v1.ServiceSpec{
+			Type:  v1.ServiceTypeLoadBalancer,
+			Ports: []v1.ServicePort{{Port: 80, NodePort: 30080, Protocol: v1.ProtocolTCP}},
+		},
+	}
+	nodeIngress := func() []ec2types.IpPermission {
+		sg, err := c.findSecurityGroup(context.TODO(), "sg-node")
+		require.NoError(t, err)
+		return sg.IpPermissions
+	}
+	fromLB := []ec2types.UserIdGroupPair{{GroupId: aws.String("sg-lb")}}
+	tcpPort := func(port int32) ec2types.IpPermission {
+		return ec2types.IpPermission{IpProtocol: aws.String("tcp"), FromPort: aws.Int32(port), ToPort: aws.Int32(port), UserIdGroupPairs: fromLB}
+	}
+	allTraffic := ec2types.IpPermission{IpProtocol: aws.String("-1"), UserIdGroupPairs: fromLB}
+
+	// Cluster traffic is load balanced through any node port, so all traffic from the load balancer is allowed
+	service.Spec.ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyTypeCluster
+	require.NoError(t, c.updateInstanceSecurityGroupsForLoadBalancer(context.TODO(), lb, instances, service, false))
+	assert.ElementsMatch(t, []ec2types.IpPermission{allTraffic}, nodeIngress())
+
+	// Local traffic only needs the node port and the health check node port
+	service.Spec.ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyTypeLocal
+	service.Spec.HealthCheckNodePort = 32000
+	require.NoError(t, c.updateInstanceSecurityGroupsForLoadBalancer(context.TODO(), lb, instances, service, false))
+	assert.ElementsMatch(t, []ec2types.IpPermission{tcpPort(30080), tcpPort(32000)}, nodeIngress())
+
+	// Reconciling again doesn't change the rules
+	require.NoError(t, c.updateInstanceSecurityGroupsForLoadBalancer(context.TODO(), lb, instances, service, false))
+	assert.ElementsMatch(t, []ec2types.IpPermission{tcpPort(30080), tcpPort(32000)}, nodeIngress())
+
+	service.Spec.ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyTypeCluster
+	service.Spec.HealthCheckNodePort = 0
+	require.NoError(t, c.updateInstanceSecurityGroupsForLoadBalancer(context.TODO(), lb, instances, service, false))
+	assert.ElementsMatch(t, []ec2types.IpPermission{allTraffic}, nodeIngress())
+
+	// The rules are removed when the load balancer is deleted
+	require.NoError(t, c.updateInstanceSecurityGroupsForLoadBalancer(context.TODO(), lb, nil, service, true))
+	assert.Empty(t, nodeIngress())
+}
+
+func TestUpdateInstanceSecurityGroupsForLoadBalancerSharedSecurityGroup(t *testing.T) {
+	awsServices := NewFakeAWSServices(TestClusterID)
+	c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+	require.NoError(t, err)
+	clusterTags := []ec2types.Tag{{Key: aws.String(TagNameKubernetesClusterLegacy), Value: aws.String(TestClusterID)}}
+	fakeEC2 := awsServices.ec2.(*FakeEC2Impl)
+	fakeEC2.SecurityGroups = []ec2types.SecurityGroup{
+		{GroupId: aws.String("sg-shared")},
+		{GroupId: aws.String("sg-node"), Tags: clusterTags},
+	}
+	instance := makeInstance("i-00000000000000000", "192.168.0.1", "1.2.3.4", "instance-same.ec2.internal", "instance-same.ec2.external", nil, true)
+	instance.SecurityGroups = []ec2types.GroupIdentifier{{GroupId: aws.String("sg-node")}}
+	instances := map[InstanceID]*ec2types.Instance{"i-00000000000000000": &instance}
+	lb := &elb.LoadBalancerDescription{LoadBalancerName: aws.String("lb"), SecurityGroups: []*string{aws.String("sg-shared")}}
+	newService := func(name string, policy v1.ServiceExternalTrafficPolicyType, nodePort int32) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        name,
+				UID:         types.UID(name),
+				Annotations: map[string]string{ServiceAnnotationLoadBalancerSecurityGroups: "sg-shared"},
+			},
+			Spec: v1.ServiceSpec{
+				Type:                  v1.ServiceTypeLoadBalancer,
+				ExternalTrafficPolicy: policy,
+				Ports:                 []v1.ServicePort{{Port: 80, NodePort: nodePort, Protocol: v1.ProtocolTCP}},
+			},
+		}
+	}
+	clusterService := newService("cluster", v1.ServiceExternalTrafficPolicyTypeCluster, 30080)
+	localService := newService("local", v1.ServiceExternalTrafficPolicyTypeLocal, 30081)
+	nodeIngress := func() []ec2types.IpPermission {
+		sg, err := c.findSecurityGroup(context.TODO(), "sg-node")
+		require.NoError(t, err)
+		return sg.IpPermissions
+	}
+	fromLB := []ec2types.UserIdGroupPair{{GroupId: aws.String("sg-shared")}}
+	localPort := ec2types.IpPermission{IpProtocol: aws.String("tcp"), FromPort: aws.Int32(30081), ToPort: aws.Int32(30081), UserIdGroupPairs: fromLB}
+	allTraffic := ec2types.IpPermission{IpProtocol: aws.String("-1"), UserIdGroupPairs: fromLB}
+
+	// Each service keeps the rules of the other one
+	for i := 0; i < 2; i++ {
+		require.NoError(t, c.updateInstanceSecurityGroupsForLoadBalancer(context.TODO(), lb, instances, clusterService, false))
+		require.NoError(t, c.updateInstanceSecurityGroupsForLoadBalancer(context.TODO(), lb, instances, localService, false))
+		assert.ElementsMatch(t, []ec2types.IpPermission{allTraffic, localPort}, nodeIngress())
+	}
+	assert.Equal(t, 0, fakeEC2.apiCalls["RevokeSecurityGroupIngress"])
+
+	// Deleting a service only revokes its own rules
+	require.NoError(t, c.updateInstanceSecurityGroupsForLoadBalancer(context.TODO(), lb, nil, localService, true))
+	assert.ElementsMatch(t, []ec2types.IpPermission{allTraffic}, nodeIngress())
+}
+
+func TestUpdateInstanceSecurityGroupsForNLBVPCCidrs(t *testing.T) {
+	awsServices := NewFakeAWSServices(TestClusterID)
+	c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+	require.NoError(t, err)
+	clusterTags := []ec2types.Tag{{Key: aws.String(TagNameKubernetesClusterLegacy), Value: aws.String(TestClusterID)}}
+	fakeEC2 := awsServices.ec2.(*FakeEC2Impl)
+	fakeEC2.SecurityGroups = []ec2types.SecurityGroup{{GroupId: aws.String("sg-node"), Tags: clusterTags}}
+	fakeEC2.Vpcs = []ec2types.Vpc{{
+		VpcId:     aws.String("vpc-mac0"),
+		CidrBlock: aws.String("10.0.0.0/16"),
+		CidrBlockAssociationSet: []ec2types.VpcCidrBlockAssociation{
+			{CidrBlock: aws.String("10.0.0.0/16"), CidrBlockState: &ec2types.VpcCidrBlockState{State: ec2types.VpcCidrBlockStateCodeAssociated}},
+			{CidrBlock: aws.String("100.64.0.0/16"), CidrBlockState: &ec2types.VpcCidrBlockState{State: ec2types.VpcCidrBlockStateCodeAssociated}},
+		},
+	}}
+	instance := makeInstance("i-00000000000000000", "10.0.0.1", "1.2.3.4", "instance-same.ec2.internal", "instance-same.ec2.external", nil, true)
+	instance.SecurityGroups = []ec2types.GroupIdentifier{{GroupId: aws.String("sg-node")}}
+	instances := map[InstanceID]*ec2types.Instance{"i-00000000000000000": &instance}
+	service := &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "myservice", UID: "id"},
+		Spec: v1.ServiceSpec{
+			Type:                  v1.ServiceTypeLoadBalancer,
+			ExternalTrafficPolicy: v1.ServiceExternalTrafficPolicyTypeLocal,
+			HealthCheckNodePort:   32000,
+		},
+	}
+	mappings := []nlbPortMapping{{
+		FrontendPort:      80,
+		TrafficPort:       30080,
+		TrafficProtocol:   string(v1.ProtocolTCP),
+		HealthCheckConfig: healthCheckConfig{Port: "32000", Protocol: elbv2.ProtocolEnumHttp},
+	}}
+	healthCheckCidrs := func() []string {
+		sg, err := c.findSecurityGroup(context.TODO(), "sg-node")
+		require.NoError(t, err)
+		var cidrs []string
+		for _, perm := range sg.IpPermissions {
+			if aws.Int32Value(perm.FromPort) != 32000 {
+				continue
+			}
+			for _, r := range perm.IpRanges {
+				cidrs = append(cidrs, aws.StringValue(r.CidrIp))
+			}
+		}
+		return cidrs
+	}
+
+	// Local traffic allows health checks from the primary and the secondary CIDR of the VPC
+	cidrs, err := c.getNLBHealthCheckCidrs(context.TODO(), service, []string{"10.0.1.0/24"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/16", "10.0.1.0/24", "100.64.0.0/16"}, cidrs)
+	require.NoError(t, c.updateInstanceSecurityGroupsForNLB(context.TODO(), "lb", instances, cidrs, []string{"0.0.0.0/0"}, mappings))
+	assert.Subset(t, healthCheckCidrs(), []string{"10.0.0.0/16", "100.64.0.0/16"})
+
+	// Cluster traffic only allows the subnets of the load balancer
+	service.Spec.ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyTypeCluster
+	cidrs, err = c.getNLBHealthCheckCidrs(context.TODO(), service, []string{"10.0.1.0/24"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.1.0/24"}, cidrs)
+}
+
+func TestUpdateInstanceSecurityGroupsForNLBSharedSecurityGroup(t *testing.T) {
+	awsServices := NewFakeAWSServices(TestClusterID)
+	c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+	require.NoError(t, err)
+	// The node security group is shared with the cluster "other"
+	fakeEC2 := awsServices.ec2.(*FakeEC2Impl)
+	otherRules := []ec2types.IpPermission{
+		{IpProtocol: aws.String("tcp"), FromPort: aws.Int32(31000), ToPort: aws.Int32(31000),
+			IpRanges: []ec2types.IpRange{{CidrIp: aws.String("0.0.0.0/0"), Description: aws.String(NLBClientRuleDescription + "=other-lb")}}},
+		{IpProtocol: aws.String("tcp"), FromPort: aws.Int32(31000), ToPort: aws.Int32(31000),
+			IpRanges: []ec2types.IpRange{{CidrIp: aws.String("10.0.2.0/24"), Description: aws.String(NLBHealthCheckRuleDescription + "=other-lb")}}},
+		{IpProtocol: aws.String("icmp"), FromPort: aws.Int32(3), ToPort: aws.Int32(4),
+			IpRanges: []ec2types.IpRange{{CidrIp: aws.String("0.0.0.0/0"), Description: aws.String(NLBMtuDiscoveryRuleDescription)}}},
+		{IpProtocol: aws.String("-1"), UserIdGroupPairs: []ec2types.UserIdGroupPair{{GroupId: aws.String("sg-other-elb")}}},
+	}
+	fakeEC2.SecurityGroups = []ec2types.SecurityGroup{{
+		GroupId: aws.String("sg-node"),
+		Tags: []ec2types.Tag{
+			{Key: aws.String(TagNameKubernetesClusterPrefix + TestClusterID), Value: aws.String(ResourceLifecycleShared)},
+			{Key: aws.String(TagNameKubernetesClusterPrefix + "other"), Value: aws.String(ResourceLifecycleShared)},
+		},
+		IpPermissions: otherRules,
+	}}
+	instance := makeInstance("i-00000000000000000", "10.0.1.1", "1.2.3.4", "instance-same.ec2.internal", "instance-same.ec2.external", nil, true)
+	instance.SecurityGroups = []ec2types.GroupIdentifier{{GroupId: aws.String("sg-node")}}
+	instances := map[InstanceID]*ec2types.Instance{"i-00000000000000000": &instance}
+	mappings := []nlbPortMapping{{
+		FrontendPort:      80,
+		TrafficPort:       30080,
+		TrafficProtocol:   string(v1.ProtocolTCP),
+		HealthCheckConfig: healthCheckConfig{Port: defaultHealthCheckPort, Protocol: elbv2.ProtocolEnumTcp},
+	}}
+	nodeIngress := func() []ec2types.IpPermission {
+		sg, err := c.findSecurityGroup(context.TODO(), "sg-node")
+		require.NoError(t, err)
+		return NewIPPermissionSet(sg.IpPermissions...).Ungroup().List()
+	}
+	ownRules := []ec2types.IpPermission{
+		{IpProtocol: aws.String("tcp"), FromPort: aws.Int32(30080), ToPort: aws.Int32(30080),
+			IpRanges: []ec2types.IpRange{{CidrIp: aws.String("10.0.1.0/24"), Description: aws.String(NLBHealthCheckRuleDescription + "=lb")}}},
+		{IpProtocol: aws.String("tcp"), FromPort: aws.Int32(30080), ToPort: aws.Int32(30080),
+			IpRanges: []ec2types.IpRange{{CidrIp: aws.String("192.168.0.0/16"), Description: aws.String(NLBClientRuleDescription + "=lb")}}},
+		{IpProtocol: aws.String("icmp"), FromPort: aws.Int32(3), ToPort: aws.Int32(4),
+			IpRanges: []ec2types.IpRange{{CidrIp: aws.String("192.168.0.0/16"), Description: aws.String(NLBMtuDiscoveryRuleDescription)}}},
+	}
+
+	// The rules of this cluster's load balancer are added next to the rules of the other cluster
+	require.NoError(t, c.updateInstanceSecurityGroupsForNLB(context.TODO(), "lb", instances, []string{"10.0.1.0/24"}, []string{"192.168.0.0/16"}, mappings))
+	assert.ElementsMatch(t, append(slices.Clone(otherRules), ownRules...), nodeIngress())
+
+	// Only the rules of this cluster's load balancer are removed once its instances are gone
+	require.NoError(t, c.updateInstanceSecurityGroupsForNLB(context.TODO(), "lb", nil, []string{"10.0.1.0/24"}, []string{"192.168.0.0/16"}, mappings))
+	assert.ElementsMatch(t, otherRules, nodeIngress())
+}
+
+func TestGetTaggedSecurityGroupsExactClusterTag(t *testing.T) {
+	securityGroups := []ec2types.SecurityGroup{
+		{GroupId: aws.String("sg-foo"), Tags: []ec2types.Tag{
+			{Key: aws.String(TagNameKubernetesClusterPrefix + "foo"), Value: aws.String(ResourceLifecycleOwned)},
+			{Key: aws.String("team"), Value: aws.String("a")},
+		}},
+		{GroupId: aws.String("sg-foo-legacy"), Tags: []ec2types.Tag{
+			{Key: aws.String(TagNameKubernetesClusterLegacy), Value: aws.String("foo")},
+		}},
+		{GroupId: aws.String("sg-foo-bar"), Tags: []ec2types.Tag{
+			{Key: aws.String(TagNameKubernetesClusterPrefix + "foo-bar"), Value: aws.String(ResourceLifecycleShared)},
+			{Key: aws.String("team"), Value: aws.String("b")},
+		}},
+		{GroupId: aws.String("sg-foo-bar-legacy"), Tags: []ec2types.Tag{
+			{Key: aws.String(TagNameKubernetesClusterLegacy), Value: aws.String("foo-bar")},
+		}},
+		{GroupId: aws.String("sg-foo-any-value"), Tags: []ec2types.Tag{
+			{Key: aws.String(TagNameKubernetesClusterPrefix + "foo"), Value: aws.String("")},
+		}},
+	}
+	for _, tc := range []struct {
+		clusterID  string
+		filterTags []string
+		expected   []string
+	}{
+		{clusterID: "foo", expected: []string{"sg-foo", "sg-foo-legacy", "sg-foo-any-value"}},
+		{clusterID: "foo-bar", expected: []string{"sg-foo-bar", "sg-foo-bar-legacy"}},
+		{clusterID: "foo", filterTags: []string{"team=a"}, expected: []string{"sg-foo"}},
+		{clusterID: "foo-bar", filterTags: []string{"team=a"}, expected: []string{}},
+	} {
+		t.Run(fmt.Sprintf("%s %v", tc.clusterID, tc.filterTags), func(t *testing.T) {
+			awsServices := NewFakeAWSServices(tc.clusterID)
+			awsServices.ec2.(*FakeEC2Impl).SecurityGroups = securityGroups
+			cfg := config.CloudConfig{}
+			cfg.Global.SecurityGroupFilterTags = tc.filterTags
+			c, err := newAWSCloud(cfg, awsServices)
+			require.NoError(t, err)
+
+			groups, err := c.getTaggedSecurityGroups(context.TODO())
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.expected, lo.Keys(groups))
+		})
+	}
+
+	cfg := config.CloudConfig{}
+	cfg.Global.SecurityGroupFilterTags = []string{"team"}
+	_, err := newAWSCloud(cfg, NewFakeAWSServices("foo"))
+	assert.Error(t, err)
+}
+
+func TestResourceTags(t *testing.T) {
+	awsServices := NewFakeAWSServices(TestClusterID)
+	cfg := config.CloudConfig{}
+	cfg.Global.KubernetesClusterID = TestClusterID
+	cfg.Global.ResourceTags = []string{"cost-center=1234", "team=a"}
+	c, err := newAWSCloud(cfg, awsServices)
+	require.NoError(t, err)
+	fakeEC2 := awsServices.ec2.(*FakeEC2Impl)
+	tagMap := func(tags []ec2types.Tag) map[string]string {
+		m := map[string]string{}
+		for _, tag := range tags {
+			m[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+		return m
+	}
+
+	// The configured tags are merged with the cluster tags and the additional tags, which take precedence
+	groupID, err := c.ensureSecurityGroup(context.TODO(), "k8s-elb-tags", "tags", map[string]string{"team": "b"})
+	require.NoError(t, err)
+	require.Len(t, fakeEC2.SecurityGroups, 1)
+	assert.Equal(t, map[string]string{
+		"cost-center":             "1234",
+		"team":                    "b",
+		c.tagging.clusterTagKey(): ResourceLifecycleOwned,
+	}, tagMap(fakeEC2.SecurityGroups[0].Tags))
+
+	// Missing configured tags are added, tags changed or added out of band are kept
+	fakeEC2.SecurityGroups[0].Tags = []ec2types.Tag{
+		{Key: aws.String(c.tagging.clusterTagKey()), Value: aws.String(ResourceLifecycleOwned)},
+		{Key: aws.String("team"), Value: aws.String("c")},
+		{Key: aws.String("owner"), Value: aws.String("someone")},
+	}
+	_, err = c.ensureSecurityGroup(context.TODO(), "k8s-elb-tags", "tags", nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"cost-center":             "1234",
+		"team":                    "c",
+		"owner":                   "someone",
+		c.tagging.clusterTagKey(): ResourceLifecycleOwned,
+	}, tagMap(fakeEC2.SecurityGroups[0].Tags))
+	assert.Equal(t, groupID, aws.StringValue(fakeEC2.SecurityGroups[0].GroupId))
+
+	// A different cluster tag is still an error
+	fakeEC2.SecurityGroups[0].Tags[0].Value = aws.String("other")
+	_, err = c.ensureSecurityGroup(context.TODO(), "k8s-elb-tags", "tags", nil)
+	assert.Error(t, err)
+
+	cfg.Global.ResourceTags = []string{"cost-center"}
+	_, err = newAWSCloud(cfg, NewFakeAWSServices(TestClusterID))
+	assert.Error(t, err)
+}
+
 func TestInstanceIDIndexFunc(t *testing.T) {
 	type args struct {
 		obj interface{}
@@ -0,0 +1,109 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/smithy-go"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/klog/v2"
+)
+
+// volumeModificationBackoff is used while waiting for a volume modification to make the new size available
+var volumeModificationBackoff = wait.Backoff{
+	Duration: 1 * time.Second,
+	Factor:   1.8,
+	Jitter:   0.1,
+	Steps:    10,
+	Cap:      30 * time.Second,
+}
+
+// ResizeVolume modifies the size of an EBS volume without detaching it and waits until the new size is available,
+// which is when the modification is optimizing or completed. If a modification to the same size is already in
+// progress, it waits for that modification instead.
+func (c *Cloud) ResizeVolume(ctx context.Context, volumeID string, sizeGiB int32) (*ec2types.VolumeModification, error) {
+	output, err := c.ec2.ModifyVolume(ctx, &ec2.ModifyVolumeInput{
+		VolumeId: aws.String(volumeID),
+		Size:     aws.Int32(sizeGiB),
+	})
+	var modification *ec2types.VolumeModification
+	if err == nil {
+		modification = output.VolumeModification
+	} else {
+		var ae smithy.APIError
+		if !errors.As(err, &ae) || ae.ErrorCode() != "IncorrectModificationState" {
+			return nil, fmt.Errorf("error modifying volume %s: %q", volumeID, err)
+		}
+		modification, err = c.describeVolumeModification(ctx, volumeID)
+		if err != nil {
+			return nil, err
+		}
+		if modification == nil {
+			return nil, fmt.Errorf("error modifying volume %s: %q", volumeID, ae)
+		}
+		if aws.Int32Value(modification.TargetSize) != sizeGiB {
+			return nil, fmt.Errorf("volume %s is already being modified to %d GiB", volumeID, aws.Int32Value(modification.TargetSize))
+		}
+		klog.V(2).Infof("Volume %s is already being modified to %d GiB, waiting for the existing modification", volumeID, sizeGiB)
+	}
+
+	err = wait.ExponentialBackoffWithContext(ctx, volumeModificationBackoff, func(ctx context.Context) (bool, error) {
+		if modification != nil {
+			switch modification.ModificationState {
+			case ec2types.VolumeModificationStateOptimizing, ec2types.VolumeModificationStateCompleted:
+				return true, nil
+			case ec2types.VolumeModificationStateFailed:
+				return false, fmt.Errorf("modification of volume %s failed: %s", volumeID, aws.StringValue(modification.StatusMessage))
+			}
+		}
+		var err error
+		modification, err = c.describeVolumeModification(ctx, volumeID)
+		return false, err
+	})
+	if wait.Interrupted(err) {
+		return nil, fmt.Errorf("timed out waiting for modification of volume %s", volumeID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return modification, nil
+}
+
+// describeVolumeModification returns the latest modification of a volume, or nil if it was never modified
+func (c *Cloud) describeVolumeModification(ctx context.Context, volumeID string) (*ec2types.VolumeModification, error) {
+	modifications, err := c.ec2.DescribeVolumesModifications(ctx, &ec2.DescribeVolumesModificationsInput{
+		VolumeIds: []string{volumeID},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error describing modifications of volume %s: %q", volumeID, err)
+	}
+	var latest *ec2types.VolumeModification
+	for i := range modifications {
+		modification := &modifications[i]
+		if latest == nil || aws.TimeValue(modification.StartTime).After(aws.TimeValue(latest.StartTime)) {
+			latest = modification
+		}
+	}
+	return latest, nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"k8s.io/cloud-provider-aws/pkg/providers/v1/config"
+)
+
+const testVolumeID = "vol-0123456789"
+
+func TestResizeVolume(t *testing.T) {
+	defer func(backoff wait.Backoff) { volumeModificationBackoff = backoff }(volumeModificationBackoff)
+	volumeModificationBackoff = wait.Backoff{Duration: time.Millisecond, Factor: 1, Steps: 5}
+
+	newCloud := func(t *testing.T, modification *ec2types.VolumeModification) (*Cloud, *FakeEC2Impl) {
+		awsServices := NewFakeAWSServices(TestClusterID)
+		c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+		require.NoError(t, err)
+		fakeEC2 := awsServices.ec2.(*FakeEC2Impl)
+		if modification != nil {
+			fakeEC2.VolumeModifications = map[string]*ec2types.VolumeModification{testVolumeID: modification}
+		}
+		return c, fakeEC2
+	}
+
+	t.Run("waits until the modification is optimizing", func(t *testing.T) {
+		c, fakeEC2 := newCloud(t, nil)
+
+		modification, err := c.ResizeVolume(context.TODO(), testVolumeID, 20)
+		require.NoError(t, err)
+		assert.Equal(t, ec2types.VolumeModificationStateOptimizing, modification.ModificationState)
+		assert.Equal(t, int32(20), aws.Int32Value(modification.TargetSize))
+		assert.Equal(t, 1, fakeEC2.apiCalls["ModifyVolume"])
+		// modifying is described once before the modification is optimizing
+		assert.Equal(t, 2, fakeEC2.apiCalls["DescribeVolumesModifications"])
+	})
+
+	t.Run("returns the modification to the same size in progress", func(t *testing.T) {
+		c, fakeEC2 := newCloud(t, &ec2types.VolumeModification{
+			VolumeId:          aws.String(testVolumeID),
+			TargetSize:        aws.Int32(20),
+			ModificationState: ec2types.VolumeModificationStateModifying,
+		})
+
+		modification, err := c.ResizeVolume(context.TODO(), testVolumeID, 20)
+		require.NoError(t, err)
+		assert.Equal(t, ec2types.VolumeModificationStateOptimizing, modification.ModificationState)
+		assert.Equal(t, int32(20), aws.Int32Value(modification.TargetSize))
+		assert.Equal(t, 1, fakeEC2.apiCalls["ModifyVolume"])
+	})
+
+	t.Run("fails while a modification to another size is in progress", func(t *testing.T) {
+		c, _ := newCloud(t, &ec2types.VolumeModification{
+			VolumeId:          aws.String(testVolumeID),
+			TargetSize:        aws.Int32(30),
+			ModificationState: ec2types.VolumeModificationStateOptimizing,
+		})
+
+		_, err := c.ResizeVolume(context.TODO(), testVolumeID, 20)
+		assert.ErrorContains(t, err, "already being modified to 30 GiB")
+	})
+
+	t.Run("modifies a volume again after the previous modification completed", func(t *testing.T) {
+		c, _ := newCloud(t, &ec2types.VolumeModification{
+			VolumeId:          aws.String(testVolumeID),
+			TargetSize:        aws.Int32(10),
+			ModificationState: ec2types.VolumeModificationStateCompleted,
+		})
+
+		modification, err := c.ResizeVolume(context.TODO(), testVolumeID, 20)
+		require.NoError(t, err)
+		assert.Equal(t, int32(20), aws.Int32Value(modification.TargetSize))
+	})
+
+	t.Run("fails when the modification fails", func(t *testing.T) {
+		c, fakeEC2 := newCloud(t, nil)
+
+		_, err := c.ResizeVolume(context.TODO(), testVolumeID, 20000)
+		assert.ErrorContains(t, err, "exceeds the maximum")
+		assert.Equal(t, ec2types.VolumeModificationStateFailed, fakeEC2.VolumeModifications[testVolumeID].ModificationState)
+	})
+
+	t.Run("fails when the volume can't be modified", func(t *testing.T) {
+		c, fakeEC2 := newCloud(t, nil)
+		fakeEC2.SetError("ModifyVolume", errors.New("InvalidVolume.NotFound"))
+
+		_, err := c.ResizeVolume(context.TODO(), testVolumeID, 20)
+		assert.ErrorContains(t, err, "InvalidVolume.NotFound")
+		assert.Zero(t, fakeEC2.apiCalls["DescribeVolumesModifications"])
+	})
+}
@@ -18,17 +18,25 @@ package batcher_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/Pallinder/go-randomdata"
 	aws "k8s.io/cloud-provider-aws/pkg/providers/v1"
 	"k8s.io/cloud-provider-aws/pkg/providers/v1/batcher"
+	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	dto "github.com/prometheus/client_model/go"
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"k8s.io/component-base/metrics/legacyregistry"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -91,6 +99,1486 @@ var _ = Describe("Batcher", func() {
 			Eventually(fakeBatcher.activeBatches.Load).Should(BeNumerically("==", 300))
 			Eventually(fakeBatcher.completedBatches.Load, time.Second*3).Should(BeNumerically("==", 300))
 		})
+		It("should converge to a lowered worker limit while batches are in flight", func() {
+			// This batcher will get canceled at the end of the test run
+			fakeBatcher = NewFakeBatcher(cancelCtx, time.Second, 300)
+
+			// Generate enough items to keep the workers saturated after the limit is lowered
+			for i := 0; i < 1200; i++ {
+				go func() {
+					fakeBatcher.batcher.Add(cancelCtx, lo.ToPtr(randomName()))
+				}()
+			}
+
+			Eventually(fakeBatcher.activeBatches.Load).Should(BeNumerically("==", 300))
+			fakeBatcher.batcher.SetMaxRequestWorkers(50)
+			Eventually(fakeBatcher.activeBatches.Load, time.Second*3).Should(BeNumerically("<=", 50))
+			Consistently(fakeBatcher.activeBatches.Load, time.Second*2).Should(BeNumerically("<=", 50))
+		})
+		DescribeTable("should keep one request worker when the limit is set below one",
+			func(n int, adaptive bool) {
+				options := batcher.Options[string, string]{
+					Name:              "min-workers",
+					IdleTimeout:       10 * time.Millisecond,
+					MaxTimeout:        100 * time.Millisecond,
+					MaxRequestWorkers: 4,
+					RequestHasher:     batcher.DefaultHasher[string],
+					BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+						return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+							return batcher.Result[string]{Output: i}
+						})
+					},
+				}
+				if adaptive {
+					options.AdaptiveConcurrency = &batcher.AdaptiveConcurrencyPolicy{ErrorRateThreshold: 0.5}
+				}
+				b := batcher.NewBatcher(cancelCtx, options)
+				b.SetMaxRequestWorkers(n)
+				Expect(b.RequestWorkers()).To(Equal(1))
+
+				ctx, cancel := context.WithTimeout(cancelCtx, time.Second)
+				defer cancel()
+				Expect(b.Add(ctx, lo.ToPtr("item")).Err).ToNot(HaveOccurred())
+			},
+			Entry("zero", 0, false),
+			Entry("negative", -1, false),
+			Entry("zero with adaptive concurrency", 0, true),
+			Entry("negative with adaptive concurrency", -1, true),
+		)
+	})
+	Context("RequestHasher", func() {
+		echo := func(ctx context.Context, items []*string) []batcher.Result[string] {
+			return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+				return batcher.Result[string]{Output: lo.ToPtr(*i)}
+			})
+		}
+		It("should default to DefaultHasher for comparable inputs", func() {
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "default-hasher",
+				IdleTimeout:   10 * time.Millisecond,
+				MaxTimeout:    100 * time.Millisecond,
+				BatchExecutor: echo,
+			})
+			result := b.Add(cancelCtx, lo.ToPtr("item"))
+			Expect(result.Err).ToNot(HaveOccurred())
+			Expect(*result.Output).To(Equal("item"))
+		})
+		It("should use an explicit hasher", func() {
+			var hashed atomic.Int64
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:        "explicit-hasher",
+				IdleTimeout: 10 * time.Millisecond,
+				MaxTimeout:  100 * time.Millisecond,
+				RequestHasher: func(ctx context.Context, input *string) uint64 {
+					hashed.Add(1)
+					return 0
+				},
+				BatchExecutor: echo,
+			})
+			result := b.Add(cancelCtx, lo.ToPtr("item"))
+			Expect(result.Err).ToNot(HaveOccurred())
+			Expect(hashed.Load()).To(BeNumerically("==", 1))
+		})
+		It("should panic at construction when the input isn't comparable and has no hasher", func() {
+			Expect(func() {
+				batcher.NewBatcher(cancelCtx, batcher.Options[[]string, string]{
+					Name: "not-comparable",
+				})
+			}).To(PanicWith(ContainSubstring("RequestHasher is required")))
+		})
+	})
+	Context("Priority", func() {
+		It("should execute a high priority item ahead of a low priority backlog", func() {
+			var mu sync.Mutex
+			var completed []string
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:              "priority",
+				IdleTimeout:       10 * time.Millisecond,
+				MaxTimeout:        100 * time.Millisecond,
+				MaxRequestWorkers: 1,
+				RequestHasher:     batcher.DefaultHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					time.Sleep(100 * time.Millisecond)
+					mu.Lock()
+					defer mu.Unlock()
+					completed = append(completed, lo.Map(items, func(i *string, _ int) string { return *i })...)
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: lo.ToPtr(*i)}
+					})
+				},
+			})
+
+			// Saturate the single worker with a backlog of low priority batches
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					b.Add(cancelCtx, lo.ToPtr(randomName()))
+				}()
+			}
+			time.Sleep(50 * time.Millisecond)
+
+			result := b.AddWithPriority(cancelCtx, lo.ToPtr("high"), 10)
+			Expect(result.Err).ToNot(HaveOccurred())
+			wg.Wait()
+
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(completed).To(HaveLen(11))
+			Expect(lo.IndexOf(completed, "high")).To(BeNumerically("<=", 2))
+		})
+	})
+	Context("AddBatch", func() {
+		It("should return results in input order", func() {
+			var executions atomic.Int64
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "add-batch",
+				IdleTimeout:   100 * time.Millisecond,
+				MaxTimeout:    time.Second,
+				RequestHasher: batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					executions.Add(1)
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: lo.ToPtr(*i)}
+					})
+				},
+			})
+
+			names := lo.Times(20, func(_ int) string { return randomName() })
+			results := b.AddBatch(cancelCtx, lo.ToSlicePtr(names))
+			Expect(results).To(HaveLen(20))
+			for i, result := range results {
+				Expect(result.Err).ToNot(HaveOccurred())
+				Expect(*result.Output).To(Equal(names[i]))
+			}
+			Expect(executions.Load()).To(BeNumerically("==", 1))
+		})
+		It("should key results by input and add duplicate inputs once", func() {
+			var executed atomic.Int64
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "add-batch-keyed",
+				IdleTimeout:   100 * time.Millisecond,
+				MaxTimeout:    time.Second,
+				RequestHasher: batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					executed.Add(int64(len(items)))
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: lo.ToPtr(strings.ToUpper(*i))}
+					})
+				},
+			})
+
+			results := batcher.AddBatchKeyed(cancelCtx, b, []*string{lo.ToPtr("a"), lo.ToPtr("b"), lo.ToPtr("a"), nil, lo.ToPtr("c")})
+			Expect(results).To(HaveLen(3))
+			for key, result := range results {
+				Expect(result.Err).ToNot(HaveOccurred())
+				Expect(*result.Output).To(Equal(strings.ToUpper(key)))
+			}
+			Expect(executed.Load()).To(BeNumerically("==", 3))
+		})
+	})
+	Context("Flush", func() {
+		It("should execute buffered items without waiting for the batch timeouts", func() {
+			var executed atomic.Int64
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "flush",
+				IdleTimeout:   time.Hour,
+				MaxTimeout:    time.Hour,
+				RequestHasher: batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					executed.Add(int64(len(items)))
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			})
+
+			var wg sync.WaitGroup
+			var completed atomic.Int64
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer GinkgoRecover()
+					result := b.Add(cancelCtx, lo.ToPtr(randomName()))
+					Expect(result.Err).ToNot(HaveOccurred())
+					completed.Add(1)
+				}()
+			}
+			// Wait for all the items to be buffered before flushing
+			Eventually(func() int64 {
+				b.Flush(cancelCtx)
+				return executed.Load()
+			}).Should(BeNumerically("==", 10))
+			wg.Wait()
+			Expect(completed.Load()).To(BeNumerically("==", 10))
+		})
+	})
+	Context("Len", func() {
+		It("should count buffered items until they are dispatched", func() {
+			var calls, executed atomic.Int64
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "len",
+				IdleTimeout:   time.Hour,
+				MaxTimeout:    time.Hour,
+				RequestHasher: batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					calls.Add(1)
+					executed.Add(int64(len(items)))
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			})
+			Expect(b.Len()).To(BeZero())
+
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer GinkgoRecover()
+					Expect(b.Add(cancelCtx, lo.ToPtr(randomName())).Err).ToNot(HaveOccurred())
+				}()
+			}
+			// The items are coalesced rather than dispatched one by one
+			Eventually(b.Len).Should(Equal(10))
+			Expect(executed.Load()).To(BeZero())
+
+			b.Flush(cancelCtx)
+			wg.Wait()
+			Expect(b.Len()).To(BeZero())
+			Expect(calls.Load()).To(BeNumerically("==", 1))
+			Expect(executed.Load()).To(BeNumerically("==", 10))
+		})
+	})
+	Context("AddWithOptions", func() {
+		It("should batch callers with different timeouts separately", func() {
+			var mu sync.Mutex
+			var batches [][]string
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "add_with_options",
+				IdleTimeout:   time.Hour,
+				MaxTimeout:    time.Hour,
+				RequestHasher: batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					mu.Lock()
+					batches = append(batches, lo.FromSlicePtr(items))
+					mu.Unlock()
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			})
+
+			var slow sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				slow.Add(1)
+				go func() {
+					defer slow.Done()
+					defer GinkgoRecover()
+					Expect(b.Add(cancelCtx, lo.ToPtr("slow")).Err).ToNot(HaveOccurred())
+				}()
+			}
+			Eventually(b.Len).Should(Equal(5))
+
+			// The fast callers complete within their own window while the slow callers are still buffered
+			var fast sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				fast.Add(1)
+				go func() {
+					defer fast.Done()
+					defer GinkgoRecover()
+					result := b.AddWithOptions(cancelCtx, lo.ToPtr("fast"), batcher.AddOptions{
+						IdleTimeout: 100 * time.Millisecond,
+						MaxTimeout:  time.Second,
+					})
+					Expect(result.Err).ToNot(HaveOccurred())
+				}()
+			}
+			fast.Wait()
+			Expect(b.Len()).To(Equal(5))
+
+			b.Flush(cancelCtx)
+			slow.Wait()
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(batches).ToNot(BeEmpty())
+			for _, batch := range batches {
+				Expect(lo.Uniq(batch)).To(HaveLen(1))
+			}
+			Expect(lo.Sum(lo.Map(batches, func(batch []string, _ int) int { return len(batch) }))).To(Equal(10))
+		})
+		It("should stop the batching loops of windows that have no more requests", func() {
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "add_with_options_idle",
+				IdleTimeout:   time.Hour,
+				MaxTimeout:    time.Hour,
+				RequestHasher: batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			})
+			goroutines := runtime.NumGoroutine()
+
+			// Every distinct window starts its own batching loop
+			var wg sync.WaitGroup
+			for i := 1; i <= 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer GinkgoRecover()
+					result := b.AddWithOptions(cancelCtx, lo.ToPtr(randomName()), batcher.AddOptions{
+						IdleTimeout: time.Duration(i) * time.Millisecond,
+						MaxTimeout:  100*time.Millisecond + time.Duration(i)*time.Millisecond,
+					})
+					Expect(result.Err).ToNot(HaveOccurred())
+				}()
+			}
+			wg.Wait()
+			Expect(runtime.NumGoroutine()).To(BeNumerically(">", goroutines+25))
+			Eventually(runtime.NumGoroutine, time.Second).Should(BeNumerically("<=", goroutines))
+
+			// A window whose loop stopped starts a new one for its next request
+			result := b.AddWithOptions(cancelCtx, lo.ToPtr(randomName()), batcher.AddOptions{
+				IdleTimeout: time.Millisecond,
+				MaxTimeout:  101 * time.Millisecond,
+			})
+			Expect(result.Err).ToNot(HaveOccurred())
+		})
+	})
+	Context("GetOrCreate", func() {
+		options := func(name string) batcher.Options[string, string] {
+			return batcher.Options[string, string]{
+				Name:          name,
+				IdleTimeout:   10 * time.Millisecond,
+				MaxTimeout:    100 * time.Millisecond,
+				RequestHasher: batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			}
+		}
+		getOrCreateConcurrently := func(names []string) []*batcher.Batcher[string, string] {
+			batchers := make([]*batcher.Batcher[string, string], len(names))
+			var wg sync.WaitGroup
+			for i, name := range names {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					batchers[i] = batcher.GetOrCreate(cancelCtx, options(name))
+				}()
+			}
+			wg.Wait()
+			return batchers
+		}
+
+		It("should return the same batcher to concurrent callers with the same name", func() {
+			name := randomName()
+			batchers := getOrCreateConcurrently(lo.Times(50, func(_ int) string { return name }))
+			Expect(lo.Uniq(batchers)).To(HaveLen(1))
+			Expect(batchers[0].Add(cancelCtx, lo.ToPtr("item")).Err).ToNot(HaveOccurred())
+		})
+		It("should return a batcher per name to concurrent callers with different names", func() {
+			names := lo.Times(50, func(i int) string { return fmt.Sprintf("%s-%d", randomName(), i) })
+			batchers := getOrCreateConcurrently(append(names, names...))
+			Expect(lo.Uniq(batchers)).To(HaveLen(50))
+			for i := range names {
+				Expect(batchers[i]).To(BeIdenticalTo(batchers[i+len(names)]))
+			}
+		})
+		It("should not share a batcher between types with the same name", func() {
+			name := randomName()
+			b := batcher.GetOrCreate(cancelCtx, options(name))
+			other := batcher.GetOrCreate(cancelCtx, batcher.Options[int, string]{
+				Name:          name,
+				IdleTimeout:   10 * time.Millisecond,
+				MaxTimeout:    100 * time.Millisecond,
+				BatchExecutor: func(ctx context.Context, items []*int) []batcher.Result[string] { return nil },
+			})
+			Expect(other).ToNot(BeNil())
+			Expect(batcher.GetOrCreate(cancelCtx, options(name))).To(BeIdenticalTo(b))
+		})
+		It("should replace a closed batcher", func() {
+			name := randomName()
+			b := batcher.GetOrCreate(cancelCtx, options(name))
+			b.Close()
+			Expect(batcher.GetOrCreate(cancelCtx, options(name))).ToNot(BeIdenticalTo(b))
+		})
+	})
+	Context("Close", func() {
+		It("should execute buffered items and deliver their results before returning", func() {
+			var executed atomic.Int64
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "close",
+				IdleTimeout:   time.Hour,
+				MaxTimeout:    time.Minute,
+				RequestHasher: batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					executed.Add(int64(len(items)))
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			})
+
+			var wg sync.WaitGroup
+			results := make([]batcher.Result[string], 10)
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					results[i] = b.Add(cancelCtx, lo.ToPtr(randomName()))
+				}(i)
+			}
+			// Wait for all the items to be buffered before closing
+			Eventually(func() float64 { return queuedItems("close") }).Should(BeNumerically("==", 10))
+			b.Close()
+
+			Expect(executed.Load()).To(BeNumerically("==", 10))
+			wg.Wait()
+			for _, result := range results {
+				Expect(result.Err).ToNot(HaveOccurred())
+				Expect(result.Output).ToNot(BeNil())
+			}
+			result := b.Add(cancelCtx, lo.ToPtr(randomName()))
+			Expect(result.Err).To(MatchError(batcher.ErrBatcherClosed))
+			// Closing again is a no-op
+			b.Close()
+		})
+		It("should not block batches added while closing", func() {
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "close-add-batch",
+				IdleTimeout:   time.Hour,
+				MaxTimeout:    time.Hour,
+				RequestHasher: batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			})
+
+			done := make(chan []batcher.Result[string], 10)
+			for i := 0; i < 10; i++ {
+				go func() {
+					done <- b.AddBatch(context.Background(), lo.Times(1000, func(_ int) *string { return lo.ToPtr(randomName()) }))
+				}()
+			}
+			Eventually(func() float64 { return queuedItems("close-add-batch") }).Should(BeNumerically(">", 0))
+			b.Close()
+
+			for i := 0; i < 10; i++ {
+				var results []batcher.Result[string]
+				Eventually(done).Should(Receive(&results))
+				for _, result := range results {
+					if result.Err != nil {
+						Expect(result.Err).To(MatchError(batcher.ErrBatcherClosed))
+					}
+				}
+			}
+		})
+		It("should cancel batches that are still executing after MaxTimeout", func() {
+			var started atomic.Bool
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "close-timeout",
+				IdleTimeout:   10 * time.Millisecond,
+				MaxTimeout:    100 * time.Millisecond,
+				RequestHasher: batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					started.Store(true)
+					<-ctx.Done()
+					return lo.Map(items, func(_ *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Err: ctx.Err()}
+					})
+				},
+			})
+
+			done := make(chan batcher.Result[string])
+			go func() {
+				done <- b.Add(cancelCtx, lo.ToPtr(randomName()))
+			}()
+			Eventually(started.Load).Should(BeTrue())
+			b.Close()
+
+			var result batcher.Result[string]
+			Eventually(done).Should(Receive(&result))
+			Expect(result.Err).To(MatchError(context.Canceled))
+		})
+	})
+	Context("MaxItemsPerBatch", func() {
+		It("should split a batch into executor calls of at most MaxItemsPerBatch items", func() {
+			var maxBatchSize atomic.Int64
+			var executed atomic.Int64
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:             "split",
+				IdleTimeout:      time.Hour,
+				MaxTimeout:       time.Hour,
+				MaxItemsPerBatch: 3,
+				RequestHasher:    batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					executed.Add(int64(len(items)))
+					if int64(len(items)) > maxBatchSize.Load() {
+						maxBatchSize.Store(int64(len(items)))
+					}
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: lo.ToPtr(*i)}
+					})
+				},
+			})
+
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer GinkgoRecover()
+					name := randomName()
+					result := b.Add(cancelCtx, lo.ToPtr(name))
+					Expect(result.Err).ToNot(HaveOccurred())
+					Expect(*result.Output).To(Equal(name))
+				}()
+			}
+			Eventually(func() int64 {
+				b.Flush(cancelCtx)
+				return executed.Load()
+			}).Should(BeNumerically("==", 10))
+			wg.Wait()
+			Expect(maxBatchSize.Load()).To(BeNumerically("==", 3))
+		})
+	})
+	Context("MaxBufferedItems", func() {
+		It("should dispatch the items of a key once MaxBufferedItems are buffered, before the idle timeout", func() {
+			var mu sync.Mutex
+			var batchSizes []int
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:             "max-buffered",
+				IdleTimeout:      time.Hour,
+				MaxTimeout:       time.Hour,
+				MaxBufferedItems: 5,
+				RequestHasher:    batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					mu.Lock()
+					batchSizes = append(batchSizes, len(items))
+					mu.Unlock()
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			})
+
+			var completed atomic.Int64
+			var wg sync.WaitGroup
+			for i := 0; i < 12; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer GinkgoRecover()
+					Expect(b.Add(cancelCtx, lo.ToPtr(randomName())).Err).ToNot(HaveOccurred())
+					completed.Add(1)
+				}()
+			}
+			// Two batches are dispatched at the threshold, the remaining items wait for their batching window
+			Eventually(completed.Load).Should(BeNumerically("==", 10))
+			Consistently(completed.Load, 100*time.Millisecond).Should(BeNumerically("==", 10))
+			Expect(b.Len()).To(Equal(2))
+			mu.Lock()
+			Expect(batchSizes).To(Equal([]int{5, 5}))
+			mu.Unlock()
+
+			b.Flush(cancelCtx)
+			wg.Wait()
+			Expect(completed.Load()).To(BeNumerically("==", 12))
+		})
+	})
+	Context("RateLimiter", func() {
+		It("should hold back the dispatch of batches until the rate limiter allows a call", func() {
+			limiter := &blockingRateLimiter{available: make(chan struct{})}
+			var executed atomic.Int64
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "rate-limited",
+				IdleTimeout:   10 * time.Millisecond,
+				MaxTimeout:    100 * time.Millisecond,
+				RequestHasher: batcher.OneBucketHasher[string],
+				RateLimiter:   limiter,
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					executed.Add(1)
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			})
+
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer GinkgoRecover()
+					Expect(b.Add(cancelCtx, lo.ToPtr(randomName())).Err).ToNot(HaveOccurred())
+				}()
+			}
+			Eventually(b.Len).Should(Equal(5))
+			Consistently(executed.Load, 200*time.Millisecond).Should(BeNumerically("==", 0))
+
+			close(limiter.available)
+			wg.Wait()
+			Expect(executed.Load()).To(BeNumerically("==", 1))
+		})
+	})
+	Context("ShardItems", func() {
+		It("should shard a dominant key over workers that execute its batches concurrently", func() {
+			var mu sync.Mutex
+			var executing, maxExecuting int
+			var batchSizes []int
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:        "sharded",
+				IdleTimeout: 100 * time.Millisecond,
+				MaxTimeout:  time.Second,
+				ShardItems:  10,
+				RequestHasher: func(_ context.Context, item *string) uint64 {
+					return lo.Ternary[uint64](strings.HasPrefix(*item, "hot"), 1, 2)
+				},
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					mu.Lock()
+					executing++
+					maxExecuting = max(maxExecuting, executing)
+					batchSizes = append(batchSizes, len(items))
+					mu.Unlock()
+					time.Sleep(100 * time.Millisecond)
+					mu.Lock()
+					executing--
+					mu.Unlock()
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			})
+
+			var wg sync.WaitGroup
+			for i := 0; i < 62; i++ {
+				item := lo.Ternary(i < 60, "hot-", "cold-") + randomName()
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer GinkgoRecover()
+					result := b.Add(cancelCtx, &item)
+					Expect(result.Err).ToNot(HaveOccurred())
+					Expect(*result.Output).To(Equal(item))
+				}()
+			}
+			wg.Wait()
+
+			mu.Lock()
+			defer mu.Unlock()
+			// The hot key is sharded over several batches, that are executed at the same time
+			Expect(lo.Sum(batchSizes)).To(Equal(62))
+			Expect(len(batchSizes)).To(BeNumerically(">", 2))
+			Expect(maxExecuting).To(BeNumerically(">", 2))
+		})
+	})
+	Context("Deduplication", func() {
+		It("should collapse identical items into a single executor input", func() {
+			var executed atomic.Int64
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:                "dedupe",
+				IdleTimeout:         time.Hour,
+				MaxTimeout:          time.Hour,
+				RequestHasher:       batcher.OneBucketHasher[string],
+				RequestDeduplicator: batcher.DefaultHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					executed.Add(int64(len(items)))
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: lo.ToPtr(*i)}
+					})
+				},
+			})
+
+			name := randomName()
+			var wg sync.WaitGroup
+			var completed atomic.Int64
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer GinkgoRecover()
+					result := b.Add(cancelCtx, lo.ToPtr(name))
+					Expect(result.Err).ToNot(HaveOccurred())
+					Expect(*result.Output).To(Equal(name))
+					completed.Add(1)
+				}()
+			}
+			// Give every Add time to be buffered before flushing them as one batch
+			time.Sleep(time.Second)
+			b.Flush(cancelCtx)
+			wg.Wait()
+			Expect(completed.Load()).To(BeNumerically("==", 50))
+			Expect(executed.Load()).To(BeNumerically("==", 1))
+		})
+		It("should join items added while an equal item is in flight", func() {
+			var executed atomic.Int64
+			started := make(chan struct{}, 10)
+			release := make(chan struct{})
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:                "dedupe_in_flight",
+				IdleTimeout:         10 * time.Millisecond,
+				MaxTimeout:          100 * time.Millisecond,
+				RequestHasher:       batcher.OneBucketHasher[string],
+				DeduplicateInFlight: true,
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					executed.Add(int64(len(items)))
+					started <- struct{}{}
+					<-release
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: lo.ToPtr(*i + "-" + strconv.FormatInt(executed.Load(), 10))}
+					})
+				},
+			})
+
+			name := randomName()
+			results := make(chan batcher.Result[string], 3)
+			add := func(ctx context.Context) {
+				go func() { results <- b.Add(ctx, lo.ToPtr(name)) }()
+			}
+			// Added before the batch executes, the leader gives up while the batch executes
+			leaderCtx, cancelLeader := context.WithCancel(cancelCtx)
+			add(leaderCtx)
+			add(cancelCtx)
+			Eventually(started).Should(Receive())
+			// Added while the batch executes
+			add(cancelCtx)
+			Consistently(started, 100*time.Millisecond).ShouldNot(Receive())
+			cancelLeader()
+			Expect((<-results).Err).To(MatchError(context.Canceled))
+			close(release)
+			for i := 0; i < 2; i++ {
+				result := <-results
+				Expect(result.Err).ToNot(HaveOccurred())
+				Expect(*result.Output).To(Equal(name + "-1"))
+			}
+			Expect(executed.Load()).To(BeNumerically("==", 1))
+
+			// Once the result is delivered the item is executed again
+			result := b.Add(cancelCtx, lo.ToPtr(name))
+			Expect(result.Err).ToNot(HaveOccurred())
+			Expect(*result.Output).To(Equal(name + "-2"))
+		})
+		It("should drop an item in flight once every caller gave up on it", func() {
+			var executed atomic.Int64
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:                "dedupe_in_flight_canceled",
+				IdleTimeout:         time.Hour,
+				MaxTimeout:          time.Hour,
+				RequestHasher:       batcher.OneBucketHasher[string],
+				DeduplicateInFlight: true,
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					executed.Add(int64(len(items)))
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			})
+
+			ctx, cancel := context.WithCancel(cancelCtx)
+			results := make(chan batcher.Result[string], 2)
+			for i := 0; i < 2; i++ {
+				go func() { results <- b.Add(ctx, lo.ToPtr("item")) }()
+			}
+			Eventually(b.Len).Should(Equal(1))
+			cancel()
+			Expect((<-results).Err).To(MatchError(context.Canceled))
+			Expect((<-results).Err).To(MatchError(context.Canceled))
+			Eventually(b.Len).Should(Equal(0))
+			b.Flush(cancelCtx)
+			Expect(executed.Load()).To(BeNumerically("==", 0))
+		})
+	})
+	Context("Cancellation", func() {
+		It("should unblock a canceled caller without affecting the rest of the batch", func() {
+			release := make(chan struct{})
+			var executed atomic.Int64
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "cancel",
+				IdleTimeout:   100 * time.Millisecond,
+				MaxTimeout:    time.Second,
+				RequestHasher: batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					<-release
+					executed.Add(int64(len(items)))
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: lo.ToPtr(*i)}
+					})
+				},
+			})
+
+			var wg sync.WaitGroup
+			var succeeded atomic.Int64
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer GinkgoRecover()
+					name := randomName()
+					result := b.Add(cancelCtx, lo.ToPtr(name))
+					Expect(result.Err).ToNot(HaveOccurred())
+					Expect(*result.Output).To(Equal(name))
+					succeeded.Add(1)
+				}()
+			}
+			addCtx, addCancel := context.WithCancel(cancelCtx)
+			canceled := make(chan batcher.Result[string])
+			go func() {
+				canceled <- b.Add(addCtx, lo.ToPtr(randomName()))
+			}()
+			addCancel()
+
+			var result batcher.Result[string]
+			Eventually(canceled).Should(Receive(&result))
+			Expect(result.Err).To(MatchError(context.Canceled))
+
+			close(release)
+			wg.Wait()
+			Expect(succeeded.Load()).To(BeNumerically("==", 5))
+		})
+	})
+	Context("Retries", func() {
+		It("should retry retryable errors and return other errors immediately", func() {
+			errThrottled := errors.New("throttled")
+			errFatal := errors.New("fatal")
+			var attempts sync.Map
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "retry",
+				IdleTimeout:   10 * time.Millisecond,
+				MaxTimeout:    100 * time.Millisecond,
+				RequestHasher: batcher.DefaultHasher[string],
+				RetryPolicy: &batcher.RetryPolicy{
+					MaxRetries:  3,
+					BaseDelay:   10 * time.Millisecond,
+					MaxDelay:    100 * time.Millisecond,
+					IsRetryable: func(err error) bool { return errors.Is(err, errThrottled) },
+				},
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						count, _ := attempts.LoadOrStore(*i, &atomic.Int64{})
+						n := count.(*atomic.Int64).Add(1)
+						switch {
+						case strings.HasPrefix(*i, "fatal"):
+							return batcher.Result[string]{Err: errFatal}
+						case strings.HasPrefix(*i, "always") || n < 3:
+							return batcher.Result[string]{Err: errThrottled}
+						}
+						return batcher.Result[string]{Output: lo.ToPtr(*i)}
+					})
+				},
+			})
+
+			result := b.Add(cancelCtx, lo.ToPtr("throttled"))
+			Expect(result.Err).ToNot(HaveOccurred())
+			Expect(*result.Output).To(Equal("throttled"))
+			count, _ := attempts.Load("throttled")
+			Expect(count.(*atomic.Int64).Load()).To(BeNumerically("==", 3))
+
+			result = b.Add(cancelCtx, lo.ToPtr("fatal"))
+			Expect(result.Err).To(MatchError(errFatal))
+			count, _ = attempts.Load("fatal")
+			Expect(count.(*atomic.Int64).Load()).To(BeNumerically("==", 1))
+
+			result = b.Add(cancelCtx, lo.ToPtr("always"))
+			Expect(result.Err).To(MatchError(errThrottled))
+			count, _ = attempts.Load("always")
+			Expect(count.(*atomic.Int64).Load()).To(BeNumerically("==", 4))
+		})
+		DescribeTable("should return the last error of a retried item once the batcher stops",
+			func(stop func(b *batcher.Batcher[string, string], cancel context.CancelFunc)) {
+				errThrottled := errors.New("throttled")
+				batcherCtx, cancelBatcher := context.WithCancel(cancelCtx)
+				defer cancelBatcher()
+				executed := make(chan struct{}, 1)
+				b := batcher.NewBatcher(batcherCtx, batcher.Options[string, string]{
+					Name:          "retry-stop",
+					IdleTimeout:   10 * time.Millisecond,
+					MaxTimeout:    100 * time.Millisecond,
+					RequestHasher: batcher.OneBucketHasher[string],
+					RetryPolicy: &batcher.RetryPolicy{
+						MaxRetries:  3,
+						BaseDelay:   200 * time.Millisecond,
+						IsRetryable: func(err error) bool { return errors.Is(err, errThrottled) },
+					},
+					BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+						select {
+						case executed <- struct{}{}:
+						default:
+						}
+						return lo.Map(items, func(_ *string, _ int) batcher.Result[string] {
+							return batcher.Result[string]{Err: errThrottled}
+						})
+					},
+				})
+
+				// The caller doesn't give up, so it relies on the batcher to return a result
+				results := make(chan batcher.Result[string], 1)
+				go func() { results <- b.Add(context.Background(), lo.ToPtr("item")) }()
+				Eventually(executed).Should(Receive())
+				stop(b, cancelBatcher)
+				var result batcher.Result[string]
+				Eventually(results, time.Second).Should(Receive(&result))
+				Expect(result.Err).To(MatchError(errThrottled))
+			},
+			Entry("closed", func(b *batcher.Batcher[string, string], _ context.CancelFunc) { b.Close() }),
+			Entry("shut down", func(_ *batcher.Batcher[string, string], cancel context.CancelFunc) { cancel() }),
+		)
+	})
+	Context("CircuitBreaker", func() {
+		var errThrottled error
+		var calls atomic.Int64
+		var failing atomic.Bool
+		var release chan struct{}
+		var b *batcher.Batcher[string, string]
+
+		BeforeEach(func() {
+			errThrottled = errors.New("throttled")
+			calls.Store(0)
+			failing.Store(true)
+			release = nil
+			b = batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "circuit",
+				IdleTimeout:   10 * time.Millisecond,
+				MaxTimeout:    100 * time.Millisecond,
+				RequestHasher: batcher.DefaultHasher[string],
+				CircuitBreaker: &batcher.CircuitBreakerPolicy{
+					FailureThreshold: 2,
+					Cooldown:         200 * time.Millisecond,
+				},
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					calls.Add(1)
+					if release != nil {
+						<-release
+					}
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						if failing.Load() {
+							return batcher.Result[string]{Err: errThrottled}
+						}
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			})
+		})
+		// open fails enough batches in a row to open the circuit
+		open := func() {
+			for i := 0; i < 2; i++ {
+				Expect(b.Add(cancelCtx, lo.ToPtr(randomName())).Err).To(MatchError(errThrottled))
+			}
+		}
+
+		It("should stay closed while batches succeed between failures", func() {
+			Expect(b.Add(cancelCtx, lo.ToPtr(randomName())).Err).To(MatchError(errThrottled))
+			failing.Store(false)
+			Expect(b.Add(cancelCtx, lo.ToPtr(randomName())).Err).ToNot(HaveOccurred())
+			failing.Store(true)
+			Expect(b.Add(cancelCtx, lo.ToPtr(randomName())).Err).To(MatchError(errThrottled))
+			Expect(b.Add(cancelCtx, lo.ToPtr(randomName())).Err).To(MatchError(errThrottled))
+			Expect(calls.Load()).To(BeNumerically("==", 4))
+		})
+		It("should fail fast without executing while the circuit is open", func() {
+			open()
+			start := time.Now()
+			Expect(b.Add(cancelCtx, lo.ToPtr(randomName())).Err).To(MatchError(batcher.ErrCircuitOpen))
+			Expect(time.Since(start)).To(BeNumerically("<", 10*time.Millisecond))
+			Expect(calls.Load()).To(BeNumerically("==", 2))
+		})
+		It("should execute a single probe batch after the cooldown and close when it succeeds", func() {
+			open()
+			time.Sleep(200 * time.Millisecond)
+			failing.Store(false)
+			release = make(chan struct{})
+
+			probe := make(chan batcher.Result[string], 1)
+			go func() { probe <- b.Add(cancelCtx, lo.ToPtr("probe")) }()
+			Eventually(calls.Load).Should(BeNumerically("==", 3))
+			// other callers fail fast while the probe is executing
+			Expect(b.Add(cancelCtx, lo.ToPtr(randomName())).Err).To(MatchError(batcher.ErrCircuitOpen))
+
+			close(release)
+			Eventually(probe).Should(Receive(HaveField("Err", BeNil())))
+			Expect(b.Add(cancelCtx, lo.ToPtr(randomName())).Err).ToNot(HaveOccurred())
+			Expect(calls.Load()).To(BeNumerically("==", 4))
+		})
+		It("should open again when the probe batch fails", func() {
+			open()
+			time.Sleep(200 * time.Millisecond)
+			Expect(b.Add(cancelCtx, lo.ToPtr("probe")).Err).To(MatchError(errThrottled))
+			Expect(b.Add(cancelCtx, lo.ToPtr(randomName())).Err).To(MatchError(batcher.ErrCircuitOpen))
+			Expect(calls.Load()).To(BeNumerically("==", 3))
+		})
+	})
+	Context("AdaptiveConcurrency", func() {
+		var errThrottled error
+		var failing atomic.Bool
+		var b *batcher.Batcher[string, string]
+
+		BeforeEach(func() {
+			errThrottled = errors.New("throttled")
+			failing.Store(true)
+			b = batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:              "adaptive",
+				IdleTimeout:       10 * time.Millisecond,
+				MaxTimeout:        100 * time.Millisecond,
+				MaxRequestWorkers: 8,
+				RequestHasher:     batcher.DefaultHasher[string],
+				AdaptiveConcurrency: &batcher.AdaptiveConcurrencyPolicy{
+					Window:             200 * time.Millisecond,
+					ErrorRateThreshold: 0.5,
+					MinRequestWorkers:  2,
+				},
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						if failing.Load() {
+							return batcher.Result[string]{Err: errThrottled}
+						}
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			})
+		})
+
+		It("should halve the request workers on errors and add them back one by one once batches succeed", func() {
+			Expect(b.ErrorRate()).To(BeZero())
+			for _, workers := range []int{4, 2, 2} {
+				Expect(b.Add(cancelCtx, lo.ToPtr(randomName())).Err).To(MatchError(errThrottled))
+				Expect(b.RequestWorkers()).To(Equal(workers))
+			}
+			Expect(b.ErrorRate()).To(Equal(1.0))
+
+			// successes don't add workers back while the errors are within the window
+			failing.Store(false)
+			Expect(b.Add(cancelCtx, lo.ToPtr(randomName())).Err).ToNot(HaveOccurred())
+			Expect(b.RequestWorkers()).To(Equal(2))
+
+			Eventually(b.ErrorRate).Should(BeZero())
+			for _, workers := range []int{3, 4, 5, 6, 7, 8, 8} {
+				Expect(b.Add(cancelCtx, lo.ToPtr(randomName())).Err).ToNot(HaveOccurred())
+				Expect(b.RequestWorkers()).To(Equal(workers))
+			}
+		})
+		It("should cap the request workers at the maximum that is set", func() {
+			Expect(b.Add(cancelCtx, lo.ToPtr(randomName())).Err).To(MatchError(errThrottled))
+			Expect(b.RequestWorkers()).To(Equal(4))
+			b.SetMaxRequestWorkers(3)
+			Expect(b.RequestWorkers()).To(Equal(3))
+
+			failing.Store(false)
+			Eventually(b.ErrorRate).Should(BeZero())
+			for range 2 {
+				Expect(b.Add(cancelCtx, lo.ToPtr(randomName())).Err).ToNot(HaveOccurred())
+				Expect(b.RequestWorkers()).To(Equal(3))
+			}
+		})
+	})
+	Context("StreamingBatchExecutor", func() {
+		It("should unblock callers of early items before slow items complete", func() {
+			release := make(chan struct{})
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "streaming",
+				IdleTimeout:   100 * time.Millisecond,
+				MaxTimeout:    time.Second,
+				RequestHasher: batcher.OneBucketHasher[string],
+				StreamingBatchExecutor: func(ctx context.Context, items []*string, deliver func(int, batcher.Result[string])) {
+					var wg sync.WaitGroup
+					for idx, item := range items {
+						wg.Add(1)
+						go func() {
+							defer wg.Done()
+							if strings.HasPrefix(*item, "slow") {
+								<-release
+							}
+							deliver(idx, batcher.Result[string]{Output: lo.ToPtr(*item)})
+						}()
+					}
+					wg.Wait()
+				},
+			})
+
+			fast := make(chan batcher.Result[string], 1)
+			slow := make(chan batcher.Result[string], 1)
+			go func() { fast <- b.Add(cancelCtx, lo.ToPtr("fast")) }()
+			go func() { slow <- b.Add(cancelCtx, lo.ToPtr("slow")) }()
+
+			var result batcher.Result[string]
+			Eventually(fast, time.Second*5).Should(Receive(&result))
+			Expect(result.Err).ToNot(HaveOccurred())
+			Expect(*result.Output).To(Equal("fast"))
+			Consistently(slow, 200*time.Millisecond).ShouldNot(Receive())
+
+			close(release)
+			Eventually(slow, time.Second*5).Should(Receive(&result))
+			Expect(result.Err).ToNot(HaveOccurred())
+			Expect(*result.Output).To(Equal("slow"))
+		})
+		It("should return ErrBatchTimeout for items the executor delivered no result for", func() {
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "streaming-timeout",
+				IdleTimeout:   100 * time.Millisecond,
+				MaxTimeout:    time.Second,
+				RequestHasher: batcher.OneBucketHasher[string],
+				StreamingBatchExecutor: func(ctx context.Context, items []*string, deliver func(int, batcher.Result[string])) {
+					for idx, item := range items {
+						if *item == "delivered" {
+							deliver(idx, batcher.Result[string]{Output: item})
+							// only the first result of an item is used
+							deliver(idx, batcher.Result[string]{Err: errors.New("duplicate")})
+						}
+					}
+				},
+			})
+
+			results := b.AddBatch(cancelCtx, lo.ToSlicePtr([]string{"delivered", "dropped"}))
+			Expect(results[0].Err).ToNot(HaveOccurred())
+			Expect(*results[0].Output).To(Equal("delivered"))
+			Expect(errors.Is(results[1].Err, batcher.ErrBatchTimeout)).To(BeTrue())
+		})
+	})
+	Context("Timeouts", func() {
+		It("should return ErrBatchTimeout for items the executor returned no result for", func() {
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "timeout",
+				IdleTimeout:   100 * time.Millisecond,
+				MaxTimeout:    time.Second,
+				RequestHasher: batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					return nil
+				},
+			})
+
+			result := b.Add(cancelCtx, lo.ToPtr(randomName()))
+			Expect(errors.Is(result.Err, batcher.ErrBatchTimeout)).To(BeTrue())
+			var timeoutErr *batcher.TimeoutError
+			Expect(errors.As(result.Err, &timeoutErr)).To(BeTrue())
+			Expect(timeoutErr.Name).To(Equal("timeout"))
+			Expect(timeoutErr.Elapsed).To(BeNumerically(">", 0))
+		})
+		It("should dispatch items before the deadline of their caller's context", func() {
+			var executed atomic.Int64
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "deadline",
+				IdleTimeout:   time.Second,
+				MaxTimeout:    time.Second,
+				RequestHasher: batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					executed.Add(int64(len(items)))
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			})
+
+			// An item without a deadline is dispatched with the item that has one
+			go b.Add(cancelCtx, lo.ToPtr(randomName()))
+			Eventually(b.Len).Should(Equal(1))
+			ctx, cancel := context.WithTimeout(cancelCtx, 50*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			result := b.Add(ctx, lo.ToPtr(randomName()))
+			Expect(result.Err).ToNot(HaveOccurred())
+			Expect(time.Since(start)).To(BeNumerically("<", 50*time.Millisecond))
+			Expect(executed.Load()).To(BeNumerically("==", 2))
+		})
+		It("should return ErrBatchTimeout when the executor ignores the cancellation of its BatchExecutorTimeout", func() {
+			release := make(chan struct{})
+			defer close(release)
+			canceled := make(chan struct{})
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:                 "executor-timeout",
+				IdleTimeout:          10 * time.Millisecond,
+				MaxTimeout:           time.Second,
+				BatchExecutorTimeout: 50 * time.Millisecond,
+				RequestHasher:        batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					<-ctx.Done()
+					close(canceled)
+					<-release
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			})
+
+			start := time.Now()
+			results := b.AddBatch(cancelCtx, []*string{lo.ToPtr(randomName()), lo.ToPtr(randomName())})
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+			Eventually(canceled).Should(BeClosed())
+			for _, result := range results {
+				Expect(errors.Is(result.Err, batcher.ErrBatchTimeout)).To(BeTrue())
+			}
+		})
+		It("should return the delivered results of a StreamingBatchExecutor that exceeds its BatchExecutorTimeout", func() {
+			release := make(chan struct{})
+			defer close(release)
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:                 "streaming-executor-timeout",
+				IdleTimeout:          10 * time.Millisecond,
+				MaxTimeout:           time.Second,
+				BatchExecutorTimeout: 50 * time.Millisecond,
+				RequestHasher:        batcher.OneBucketHasher[string],
+				StreamingBatchExecutor: func(ctx context.Context, items []*string, deliver func(int, batcher.Result[string])) {
+					deliver(0, batcher.Result[string]{Output: items[0]})
+					<-release
+					deliver(1, batcher.Result[string]{Output: items[1]})
+				},
+			})
+
+			inputs := []*string{lo.ToPtr(randomName()), lo.ToPtr(randomName())}
+			results := b.AddBatch(cancelCtx, inputs)
+			Expect(results[0].Err).ToNot(HaveOccurred())
+			Expect(results[0].Output).To(Equal(inputs[0]))
+			Expect(errors.Is(results[1].Err, batcher.ErrBatchTimeout)).To(BeTrue())
+		})
+	})
+	Context("Tracing", func() {
+		It("should execute batches in a span linked to every caller", func() {
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+			previous := otel.GetTracerProvider()
+			otel.SetTracerProvider(provider)
+			defer otel.SetTracerProvider(previous)
+
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "trace",
+				IdleTimeout:   100 * time.Millisecond,
+				MaxTimeout:    time.Second,
+				RequestHasher: batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: lo.ToPtr(*i)}
+					})
+				},
+			})
+
+			callerCtx, caller := provider.Tracer("test").Start(cancelCtx, "caller")
+			results := b.AddBatch(callerCtx, lo.ToSlicePtr([]string{randomName(), randomName()}))
+			caller.End()
+			for _, result := range results {
+				Expect(result.Err).ToNot(HaveOccurred())
+			}
+
+			spans := lo.Filter(recorder.Ended(), func(s sdktrace.ReadOnlySpan, _ int) bool {
+				return s.Name() == "batcher.trace.execute"
+			})
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].Parent().SpanID()).To(Equal(caller.SpanContext().SpanID()))
+			Expect(spans[0].Links()).To(HaveLen(2))
+		})
+	})
+	Context("Panics", func() {
+		It("should return an error to every caller when the executor panics", func() {
+			fakeBatcher = NewPanickingFakeBatcher(cancelCtx)
+
+			var wg sync.WaitGroup
+			var failed atomic.Int64
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if result := fakeBatcher.batcher.Add(cancelCtx, lo.ToPtr(randomName())); result.Err != nil {
+						failed.Add(1)
+					}
+				}()
+			}
+			Eventually(failed.Load, time.Second*5).Should(BeNumerically("==", 10))
+			wg.Wait()
+			Expect(fakeBatcher.completedBatches.Load()).To(BeNumerically(">", 0))
+		})
+	})
+
+	Context("SingleFlightPerKey", func() {
+		It("should never execute overlapping batches for a key", func() {
+			var mu sync.Mutex
+			inFlight := map[string]int{}
+			maxInFlight := map[string]int{}
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:               "single_flight",
+				IdleTimeout:        time.Millisecond,
+				MaxTimeout:         10 * time.Millisecond,
+				MaxItemsPerBatch:   2,
+				SingleFlightPerKey: true,
+				RequestHasher: func(_ context.Context, input *string) uint64 {
+					return uint64((*input)[0])
+				},
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					key := (*items[0])[:1]
+					mu.Lock()
+					inFlight[key]++
+					maxInFlight[key] = max(maxInFlight[key], inFlight[key])
+					mu.Unlock()
+					time.Sleep(20 * time.Millisecond)
+					mu.Lock()
+					inFlight[key]--
+					mu.Unlock()
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			})
+
+			var wg sync.WaitGroup
+			var succeeded atomic.Int64
+			for i := 0; i < 40; i++ {
+				key := []string{"a", "b"}[i%2]
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if result := b.Add(cancelCtx, lo.ToPtr(key+randomName())); result.Err == nil {
+						succeeded.Add(1)
+					}
+				}()
+				time.Sleep(time.Millisecond)
+			}
+			wg.Wait()
+			Expect(succeeded.Load()).To(BeNumerically("==", 40))
+			Expect(maxInFlight).To(Equal(map[string]int{"a": 1, "b": 1}))
+		})
+	})
+
+	Context("Middlewares", func() {
+		It("should wrap the executor in the order of the middlewares", func() {
+			var mu sync.Mutex
+			var calls []string
+			record := func(call string) {
+				mu.Lock()
+				defer mu.Unlock()
+				calls = append(calls, call)
+			}
+			middleware := func(name string) batcher.Middleware[string, string] {
+				return func(next batcher.BatchExecutor[string, string]) batcher.BatchExecutor[string, string] {
+					return func(ctx context.Context, items []*string) []batcher.Result[string] {
+						record(name + " before")
+						defer record(name + " after")
+						return next(ctx, items)
+					}
+				}
+			}
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          "middlewares",
+				IdleTimeout:   100 * time.Millisecond,
+				MaxTimeout:    1 * time.Second,
+				RequestHasher: batcher.OneBucketHasher[string],
+				Middlewares:   []batcher.Middleware[string, string]{middleware("outer"), middleware("inner")},
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					record("executor")
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			})
+
+			result := b.Add(cancelCtx, lo.ToPtr("item"))
+			Expect(result.Err).ToNot(HaveOccurred())
+			Expect(*result.Output).To(Equal("item"))
+			mu.Lock()
+			defer mu.Unlock()
+			Expect(calls).To(Equal([]string{"outer before", "inner before", "executor", "inner after", "outer after"}))
+		})
+	})
+	Context("Metrics", func() {
+		It("should export the queued items, batches, batch sizes and batch durations", func() {
+			name := randomName()
+			release := make(chan struct{})
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:          name,
+				IdleTimeout:   time.Hour,
+				MaxTimeout:    time.Minute,
+				RequestHasher: batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					<-release
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			})
+
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					Expect(b.Add(cancelCtx, lo.ToPtr(randomName())).Err).ToNot(HaveOccurred())
+				}()
+			}
+			Eventually(func() float64 { return queuedItems(name) }).Should(BeNumerically("==", 5))
+			Expect(batcherMetric("cloudprovider_aws_batcher_batches_total", name)).To(BeNil())
+
+			// The items leave the queue once they are taken for execution
+			go b.Flush(cancelCtx)
+			Eventually(func() float64 { return queuedItems(name) }).Should(BeNumerically("==", 0))
+			close(release)
+			wg.Wait()
+
+			Eventually(func() float64 {
+				return batcherMetric("cloudprovider_aws_batcher_batches_total", name).GetCounter().GetValue()
+			}).Should(BeNumerically("==", 1))
+			size := batcherMetric("cloudprovider_aws_batcher_batch_size", name).GetHistogram()
+			Expect(size.GetSampleCount()).To(BeNumerically("==", 1))
+			Expect(size.GetSampleSum()).To(BeNumerically("==", 5))
+			duration := batcherMetric("cloudprovider_aws_batcher_batch_duration_seconds", name).GetHistogram()
+			Expect(duration.GetSampleCount()).To(BeNumerically("==", 1))
+			Expect(duration.GetSampleSum()).To(BeNumerically(">", 0))
+		})
+
+		It("should never report a negative number of queued items", func() {
+			name := randomName()
+			b := batcher.NewBatcher(cancelCtx, batcher.Options[string, string]{
+				Name:             name,
+				IdleTimeout:      10 * time.Millisecond,
+				MaxTimeout:       50 * time.Millisecond,
+				MaxBufferedItems: 7,
+				RequestHasher:    batcher.OneBucketHasher[string],
+				BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+					return lo.Map(items, func(i *string, _ int) batcher.Result[string] {
+						return batcher.Result[string]{Output: i}
+					})
+				},
+			})
+
+			done := make(chan struct{})
+			var minQueued atomic.Int64
+			go func() {
+				defer GinkgoRecover()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+						if queued := int64(queuedItems(name)); queued < minQueued.Load() {
+							minQueued.Store(queued)
+						}
+					}
+				}
+			}()
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					ctx := cancelCtx
+					if i%2 == 0 {
+						// Half of the callers give up before their batching window ends
+						var cancel context.CancelFunc
+						ctx, cancel = context.WithTimeout(cancelCtx, 5*time.Millisecond)
+						defer cancel()
+					}
+					b.Add(ctx, lo.ToPtr(randomName()))
+				}(i)
+			}
+			wg.Wait()
+			close(done)
+
+			Eventually(func() float64 { return queuedItems(name) }).Should(BeNumerically("==", 0))
+			Expect(minQueued.Load()).To(BeNumerically(">=", 0))
+		})
 	})
 })
 
@@ -139,9 +1627,70 @@ func NewFakeBatcher(ctx context.Context, requestLength time.Duration, maxRequest
 	}
 }
 
+// NewPanickingFakeBatcher creates a FakeBatcher whose executor always panics
+func NewPanickingFakeBatcher(ctx context.Context) *FakeBatcher {
+	activeBatches := &atomic.Int64{}
+	completedBatches := &atomic.Int64{}
+	options := batcher.Options[string, string]{
+		Name:          "fake-panic",
+		IdleTimeout:   100 * time.Millisecond,
+		MaxTimeout:    1 * time.Second,
+		RequestHasher: batcher.DefaultHasher[string],
+		BatchExecutor: func(ctx context.Context, items []*string) []batcher.Result[string] {
+			activeBatches.Add(1)
+			defer activeBatches.Add(-1)
+			defer completedBatches.Add(1)
+			panic("fake executor panic")
+		},
+	}
+	return &FakeBatcher{
+		activeBatches:    activeBatches,
+		completedBatches: completedBatches,
+		batcher:          batcher.NewBatcher(ctx, options),
+	}
+}
+
 func randomName() string {
 	sequentialNumberLock.Lock()
 	defer sequentialNumberLock.Unlock()
 	sequentialNumber++
 	return strings.ToLower(fmt.Sprintf("%s-%d-%s", randomdata.SillyName(), sequentialNumber, randomdata.Alphanumeric(10)))
 }
+
+// queuedItems returns the number of items buffered by the named batcher
+func queuedItems(name string) float64 {
+	return batcherMetric("cloudprovider_aws_batcher_queued_items", name).GetGauge().GetValue()
+}
+
+// batcherMetric returns the series of the metric family for the named batcher, or nil when it has none
+func batcherMetric(family string, name string) *dto.Metric {
+	families, err := legacyregistry.DefaultGatherer.Gather()
+	Expect(err).ToNot(HaveOccurred())
+	for _, f := range families {
+		if f.GetName() != family {
+			continue
+		}
+		for _, metric := range f.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "batcher" && label.GetValue() == name {
+					return metric
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// blockingRateLimiter allows calls once available is closed
+type blockingRateLimiter struct {
+	available chan struct{}
+}
+
+func (l *blockingRateLimiter) WaitAvailable(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-l.available:
+		return nil
+	}
+}
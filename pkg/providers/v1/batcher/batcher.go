@@ -20,12 +20,16 @@ import (
 	"context"
 	"fmt"
 	"k8s.io/klog/v2"
+	"reflect"
+	"runtime/debug"
 	"sync"
 	"time"
 
 	"github.com/mitchellh/hashstructure/v2"
 	"github.com/samber/lo"
-	"golang.org/x/sync/errgroup"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Options allows for configuration of the Batcher
@@ -34,9 +38,57 @@ type Options[T input, U output] struct {
 	IdleTimeout       time.Duration
 	MaxTimeout        time.Duration
 	MaxItems          int
+	MaxItemsPerBatch  int // caps the items passed to a single BatchExecutor call, zero means no limit
+	MaxBufferedItems  int // dispatches the items of a key once this many are buffered, zero means no limit
 	MaxRequestWorkers int
-	RequestHasher     RequestHasher[T]
-	BatchExecutor     BatchExecutor[T, U]
+	RequestHasher     RequestHasher[T] // defaults to DefaultHasher for comparable inputs
+	// RequestDeduplicator optionally identifies equal inputs within a batch. Equal inputs are passed to the
+	// BatchExecutor once and the single result is returned to every caller that added them.
+	RequestDeduplicator RequestHasher[T]
+	BatchExecutor       BatchExecutor[T, U]
+	// StreamingBatchExecutor optionally replaces the BatchExecutor with an executor that delivers the result of each
+	// input as soon as it is available, so callers don't wait for the slowest input of their batch
+	StreamingBatchExecutor StreamingBatchExecutor[T, U]
+	// BatchExecutorTimeout optionally bounds each execution of the executor. The executor's context is canceled after
+	// the timeout and the items without a result get a TimeoutError, even if the executor ignores the cancellation.
+	BatchExecutorTimeout time.Duration
+	// RetryPolicy optionally re-enqueues items that failed with a retryable error instead of returning the error
+	RetryPolicy *RetryPolicy
+	// CircuitBreaker optionally fails items fast while the BatchExecutor keeps failing
+	CircuitBreaker *CircuitBreakerPolicy
+	// AdaptiveConcurrency optionally lowers the request workers below MaxRequestWorkers while the error rate of the
+	// executions is high, and raises them back as batches succeed
+	AdaptiveConcurrency *AdaptiveConcurrencyPolicy
+	// ShardItems shards the items of a single key over concurrent batches once the key has more than this many
+	// items, so a hot key is executed by several workers instead of a few large batches. Zero disables sharding.
+	ShardItems int
+	// ShardHasher is the secondary hash that assigns each item of a key to its shard, equal hashes always share a
+	// shard. It defaults to the RequestDeduplicator, so duplicates are still executed once, then to DefaultHasher.
+	ShardHasher RequestHasher[T]
+	// RateLimiter optionally holds back the dispatch of batches while the rate limit of the batched API is exhausted,
+	// items keep being buffered meanwhile
+	RateLimiter RateLimiter
+	// SingleFlightPerKey executes at most one batch per RequestHasher key at a time, batches of different keys still
+	// execute concurrently. Batches of a key that is in flight wait for it and are then coalesced, up to
+	// MaxItemsPerBatch items. The shards of a key are serialized as well.
+	SingleFlightPerKey bool
+	// DeduplicateInFlight joins an input equal to an input that is buffered or executing, according to the
+	// RequestDeduplicator, to that input instead of adding it again. Every caller gets the single result, even when
+	// the inputs were added in different batching windows. It suits idempotent operations, and the
+	// RequestDeduplicator defaults to DefaultHasher with it.
+	DeduplicateInFlight bool
+	// Middlewares wrap the BatchExecutor, e.g. with retries, metrics or tracing. The first middleware is the outermost,
+	// so it is called first and returns last. They don't wrap a StreamingBatchExecutor.
+	Middlewares []Middleware[T, U]
+}
+
+// AddOptions configures a single call to add inputs to the batcher
+type AddOptions struct {
+	// IdleTimeout and MaxTimeout override the batching window of the batcher's Options when they are set
+	IdleTimeout time.Duration
+	MaxTimeout  time.Duration
+	// Priority orders the execution of batches when all request workers are busy
+	Priority int
 }
 
 // Result is a container for the output and error of an execution
@@ -45,15 +97,34 @@ type Result[U output] struct {
 	Err    error
 }
 
+// tracerName is the instrumentation scope of the spans created by the batcher
+const tracerName = "k8s.io/cloud-provider-aws/pkg/providers/v1/batcher"
+
 type input = any
 type output = any
 
-// request is a batched request with the calling ctx, requestor, and hash to determine the batching bucket
+// request is a batched request with the calling ctx, requestor, and bucket to determine the batching bucket
 type request[T input, U output] struct {
 	ctx       context.Context
-	hash      uint64
+	bucket    bucket
 	input     *T
 	requestor chan Result[U]
+	added     time.Time
+	attempts  int
+}
+
+// bucket groups requests that are executed together, requests are only batched with requests of the same priority
+// and batching window
+type bucket struct {
+	hash     uint64
+	priority int
+	window   window
+}
+
+// window is how long a batching loop waits for more requests before executing the requests it has
+type window struct {
+	idleTimeout time.Duration
+	maxTimeout  time.Duration
 }
 
 // Batcher is used to batch API calls with identical parameters into a single call
@@ -62,13 +133,55 @@ type Batcher[T input, U output] struct {
 	options Options[T, U]
 
 	mu       sync.Mutex
-	requests map[uint64][]*request[T, U]
+	requests map[bucket][]*request[T, U]
+	// closed is set by Close, after which no requests are accepted
+	closed bool
 
-	// trigger to initiate the batcher
-	trigger chan struct{}
+	// triggers initiate the batching loop of each window, loops are started on the first request of their window and
+	// stop once their window had no requests for its MaxTimeout
+	triggers map[window]chan struct{}
+	loops    sync.WaitGroup
+	// closing is closed by Close to make the batching loops execute the buffered requests and exit
+	closing chan struct{}
+	// done is closed once Close has stopped every batching loop and every batch they started has completed
+	done chan struct{}
+
+	// execCtx cancels executing batches, it is canceled with the batcher's context or when Close times out
+	execCtx    context.Context
+	cancelExec context.CancelFunc
 
 	// requestWorkers is a group of concurrent workers that execute requests
-	requestWorkers errgroup.Group
+	requestWorkers *workerPool
+
+	// breaker is the circuit breaker of the CircuitBreaker option, it is nil when the option is not set
+	breaker *circuitBreaker
+
+	// errorRate is the error rate of the executed items, concurrency adjusts the request workers to it with the
+	// AdaptiveConcurrency option and is nil when the option is not set
+	errorRate   *errorRateWindow
+	concurrency *adaptiveConcurrency
+
+	// flights holds the batches waiting for the batch in flight of their key with SingleFlightPerKey, a key is in
+	// the map while one of its batches executes
+	flightsMu sync.Mutex
+	flights   map[uint64][]*flightBatch[T, U]
+
+	// inFlight holds the requests that are buffered or executing with DeduplicateInFlight, by RequestDeduplicator
+	// key, it is guarded by mu
+	inFlight map[uint64]*inFlightRequest[T, U]
+}
+
+// inFlightRequest is a request shared by the callers that added equal inputs, the result of the request is sent to
+// every waiter
+type inFlightRequest[T input, U output] struct {
+	request *request[T, U]
+	waiters []chan Result[U]
+}
+
+// flightBatch is a batch waiting for the batch in flight of its key, done is called once it executed
+type flightBatch[T input, U output] struct {
+	requests []*request[T, U]
+	done     func()
 }
 
 // BatchExecutor is a function that executes a slice of inputs against the batched API.
@@ -77,41 +190,357 @@ type Batcher[T input, U output] struct {
 // same order, if order matters for the batched API
 type BatchExecutor[T input, U output] func(ctx context.Context, input []*T) []Result[U]
 
+// Middleware wraps a BatchExecutor with a BatchExecutor that calls next
+type Middleware[T input, U output] func(next BatchExecutor[T, U]) BatchExecutor[T, U]
+
+// StreamingBatchExecutor is a function that executes a slice of inputs against the batched API and calls deliver
+// with the index of an input and its result as soon as that result is available. deliver may be called from several
+// goroutines, only the first result of an input is used, and inputs without a result when the executor returns
+// get a TimeoutError.
+type StreamingBatchExecutor[T input, U output] func(ctx context.Context, input []*T, deliver func(idx int, result Result[U]))
+
+// RateLimiter paces the dispatch of batches
+type RateLimiter interface {
+	// WaitAvailable blocks until the batched API can be called without exceeding its rate limit. It doesn't take
+	// from the limit, as the calls of the BatchExecutor do.
+	WaitAvailable(ctx context.Context) error
+}
+
 // RequestHasher is a function that hashes input to bucket inputs into distinct batches
 type RequestHasher[T input] func(ctx context.Context, input *T) uint64
 
-// NewBatcher creates a batcher that can batch a particular input and output type
+// NewBatcher creates a batcher that can batch a particular input and output type. When no RequestHasher is set,
+// comparable inputs are hashed with DefaultHasher, and NewBatcher panics for inputs that aren't comparable.
 func NewBatcher[T input, U output](ctx context.Context, options Options[T, U]) *Batcher[T, U] {
+	if options.RequestHasher == nil {
+		if t := reflect.TypeFor[T](); !t.Comparable() {
+			panic(fmt.Sprintf("batcher %s: a RequestHasher is required for input type %v that is not comparable", options.Name, t))
+		}
+		options.RequestHasher = DefaultHasher[T]
+	}
+	if options.ShardHasher == nil {
+		options.ShardHasher = lo.Ternary(options.RequestDeduplicator != nil, options.RequestDeduplicator, DefaultHasher[T])
+	}
+	if options.DeduplicateInFlight && options.RequestDeduplicator == nil {
+		options.RequestDeduplicator = DefaultHasher[T]
+	}
+	if options.BatchExecutor != nil {
+		for i := len(options.Middlewares) - 1; i >= 0; i-- {
+			options.BatchExecutor = options.Middlewares[i](options.BatchExecutor)
+		}
+	}
+	registerMetrics()
 	b := &Batcher[T, U]{
-		ctx:      ctx,
-		options:  options,
-		requests: map[uint64][]*request[T, U]{},
+		ctx:       ctx,
+		options:   options,
+		requests:  map[bucket][]*request[T, U]{},
+		triggers:  map[window]chan struct{}{},
+		closing:   make(chan struct{}),
+		done:      make(chan struct{}),
+		breaker:   newCircuitBreaker(options.Name, options.CircuitBreaker),
+		errorRate: newErrorRateWindow(options.AdaptiveConcurrency),
+		flights:   map[uint64][]*flightBatch[T, U]{},
+		inFlight:  map[uint64]*inFlightRequest[T, U]{},
+	}
+	b.execCtx, b.cancelExec = context.WithCancel(ctx)
+	b.requestWorkers = newWorkerPool(lo.Ternary(b.options.MaxRequestWorkers != 0, b.options.MaxRequestWorkers, 100))
+	b.concurrency = newAdaptiveConcurrency(options.Name, options.AdaptiveConcurrency, b.requestWorkers, b.requestWorkers.Limit())
+	b.trigger(b.window(AddOptions{}))
+	return b
+}
+
+// window returns the batching window of opts, defaulting to the batcher's Options
+func (b *Batcher[T, U]) window(opts AddOptions) window {
+	return window{
+		idleTimeout: lo.Ternary(opts.IdleTimeout > 0, opts.IdleTimeout, b.options.IdleTimeout),
+		maxTimeout:  lo.Ternary(opts.MaxTimeout > 0, opts.MaxTimeout, b.options.MaxTimeout),
+	}
+}
+
+// trigger returns the trigger channel of the batching loop of a window, starting the loop for the first request of
+// the window. b.mu must be held, or the batcher not yet shared.
+func (b *Batcher[T, U]) trigger(w window) chan struct{} {
+	trigger, ok := b.triggers[w]
+	if !ok {
 		// The trigger channel is buffered since we shouldn't block the Add() method on the trigger channel
 		// if another Add() has already triggered it. This works because we add the request to the request map BEFORE
 		// we perform the trigger
-		trigger: make(chan struct{}, 1),
+		trigger = make(chan struct{}, 1)
+		b.triggers[w] = trigger
+		b.loops.Add(1)
+		go b.run(w, trigger)
 	}
-	b.requestWorkers.SetLimit(lo.Ternary(b.options.MaxRequestWorkers != 0, b.options.MaxRequestWorkers, 100))
-	go b.run()
-	return b
+	return trigger
 }
 
-// Add will add an input to the batcher using the batcher's hashing function
+// Add will add an input to the batcher using the batcher's hashing function. If ctx is done before a result is
+// available, Add returns ctx.Err() without waiting for the rest of the batch. When the deadline of ctx falls within
+// the batching window, the input is dispatched early with the items buffered with it, so it can execute in time.
 func (b *Batcher[T, U]) Add(ctx context.Context, input *T) Result[U] {
-	request := &request[T, U]{
-		ctx:   ctx,
-		hash:  b.options.RequestHasher(ctx, input),
-		input: input,
-		// The requestor channel is buffered to ensure that the exec runner can always write the result out preventing
-		// any single caller from blocking the others. Specifically since we register our request and then trigger, the
-		// request may be processed while the triggering blocks.
-		requestor: make(chan Result[U], 1),
+	return b.addBatch(ctx, []*T{input}, AddOptions{})[0]
+}
+
+// AddWithPriority adds an input like Add, but the input is only batched with inputs of the same priority. When all
+// request workers are busy, batches with a higher priority are executed before batches with a lower priority.
+func (b *Batcher[T, U]) AddWithPriority(ctx context.Context, input *T, priority int) Result[U] {
+	return b.addBatch(ctx, []*T{input}, AddOptions{Priority: priority})[0]
+}
+
+// AddWithOptions adds an input like Add, with the batching window and priority of opts. The input is only batched
+// with inputs of the same batching window and priority, so callers with different latency tolerances can share a
+// batcher.
+func (b *Batcher[T, U]) AddWithOptions(ctx context.Context, input *T, opts AddOptions) Result[U] {
+	return b.addBatch(ctx, []*T{input}, opts)[0]
+}
+
+// AddBatch adds every input to the batcher and blocks until all of their results are available. Results are
+// returned in the same order as the inputs. Inputs without a result when ctx is done get ctx.Err().
+func (b *Batcher[T, U]) AddBatch(ctx context.Context, inputs []*T) []Result[U] {
+	return b.addBatch(ctx, inputs, AddOptions{})
+}
+
+// AddBatchKeyed adds inputs like AddBatch and returns the results keyed by input value instead of by index.
+// Duplicate inputs are only added once, every duplicate shares the single result of its key. Nil inputs are
+// ignored. It is a function rather than a method because it requires comparable inputs.
+func AddBatchKeyed[T comparable, U output](ctx context.Context, b *Batcher[T, U], inputs []*T) map[T]Result[U] {
+	unique := lo.UniqBy(lo.Compact(inputs), func(input *T) T { return *input })
+	results := b.AddBatch(ctx, unique)
+	keyed := make(map[T]Result[U], len(unique))
+	for i, input := range unique {
+		keyed[*input] = results[i]
+	}
+	return keyed
+}
+
+func (b *Batcher[T, U]) addBatch(ctx context.Context, inputs []*T, opts AddOptions) []Result[U] {
+	if err := b.breaker.allow(); err != nil {
+		return lo.Map(inputs, func(_ *T, _ int) Result[U] { return Result[U]{Err: err} })
+	}
+	w := b.window(opts)
+	requests := lo.Map(inputs, func(input *T, _ int) *request[T, U] {
+		return &request[T, U]{
+			ctx:    ctx,
+			bucket: bucket{hash: b.options.RequestHasher(ctx, input), priority: opts.Priority, window: w},
+			input:  input,
+			added:  time.Now(),
+			// The requestor channel is buffered to ensure that the exec runner can always write the result out preventing
+			// any single caller from blocking the others. Specifically since we register our request and then trigger, the
+			// request may be processed while the triggering blocks.
+			requestor: make(chan Result[U], 1),
+		}
+	})
+	var keys []uint64
+	if b.options.DeduplicateInFlight {
+		keys = lo.Map(inputs, func(input *T, _ int) uint64 { return b.options.RequestDeduplicator(ctx, input) })
 	}
 	b.mu.Lock()
-	b.requests[request.hash] = append(b.requests[request.hash], request)
+	if b.closed {
+		b.mu.Unlock()
+		return lo.Map(requests, func(_ *request[T, U], _ int) Result[U] { return Result[U]{Err: ErrBatcherClosed} })
+	}
+	queued, waiters := requests, lo.Map(requests, func(request *request[T, U], _ int) chan Result[U] { return request.requestor })
+	if b.options.DeduplicateInFlight {
+		queued, waiters = b.joinInFlight(requests, keys)
+	}
+	for _, request := range queued {
+		b.requests[request.bucket] = append(b.requests[request.bucket], request)
+	}
+	full := b.takeFull(lo.Map(queued, func(request *request[T, U], _ int) bucket { return request.bucket })...)
+	recordQueuedItems(b.options.Name, len(queued)-count(full))
+	trigger := b.trigger(w)
+	b.mu.Unlock()
+	b.dispatch(full)
+	stop := b.dispatchBeforeDeadline(ctx, w, queued)
+	defer stop()
+	// The trigger is only a wakeup of the batching loop, so it never blocks: the trigger channel is buffered, the
+	// requests are already registered, and a closed batcher's loop no longer reads it.
+	for range queued {
+		select {
+		case trigger <- struct{}{}:
+		default:
+		}
+	}
+	results := make([]Result[U], len(requests))
+	for i, request := range requests {
+		select {
+		case results[i] = <-waiters[i]:
+		case <-ctx.Done():
+			if b.options.DeduplicateInFlight {
+				b.leaveInFlight(keys[i], waiters[i], ctx.Err())
+			} else {
+				b.remove(request)
+			}
+			results[i] = Result[U]{Err: ctx.Err()}
+		}
+	}
+	return results
+}
+
+// joinInFlight returns the requests to queue and the channel each request's caller receives its result on. Requests
+// equal to a request in flight wait for its result instead of being queued. The queued requests are detached from
+// the cancellation of their caller, as other callers may join them. b.mu must be held.
+func (b *Batcher[T, U]) joinInFlight(requests []*request[T, U], keys []uint64) ([]*request[T, U], []chan Result[U]) {
+	var queued []*request[T, U]
+	waiters := make([]chan Result[U], len(requests))
+	for i, req := range requests {
+		waiters[i] = make(chan Result[U], 1)
+		if shared, ok := b.inFlight[keys[i]]; ok {
+			shared.waiters = append(shared.waiters, waiters[i])
+			continue
+		}
+		req.ctx = context.WithoutCancel(req.ctx)
+		shared := &inFlightRequest[T, U]{request: req, waiters: []chan Result[U]{waiters[i]}}
+		b.inFlight[keys[i]] = shared
+		go b.fanOut(keys[i], shared)
+		queued = append(queued, req)
+	}
+	return queued, waiters
+}
+
+// fanOut sends the result of a request in flight to its waiters
+func (b *Batcher[T, U]) fanOut(key uint64, shared *inFlightRequest[T, U]) {
+	result := <-shared.request.requestor
+	b.mu.Lock()
+	if b.inFlight[key] == shared {
+		delete(b.inFlight, key)
+	}
+	waiters := shared.waiters
+	b.mu.Unlock()
+	for _, waiter := range waiters {
+		waiter <- result
+	}
+}
+
+// leaveInFlight stops a caller from waiting on a request in flight. The request is dropped when no caller waits on
+// it anymore and it has not been dispatched yet.
+func (b *Batcher[T, U]) leaveInFlight(key uint64, waiter chan Result[U], err error) {
+	b.mu.Lock()
+	shared, ok := b.inFlight[key]
+	if !ok || !lo.Contains(shared.waiters, waiter) {
+		b.mu.Unlock()
+		return
+	}
+	shared.waiters = lo.Without(shared.waiters, waiter)
+	if len(shared.waiters) > 0 {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.inFlight, key)
+	b.mu.Unlock()
+	if b.remove(shared.request) {
+		// Nobody waits on the result, it only ends fanOut
+		shared.request.requestor <- Result[U]{Err: err}
+	}
+}
+
+// remove drops a request that has not been dispatched yet and returns whether it did. Requests that are already
+// executing are left in their batch, and their result is discarded into the buffered requestor channel.
+func (b *Batcher[T, U]) remove(req *request[T, U]) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	remaining := lo.Without(b.requests[req.bucket], req)
+	if len(remaining) == len(b.requests[req.bucket]) {
+		return false
+	}
+	recordQueuedItems(b.options.Name, -1)
+	if len(remaining) == 0 {
+		delete(b.requests, req.bucket)
+		return true
+	}
+	b.requests[req.bucket] = remaining
+	return true
+}
+
+// SetMaxRequestWorkers changes the number of batches that may execute concurrently. Lowering the limit lets
+// running batches finish, but no new batch starts until the number of running batches drops below n. With the
+// AdaptiveConcurrency option n is the most request workers, which are only added back as batches succeed. n is
+// raised to 1 when it is lower, so that batches keep executing.
+func (b *Batcher[T, U]) SetMaxRequestWorkers(n int) {
+	n = max(n, 1)
+	if b.concurrency != nil {
+		b.concurrency.setMax(n)
+		return
+	}
+	b.requestWorkers.SetLimit(n)
+}
+
+// RequestWorkers returns the number of batches that may currently execute concurrently
+func (b *Batcher[T, U]) RequestWorkers() int {
+	return b.requestWorkers.Limit()
+}
+
+// ErrorRate returns the fraction of the items executed recently that failed, over the Window of the
+// AdaptiveConcurrency option or the last minute. Items without a result count as failed.
+func (b *Batcher[T, U]) ErrorRate() float64 {
+	return b.errorRate.rate()
+}
+
+// recordErrors adds the outcome of an executed batch to the error rate and adjusts the request workers to it
+func (b *Batcher[T, U]) recordErrors(items, errors int) {
+	rate := b.errorRate.record(items, errors)
+	b.concurrency.adjust(errors > 0, rate)
+}
+
+// Len returns the number of items that are buffered and not yet dispatched to the BatchExecutor
+func (b *Batcher[T, U]) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for _, requests := range b.requests {
+		n += len(requests)
+	}
+	return n
+}
+
+// Flush immediately executes every request that is currently buffered, regardless of the
+// IdleTimeout and MaxTimeout, and blocks until those batches complete or ctx is done.
+// Requests added after Flush is called are left for the normal batching loop.
+func (b *Batcher[T, U]) Flush(ctx context.Context) {
+	requests := b.take(nil)
+
+	var wg sync.WaitGroup
+	for _, v := range b.split(requests) {
+		wg.Add(1)
+		b.start(v, wg.Done)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-ctx.Done():
+	case <-done:
+	}
+}
+
+// Close stops accepting requests, executes the requests that are buffered and waits until every batch has delivered
+// its results. Batches still executing after MaxTimeout are canceled through their context. Requests added after
+// Close get ErrBatcherClosed.
+func (b *Batcher[T, U]) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		<-b.done
+		return
+	}
+	b.closed = true
 	b.mu.Unlock()
-	b.trigger <- struct{}{}
-	return <-request.requestor
+	close(b.closing)
+	go func() {
+		b.loops.Wait()
+		close(b.done)
+	}()
+
+	timeout := time.NewTimer(b.options.MaxTimeout)
+	defer timeout.Stop()
+	select {
+	case <-b.done:
+	case <-timeout.C:
+		klog.Warningf("Batcher %s did not complete its batches within %v of closing, canceling them", b.options.Name, b.options.MaxTimeout)
+		b.cancelExec()
+		<-b.done
+	}
 }
 
 // DefaultHasher will hash the entire input
@@ -128,53 +557,226 @@ func OneBucketHasher[T input](_ context.Context, _ *T) uint64 {
 	return 0
 }
 
-func (b *Batcher[T, U]) run() {
+// run is the batching loop of a window. It waits for requests of the window and executes them once the window
+// has passed. It stops once the window had no requests for its MaxTimeout, so that the windows of AddOptions don't
+// each keep a loop running.
+func (b *Batcher[T, U]) run(w window, trigger chan struct{}) {
+	defer b.loops.Done()
 	for {
 		var startTime time.Time
 		select {
 		// context that we started with has completed so the app is shutting down
 		case <-b.ctx.Done():
-			_ = b.requestWorkers.Wait()
+			b.requestWorkers.Wait()
 			return
-		case <-b.trigger:
+		// the batcher is closed, so execute what is buffered without waiting for more requests
+		case <-b.closing:
+			b.dispatch(b.take(&w))
+			b.requestWorkers.Wait()
+			return
+		case <-trigger:
 			// Start the timer for logging batch duration
 			startTime = time.Now()
+		case <-time.After(w.maxTimeout):
+			if b.stopIdle(w) {
+				return
+			}
+			continue
 		}
-		b.waitForIdle()
-		// Log the time taken instead of using Prometheus metrics
+		b.waitForIdle(w, trigger)
+		// Log the time spent waiting for the batch window, executor latency is recorded in runCalls
 		duration := time.Since(startTime)
 		klog.Infof("Batch processing duration: %v", duration)
 
-		// Copy the requests, so we can reset the requests for the next batching loop
+		b.waitForRateLimit()
+		b.dispatch(b.take(&w))
+	}
+}
+
+// stopIdle removes the trigger of window w and returns true when no requests of the window are queued, so that its
+// loop can stop. Requests are queued under b.mu before their trigger is sent, so a request added afterwards starts a
+// new loop and none is left behind.
+func (b *Batcher[T, U]) stopIdle(w window) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed || lo.SomeBy(lo.Keys(b.requests), func(k bucket) bool { return k.window == w }) {
+		return false
+	}
+	delete(b.triggers, w)
+	return true
+}
+
+// dispatch executes the requests on the request workers, in batches of at most MaxItemsPerBatch items
+func (b *Batcher[T, U]) dispatch(requests map[bucket][]*request[T, U]) {
+	for _, v := range b.split(requests) {
+		b.start(v, func() {})
+	}
+}
+
+// start executes a batch on the request workers and calls done once it executed. With SingleFlightPerKey a batch
+// whose key has a batch in flight waits for it instead.
+func (b *Batcher[T, U]) start(requests []*request[T, U], done func()) {
+	if !b.options.SingleFlightPerKey {
+		b.requestWorkers.Go(requests[0].bucket.priority, func() {
+			defer done()
+			b.runCalls(requests)
+		})
+		return
+	}
+	key := requests[0].bucket.hash
+	b.flightsMu.Lock()
+	if waiting, ok := b.flights[key]; ok {
+		b.flights[key] = append(waiting, &flightBatch[T, U]{requests: requests, done: done})
+		b.flightsMu.Unlock()
+		return
+	}
+	b.flights[key] = nil
+	b.flightsMu.Unlock()
+	b.requestWorkers.Go(requests[0].bucket.priority, func() {
+		b.runFlight(key, []*flightBatch[T, U]{{requests: requests, done: done}})
+	})
+}
+
+// runFlight executes the batches of a key one after the other, coalescing the batches that waited for the previous
+// one, until no batch of the key is waiting
+func (b *Batcher[T, U]) runFlight(key uint64, batches []*flightBatch[T, U]) {
+	for len(batches) > 0 {
+		b.runCalls(lo.FlatMap(batches, func(batch *flightBatch[T, U], _ int) []*request[T, U] { return batch.requests }))
+		for _, batch := range batches {
+			batch.done()
+		}
+		b.flightsMu.Lock()
+		batches, b.flights[key] = b.coalesce(b.flights[key])
+		if len(batches) == 0 {
+			delete(b.flights, key)
+		}
+		b.flightsMu.Unlock()
+	}
+}
+
+// coalesce returns the first waiting batches that fit in a batch of MaxItemsPerBatch items, at least one, and the
+// batches that keep waiting
+func (b *Batcher[T, U]) coalesce(waiting []*flightBatch[T, U]) ([]*flightBatch[T, U], []*flightBatch[T, U]) {
+	n, items := 0, 0
+	for n < len(waiting) {
+		items += len(waiting[n].requests)
+		if n > 0 && b.options.MaxItemsPerBatch > 0 && items > b.options.MaxItemsPerBatch {
+			break
+		}
+		n++
+	}
+	return waiting[:n], waiting[n:]
+}
+
+// take removes the requests of a window, or every request when w is nil, so the next batching loop starts empty
+func (b *Batcher[T, U]) take(w *window) map[bucket][]*request[T, U] {
+	b.mu.Lock()
+	requests := b.requests
+	if w == nil {
+		b.requests = map[bucket][]*request[T, U]{}
+	} else {
+		requests = lo.PickBy(b.requests, func(k bucket, _ []*request[T, U]) bool { return k.window == *w })
+		b.requests = lo.OmitBy(b.requests, func(k bucket, _ []*request[T, U]) bool { return k.window == *w })
+	}
+	recordQueuedItems(b.options.Name, -count(requests))
+	b.mu.Unlock()
+	return requests
+}
+
+// queued returns the number of requests queued in window w
+func (b *Batcher[T, U]) queued(w window) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return count(lo.PickBy(b.requests, func(k bucket, _ []*request[T, U]) bool { return k.window == w }))
+}
+
+// count returns the number of requests in every bucket
+func count[T input, U output](requests map[bucket][]*request[T, U]) int {
+	return lo.Sum(lo.MapToSlice(requests, func(_ bucket, v []*request[T, U]) int { return len(v) }))
+}
+
+// takeFull removes the requests of the buckets that have reached MaxBufferedItems, so they can be dispatched without
+// waiting for their batching window. b.mu must be held.
+func (b *Batcher[T, U]) takeFull(buckets ...bucket) map[bucket][]*request[T, U] {
+	full := map[bucket][]*request[T, U]{}
+	if b.options.MaxBufferedItems <= 0 {
+		return full
+	}
+	for _, k := range buckets {
+		if len(b.requests[k]) >= b.options.MaxBufferedItems {
+			full[k] = b.requests[k]
+			delete(b.requests, k)
+		}
+	}
+	if n := count(full); n > 0 {
+		klog.V(4).Infof("Dispatching %d buffered items for label %v without waiting for the batching window", n, b.options.Name)
+	}
+	return full
+}
+
+// dispatchBeforeDeadline dispatches the buckets of the requests without waiting for the batching window when the
+// deadline of ctx falls within it. They are dispatched once half of the time until the deadline has passed, leaving
+// the other half for the execution. It returns a function that cancels the early dispatch.
+func (b *Batcher[T, U]) dispatchBeforeDeadline(ctx context.Context, w window, requests []*request[T, U]) func() bool {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return func() bool { return false }
+	}
+	wait := time.Until(deadline) / 2
+	if wait >= w.maxTimeout {
+		return func() bool { return false }
+	}
+	timer := time.AfterFunc(wait, func() {
 		b.mu.Lock()
-		requests := b.requests
-		b.requests = map[uint64][]*request[T, U]{}
+		pending := map[bucket][]*request[T, U]{}
+		for _, request := range requests {
+			if lo.Contains(b.requests[request.bucket], request) {
+				pending[request.bucket] = b.requests[request.bucket]
+				delete(b.requests, request.bucket)
+			}
+		}
+		recordQueuedItems(b.options.Name, -count(pending))
 		b.mu.Unlock()
+		if n := count(pending); n > 0 {
+			klog.V(4).Infof("Dispatching %d buffered items for label %v before the deadline of their caller", n, b.options.Name)
+			b.dispatch(pending)
+		}
+	})
+	return timer.Stop
+}
 
-		for _, v := range requests {
-			req := v // create a local closure for the requests value
-			b.requestWorkers.Go(func() error {
-				b.runCalls(req)
-				return nil
-			})
+// split breaks each bucket of requests into its shards, and the shards into batches of at most MaxItemsPerBatch items
+func (b *Batcher[T, U]) split(requests map[bucket][]*request[T, U]) [][]*request[T, U] {
+	var batches [][]*request[T, U]
+	for _, v := range requests {
+		for _, shard := range b.shard(v) {
+			if b.options.MaxItemsPerBatch <= 0 {
+				batches = append(batches, shard)
+				continue
+			}
+			batches = append(batches, lo.Chunk(shard, b.options.MaxItemsPerBatch)...)
 		}
 	}
+	return batches
 }
 
-func (b *Batcher[T, U]) waitForIdle() {
-	timeout := time.NewTimer(b.options.MaxTimeout)
-	idle := time.NewTimer(b.options.IdleTimeout)
+func (b *Batcher[T, U]) waitForIdle(w window, trigger chan struct{}) {
+	timeout := time.NewTimer(w.maxTimeout)
+	idle := time.NewTimer(w.idleTimeout)
 	count := 1 // we already got a single trigger
 	for b.options.MaxItems == 0 || count < b.options.MaxItems {
 		select {
 		case <-b.ctx.Done():
 			return
-		case <-b.trigger:
-			count++
+		case <-b.closing:
+			return
+		case <-trigger:
+			// Triggers are dropped while one is pending, so the queued requests are counted instead
+			count = b.queued(w)
 			if !idle.Stop() {
 				<-idle.C
 			}
-			idle.Reset(b.options.IdleTimeout)
+			idle.Reset(w.idleTimeout)
 		case <-timeout.C:
 			return
 		case <-idle.C:
@@ -183,15 +785,215 @@ func (b *Batcher[T, U]) waitForIdle() {
 	}
 }
 
+// waitForRateLimit blocks until the RateLimiter allows a call, or the batcher's executions are canceled
+func (b *Batcher[T, U]) waitForRateLimit() {
+	if b.options.RateLimiter == nil {
+		return
+	}
+	if err := b.options.RateLimiter.WaitAvailable(b.execCtx); err != nil {
+		klog.V(4).Infof("Batcher %s stopped waiting for the rate limit: %v", b.options.Name, err)
+	}
+}
+
 func (b *Batcher[T, U]) runCalls(requests []*request[T, U]) {
 	klog.Infof("Batch size for label %v is %v", b.options.Name, len(requests))
-	requestIdx := 0
-	for _, result := range b.options.BatchExecutor(requests[0].ctx, lo.Map(requests, func(req *request[T, U], _ int) *T { return req.input })) {
-		requests[requestIdx].requestor <- result
-		requestIdx++
+	// The batch shouldn't be canceled by any single caller, so only the batcher's context can cancel the execution
+	ctx, cancel := context.WithCancel(context.WithoutCancel(requests[0].ctx))
+	defer cancel()
+	stop := context.AfterFunc(b.execCtx, cancel)
+	defer stop()
+	if b.options.BatchExecutorTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, b.options.BatchExecutorTimeout)
+		defer cancel()
+	}
+	// Trace the execution as a child of the first caller's span, linked to the spans of every other caller
+	ctx, span := otel.Tracer(tracerName).Start(ctx, fmt.Sprintf("batcher.%s.execute", b.options.Name),
+		trace.WithLinks(lo.Map(requests, func(req *request[T, U], _ int) trace.Link {
+			return trace.LinkFromContext(req.ctx)
+		})...),
+		trace.WithAttributes(attribute.Int("batch.size", len(requests))))
+	defer span.End()
+	probe, err := b.breaker.acquire()
+	if err != nil {
+		for _, req := range requests {
+			req.requestor <- Result[U]{Err: err}
+		}
+		return
+	}
+	inputs, groups := b.dedupe(ctx, requests)
+
+	var mu sync.Mutex
+	delivered := make([]bool, len(groups))
+	succeeded := false
+	successes := 0
+	// deliver returns the result of an input to the requests waiting on it, only the first result of an input is used
+	deliver := func(idx int, result Result[U]) {
+		mu.Lock()
+		if idx < 0 || idx >= len(groups) || delivered[idx] {
+			mu.Unlock()
+			return
+		}
+		delivered[idx] = true
+		succeeded = succeeded || result.Err == nil
+		if result.Err == nil {
+			successes++
+		}
+		mu.Unlock()
+		for _, req := range groups[idx] {
+			if b.options.RetryPolicy.shouldRetry(result.Err, req.attempts) && !b.isClosed() {
+				b.retry(req, result)
+				continue
+			}
+			req.requestor <- result
+		}
+	}
+
+	start := time.Now()
+	if b.options.StreamingBatchExecutor != nil {
+		b.await(ctx, func() { b.executeStreaming(ctx, inputs, deliver) })
+		recordBatch(b.options.Name, len(inputs), time.Since(start).Seconds())
+		mu.Lock()
+		b.breaker.record(probe, !succeeded)
+		b.recordErrors(len(groups), len(groups)-successes)
+		mu.Unlock()
+	} else {
+		// the executor may outlive an abandoned batch, so its results are passed through a channel
+		executed := make(chan []Result[U], 1)
+		b.await(ctx, func() { executed <- b.execute(ctx, inputs) })
+		recordBatch(b.options.Name, len(inputs), time.Since(start).Seconds())
+		var results []Result[U]
+		select {
+		case results = <-executed:
+		default:
+		}
+		b.breaker.record(probe, !lo.SomeBy(results, func(result Result[U]) bool { return result.Err == nil }))
+		b.recordErrors(len(groups), len(groups)-lo.CountBy(results[:min(len(results), len(groups))], func(result Result[U]) bool { return result.Err == nil }))
+		for idx, result := range results {
+			deliver(idx, result)
+		}
+	}
+	// any unmapped outputs should return a timeout error to the caller, results delivered later are dropped
+	mu.Lock()
+	var unmapped []*request[T, U]
+	for idx := range groups {
+		if !delivered[idx] {
+			delivered[idx] = true
+			unmapped = append(unmapped, groups[idx]...)
+		}
+	}
+	mu.Unlock()
+	for _, req := range unmapped {
+		req.requestor <- Result[U]{Err: &TimeoutError{Name: b.options.Name, Elapsed: time.Since(req.added)}}
+	}
+}
+
+func (b *Batcher[T, U]) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// retry re-enqueues a request after the RetryPolicy backoff. If the batcher is shutting down or closed the last
+// result is returned to the caller instead, as its batching loops no longer execute requests.
+func (b *Batcher[T, U]) retry(req *request[T, U], result Result[U]) {
+	delay := b.options.RetryPolicy.backoff(req.attempts)
+	req.attempts++
+	klog.V(4).Infof("Retrying item for label %v in %v after error, %v", b.options.Name, delay, result.Err)
+	time.AfterFunc(delay, func() {
+		// the caller has already given up on this request
+		if req.ctx.Err() != nil {
+			return
+		}
+		b.mu.Lock()
+		// a closed or shut down batcher doesn't execute requests anymore
+		if b.closed || b.ctx.Err() != nil {
+			b.mu.Unlock()
+			req.requestor <- result
+			return
+		}
+		b.requests[req.bucket] = append(b.requests[req.bucket], req)
+		full := b.takeFull(req.bucket)
+		recordQueuedItems(b.options.Name, 1-count(full))
+		trigger := b.trigger(req.bucket.window)
+		b.mu.Unlock()
+		b.dispatch(full)
+		// As in addBatch, the trigger is only a wakeup of the batching loop, so it never blocks
+		select {
+		case trigger <- struct{}{}:
+		default:
+		}
+		// the batching loops don't execute the queued requests once the batcher's context is done
+		if b.ctx.Err() != nil && b.remove(req) {
+			req.requestor <- result
+		}
+	})
+}
+
+// dedupe returns the inputs to execute along with the requests waiting on each input. Without a
+// RequestDeduplicator every request gets its own input.
+func (b *Batcher[T, U]) dedupe(ctx context.Context, requests []*request[T, U]) ([]*T, [][]*request[T, U]) {
+	if b.options.RequestDeduplicator == nil {
+		return lo.Map(requests, func(req *request[T, U], _ int) *T { return req.input }),
+			lo.Map(requests, func(req *request[T, U], _ int) []*request[T, U] { return []*request[T, U]{req} })
 	}
-	// any unmapped outputs should return an error to the caller
-	for ; requestIdx < len(requests); requestIdx++ {
-		requests[requestIdx].requestor <- Result[U]{Err: fmt.Errorf("error making call")}
+	var inputs []*T
+	var groups [][]*request[T, U]
+	index := map[uint64]int{}
+	for _, req := range requests {
+		key := b.options.RequestDeduplicator(ctx, req.input)
+		if idx, ok := index[key]; ok {
+			groups[idx] = append(groups[idx], req)
+			continue
+		}
+		index[key] = len(inputs)
+		inputs = append(inputs, req.input)
+		groups = append(groups, []*request[T, U]{req})
 	}
+	return inputs, groups
+}
+
+// await calls fn and waits for it to return. With a BatchExecutorTimeout it stops waiting once ctx expires, the
+// batch is abandoned and fn keeps running in the background.
+func (b *Batcher[T, U]) await(ctx context.Context, fn func()) {
+	if b.options.BatchExecutorTimeout <= 0 {
+		fn()
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		klog.Warningf("Batch executor for label %v did not return after its context was done, abandoning the batch, %v", b.options.Name, ctx.Err())
+	}
+}
+
+// execute calls the BatchExecutor, converting a panic into an error result for every input
+func (b *Batcher[T, U]) execute(ctx context.Context, inputs []*T) (results []Result[U]) {
+	defer func() {
+		if r := recover(); r != nil {
+			err := fmt.Errorf("batch executor panicked: %v\n%s", r, debug.Stack())
+			klog.Errorf("Batch executor for label %v panicked, %v", b.options.Name, err)
+			results = lo.Map(inputs, func(_ *T, _ int) Result[U] { return Result[U]{Err: err} })
+		}
+	}()
+	return b.options.BatchExecutor(ctx, inputs)
+}
+
+// executeStreaming calls the StreamingBatchExecutor, converting a panic into an error result for every input that
+// wasn't delivered yet
+func (b *Batcher[T, U]) executeStreaming(ctx context.Context, inputs []*T, deliver func(idx int, result Result[U])) {
+	defer func() {
+		if r := recover(); r != nil {
+			err := fmt.Errorf("batch executor panicked: %v\n%s", r, debug.Stack())
+			klog.Errorf("Batch executor for label %v panicked, %v", b.options.Name, err)
+			for idx := range inputs {
+				deliver(idx, Result[U]{Err: err})
+			}
+		}
+	}()
+	b.options.StreamingBatchExecutor(ctx, inputs, deliver)
 }
@@ -0,0 +1,150 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// CircuitBreakerPolicy configures a circuit breaker around the BatchExecutor. After FailureThreshold consecutive
+// failed batches the circuit opens, and items fail fast with ErrCircuitOpen for Cooldown instead of being executed.
+// After the Cooldown a single probe batch is executed, which closes the circuit when it succeeds and opens it again
+// when it fails. A batch fails when none of its items get a result without an error.
+type CircuitBreakerPolicy struct {
+	// FailureThreshold is the number of consecutive failed batches that opens the circuit
+	FailureThreshold int
+	// Cooldown is how long the circuit stays open before a probe batch is allowed
+	Cooldown time.Duration
+}
+
+// circuitState is the state of a circuit breaker
+type circuitState int
+
+const (
+	// circuitClosed executes every batch
+	circuitClosed circuitState = iota
+	// circuitOpen fails every item until the cooldown has passed
+	circuitOpen
+	// circuitHalfOpen executes a single probe batch, and fails other items while the probe is executing
+	circuitHalfOpen
+)
+
+// circuitBreaker tracks the failures of a batcher's executions, a nil circuitBreaker never opens
+type circuitBreaker struct {
+	name   string
+	policy CircuitBreakerPolicy
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	// probing is set while the probe batch of the half-open state is executing
+	probing bool
+}
+
+func newCircuitBreaker(name string, policy *CircuitBreakerPolicy) *circuitBreaker {
+	if policy == nil || policy.FailureThreshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{name: name, policy: *policy}
+}
+
+// allow returns ErrCircuitOpen when new items must fail fast instead of being queued
+func (c *circuitBreaker) allow() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.halfOpenAfterCooldown()
+	if c.state == circuitOpen || (c.state == circuitHalfOpen && c.probing) {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// acquire returns whether a batch may execute and whether it is the probe batch of the half-open state. Items queued
+// before the circuit opened are failed rather than executed.
+func (c *circuitBreaker) acquire() (probe bool, err error) {
+	if c == nil {
+		return false, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.halfOpenAfterCooldown()
+	switch {
+	case c.state == circuitOpen:
+		return false, ErrCircuitOpen
+	case c.state == circuitHalfOpen && c.probing:
+		return false, ErrCircuitOpen
+	case c.state == circuitHalfOpen:
+		c.probing = true
+		return true, nil
+	}
+	return false, nil
+}
+
+// record updates the circuit with the outcome of an executed batch
+func (c *circuitBreaker) record(probe bool, failed bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if probe {
+		c.probing = false
+		if failed {
+			c.open()
+		} else {
+			klog.Infof("Closing circuit of batcher %s after a successful probe", c.name)
+			c.state = circuitClosed
+			c.failures = 0
+		}
+		return
+	}
+	// batches that started before the circuit opened don't change its state
+	if c.state != circuitClosed {
+		return
+	}
+	if !failed {
+		c.failures = 0
+		return
+	}
+	c.failures++
+	if c.failures >= c.policy.FailureThreshold {
+		c.open()
+	}
+}
+
+// open opens the circuit for the cooldown, c.mu must be held
+func (c *circuitBreaker) open() {
+	klog.Warningf("Opening circuit of batcher %s for %v after failed batches", c.name, c.policy.Cooldown)
+	c.state = circuitOpen
+	c.openedAt = time.Now()
+	c.failures = 0
+}
+
+// halfOpenAfterCooldown moves an open circuit to half-open once the cooldown has passed, c.mu must be held
+func (c *circuitBreaker) halfOpenAfterCooldown() {
+	if c.state == circuitOpen && time.Since(c.openedAt) >= c.policy.Cooldown {
+		c.state = circuitHalfOpen
+		c.probing = false
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// defaultErrorRateWindow is the window the error rate is computed over when no AdaptiveConcurrencyPolicy sets one
+const defaultErrorRateWindow = time.Minute
+
+// AdaptiveConcurrencyPolicy adjusts the request workers of a batcher to the error rate of its executions with
+// additive increase and multiplicative decrease (AIMD). A batch with errors that leaves the error rate over Window
+// above ErrorRateThreshold multiplies the request workers by DecreaseFactor, down to MinRequestWorkers. A batch
+// without errors while the error rate is at or below the threshold adds a request worker back, up to
+// MaxRequestWorkers.
+type AdaptiveConcurrencyPolicy struct {
+	// Window is how far back the executed items count towards the error rate
+	Window time.Duration
+	// ErrorRateThreshold is the fraction of failed items, between 0 and 1, above which request workers are removed
+	ErrorRateThreshold float64
+	// DecreaseFactor is the fraction of the request workers kept on a decrease, it defaults to 0.5
+	DecreaseFactor float64
+	// MinRequestWorkers is the fewest request workers that are kept, it defaults to 1
+	MinRequestWorkers int
+}
+
+// errorRateSample is the outcome of the items of an executed batch
+type errorRateSample struct {
+	at     time.Time
+	items  int
+	errors int
+}
+
+// errorRateWindow computes the fraction of failed items over a sliding window of executed batches
+type errorRateWindow struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	samples []errorRateSample
+}
+
+func newErrorRateWindow(policy *AdaptiveConcurrencyPolicy) *errorRateWindow {
+	if policy != nil && policy.Window > 0 {
+		return &errorRateWindow{window: policy.Window}
+	}
+	return &errorRateWindow{window: defaultErrorRateWindow}
+}
+
+// record adds the outcome of a batch and returns the error rate including it
+func (w *errorRateWindow) record(items, errors int) float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.samples = append(w.samples, errorRateSample{at: time.Now(), items: items, errors: errors})
+	return w.rateLocked()
+}
+
+// rate returns the fraction of the items executed within the window that failed, zero when none were executed
+func (w *errorRateWindow) rate() float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rateLocked()
+}
+
+// rateLocked expires the samples that left the window and returns the error rate of the others, w.mu must be held
+func (w *errorRateWindow) rateLocked() float64 {
+	cutoff := time.Now().Add(-w.window)
+	for len(w.samples) > 0 && w.samples[0].at.Before(cutoff) {
+		w.samples = w.samples[1:]
+	}
+	items, errors := 0, 0
+	for _, sample := range w.samples {
+		items += sample.items
+		errors += sample.errors
+	}
+	if items == 0 {
+		return 0
+	}
+	return float64(errors) / float64(items)
+}
+
+// adaptiveConcurrency sets the limit of the request workers from the error rate, a nil adaptiveConcurrency leaves
+// the limit alone
+type adaptiveConcurrency struct {
+	name    string
+	policy  AdaptiveConcurrencyPolicy
+	workers *workerPool
+
+	mu    sync.Mutex
+	max   int
+	limit int
+}
+
+func newAdaptiveConcurrency(name string, policy *AdaptiveConcurrencyPolicy, workers *workerPool, maxWorkers int) *adaptiveConcurrency {
+	if policy == nil {
+		return nil
+	}
+	a := &adaptiveConcurrency{name: name, policy: *policy, workers: workers, max: maxWorkers, limit: maxWorkers}
+	if a.policy.DecreaseFactor <= 0 || a.policy.DecreaseFactor >= 1 {
+		a.policy.DecreaseFactor = 0.5
+	}
+	a.policy.MinRequestWorkers = max(a.policy.MinRequestWorkers, 1)
+	return a
+}
+
+// adjust removes request workers after a failed batch while the error rate is above the threshold, and adds one
+// back after a successful batch otherwise
+func (a *adaptiveConcurrency) adjust(failed bool, rate float64) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	limit := a.limit
+	switch {
+	case failed && rate > a.policy.ErrorRateThreshold:
+		limit = max(int(float64(a.limit)*a.policy.DecreaseFactor), min(a.policy.MinRequestWorkers, a.max))
+	case !failed && rate <= a.policy.ErrorRateThreshold:
+		limit = min(a.limit+1, a.max)
+	}
+	if limit == a.limit {
+		return
+	}
+	klog.V(2).Infof("Changing request workers of batcher %s from %d to %d at an error rate of %.2f", a.name, a.limit, limit, rate)
+	a.limit = limit
+	a.workers.SetLimit(limit)
+}
+
+// setMax changes the most request workers, the current limit is lowered to it but only raised by successful batches
+func (a *adaptiveConcurrency) setMax(n int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.max = n
+	a.limit = min(a.limit, n)
+	a.workers.SetLimit(a.limit)
+}
@@ -0,0 +1,47 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrBatchTimeout is matched by errors.Is when a batch closed without the executor producing a result for an item
+var ErrBatchTimeout = errors.New("batch timed out")
+
+// ErrBatcherClosed is returned for items added after the batcher was closed
+var ErrBatcherClosed = errors.New("batcher is closed")
+
+// ErrCircuitOpen is returned for items that fail fast because the circuit breaker of the batcher is open
+var ErrCircuitOpen = errors.New("batcher circuit is open")
+
+// TimeoutError is returned to a caller whose item did not get a result from the batch executor
+type TimeoutError struct {
+	Name    string
+	Elapsed time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("batcher %s returned no result after %v", e.Name, e.Elapsed)
+}
+
+// Is allows errors.Is(err, ErrBatchTimeout) to match a TimeoutError
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrBatchTimeout
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher
+
+import (
+	"sync"
+
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+const batcherNameLabel = "batcher"
+
+var (
+	queuedItems = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Name:           "cloudprovider_aws_batcher_queued_items",
+			Help:           "Number of items waiting to be dispatched to a batch executor.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{batcherNameLabel})
+
+	batchesExecuted = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Name:           "cloudprovider_aws_batcher_batches_total",
+			Help:           "Number of batches executed.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{batcherNameLabel})
+
+	batchSize = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Name:           "cloudprovider_aws_batcher_batch_size",
+			Help:           "Number of items passed to a single batch executor call.",
+			Buckets:        metrics.ExponentialBuckets(1, 2, 11), // 1 -> 1024
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{batcherNameLabel})
+
+	batchDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Name:           "cloudprovider_aws_batcher_batch_duration_seconds",
+			Help:           "Latency (in seconds) of a single batch executor call.",
+			StabilityLevel: metrics.ALPHA,
+		},
+		[]string{batcherNameLabel})
+)
+
+var register sync.Once
+
+// registerMetrics registers batcher metrics. Metrics are labeled by batcher name, so batchers sharing a name
+// share the same series.
+func registerMetrics() {
+	register.Do(func() {
+		legacyregistry.MustRegister(queuedItems)
+		legacyregistry.MustRegister(batchesExecuted)
+		legacyregistry.MustRegister(batchSize)
+		legacyregistry.MustRegister(batchDuration)
+	})
+}
+
+// recordQueuedItems adds delta to the queued items gauge. The batcher lock must be held, so that the gauge follows
+// the changes of the queued requests in order and never goes negative.
+func recordQueuedItems(name string, delta int) {
+	queuedItems.With(metrics.Labels{batcherNameLabel: name}).Add(float64(delta))
+}
+
+func recordBatch(name string, size int, seconds float64) {
+	batchesExecuted.With(metrics.Labels{batcherNameLabel: name}).Inc()
+	batchSize.With(metrics.Labels{batcherNameLabel: name}).Observe(float64(size))
+	batchDuration.With(metrics.Labels{batcherNameLabel: name}).Observe(seconds)
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// registryKey identifies a shared batcher, batchers of different input and output types can share a name
+type registryKey struct {
+	name string
+	typ  reflect.Type
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[registryKey]any{}
+)
+
+// GetOrCreate returns the batcher shared by every caller with the same Options.Name and input and output types,
+// creating it with ctx and options for the first caller. The options of later callers are ignored. A shared
+// batcher that has been closed is replaced by a new one.
+func GetOrCreate[T input, U output](ctx context.Context, options Options[T, U]) *Batcher[T, U] {
+	key := registryKey{name: options.Name, typ: reflect.TypeFor[*Batcher[T, U]]()}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if b, ok := registry[key].(*Batcher[T, U]); ok && !b.isClosed() {
+		return b
+	}
+	b := NewBatcher(ctx, options)
+	registry[key] = b
+	return b
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// RetryPolicy configures how items that failed with a retryable error are re-enqueued into the batcher
+type RetryPolicy struct {
+	// MaxRetries is the number of times an item is re-enqueued before its error is returned to the caller
+	MaxRetries int
+	// BaseDelay is the delay before the first retry, it doubles on every following retry
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries, zero means no cap
+	MaxDelay time.Duration
+	// IsRetryable decides whether an error returned by the BatchExecutor should be retried
+	IsRetryable func(error) bool
+}
+
+// shouldRetry returns true when an item that has been retried attempts times should be retried again
+func (p *RetryPolicy) shouldRetry(err error, attempts int) bool {
+	return p != nil && err != nil && p.IsRetryable != nil && attempts < p.MaxRetries && p.IsRetryable(err)
+}
+
+// backoff returns the jittered exponential delay before the given retry attempt
+func (p *RetryPolicy) backoff(attempts int) time.Duration {
+	delay := p.BaseDelay << attempts
+	if delay < p.BaseDelay || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	return wait.Jitter(delay/2, 1)
+}
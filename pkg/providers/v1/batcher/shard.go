@@ -0,0 +1,47 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher
+
+import (
+	"github.com/samber/lo"
+)
+
+// shard breaks the requests of a bucket into one shard per ShardItems requests by their ShardHasher, so the shards
+// are executed concurrently. Requests with an equal hash always share a shard.
+func (b *Batcher[T, U]) shard(requests []*request[T, U]) [][]*request[T, U] {
+	if b.options.ShardItems <= 0 || len(requests) <= b.options.ShardItems {
+		return [][]*request[T, U]{requests}
+	}
+	shards := make([][]*request[T, U], (len(requests)+b.options.ShardItems-1)/b.options.ShardItems)
+	for _, request := range requests {
+		i := jumpHash(b.options.ShardHasher(request.ctx, request.input), len(shards))
+		shards[i] = append(shards[i], request)
+	}
+	return lo.Filter(shards, func(shard []*request[T, U], _ int) bool { return len(shard) > 0 })
+}
+
+// jumpHash is the jump consistent hash of Lamping and Veach, it maps a key to one of n shards evenly and moves
+// only 1/n of the keys when a shard is added, so an item's shard barely changes as the size of its key varies
+func jumpHash(key uint64, n int) int {
+	var b, j int64 = -1, 0
+	for j < int64(n) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
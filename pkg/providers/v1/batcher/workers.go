@@ -0,0 +1,104 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package batcher
+
+import (
+	"slices"
+	"sync"
+)
+
+// workerPool runs functions on goroutines while limiting how many run concurrently. Unlike an errgroup.Group,
+// the limit can be changed while functions are running, and functions waiting for a free worker are started
+// in priority order.
+type workerPool struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	limit   int
+	active  int
+	pending []func()
+	// priorities holds the priority of each pending function, pending is kept sorted by priority and then
+	// by insertion order
+	priorities []int
+}
+
+func newWorkerPool(limit int) *workerPool {
+	p := &workerPool{limit: limit}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// Go queues f to run on a new goroutine once fewer than limit functions are active. Queued functions with a
+// higher priority are started first.
+func (p *workerPool) Go(priority int, f func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// insert after every function with the same or a higher priority
+	idx, _ := slices.BinarySearchFunc(p.priorities, priority, func(e, target int) int {
+		if e >= target {
+			return -1
+		}
+		return 1
+	})
+	p.pending = slices.Insert(p.pending, idx, f)
+	p.priorities = slices.Insert(p.priorities, idx, priority)
+	p.dispatch()
+}
+
+// dispatch starts pending functions while there are free workers, p.mu must be held
+func (p *workerPool) dispatch() {
+	for p.active < p.limit && len(p.pending) > 0 {
+		f := p.pending[0]
+		p.pending = p.pending[1:]
+		p.priorities = p.priorities[1:]
+		p.active++
+		go func() {
+			defer func() {
+				p.mu.Lock()
+				p.active--
+				p.dispatch()
+				p.mu.Unlock()
+				p.cond.Broadcast()
+			}()
+			f()
+		}()
+	}
+}
+
+// SetLimit changes the number of functions allowed to run concurrently. Functions that are already
+// running are allowed to finish when the limit is lowered.
+func (p *workerPool) SetLimit(limit int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.limit = limit
+	p.dispatch()
+}
+
+// Limit returns the number of functions allowed to run concurrently
+func (p *workerPool) Limit() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.limit
+}
+
+// Wait blocks until all queued and active functions have returned
+func (p *workerPool) Wait() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.active > 0 || len(p.pending) > 0 {
+		p.cond.Wait()
+	}
+}
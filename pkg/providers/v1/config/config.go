@@ -3,9 +3,11 @@ package config
 import (
 	"context"
 	"fmt"
+	"math"
 	"net/url"
 
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go/aws/endpoints"
@@ -55,6 +57,12 @@ type CloudConfig struct {
 		// condition context keys in your role trust policy to limit access to the role to only requests that are generated
 		// by expected resources. https://docs.aws.amazon.com/IAM/latest/UserGuide/confused-deputy.html
 		SourceARN string
+		// RoleSessionName is the session name used when assuming the role specified by RoleARN, it identifies the
+		// cloud provider in CloudTrail. A session name is generated when it is not set.
+		RoleSessionName string
+		// RoleExternalID is the external ID passed when assuming the role specified by RoleARN, for roles in another
+		// account whose trust policy requires one.
+		RoleExternalID string
 
 		// KubernetesClusterTag is the legacy cluster id we'll use to identify our cluster resources
 		KubernetesClusterTag string
@@ -70,6 +78,11 @@ type CloudConfig struct {
 		//local VPC subnet (so load balancers can access it). E.g. 10.82.0.0/16 30000-32000.
 		DisableSecurityGroupIngress bool
 
+		// DisableSourceDestCheckManagement leaves the source/destination check of instances as it is when routes
+		// are created to them. Instances only forward pod traffic when the check is disabled, e.g. by the tool
+		// that launches them.
+		DisableSourceDestCheckManagement bool `json:"disableSourceDestCheckManagement,omitempty" yaml:"disableSourceDestCheckManagement,omitempty"`
+
 		//AWS has a hard limit of 500 security groups. For large clusters creating a security group for each ELB
 		//can cause the max number of security groups to be reached. If this is set instead of creating a new
 		//Security group for each ELB this security group will be used instead.
@@ -97,6 +110,69 @@ type CloudConfig struct {
 		//
 		// WARNING: Updating the default behavior and corresponding unit tests would be a much safer option.
 		SupportedTopologyInstanceTypePattern string `json:"supportedTopologyInstanceTypePattern,omitempty" yaml:"supportedTopologyInstanceTypePattern,omitempty"`
+
+		// InstanceCacheTTL is how long instances looked up by instance ID are cached, e.g. "30s".
+		// Instances are not cached when unset.
+		InstanceCacheTTL string `json:"instanceCacheTTL,omitempty" yaml:"instanceCacheTTL,omitempty"`
+
+		// NodeAddressCacheTTL is how long the node addresses of instances are cached, e.g. "30s".
+		// Node addresses are not cached when unset.
+		NodeAddressCacheTTL string `json:"nodeAddressCacheTTL,omitempty" yaml:"nodeAddressCacheTTL,omitempty"`
+
+		// InstanceStateChangeQueueURL is the URL of an SQS queue that an EventBridge rule sends EC2 instance state
+		// change notifications to. Cached instances are invalidated when they stop or terminate rather than when
+		// their TTL expires. Events are not consumed when unset.
+		InstanceStateChangeQueueURL string `json:"instanceStateChangeQueueURL,omitempty" yaml:"instanceStateChangeQueueURL,omitempty"`
+
+		// DeregisterTargetsBatchIdleTimeout is how long NLB target deregistrations wait for more deregistrations
+		// from the same target group before they are sent in a single call, e.g. "200ms". Defaults to 100ms.
+		DeregisterTargetsBatchIdleTimeout string `json:"deregisterTargetsBatchIdleTimeout,omitempty" yaml:"deregisterTargetsBatchIdleTimeout,omitempty"`
+
+		// SecurityGroupFilterTags are tags, as "key=value", that security groups must have in addition to the cluster
+		// tag to be discovered as security groups of the cluster, e.g. to tell clusters apart in a shared VPC.
+		SecurityGroupFilterTags []string `json:"securityGroupFilterTags,omitempty" yaml:"securityGroupFilterTags,omitempty"`
+
+		// Instance metadata is requested with IMDSv2 session tokens. EnableIMDSv1Fallback allows falling back
+		// to IMDSv1 requests when a token can't be retrieved, e.g. when the hop limit is too low.
+		EnableIMDSv1Fallback bool `json:"enableIMDSv1Fallback,omitempty" yaml:"enableIMDSv1Fallback,omitempty"`
+
+		// EnableSecondaryIPNodeAddresses reports the secondary private IPs of the network interfaces of an instance as
+		// InternalIP node addresses, in addition to the primary private IP of each network interface.
+		EnableSecondaryIPNodeAddresses bool `json:"enableSecondaryIPNodeAddresses,omitempty" yaml:"enableSecondaryIPNodeAddresses,omitempty"`
+
+		// EnableAPICallMetrics counts the calls to the EC2 and ELB APIs by API name and result, and records their
+		// latency, to help diagnose throttling.
+		EnableAPICallMetrics bool `json:"enableAPICallMetrics,omitempty" yaml:"enableAPICallMetrics,omitempty"`
+
+		// EnableLoadBalancerDryRun logs the changes load balancer reconciliation would make to load balancers,
+		// listeners, target groups and security groups instead of making them.
+		EnableLoadBalancerDryRun bool `json:"enableLoadBalancerDryRun,omitempty" yaml:"enableLoadBalancerDryRun,omitempty"`
+
+		// EnableNLBSecurityGroups creates NLBs with a security group managed for each load balancer, that allows the
+		// traffic from the load balancer source ranges to the listener ports. Security groups can only be attached to
+		// NLBs created with them, and aren't supported in every region.
+		EnableNLBSecurityGroups bool `json:"enableNLBSecurityGroups,omitempty" yaml:"enableNLBSecurityGroups,omitempty"`
+
+		// EnableCordonedNodeDeregistration deregisters the instances of cordoned nodes from classic ELBs, so they stop
+		// receiving traffic before they are drained, and registers them again once the nodes are uncordoned.
+		EnableCordonedNodeDeregistration bool `json:"enableCordonedNodeDeregistration,omitempty" yaml:"enableCordonedNodeDeregistration,omitempty"`
+
+		// InstanceIDLabel is a node label holding the ID of the instance backing the node, for nodes whose provider
+		// IDs don't hold the instance ID, e.g. hybrid or Outposts nodes registered by other tooling. Nodes without
+		// the label are mapped to instances by their provider IDs.
+		InstanceIDLabel string `json:"instanceIDLabel,omitempty" yaml:"instanceIDLabel,omitempty"`
+
+		// ResourceTags are tags, as "key=value", added to every load balancer, target group and security group
+		// the cloud provider creates, e.g. for cost allocation. The cluster tags and the tags of the service
+		// annotations take precedence.
+		ResourceTags []string `json:"resourceTags,omitempty" yaml:"resourceTags,omitempty"`
+
+		// APIRateLimitQPS limits the rate of calls to the EC2 and ELB APIs, shared by all clients and batchers, to stay
+		// within the account's API limits during mass reconciles. Zero disables the limit.
+		APIRateLimitQPS float64 `json:"apiRateLimitQPS,omitempty" yaml:"apiRateLimitQPS,omitempty"`
+		// APIRateLimitBurst is the number of calls that can be made at once before APIRateLimitQPS applies, it
+		// defaults to APIRateLimitQPS rounded up.
+		APIRateLimitBurst int `json:"apiRateLimitBurst,omitempty" yaml:"apiRateLimitBurst,omitempty"`
 	}
 	// [ServiceOverride "1"]
 	//  Service = s3
@@ -111,6 +187,10 @@ type CloudConfig struct {
 	//     URL = https://ec2.foo.bar
 	//     SigningRegion = signing_region
 	//     SigningMethod = signing_method
+	//
+	//  [ServiceOverride "3"]
+	//     Service = ec2metadata
+	//     URL = http://metadata.foo.bar
 	ServiceOverride map[string]*struct {
 		Service       string
 		Region        string
@@ -161,7 +241,8 @@ func (cfg *CloudConfig) ValidateOverrides() error {
 		ovrd.Service = name
 
 		region := strings.TrimSpace(ovrd.Region)
-		if region == "" {
+		// The metadata service is not regional, so its override applies to all regions
+		if region == "" && !isMetadataService(name) {
 			return fmt.Errorf("service region is missing [Region is \"\"] in override %s", onum)
 		}
 		// insure the map region is space trimmed
@@ -172,7 +253,7 @@ func (cfg *CloudConfig) ValidateOverrides() error {
 			return fmt.Errorf("url is missing [URL is \"\"] in override %s", onum)
 		}
 		signingRegion := strings.TrimSpace(ovrd.SigningRegion)
-		if signingRegion == "" {
+		if signingRegion == "" && !isMetadataService(name) {
 			return fmt.Errorf("signingRegion is missing [SigningRegion is \"\"] in override %s", onum)
 		}
 		signature := name + "_" + region
@@ -184,6 +265,122 @@ func (cfg *CloudConfig) ValidateOverrides() error {
 	return nil
 }
 
+// GetInstanceCacheTTL parses InstanceCacheTTL, it returns zero when unset
+func (cfg *CloudConfig) GetInstanceCacheTTL() (time.Duration, error) {
+	return parseDuration("InstanceCacheTTL", cfg.Global.InstanceCacheTTL)
+}
+
+// GetNodeAddressCacheTTL parses NodeAddressCacheTTL, it returns zero when unset
+func (cfg *CloudConfig) GetNodeAddressCacheTTL() (time.Duration, error) {
+	return parseDuration("NodeAddressCacheTTL", cfg.Global.NodeAddressCacheTTL)
+}
+
+// GetSecurityGroupFilterTags parses SecurityGroupFilterTags into a map of tag keys to values
+func (cfg *CloudConfig) GetSecurityGroupFilterTags() (map[string]string, error) {
+	return parseTags("SecurityGroupFilterTags", cfg.Global.SecurityGroupFilterTags)
+}
+
+// GetResourceTags parses ResourceTags into a map of tag keys to values
+func (cfg *CloudConfig) GetResourceTags() (map[string]string, error) {
+	return parseTags("ResourceTags", cfg.Global.ResourceTags)
+}
+
+func parseTags(name string, list []string) (map[string]string, error) {
+	tags := make(map[string]string, len(list))
+	for _, tag := range list {
+		key, value, found := strings.Cut(tag, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid %s entry %q: must be key=value", name, tag)
+		}
+		tags[key] = strings.TrimSpace(value)
+	}
+	return tags, nil
+}
+
+// DefaultDeregisterTargetsBatchIdleTimeout is the batching idle timeout of NLB target deregistrations
+const DefaultDeregisterTargetsBatchIdleTimeout = 100 * time.Millisecond
+
+// GetDeregisterTargetsBatchIdleTimeout parses DeregisterTargetsBatchIdleTimeout, it returns the default when unset or zero
+func (cfg *CloudConfig) GetDeregisterTargetsBatchIdleTimeout() (time.Duration, error) {
+	timeout, err := parseDuration("DeregisterTargetsBatchIdleTimeout", cfg.Global.DeregisterTargetsBatchIdleTimeout)
+	if err != nil || timeout > 0 {
+		return timeout, err
+	}
+	return DefaultDeregisterTargetsBatchIdleTimeout, nil
+}
+
+func parseDuration(name, value string) (time.Duration, error) {
+	if strings.TrimSpace(value) == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: %w", name, value, err)
+	}
+	if ttl < 0 {
+		return 0, fmt.Errorf("invalid %s %q: must not be negative", name, value)
+	}
+	return ttl, nil
+}
+
+// GetIMDSv1FallbackEnabled returns whether instance metadata requests may fall back to IMDSv1
+func (cfg *CloudConfig) GetIMDSv1FallbackEnabled() bool {
+	return cfg.Global.EnableIMDSv1Fallback
+}
+
+// GetSecondaryIPNodeAddressesEnabled returns whether secondary private IPs are reported as node addresses
+func (cfg *CloudConfig) GetSecondaryIPNodeAddressesEnabled() bool {
+	return cfg.Global.EnableSecondaryIPNodeAddresses
+}
+
+// GetAPICallMetricsEnabled returns whether calls to the EC2 and ELB APIs are counted by API name
+func (cfg *CloudConfig) GetAPICallMetricsEnabled() bool {
+	return cfg.Global.EnableAPICallMetrics
+}
+
+// GetLoadBalancerDryRunEnabled returns whether load balancer changes are logged instead of made
+func (cfg *CloudConfig) GetLoadBalancerDryRunEnabled() bool {
+	return cfg.Global.EnableLoadBalancerDryRun
+}
+
+// GetNLBSecurityGroupsEnabled returns whether a security group is managed for NLBs
+func (cfg *CloudConfig) GetNLBSecurityGroupsEnabled() bool {
+	return cfg.Global.EnableNLBSecurityGroups
+}
+
+// GetCordonedNodeDeregistrationEnabled returns whether cordoned nodes are deregistered from classic ELBs
+func (cfg *CloudConfig) GetCordonedNodeDeregistrationEnabled() bool {
+	return cfg.Global.EnableCordonedNodeDeregistration
+}
+
+// GetAPIRateLimit returns the QPS and burst of the rate limit of calls to the EC2 and ELB APIs, a QPS of zero means
+// calls aren't limited
+func (cfg *CloudConfig) GetAPIRateLimit() (float64, int) {
+	qps := max(cfg.Global.APIRateLimitQPS, 0)
+	burst := cfg.Global.APIRateLimitBurst
+	if burst <= 0 {
+		burst = int(math.Ceil(qps))
+	}
+	return qps, burst
+}
+
+// metadataServiceName is the service name the SDK resolves the instance metadata endpoint for
+const metadataServiceName = "ec2metadata"
+
+func isMetadataService(service string) bool {
+	return strings.EqualFold(service, metadataServiceName)
+}
+
+// overrideMatches reports whether a service override applies to a service in a region. Service names are compared
+// case-insensitively because the SDKs use different casing, e.g. "ec2" in aws-sdk-go and "EC2" in aws-sdk-go-v2.
+func overrideMatches(overrideService, overrideRegion, service, region string) bool {
+	if !strings.EqualFold(overrideService, service) {
+		return false
+	}
+	return overrideRegion == region || (isMetadataService(service) && overrideRegion == "")
+}
+
 // GetResolver computes the correct resolver to use
 func (cfg *CloudConfig) GetResolver() endpoints.ResolverFunc {
 	defaultResolver := endpoints.DefaultResolver()
@@ -198,7 +395,7 @@ func (cfg *CloudConfig) GetResolver() endpoints.ResolverFunc {
 	return func(service, region string,
 		optFns ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
 		for _, override := range cfg.ServiceOverride {
-			if override.Service == service && override.Region == region {
+			if overrideMatches(override.Service, override.Region, service, region) {
 				return endpoints.ResolvedEndpoint{
 					URL:           override.URL,
 					SigningRegion: override.SigningRegion,
@@ -217,7 +414,7 @@ func (cfg *CloudConfig) GetResolver() endpoints.ResolverFunc {
 func (cfg *CloudConfig) GetEC2EndpointOpts(region string) []func(*ec2.Options) {
 	opts := []func(*ec2.Options){}
 	for _, override := range cfg.ServiceOverride {
-		if override.Service == ec2.ServiceID && override.Region == region {
+		if overrideMatches(override.Service, override.Region, ec2.ServiceID, region) {
 			opts = append(opts,
 				ec2.WithSigV4SigningName(override.SigningName),
 				ec2.WithSigV4SigningRegion(override.SigningRegion),
@@ -250,7 +447,7 @@ func (r *EC2Resolver) ResolveEndpoint(
 	endpoint smithyendpoints.Endpoint, err error,
 ) {
 	for _, override := range r.Cfg.ServiceOverride {
-		if override.Service == ec2.ServiceID && override.Region == aws.ToString(params.Region) {
+		if overrideMatches(override.Service, override.Region, ec2.ServiceID, aws.ToString(params.Region)) {
 			customURL, err := url.Parse(override.URL)
 			if err != nil {
 				return smithyendpoints.Endpoint{}, fmt.Errorf("could not parse override URL, %w", err)
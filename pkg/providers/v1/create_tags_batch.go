@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/mitchellh/hashstructure/v2"
+	"github.com/samber/lo"
 	"k8s.io/cloud-provider-aws/pkg/providers/v1/batcher"
 	"k8s.io/cloud-provider-aws/pkg/providers/v1/iface"
 	"k8s.io/klog/v2"
@@ -36,7 +37,7 @@ type createTagsBatcher struct {
 }
 
 // newCreateTagsBatcher creates a newCreateTagsBatcher object
-func newCreateTagsBatcher(ctx context.Context, ec2api iface.EC2) *createTagsBatcher {
+func newCreateTagsBatcher(ctx context.Context, ec2api iface.EC2, rateLimiter batcher.RateLimiter) *createTagsBatcher {
 	options := batcher.Options[ec2.CreateTagsInput, ec2.CreateTagsOutput]{
 		Name:          "create_tags",
 		IdleTimeout:   100 * time.Millisecond,
@@ -44,6 +45,7 @@ func newCreateTagsBatcher(ctx context.Context, ec2api iface.EC2) *createTagsBatc
 		MaxItems:      50,
 		RequestHasher: createTagsHasher,
 		BatchExecutor: execCreateTagsBatch(ctx, ec2api),
+		RateLimiter:   rateLimiter,
 	}
 	return &createTagsBatcher{batcher: batcher.NewBatcher(ctx, options)}
 }
@@ -70,14 +72,15 @@ func createTagsHasher(ctx context.Context, input *ec2.CreateTagsInput) uint64 {
 func execCreateTagsBatch(ctx context.Context, ec2api iface.EC2) batcher.BatchExecutor[ec2.CreateTagsInput, ec2.CreateTagsOutput] {
 	return func(ctx context.Context, inputs []*ec2.CreateTagsInput) []batcher.Result[ec2.CreateTagsOutput] {
 		results := make([]batcher.Result[ec2.CreateTagsOutput], len(inputs))
-		firstInput := inputs[0]
-		// aggregate instanceIDs into 1 input
-		for _, input := range inputs[1:] {
-			firstInput.Resources = append(firstInput.Resources, input.Resources...)
+		// aggregate resource IDs into 1 input, the inputs are left unchanged so they can be retried individually
+		var resources []string
+		for _, input := range inputs {
+			resources = append(resources, input.Resources...)
 		}
 		batchedInput := &ec2.CreateTagsInput{
-			Resources: firstInput.Resources,
-			Tags:      firstInput.Tags,
+			// the same resource may be tagged by several reconcilers at once
+			Resources: lo.Uniq(resources),
+			Tags:      inputs[0].Tags,
 		}
 		klog.Infof("Batched create tags %v", batchedInput)
 		output, err := ec2api.CreateTags(ctx, batchedInput)
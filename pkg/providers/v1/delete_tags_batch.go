@@ -36,7 +36,7 @@ type deleteTagsBatcher struct {
 }
 
 // newDeleteTagsBatcher creates a newDeleteTagsBatcher object
-func newDeleteTagsBatcher(ctx context.Context, ec2api iface.EC2) *deleteTagsBatcher {
+func newDeleteTagsBatcher(ctx context.Context, ec2api iface.EC2, rateLimiter batcher.RateLimiter) *deleteTagsBatcher {
 	options := batcher.Options[ec2.DeleteTagsInput, ec2.DeleteTagsOutput]{
 		Name:          "delete_tags",
 		IdleTimeout:   100 * time.Millisecond,
@@ -44,6 +44,7 @@ func newDeleteTagsBatcher(ctx context.Context, ec2api iface.EC2) *deleteTagsBatc
 		MaxItems:      50,
 		RequestHasher: deleteTagsHasher,
 		BatchExecutor: execDeleteTagsBatch(ctx, ec2api),
+		RateLimiter:   rateLimiter,
 	}
 	return &deleteTagsBatcher{batcher: batcher.NewBatcher(ctx, options)}
 }
@@ -0,0 +1,112 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/mitchellh/hashstructure/v2"
+	"github.com/samber/lo"
+	"k8s.io/cloud-provider-aws/pkg/providers/v1/batcher"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// deregisterTargetsBatcher contains the batcher details
+type deregisterTargetsBatcher struct {
+	batcher *batcher.Batcher[elbv2.DeregisterTargetsInput, elbv2.DeregisterTargetsOutput]
+}
+
+// newDeregisterTargetsBatcher creates a deregisterTargetsBatcher object, deregistrations from the same target group
+// that arrive within idleTimeout of each other are sent in a single call
+func newDeregisterTargetsBatcher(ctx context.Context, elbv2api ELBV2, idleTimeout time.Duration, rateLimiter batcher.RateLimiter) *deregisterTargetsBatcher {
+	options := batcher.Options[elbv2.DeregisterTargetsInput, elbv2.DeregisterTargetsOutput]{
+		Name:          "deregister_targets",
+		IdleTimeout:   idleTimeout,
+		MaxTimeout:    max(idleTimeout, 1*time.Second),
+		MaxItems:      defaultDeregisterTargetsChunkSize,
+		RequestHasher: deregisterTargetsHasher,
+		BatchExecutor: execDeregisterTargetsBatch(elbv2api),
+		RateLimiter:   rateLimiter,
+	}
+	return &deregisterTargetsBatcher{batcher: batcher.NewBatcher(ctx, options)}
+}
+
+// DeregisterTargets adds deregister targets input to batcher
+func (b *deregisterTargetsBatcher) DeregisterTargets(ctx context.Context, input *elbv2.DeregisterTargetsInput) (*elbv2.DeregisterTargetsOutput, error) {
+	result := b.batcher.Add(ctx, input)
+	return result.Output, result.Err
+}
+
+// deregisterTargetsHasher generates hash for different deregister targets inputs
+// Inputs for the same target group have same hash, so they get executed together
+func deregisterTargetsHasher(ctx context.Context, input *elbv2.DeregisterTargetsInput) uint64 {
+	hash, err := hashstructure.Hash(aws.StringValue(input.TargetGroupArn), hashstructure.FormatV2, nil)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed hashing input target group")
+	}
+	return hash
+}
+
+func execDeregisterTargetsBatch(elbv2api ELBV2) batcher.BatchExecutor[elbv2.DeregisterTargetsInput, elbv2.DeregisterTargetsOutput] {
+	return func(ctx context.Context, inputs []*elbv2.DeregisterTargetsInput) []batcher.Result[elbv2.DeregisterTargetsOutput] {
+		results := make([]batcher.Result[elbv2.DeregisterTargetsOutput], len(inputs))
+		// aggregate the targets of all inputs, the same target may be deregistered by several callers
+		targets := lo.UniqBy(lo.FlatMap(inputs, func(input *elbv2.DeregisterTargetsInput, _ int) []*elbv2.TargetDescription {
+			return input.Targets
+		}), func(target *elbv2.TargetDescription) string {
+			return aws.StringValue(target.Id) + ":" + aws.StringValue(target.AvailabilityZone) + ":" + strconv.FormatInt(aws.Int64Value(target.Port), 10)
+		})
+
+		var err error
+		var output *elbv2.DeregisterTargetsOutput
+		for _, chunk := range lo.Chunk(targets, defaultDeregisterTargetsChunkSize) {
+			batchedInput := &elbv2.DeregisterTargetsInput{
+				TargetGroupArn: inputs[0].TargetGroupArn,
+				Targets:        chunk,
+			}
+			klog.Infof("Batched deregister targets %v", batchedInput)
+			if output, err = elbv2api.DeregisterTargets(batchedInput); err != nil {
+				break
+			}
+		}
+
+		if err != nil {
+			klog.Errorf("Error occurred trying to batch deregister targets, trying individually, %v", err)
+			var wg sync.WaitGroup
+			for idx, input := range inputs {
+				wg.Add(1)
+				go func(input *elbv2.DeregisterTargetsInput) {
+					defer wg.Done()
+					out, err := elbv2api.DeregisterTargets(input)
+					results[idx] = batcher.Result[elbv2.DeregisterTargetsOutput]{Output: out, Err: err}
+				}(input)
+			}
+			wg.Wait()
+		} else {
+			for idx := range inputs {
+				results[idx] = batcher.Result[elbv2.DeregisterTargetsOutput]{Output: output}
+			}
+		}
+		return results
+	}
+}
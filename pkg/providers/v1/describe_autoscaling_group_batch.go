@@ -0,0 +1,190 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	"k8s.io/cloud-provider-aws/pkg/providers/v1/batcher"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+)
+
+// TagNameAutoScalingGroupName is the tag that EC2 auto scaling sets on the instances of an auto scaling group
+const TagNameAutoScalingGroupName = "aws:autoscaling:groupName"
+
+// autoScalingGroupCacheTTL is how long described auto scaling groups are cached, membership changes quickly while
+// a group scales, so it is kept short
+const autoScalingGroupCacheTTL = 10 * time.Second
+
+// maxAutoScalingGroupNamesPerCall is the most group names DescribeAutoScalingGroups accepts in a single call
+const maxAutoScalingGroupNamesPerCall = 100
+
+// describeAutoScalingGroupBatcher contains the batcher details
+type describeAutoScalingGroupBatcher struct {
+	batcher *batcher.Batcher[string, autoscaling.Group]
+	cache   *autoScalingGroupCache
+}
+
+// newDescribeAutoScalingGroupBatcher creates a describeAutoScalingGroupBatcher object
+func newDescribeAutoScalingGroupBatcher(ctx context.Context, asg ASG) *describeAutoScalingGroupBatcher {
+	options := batcher.Options[string, autoscaling.Group]{
+		Name:             "describe_autoscaling_group",
+		IdleTimeout:      100 * time.Millisecond,
+		MaxTimeout:       1 * time.Second,
+		MaxItems:         500,
+		MaxItemsPerBatch: maxAutoScalingGroupNamesPerCall,
+		// All lookups are by group name, so they can always be executed together
+		RequestHasher:       batcher.OneBucketHasher[string],
+		RequestDeduplicator: batcher.DefaultHasher[string],
+		BatchExecutor:       execDescribeAutoScalingGroupBatch(asg),
+	}
+	return &describeAutoScalingGroupBatcher{
+		batcher: batcher.NewBatcher(ctx, options),
+		cache:   &autoScalingGroupCache{ttl: autoScalingGroupCacheTTL, clock: clock.RealClock{}, entries: map[string]autoScalingGroupCacheEntry{}},
+	}
+}
+
+// DescribeAutoScalingGroup adds an auto scaling group name to the batcher, it returns nil if the group is not found
+func (b *describeAutoScalingGroupBatcher) DescribeAutoScalingGroup(ctx context.Context, name string) (*autoscaling.Group, error) {
+	if group, ok := b.cache.get(name); ok {
+		return group, nil
+	}
+	result := b.batcher.Add(ctx, &name)
+	if result.Err == nil && result.Output != nil {
+		b.cache.set(name, result.Output)
+	}
+	return result.Output, result.Err
+}
+
+func execDescribeAutoScalingGroupBatch(asg ASG) batcher.BatchExecutor[string, autoscaling.Group] {
+	return func(ctx context.Context, inputs []*string) []batcher.Result[autoscaling.Group] {
+		results := make([]batcher.Result[autoscaling.Group], len(inputs))
+		request := &autoscaling.DescribeAutoScalingGroupsInput{
+			AutoScalingGroupNames: aws.StringSlice(lo.FromSlicePtr(inputs)),
+		}
+		klog.Infof("Batched describe autoscaling groups %v", request)
+		groups := map[string]*autoscaling.Group{}
+		for {
+			output, err := asg.DescribeAutoScalingGroups(request)
+			if err != nil {
+				return lo.Map(inputs, func(_ *string, _ int) batcher.Result[autoscaling.Group] {
+					return batcher.Result[autoscaling.Group]{Err: err}
+				})
+			}
+			for _, group := range output.AutoScalingGroups {
+				groups[aws.StringValue(group.AutoScalingGroupName)] = group
+			}
+			if aws.StringValue(output.NextToken) == "" {
+				break
+			}
+			request.NextToken = output.NextToken
+		}
+		// Groups that don't exist are left without an output
+		for idx, input := range inputs {
+			results[idx] = batcher.Result[autoscaling.Group]{Output: groups[*input]}
+		}
+		return results
+	}
+}
+
+type autoScalingGroupCacheEntry struct {
+	group   *autoscaling.Group
+	expires time.Time
+}
+
+// autoScalingGroupCache caches auto scaling groups by name for a TTL
+type autoScalingGroupCache struct {
+	ttl   time.Duration
+	clock clock.Clock
+
+	mu      sync.Mutex
+	entries map[string]autoScalingGroupCacheEntry
+}
+
+// get returns the cached group if it hasn't expired
+func (c *autoScalingGroupCache) get(name string) (*autoscaling.Group, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[name]
+	if !ok {
+		return nil, false
+	}
+	if !c.clock.Now().Before(entry.expires) {
+		delete(c.entries, name)
+		return nil, false
+	}
+	return entry.group, true
+}
+
+// set caches a group until the TTL expires
+func (c *autoScalingGroupCache) set(name string, group *autoscaling.Group) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[name] = autoScalingGroupCacheEntry{group: group, expires: c.clock.Now().Add(c.ttl)}
+}
+
+// getAutoScalingGroupInstances returns the auto scaling group instance of each instance that belongs to an auto
+// scaling group, keyed by instance ID. The groups of the instances are looked up concurrently, so they are described
+// together.
+func (c *Cloud) getAutoScalingGroupInstances(ctx context.Context, instances []*ec2types.Instance) (map[string]*autoscaling.Instance, error) {
+	groupNames := map[string]string{}
+	for _, instance := range instances {
+		for _, tag := range instance.Tags {
+			if aws.StringValue(tag.Key) == TagNameAutoScalingGroupName {
+				groupNames[aws.StringValue(instance.InstanceId)] = aws.StringValue(tag.Value)
+			}
+		}
+	}
+
+	names := lo.Uniq(lo.Values(groupNames))
+	groups := make([]*autoscaling.Group, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			groups[i], errs[i] = c.describeAutoScalingGroupBatcher.DescribeAutoScalingGroup(ctx, name)
+		}(i, name)
+	}
+	wg.Wait()
+
+	members := map[string]*autoscaling.Instance{}
+	for i, group := range groups {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		if group == nil {
+			continue
+		}
+		for _, member := range group.Instances {
+			instanceID := aws.StringValue(member.InstanceId)
+			if groupNames[instanceID] == names[i] {
+				members[instanceID] = member
+			}
+		}
+	}
+	return members, nil
+}
@@ -27,6 +27,7 @@ import (
 	"k8s.io/cloud-provider-aws/pkg/providers/v1/batcher"
 	"k8s.io/cloud-provider-aws/pkg/providers/v1/iface"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
@@ -36,17 +37,21 @@ import (
 // describeInstanceBatcher contains the batcher details
 type describeInstanceBatcher struct {
 	batcher *batcher.Batcher[ec2.DescribeInstancesInput, ec2types.Instance]
+	// cache holds instances described by the batcher, it is nil when caching is disabled
+	cache *instanceIDCache
 }
 
 // newdescribeInstanceBatcher creates a createdescribeInstanceBatcher object
-func newdescribeInstanceBatcher(ctx context.Context, ec2api iface.EC2) *describeInstanceBatcher {
+func newdescribeInstanceBatcher(ctx context.Context, ec2api iface.EC2, rateLimiter batcher.RateLimiter) *describeInstanceBatcher {
 	options := batcher.Options[ec2.DescribeInstancesInput, ec2types.Instance]{
-		Name:          "describe_instance",
-		IdleTimeout:   100 * time.Millisecond,
-		MaxTimeout:    1 * time.Second,
-		MaxItems:      500,
-		RequestHasher: describeInstanceHasher,
-		BatchExecutor: execDescribeInstanceBatch(ec2api),
+		Name:                "describe_instance",
+		IdleTimeout:         100 * time.Millisecond,
+		MaxTimeout:          1 * time.Second,
+		MaxItems:            500,
+		RequestHasher:       describeInstanceHasher,
+		RequestDeduplicator: describeInstanceDeduplicator,
+		BatchExecutor:       execDescribeInstanceBatch(ec2api),
+		RateLimiter:         rateLimiter,
 	}
 	return &describeInstanceBatcher{batcher: batcher.NewBatcher(ctx, options)}
 }
@@ -56,10 +61,28 @@ func (b *describeInstanceBatcher) DescribeInstances(ctx context.Context, input *
 	if len(input.InstanceIds) != 1 {
 		return nil, fmt.Errorf("expected to receive a single instance only, found %d", len(input.InstanceIds))
 	}
+	instanceID := input.InstanceIds[0]
+	if instance, ok := b.cache.get(instanceID); ok {
+		return []*ec2types.Instance{instance}, nil
+	}
 	result := b.batcher.Add(ctx, input)
+	if result.Err == nil {
+		b.cache.set(instanceID, result.Output)
+	}
 	return []*ec2types.Instance{result.Output}, result.Err
 }
 
+// withCache caches described instances by instance ID for ttl, caching is disabled when ttl is not positive
+func (b *describeInstanceBatcher) withCache(ttl time.Duration) *describeInstanceBatcher {
+	b.cache = newInstanceIDCache(ttl, clock.RealClock{})
+	return b
+}
+
+// invalidate removes an instance from the cache, so that the next lookup describes it again
+func (b *describeInstanceBatcher) invalidate(instanceID string) {
+	b.cache.invalidate(instanceID)
+}
+
 // DescribeInstanceHasher generates hash for different describe instances inputs
 // Same inputs have same hash, so they get executed together
 func describeInstanceHasher(ctx context.Context, input *ec2.DescribeInstancesInput) uint64 {
@@ -71,16 +94,21 @@ func describeInstanceHasher(ctx context.Context, input *ec2.DescribeInstancesInp
 	return hash
 }
 
+// describeInstanceDeduplicator identifies inputs for the same instance, so each instance is only described once per batch
+func describeInstanceDeduplicator(ctx context.Context, input *ec2.DescribeInstancesInput) uint64 {
+	hash, err := hashstructure.Hash(input.InstanceIds, hashstructure.FormatV2, nil)
+	if err != nil {
+		log.FromContext(ctx).Error(err, "failed hashing input instance IDs")
+	}
+	return hash
+}
+
 func execDescribeInstanceBatch(ec2api iface.EC2) batcher.BatchExecutor[ec2.DescribeInstancesInput, ec2types.Instance] {
 	return func(ctx context.Context, inputs []*ec2.DescribeInstancesInput) []batcher.Result[ec2types.Instance] {
 		results := make([]batcher.Result[ec2types.Instance], len(inputs))
-		firstInput := inputs[0]
-		// aggregate instanceIDs into 1 input
-		for _, input := range inputs[1:] {
-			firstInput.InstanceIds = append(firstInput.InstanceIds, input.InstanceIds...)
-		}
+		// aggregate instanceIDs into 1 input, leaving the inputs intact so they can be retried individually
 		batchedInput := &ec2.DescribeInstancesInput{
-			InstanceIds: firstInput.InstanceIds,
+			InstanceIds: lo.Uniq(lo.FlatMap(inputs, func(input *ec2.DescribeInstancesInput, _ int) []string { return input.InstanceIds })),
 		}
 		klog.Infof("Batched describe instances %v", batchedInput)
 		output, err := ec2api.DescribeInstances(ctx, batchedInput)
@@ -96,6 +124,11 @@ func execDescribeInstanceBatch(ec2api iface.EC2) batcher.BatchExecutor[ec2.Descr
 						results[idx] = batcher.Result[ec2types.Instance]{Output: nil, Err: err}
 						return
 					}
+					if len(out) == 0 {
+						// Like in a batched lookup, an instance that is not found has no instance ID
+						results[idx] = batcher.Result[ec2types.Instance]{Output: &ec2types.Instance{}}
+						return
+					}
 					results[idx] = batcher.Result[ec2types.Instance]{Output: &out[0], Err: err}
 				}(input)
 			}
@@ -0,0 +1,106 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"sync"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"k8s.io/utils/clock"
+)
+
+// instanceIDCache caches instances described by ID for a fixed TTL
+type instanceIDCache struct {
+	ttl   time.Duration
+	clock clock.Clock
+
+	mu      sync.Mutex
+	entries map[string]instanceIDCacheEntry
+}
+
+type instanceIDCacheEntry struct {
+	instance *ec2types.Instance
+	expires  time.Time
+}
+
+// newInstanceIDCache creates an instanceIDCache, it returns nil when ttl is not positive which disables caching
+func newInstanceIDCache(ttl time.Duration, clock clock.Clock) *instanceIDCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &instanceIDCache{
+		ttl:     ttl,
+		clock:   clock,
+		entries: map[string]instanceIDCacheEntry{},
+	}
+}
+
+// get returns the cached instance if it hasn't expired
+func (c *instanceIDCache) get(instanceID string) (*ec2types.Instance, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[instanceID]
+	if !ok {
+		return nil, false
+	}
+	if !c.clock.Now().Before(entry.expires) {
+		delete(c.entries, instanceID)
+		return nil, false
+	}
+	return entry.instance, true
+}
+
+// set caches an instance. Instances in a transitional state are about to change, so they evict any cached
+// entry instead of being cached. An instance that wasn't found has no instance ID and isn't cached either, it may
+// have just been launched and must not read as nonexistent until the entry expires.
+func (c *instanceIDCache) set(instanceID string, instance *ec2types.Instance) {
+	if c == nil || instanceID == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if instance == nil || instance.InstanceId == nil || isTransitionalInstanceState(instance.State) {
+		delete(c.entries, instanceID)
+		return
+	}
+	c.entries[instanceID] = instanceIDCacheEntry{instance: instance, expires: c.clock.Now().Add(c.ttl)}
+}
+
+// invalidate removes an instance from the cache
+func (c *instanceIDCache) invalidate(instanceID string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, instanceID)
+}
+
+func isTransitionalInstanceState(state *ec2types.InstanceState) bool {
+	if state == nil {
+		return false
+	}
+	switch state.Name {
+	case ec2types.InstanceStateNamePending, ec2types.InstanceStateNameStopping, ec2types.InstanceStateNameShuttingDown:
+		return true
+	}
+	return false
+}
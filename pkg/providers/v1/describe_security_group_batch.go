@@ -0,0 +1,104 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	"k8s.io/cloud-provider-aws/pkg/providers/v1/batcher"
+	"k8s.io/cloud-provider-aws/pkg/providers/v1/iface"
+	"k8s.io/klog/v2"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+)
+
+// describeSecurityGroupBatcher contains the batcher details
+type describeSecurityGroupBatcher struct {
+	batcher *batcher.Batcher[string, ec2types.SecurityGroup]
+}
+
+// newDescribeSecurityGroupBatcher creates a describeSecurityGroupBatcher object
+func newDescribeSecurityGroupBatcher(ctx context.Context, ec2api iface.EC2, rateLimiter batcher.RateLimiter) *describeSecurityGroupBatcher {
+	options := batcher.Options[string, ec2types.SecurityGroup]{
+		Name:        "describe_security_group",
+		IdleTimeout: 100 * time.Millisecond,
+		MaxTimeout:  1 * time.Second,
+		MaxItems:    500,
+		// All lookups are by group ID, so they can always be executed together
+		RequestHasher:       batcher.OneBucketHasher[string],
+		RequestDeduplicator: batcher.DefaultHasher[string],
+		BatchExecutor:       execDescribeSecurityGroupBatch(ec2api),
+		RateLimiter:         rateLimiter,
+	}
+	return &describeSecurityGroupBatcher{batcher: batcher.NewBatcher(ctx, options)}
+}
+
+// DescribeSecurityGroup adds a security group ID to the batcher, it returns nil if the group is not found
+func (b *describeSecurityGroupBatcher) DescribeSecurityGroup(ctx context.Context, securityGroupID string) (*ec2types.SecurityGroup, error) {
+	result := b.batcher.Add(ctx, &securityGroupID)
+	return result.Output, result.Err
+}
+
+func execDescribeSecurityGroupBatch(ec2api iface.EC2) batcher.BatchExecutor[string, ec2types.SecurityGroup] {
+	return func(ctx context.Context, inputs []*string) []batcher.Result[ec2types.SecurityGroup] {
+		results := make([]batcher.Result[ec2types.SecurityGroup], len(inputs))
+		batchedInput := &ec2.DescribeSecurityGroupsInput{
+			GroupIds: lo.FromSlicePtr(inputs),
+		}
+		klog.Infof("Batched describe security groups %v", batchedInput)
+		// We don't apply our tag filters because we are retrieving by ID
+		output, err := ec2api.DescribeSecurityGroups(ctx, batchedInput)
+		if err != nil {
+			// A single missing group fails the whole request, so look the groups up one by one
+			klog.Errorf("Error occurred trying to batch describe security groups, trying individually, %v", err)
+			var wg sync.WaitGroup
+			for idx, input := range inputs {
+				wg.Add(1)
+				go func(securityGroupID string) {
+					defer wg.Done()
+					out, err := ec2api.DescribeSecurityGroups(ctx, &ec2.DescribeSecurityGroupsInput{
+						GroupIds: []string{securityGroupID},
+					})
+					switch {
+					case err != nil:
+						results[idx] = batcher.Result[ec2types.SecurityGroup]{Err: err}
+					case len(out) > 1:
+						// This should not be possible - ids should be unique
+						results[idx] = batcher.Result[ec2types.SecurityGroup]{Err: fmt.Errorf("multiple security groups found with same id %q", securityGroupID)}
+					case len(out) == 1:
+						results[idx] = batcher.Result[ec2types.SecurityGroup]{Output: &out[0]}
+					}
+				}(*input)
+			}
+			wg.Wait()
+		} else {
+			groupIDToOutputMap := map[string]ec2types.SecurityGroup{}
+			lo.ForEach(output, func(o ec2types.SecurityGroup, _ int) { groupIDToOutputMap[lo.FromPtr(o.GroupId)] = o })
+			for idx, input := range inputs {
+				if o, ok := groupIDToOutputMap[*input]; ok {
+					results[idx] = batcher.Result[ec2types.SecurityGroup]{Output: &o}
+				}
+			}
+		}
+		return results
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// instanceStateChangeDetailType is the EventBridge detail type of EC2 instance state changes
+	instanceStateChangeDetailType = "EC2 Instance State-change Notification"
+
+	// instanceStateEventsWaitTimeSeconds is how long a receive long polls the queue for messages
+	instanceStateEventsWaitTimeSeconds = 20
+	// instanceStateEventsMaxMessages is the most messages a single receive returns
+	instanceStateEventsMaxMessages = 10
+	// instanceStateEventsRetryInterval is how long to wait before receiving again after a failed receive
+	instanceStateEventsRetryInterval = 10 * time.Second
+)
+
+// instanceStateChangeEvent is an EC2 instance state change delivered by EventBridge
+type instanceStateChangeEvent struct {
+	DetailType string `json:"detail-type"`
+	Detail     struct {
+		InstanceID string `json:"instance-id"`
+		State      string `json:"state"`
+	} `json:"detail"`
+}
+
+// instanceStateEventConsumer reads EC2 instance state changes that EventBridge delivers to an SQS queue, and
+// invalidates the cached lookups of instances that are stopped or terminated so that they are described again
+// rather than served stale until their TTL expires
+type instanceStateEventConsumer struct {
+	sqs        SQS
+	queueURL   string
+	invalidate func(instanceID string)
+}
+
+func newInstanceStateEventConsumer(sqs SQS, queueURL string, invalidate func(instanceID string)) *instanceStateEventConsumer {
+	return &instanceStateEventConsumer{
+		sqs:        sqs,
+		queueURL:   queueURL,
+		invalidate: invalidate,
+	}
+}
+
+// run receives events until the context is done
+func (c *instanceStateEventConsumer) run(ctx context.Context) {
+	klog.Infof("Consuming EC2 instance state change events from %s", c.queueURL)
+	for ctx.Err() == nil {
+		if err := c.receive(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			klog.Errorf("Error receiving EC2 instance state change events from %s: %v", c.queueURL, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(instanceStateEventsRetryInterval):
+			}
+		}
+	}
+}
+
+// receive long polls the queue once, handles the received events and deletes their messages
+func (c *instanceStateEventConsumer) receive(ctx context.Context) error {
+	output, err := c.sqs.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(c.queueURL),
+		MaxNumberOfMessages: aws.Int64(instanceStateEventsMaxMessages),
+		WaitTimeSeconds:     aws.Int64(instanceStateEventsWaitTimeSeconds),
+	})
+	if err != nil {
+		return err
+	}
+	for _, message := range output.Messages {
+		c.handle(aws.StringValue(message.Body))
+		// Messages are deleted even when they can't be parsed, redelivering them wouldn't help
+		if _, err := c.sqs.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+			QueueUrl:      aws.String(c.queueURL),
+			ReceiptHandle: message.ReceiptHandle,
+		}); err != nil {
+			klog.Warningf("Error deleting message %s from %s: %v", aws.StringValue(message.MessageId), c.queueURL, err)
+		}
+	}
+	return nil
+}
+
+// handle invalidates the instance of a stop or terminate event, other events are ignored
+func (c *instanceStateEventConsumer) handle(body string) {
+	var event instanceStateChangeEvent
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		klog.Warningf("Ignoring message that is not an EC2 instance state change event: %v", err)
+		return
+	}
+	if event.DetailType != instanceStateChangeDetailType || event.Detail.InstanceID == "" {
+		klog.V(4).Infof("Ignoring event of type %q", event.DetailType)
+		return
+	}
+	switch ec2types.InstanceStateName(event.Detail.State) {
+	case ec2types.InstanceStateNameStopping, ec2types.InstanceStateNameStopped,
+		ec2types.InstanceStateNameShuttingDown, ec2types.InstanceStateNameTerminated:
+		klog.V(2).Infof("Invalidating cached instance %s, it is %s", event.Detail.InstanceID, event.Detail.State)
+		c.invalidate(event.Detail.InstanceID)
+	}
+}
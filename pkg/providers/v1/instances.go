@@ -19,7 +19,6 @@ package aws
 import (
 	"context"
 	"fmt"
-	"net/url"
 	"regexp"
 	"strings"
 	"sync"
@@ -59,47 +58,117 @@ type KubernetesInstanceID string
 
 // MapToAWSInstanceID extracts the InstanceID from the KubernetesInstanceID
 func (name KubernetesInstanceID) MapToAWSInstanceID() (InstanceID, error) {
-	s := string(name)
+	_, instanceID, err := ParseProviderID(string(name))
+	return instanceID, err
+}
+
+// providerIDPrefix is the prefix of the provider IDs of the nodes, the host (AWS endpoint) is always empty
+const providerIDPrefix = ProviderName + ":///"
 
-	if !strings.HasPrefix(s, "aws://") {
+// ParseProviderID extracts the availability zone and the InstanceID from the provider ID of a node, in one of the
+// forms of KubernetesInstanceID. The zone is empty for the legacy aws:////<awsInstanceId> form and for bare
+// instance IDs. Non-empty path segments between the zone and the ID, e.g. the task ID of Fargate nodes, are ignored.
+func ParseProviderID(providerID string) (string, InstanceID, error) {
+	if !strings.Contains(providerID, "://") {
 		// Assume a bare aws instance id (i-1234...)
-		// Build a URL with an empty host (AZ)
-		s = "aws://" + "/" + "/" + s
-	}
-	url, err := url.Parse(s)
-	if err != nil {
-		return "", fmt.Errorf("Invalid instance name (%s): %v", name, err)
+		if !isValidInstanceID(providerID) {
+			return "", "", fmt.Errorf("Invalid format for AWS instance (%s)", providerID)
+		}
+		return "", InstanceID(providerID), nil
 	}
-	if url.Scheme != "aws" {
-		return "", fmt.Errorf("Invalid scheme for AWS instance (%s)", name)
+	if !strings.HasPrefix(providerID, providerIDPrefix) {
+		return "", "", fmt.Errorf("Invalid provider ID (%s), expected the %s<zone>/<instance-id> format", providerID, providerIDPrefix)
 	}
 
-	awsID := ""
-	tokens := strings.Split(strings.Trim(url.Path, "/"), "/")
+	tokens := strings.Split(strings.TrimPrefix(providerID, providerIDPrefix), "/")
+	if len(tokens) < 2 {
+		return "", "", fmt.Errorf("Invalid provider ID (%s), expected the %s<zone>/<instance-id> format", providerID, providerIDPrefix)
+	}
+	zone := tokens[0]
 	// last token in the providerID is the aws resource ID for both EC2 and Fargate nodes
-	if len(tokens) > 0 {
-		awsID = tokens[len(tokens)-1]
+	awsID := tokens[len(tokens)-1]
+	for _, token := range tokens[1:] {
+		if token == "" {
+			return "", "", fmt.Errorf("Invalid provider ID (%s), unexpected empty path segment", providerID)
+		}
+	}
+	if !isValidInstanceID(awsID) {
+		return "", "", fmt.Errorf("Invalid format for AWS instance (%s)", providerID)
 	}
 
-	// We sanity check the resulting instance ID; the two known formats are
-	// i-12345678 and i-12345678abcdef01
-	if awsID == "" || !(awsInstanceRegMatch.MatchString(awsID) || variant.IsVariantNode(awsID)) {
-		return "", fmt.Errorf("Invalid format for AWS instance (%s)", name)
+	return zone, InstanceID(awsID), nil
+}
+
+// FormatProviderID returns the provider ID of the instance in the given availability zone, in the
+// aws:///<zone>/<awsInstanceId> form. An empty zone produces the legacy aws:////<awsInstanceId> form.
+func FormatProviderID(zone string, instanceID InstanceID) string {
+	return ProviderName + "://" + formatInstanceID(zone, instanceID)
+}
+
+// formatInstanceID returns the ID of the instance in the /<zone>/<awsInstanceId> form of the Instances interface
+func formatInstanceID(zone string, instanceID InstanceID) string {
+	return "/" + zone + "/" + string(instanceID)
+}
+
+// isValidInstanceID sanity checks an instance ID; the two known formats are i-12345678 and
+// i-12345678abcdef01, besides the IDs of variant nodes
+func isValidInstanceID(awsID string) bool {
+	return awsID != "" && (awsInstanceRegMatch.MatchString(awsID) || variant.IsVariantNode(awsID))
+}
+
+// InstanceIDResolver maps a Kubernetes node to the ID of the AWS instance backing it. The InstanceIDLabel option
+// reads the instance IDs from a node label, and programs embedding the cloud provider can set their own resolver with
+// Cloud.SetInstanceIDResolver, when the provider IDs of their nodes don't hold the instance ID, e.g. for hybrid or
+// Outposts nodes registered by other tooling.
+type InstanceIDResolver interface {
+	InstanceID(node *v1.Node) (InstanceID, error)
+}
+
+// providerIDInstanceIDResolver is the default InstanceIDResolver, it extracts the instance ID from the provider ID
+// of the node, or from its name for resource-based node names when the provider ID isn't set yet
+type providerIDInstanceIDResolver struct{}
+
+// InstanceID implements InstanceIDResolver
+func (providerIDInstanceIDResolver) InstanceID(node *v1.Node) (InstanceID, error) {
+	if node.Spec.ProviderID == "" {
+		// resource-based names are the instance ID, followed by the domain on some distributions
+		if name, _, _ := strings.Cut(node.Name, "."); strings.HasPrefix(name, rbnNamePrefix) && isValidInstanceID(name) {
+			return InstanceID(name), nil
+		}
+		return "", fmt.Errorf("node %q did not have ProviderID set", node.Name)
+	}
+	instanceID, err := KubernetesInstanceID(node.Spec.ProviderID).MapToAWSInstanceID()
+	if err != nil {
+		return "", fmt.Errorf("unable to parse ProviderID %q for node %q", node.Spec.ProviderID, node.Name)
 	}
+	return instanceID, nil
+}
 
-	return InstanceID(awsID), nil
+// labelInstanceIDResolver reads the instance IDs of nodes from a label, and maps the nodes without the label by
+// their provider IDs
+type labelInstanceIDResolver struct {
+	label string
+}
+
+// InstanceID implements InstanceIDResolver
+func (r labelInstanceIDResolver) InstanceID(node *v1.Node) (InstanceID, error) {
+	instanceID, ok := node.Labels[r.label]
+	if !ok {
+		return providerIDInstanceIDResolver{}.InstanceID(node)
+	}
+	if !isValidInstanceID(instanceID) {
+		return "", fmt.Errorf("invalid instance ID %q in label %s of node %q", instanceID, r.label, node.Name)
+	}
+	return InstanceID(instanceID), nil
 }
 
 // mapToAWSInstanceID extracts the InstanceIDs from the Nodes, returning an error if a Node cannot be mapped
-func mapToAWSInstanceIDs(nodes []*v1.Node) ([]InstanceID, error) {
+func mapToAWSInstanceIDs(resolver InstanceIDResolver, nodes []*v1.Node) ([]InstanceID, error) {
 	var instanceIDs []InstanceID
 	for _, node := range nodes {
-		if node.Spec.ProviderID == "" {
-			return nil, fmt.Errorf("node %q did not have ProviderID set", node.Name)
-		}
-		instanceID, err := KubernetesInstanceID(node.Spec.ProviderID).MapToAWSInstanceID()
+		instanceID, err := resolver.InstanceID(node)
 		if err != nil {
-			return nil, fmt.Errorf("unable to parse ProviderID %q for node %q", node.Spec.ProviderID, node.Name)
+			return nil, err
 		}
 		instanceIDs = append(instanceIDs, instanceID)
 	}
@@ -108,16 +177,12 @@ func mapToAWSInstanceIDs(nodes []*v1.Node) ([]InstanceID, error) {
 }
 
 // mapToAWSInstanceIDsTolerant extracts the InstanceIDs from the Nodes, skipping Nodes that cannot be mapped
-func mapToAWSInstanceIDsTolerant(nodes []*v1.Node) []InstanceID {
+func mapToAWSInstanceIDsTolerant(resolver InstanceIDResolver, nodes []*v1.Node) []InstanceID {
 	var instanceIDs []InstanceID
 	for _, node := range nodes {
-		if node.Spec.ProviderID == "" {
-			klog.Warningf("node %q did not have ProviderID set", node.Name)
-			continue
-		}
-		instanceID, err := KubernetesInstanceID(node.Spec.ProviderID).MapToAWSInstanceID()
+		instanceID, err := resolver.InstanceID(node)
 		if err != nil {
-			klog.Warningf("unable to parse ProviderID %q for node %q", node.Spec.ProviderID, node.Name)
+			klog.Warning(err)
 			continue
 		}
 		instanceIDs = append(instanceIDs, instanceID)
@@ -105,7 +105,7 @@ func TestMapToAWSInstanceIDs(t *testing.T) {
 		node := &v1.Node{}
 		node.Spec.ProviderID = string(test.Kubernetes)
 
-		awsInstanceIds, err := mapToAWSInstanceIDs([]*v1.Node{node})
+		awsInstanceIds, err := mapToAWSInstanceIDs(providerIDInstanceIDResolver{}, []*v1.Node{node})
 		if err != nil {
 			if !test.ExpectError {
 				t.Errorf("unexpected error parsing %s: %v", test.Kubernetes, err)
@@ -120,7 +120,7 @@ func TestMapToAWSInstanceIDs(t *testing.T) {
 			}
 		}
 
-		awsInstanceIds = mapToAWSInstanceIDsTolerant([]*v1.Node{node})
+		awsInstanceIds = mapToAWSInstanceIDsTolerant(providerIDInstanceIDResolver{}, []*v1.Node{node})
 		if test.ExpectError {
 			if len(awsInstanceIds) != 0 {
 				t.Errorf("unexpected results parsing %s: %s", test.Kubernetes, awsInstanceIds)
@@ -135,6 +135,68 @@ func TestMapToAWSInstanceIDs(t *testing.T) {
 	}
 }
 
+func TestParseProviderID(t *testing.T) {
+	tests := []struct {
+		name        string
+		providerID  string
+		zone        string
+		instanceID  InstanceID
+		expectError bool
+	}{
+		{name: "zone and instance ID", providerID: "aws:///us-east-1a/i-12345678", zone: "us-east-1a", instanceID: "i-12345678"},
+		{name: "long instance ID", providerID: "aws:///us-east-1a/i-12345678abcdef01", zone: "us-east-1a", instanceID: "i-12345678abcdef01"},
+		{name: "legacy empty zone", providerID: "aws:////i-12345678abcdef01", instanceID: "i-12345678abcdef01"},
+		{name: "bare instance ID", providerID: "i-12345678abcdef01", instanceID: "i-12345678abcdef01"},
+		{name: "segment before instance ID", providerID: "aws:///us-west-2c/1abc-2def/i-12345678", zone: "us-west-2c", instanceID: "i-12345678"},
+		{name: "fargate", providerID: "aws:///us-west-2c/1abc-2def/fargate-192.168.164.88", zone: "us-west-2c", instanceID: "fargate-192.168.164.88"},
+		{name: "fargate without task ID", providerID: "aws:///us-west-2c/fargate-192.168.164.88", zone: "us-west-2c", instanceID: "fargate-192.168.164.88"},
+		{name: "empty", providerID: "", expectError: true},
+		{name: "bare volume ID", providerID: "vol-12345678", expectError: true},
+		{name: "volume ID", providerID: "aws:///us-east-1a/vol-12345678abcdef01", expectError: true},
+		{name: "other scheme", providerID: "gce:///us-east-1a/i-12345678", expectError: true},
+		{name: "host", providerID: "aws://accountid/us-east-1a/i-12345678", expectError: true},
+		{name: "missing zone segment", providerID: "aws:///i-12345678", expectError: true},
+		{name: "missing instance ID", providerID: "aws:///us-east-1a/", expectError: true},
+		{name: "trailing slash", providerID: "aws:///us-east-1a/i-12345678/", expectError: true},
+		{name: "suffix", providerID: "aws:///us-east-1a/i-12345678/suffix", expectError: true},
+		{name: "empty segment", providerID: "aws:///us-west-2c//fargate-192.168.164.88", expectError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			zone, instanceID, err := ParseProviderID(test.providerID)
+			if test.expectError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.zone, zone)
+			assert.Equal(t, test.instanceID, instanceID)
+		})
+	}
+}
+
+func TestFormatProviderID(t *testing.T) {
+	tests := []struct {
+		zone       string
+		instanceID InstanceID
+		providerID string
+	}{
+		{zone: "us-east-1a", instanceID: "i-12345678abcdef01", providerID: "aws:///us-east-1a/i-12345678abcdef01"},
+		{zone: "", instanceID: "i-12345678abcdef01", providerID: "aws:////i-12345678abcdef01"},
+	}
+
+	for _, test := range tests {
+		providerID := FormatProviderID(test.zone, test.instanceID)
+		assert.Equal(t, test.providerID, providerID)
+
+		zone, instanceID, err := ParseProviderID(providerID)
+		assert.NoError(t, err)
+		assert.Equal(t, test.zone, zone)
+		assert.Equal(t, test.instanceID, instanceID)
+	}
+}
+
 func TestSnapshotMeetsCriteria(t *testing.T) {
 	snapshot := &allInstancesSnapshot{timestamp: time.Now().Add(-3601 * time.Second)}
 
@@ -26,6 +26,7 @@ import (
 	"k8s.io/cloud-provider-aws/pkg/providers/v1/variant"
 	"strconv"
 
+	"github.com/aws/aws-sdk-go/aws"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	cloudprovider "k8s.io/cloud-provider"
@@ -37,6 +38,15 @@ func (c *Cloud) getProviderID(ctx context.Context, node *v1.Node) (string, error
 		return node.Spec.ProviderID, nil
 	}
 
+	// The instance ID resolver may map the node without a provider ID, e.g. from a label or its resource-based name
+	if resolvedID, err := c.instanceIDResolver.InstanceID(node); err == nil && !variant.IsVariantNode(string(resolvedID)) {
+		instance, err := c.getInstanceByID(ctx, string(resolvedID))
+		if err != nil {
+			return "", err
+		}
+		return c.ProviderName() + "://" + formatInstanceID(aws.StringValue(instance.Placement.AvailabilityZone), resolvedID), nil
+	}
+
 	instanceID, err := c.InstanceID(ctx, types.NodeName(node.Name))
 	if err != nil {
 		return "", err
@@ -120,7 +130,12 @@ func (c *Cloud) InstanceMetadata(ctx context.Context, node *v1.Node) (*cloudprov
 	if err != nil {
 		return nil, err
 	}
-	instanceID, err := KubernetesInstanceID(providerID).MapToAWSInstanceID()
+	resolved := node
+	if node.Spec.ProviderID == "" {
+		resolved = node.DeepCopy()
+		resolved.Spec.ProviderID = providerID
+	}
+	instanceID, err := c.instanceIDResolver.InstanceID(resolved)
 	if err != nil {
 		return nil, fmt.Errorf("failed to map provider ID to AWS instance ID for node %s: %w", node.Name, err)
 	}
@@ -19,8 +19,10 @@ package aws
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	awsv2 "github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
@@ -32,8 +34,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/cloud-provider-aws/pkg/resourcemanagers"
 	"k8s.io/cloud-provider-aws/pkg/services"
+	clocktesting "k8s.io/utils/clock/testing"
 )
 
 func TestGetProviderId(t *testing.T) {
@@ -130,6 +134,12 @@ func TestInstanceShutdown(t *testing.T) {
 		instanceState    ec2types.InstanceStateName
 		expectedShutdown bool
 	}{
+		{
+			name:             "Should return false when instance is not found",
+			instanceExists:   false,
+			instanceState:    "",
+			expectedShutdown: false,
+		},
 		{
 			name:             "Should return false when instance is found and running",
 			instanceExists:   true,
@@ -308,7 +318,7 @@ func TestInstanceMetadata(t *testing.T) {
 
 func TestDescribeInstanceBatching(t *testing.T) {
 	mockedEC2API := newMockedEC2API()
-	batcher := newdescribeInstanceBatcher(context.Background(), &awsSdkEC2{ec2: mockedEC2API})
+	batcher := newdescribeInstanceBatcher(context.Background(), &awsSdkEC2{ec2: mockedEC2API}, nil)
 
 	mockedEC2API.On("DescribeInstances", mock.Anything).Return(&ec2.DescribeInstancesOutput{
 		Reservations: []ec2types.Reservation{
@@ -360,9 +370,108 @@ func TestDescribeInstanceBatching(t *testing.T) {
 	mockedEC2API.AssertNumberOfCalls(t, "DescribeInstances", 1)
 }
 
+func TestInstanceLookupBatching(t *testing.T) {
+	instanceA := makeInstance("i-a", "192.168.0.1", "1.2.3.4", "instance-a.ec2.internal", "instance-a.ec2.external", nil, true)
+	instanceB := makeInstance("i-b", "192.168.0.2", "1.2.3.5", "instance-b.ec2.internal", "instance-b.ec2.external", nil, true)
+	instanceB.State = &ec2types.InstanceState{Name: ec2types.InstanceStateNameStopped}
+	c, awsServices := mockInstancesResp(&instanceA, []*ec2types.Instance{&instanceA, &instanceB})
+	c.instanceTopologyManager = &resourcemanagers.MockedInstanceTopologyManager{}
+	for key := range awsServices.callCounts {
+		delete(awsServices.callCounts, key)
+	}
+
+	// A node sync checks whether the instance exists, whether it is shut down and its metadata
+	var wg sync.WaitGroup
+	for _, instance := range []ec2types.Instance{instanceA, instanceB} {
+		node := &v1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				// Set labels to skip attempts to update them
+				Labels: map[string]string{LabelZoneID: "az1", LabelNetworkNodePrefix + "1": "nn-123456789"},
+			},
+			Spec: v1.NodeSpec{ProviderID: fmt.Sprintf("aws:///us-west-2a/%s", *instance.InstanceId)},
+		}
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			exists, err := c.InstanceExists(context.TODO(), node)
+			assert.NoError(t, err)
+			assert.True(t, exists)
+		}()
+		go func() {
+			defer wg.Done()
+			shutdown, err := c.InstanceShutdown(context.TODO(), node)
+			assert.NoError(t, err)
+			assert.Equal(t, instance.State.Name == ec2types.InstanceStateNameStopped, shutdown)
+		}()
+		go func() {
+			defer wg.Done()
+			metadata, err := c.InstanceMetadata(context.TODO(), node)
+			assert.NoError(t, err)
+			assert.Equal(t, "c3.large", metadata.InstanceType)
+		}()
+	}
+	wg.Wait()
+
+	describeCalls := 0
+	for key, count := range awsServices.callCounts {
+		if strings.HasPrefix(key, "ec2:DescribeInstances:") {
+			describeCalls += count
+		}
+	}
+	assert.Equal(t, 1, describeCalls)
+}
+
+func TestDescribeInstanceCache(t *testing.T) {
+	awsServices := NewFakeAWSServices(TestClusterID)
+	describeCalls := func() int {
+		return awsServices.callCounts["ec2:DescribeInstances:i-self"]
+	}
+	fakeClock := clocktesting.NewFakeClock(time.Now())
+	b := newdescribeInstanceBatcher(context.Background(), awsServices.ec2, nil)
+	b.cache = newInstanceIDCache(time.Minute, fakeClock)
+	describe := func() {
+		instances, err := b.DescribeInstances(context.Background(), &ec2.DescribeInstancesInput{InstanceIds: []string{"i-self"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "i-self", aws.StringValue(instances[0].InstanceId))
+	}
+
+	describe()
+	assert.Equal(t, 1, describeCalls())
+
+	// a second lookup within the TTL is served from the cache
+	describe()
+	assert.Equal(t, 1, describeCalls())
+
+	// an expired entry is described again
+	fakeClock.Step(2 * time.Minute)
+	describe()
+	assert.Equal(t, 2, describeCalls())
+
+	// an invalidated entry is described again
+	b.invalidate("i-self")
+	describe()
+	assert.Equal(t, 3, describeCalls())
+
+	// instances in a transitional state aren't cached
+	awsServices.selfInstance.State = &ec2types.InstanceState{Name: ec2types.InstanceStateNameStopping}
+	b.invalidate("i-self")
+	describe()
+	describe()
+	assert.Equal(t, 5, describeCalls())
+
+	// an instance that isn't found isn't cached, so it is found once it exists
+	instances, err := b.DescribeInstances(context.Background(), &ec2.DescribeInstancesInput{InstanceIds: []string{"i-new"}})
+	assert.NoError(t, err)
+	assert.Nil(t, instances[0].InstanceId)
+	awsServices.instances = append(awsServices.instances, &ec2types.Instance{InstanceId: aws.String("i-new")})
+	instances, err = b.DescribeInstances(context.Background(), &ec2.DescribeInstancesInput{InstanceIds: []string{"i-new"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "i-new", aws.StringValue(instances[0].InstanceId))
+}
+
 func getCloudWithMockedDescribeInstances(instanceExists bool, instanceState ec2types.InstanceStateName, instanceID string) *Cloud {
 	mockedEC2API := newMockedEC2API()
-	c := &Cloud{ec2: &awsSdkEC2{ec2: mockedEC2API}, describeInstanceBatcher: newdescribeInstanceBatcher(context.Background(), &awsSdkEC2{ec2: mockedEC2API})}
+	c := &Cloud{ec2: &awsSdkEC2{ec2: mockedEC2API}, describeInstanceBatcher: newdescribeInstanceBatcher(context.Background(), &awsSdkEC2{ec2: mockedEC2API}, nil), instanceIDResolver: providerIDInstanceIDResolver{}}
 
 	if !instanceExists {
 		mockedEC2API.On("DescribeInstances", mock.Anything).Return(&ec2.DescribeInstancesOutput{}, awserr.New("InvalidInstanceID.NotFound", "Instance not found", nil))
@@ -0,0 +1,92 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/utils/clock"
+)
+
+// nodeAddressCache caches the node addresses of instances by instance ID for a fixed TTL
+type nodeAddressCache struct {
+	ttl   time.Duration
+	clock clock.Clock
+
+	mu      sync.Mutex
+	entries map[string]nodeAddressCacheEntry
+}
+
+type nodeAddressCacheEntry struct {
+	addresses []v1.NodeAddress
+	expires   time.Time
+}
+
+// newNodeAddressCache creates a nodeAddressCache, it returns nil when ttl is not positive which disables caching
+func newNodeAddressCache(ttl time.Duration, clock clock.Clock) *nodeAddressCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &nodeAddressCache{
+		ttl:     ttl,
+		clock:   clock,
+		entries: map[string]nodeAddressCacheEntry{},
+	}
+}
+
+// get returns a copy of the cached addresses if they haven't expired
+func (c *nodeAddressCache) get(instanceID string) ([]v1.NodeAddress, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[instanceID]
+	if !ok {
+		return nil, false
+	}
+	if !c.clock.Now().Before(entry.expires) {
+		delete(c.entries, instanceID)
+		return nil, false
+	}
+	return append([]v1.NodeAddress(nil), entry.addresses...), true
+}
+
+// set caches the addresses of an instance
+func (c *nodeAddressCache) set(instanceID string, addresses []v1.NodeAddress) {
+	if c == nil || instanceID == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[instanceID] = nodeAddressCacheEntry{
+		addresses: append([]v1.NodeAddress(nil), addresses...),
+		expires:   c.clock.Now().Add(c.ttl),
+	}
+}
+
+// invalidate removes the addresses of an instance from the cache
+func (c *nodeAddressCache) invalidate(instanceID string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, instanceID)
+}
@@ -0,0 +1,91 @@
+/*
+Copyright 2024 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package aws
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/smithy-go/middleware"
+	"golang.org/x/time/rate"
+	"k8s.io/klog/v2"
+
+	"k8s.io/cloud-provider-aws/pkg/providers/v1/batcher"
+)
+
+// apiRateLimiter is a token bucket shared by the EC2 and ELB clients, so that the aggregate rate of API calls stays
+// within the account's limits. Unlike CrossRequestRetryDelay it paces calls before any throttling is observed.
+type apiRateLimiter struct {
+	limiter *rate.Limiter
+}
+
+var _ batcher.RateLimiter = &apiRateLimiter{}
+
+// newAPIRateLimiter creates an apiRateLimiter, it returns nil when qps is zero as calls aren't limited
+func newAPIRateLimiter(qps float64, burst int) *apiRateLimiter {
+	if qps <= 0 {
+		return nil
+	}
+	return &apiRateLimiter{limiter: rate.NewLimiter(rate.Limit(qps), max(burst, 1))}
+}
+
+// Wait blocks until a call can be made and takes a token for it
+func (l *apiRateLimiter) Wait(ctx context.Context) error {
+	return l.limiter.Wait(ctx)
+}
+
+// WaitAvailable blocks until a token is available without taking it, so batchers hold back their batches while the
+// limit is exhausted and leave the tokens to the calls of their executors
+func (l *apiRateLimiter) WaitAvailable(ctx context.Context) error {
+	for {
+		tokens := l.limiter.Tokens()
+		if tokens >= 1 {
+			return nil
+		}
+		delay := time.Duration((1 - tokens) / float64(l.limiter.Limit()) * float64(time.Second))
+		if err := sleepWithContext(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// BeforeSign is added to the Sign chain of AWS SDK Go V1 clients; called before each request
+func (l *apiRateLimiter) BeforeSign(r *request.Request) {
+	if err := l.Wait(r.Context()); err != nil {
+		r.Error = awserr.New(request.CanceledErrorCode, "request context canceled while waiting for the API rate limit", err)
+		r.Retryable = aws.Bool(false)
+	}
+}
+
+// rateLimitMiddleware is the middleware replica of apiRateLimiter.BeforeSign for AWS SDK Go V2 clients
+func rateLimitMiddleware(l *apiRateLimiter) middleware.FinalizeMiddleware {
+	return middleware.FinalizeMiddlewareFunc(
+		"k8s/rate-limit",
+		func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (
+			out middleware.FinalizeOutput, metadata middleware.Metadata, err error,
+		) {
+			if err := l.Wait(ctx); err != nil {
+				klog.V(4).Infof("Canceled AWS request (%s) while waiting for the API rate limit: %v", describeRequestV2(ctx), err)
+				return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+			}
+			return next.HandleFinalize(ctx, in)
+		},
+	)
+}
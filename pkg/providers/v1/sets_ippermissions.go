@@ -58,8 +58,28 @@ func (s IPPermissionSet) Ungroup() IPPermissionSet {
 		}
 	}
 
-	l2 := []ec2types.IpPermission{}
+	// IPv6 ranges are split out too, separately from the IPv4 ranges they are combined with
+	l1 := []ec2types.IpPermission{}
 	for _, p := range l {
+		if len(p.Ipv6Ranges) == 0 || (len(p.Ipv6Ranges) == 1 && len(p.IpRanges) == 0) {
+			l1 = append(l1, p)
+			continue
+		}
+		if len(p.IpRanges) != 0 {
+			c := p
+			c.Ipv6Ranges = nil
+			l1 = append(l1, c)
+		}
+		for _, ipv6Range := range p.Ipv6Ranges {
+			c := p
+			c.IpRanges = nil
+			c.Ipv6Ranges = []ec2types.Ipv6Range{ipv6Range}
+			l1 = append(l1, c)
+		}
+	}
+
+	l2 := []ec2types.IpPermission{}
+	for _, p := range l1 {
 		if len(p.UserIdGroupPairs) <= 1 {
 			l2 = append(l2, p)
 			continue
@@ -145,6 +145,38 @@ func TestUngroup(t *testing.T) {
 				},
 			),
 		},
+		{
+			"IPv4 and IPv6 ranges in input set",
+			NewIPPermissionSet(
+				ec2types.IpPermission{
+					FromPort:   aws.Int32(1),
+					IpProtocol: aws.String("tcp"),
+					IpRanges:   []ec2types.IpRange{{CidrIp: aws.String("10.0.0.0/16")}},
+					Ipv6Ranges: []ec2types.Ipv6Range{{CidrIpv6: aws.String("2001:db8::/32")}, {CidrIpv6: aws.String("2001:db9::/32")}},
+					ToPort:     aws.Int32(2),
+				},
+			),
+			NewIPPermissionSet(
+				ec2types.IpPermission{
+					FromPort:   aws.Int32(1),
+					IpProtocol: aws.String("tcp"),
+					IpRanges:   []ec2types.IpRange{{CidrIp: aws.String("10.0.0.0/16")}},
+					ToPort:     aws.Int32(2),
+				},
+				ec2types.IpPermission{
+					FromPort:   aws.Int32(1),
+					IpProtocol: aws.String("tcp"),
+					Ipv6Ranges: []ec2types.Ipv6Range{{CidrIpv6: aws.String("2001:db8::/32")}},
+					ToPort:     aws.Int32(2),
+				},
+				ec2types.IpPermission{
+					FromPort:   aws.Int32(1),
+					IpProtocol: aws.String("tcp"),
+					Ipv6Ranges: []ec2types.Ipv6Range{{CidrIpv6: aws.String("2001:db9::/32")}},
+					ToPort:     aws.Int32(2),
+				},
+			),
+		},
 	}
 
 	for _, test := range tests {
@@ -27,8 +27,6 @@ import (
 	"k8s.io/klog/v2"
 
 	"k8s.io/apimachinery/pkg/util/wait"
-
-	"k8s.io/cloud-provider-aws/pkg/providers/v1/iface"
 )
 
 // TagNameKubernetesClusterPrefix is the tag name we use to differentiate multiple
@@ -64,6 +62,9 @@ type awsTagging struct {
 
 	// usesLegacyTags is true if we are using the legacy TagNameKubernetesClusterLegacy tags
 	usesLegacyTags bool
+
+	// resourceTags are the configured tags added to every resource we create
+	resourceTags map[string]string
 }
 
 func (t *awsTagging) init(legacyClusterID string, clusterID string) error {
@@ -134,6 +135,11 @@ func (t *awsTagging) clusterTagKey() string {
 	return TagNameKubernetesClusterPrefix + t.ClusterID
 }
 
+// isClusterTagKey returns true if key is the key of a tag that buildTags sets for the cluster
+func (t *awsTagging) isClusterTagKey(key string) bool {
+	return key == t.clusterTagKey() || (t.usesLegacyTags && key == TagNameKubernetesClusterLegacy)
+}
+
 func (t *awsTagging) hasClusterTag(tags []ec2types.Tag) bool {
 	// if the clusterID is not configured -- we consider all instances.
 	if len(t.ClusterID) == 0 {
@@ -154,6 +160,21 @@ func (t *awsTagging) hasClusterTag(tags []ec2types.Tag) bool {
 	return false
 }
 
+// hasOtherClusterTag returns true if the tags mark the resource as belonging to a cluster other than this one
+func (t *awsTagging) hasOtherClusterTag(tags []ec2types.Tag) bool {
+	clusterTagKey := t.clusterTagKey()
+	for _, tag := range tags {
+		tagKey := aws.StringValue(tag.Key)
+		if tagKey == TagNameKubernetesClusterLegacy && aws.StringValue(tag.Value) != t.ClusterID {
+			return true
+		}
+		if strings.HasPrefix(tagKey, TagNameKubernetesClusterPrefix) && tagKey != clusterTagKey {
+			return true
+		}
+	}
+	return false
+}
+
 func (t *awsTagging) hasNoClusterPrefixTag(tags []ec2types.Tag) bool {
 	for _, tag := range tags {
 		if strings.HasPrefix(aws.StringValue(tag.Key), TagNameKubernetesClusterPrefix) {
@@ -166,7 +187,8 @@ func (t *awsTagging) hasNoClusterPrefixTag(tags []ec2types.Tag) bool {
 // Ensure that a resource has the correct tags
 // If it has no tags, we assume that this was a problem caused by an error in between creation and tagging,
 // and we add the tags.  If it has a different cluster's tags, that is an error.
-func (t *awsTagging) readRepairClusterTags(ctx context.Context, client iface.EC2, resourceID string, lifecycle ResourceLifecycle, additionalTags map[string]string, observedTags []ec2types.Tag) error {
+// Other tags with a different value were changed out of band and are left alone.
+func (t *awsTagging) readRepairClusterTags(ctx context.Context, tagger *createTagsBatcher, resourceID string, lifecycle ResourceLifecycle, additionalTags map[string]string, observedTags []ec2types.Tag) error {
 	actualTagMap := make(map[string]string)
 	for _, tag := range observedTags {
 		actualTagMap[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
@@ -181,9 +203,9 @@ func (t *awsTagging) readRepairClusterTags(ctx context.Context, client iface.EC2
 			continue
 		}
 		if actual == "" {
-			klog.Warningf("Resource %q was missing expected cluster tag %q.  Will add (with value %q)", resourceID, k, expected)
+			klog.Warningf("Resource %q was missing expected tag %q.  Will add (with value %q)", resourceID, k, expected)
 			addTags[k] = expected
-		} else {
+		} else if t.isClusterTagKey(k) {
 			return fmt.Errorf("resource %q has tag belonging to another cluster: %q=%q (expected %q)", resourceID, k, actual, expected)
 		}
 	}
@@ -192,20 +214,19 @@ func (t *awsTagging) readRepairClusterTags(ctx context.Context, client iface.EC2
 		return nil
 	}
 
-	if err := t.createTags(ctx, client, resourceID, lifecycle, addTags); err != nil {
+	if err := t.createTags(ctx, tagger, resourceID, addTags); err != nil {
 		return fmt.Errorf("error adding missing tags to resource %q: %q", resourceID, err)
 	}
 
 	return nil
 }
 
-// createTags calls EC2 CreateTags, but adds retry-on-failure logic
+// createTags calls EC2 CreateTags through the batcher, but adds retry-on-failure logic
 // We retry mainly because if we create an object, we cannot tag it until it is "fully created" (eventual consistency)
 // The error code varies though (depending on what we are tagging), so we simply retry on all errors
-func (t *awsTagging) createTags(ctx context.Context, client iface.EC2, resourceID string, lifecycle ResourceLifecycle, additionalTags map[string]string) error {
-	tags := t.buildTags(lifecycle, additionalTags)
-
-	if tags == nil || len(tags) == 0 {
+// Only the given tags are added, so that the values of other tags that were changed out of band are kept
+func (t *awsTagging) createTags(ctx context.Context, tagger *createTagsBatcher, resourceID string, tags map[string]string) error {
+	if len(tags) == 0 {
 		return nil
 	}
 
@@ -229,7 +250,7 @@ func (t *awsTagging) createTags(ctx context.Context, client iface.EC2, resourceI
 
 	var lastErr error
 	err := wait.ExponentialBackoff(backoff, func() (bool, error) {
-		_, err := client.CreateTags(ctx, request)
+		_, err := tagger.createTags(ctx, request)
 		if err == nil {
 			return true, nil
 		}
@@ -292,6 +313,9 @@ func (t *awsTagging) addLegacyFilters(filters []*ec2types.Filter) []*ec2types.Fi
 
 func (t *awsTagging) buildTags(lifecycle ResourceLifecycle, additionalTags map[string]string) map[string]string {
 	tags := make(map[string]string)
+	for k, v := range t.resourceTags {
+		tags[k] = v
+	}
 	for k, v := range additionalTags {
 		tags[k] = v
 	}
@@ -316,14 +340,15 @@ func (t *awsTagging) clusterID() string {
 }
 
 // TagResource calls EC2 and tag the resource associated to resourceID
-// with the supplied tags
+// with the supplied tags. Like other EC2 resources, it is tagged through the batcher, with the other
+// resources tagged at the same time with the same tags.
 func (c *Cloud) TagResource(ctx context.Context, resourceID string, tags map[string]string) error {
 	request := &ec2.CreateTagsInput{
 		Resources: []string{resourceID},
 		Tags:      buildAwsTags(tags),
 	}
 
-	output, err := c.ec2.CreateTags(ctx, request)
+	output, err := c.createTagsBatcher.createTags(ctx, request)
 
 	if err != nil {
 		klog.Errorf("Error occurred trying to tag resources, %v", err)
@@ -21,7 +21,9 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"fmt"
 	"os"
+	"sync"
 	"testing"
 
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
@@ -192,6 +194,61 @@ func TestHasClusterTag(t *testing.T) {
 	}
 }
 
+func TestHasOtherClusterTag(t *testing.T) {
+	awsServices := NewFakeAWSServices(TestClusterID)
+	c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+	if err != nil {
+		t.Errorf("Error building aws cloud: %v", err)
+		return
+	}
+	grid := []struct {
+		Tags     map[string]string
+		Expected bool
+	}{
+		{
+			Tags: map[string]string{},
+		},
+		{
+			Tags: map[string]string{
+				TagNameKubernetesClusterLegacy: TestClusterID,
+			},
+		},
+		{
+			Tags: map[string]string{
+				TagNameKubernetesClusterLegacy: "a",
+			},
+			Expected: true,
+		},
+		{
+			Tags: map[string]string{
+				TagNameKubernetesClusterPrefix + TestClusterID: "owned",
+			},
+		},
+		{
+			Tags: map[string]string{
+				TagNameKubernetesClusterPrefix + TestClusterID: "shared",
+				TagNameKubernetesClusterPrefix + "b":           "shared",
+			},
+			Expected: true,
+		},
+		{
+			Tags: map[string]string{
+				"other": "a",
+			},
+		},
+	}
+	for _, g := range grid {
+		var ec2Tags []ec2types.Tag
+		for k, v := range g.Tags {
+			ec2Tags = append(ec2Tags, ec2types.Tag{Key: aws.String(k), Value: aws.String(v)})
+		}
+		result := c.tagging.hasOtherClusterTag(ec2Tags)
+		if result != g.Expected {
+			t.Errorf("Unexpected result for tags %v: %t", g.Tags, result)
+		}
+	}
+}
+
 func TestHasNoClusterPrefixTag(t *testing.T) {
 	awsServices := NewFakeAWSServices(TestClusterID)
 	c, err := newAWSCloud(config.CloudConfig{}, awsServices)
@@ -293,6 +350,90 @@ func TestTagResource(t *testing.T) {
 	}
 }
 
+func TestTagResourceBatch(t *testing.T) {
+	awsServices := NewFakeAWSServices(TestClusterID)
+	c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+	if err != nil {
+		t.Errorf("Error building aws cloud: %v", err)
+		return
+	}
+	fakeEC2 := awsServices.ec2.(*FakeEC2Impl)
+	tagConcurrently := func(resourceTags map[string]map[string]string) map[string]error {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		errs := map[string]error{}
+		for resourceID, tags := range resourceTags {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := c.TagResourceBatch(context.TODO(), resourceID, tags)
+				mu.Lock()
+				defer mu.Unlock()
+				errs[resourceID] = err
+			}()
+		}
+		wg.Wait()
+		return errs
+	}
+
+	// Resources sharing the same tags are tagged with a single call
+	resourceTags := map[string]map[string]string{}
+	for i := 0; i < 30; i++ {
+		resourceTags[fmt.Sprintf("i-%d", i)] = map[string]string{"foo": "bar", "team": "a"}
+	}
+	for resourceID, err := range tagConcurrently(resourceTags) {
+		assert.NoError(t, err, "tagging %s", resourceID)
+	}
+	assert.Equal(t, 1, fakeEC2.apiCalls["CreateTags"])
+
+	// Each tag set is tagged separately
+	fakeEC2.apiCalls["CreateTags"] = 0
+	tagConcurrently(map[string]map[string]string{
+		"i-a": {"foo": "bar"},
+		"i-b": {"foo": "baz"},
+	})
+	assert.Equal(t, 2, fakeEC2.apiCalls["CreateTags"])
+
+	// A failed batch is retried per resource, so only the failing resource returns an error
+	fakeEC2.apiCalls["CreateTags"] = 0
+	errs := tagConcurrently(map[string]map[string]string{
+		"i-error": {"foo": "bar"},
+		"i-ok":    {"foo": "bar"},
+	})
+	assert.Equal(t, 3, fakeEC2.apiCalls["CreateTags"])
+	assert.Error(t, errs["i-error"])
+	assert.NoError(t, errs["i-ok"])
+}
+
+func TestReadRepairClusterTags(t *testing.T) {
+	awsServices := NewFakeAWSServices(TestClusterID)
+	c, err := newAWSCloud(config.CloudConfig{}, awsServices)
+	if err != nil {
+		t.Errorf("Error building aws cloud: %v", err)
+		return
+	}
+	fakeEC2 := awsServices.ec2.(*FakeEC2Impl)
+
+	// The missing tags of resources repaired at the same time are added with a single call
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := c.tagging.readRepairClusterTags(context.TODO(), c.createTagsBatcher, fmt.Sprintf("sg-%d", i), ResourceLifecycleOwned, nil, nil)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, fakeEC2.apiCalls["CreateTags"])
+
+	// Tagging is retried while the resource isn't found yet
+	fakeEC2.apiCalls["CreateTags"] = 0
+	err = c.tagging.readRepairClusterTags(context.TODO(), c.createTagsBatcher, "i-not-found-count-3-sg", ResourceLifecycleOwned, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, fakeEC2.apiCalls["CreateTags"])
+}
+
 func TestUntagResource(t *testing.T) {
 	testFlags := flag.NewFlagSet("TestUntagResource", flag.ExitOnError)
 	klog.InitFlags(testFlags)
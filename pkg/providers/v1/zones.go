@@ -30,6 +30,8 @@ type zoneDetails struct {
 	name     string
 	id       string
 	zoneType string
+	// networkBorderGroup is where the elastic IPs that can be used in the zone are advertised from
+	networkBorderGroup string
 }
 
 type zoneCache struct {
@@ -122,9 +124,10 @@ func (z *zoneCache) populate(ctx context.Context) error {
 	for _, zone := range zones {
 		name := aws.StringValue(zone.ZoneName)
 		z.zoneNameToDetails[name] = zoneDetails{
-			name:     name,
-			id:       aws.StringValue(zone.ZoneId),
-			zoneType: aws.StringValue(zone.ZoneType),
+			name:               name,
+			id:                 aws.StringValue(zone.ZoneId),
+			zoneType:           aws.StringValue(zone.ZoneType),
+			networkBorderGroup: aws.StringValue(zone.NetworkBorderGroup),
 		}
 	}
 